@@ -34,6 +34,7 @@ func buildImage(args []string, logger log.Logger) error {
 		}
 	}
 	request := proto.BuildImageRequest{
+		DisableBuildCache:    *disableBuildCache,
 		StreamName:           args[0],
 		ExpiresIn:            *expiresIn,
 		MaximumBuildDuration: *maximumBuildDuration,
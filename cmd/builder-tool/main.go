@@ -18,10 +18,12 @@ import (
 var (
 	alwaysShowBuildLog = flag.Bool("alwaysShowBuildLog", false,
 		"If true, show build log even for successful builds")
-	bindMounts      flagutil.StringList
-	digraphExcludes flagutil.StringList
-	digraphIncludes flagutil.StringList
-	disableFor      = flag.Duration("disableFor", 5*time.Minute,
+	bindMounts        flagutil.StringList
+	digraphExcludes   flagutil.StringList
+	digraphIncludes   flagutil.StringList
+	disableBuildCache = flag.Bool("disableBuildCache", false,
+		"If true, do not use a cached post-install tree when building")
+	disableFor = flag.Duration("disableFor", 5*time.Minute,
 		"How long to disable")
 	expiresIn = flag.Duration("expiresIn", time.Hour,
 		"How long before the image expires (auto deletes)")
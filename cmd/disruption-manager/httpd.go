@@ -35,6 +35,7 @@ func startHttpServer(dm *disruptionManager,
 	}
 	html.HandleFunc("/", s.statusHandler)
 	html.HandleFunc("/api/v1/request", s.requestHandler)
+	html.HandleFunc("/showDecisions", s.showDecisionsHandler)
 	html.HandleFunc("/showState", s.showStateHandler)
 	return s, nil
 }
@@ -102,6 +103,34 @@ func (s *httpServer) serve(portNum uint) error {
 	return http.Serve(listener, nil)
 }
 
+func (s *httpServer) showDecisionsHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	fmt.Fprintln(writer, "<title>Disruption Manager decisions page</title>")
+	fmt.Fprintln(writer, `<style>
+	                          table, th, td {
+	                          border-collapse: collapse;
+	                          }
+	                          </style>`)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<center>")
+	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
+	tw, _ := html.NewTableWriter(writer, true,
+		"Time", "Hostname", "Group", "State", "Reason")
+	for _, decision := range s.disruptionManager.getDecisions() {
+		tw.WriteRow("", "",
+			decision.Time.Format(format.TimeFormatSeconds),
+			decision.Hostname,
+			decision.GroupIdentifier,
+			decision.State.String(),
+			decision.Reason)
+	}
+	tw.Close()
+	fmt.Fprintln(writer, "</center>")
+	fmt.Fprintln(writer, "</body>")
+}
+
 func (s *httpServer) showStateHandler(w http.ResponseWriter,
 	req *http.Request) {
 	writer := bufio.NewWriter(w)
@@ -184,6 +213,12 @@ func (s *httpServer) statusHandler(w http.ResponseWriter, req *http.Request) {
 			groupList.totalWaiting)
 		fmt.Fprintln(writer, `<a href="showState">dashboard</a><br>`)
 	}
+	policy := s.disruptionManager.getPolicy()
+	if freeze := policy.activeFreeze("", time.Now()); freeze != nil {
+		fmt.Fprintf(writer, "<font color=\"red\">Global %s</font><br>\n",
+			freeze)
+	}
+	fmt.Fprintln(writer, `Recent <a href="showDecisions">decisions</a><br>`)
 	for _, htmlWriter := range s.htmlWriters {
 		htmlWriter.WriteHtml(writer)
 	}
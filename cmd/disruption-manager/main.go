@@ -20,6 +20,8 @@ var (
 	maximumPermittedDuration = flag.Duration("maximumPermittedDuration",
 		time.Hour,
 		"Maximum time disruption will be permitted after last request")
+	policyFile = flag.String("policyFile", "",
+		"Optional name of file containing disruption policy (JSON)")
 	portNum = flag.Uint("portNum", constants.DisruptionManagerPortNumber,
 		"Port number to allocate and listen on for HTTP/RPC")
 	stateDir = flag.String("stateDir", "/var/lib/disruption-manager",
@@ -48,6 +50,11 @@ func main() {
 	if err != nil {
 		logger.Fatalf("Unable to create Disruption Manager: %s\n", err)
 	}
+	if *policyFile != "" {
+		if err := dm.loadPolicyFile(*policyFile); err != nil {
+			logger.Fatalln(err)
+		}
+	}
 	err = setupserver.SetupTlsWithParams(setupserver.Params{
 		GenerateIfMissing: *generateMissingWebcert,
 		Logger:            logger,
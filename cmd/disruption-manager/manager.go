@@ -36,12 +36,15 @@ type disruptionManager struct {
 	recalculateNotifier chan<- struct{}
 	writeNotifier       chan<- struct{}
 	mutex               sync.Mutex                // Protect everything below.
+	decisions           []decisionType            // Oldest first.
 	exportable          *groupListType            // nil if invalid.
 	groups              map[string]*groupInfoType // Key: group identifier.
+	policy              *policyType               // nil if no policy.
 }
 
 type groupInfoType struct {
 	maxPermitted uint64
+	members      map[string]struct{}      // K: hostname of machines seen.
 	permitted    map[string]time.Time     // K: hostname, V: last request time.
 	requested    map[string]time.Time     // K: hostname, V: last request time.
 	waiting      map[string]*waitDataType // K: hostname.
@@ -191,32 +194,41 @@ func (dm *disruptionManager) cancel(machine mdb.Machine) (
 	defer func() {
 		dm.unlockAndInvalidate(invalidate)
 	}()
-	group, groupText := dm.getGroup(machine)
+	group, groupIdentifier, groupText := dm.getGroup(machine)
 	var logMessage string
 	if _, ok := group.permitted[machine.Hostname]; ok {
 		invalidate = true
+		delete(group.permitted, machine.Hostname)
+		dm.recordDecision(machine.Hostname, groupIdentifier,
+			sub_proto.DisruptionStateDenied, "cancelled")
 		if waitData != nil {
 			group.waiting[machine.Hostname] = waitData
 			go waitData.wait(dm.recalculateNotifier, machine.Hostname,
 				groupText, dm.logger)
 			logMessage = fmt.Sprintf("%s: permitted->denied/waiting (%s)",
 				machine.Hostname, groupText)
-		} else {
+		} else if !dm.policy.hasExternalChecks(groupIdentifier) {
 			// Move one host from Requested -> Permitted if possible.
 			for hostname, lastRequest := range group.requested {
+				ok, reason := group.canPermit(nil, groupIdentifier,
+					dm.policy)
+				if !ok {
+					break
+				}
 				group.permitted[hostname] = lastRequest
 				delete(group.requested, hostname)
+				dm.recordDecision(hostname, groupIdentifier,
+					sub_proto.DisruptionStatePermitted, reason)
 				logMessage = fmt.Sprintf(
 					"%s: permitted->denied and %s: requested->permitted (%s)",
 					machine.Hostname, hostname, groupText)
 				break
 			}
-			if logMessage == "" {
-				logMessage = fmt.Sprintf("%s: permitted->denied (%s)",
-					machine.Hostname, groupText)
-			}
 		}
-		delete(group.permitted, machine.Hostname)
+		if logMessage == "" {
+			logMessage = fmt.Sprintf("%s: permitted->denied (%s)",
+				machine.Hostname, groupText)
+		}
 	}
 	if _, ok := group.requested[machine.Hostname]; ok {
 		invalidate = true
@@ -225,18 +237,21 @@ func (dm *disruptionManager) cancel(machine mdb.Machine) (
 				machine.Hostname, groupText)
 		}
 		delete(group.requested, machine.Hostname)
+		dm.recordDecision(machine.Hostname, groupIdentifier,
+			sub_proto.DisruptionStateDenied, "cancelled")
 	}
 	return sub_proto.DisruptionStateDenied, logMessage, nil
 }
 
 func (dm *disruptionManager) check(machine mdb.Machine) (
 	sub_proto.DisruptionState, string, error) {
+	approved, approvalReason := dm.externalCheck(machine, false)
 	var invalidate bool
 	dm.mutex.Lock()
 	defer func() {
 		dm.unlockAndInvalidate(invalidate)
 	}()
-	group, groupText := dm.getGroup(machine)
+	group, groupIdentifier, groupText := dm.getGroup(machine)
 	if _, ok := group.permitted[machine.Hostname]; ok {
 		return sub_proto.DisruptionStatePermitted, "", nil
 	}
@@ -244,21 +259,55 @@ func (dm *disruptionManager) check(machine mdb.Machine) (
 	if !previouslyRequested {
 		return sub_proto.DisruptionStateDenied, "", nil
 	}
-	if !group.canPermit(machine.Tags) {
+	ok, reason := group.canPermit(machine.Tags, groupIdentifier, dm.policy)
+	if ok && !approved {
+		ok, reason = false, approvalReason
+	}
+	if !ok {
+		dm.recordDecision(machine.Hostname, groupIdentifier,
+			sub_proto.DisruptionStateRequested, reason)
 		return sub_proto.DisruptionStateRequested, "", nil
 	}
 	// Previously requested and now there is room. W00t!
 	invalidate = true
 	group.permitted[machine.Hostname] = lastRequestTime
 	delete(group.requested, machine.Hostname)
+	dm.recordDecision(machine.Hostname, groupIdentifier,
+		sub_proto.DisruptionStatePermitted,
+		joinReasons(reason, approvalReason))
 	return sub_proto.DisruptionStatePermitted,
 		fmt.Sprintf("%s: requested->permitted (%s)",
 			machine.Hostname, groupText),
 		nil
 }
 
+// externalCheck performs the external policy checks for the machine, if
+// configured and if disruption would otherwise be permitted. If requesting is
+// false, the checks are only performed for previously requested machines.
+// It returns true if disruption may proceed, and the reason.
+func (dm *disruptionManager) externalCheck(machine mdb.Machine,
+	requesting bool) (bool, string) {
+	dm.mutex.Lock()
+	group, groupIdentifier, _ := dm.getGroup(machine)
+	policy := dm.policy
+	needCheck := policy.hasExternalChecks(groupIdentifier)
+	if _, ok := group.permitted[machine.Hostname]; ok {
+		needCheck = false
+	} else if _, ok := group.requested[machine.Hostname]; !ok && !requesting {
+		needCheck = false
+	}
+	if needCheck {
+		needCheck, _ = group.canPermit(machine.Tags, groupIdentifier, policy)
+	}
+	dm.mutex.Unlock()
+	if !needCheck {
+		return true, ""
+	}
+	return policy.externalCheck(machine, groupIdentifier)
+}
+
 func (dm *disruptionManager) getGroup(machine mdb.Machine) (
-	*groupInfoType, string) {
+	*groupInfoType, string, string) {
 	var groupIdentifier string
 	if id, ok := machine.Tags[tagGroupIdentifier]; ok {
 		groupIdentifier = id
@@ -270,7 +319,8 @@ func (dm *disruptionManager) getGroup(machine mdb.Machine) (
 		group = newGroup()
 		dm.groups[groupIdentifier] = group
 	}
-	return group, makeGroupText(groupIdentifier)
+	group.members[machine.Hostname] = struct{}{}
+	return group, groupIdentifier, makeGroupText(groupIdentifier)
 }
 
 func (dm *disruptionManager) getGroupList() *groupListType {
@@ -359,6 +409,8 @@ func (dm *disruptionManager) recalculateOnce() []string {
 			if lastRequestTime.Before(expireBefore) {
 				invalidate = true
 				delete(group.permitted, hostname)
+				dm.recordDecision(hostname, groupIdentifier,
+					sub_proto.DisruptionStateDenied, "permission expired")
 				logLines = append(logLines,
 					fmt.Sprintf("%s: permitted/expired->denied (%s)",
 						hostname, groupText))
@@ -368,12 +420,20 @@ func (dm *disruptionManager) recalculateOnce() []string {
 			if lastRequestTime.Before(expireBefore) {
 				invalidate = true
 				delete(group.requested, hostname)
+				dm.recordDecision(hostname, groupIdentifier,
+					sub_proto.DisruptionStateDenied, "request expired")
 				dm.logger.Printf("%s: requested/expired->denied (%s)\n",
 					hostname, groupText)
-			} else if group.canPermit(nil) {
+			} else if dm.policy.hasExternalChecks(groupIdentifier) {
+				// Machine data are needed: wait for the next check/request.
+				continue
+			} else if ok, reason := group.canPermit(nil, groupIdentifier,
+				dm.policy); ok {
 				invalidate = true
 				group.permitted[hostname] = lastRequestTime
 				delete(group.requested, hostname)
+				dm.recordDecision(hostname, groupIdentifier,
+					sub_proto.DisruptionStatePermitted, reason)
 				logLines = append(logLines,
 					fmt.Sprintf("%s: requested->permitted (%s)",
 						hostname, groupText))
@@ -385,16 +445,24 @@ func (dm *disruptionManager) recalculateOnce() []string {
 
 func (dm *disruptionManager) request(machine mdb.Machine) (
 	sub_proto.DisruptionState, string, error) {
+	approved, approvalReason := dm.externalCheck(machine, true)
 	dm.mutex.Lock()
 	defer dm.unlockAndInvalidate(true)
-	group, groupText := dm.getGroup(machine)
+	group, groupIdentifier, groupText := dm.getGroup(machine)
 	if _, ok := group.permitted[machine.Hostname]; ok {
 		group.permitted[machine.Hostname] = time.Now()
 		return sub_proto.DisruptionStatePermitted, "", nil
 	}
 	var logMessage string
-	if group.canPermit(machine.Tags) {
+	ok, reason := group.canPermit(machine.Tags, groupIdentifier, dm.policy)
+	if ok && !approved {
+		ok, reason = false, approvalReason
+	}
+	if ok {
 		group.permitted[machine.Hostname] = time.Now()
+		dm.recordDecision(machine.Hostname, groupIdentifier,
+			sub_proto.DisruptionStatePermitted,
+			joinReasons(reason, approvalReason))
 		if _, ok := group.requested[machine.Hostname]; ok {
 			logMessage = fmt.Sprintf("%s: requested->permitted (%s)",
 				machine.Hostname, groupText)
@@ -406,9 +474,11 @@ func (dm *disruptionManager) request(machine mdb.Machine) (
 		return sub_proto.DisruptionStatePermitted, logMessage, nil
 	}
 	if _, ok := group.requested[machine.Hostname]; !ok {
-		logMessage = fmt.Sprintf("%s: denied->requested (%s)",
-			machine.Hostname, groupText)
+		logMessage = fmt.Sprintf("%s: denied->requested (%s): %s",
+			machine.Hostname, groupText, reason)
 	}
+	dm.recordDecision(machine.Hostname, groupIdentifier,
+		sub_proto.DisruptionStateRequested, reason)
 	group.requested[machine.Hostname] = time.Now()
 	return sub_proto.DisruptionStateRequested, logMessage, nil
 }
@@ -443,19 +513,50 @@ func (dm *disruptionManager) writeOnce() error {
 	return nil
 }
 
-// canPermit returns true if the group can permit more disruption.
-func (group *groupInfoType) canPermit(tgs tags.Tags) bool {
-	maximum, err := strconv.ParseUint(tgs[tagGroupMaximumDisrupting], 10, 64)
-	if err != nil || maximum < 1 {
-		maximum = 1
+// canPermit returns true if the group can permit more disruption, and the
+// reason. If tgs is nil, the most recently seen maximum is used.
+func (group *groupInfoType) canPermit(tgs tags.Tags, groupIdentifier string,
+	policy *policyType) (bool, string) {
+	if freeze := policy.activeFreeze(groupIdentifier, time.Now()); freeze != nil {
+		return false, freeze.String()
+	}
+	maximum := policy.maximumDisrupting(groupIdentifier,
+		uint(len(group.members)))
+	if maximum < 1 && tgs == nil {
+		maximum = group.maxPermitted
+	}
+	if maximum < 1 {
+		var err error
+		maximum, err = strconv.ParseUint(tgs[tagGroupMaximumDisrupting], 10,
+			64)
+		if err != nil || maximum < 1 {
+			maximum = 1
+		}
 	}
 	group.maxPermitted = maximum
-	return uint64(len(group.permitted)+len(group.waiting)) < maximum
+	numDisrupting := uint64(len(group.permitted) + len(group.waiting))
+	if numDisrupting >= maximum {
+		return false, fmt.Sprintf("%d of %d permitted disruptions in progress",
+			numDisrupting, maximum)
+	}
+	return true, fmt.Sprintf("%d of %d permitted disruptions in progress",
+		numDisrupting, maximum)
+}
+
+func joinReasons(reasons ...string) string {
+	var nonEmpty []string
+	for _, reason := range reasons {
+		if reason != "" {
+			nonEmpty = append(nonEmpty, reason)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
 }
 
 func newGroup() *groupInfoType {
 	return &groupInfoType{
 		maxPermitted: 1,
+		members:      make(map[string]struct{}),
 		permitted:    make(map[string]time.Time),
 		requested:    make(map[string]time.Time),
 		waiting:      make(map[string]*waitDataType),
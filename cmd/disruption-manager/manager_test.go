@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -230,3 +232,169 @@ func TestReadinessTimeout(t *testing.T) {
 			format.Duration(timeTaken))
 	}
 }
+
+func TestChangeFreeze(t *testing.T) {
+	logger := testlogger.New(t)
+	dm, err := newDisruptionManager("", time.Second, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm.setPolicy(&policyType{
+		ChangeFreezes: []freezeType{{
+			Start:  time.Now().Add(-time.Hour),
+			End:    time.Now().Add(time.Hour),
+			Reason: "holiday",
+		}},
+	})
+	state, _, err := dm.request(machine0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != proto.DisruptionStateRequested {
+		t.Fatalf("during freeze state: %s != %s",
+			state, proto.DisruptionStateRequested)
+	}
+	decisions := dm.getDecisions()
+	if len(decisions) != 1 {
+		t.Fatalf("number of decisions: %d != 1", len(decisions))
+	}
+	if !strings.Contains(decisions[0].Reason, "holiday") {
+		t.Fatalf("decision reason: %s", decisions[0].Reason)
+	}
+	dm.setPolicy(nil)
+	state, _, err = dm.check(machine0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != proto.DisruptionStatePermitted {
+		t.Fatalf("after freeze state: %s != %s",
+			state, proto.DisruptionStatePermitted)
+	}
+}
+
+func TestMaximumDisruptingPercent(t *testing.T) {
+	logger := testlogger.New(t)
+	dm, err := newDisruptionManager("", time.Second, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm.setPolicy(&policyType{
+		Groups: map[string]groupPolicyType{
+			".": {GroupSize: 10, MaximumDisruptingPercent: 20},
+		},
+	})
+	for index, expectedState := range []proto.DisruptionState{
+		proto.DisruptionStatePermitted,
+		proto.DisruptionStatePermitted,
+		proto.DisruptionStateRequested,
+	} {
+		machine := mdb.Machine{Hostname: fmt.Sprintf("testhost-%d", index)}
+		state, _, err := dm.request(machine)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != expectedState {
+			t.Fatalf("%s state: %s != %s",
+				machine.Hostname, state, expectedState)
+		}
+	}
+}
+
+func TestApprovalWebhook(t *testing.T) {
+	logger := testlogger.New(t)
+	listener, err := net.Listen("tcp", ":")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var approve bool
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc("/approve",
+		func(w http.ResponseWriter, req *http.Request) {
+			var request approvalRequestType
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(approvalResponseType{
+				Approved: approve,
+				Reason:   "ticket for " + request.MDB.Hostname,
+			})
+		})
+	go http.Serve(listener, serveMux)
+	dm, err := newDisruptionManager("", time.Second, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm.setPolicy(&policyType{
+		ApprovalWebhookUrl: fmt.Sprintf("http://%s/approve", listener.Addr()),
+	})
+	state, _, err := dm.request(machine0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != proto.DisruptionStateRequested {
+		t.Fatalf("unapproved state: %s != %s",
+			state, proto.DisruptionStateRequested)
+	}
+	approve = true
+	state, _, err = dm.check(machine0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != proto.DisruptionStatePermitted {
+		t.Fatalf("approved state: %s != %s",
+			state, proto.DisruptionStatePermitted)
+	}
+	decisions := dm.getDecisions()
+	if len(decisions) != 2 {
+		t.Fatalf("number of decisions: %d != 2", len(decisions))
+	}
+	if !strings.Contains(decisions[0].Reason, "ticket for testhost-0") {
+		t.Fatalf("decision reason: %s", decisions[0].Reason)
+	}
+}
+
+func TestHealthyPeers(t *testing.T) {
+	logger := testlogger.New(t)
+	listener, err := net.Listen("tcp", ":")
+	if err != nil {
+		t.Fatal(err)
+	}
+	numHealthy := "2"
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc("/healthy",
+		func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(numHealthy + "\n"))
+		})
+	go http.Serve(listener, serveMux)
+	dm, err := newDisruptionManager("", time.Second, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm.setPolicy(&policyType{
+		Groups: map[string]groupPolicyType{
+			".": {
+				HealthyPeersUrl: fmt.Sprintf("http://%s/healthy",
+					listener.Addr()),
+				MinimumHealthyPeers: 2,
+			},
+		},
+	})
+	state, _, err := dm.request(machine0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != proto.DisruptionStateRequested {
+		t.Fatalf("unhealthy state: %s != %s",
+			state, proto.DisruptionStateRequested)
+	}
+	numHealthy = "3"
+	state, _, err = dm.request(machine0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != proto.DisruptionStatePermitted {
+		t.Fatalf("healthy state: %s != %s",
+			state, proto.DisruptionStatePermitted)
+	}
+}
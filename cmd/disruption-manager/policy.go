@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	sub_proto "github.com/Cloud-Foundations/Dominator/proto/sub"
+)
+
+const (
+	decisionLogLength = 256
+	policyHttpTimeout = 10 * time.Second
+)
+
+type approvalRequestType struct {
+	GroupIdentifier string
+	MDB             mdb.Machine
+}
+
+type approvalResponseType struct {
+	Approved bool
+	Reason   string `json:",omitempty"`
+}
+
+type decisionType struct {
+	Time            time.Time
+	Hostname        string
+	GroupIdentifier string
+	State           sub_proto.DisruptionState
+	Reason          string
+}
+
+type freezeType struct {
+	Start  time.Time
+	End    time.Time
+	Reason string `json:",omitempty"`
+}
+
+type groupPolicyType struct {
+	ChangeFreezes            []freezeType `json:",omitempty"`
+	GroupSize                uint         `json:",omitempty"`
+	HealthyPeersUrl          string       `json:",omitempty"`
+	MaximumDisruptingPercent uint         `json:",omitempty"`
+	MinimumHealthyPeers      uint         `json:",omitempty"`
+}
+
+type policyType struct {
+	ApprovalWebhookUrl string                     `json:",omitempty"`
+	ChangeFreezes      []freezeType               `json:",omitempty"`
+	Groups             map[string]groupPolicyType `json:",omitempty"`
+}
+
+var httpClient = &http.Client{Timeout: policyHttpTimeout}
+
+func loadPolicy(reader io.Reader) (*policyType, error) {
+	var policy policyType
+	if err := json.Read(reader, &policy); err != nil {
+		return nil, err
+	}
+	for _, freeze := range policy.ChangeFreezes {
+		if err := freeze.check(); err != nil {
+			return nil, err
+		}
+	}
+	for groupIdentifier, groupPolicy := range policy.Groups {
+		for _, freeze := range groupPolicy.ChangeFreezes {
+			if err := freeze.check(); err != nil {
+				return nil, fmt.Errorf("%s: %s",
+					makeGroupText(groupIdentifier), err)
+			}
+		}
+		if groupPolicy.MaximumDisruptingPercent > 100 {
+			return nil, fmt.Errorf("%s: MaximumDisruptingPercent: %d > 100",
+				makeGroupText(groupIdentifier),
+				groupPolicy.MaximumDisruptingPercent)
+		}
+	}
+	return &policy, nil
+}
+
+func (freeze freezeType) check() error {
+	if freeze.Start.IsZero() || freeze.End.IsZero() {
+		return fmt.Errorf("change freeze must have Start and End times")
+	}
+	if !freeze.End.After(freeze.Start) {
+		return fmt.Errorf("change freeze End: %s is not after Start: %s",
+			freeze.End, freeze.Start)
+	}
+	return nil
+}
+
+func (freeze freezeType) String() string {
+	if freeze.Reason == "" {
+		return fmt.Sprintf("change freeze until %s",
+			freeze.End.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("change freeze until %s: %s",
+		freeze.End.Format(time.RFC3339), freeze.Reason)
+}
+
+// loadPolicyFile reads the policy file and then watches it for changes,
+// replacing the active policy each time a valid policy is read.
+func (dm *disruptionManager) loadPolicyFile(filename string) error {
+	readerChannel := fsutil.WatchFile(filename, dm.logger)
+	reader := <-readerChannel
+	policy, err := loadPolicy(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("error loading policy: %s: %s", filename, err)
+	}
+	dm.setPolicy(policy)
+	go func() {
+		for reader := range readerChannel {
+			policy, err := loadPolicy(reader)
+			reader.Close()
+			if err != nil {
+				dm.logger.Printf("error loading policy: %s: %s\n",
+					filename, err)
+				continue
+			}
+			dm.logger.Printf("loaded policy: %s\n", filename)
+			dm.setPolicy(policy)
+		}
+	}()
+	return nil
+}
+
+func (dm *disruptionManager) getDecisions() []decisionType {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+	decisions := make([]decisionType, 0, len(dm.decisions))
+	for index := len(dm.decisions) - 1; index >= 0; index-- {
+		decisions = append(decisions, dm.decisions[index])
+	}
+	return decisions
+}
+
+func (dm *disruptionManager) getPolicy() *policyType {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+	return dm.policy
+}
+
+// recordDecision adds a decision to the decision log, unless the most recent
+// decision for the host was the same. The lock must be held.
+func (dm *disruptionManager) recordDecision(hostname, groupIdentifier string,
+	state sub_proto.DisruptionState, reason string) {
+	for index := len(dm.decisions) - 1; index >= 0; index-- {
+		decision := dm.decisions[index]
+		if decision.Hostname != hostname {
+			continue
+		}
+		if decision.State == state && decision.Reason == reason {
+			return
+		}
+		break
+	}
+	if len(dm.decisions) >= decisionLogLength {
+		copy(dm.decisions, dm.decisions[1:])
+		dm.decisions = dm.decisions[:len(dm.decisions)-1]
+	}
+	dm.decisions = append(dm.decisions, decisionType{
+		Time:            time.Now(),
+		Hostname:        hostname,
+		GroupIdentifier: groupIdentifier,
+		State:           state,
+		Reason:          reason,
+	})
+}
+
+func (dm *disruptionManager) setPolicy(policy *policyType) {
+	dm.mutex.Lock()
+	dm.policy = policy
+	dm.mutex.Unlock()
+	sendNotification(dm.recalculateNotifier)
+}
+
+// activeFreeze returns the first change freeze (global or for the group)
+// which is in effect at the specified time, else nil.
+func (policy *policyType) activeFreeze(groupIdentifier string,
+	now time.Time) *freezeType {
+	if policy == nil {
+		return nil
+	}
+	for _, freeze := range policy.ChangeFreezes {
+		if !now.Before(freeze.Start) && now.Before(freeze.End) {
+			return &freeze
+		}
+	}
+	for _, freeze := range policy.Groups[groupIdentifier].ChangeFreezes {
+		if !now.Before(freeze.Start) && now.Before(freeze.End) {
+			return &freeze
+		}
+	}
+	return nil
+}
+
+// externalCheck checks the healthy peer count and the approval webhook (if
+// configured). It returns true if disruption may proceed, and the reason.
+func (policy *policyType) externalCheck(machine mdb.Machine,
+	groupIdentifier string) (bool, string) {
+	if policy == nil {
+		return true, ""
+	}
+	var reasons []string
+	groupPolicy := policy.Groups[groupIdentifier]
+	if groupPolicy.HealthyPeersUrl != "" {
+		ok, reason := checkHealthyPeers(groupPolicy.HealthyPeersUrl, machine,
+			groupPolicy.MinimumHealthyPeers)
+		if !ok {
+			return false, reason
+		}
+		reasons = append(reasons, reason)
+	}
+	if policy.ApprovalWebhookUrl != "" {
+		ok, reason := callApprovalWebhook(policy.ApprovalWebhookUrl, machine,
+			groupIdentifier)
+		if !ok {
+			return false, reason
+		}
+		reasons = append(reasons, reason)
+	}
+	return true, strings.Join(reasons, ", ")
+}
+
+// hasExternalChecks returns true if external checks are required before
+// permitting disruption for machines in the group.
+func (policy *policyType) hasExternalChecks(groupIdentifier string) bool {
+	if policy == nil {
+		return false
+	}
+	if policy.ApprovalWebhookUrl != "" {
+		return true
+	}
+	return policy.Groups[groupIdentifier].HealthyPeersUrl != ""
+}
+
+// maximumDisrupting returns the maximum number of concurrent disruptions
+// for a group of the specified size, or 0 if there is no policy limit.
+func (policy *policyType) maximumDisrupting(groupIdentifier string,
+	numSeen uint) uint64 {
+	if policy == nil {
+		return 0
+	}
+	groupPolicy := policy.Groups[groupIdentifier]
+	if groupPolicy.MaximumDisruptingPercent < 1 {
+		return 0
+	}
+	groupSize := groupPolicy.GroupSize
+	if groupSize < 1 {
+		groupSize = numSeen
+	}
+	maximum := uint64(groupSize) *
+		uint64(groupPolicy.MaximumDisruptingPercent) / 100
+	if maximum < 1 {
+		maximum = 1
+	}
+	return maximum
+}
+
+func callApprovalWebhook(url string, machine mdb.Machine,
+	groupIdentifier string) (bool, string) {
+	buffer := &bytes.Buffer{}
+	err := json.WriteWithIndent(buffer, "    ", approvalRequestType{
+		GroupIdentifier: groupIdentifier,
+		MDB:             machine,
+	})
+	if err != nil {
+		return false, "approval webhook: " + err.Error()
+	}
+	resp, err := httpClient.Post(url, "application/json", buffer)
+	if err != nil {
+		return false, "approval webhook: " + err.Error()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, "approval webhook: " + resp.Status
+	}
+	var response approvalResponseType
+	if err := json.Read(resp.Body, &response); err != nil {
+		return false, "approval webhook: " + err.Error()
+	}
+	if !response.Approved {
+		if response.Reason == "" {
+			return false, "approval webhook: not approved"
+		}
+		return false, "approval webhook: not approved: " + response.Reason
+	}
+	if response.Reason == "" {
+		return true, "approved by webhook"
+	}
+	return true, "approved by webhook: " + response.Reason
+}
+
+// checkHealthyPeers queries the URL for the number of healthy machines in the
+// group (including the specified machine). It returns true if at least
+// minimum machines will remain healthy while the machine is disrupted.
+func checkHealthyPeers(url string, machine mdb.Machine,
+	minimum uint) (bool, string) {
+	tmpl, err := template.New("").Parse(url)
+	if err != nil {
+		return false, "healthy peers URL: " + err.Error()
+	}
+	builder := &strings.Builder{}
+	if err := tmpl.Execute(builder, machine); err != nil {
+		return false, "healthy peers URL: " + err.Error()
+	}
+	resp, err := httpClient.Get(builder.String())
+	if err != nil {
+		return false, "healthy peers: " + err.Error()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, "healthy peers: " + resp.Status
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return false, "healthy peers: " + err.Error()
+	}
+	numHealthy, err := strconv.ParseUint(strings.TrimSpace(string(body)),
+		10, 64)
+	if err != nil {
+		return false, "healthy peers: " + err.Error()
+	}
+	var numRemaining uint64
+	if numHealthy > 0 {
+		numRemaining = numHealthy - 1
+	}
+	if numRemaining < uint64(minimum) {
+		return false, fmt.Sprintf("%d healthy peers would remain, need %d",
+			numRemaining, minimum)
+	}
+	return true, fmt.Sprintf("%d healthy peers would remain", numRemaining)
+}
@@ -24,6 +24,9 @@ func startRpcServer(dm *disruptionManager, logger log.DebugLogger) error {
 				"Cancel",
 				"Check",
 				"Request",
+			},
+			ReadOnlyMethods: []string{
+				"Check",
 			}})
 	return nil
 }
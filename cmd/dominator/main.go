@@ -13,6 +13,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/dom/rpcd"
 	"github.com/Cloud-Foundations/Dominator/lib/constants"
 	"github.com/Cloud-Foundations/Dominator/lib/flags/loadflags"
+	"github.com/Cloud-Foundations/Dominator/lib/grpc"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/log/serverlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/mdb"
@@ -33,6 +34,8 @@ var (
 		"If true, show debugging output")
 	fdLimit = flag.Uint64("fdLimit", getFdLimit(),
 		"Maximum number of open file descriptors (this limits concurrent connection attempts)")
+	grpcPortNum = flag.Uint("grpcPortNum", 0,
+		"Port number to listen on for the gRPC gateway (0: disabled)")
 	imageServerHostname = flag.String("imageServerHostname", "localhost",
 		"Hostname of image server")
 	imageServerPortNum = flag.Uint("imageServerPortNum",
@@ -144,6 +147,15 @@ func main() {
 			Herd:   herd,
 			Logger: logger,
 		})
+	if *grpcPortNum > 0 {
+		err := grpc.StartGatewayServer(
+			grpc.GatewayConfig{PortNumber: *grpcPortNum},
+			grpc.GatewayParams{Logger: logger})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to start gRPC gateway: %s\n", err)
+			os.Exit(1)
+		}
+	}
 	if err = herd.StartServer(*portNum, true); err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to create http server: %s\n", err)
 		os.Exit(1)
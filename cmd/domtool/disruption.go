@@ -11,6 +11,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/errors"
 	"github.com/Cloud-Foundations/Dominator/lib/json"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	dm_proto "github.com/Cloud-Foundations/Dominator/proto/disruptionmanager"
 	sub_proto "github.com/Cloud-Foundations/Dominator/proto/sub"
@@ -46,39 +47,48 @@ func disruptionOperation(requestType sub_proto.DisruptionRequest,
 	if err != nil {
 		return err
 	}
-	parsedUrl, err := url.Parse(*disruptionManagerUrl)
+	state, err := sendDisruptionRequest(requestType, machine)
 	if err != nil {
 		return err
 	}
+	fmt.Println(state)
+	return nil
+}
+
+func sendDisruptionRequest(requestType sub_proto.DisruptionRequest,
+	machine mdb.Machine) (sub_proto.DisruptionState, error) {
+	parsedUrl, err := url.Parse(*disruptionManagerUrl)
+	if err != nil {
+		return 0, err
+	}
 	switch parsedUrl.Scheme {
 	case "http", "https":
 		data := &bytes.Buffer{}
 		err := json.WriteWithIndent(data, "    ",
 			dm_proto.DisruptionRequest{MDB: machine, Request: requestType})
 		if err != nil {
-			return err
+			return 0, err
 		}
 		resp, err := http.Post(*disruptionManagerUrl, "application/json", data)
 		if err != nil {
-			return fmt.Errorf("POST error: %s", err)
+			return 0, fmt.Errorf("POST error: %s", err)
 		}
 		defer resp.Body.Close()
 		var reply dm_proto.DisruptionResponse
 		if resp.StatusCode != http.StatusOK {
 			body := &strings.Builder{}
 			io.Copy(body, resp.Body)
-			return fmt.Errorf("%s: %s",
+			return 0, fmt.Errorf("%s: %s",
 				resp.Status, strings.TrimSpace(body.String()))
 		}
 		if err := json.Read(resp.Body, &reply); err != nil {
-			return fmt.Errorf("error decoding response: %s", err)
+			return 0, fmt.Errorf("error decoding response: %s", err)
 		}
-		fmt.Println(reply.Response)
-		return nil
+		return reply.Response, nil
 	case "srpc":
 		client, err := srpc.DialHTTP("tcp", parsedUrl.Host, 0)
 		if err != nil {
-			return fmt.Errorf("error dialing: %s", err)
+			return 0, fmt.Errorf("error dialing: %s", err)
 		}
 		defer client.Close()
 		switch requestType {
@@ -88,39 +98,30 @@ func disruptionOperation(requestType sub_proto.DisruptionRequest,
 			err := client.RequestReply("DisruptionManager.Cancel",
 				request, &reply)
 			if err != nil {
-				return err
-			}
-			if err := errors.New(reply.Error); err != nil {
-				return err
+				return 0, err
 			}
-			fmt.Println(reply.Response)
+			return reply.Response, errors.New(reply.Error)
 		case sub_proto.DisruptionRequestCheck:
 			request := dm_proto.DisruptionCheckRequest{MDB: machine}
 			var reply dm_proto.DisruptionCheckResponse
 			err := client.RequestReply("DisruptionManager.Check",
 				request, &reply)
 			if err != nil {
-				return err
-			}
-			if err := errors.New(reply.Error); err != nil {
-				return err
+				return 0, err
 			}
-			fmt.Println(reply.Response)
+			return reply.Response, errors.New(reply.Error)
 		case sub_proto.DisruptionRequestRequest:
 			request := dm_proto.DisruptionRequestRequest{MDB: machine}
 			var reply dm_proto.DisruptionRequestResponse
 			err := client.RequestReply("DisruptionManager.Request",
 				request, &reply)
 			if err != nil {
-				return err
+				return 0, err
 			}
-			if err := errors.New(reply.Error); err != nil {
-				return err
-			}
-			fmt.Println(reply.Response)
+			return reply.Response, errors.New(reply.Error)
 		}
+		return 0, fmt.Errorf("unsupported request type: %d", requestType)
 	default:
-		return fmt.Errorf("unsupported scheme: %s", *disruptionManagerUrl)
+		return 0, fmt.Errorf("unsupported scheme: %s", *disruptionManagerUrl)
 	}
-	return nil
 }
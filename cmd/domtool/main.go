@@ -48,6 +48,8 @@ var (
 		"Timeout for waiting in fast update queue")
 	removePaused = flag.Bool("removePaused", false,
 		"Remove paused sub from MDB rather than disable updates")
+	rollingConcurrency = flag.Uint("rollingConcurrency", 1,
+		"Maximum number of subs to perform a rolling action on concurrently")
 	scanExcludeList  flagutil.StringList = constants.ScanExcludeList
 	scanSpeedPercent                     = flag.Uint("scanSpeedPercent",
 		constants.DefaultScanSpeedPercent,
@@ -108,6 +110,7 @@ var subcommands = []commands.Command{
 	{"pause-sub-updates", "sub reason", 2, 2, pauseSubUpdatesSubcommand},
 	{"process-mdb-template", "", 0, 0, processMdbTemplateSubcommand},
 	{"resume-sub-updates", "sub", 1, 1, resumeSubUpdatesSubcommand},
+	{"rolling-action", "action [service]", 1, 2, rollingActionSubcommand},
 	{"set-default-image", "", 1, 1, setDefaultImageSubcommand},
 }
 
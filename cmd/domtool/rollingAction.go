@@ -0,0 +1,486 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	domclient "github.com/Cloud-Foundations/Dominator/dom/client"
+	"github.com/Cloud-Foundations/Dominator/lib/backoffdelay"
+	"github.com/Cloud-Foundations/Dominator/lib/constants"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/log/prefixlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/triggers"
+	"github.com/Cloud-Foundations/Dominator/proto/dominator"
+	"github.com/Cloud-Foundations/Dominator/proto/mdbserver"
+	sub_proto "github.com/Cloud-Foundations/Dominator/proto/sub"
+	subclient "github.com/Cloud-Foundations/Dominator/sub/client"
+)
+
+const (
+	subTriggersFilename = "/.subd/triggers.previous"
+
+	tagDisruptionManagerReadyTimeout = "DisruptionManagerReadyTimeout"
+	tagDisruptionManagerReadyUrl     = "DisruptionManagerReadyUrl"
+)
+
+type rollingActionType struct {
+	name    string
+	service string
+}
+
+type rollingProgress struct {
+	mutex    sync.Mutex // Protect everything below.
+	aborted  bool
+	failed   []string
+	finished uint
+	total    uint
+}
+
+func rollingActionSubcommand(args []string, logger log.DebugLogger) error {
+	if err := rollingAction(getClient(), args, logger); err != nil {
+		return fmt.Errorf("error performing rolling action: %s", err)
+	}
+	return nil
+}
+
+func parseRollingAction(args []string) (*rollingActionType, error) {
+	action := &rollingActionType{name: args[0]}
+	switch action.name {
+	case "reboot":
+		if len(args) != 1 {
+			return nil, errors.New("reboot takes no arguments")
+		}
+	case "reload-service", "restart-service", "run-trigger":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s requires a service name", action.name)
+		}
+		action.service = args[1]
+	default:
+		return nil, fmt.Errorf("unknown action: %s", action.name)
+	}
+	return action, nil
+}
+
+func rollingAction(domClient *srpc.Client, args []string,
+	logger log.DebugLogger) error {
+	action, err := parseRollingAction(args)
+	if err != nil {
+		return err
+	}
+	if *disruptionManagerUrl == "" {
+		return errors.New("no -disruptionManagerUrl specified")
+	}
+	hostnames, err := getSubsFromFile()
+	if err != nil {
+		return err
+	}
+	reply, err := domclient.GetInfoForSubs(domClient,
+		dominator.GetInfoForSubsRequest{
+			Hostnames:        hostnames,
+			LocationsToMatch: locationsToMatch,
+			StatusesToMatch:  statusesToMatch,
+			TagsToMatch:      tagsToMatch,
+		})
+	if err != nil {
+		return err
+	}
+	subs := reply.Subs
+	if len(subs) < 1 {
+		return errors.New("no subs selected")
+	}
+	sort.Slice(subs, func(left, right int) bool {
+		return subs[left].Hostname < subs[right].Hostname
+	})
+	concurrency := int(*rollingConcurrency)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	logger.Printf("performing: %s on %d subs, concurrency: %d\n",
+		strings.Join(args, " "), len(subs), concurrency)
+	startTime := time.Now()
+	progress := &rollingProgress{total: uint(len(subs))}
+	subsChannel := make(chan mdb.Machine)
+	var waitGroup sync.WaitGroup
+	for count := 0; count < concurrency; count++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for machine := range subsChannel {
+				subLogger := prefixlogger.New(machine.Hostname+": ", logger)
+				err := action.perform(domClient, machine, subLogger)
+				progress.update(machine.Hostname, err, subLogger)
+			}
+		}()
+	}
+	for _, sub := range subs {
+		if progress.isAborted() {
+			break
+		}
+		subsChannel <- sub.Machine
+	}
+	close(subsChannel)
+	waitGroup.Wait()
+	logger.Printf("finished: %d of %d subs in %s\n",
+		progress.finished, progress.total,
+		format.Duration(time.Since(startTime)))
+	if len(progress.failed) > 0 {
+		return fmt.Errorf("aborted after failures on: %s",
+			strings.Join(progress.failed, ", "))
+	}
+	return nil
+}
+
+func (progress *rollingProgress) isAborted() bool {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	return progress.aborted
+}
+
+func (progress *rollingProgress) update(hostname string, err error,
+	logger log.Logger) {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	if err != nil {
+		progress.aborted = true
+		progress.failed = append(progress.failed, hostname)
+		logger.Printf("failed: %s\n", err)
+		return
+	}
+	progress.finished++
+	logger.Printf("completed (%d/%d)\n", progress.finished, progress.total)
+}
+
+// perform will wait for permission from the Disruption Manager, pause updates
+// by the dominator, perform the action on the sub, wait for it to become ready
+// and then resume updates and release the permission.
+func (action *rollingActionType) perform(domClient *srpc.Client,
+	machine mdb.Machine, logger log.DebugLogger) error {
+	if err := waitForDisruptionPermission(machine, logger); err != nil {
+		return err
+	}
+	defer func() {
+		_, err := sendDisruptionRequest(sub_proto.DisruptionRequestCancel,
+			machine)
+		if err != nil {
+			logger.Printf("error cancelling disruption: %s\n", err)
+		}
+	}()
+	err := pauseDominatorUpdates(domClient, machine.Hostname,
+		"domtool rolling-action "+action.name, logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resumeDominatorUpdates(machine.Hostname); err != nil {
+			logger.Printf("error resuming updates: %s\n", err)
+		}
+	}()
+	startTime := time.Now()
+	if err := action.performOnSub(machine, logger); err != nil {
+		return err
+	}
+	if action.name == "reboot" {
+		if err := waitForReboot(machine, startTime, logger); err != nil {
+			return err
+		}
+	}
+	return waitForReady(machine, logger)
+}
+
+func (action *rollingActionType) performOnSub(machine mdb.Machine,
+	logger log.DebugLogger) error {
+	client, err := dialSub(machine)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	subTriggers, err := readSubTriggers(client)
+	if err != nil {
+		return err
+	}
+	trigger, err := action.makeTrigger(subTriggers)
+	if err != nil {
+		return err
+	}
+	tmpPathname := fmt.Sprintf("/domtool-rolling-action-%d", os.Getpid())
+	logger.Debugf(0, "performing: %s\n", action.name)
+	request := makeUpdateRequest(subTriggers, trigger, tmpPathname)
+	err = subclient.CallUpdate(client, request, &sub_proto.UpdateResponse{})
+	if err != nil {
+		return err
+	}
+	if trigger.DoReboot {
+		return nil
+	}
+	var reply sub_proto.PollResponse
+	err = subclient.CallPoll(client, sub_proto.PollRequest{ShortPollOnly: true},
+		&reply)
+	if err != nil {
+		return err
+	}
+	if reply.LastUpdateError != "" {
+		return errors.New(reply.LastUpdateError)
+	}
+	if reply.LastUpdateHadTriggerFailures {
+		return errors.New("trigger failed")
+	}
+	return nil
+}
+
+// makeTrigger will make a trigger for the action. For the run-trigger action,
+// the trigger for the service is selected from the triggers saved by the sub
+// for the image it has.
+func (action *rollingActionType) makeTrigger(
+	subTriggers []*triggers.Trigger) (*triggers.Trigger, error) {
+	switch action.name {
+	case "reboot":
+		return &triggers.Trigger{Service: "reboot", DoReboot: true}, nil
+	case "reload-service":
+		return &triggers.Trigger{Service: action.service, DoReload: true}, nil
+	case "restart-service":
+		return &triggers.Trigger{Service: action.service}, nil
+	}
+	return action.selectTrigger(subTriggers)
+}
+
+// selectTrigger will make a trigger for the action from the trigger for the
+// service in subTriggers.
+func (action *rollingActionType) selectTrigger(
+	subTriggers []*triggers.Trigger) (*triggers.Trigger, error) {
+	for _, trigger := range subTriggers {
+		if trigger.Service == action.service {
+			return &triggers.Trigger{
+				Service:  trigger.Service,
+				DoReboot: trigger.DoReboot,
+				DoReload: trigger.DoReload,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("no trigger for service: %s", action.service)
+}
+
+// makeUpdateRequest will make an update request which creates and then deletes
+// tmpPathname, with trigger changed to match only tmpPathname. The sub runs the
+// "stop" phase for the matching triggers from its saved triggers merged with
+// the triggers in the request and then the "start" phase for the matching
+// triggers in the request, so a service is stopped and then started. The sub
+// replaces its saved triggers with the triggers in the request, so subTriggers
+// are included in the request.
+func makeUpdateRequest(subTriggers []*triggers.Trigger,
+	trigger *triggers.Trigger, tmpPathname string) sub_proto.UpdateRequest {
+	trigger.MatchLines = []string{tmpPathname}
+	requestTriggers := triggers.New()
+	requestTriggers.Triggers = append(requestTriggers.Triggers, subTriggers...)
+	requestTriggers.Triggers = append(requestTriggers.Triggers, trigger)
+	return sub_proto.UpdateRequest{
+		InodesToMake: []sub_proto.Inode{
+			{
+				Name:         tmpPathname,
+				GenericInode: &filesystem.RegularInode{},
+			},
+		},
+		PathsToDelete: []string{tmpPathname},
+		SparseImage:   true,
+		Triggers:      requestTriggers,
+		Wait:          !trigger.DoReboot,
+	}
+}
+
+// pauseDominatorUpdates will pause updates for the sub via the MDB server and
+// will wait until the dominator has disabled updates for the sub, so that the
+// dominator does not update the sub while the action is performed.
+func pauseDominatorUpdates(domClient *srpc.Client, hostname, reason string,
+	logger log.DebugLogger) error {
+	client, err := getMdbdClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	request := mdbserver.PauseUpdatesRequest{
+		Hostname: hostname,
+		Reason:   reason,
+		Until:    time.Now().Add(*pauseDuration),
+	}
+	var reply mdbserver.PauseUpdatesResponse
+	err = client.RequestReply("MdbServer.PauseUpdates", request, &reply)
+	if err != nil {
+		return fmt.Errorf("error pausing updates: %s", err)
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("error pausing updates: %s", reply.Error)
+	}
+	stopTime := time.Now().Add(*timeout)
+	sleeper := backoffdelay.NewExponential(time.Second, 10*time.Second, 1)
+	for ; time.Until(stopTime) > 0; sleeper.Sleep() {
+		reply, err := domclient.GetInfoForSubs(domClient,
+			dominator.GetInfoForSubsRequest{Hostnames: []string{hostname}})
+		if err != nil {
+			return err
+		}
+		if len(reply.Subs) == 1 && reply.Subs[0].DisableUpdates {
+			return nil
+		}
+		logger.Debugln(1, "waiting for dominator to pause updates")
+	}
+	resumeDominatorUpdates(hostname)
+	return errors.New("timed out waiting for dominator to pause updates")
+}
+
+func resumeDominatorUpdates(hostname string) error {
+	client, err := getMdbdClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return resumeSubUpdates(client, hostname)
+}
+
+// readSubTriggers will read the triggers which the sub saved for the image it
+// has.
+func readSubTriggers(client *srpc.Client) ([]*triggers.Trigger, error) {
+	var subTriggers []*triggers.Trigger
+	err := subclient.GetFiles(client, []string{subTriggersFilename},
+		func(reader io.Reader, size uint64) error {
+			return json.NewDecoder(reader).Decode(&subTriggers)
+		})
+	if err != nil {
+		return nil, fmt.Errorf("error reading triggers: %s", err)
+	}
+	return subTriggers, nil
+}
+
+func dialSub(machine mdb.Machine) (*srpc.Client, error) {
+	address := fmt.Sprintf("%s:%d", machine.Hostname, constants.SubPortNumber)
+	client, err := srpc.DialHTTPWithDialer("tcp", address, dialer)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing: %s: %s", address, err)
+	}
+	return client, nil
+}
+
+func waitForDisruptionPermission(machine mdb.Machine,
+	logger log.DebugLogger) error {
+	stopTime := time.Now().Add(*timeout)
+	sleeper := backoffdelay.NewExponential(time.Second, time.Minute, 1)
+	var loggedWaiting bool
+	for ; time.Until(stopTime) > 0; sleeper.Sleep() {
+		state, err := sendDisruptionRequest(
+			sub_proto.DisruptionRequestRequest, machine)
+		if err != nil {
+			return fmt.Errorf("error requesting disruption: %s", err)
+		}
+		switch state {
+		case sub_proto.DisruptionStateAnytime,
+			sub_proto.DisruptionStatePermitted:
+			return nil
+		case sub_proto.DisruptionStateRequested:
+			if !loggedWaiting {
+				logger.Println("waiting for disruption permission")
+				loggedWaiting = true
+			}
+		default:
+			return fmt.Errorf("disruption: %s", state)
+		}
+	}
+	sendDisruptionRequest(sub_proto.DisruptionRequestCancel, machine)
+	return errors.New("timed out waiting for disruption permission")
+}
+
+// waitForReboot will wait until the sub has booted after startTime.
+func waitForReboot(machine mdb.Machine, startTime time.Time,
+	logger log.DebugLogger) error {
+	logger.Println("waiting for reboot")
+	stopTime := time.Now().Add(*timeout)
+	sleeper := backoffdelay.NewExponential(5*time.Second, time.Minute, 1)
+	for ; time.Until(stopTime) > 0; sleeper.Sleep() {
+		client, err := dialSub(machine)
+		if err != nil {
+			logger.Debugf(1, "%s\n", err)
+			continue
+		}
+		var reply sub_proto.PollResponse
+		err = subclient.CallPoll(client,
+			sub_proto.PollRequest{ShortPollOnly: true}, &reply)
+		client.Close()
+		if err != nil {
+			logger.Debugf(1, "%s\n", err)
+			continue
+		}
+		if reply.SystemUptime == nil {
+			return errors.New("sub does not report uptime")
+		}
+		if reply.PollTime.Add(-*reply.SystemUptime).After(startTime) {
+			logger.Println("rebooted")
+			return nil
+		}
+	}
+	return errors.New("timed out waiting for reboot")
+}
+
+// waitForReady will wait until the sub is ready, using the same
+// DisruptionManagerReadyUrl and DisruptionManagerReadyTimeout tags as the
+// Disruption Manager.
+func waitForReady(machine mdb.Machine, logger log.DebugLogger) error {
+	var readyTimeout time.Duration
+	if value, ok := machine.Tags[tagDisruptionManagerReadyTimeout]; ok {
+		var err error
+		if readyTimeout, err = time.ParseDuration(value); err != nil {
+			return fmt.Errorf("error parsing [%s]=%s: %s",
+				tagDisruptionManagerReadyTimeout, value, err)
+		}
+	}
+	value, ok := machine.Tags[tagDisruptionManagerReadyUrl]
+	if !ok {
+		if readyTimeout > 0 {
+			logger.Printf("waiting %s before continuing\n",
+				format.Duration(readyTimeout))
+			time.Sleep(readyTimeout)
+		}
+		return nil
+	}
+	tmpl, err := template.New("").Parse(value)
+	if err != nil {
+		return fmt.Errorf("error parsing [%s]=%s: %s",
+			tagDisruptionManagerReadyUrl, value, err)
+	}
+	builder := &strings.Builder{}
+	if err := tmpl.Execute(builder, machine); err != nil {
+		return fmt.Errorf("error executing [%s]=%s: %s",
+			tagDisruptionManagerReadyUrl, value, err)
+	}
+	readyUrl := builder.String()
+	if readyTimeout <= 0 {
+		readyTimeout = 15 * time.Minute
+	}
+	logger.Printf("waiting for: %s\n", readyUrl)
+	stopTime := time.Now().Add(readyTimeout)
+	sleeper := backoffdelay.NewExponential(time.Second, 30*time.Second, 1)
+	for ; time.Until(stopTime) > 0; sleeper.Sleep() {
+		resp, err := http.Get(readyUrl)
+		if err != nil {
+			logger.Debugf(1, "%s: %s\n", readyUrl, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			logger.Debugf(1, "%s: %s\n", readyUrl, resp.Status)
+			continue
+		}
+		logger.Println("ready")
+		return nil
+	}
+	return fmt.Errorf("not ready after %s", format.Duration(readyTimeout))
+}
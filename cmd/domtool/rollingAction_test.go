@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/triggers"
+	"github.com/Cloud-Foundations/Dominator/sub/lib"
+)
+
+func TestParseRollingAction(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected *rollingActionType
+	}{
+		{[]string{"reboot"}, &rollingActionType{name: "reboot"}},
+		{[]string{"reboot", "sshd"}, nil},
+		{[]string{"reload-service", "nginx"},
+			&rollingActionType{name: "reload-service", service: "nginx"}},
+		{[]string{"reload-service"}, nil},
+		{[]string{"restart-service", "nginx"},
+			&rollingActionType{name: "restart-service", service: "nginx"}},
+		{[]string{"restart-service"}, nil},
+		{[]string{"restart-service", "nginx", "sshd"}, nil},
+		{[]string{"run-trigger", "nginx"},
+			&rollingActionType{name: "run-trigger", service: "nginx"}},
+		{[]string{"run-trigger"}, nil},
+		{[]string{"shutdown"}, nil},
+	}
+	for _, test := range tests {
+		action, err := parseRollingAction(test.args)
+		if test.expected == nil {
+			if err == nil {
+				t.Errorf("%v: no error", test.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %s", test.args, err)
+		} else if !reflect.DeepEqual(action, test.expected) {
+			t.Errorf("%v: %+v != %+v", test.args, *action, *test.expected)
+		}
+	}
+}
+
+func TestMakeTrigger(t *testing.T) {
+	tests := []struct {
+		action   rollingActionType
+		expected triggers.Trigger
+	}{
+		{rollingActionType{name: "reboot"},
+			triggers.Trigger{Service: "reboot", DoReboot: true}},
+		{rollingActionType{name: "reload-service", service: "nginx"},
+			triggers.Trigger{Service: "nginx", DoReload: true}},
+		{rollingActionType{name: "restart-service", service: "nginx"},
+			triggers.Trigger{Service: "nginx"}},
+	}
+	for _, test := range tests {
+		trigger, err := test.action.makeTrigger(nil)
+		if err != nil {
+			t.Errorf("%s: %s", test.action.name, err)
+		} else if !reflect.DeepEqual(*trigger, test.expected) {
+			t.Errorf("%s: %+v != %+v", test.action.name, *trigger,
+				test.expected)
+		}
+	}
+}
+
+func TestUpdateRequestRunsStopAndStart(t *testing.T) {
+	subTriggers := []*triggers.Trigger{
+		{
+			MatchLines: []string{"/etc/nginx/.*"},
+			Service:    "nginx",
+			DoReload:   true,
+		},
+		{
+			MatchLines: []string{"/etc/ssh/.*"},
+			Service:    "sshd",
+		},
+	}
+	tests := []struct {
+		action   rollingActionType
+		expected []string
+	}{
+		{rollingActionType{name: "restart-service", service: "nginx"},
+			[]string{"stop nginx", "start nginx"}},
+		{rollingActionType{name: "run-trigger", service: "sshd"},
+			[]string{"stop sshd", "start sshd"}},
+	}
+	for _, test := range tests {
+		trigger, err := test.action.makeTrigger(subTriggers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request := makeUpdateRequest(subTriggers, trigger,
+			"/domtool-rolling-action-test")
+		if num := len(request.Triggers.Triggers); num != 3 {
+			t.Errorf("%s: saved triggers not preserved: %d triggers",
+				test.action.name, num)
+		}
+		// The sub merges the request triggers into its saved triggers to
+		// select the triggers to stop.
+		oldTriggers := &triggers.MergeableTriggers{}
+		oldTriggers.Merge(&triggers.Triggers{Triggers: subTriggers})
+		oldTriggers.Merge(request.Triggers)
+		var actions []string
+		rootDir := t.TempDir()
+		_, _, err = lib.UpdateWithOptions(request, lib.UpdateOptions{
+			Logger:            testlogger.New(t),
+			ObjectsDir:        t.TempDir(),
+			OldTriggers:       oldTriggers.ExportTriggers(),
+			RootDirectoryName: rootDir,
+			RunTriggers: func(triggerList []*triggers.Trigger,
+				action string, logger log.Logger) bool {
+				for _, trigger := range triggerList {
+					actions = append(actions,
+						fmt.Sprintf("%s %s", action, trigger.Service))
+				}
+				return false
+			},
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", test.action.name, err)
+		}
+		if !reflect.DeepEqual(actions, test.expected) {
+			t.Errorf("%s: %v != %v", test.action.name, actions, test.expected)
+		}
+		_, err = os.Stat(filepath.Join(rootDir, "domtool-rolling-action-test"))
+		if !os.IsNotExist(err) {
+			t.Errorf("%s: temporary file not deleted", test.action.name)
+		}
+	}
+}
+
+func TestSelectTrigger(t *testing.T) {
+	subTriggers := []*triggers.Trigger{
+		{
+			MatchLines: []string{"/etc/nginx/.*"},
+			Service:    "nginx",
+			DoReload:   true,
+		},
+		{
+			MatchLines: []string{"/boot/.*"},
+			Service:    "reboot",
+			DoReboot:   true,
+		},
+		{
+			MatchLines: []string{"/etc/ssh/.*"},
+			Service:    "sshd",
+		},
+	}
+	tests := []struct {
+		service  string
+		expected *triggers.Trigger
+	}{
+		{"nginx", &triggers.Trigger{Service: "nginx", DoReload: true}},
+		{"reboot", &triggers.Trigger{Service: "reboot", DoReboot: true}},
+		{"sshd", &triggers.Trigger{Service: "sshd"}},
+		{"postfix", nil},
+	}
+	for _, test := range tests {
+		action := rollingActionType{name: "run-trigger", service: test.service}
+		trigger, err := action.selectTrigger(subTriggers)
+		if test.expected == nil {
+			if err == nil {
+				t.Errorf("%s: no error", test.service)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.service, err)
+		} else if !reflect.DeepEqual(trigger, test.expected) {
+			t.Errorf("%s: %+v != %+v", test.service, *trigger,
+				*test.expected)
+		}
+	}
+	action := rollingActionType{name: "run-trigger", service: "nginx"}
+	if _, err := action.selectTrigger(nil); err == nil {
+		t.Error("trigger selected from no triggers")
+	}
+}
@@ -59,6 +59,10 @@ func main() {
 		logger.Fatalln(err)
 	}
 	manager := filegen.New(logger)
+	if err := setupSecrets(manager, logger); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	if *configFile != "" {
 		if err := util.LoadConfiguration(manager, *configFile); err != nil {
 			fmt.Fprintln(os.Stderr, err)
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filegen"
+	"github.com/Cloud-Foundations/Dominator/lib/filegen/secrets"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+var (
+	secretsFile = flag.String("secretsFile", "",
+		"Name of encrypted file containing secrets for templates")
+	secretsKeyFile = flag.String("secretsKeyFile", "",
+		"Name of file containing the key for -secretsFile")
+	secretsRefreshInterval = flag.Duration("secretsRefreshInterval", 0,
+		"Interval between checks for changed secrets (default 5m)")
+	secretsScopesFile = flag.String("secretsScopesFile", "",
+		"Name of JSON file containing scopes restricting access to secrets")
+	vaultAddress = flag.String("vaultAddress", "",
+		"Address of Vault-compatible server providing secrets for templates")
+	vaultKvVersion = flag.Uint("vaultKvVersion", 2,
+		"Version of the Vault KV secrets engine")
+	vaultMountPath = flag.String("vaultMountPath", "secret",
+		"Mount path of the Vault KV secrets engine")
+	vaultTokenFile = flag.String("vaultTokenFile", "",
+		"Name of file containing the Vault token")
+)
+
+func setupSecrets(manager *filegen.Manager, logger log.DebugLogger) error {
+	var provider filegen.SecretsProvider
+	if *secretsFile != "" && *vaultAddress != "" {
+		return errors.New("cannot specify secrets file and Vault address")
+	} else if *secretsFile != "" {
+		if *secretsKeyFile == "" {
+			return errors.New("no secrets key file specified")
+		}
+		fileProvider, err := secrets.NewEncryptedFileProvider(*secretsFile,
+			*secretsKeyFile, logger)
+		if err != nil {
+			return err
+		}
+		provider = fileProvider
+	} else if *vaultAddress != "" {
+		vaultProvider, err := secrets.NewVaultProvider(secrets.VaultConfig{
+			Address:   *vaultAddress,
+			KvVersion: *vaultKvVersion,
+			MountPath: *vaultMountPath,
+			TokenFile: *vaultTokenFile,
+		})
+		if err != nil {
+			return err
+		}
+		provider = vaultProvider
+	} else {
+		return nil
+	}
+	if *secretsScopesFile == "" {
+		return errors.New("no secrets scopes file specified")
+	}
+	config := filegen.SecretsConfig{RefreshInterval: *secretsRefreshInterval}
+	err := json.ReadFromFile(*secretsScopesFile, &config.Scopes)
+	if err != nil {
+		return err
+	}
+	if len(config.Scopes) < 1 {
+		return errors.New("no secrets scopes in: " + *secretsScopesFile)
+	}
+	manager.SetSecretsProvider(provider, config)
+	return nil
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/constants"
 	"github.com/Cloud-Foundations/Dominator/lib/flags/loadflags"
 	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/grpc"
 	"github.com/Cloud-Foundations/Dominator/lib/json"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/log/serverlogger"
@@ -27,10 +28,14 @@ import (
 )
 
 var (
+	bmcInsecureSkipVerify = flag.Bool("bmcInsecureSkipVerify", false,
+		"If true, do not verify the certificates of Redfish BMCs")
 	checkTopology = flag.Bool("checkTopology", false,
 		"If true, perform a one-time check, write to stdout and exit")
 	createDeadline = flag.Duration("createDeadline",
 		5*time.Minute, "Deadline for creating VMs for available allocation")
+	grpcPortNum = flag.Uint("grpcPortNum", 0,
+		"Port number to listen on for the gRPC gateway (0: disabled)")
 	ipmiPasswordFile = flag.String("ipmiPasswordFile", "",
 		"Name of password file used to authenticate for IPMI requests")
 	ipmiUsername = flag.String("ipmiUsername", "",
@@ -111,10 +116,11 @@ func main() {
 		logger.Fatalf("Cannot create Hypervisors DB: %s\n", err)
 	}
 	hyperManager, err := hypervisors.New(hypervisors.StartOptions{
-		IpmiPasswordFile: *ipmiPasswordFile,
-		IpmiUsername:     *ipmiUsername,
-		Logger:           logger,
-		Storer:           hypervisorsStorer,
+		BmcInsecureSkipVerify: *bmcInsecureSkipVerify,
+		IpmiPasswordFile:      *ipmiPasswordFile,
+		IpmiUsername:          *ipmiUsername,
+		Logger:                logger,
+		Storer:                hypervisorsStorer,
 	})
 	if err != nil {
 		logger.Fatalf("Cannot create hypervisors manager: %s\n", err)
@@ -148,6 +154,14 @@ func main() {
 	if err != nil {
 		logger.Fatalf("Cannot start rpcd: %s\n", err)
 	}
+	if *grpcPortNum > 0 {
+		err := grpc.StartGatewayServer(
+			grpc.GatewayConfig{PortNumber: *grpcPortNum},
+			grpc.GatewayParams{Logger: logger})
+		if err != nil {
+			logger.Fatalf("Unable to start gRPC gateway: %s\n", err)
+		}
+	}
 	webServer, err := httpd.StartServer(*portNum, logger)
 	if err != nil {
 		logger.Fatalf("Unable to create http server: %s\n", err)
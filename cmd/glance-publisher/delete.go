@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func deleteSubcommand(args []string, logger log.DebugLogger) error {
+	if err := deleteImages(args, logger); err != nil {
+		return fmt.Errorf("error deleting images: %s", err)
+	}
+	return nil
+}
+
+func deleteImages(imageIds []string, logger log.DebugLogger) error {
+	publisher, err := getPublisher()
+	if err != nil {
+		return err
+	}
+	return publisher.DeleteImages(imageIds, logger)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	libjson "github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func listImagesSubcommand(args []string, logger log.DebugLogger) error {
+	if err := listImages(logger); err != nil {
+		return fmt.Errorf("error listing images: %s", err)
+	}
+	return nil
+}
+
+func listImages(logger log.DebugLogger) error {
+	publisher, err := getPublisher()
+	if err != nil {
+		return err
+	}
+	results, err := publisher.ListImages(searchTags, excludeSearchTags,
+		*minImageAge, logger)
+	if err != nil {
+		return err
+	}
+	return libjson.WriteWithIndent(os.Stdout, "    ", results)
+}
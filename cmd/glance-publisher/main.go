@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/imagepublishers/glancepublisher"
+	"github.com/Cloud-Foundations/Dominator/lib/constants"
+	"github.com/Cloud-Foundations/Dominator/lib/flags/commands"
+	"github.com/Cloud-Foundations/Dominator/lib/flags/loadflags"
+	"github.com/Cloud-Foundations/Dominator/lib/log/cmdlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc/setupclient"
+	libtags "github.com/Cloud-Foundations/Dominator/lib/tags"
+)
+
+var (
+	authTokenFile = flag.String("authTokenFile", "",
+		"File containing authentication token (default $OS_AUTH_TOKEN)")
+	computeEndpoint = flag.String("computeEndpoint", "",
+		"URL of compute (Nova) API, used to find images in use")
+	diskFormat = flag.String("diskFormat", glancepublisher.DiskFormatRaw,
+		"Disk format to publish (raw or vhd)")
+	excludeSearchTags libtags.Tags
+	expiresIn         = flag.Duration("expiresIn", time.Hour,
+		"Date to set for the ExpiresAt tag")
+	glanceEndpoint = flag.String("glanceEndpoint", "",
+		"URL of Glance image API (default $OS_IMAGE_URL)")
+	imageName = flag.String("imageName", "",
+		"Name of published image (default image-stream/leaf-name)")
+	imageUnpackerHostname = flag.String("imageUnpackerHostname", "localhost",
+		"Hostname of image-unpacker server")
+	imageUnpackerPortNum = flag.Uint("imageUnpackerPortNum",
+		constants.ImageUnpackerPortNumber,
+		"Port number of image-unpacker server")
+	minImageAge = flag.Duration("minImageAge", time.Hour*24,
+		"Minimum image age when listing or deleting unused images")
+	searchTags = libtags.Tags{"Preferred": "true"}
+	tags       = make(libtags.Tags)
+	visibility = flag.String("visibility", "private",
+		"Visibility of published images")
+)
+
+func init() {
+	flag.Var(&excludeSearchTags, "excludeSearchTags",
+		"Name of exclude tags to use when searching for images")
+	flag.Var(&searchTags, "searchTags",
+		"Name of tags to use when searching for images")
+	flag.Var(&tags, "tags", "Tags to apply")
+}
+
+func printUsage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintln(w, "Usage: glance-publisher [flags...] publish [args...]")
+	fmt.Fprintln(w, "Common flags:")
+	flag.PrintDefaults()
+	fmt.Fprintln(w, "Commands:")
+	commands.PrintCommands(w, subcommands)
+}
+
+var subcommands = []commands.Command{
+	{"delete", "image-id...", 1, -1, deleteSubcommand},
+	{"delete-unused-images", "", 0, 0, deleteUnusedImagesSubcommand},
+	{"expire", "", 0, 0, expireSubcommand},
+	{"list-images", "", 0, 0, listImagesSubcommand},
+	{"list-unused-images", "", 0, 0, listUnusedImagesSubcommand},
+	{"list-used-images", "", 0, 0, listUsedImagesSubcommand},
+	{"publish", "image-stream leaf-name", 2, 2, publishSubcommand},
+	{"publish-to-url", "image-stream leaf-name type url", 4, 4,
+		publishToUrlSubcommand},
+	{"set-tags", "image-id...", 1, -1, setTagsSubcommand},
+}
+
+func getPublisher() (*glancepublisher.Publisher, error) {
+	endpoint := *glanceEndpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OS_IMAGE_URL")
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("no Glance endpoint specified")
+	}
+	token := os.Getenv("OS_AUTH_TOKEN")
+	if *authTokenFile != "" {
+		data, err := os.ReadFile(*authTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	return glancepublisher.New(glancepublisher.Params{
+		ComputeEndpoint: *computeEndpoint,
+		Endpoint:        endpoint,
+		Token:           token,
+	}), nil
+}
+
+func getUnpackerAddress() string {
+	return fmt.Sprintf("%s:%d", *imageUnpackerHostname, *imageUnpackerPortNum)
+}
+
+func doMain() int {
+	if err := loadflags.LoadForCli("glance-publisher"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	cmdlogger.SetDatestampsDefault(true)
+	flag.Usage = printUsage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		printUsage()
+		return 2
+	}
+	logger := cmdlogger.New()
+	srpc.SetDefaultLogger(logger)
+	err := setupclient.SetupTlsWithParams(setupclient.Params{
+		IgnoreMissingCerts: true,
+		Logger:             logger,
+	})
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+	return commands.RunCommands(subcommands, printUsage, logger)
+}
+
+func main() {
+	os.Exit(doMain())
+}
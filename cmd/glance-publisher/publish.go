@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/imagepublishers/glancepublisher"
+	libjson "github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func publishSubcommand(args []string, logger log.DebugLogger) error {
+	if err := publish(args[0], args[1], logger); err != nil {
+		return fmt.Errorf("error publishing image: %s", err)
+	}
+	return nil
+}
+
+func publishToUrlSubcommand(args []string, logger log.DebugLogger) error {
+	err := glancepublisher.PublishToUrl(getUnpackerAddress(),
+		path.Clean(args[0]), path.Clean(args[1]), args[2], args[3], logger)
+	if err != nil {
+		return fmt.Errorf("error publishing image: %s", err)
+	}
+	return nil
+}
+
+func publish(streamName string, imageLeafName string,
+	logger log.DebugLogger) error {
+	publisher, err := getPublisher()
+	if err != nil {
+		return err
+	}
+	if *expiresIn > 0 {
+		expirationTime := time.Now().Add(*expiresIn)
+		tags[glancepublisher.ExpiresAtTag] = expirationTime.UTC().Format(
+			glancepublisher.ExpiresAtFormat)
+	}
+	image, err := publisher.Publish(glancepublisher.PublishParams{
+		DiskFormat:      *diskFormat,
+		ImageLeafName:   path.Clean(imageLeafName),
+		ImageName:       *imageName,
+		StreamName:      path.Clean(streamName),
+		Tags:            tags,
+		UnpackerAddress: getUnpackerAddress(),
+		Visibility:      *visibility,
+	}, logger)
+	if err != nil {
+		return err
+	}
+	return libjson.WriteWithIndent(os.Stdout, "    ", image)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func setTagsSubcommand(args []string, logger log.DebugLogger) error {
+	if err := setTags(args, logger); err != nil {
+		return fmt.Errorf("error setting tags: %s", err)
+	}
+	return nil
+}
+
+func setTags(imageIds []string, logger log.DebugLogger) error {
+	if len(tags) < 1 {
+		return errors.New("no tags specified")
+	}
+	publisher, err := getPublisher()
+	if err != nil {
+		return err
+	}
+	return publisher.SetTags(imageIds, tags, logger)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	libjson "github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func deleteUnusedImagesSubcommand(args []string, logger log.DebugLogger) error {
+	if err := deleteUnusedImages(logger); err != nil {
+		return fmt.Errorf("error deleting unused images: %s", err)
+	}
+	return nil
+}
+
+func listUnusedImagesSubcommand(args []string, logger log.DebugLogger) error {
+	if err := listUnusedImages(logger); err != nil {
+		return fmt.Errorf("error listing unused images: %s", err)
+	}
+	return nil
+}
+
+func deleteUnusedImages(logger log.DebugLogger) error {
+	publisher, err := getPublisher()
+	if err != nil {
+		return err
+	}
+	results, err := publisher.DeleteUnusedImages(searchTags,
+		excludeSearchTags, *minImageAge, logger)
+	if err != nil {
+		return err
+	}
+	return libjson.WriteWithIndent(os.Stdout, "    ", results)
+}
+
+func listUnusedImages(logger log.DebugLogger) error {
+	publisher, err := getPublisher()
+	if err != nil {
+		return err
+	}
+	results, err := publisher.ListUnusedImages(searchTags, excludeSearchTags,
+		*minImageAge, logger)
+	if err != nil {
+		return err
+	}
+	return libjson.WriteWithIndent(os.Stdout, "    ", results)
+}
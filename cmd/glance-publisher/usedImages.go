@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	libjson "github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func listUsedImagesSubcommand(args []string, logger log.DebugLogger) error {
+	if err := listUsedImages(logger); err != nil {
+		return fmt.Errorf("error listing used images: %s", err)
+	}
+	return nil
+}
+
+func listUsedImages(logger log.DebugLogger) error {
+	publisher, err := getPublisher()
+	if err != nil {
+		return err
+	}
+	results, err := publisher.ListUsedImages(searchTags, excludeSearchTags,
+		logger)
+	if err != nil {
+		return err
+	}
+	return libjson.WriteWithIndent(os.Stdout, "    ", results)
+}
@@ -30,6 +30,17 @@ func addSubnet(subnetId, ipGateway, ipMask string, nameservers []string,
 		IpMask:            net.ParseIP(ipMask),
 		DomainNameServers: nsIPs,
 	}
+	if *subnetIpv6Gateway != "" {
+		ip, ipNet, err := net.ParseCIDR(*subnetIpv6Gateway)
+		if err != nil {
+			return err
+		}
+		if ip.To4() != nil {
+			return fmt.Errorf("%s is not an IPv6 address", ip)
+		}
+		subnet.Ipv6Gateway = ip
+		subnet.Ipv6Mask = net.IP(ipNet.Mask)
+	}
 	subnet.Shrink()
 	request := proto.UpdateSubnetsRequest{Add: []proto.Subnet{subnet}}
 	var reply proto.UpdateSubnetsResponse
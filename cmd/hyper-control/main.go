@@ -26,7 +26,9 @@ var (
 		"connection timeout")
 	externalLeaseHostnames flagutil.StringList
 	externalLeaseAddresses proto.AddressList
-	emailBodyFilename      = flag.String("emailBodyFilename", "",
+	drainVMs               = flag.Bool("drainVMs", false,
+		"If true, rollout-image moves VMs off each used Hypervisor before upgrading and moves them back afterwards")
+	emailBodyFilename = flag.String("emailBodyFilename", "",
 		"Filename containing body of email message to send (default is to read from stdin")
 	emailDomain = flag.String("emailDomain", "",
 		"Email domain to sent notifications to")
@@ -65,6 +67,8 @@ var (
 		"How long to offer DHCP OFFERs and ACKs")
 	maxConcurrent = flag.Uint("maxConcurrent", 0,
 		"Maximum number of concurrent updates for rollout-image (default infinite)")
+	maxConcurrentPerLocation = flag.Uint("maxConcurrentPerLocation", 1,
+		"Maximum number of concurrent upgrades per location for rollout-image with -drainVMs (0: no limit)")
 	maxUpdates = flag.Uint64("maxUpdates", 0,
 		"Maximum number of updates to receive (default infinite)")
 	memory              = flagutil.Size(4 << 30)
@@ -74,6 +78,8 @@ var (
 		"How long to provide files via TFTP after last DHCP ACK")
 	netbootTimeout = flag.Duration("netbootTimeout", time.Minute,
 		"Time to wait for DHCP ACKs to be sent")
+	networkConfigFormat = flag.String("networkConfigFormat", "",
+		"Network configuration format: debian, netplan or systemd-networkd (default from topology or detected)")
 	networkInterfacesFile = flag.String("networkInterfacesFile", "",
 		"File containing network interfaces for show-network-configuration")
 	numAcknowledgementsToWaitFor = flag.Uint("numAcknowledgementsToWaitFor",
@@ -87,7 +93,9 @@ var (
 	smtpServer            = flag.String("smtpServer", "", "Address of SMTP server")
 	storageLayoutFilename = flag.String("storageLayoutFilename", "",
 		"Name of file containing storage layout for installing machine")
-	subnetIDs       flagutil.StringList
+	subnetIDs         flagutil.StringList
+	subnetIpv6Gateway = flag.String("subnetIpv6Gateway", "",
+		"Optional IPv6 gateway and prefix length (CIDR notation) for add-subnet")
 	targetImageName = flag.String("targetImageName", "",
 		"Name of image to install for netboot-{host,vm}")
 	topologyDir = flag.String("topologyDir", "",
@@ -98,7 +106,8 @@ var (
 	volumeSizes = flagutil.SizeList{16 << 30}
 	writeLock   = flag.Bool("writeLock", false, "If true, hold a write lock")
 
-	rrDialer *rrdialer.Dialer
+	restartableVmTags tags.MatchTags
+	rrDialer          *rrdialer.Dialer
 )
 
 func init() {
@@ -109,6 +118,8 @@ func init() {
 	flag.Var(&hypervisorTags, "hypervisorTags", "Tags to apply to Hypervisor")
 	flag.Var(&memory, "memory", "memory for VM")
 	flag.Var(&netbootFiles, "netbootFiles", "Extra files served by TFTP server")
+	flag.Var(&restartableVmTags, "restartableVmTags",
+		"Tags identifying VMs which may be stopped and restarted rather than migrated by rollout-image with -drainVMs")
 	flag.Var(&subnetIDs, "subnetIDs", "Subnet IDs for VM")
 	flag.Var(&volumeSizes, "volumeSizes", "Sizes for volumes for VM")
 }
@@ -40,6 +40,7 @@ type hypervisorType struct {
 	hypervisorClientResource  *srpc.ClientResource
 	initialTags               tags.Tags
 	initialUnhealthyList      map[string]struct{}
+	location                  string
 	logger                    log.DebugLogger
 	noVMs                     bool
 	subClientResource         *srpc.ClientResource
@@ -129,7 +130,7 @@ func rolloutImage(imageName string, logger log.DebugLogger) error {
 	}
 	hypervisors := make([]*hypervisorType, 0, len(hypervisorAddresses))
 	defer closeHypervisors(hypervisors)
-	tagsForHypervisors, err := getTagsForHypervisors(fleetManagerClientResource)
+	machines, err := getMachinesForHypervisors(fleetManagerClientResource)
 	logger.Debugln(0, "checking and tagging Hypervisors")
 	if err != nil {
 		return fmt.Errorf("failure getting tags: %s", err)
@@ -143,7 +144,7 @@ func rolloutImage(imageName string, logger log.DebugLogger) error {
 				cpuSharer.GrabCpu()
 				defer cpuSharer.ReleaseCpu()
 				hypervisor := setupHypervisor(hostname, imageName,
-					tagsForHypervisors[hostname], cpuSharer, logger)
+					machines[hostname], cpuSharer, logger)
 				hypervisorsChannel <- hypervisor
 			}(hostname)
 		}
@@ -210,9 +211,15 @@ func rolloutImage(imageName string, logger log.DebugLogger) error {
 	if *maxConcurrent > 0 && concurrentLimit > *maxConcurrent {
 		concurrentLimit = *maxConcurrent
 	}
-	logger.Debugln(0, "upgrading used Hypervisors")
-	err = upgradeHypervisors(fleetManagerClientResource, imageName,
-		usedHypervisors, cpuSharer, concurrentLimit)
+	if *drainVMs {
+		logger.Debugln(0, "draining and upgrading used Hypervisors")
+		err = upgradeHypervisorsWithDrain(fleetManagerClientResource,
+			imageName, usedHypervisors, cpuSharer, concurrentLimit)
+	} else {
+		logger.Debugln(0, "upgrading used Hypervisors")
+		err = upgradeHypervisors(fleetManagerClientResource, imageName,
+			usedHypervisors, cpuSharer, concurrentLimit)
+	}
 	if err != nil {
 		return err
 	}
@@ -322,8 +329,8 @@ func closeHypervisors(hypervisors []*hypervisorType) {
 	}
 }
 
-func getTagsForHypervisors(clientResource *srpc.ClientResource) (
-	map[string]tags.Tags, error) {
+func getMachinesForHypervisors(clientResource *srpc.ClientResource) (
+	map[string]*fm_proto.Machine, error) {
 	client, err := clientResource.GetHTTP(nil, 0)
 	if err != nil {
 		return nil, err
@@ -348,11 +355,11 @@ func getTagsForHypervisors(clientResource *srpc.ClientResource) (
 	if err := errors.New(reply.Error); err != nil {
 		return nil, err
 	}
-	tagsForHypervisors := make(map[string]tags.Tags, len(reply.ChangedMachines))
+	machines := make(map[string]*fm_proto.Machine, len(reply.ChangedMachines))
 	for _, machine := range reply.ChangedMachines {
-		tagsForHypervisors[machine.Hostname] = machine.Tags
+		machines[machine.Hostname] = machine
 	}
-	return tagsForHypervisors, nil
+	return machines, nil
 }
 
 func listConnectedHypervisors(clientResource *srpc.ClientResource) (
@@ -431,10 +438,16 @@ func releaseImage(imageServerClientResource *srpc.ClientResource,
 	return imageclient.ChangeImageExpiration(client, imageName, time.Time{})
 }
 
-func setupHypervisor(hostname string, imageName string, tgs tags.Tags,
-	cpuSharer *cpusharer.FifoCpuSharer,
+func setupHypervisor(hostname string, imageName string,
+	machine *fm_proto.Machine, cpuSharer *cpusharer.FifoCpuSharer,
 	logger log.DebugLogger) *hypervisorType {
 	logger = prefixlogger.New(hostname+": ", logger)
+	var location string
+	var tgs tags.Tags
+	if machine != nil {
+		location = machine.Location
+		tgs = machine.Tags
+	}
 	currentRequiredImage := tgs["RequiredImage"]
 	if currentRequiredImage != "" &&
 		path.Dir(currentRequiredImage) != path.Dir(imageName) {
@@ -458,6 +471,7 @@ func setupHypervisor(hostname string, imageName string, tgs tags.Tags,
 				constants.HypervisorPortNumber)),
 		initialTags:          tgs,
 		initialUnhealthyList: make(map[string]struct{}),
+		location:             location,
 		logger:               logger,
 		subClientResource: srpc.NewClientResource("tcp",
 			fmt.Sprintf("%s:%d", hostname, constants.SubPortNumber)),
@@ -500,6 +514,26 @@ func upgradeHypervisors(fleetManagerClientResource *srpc.ClientResource,
 	return state.Reap()
 }
 
+func (h *hypervisorType) changeTags(clientResource *srpc.ClientResource,
+	newTags tags.Tags) error {
+	client, err := clientResource.GetHTTP(nil, 0)
+	if err != nil {
+		return err
+	}
+	defer client.Put()
+	request := fm_proto.ChangeMachineTagsRequest{
+		Hostname: h.hostname,
+		Tags:     newTags,
+	}
+	var reply fm_proto.ChangeMachineTagsResponse
+	err = client.RequestReply("FleetManager.ChangeMachineTags",
+		request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
 func (h *hypervisorType) getFailingHealthChecks(
 	cpuSharer *cpusharer.FifoCpuSharer,
 	timeout time.Duration) ([]string, time.Time, error) {
@@ -565,22 +599,7 @@ func (h *hypervisorType) updateTagForHypervisor(
 	if h.initialTags.Equal(newTags) {
 		return nil
 	}
-	client, err := clientResource.GetHTTP(nil, 0)
-	if err != nil {
-		return err
-	}
-	defer client.Put()
-	request := fm_proto.ChangeMachineTagsRequest{
-		Hostname: h.hostname,
-		Tags:     newTags,
-	}
-	var reply fm_proto.ChangeMachineTagsResponse
-	err = client.RequestReply("FleetManager.ChangeMachineTags",
-		request, &reply)
-	if err != nil {
-		return err
-	}
-	return errors.New(reply.Error)
+	return h.changeTags(clientResource, newTags)
 }
 
 func (h *hypervisorType) upgrade(clientResource *srpc.ClientResource,
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	hyperclient "github.com/Cloud-Foundations/Dominator/hypervisor/client"
+	"github.com/Cloud-Foundations/Dominator/lib/constants"
+	"github.com/Cloud-Foundations/Dominator/lib/cpusharer"
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/tags/tagmatcher"
+	fm_proto "github.com/Cloud-Foundations/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+type drainedVmType struct {
+	ipAddress  net.IP
+	migratedTo string // host:port. If empty, the VM was stopped.
+}
+
+type drainerType struct {
+	fleetManagerClientResource *srpc.ClientResource
+	restartableMatcher         *tagmatcher.TagMatcher
+	mutex                      sync.Mutex // Protect everything below.
+	failed                     bool
+	destinations               map[string]uint     // Key: hostname. Count.
+	destinationsReleased       *sync.Cond          // Uses mutex.
+	upgrading                  map[string]struct{} // Key: hostname.
+}
+
+// hypervisorHasCapacity returns true if the Hypervisor has capacity for the
+// VM.
+func hypervisorHasCapacity(h fm_proto.Hypervisor,
+	vmInfo hyper_proto.VmInfo) bool {
+	if vmInfo.MemoryInMiB+h.AllocatedMemory > h.MemoryInMiB {
+		return false
+	}
+	if uint64(vmInfo.MilliCPUs)+h.AllocatedMilliCPUs > uint64(h.NumCPUs*1000) {
+		return false
+	}
+	if h.AvailableMemory > 0 && vmInfo.MemoryInMiB >= h.AvailableMemory {
+		return false
+	}
+	var totalVolumeSize uint64
+	for _, volume := range vmInfo.Volumes {
+		totalVolumeSize += volume.EffectiveSize()
+	}
+	if totalVolumeSize+h.AllocatedVolumeBytes > h.TotalVolumeBytes {
+		return false
+	}
+	subnetIDs := append([]string{vmInfo.SubnetId},
+		vmInfo.SecondarySubnetIDs...)
+	for _, subnetId := range subnetIDs {
+		if numFree, ok := h.NumFreeAddresses[subnetId]; ok && numFree < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// upgradeHypervisorsWithDrain upgrades Hypervisors, moving VMs off each
+// Hypervisor before it is upgraded and back afterwards. No more than
+// concurrentLimit Hypervisors (and no more than -maxConcurrentPerLocation
+// Hypervisors in any location) are upgraded at a time. After the first failure
+// no more upgrades are started.
+func upgradeHypervisorsWithDrain(
+	fleetManagerClientResource *srpc.ClientResource, imageName string,
+	hypervisors map[*hypervisorType]struct{},
+	cpuSharer *cpusharer.FifoCpuSharer, concurrentLimit uint) error {
+	if len(hypervisors) < 1 {
+		return nil
+	}
+	drainer := newDrainer(fleetManagerClientResource,
+		tagmatcher.New(restartableVmTags, false))
+	perLocationLimit := *maxConcurrentPerLocation
+	if perLocationLimit < 1 {
+		perLocationLimit = uint(len(hypervisors))
+	}
+	semaphore := make(chan struct{}, concurrentLimit)
+	locationSemaphores := make(map[string]chan struct{})
+	for hypervisor := range hypervisors {
+		if _, ok := locationSemaphores[hypervisor.location]; !ok {
+			locationSemaphores[hypervisor.location] =
+				make(chan struct{}, perLocationLimit)
+		}
+	}
+	errorChannel := make(chan error, len(hypervisors))
+	for hypervisor := range hypervisors {
+		go func(h *hypervisorType) {
+			locationSemaphore := locationSemaphores[h.location]
+			locationSemaphore <- struct{}{}
+			defer func() { <-locationSemaphore }()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			if drainer.hasFailed() {
+				errorChannel <- nil
+				return
+			}
+			err := h.upgradeWithDrain(drainer, imageName, cpuSharer)
+			if err != nil {
+				drainer.setFailed()
+				err = fmt.Errorf("error upgrading: %s: %s", h.hostname, err)
+			}
+			errorChannel <- err
+		}(hypervisor)
+	}
+	var firstError error
+	for range hypervisors {
+		if err := <-errorChannel; err != nil && firstError == nil {
+			firstError = err
+		}
+	}
+	return firstError
+}
+
+func newDrainer(fleetManagerClientResource *srpc.ClientResource,
+	restartableMatcher *tagmatcher.TagMatcher) *drainerType {
+	drainer := &drainerType{
+		destinations:               make(map[string]uint),
+		fleetManagerClientResource: fleetManagerClientResource,
+		restartableMatcher:         restartableMatcher,
+		upgrading:                  make(map[string]struct{}),
+	}
+	drainer.destinationsReleased = sync.NewCond(&drainer.mutex)
+	return drainer
+}
+
+// chooseDestination returns the address of the Hypervisor with the most free
+// memory which has capacity for the VM and is not being upgraded. The
+// Hypervisor is reserved as a destination until releaseDestination is called.
+func (d *drainerType) chooseDestination(hypervisors []fm_proto.Hypervisor,
+	vmInfo hyper_proto.VmInfo) (string, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var best *fm_proto.Hypervisor
+	for index := range hypervisors {
+		h := &hypervisors[index]
+		if _, ok := d.upgrading[h.Hostname]; ok {
+			continue
+		}
+		if !hypervisorHasCapacity(*h, vmInfo) {
+			continue
+		}
+		if best == nil || h.MemoryInMiB-h.AllocatedMemory >
+			best.MemoryInMiB-best.AllocatedMemory {
+			best = h
+		}
+	}
+	if best == nil {
+		return "", errors.New("no Hypervisor with capacity for VM")
+	}
+	d.destinations[best.Hostname]++
+	return fmt.Sprintf("%s:%d", best.Hostname, constants.HypervisorPortNumber),
+		nil
+}
+
+// finishUpgrade marks the Hypervisor as no longer being upgraded, so that it
+// may be selected as a destination.
+func (d *drainerType) finishUpgrade(hostname string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.upgrading, hostname)
+}
+
+func (d *drainerType) hasFailed() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.failed
+}
+
+// releaseDestination releases a reservation made by chooseDestination for the
+// Hypervisor at address.
+func (d *drainerType) releaseDestination(address string) {
+	hostname, _, err := net.SplitHostPort(address)
+	if err != nil {
+		hostname = address
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.destinations[hostname] > 1 {
+		d.destinations[hostname]--
+	} else {
+		delete(d.destinations, hostname)
+		d.destinationsReleased.Broadcast()
+	}
+}
+
+// releaseDestinations releases the destinations of the migrated VMs.
+func (d *drainerType) releaseDestinations(drainedVMs []drainedVmType) {
+	for _, vm := range drainedVMs {
+		if vm.migratedTo != "" {
+			d.releaseDestination(vm.migratedTo)
+		}
+	}
+}
+
+// selectDestination returns the address of the healthy Hypervisor in the
+// location with the most free memory which has capacity for the VM and is not
+// being upgraded. The Hypervisor is reserved as a destination until
+// releaseDestination is called.
+func (d *drainerType) selectDestination(location string,
+	vmInfo hyper_proto.VmInfo) (string, error) {
+	client, err := d.fleetManagerClientResource.GetHTTP(nil, 0)
+	if err != nil {
+		return "", err
+	}
+	defer client.Put()
+	request := fm_proto.GetHypervisorsInLocationRequest{
+		Location: location,
+		SubnetId: vmInfo.SubnetId,
+	}
+	var reply fm_proto.GetHypervisorsInLocationResponse
+	err = client.RequestReply("FleetManager.GetHypervisorsInLocation",
+		request, &reply)
+	if err != nil {
+		client.Close()
+		return "", err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return "", err
+	}
+	return d.chooseDestination(reply.Hypervisors, vmInfo)
+}
+
+func (d *drainerType) setFailed() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.failed = true
+}
+
+// startUpgrade waits until no VMs from other Hypervisors are on the
+// Hypervisor and then marks it as being upgraded, so that it is not selected
+// as a destination.
+func (d *drainerType) startUpgrade(hostname string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for d.destinations[hostname] > 0 {
+		d.destinationsReleased.Wait()
+	}
+	d.upgrading[hostname] = struct{}{}
+}
+
+func (h *hypervisorType) address() string {
+	return fmt.Sprintf("%s:%d", h.hostname, constants.HypervisorPortNumber)
+}
+
+// drain moves running VMs off the Hypervisor, stopping restartable VMs and
+// migrating the others. The VMs which were moved are returned, even on error,
+// so that they may be restored.
+func (h *hypervisorType) drain(drainer *drainerType) (
+	[]drainedVmType, error) {
+	client, err := h.hypervisorClientResource.GetHTTP(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Put()
+	request := hyper_proto.ListVMsRequest{
+		IgnoreStateMask: 1<<hyper_proto.StateFailedToStart |
+			1<<hyper_proto.StateStopping |
+			1<<hyper_proto.StateStopped |
+			1<<hyper_proto.StateDestroying |
+			1<<hyper_proto.StateCrashed,
+	}
+	ipAddresses, err := hyperclient.ListVMs(client, request)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	var drainedVMs []drainedVmType
+	for _, ipAddress := range ipAddresses {
+		vmInfo, err := hyperclient.GetVmInfo(client, ipAddress)
+		if err != nil {
+			client.Close()
+			return drainedVMs, err
+		}
+		if vmInfo.State != hyper_proto.StateRunning &&
+			vmInfo.State != hyper_proto.StateStarting {
+			client.Close()
+			return drainedVMs, fmt.Errorf("VM: %s is %s",
+				ipAddress, vmInfo.State)
+		}
+		if drainer.restartableMatcher != nil &&
+			drainer.restartableMatcher.MatchEach(vmInfo.Tags) {
+			h.logger.Debugf(0, "stopping VM: %s\n", ipAddress)
+			if err := hyperclient.StopVm(client, ipAddress, nil); err != nil {
+				client.Close()
+				return drainedVMs, err
+			}
+			drainedVMs = append(drainedVMs, drainedVmType{ipAddress: ipAddress})
+			continue
+		}
+		destination, err := drainer.selectDestination(h.location, vmInfo)
+		if err != nil {
+			return drainedVMs, fmt.Errorf("VM: %s: %s", ipAddress, err)
+		}
+		h.logger.Debugf(0, "migrating VM: %s to %s\n", ipAddress, destination)
+		err = h.migrateVm(ipAddress, h.address(), destination)
+		if err != nil {
+			drainer.releaseDestination(destination)
+			return drainedVMs, fmt.Errorf("error migrating VM: %s: %s",
+				ipAddress, err)
+		}
+		drainedVMs = append(drainedVMs, drainedVmType{
+			ipAddress:  ipAddress,
+			migratedTo: destination,
+		})
+	}
+	return drainedVMs, nil
+}
+
+// isHealthy returns true if the Fleet Manager reports the Hypervisor as
+// healthy.
+func (h *hypervisorType) isHealthy(clientResource *srpc.ClientResource) (
+	bool, error) {
+	client, err := clientResource.GetHTTP(nil, 0)
+	if err != nil {
+		return false, err
+	}
+	defer client.Put()
+	request := fm_proto.ListHypervisorsInLocationRequest{
+		Location: h.location,
+	}
+	var reply fm_proto.ListHypervisorsInLocationResponse
+	err = client.RequestReply("FleetManager.ListHypervisorsInLocation",
+		request, &reply)
+	if err != nil {
+		client.Close()
+		return false, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return false, err
+	}
+	for _, address := range reply.HypervisorAddresses {
+		if hostname, _, err := net.SplitHostPort(address); err != nil {
+			continue
+		} else if hostname == h.hostname {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (h *hypervisorType) migrateVm(ipAddress net.IP,
+	sourceAddress, destinationAddress string) error {
+	sourceClient, err := srpc.DialHTTP("tcp", sourceAddress, *connectTimeout)
+	if err != nil {
+		return err
+	}
+	defer sourceClient.Close()
+	accessToken, err := hyperclient.GetVmAccessToken(sourceClient, ipAddress,
+		time.Hour)
+	if err != nil {
+		return err
+	}
+	defer hyperclient.DiscardVmAccessToken(sourceClient, ipAddress, nil)
+	destinationClient, err := srpc.DialHTTP("tcp", destinationAddress,
+		*connectTimeout)
+	if err != nil {
+		return err
+	}
+	defer destinationClient.Close()
+	request := hyper_proto.MigrateVmRequest{
+		AccessToken:      accessToken,
+		IpAddress:        ipAddress,
+		SourceHypervisor: sourceAddress,
+	}
+	return hyperclient.MigrateVm(destinationClient, request,
+		func() bool { return true }, h.logger)
+}
+
+// restoreVMs moves drained VMs back onto the Hypervisor. All VMs are attempted
+// and the first error is returned.
+func (h *hypervisorType) restoreVMs(drainedVMs []drainedVmType) error {
+	var firstError error
+	for _, vm := range drainedVMs {
+		var err error
+		if vm.migratedTo == "" {
+			h.logger.Debugf(0, "starting VM: %s\n", vm.ipAddress)
+			err = h.startVm(vm.ipAddress)
+		} else {
+			h.logger.Debugf(0, "migrating VM: %s back from %s\n",
+				vm.ipAddress, vm.migratedTo)
+			err = h.migrateVm(vm.ipAddress, vm.migratedTo, h.address())
+		}
+		if err != nil {
+			err = fmt.Errorf("error restoring VM: %s: %s", vm.ipAddress, err)
+			h.logger.Println(err)
+			if firstError == nil {
+				firstError = err
+			}
+		}
+	}
+	return firstError
+}
+
+// rollback restores the original tags on the Hypervisor and waits for the
+// original image to be installed and for the Hypervisor to be healthy.
+func (h *hypervisorType) rollback(clientResource *srpc.ClientResource,
+	cpuSharer *cpusharer.FifoCpuSharer) error {
+	previousImage := h.initialTags["RequiredImage"]
+	if previousImage == "" {
+		return errors.New("no previous image to roll back to")
+	}
+	h.logger.Printf("rolling back to: %s\n", previousImage)
+	newTags := h.initialTags.Copy()
+	delete(newTags, "PlannedImage")
+	if err := h.changeTags(clientResource, newTags); err != nil {
+		return err
+	}
+	if err := h.waitForImage(previousImage, cpuSharer); err != nil {
+		return err
+	}
+	return h.waitForHealthy(clientResource, cpuSharer)
+}
+
+func (h *hypervisorType) setDisabledState(disable bool) error {
+	client, err := h.hypervisorClientResource.GetHTTP(nil, 0)
+	if err != nil {
+		return err
+	}
+	defer client.Put()
+	if err := hyperclient.SetDisabledState(client, disable); err != nil {
+		client.Close()
+		return err
+	}
+	return nil
+}
+
+func (h *hypervisorType) startVm(ipAddress net.IP) error {
+	client, err := h.hypervisorClientResource.GetHTTP(nil, 0)
+	if err != nil {
+		return err
+	}
+	defer client.Put()
+	if err := hyperclient.StartVm(client, ipAddress, nil); err != nil {
+		client.Close()
+		return err
+	}
+	return nil
+}
+
+// upgradeWithDrain disables the Hypervisor, moves its VMs away, upgrades it,
+// waits for it to be healthy and then moves the VMs back. If the upgrade fails
+// the Hypervisor is rolled back to the previous image. The VMs are left on
+// their new Hypervisors (or stopped) if the roll back fails. The destination
+// Hypervisors remain reserved until the VMs have been moved back, so that
+// they are not upgraded while hosting the VMs.
+func (h *hypervisorType) upgradeWithDrain(drainer *drainerType,
+	imageName string, cpuSharer *cpusharer.FifoCpuSharer) error {
+	drainer.startUpgrade(h.hostname)
+	defer drainer.finishUpgrade(h.hostname)
+	if err := h.setDisabledState(true); err != nil {
+		return err
+	}
+	h.logger.Debugln(0, "draining")
+	drainedVMs, err := h.drain(drainer)
+	defer drainer.releaseDestinations(drainedVMs)
+	if err != nil {
+		err = fmt.Errorf("error draining: %s", err)
+		// Stopped VMs cannot be started while the Hypervisor is disabled.
+		if e := h.setDisabledState(false); e != nil {
+			return fmt.Errorf("%s, error enabling: %s", err, e)
+		}
+		if e := h.restoreVMs(drainedVMs); e != nil {
+			return fmt.Errorf("%s, %s", err, e)
+		}
+		return err
+	}
+	h.logger.Debugf(0, "drained %d VMs\n", len(drainedVMs))
+	err = h.upgrade(drainer.fleetManagerClientResource, imageName, cpuSharer)
+	if err == nil {
+		err = h.waitForHealthy(drainer.fleetManagerClientResource, cpuSharer)
+	}
+	if err != nil {
+		h.logger.Printf("upgrade failed: %s\n", err)
+		e := h.rollback(drainer.fleetManagerClientResource, cpuSharer)
+		if e != nil {
+			return fmt.Errorf("%s, error rolling back: %s", err, e)
+		}
+	}
+	if e := h.setDisabledState(false); e != nil {
+		if err == nil {
+			return e
+		}
+		return fmt.Errorf("%s, error enabling: %s", err, e)
+	}
+	if e := h.restoreVMs(drainedVMs); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// waitForHealthy waits for the Fleet Manager to report the Hypervisor as
+// healthy.
+func (h *hypervisorType) waitForHealthy(clientResource *srpc.ClientResource,
+	cpuSharer *cpusharer.FifoCpuSharer) error {
+	stopTime := time.Now().Add(time.Minute * 15)
+	for ; time.Until(stopTime) > 0; cpuSharer.Sleep(time.Second * 5) {
+		if healthy, err := h.isHealthy(clientResource); err != nil {
+			h.logger.Debugln(0, err)
+		} else if healthy {
+			h.logger.Debugln(0, "healthy")
+			return nil
+		}
+	}
+	return errors.New("timed out waiting for Hypervisor to be healthy")
+}
+
+// waitForImage waits for the Hypervisor to report that the image has been
+// installed.
+func (h *hypervisorType) waitForImage(imageName string,
+	cpuSharer *cpusharer.FifoCpuSharer) error {
+	stopTime := time.Now().Add(time.Minute * 15)
+	for ; time.Until(stopTime) > 0; cpuSharer.Sleep(time.Second) {
+		if syncedImage, err := h.getLastImageName(cpuSharer); err != nil {
+			continue
+		} else if syncedImage == imageName {
+			return nil
+		}
+	}
+	return fmt.Errorf("timed out waiting for image: %s", imageName)
+}
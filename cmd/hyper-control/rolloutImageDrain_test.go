@@ -0,0 +1,329 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	"github.com/Cloud-Foundations/Dominator/lib/tags/tagmatcher"
+	fm_proto "github.com/Cloud-Foundations/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+// testHypervisorServer is a Hypervisor SRPC server which only supports the
+// methods used for draining.
+type testHypervisorServer struct {
+	mutex      sync.Mutex
+	disabled   bool
+	startError string // Returned by StartVm, if not empty.
+	vms        map[string]*hyper_proto.VmInfo
+}
+
+func (t *testHypervisorServer) GetVmInfo(conn *srpc.Conn,
+	request hyper_proto.GetVmInfoRequest,
+	reply *hyper_proto.GetVmInfoResponse) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if vm, ok := t.vms[request.IpAddress.String()]; !ok {
+		reply.Error = "no such VM"
+	} else {
+		reply.VmInfo = *vm
+	}
+	return nil
+}
+
+func (t *testHypervisorServer) ListVMs(conn *srpc.Conn,
+	request hyper_proto.ListVMsRequest,
+	reply *hyper_proto.ListVMsResponse) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	ipAddresses := make([]string, 0, len(t.vms))
+	for ipAddress := range t.vms {
+		ipAddresses = append(ipAddresses, ipAddress)
+	}
+	sort.Strings(ipAddresses) // Drain in a predictable order.
+	for _, ipAddress := range ipAddresses {
+		reply.IpAddresses = append(reply.IpAddresses, net.ParseIP(ipAddress))
+	}
+	return nil
+}
+
+func (t *testHypervisorServer) SetDisabledState(conn *srpc.Conn,
+	request hyper_proto.SetDisabledStateRequest,
+	reply *hyper_proto.SetDisabledStateResponse) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.disabled = request.Disable
+	return nil
+}
+
+func (t *testHypervisorServer) StartVm(conn *srpc.Conn,
+	request hyper_proto.StartVmRequest,
+	reply *hyper_proto.StartVmResponse) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	vm, ok := t.vms[request.IpAddress.String()]
+	if !ok {
+		reply.Error = "no such VM"
+	} else if t.disabled {
+		reply.Error = "Hypervisor is disabled"
+	} else if t.startError != "" {
+		reply.Error = t.startError
+	} else if vm.State != hyper_proto.StateStopped {
+		reply.Error = "VM is " + vm.State.String()
+	} else {
+		vm.State = hyper_proto.StateRunning
+	}
+	return nil
+}
+
+func (t *testHypervisorServer) StopVm(conn *srpc.Conn,
+	request hyper_proto.StopVmRequest,
+	reply *hyper_proto.StopVmResponse) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if vm, ok := t.vms[request.IpAddress.String()]; !ok {
+		reply.Error = "no such VM"
+	} else {
+		vm.State = hyper_proto.StateStopped
+	}
+	return nil
+}
+
+func TestHypervisorHasCapacity(t *testing.T) {
+	tests := []struct {
+		name        string
+		modifyH     func(h *fm_proto.Hypervisor)
+		modifyVm    func(vmInfo *hyper_proto.VmInfo)
+		hasCapacity bool
+	}{
+		{"fits", nil, nil, true},
+		{"exact memory", nil, func(vmInfo *hyper_proto.VmInfo) {
+			vmInfo.MemoryInMiB = 65536 - 8192
+		}, true},
+		{"too much memory", nil, func(vmInfo *hyper_proto.VmInfo) {
+			vmInfo.MemoryInMiB = 65536 - 8192 + 1
+		}, false},
+		{"too many CPUs", nil, func(vmInfo *hyper_proto.VmInfo) {
+			vmInfo.MilliCPUs = 12001
+		}, false},
+		{"available memory low", func(h *fm_proto.Hypervisor) {
+			h.AvailableMemory = 4096
+		}, nil, false},
+		{"available memory unknown", func(h *fm_proto.Hypervisor) {
+			h.AvailableMemory = 0
+		}, nil, true},
+		{"virtual size too large", nil, func(vmInfo *hyper_proto.VmInfo) {
+			vmInfo.Volumes = append(vmInfo.Volumes,
+				hyper_proto.Volume{Size: 1 << 30, VirtualSize: 1 << 39})
+		}, false},
+		{"no free addresses", nil, func(vmInfo *hyper_proto.VmInfo) {
+			vmInfo.SecondarySubnetIDs = []string{"full"}
+		}, false},
+		{"unknown subnet", nil, func(vmInfo *hyper_proto.VmInfo) {
+			vmInfo.SubnetId = "other"
+		}, true},
+	}
+	for _, test := range tests {
+		var h fm_proto.Hypervisor
+		h.Hostname = "h0"
+		h.MemoryInMiB = 65536
+		h.NumCPUs = 16
+		h.TotalVolumeBytes = 1 << 40
+		h.AllocatedMemory = 8192
+		h.AllocatedMilliCPUs = 4000
+		h.AllocatedVolumeBytes = 1 << 39
+		h.NumFreeAddresses = map[string]uint{"subnet0": 10, "full": 0}
+		vmInfo := hyper_proto.VmInfo{
+			MemoryInMiB: 4096,
+			MilliCPUs:   2000,
+			SubnetId:    "subnet0",
+			Volumes:     []hyper_proto.Volume{{Size: 1 << 30}},
+		}
+		if test.modifyH != nil {
+			test.modifyH(&h)
+		}
+		if test.modifyVm != nil {
+			test.modifyVm(&vmInfo)
+		}
+		if hasCapacity := hypervisorHasCapacity(h, vmInfo); hasCapacity !=
+			test.hasCapacity {
+			t.Errorf("%s: expected capacity: %v", test.name, test.hasCapacity)
+		}
+	}
+}
+
+func TestChooseDestination(t *testing.T) {
+	drainer := newDrainer(nil, nil)
+	var hypervisors []fm_proto.Hypervisor
+	for _, hypervisor := range []struct {
+		hostname        string
+		allocatedMemory uint64
+	}{
+		{"small-free", 62000},
+		{"most-free", 1024},
+		{"some-free", 8192},
+	} {
+		var h fm_proto.Hypervisor
+		h.Hostname = hypervisor.hostname
+		h.MemoryInMiB = 65536
+		h.NumCPUs = 16
+		h.AllocatedMemory = hypervisor.allocatedMemory
+		h.NumFreeAddresses = map[string]uint{"subnet0": 10}
+		hypervisors = append(hypervisors, h)
+	}
+	vmInfo := hyper_proto.VmInfo{
+		MemoryInMiB: 4096,
+		MilliCPUs:   2000,
+		SubnetId:    "subnet0",
+	}
+	destination, err := drainer.chooseDestination(hypervisors, vmInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if destination != "most-free:6976" {
+		t.Errorf("expected most-free:6976, got: %s", destination)
+	}
+	// Hypervisors being upgraded are skipped.
+	drainer.releaseDestination(destination)
+	drainer.startUpgrade("most-free")
+	destination, err = drainer.chooseDestination(hypervisors, vmInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if destination != "some-free:6976" {
+		t.Errorf("expected some-free:6976, got: %s", destination)
+	}
+	drainer.releaseDestination(destination)
+	drainer.startUpgrade("some-free")
+	if _, err := drainer.chooseDestination(hypervisors, vmInfo); err == nil {
+		t.Error("no error when no Hypervisor has capacity")
+	}
+	if len(drainer.destinations) > 0 {
+		t.Errorf("reservations remain: %v", drainer.destinations)
+	}
+}
+
+func TestDestinationReservation(t *testing.T) {
+	drainer := newDrainer(nil, nil)
+	var h fm_proto.Hypervisor
+	h.Hostname = "dest"
+	h.MemoryInMiB = 65536
+	h.NumCPUs = 16
+	hypervisors := []fm_proto.Hypervisor{h}
+	vmInfo := hyper_proto.VmInfo{MemoryInMiB: 4096, MilliCPUs: 2000}
+	var drainedVMs []drainedVmType
+	for count := 0; count < 2; count++ {
+		destination, err := drainer.chooseDestination(hypervisors, vmInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainedVMs = append(drainedVMs,
+			drainedVmType{migratedTo: destination})
+	}
+	drainedVMs = append(drainedVMs, drainedVmType{}) // Stopped VM.
+	// The destination may not be upgraded while it has migrated VMs.
+	started := make(chan struct{})
+	go func() {
+		drainer.startUpgrade("dest")
+		close(started)
+	}()
+	drainer.releaseDestination(drainedVMs[0].migratedTo)
+	select {
+	case <-started:
+		t.Fatal("upgrade started with VMs still migrated")
+	case <-time.After(50 * time.Millisecond):
+	}
+	drainer.releaseDestinations(drainedVMs[1:])
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upgrade not started after VMs restored")
+	}
+	if len(drainer.destinations) > 0 {
+		t.Errorf("reservations remain: %v", drainer.destinations)
+	}
+	drainer.finishUpgrade("dest")
+	if _, err := drainer.chooseDestination(hypervisors, vmInfo); err != nil {
+		t.Errorf("upgraded Hypervisor not available: %s", err)
+	}
+}
+
+func TestUpgradeWithDrainFailureRestoresVMs(t *testing.T) {
+	server := &testHypervisorServer{}
+	if err := srpc.RegisterName("Hypervisor", server); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "localhost:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go http.Serve(listener, nil)
+	restartableTags := tags.Tags{"Restartable": "true"}
+	tests := []struct {
+		name          string
+		startError    string
+		expectedError string
+	}{
+		{
+			name:          "restored",
+			expectedError: "error draining: VM: 10.0.0.3 is exporting",
+		},
+		{
+			name:       "restore failed",
+			startError: "out of memory",
+			expectedError: "error draining: VM: 10.0.0.3 is exporting, " +
+				"error restoring VM: 10.0.0.2: out of memory",
+		},
+	}
+	for _, test := range tests {
+		server.mutex.Lock()
+		server.disabled = false
+		server.startError = test.startError
+		server.vms = map[string]*hyper_proto.VmInfo{
+			"10.0.0.2": {
+				State: hyper_proto.StateRunning,
+				Tags:  restartableTags,
+			},
+			"10.0.0.3": {
+				State: hyper_proto.StateExporting,
+				Tags:  restartableTags,
+			},
+		}
+		server.mutex.Unlock()
+		h := &hypervisorType{
+			hostname: "h0",
+			hypervisorClientResource: srpc.NewClientResource("tcp",
+				listener.Addr().String()),
+			logger: testlogger.New(t),
+		}
+		drainer := newDrainer(nil,
+			tagmatcher.New(tags.MatchTags{"Restartable": {"true"}}, false))
+		err := h.upgradeWithDrain(drainer, "image", nil)
+		h.hypervisorClientResource.ScheduleClose()
+		if err == nil {
+			t.Errorf("%s: upgrade did not fail", test.name)
+		} else if !strings.Contains(err.Error(), test.expectedError) {
+			t.Errorf("%s: expected: %s, got: %s",
+				test.name, test.expectedError, err)
+		}
+		server.mutex.Lock()
+		if server.disabled {
+			t.Errorf("%s: Hypervisor left disabled", test.name)
+		}
+		state := server.vms["10.0.0.2"].State
+		server.mutex.Unlock()
+		if test.startError == "" && state != hyper_proto.StateRunning {
+			t.Errorf("%s: expected VM to be running, got: %s",
+				test.name, state)
+		}
+	}
+}
@@ -51,32 +51,44 @@ func getInfoForhost(hostname string,
 	}
 }
 
+func getNetworkConfigFormat(info fm_proto.GetMachineInfoResponse,
+	rootDir string) (string, error) {
+	if *networkConfigFormat != "" {
+		if err := configurator.CheckFormat(*networkConfigFormat); err != nil {
+			return "", err
+		}
+		return *networkConfigFormat, nil
+	}
+	return configurator.GetFormat(info, rootDir)
+}
+
 func getNetworkConfiguration(hostname string, logger log.DebugLogger) (
-	*configurator.NetworkConfig, error) {
+	*configurator.NetworkConfig, string, error) {
 	info, err := getInfoForhost(hostname, logger)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	isLocal := hostname == "" || hostname == "localhost"
 	var interfacesMap map[string]net.Interface
 	if *networkInterfacesFile == "" {
-		if hostname != "" && hostname != "localhost" {
-			return nil, errors.New("no networkInterfacesFile specified")
+		if !isLocal {
+			return nil, "", errors.New("no networkInterfacesFile specified")
 		}
 		_, interfacesMap, err = getUpInterfaces(logger)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 	} else {
 		var networkInterfaces []networkInterface
 		err := json.ReadFromFile(*networkInterfacesFile, &networkInterfaces)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		interfacesMap = make(map[string]net.Interface, len(networkInterfaces))
 		for _, netInterface := range networkInterfaces {
 			macAddress, err := net.ParseMAC(netInterface.HardwareAddr)
 			if err != nil {
-				return nil, err
+				return nil, "", err
 			}
 			netIf := net.Interface{
 				Name:         netInterface.Name,
@@ -88,7 +100,19 @@ func getNetworkConfiguration(hostname string, logger log.DebugLogger) (
 			interfacesMap[netInterface.Name] = netIf
 		}
 	}
-	return configurator.Compute(info, interfacesMap, logger)
+	var rootDir string
+	if isLocal {
+		rootDir = "/"
+	}
+	format, err := getNetworkConfigFormat(info, rootDir)
+	if err != nil {
+		return nil, "", err
+	}
+	netconf, err := configurator.Compute(info, interfacesMap, logger)
+	if err != nil {
+		return nil, "", err
+	}
+	return netconf, format, nil
 }
 
 func getUpInterfaces(logger log.DebugLogger) (
@@ -112,7 +136,8 @@ func getUpInterfaces(logger log.DebugLogger) (
 }
 
 func showNetworkConfiguration(logger log.DebugLogger) error {
-	netconf, err := getNetworkConfiguration(*hypervisorHostname, logger)
+	netconf, format, err := getNetworkConfiguration(*hypervisorHostname,
+		logger)
 	if err != nil {
 		return err
 	}
@@ -120,8 +145,8 @@ func showNetworkConfiguration(logger log.DebugLogger) error {
 		return errors.New("no default subnet found")
 	}
 	fmt.Println("=============================================================")
-	fmt.Println("Network configuration:")
-	if err := netconf.PrintDebian(os.Stdout); err != nil {
+	fmt.Printf("Network configuration (%s):\n", format)
+	if err := netconf.Print(os.Stdout, format); err != nil {
 		return err
 	}
 	fmt.Println("=============================================================")
@@ -31,7 +31,11 @@ func updateNetworkConfiguration(logger log.DebugLogger) error {
 	if err != nil {
 		return err
 	}
-	if changed, err := netconf.Update("/", logger); err != nil {
+	format, err := getNetworkConfigFormat(info, "/")
+	if err != nil {
+		return err
+	}
+	if changed, err := netconf.Update("/", format, logger); err != nil {
 		return err
 	} else if !changed {
 		return nil
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -20,6 +21,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/flags/commands"
 	"github.com/Cloud-Foundations/Dominator/lib/flags/loadflags"
 	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
+	"github.com/Cloud-Foundations/Dominator/lib/grpc"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/log/serverlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/net"
@@ -47,6 +49,10 @@ var (
 		"If true, allow unauthenticated access to read-only methods")
 	dhcpServerOnBridgesOnly = flag.Bool("dhcpServerOnBridgesOnly", false,
 		"If true, run the DHCP server on bridge interfaces only")
+	grpcPortNum = flag.Uint("grpcPortNum", 0,
+		"Port number to listen on for the gRPC gateway (0: disabled)")
+	httpBootImage = flag.String("httpBootImage", "httpboot.%d",
+		"Name of boot image passed via DHCP option to UEFI HTTP Boot clients")
 	identityProvider = flag.String("identityProvider", "",
 		"Base URL of identity provider which can issue role certificates")
 	imageServerHostname = flag.String("imageServerHostname", "localhost",
@@ -54,6 +60,8 @@ var (
 	imageServerPortNum = flag.Uint("imageServerPortNum",
 		constants.ImageServerPortNumber,
 		"Port number of image server")
+	ipxeScript = flag.String("ipxeScript", "boot.ipxe",
+		"Name of script passed via DHCP option to iPXE clients")
 	lockCheckInterval = flag.Duration("lockCheckInterval", 2*time.Second,
 		"Interval between checks for lock timeouts")
 	lockLogTimeout = flag.Duration("lockLogTimeout", 5*time.Second,
@@ -64,8 +72,9 @@ var (
 		"Name of boot image passed via DHCP option")
 	objectCacheDirectory = flag.String("objectCacheDirectory", "",
 		"Directory to store object cache (default first volume directory parent)")
-	objectCacheSize = flagutil.Size(10 << 30)
-	portNum         = flag.Uint("portNum", constants.HypervisorPortNumber,
+	objectCacheSize        = flagutil.Size(10 << 30)
+	objectDeltaMinimumSize = flagutil.Size(1 << 20)
+	portNum                = flag.Uint("portNum", constants.HypervisorPortNumber,
 		"Port number to allocate and listen on for HTTP/RPC")
 	showVGA  = flag.Bool("showVGA", false, "If true, show VGA console")
 	stateDir = flag.String("stateDir", "/var/lib/hypervisor",
@@ -76,12 +85,22 @@ var (
 		"Name of default image stream for network booting")
 	username = flag.String("username", "nobody",
 		"Name of user to run VMs")
+	vmBackupImageDirectory = flag.String("vmBackupImageDirectory", "",
+		"Name of image server directory to add VM backup images to")
+	vmBackupInterval = flag.Duration("vmBackupInterval", 0,
+		"Interval between scheduled backups of all VMs (0: disabled)")
+	vmBackupObjectServer = flag.String("vmBackupObjectServer", "",
+		"Address (host:port) of image server to backup VMs to")
+	vmBackupRunningVMs = flag.Bool("vmBackupRunningVMs", false,
+		"If true, scheduled backups include running VMs (not crash-consistent)")
 	volumeDirectories flagutil.StringList
 )
 
 func init() {
 	flag.Var(&objectCacheSize, "objectCacheSize",
 		"maximum size of object cache")
+	flag.Var(&objectDeltaMinimumSize, "objectDeltaMinimumSize",
+		"Minimum size of changed files to fetch as deltas (0: disabled)")
 	flag.Var(&volumeDirectories, "volumeDirectories",
 		"Comma separated list of volume directories. If empty, scan for space")
 }
@@ -202,6 +221,13 @@ func run() {
 	if err := dhcpServer.SetNetworkBootImage(*networkBootImage); err != nil {
 		logger.Fatalf("Cannot set NetworkBootImage name: %s\n", err)
 	}
+	if err := dhcpServer.SetHttpBootImage(*httpBootImage); err != nil {
+		logger.Fatalf("Cannot set HttpBootImage name: %s\n", err)
+	}
+	if err := dhcpServer.SetIpxeScript(*ipxeScript); err != nil {
+		logger.Fatalf("Cannot set iPXE script name: %s\n", err)
+	}
+	dhcpServer.SetHttpPortNum(*portNum)
 	imageServerAddress := fmt.Sprintf("%s:%d",
 		*imageServerHostname, *imageServerPortNum)
 	tftpbootServer, err := tftpbootd.New(imageServerAddress,
@@ -209,22 +235,28 @@ func run() {
 	if err != nil {
 		logger.Fatalf("Cannot start tftpboot server: %s\n", err)
 	}
+	http.Handle("/tftpboot/", tftpbootServer)
 	managerObj, err := manager.New(manager.StartOptions{
-		BridgeMap:            bridgeMap,
-		DhcpServer:           dhcpServer,
-		IdentityProvider:     *identityProvider,
-		ImageServerAddress:   imageServerAddress,
-		LockCheckInterval:    *lockCheckInterval,
-		LockLogTimeout:       *lockLogTimeout,
-		LocalImagesDirectory: *localImagesDirectory,
-		Logger:               logger,
-		ObjectCacheDirectory: *objectCacheDirectory,
-		ObjectCacheBytes:     uint64(objectCacheSize),
-		ShowVgaConsole:       *showVGA,
-		StateDir:             *stateDir,
-		Username:             *username,
-		VlanIdToBridge:       vlanIdToBridge,
-		VolumeDirectories:    volumeDirectories,
+		BackupImageDirectory:   *vmBackupImageDirectory,
+		BackupInterval:         *vmBackupInterval,
+		BackupObjectServer:     *vmBackupObjectServer,
+		BackupRunningVMs:       *vmBackupRunningVMs,
+		BridgeMap:              bridgeMap,
+		DhcpServer:             dhcpServer,
+		IdentityProvider:       *identityProvider,
+		ImageServerAddress:     imageServerAddress,
+		LockCheckInterval:      *lockCheckInterval,
+		LockLogTimeout:         *lockLogTimeout,
+		LocalImagesDirectory:   *localImagesDirectory,
+		Logger:                 logger,
+		ObjectCacheDirectory:   *objectCacheDirectory,
+		ObjectCacheBytes:       uint64(objectCacheSize),
+		ObjectDeltaMinimumSize: uint64(objectDeltaMinimumSize),
+		ShowVgaConsole:         *showVGA,
+		StateDir:               *stateDir,
+		Username:               *username,
+		VlanIdToBridge:         vlanIdToBridge,
+		VolumeDirectories:      volumeDirectories,
 	})
 	if err != nil {
 		logger.Fatalf("Cannot start hypervisor: %s\n", err)
@@ -251,6 +283,14 @@ func run() {
 			logger.Fatalf("Cannot start rpcd: %s\n", err)
 		}
 		httpd.AddHtmlWriter(rpcHtmlWriter)
+		if *grpcPortNum > 0 {
+			err := grpc.StartGatewayServer(
+				grpc.GatewayConfig{PortNumber: *grpcPortNum},
+				grpc.GatewayParams{Logger: logger})
+			if err != nil {
+				logger.Fatalf("Unable to start gRPC gateway: %s\n", err)
+			}
+		}
 	}
 	httpd.AddHtmlWriter(logger)
 	err = metadatad.StartServer(*portNum, bridges, managerObj, logger)
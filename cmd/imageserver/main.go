@@ -5,6 +5,7 @@ import (
 	"fmt"
 	_ "net/http/pprof"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/Cloud-Foundations/Dominator/imageserver/httpd"
@@ -12,6 +13,8 @@ import (
 	"github.com/Cloud-Foundations/Dominator/imageserver/scanner"
 	"github.com/Cloud-Foundations/Dominator/lib/constants"
 	"github.com/Cloud-Foundations/Dominator/lib/flags/loadflags"
+	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
+	"github.com/Cloud-Foundations/Dominator/lib/grpc"
 	"github.com/Cloud-Foundations/Dominator/lib/log/serverlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
@@ -33,8 +36,12 @@ var (
 		"If true, allow unauthenticated access to read-only methods")
 	debug = flag.Bool("debug", false,
 		"If true, show debugging output")
+	enforceRetentionPolicies = flag.Bool("enforceRetentionPolicies", false,
+		"If true, delete images according to directory retention policies, else only report")
 	generateMissingWebcert = flag.Bool("generateMissingWebcert", false,
 		"If true, generate a missing webcert (for SRPC server)")
+	grpcPortNum = flag.Uint("grpcPortNum", 0,
+		"Port number to listen on for the gRPC gateway (0: disabled)")
 	imageDir = flag.String("imageDir", "/var/lib/imageserver",
 		"Name of image server data directory.")
 	imageServerHostname = flag.String("imageServerHostname", "",
@@ -54,14 +61,33 @@ var (
 	maximumExpirationDurationPrivileged = flag.Duration(
 		"maximumExpirationDurationPrivileged", 730*time.Hour,
 		"Maximum expiration time for privileged users")
+	mdbServerHostname = flag.String("mdbServerHostname", "",
+		"Hostname of MDB server to protect in-use images from retention policies")
+	mdbServerPortNum = flag.Uint("mdbServerPortNum",
+		constants.SimpleMdbServerPortNumber,
+		"Port number of MDB server")
 	objectDir = flag.String("objectDir", "/var/lib/objectserver",
 		"Name of image server data directory.")
 	permitInsecureMode = flag.Bool("permitInsecureMode", false,
 		"If true, run in insecure mode. This gives remote access to all")
 	portNum = flag.Uint("portNum", constants.ImageServerPortNumber,
 		"Port number to allocate and listen on for HTTP/RPC")
+	retentionCheckInterval = flag.Duration("retentionCheckInterval", time.Hour,
+		"Interval between evaluations of retention policies (0: disabled)")
+	tombstoneLifetime = flag.Duration("tombstoneLifetime", 30*24*time.Hour,
+		"Lifetime of deleted image tombstones when replicating with peers (0: forever)")
+
+	deltaTransferMinimumSize = flagutil.Size(1 << 20)
+	replicationPeers         flagutil.StringList
 )
 
+func init() {
+	flag.Var(&deltaTransferMinimumSize, "deltaTransferMinimumSize",
+		"Minimum size of changed files to replicate as deltas (0: disabled)")
+	flag.Var(&replicationPeers, "replicationPeers",
+		"Comma separated list of peer imageservers for multi-master replication")
+}
+
 func main() {
 	if os.Geteuid() == 0 {
 		fmt.Fprintln(os.Stderr, "Do not run the Image Server as root")
@@ -105,6 +131,26 @@ func main() {
 		imageServerAddress = fmt.Sprintf("%s:%d", *imageServerHostname,
 			*imageServerPortNum)
 	}
+	if *imageServerHostname != "" && len(replicationPeers) > 0 {
+		logger.Fatalln(
+			"cannot specify both -imageServerHostname and -replicationPeers")
+	}
+	peerAddresses := make([]string, 0, len(replicationPeers))
+	for _, peer := range replicationPeers {
+		if !strings.Contains(peer, ":") {
+			peer = fmt.Sprintf("%s:%d", peer, *imageServerPortNum)
+		}
+		peerAddresses = append(peerAddresses, peer)
+	}
+	// Without peers, deleted image names are never re-used.
+	var imdbTombstoneLifetime time.Duration
+	if len(peerAddresses) > 0 {
+		imdbTombstoneLifetime = *tombstoneLifetime
+	}
+	var getReferencedImages func() (map[string]struct{}, error)
+	if *mdbServerHostname != "" {
+		getReferencedImages = getMdbImages
+	}
 	imdb, err := scanner.Load(
 		scanner.Config{
 			BaseDirectory:                       *imageDir,
@@ -113,10 +159,14 @@ func main() {
 			MaximumExpirationDuration:           *maximumExpirationDuration,
 			MaximumExpirationDurationPrivileged: *maximumExpirationDurationPrivileged,
 			ReplicationMaster:                   imageServerAddress,
+			RetentionCheckInterval:              *retentionCheckInterval,
+			RetentionEnforce:                    *enforceRetentionPolicies,
+			TombstoneLifetime:                   imdbTombstoneLifetime,
 		},
 		scanner.Params{
-			Logger:       logger,
-			ObjectServer: objSrv,
+			GetReferencedImages: getReferencedImages,
+			Logger:              logger,
+			ObjectServer:        objSrv,
 		})
 	if err != nil {
 		logger.Fatalf("Cannot load image database: %s\n", err)
@@ -127,8 +177,11 @@ func main() {
 	imgSrvRpcHtmlWriter, err := imageserverRpcd.Setup(
 		imageserverRpcd.Config{
 			AllowUnauthenticatedReads:   *allowUnauthenticatedReads,
+			DeltaStagingDirectory:       *objectDir,
+			DeltaTransferMinimumSize:    uint64(deltaTransferMinimumSize),
 			InformationDatabaseTemplate: *informationDatabaseTemplate,
 			ReplicationMaster:           imageServerAddress,
+			ReplicationPeers:            peerAddresses,
 		},
 		imageserverRpcd.Params{
 			ImageDataBase: imdb,
@@ -150,6 +203,14 @@ func main() {
 			Logger:       logger,
 			ObjectServer: objSrv,
 		})
+	if *grpcPortNum > 0 {
+		err := grpc.StartGatewayServer(
+			grpc.GatewayConfig{PortNumber: *grpcPortNum},
+			grpc.GatewayParams{Logger: logger})
+		if err != nil {
+			logger.Fatalf("Unable to start gRPC gateway: %s\n", err)
+		}
+	}
 	httpd.AddHtmlWriter(imdb)
 	httpd.AddHtmlWriter(imgSrvRpcHtmlWriter)
 	httpd.AddHtmlWriter(objSrv)
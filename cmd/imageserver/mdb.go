@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/mdbserver"
+)
+
+// getMdbImages returns the names of the images referenced by the MDB.
+func getMdbImages() (map[string]struct{}, error) {
+	address := fmt.Sprintf("%s:%d", *mdbServerHostname, *mdbServerPortNum)
+	client, err := srpc.DialHTTP("tcp", address, time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing: %s: %s", address, err)
+	}
+	defer client.Close()
+	request := mdbserver.ListImagesRequest{}
+	var reply mdbserver.ListImagesResponse
+	err = client.RequestReply("MdbServer.ListImages", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	imageNames := make(map[string]struct{},
+		len(reply.PlannedImages)+len(reply.RequiredImages))
+	for _, imageName := range reply.PlannedImages {
+		imageNames[imageName] = struct{}{}
+	}
+	for _, imageName := range reply.RequiredImages {
+		imageNames[imageName] = struct{}{}
+	}
+	return imageNames, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/imageserver/client"
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func chownChannelSubcommand(args []string, logger log.DebugLogger) error {
+	imageSClient, _ := getClients()
+	if err := client.ChownChannel(imageSClient, args[0], args[1],
+		args[2]); err != nil {
+		return fmt.Errorf("error changing channel ownership: %s", err)
+	}
+	return nil
+}
+
+func deleteChannelSubcommand(args []string, logger log.DebugLogger) error {
+	imageSClient, _ := getClients()
+	if err := client.SetChannel(imageSClient, args[0], args[1], "",
+		strings.Join(args[2:], " ")); err != nil {
+		return fmt.Errorf("error deleting channel: %s", err)
+	}
+	return nil
+}
+
+func listChannelsSubcommand(args []string, logger log.DebugLogger) error {
+	if err := listChannels(args[0]); err != nil {
+		return fmt.Errorf("error listing channels: %s", err)
+	}
+	return nil
+}
+
+func listChannels(dirname string) error {
+	imageSClient, _ := getClients()
+	channels, err := client.GetChannels(imageSClient, dirname)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(channels))
+	for name := range channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		channel := channels[name]
+		if channel.OwnerGroup == "" {
+			fmt.Printf("%s: %s\n", name, channel.ImageName)
+		} else {
+			fmt.Printf("%s: %s (owner: %s)\n",
+				name, channel.ImageName, channel.OwnerGroup)
+		}
+	}
+	return nil
+}
+
+func resolveChannelSubcommand(args []string, logger log.DebugLogger) error {
+	imageSClient, _ := getClients()
+	imageName, err := client.ResolveChannel(imageSClient, args[0])
+	if err != nil {
+		return fmt.Errorf("error resolving channel: %s", err)
+	}
+	fmt.Println(imageName)
+	return nil
+}
+
+func setChannelSubcommand(args []string, logger log.DebugLogger) error {
+	imageSClient, _ := getClients()
+	if err := client.SetChannel(imageSClient, args[0], args[1], args[2],
+		strings.Join(args[3:], " ")); err != nil {
+		return fmt.Errorf("error setting channel: %s", err)
+	}
+	return nil
+}
+
+func showChannelHistorySubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := showChannelHistory(args[0]); err != nil {
+		return fmt.Errorf("error showing channel history: %s", err)
+	}
+	return nil
+}
+
+func showChannelHistory(reference string) error {
+	dirname, channelName, ok := image.SplitChannelReference(reference)
+	if !ok {
+		return fmt.Errorf("bad channel reference: %s", reference)
+	}
+	imageSClient, _ := getClients()
+	channels, err := client.GetChannels(imageSClient, dirname)
+	if err != nil {
+		return err
+	}
+	channel, ok := channels[channelName]
+	if !ok {
+		return fmt.Errorf("unknown channel: %s", reference)
+	}
+	for _, update := range channel.History {
+		imageName := update.ImageName
+		if imageName == "" {
+			imageName = "(deleted)"
+		}
+		fmt.Printf("%s %s by %s",
+			update.UpdatedAt.Format(format.TimeFormatSeconds), imageName,
+			update.UpdatedBy)
+		if update.Comment != "" {
+			fmt.Printf(": %s", update.Comment)
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -172,10 +172,16 @@ var subcommands = []commands.Command{
 	{"check", "name", 1, 1, checkImageSubcommand},
 	{"check-directory", "dirname", 1, 1, checkDirectorySubcommand},
 	{"chown", "dirname ownerGroup", 2, 2, chownDirectorySubcommand},
+	{"chown-channel", "dirname channel ownerGroup", 3, 3,
+		chownChannelSubcommand},
+	{"clear-retention-policy", "dirname", 1, 1,
+		clearRetentionPolicySubcommand},
 	{"copy", "name oldimagename", 2, 2, copyImageSubcommand},
 	{"copy-filtered-files", "name srcdir destdir", 3, 3,
 		copyFilteredFilesSubcommand},
 	{"delete", "name", 1, 1, deleteImageSubcommand},
+	{"delete-channel", "dirname channel [comment...]", 2, -1,
+		deleteChannelSubcommand},
 	{"delunrefobj", "percentage bytes", 2, 2,
 		deleteUnreferencedObjectsSubcommand},
 	{"diff", "tool left right", 3, 3, diffSubcommand},
@@ -202,6 +208,7 @@ var subcommands = []commands.Command{
 	{"get-replication-master", "", 0, 0, getReplicationMasterSubcommand},
 	{"import-fs-tree", "dirname treeUrl", 2, 2, importFsTreeSubcommand},
 	{"list", "", 0, 0, listImagesSubcommand},
+	{"list-channels", "dirname", 1, 1, listChannelsSubcommand},
 	{"list-mdb", "", 0, 0, listMdbImagesSubcommand},
 	{"list-not-in-mdb", "", 0, 0, listImagesNotInMdbSubcommand},
 	{"listdirs", "", 0, 0, listDirectoriesSubcommand},
@@ -212,6 +219,7 @@ var subcommands = []commands.Command{
 	{"merge-triggers", "triggers-file...", 1, -1, mergeTriggersSubcommand},
 	{"mkdir", "name", 1, 1, makeDirectorySubcommand},
 	{"patch-directory", "name directory", 2, 2, patchDirectorySubcommand},
+	{"resolve-channel", "directory@channel", 1, 1, resolveChannelSubcommand},
 	{"restore-from-file", "filename", 1, 1, restoreImageSubcommand},
 	{"rmdir", "name", 1, 1, removeDirectorySubcommand},
 	{"run-command-in-image-chroot", "name cmd...", 2, -1,
@@ -219,9 +227,15 @@ var subcommands = []commands.Command{
 	{"save-to-file", "name [outfile]", 1, 2, saveImageSubcommand},
 	{"scan-filtered-files", "name directory", 2, 2,
 		scanFilteredFilesSubcommand},
+	{"set-channel", "dirname channel name [comment...]", 3, -1,
+		setChannelSubcommand},
+	{"set-retention-policy", "dirname keepLatest [maximumAge]", 2, 3,
+		setRetentionPolicySubcommand},
 	{"show", "name", 1, 1, showImageSubcommand},
 	{"show-bad-computed-files", "", 0, 0, showBadComputedFilesSubcommand},
 	{"show-bad-image-subs", "", 0, 0, showBadImageSubsSubcommand},
+	{"show-channel-history", "directory@channel", 1, 1,
+		showChannelHistorySubcommand},
 	{"show-computed-file-subs", "filename source", 2, 2,
 		showComputedFileSubsSubcommand},
 	{"show-filter", "name", 1, 1, showImageFilterSubcommand},
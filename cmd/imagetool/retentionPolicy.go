@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/imageserver/client"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+func clearRetentionPolicySubcommand(args []string,
+	logger log.DebugLogger) error {
+	imageSClient, _ := getClients()
+	if err := client.SetRetentionPolicy(imageSClient, args[0],
+		nil); err != nil {
+		return fmt.Errorf("error clearing retention policy: %s", err)
+	}
+	return nil
+}
+
+func setRetentionPolicySubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := setRetentionPolicy(args[0], args[1:]); err != nil {
+		return fmt.Errorf("error setting retention policy: %s", err)
+	}
+	return nil
+}
+
+func setRetentionPolicy(dirname string, args []string) error {
+	keepLatest, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return err
+	}
+	policy := image.RetentionPolicy{KeepLatest: uint(keepLatest)}
+	if len(args) > 1 {
+		policy.MaximumAge, err = time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+	}
+	imageSClient, _ := getClients()
+	return client.SetRetentionPolicy(imageSClient, dirname, &policy)
+}
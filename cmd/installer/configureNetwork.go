@@ -47,6 +47,11 @@ func configureNetwork(machineInfo fm_proto.GetMachineInfoResponse,
 	if err != nil {
 		return err
 	}
+	format, err := configurator.GetFormat(machineInfo, *mountPoint)
+	if err != nil {
+		return err
+	}
+	logger.Debugf(0, "writing %s network configuration\n", format)
 	mappings := make(map[string]string)
 	for name := range interfaces {
 		if err := addMapping(mappings, name); err != nil {
@@ -54,7 +59,7 @@ func configureNetwork(machineInfo fm_proto.GetMachineInfoResponse,
 		}
 	}
 	if !*dryRun {
-		if err := netconf.WriteDebian(*mountPoint); err != nil {
+		if err := netconf.Write(*mountPoint, format); err != nil {
 			return err
 		}
 		if err := writeMappings(mappings); err != nil {
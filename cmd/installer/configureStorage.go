@@ -59,13 +59,19 @@ type driveType struct {
 	devpath     string
 	mbr         *mbr.Mbr
 	name        string
-	size        uint64 // Bytes
+	raidArray   *raidArrayType // If this is a RAID array.
+	size        uint64         // Bytes
 }
 
 type kexecRebooter struct {
 	logger log.DebugLogger
 }
 
+type dataVolumeType struct {
+	mountPoint string
+	volume     *driveType
+}
+
 type partitionIndicesType struct {
 	boot  int
 	extra int
@@ -95,6 +101,10 @@ func closeEncryptedVolumes(logger log.DebugLogger) error {
 		if names, err := file.Readdirnames(-1); err != nil {
 			return err
 		} else {
+			names, err := deactivateVolumeGroups(names, logger)
+			if err != nil {
+				return err
+			}
 			for _, name := range names {
 				if name == "control" {
 					continue
@@ -109,65 +119,17 @@ func closeEncryptedVolumes(logger log.DebugLogger) error {
 	}
 }
 
-func configureBootDrive(cpuSharer cpusharer.CpuSharer, drive *driveType,
-	layout installer_proto.StorageLayout, partitionIndices partitionIndicesType,
-	img *image.Image, objGetter objectserver.ObjectsGetter,
-	bootInfo *util.BootInfoType, logger log.DebugLogger) error {
-	startTime := time.Now()
-	if run("blkdiscard", "", logger, drive.devpath) == nil {
-		drive.discarded = true
-		logger.Printf("discarded %s in %s\n",
-			drive.devpath, format.Duration(time.Since(startTime)))
-	} else { // Erase old partition.
-		if err := eraseStart(drive.devpath, logger); err != nil {
+func configureBootDrive(cpuSharer cpusharer.CpuSharer,
+	bootDrive *bootDriveType, layout installer_proto.StorageLayout,
+	partitionIndices partitionIndicesType,
+	physicalVolumes map[string]struct{}, logger log.DebugLogger) error {
+	for _, drive := range bootDrive.drives {
+		err := drive.makePartitions(layout, partitionIndices, logger)
+		if err != nil {
 			return err
 		}
 	}
-	isEfi := checkIsEfi()
-	args := []string{"-s", "-a", "optimal", drive.devpath}
-	if isEfi {
-		args = append(args, "mklabel", "gpt")
-	} else {
-		args = append(args, "mklabel", "msdos")
-	}
-	unitSize := uint64(1 << 20)
-	unitSuffix := "MiB"
-	offsetInUnits := uint64(1)
-	for _, partition := range layout.BootDriveLayout {
-		minimumSize := partition.MinimumFreeBytes
-		if partition.MinimumBytes > minimumSize {
-			minimumSize = partition.MinimumBytes
-		}
-		sizeInUnits := minimumSize / unitSize
-		if sizeInUnits*unitSize < minimumSize {
-			sizeInUnits++
-		}
-		var partType string
-		switch partition.FileSystemType {
-		case installer_proto.FileSystemTypeVfat:
-			partType = "fat32"
-		default:
-			partType = partition.FileSystemType.String()
-		}
-		args = append(args, "mkpart", "primary", partType)
-		if minimumSize > 0 {
-			args = append(args,
-				strconv.FormatUint(offsetInUnits, 10)+unitSuffix,
-				strconv.FormatUint(offsetInUnits+sizeInUnits, 10)+unitSuffix)
-			offsetInUnits += sizeInUnits
-		} else {
-			args = append(args,
-				strconv.FormatUint(offsetInUnits, 10)+unitSuffix, "100%")
-		}
-	}
-	if isEfi { // EFI System Partition is always the first partition.
-		args = append(args, "set", "1", "esp", "on")
-	} else {
-		args = append(args,
-			"set", strconv.FormatInt(int64(partitionIndices.boot), 10), "boot",
-			"on")
-	}
-	if err := run("parted", *tmpRoot, logger, args...); err != nil {
+	if err := bootDrive.makeDevices(logger); err != nil {
 		return err
 	}
 	// Prepare all file-systems concurrently, make them serially.
@@ -175,7 +137,7 @@ func configureBootDrive(cpuSharer cpusharer.CpuSharer, drive *driveType,
 		len(layout.BootDriveLayout) + 1))
 	var mkfsMutex sync.Mutex
 	for index, partition := range layout.BootDriveLayout {
-		device := partitionName(drive.devpath, index+1)
+		volume := bootDrive.volume(index + 1)
 		partition := partition
 		var encrypt bool
 		switch partition.MountPoint {
@@ -185,67 +147,73 @@ func configureBootDrive(cpuSharer cpusharer.CpuSharer, drive *driveType,
 		default:
 			encrypt = layout.Encrypt
 		}
-		var bytesPerInode uint
-		if partition.MinimumBytes < 1 && partition.MinimumFreeBytes < 1 {
-			bytesPerInode = 65536
+		var err error
+		if _, ok := physicalVolumes[partition.MountPoint]; ok {
+			err = concurrentState.GoRun(func() error {
+				return volume.makePhysicalVolume(cpuSharer, volume.devpath,
+					encrypt, logger)
+			})
+		} else {
+			var bytesPerInode uint
+			if partition.MinimumBytes < 1 && partition.MinimumFreeBytes < 1 {
+				bytesPerInode = 65536
+			}
+			err = concurrentState.GoRun(func() error {
+				return volume.makeFileSystem(cpuSharer, volume.devpath,
+					partition.FileSystemLabel, partition.FileSystemType,
+					encrypt, &mkfsMutex, bytesPerInode, logger)
+			})
 		}
-		err := concurrentState.GoRun(func() error {
-			return drive.makeFileSystem(cpuSharer, device,
-				partition.FileSystemLabel, partition.FileSystemType, encrypt,
-				&mkfsMutex, bytesPerInode, logger)
-		})
 		if err != nil {
 			return err
 		}
 	}
-	if err := concurrentState.Reap(); err != nil {
-		return err
-	}
-	// Mount all file-systems, except the /boot and data file-systems, so that
-	// the image can create directories in them. First do the root partition,
-	// which might not be first in the list.
-	err := mount(partitionName(drive.devpath, partitionIndices.root),
-		*mountPoint,
-		layout.BootDriveLayout[partitionIndices.root-1].FileSystemType.String(),
-		logger)
-	if err != nil {
-		return err
-	}
-	for index, partition := range layout.BootDriveLayout {
-		switch index + 1 {
-		case partitionIndices.boot,
-			partitionIndices.extra,
-			partitionIndices.root:
-			continue
-		}
-		device := partitionName(drive.devpath, index+1)
-		err := mount(remapDevice(device, partition.MountPoint, layout.Encrypt),
-			filepath.Join(*mountPoint, partition.MountPoint),
-			partition.FileSystemType.String(), logger)
-		if err != nil {
+	return concurrentState.Reap()
+}
+
+func configureDataArray(cpuSharer cpusharer.CpuSharer,
+	array *raidArrayType, drives []*driveType, mountPoint string,
+	layout installer_proto.StorageLayout, physicalVolume bool,
+	logger log.DebugLogger) error {
+	members := make([]string, 0, len(drives))
+	for _, drive := range drives {
+		startTime := time.Now()
+		if run("blkdiscard", "", logger, drive.devpath) == nil {
+			drive.discarded = true
+			logger.Printf("discarded %s in %s\n",
+				drive.devpath, format.Duration(time.Since(startTime)))
+		} else if err := eraseStart(drive.devpath, logger); err != nil {
 			return err
 		}
+		members = append(members, drive.devpath)
 	}
-	var bootP int
-	if partitionIndices.boot != partitionIndices.root {
-		bootP = partitionIndices.boot
+	if err := array.create(members, logger); err != nil {
+		return err
 	}
-	return installRoot(drive.devpath, layout, img.FileSystem, objGetter,
-		bootInfo, bootP, partitionIndices.root, logger)
+	volume := makeArrayVolume(array, drives)
+	if physicalVolume {
+		return volume.makePhysicalVolume(cpuSharer, volume.devpath,
+			layout.Encrypt, logger)
+	}
+	return volume.makeFileSystem(cpuSharer, volume.devpath, mountPoint,
+		installer_proto.FileSystemTypeExt4, layout.Encrypt, nil, 1048576,
+		logger)
 }
 
 func configureDataDrive(cpuSharer cpusharer.CpuSharer, drive *driveType,
-	index int, layout installer_proto.StorageLayout,
-	logger log.DebugLogger) error {
+	mountPoint string, layout installer_proto.StorageLayout,
+	physicalVolume bool, logger log.DebugLogger) error {
 	startTime := time.Now()
 	if run("blkdiscard", "", logger, drive.devpath) == nil {
 		drive.discarded = true
 		logger.Printf("discarded %s in %s\n",
 			drive.devpath, format.Duration(time.Since(startTime)))
 	}
-	dataMountPoint := layout.ExtraMountPointsBasename + strconv.FormatInt(
-		int64(index), 10)
-	return drive.makeFileSystem(cpuSharer, drive.devpath, dataMountPoint,
+	if physicalVolume {
+		return drive.makePhysicalVolume(cpuSharer, drive.devpath,
+			layout.Encrypt, logger)
+	}
+	return drive.makeFileSystem(cpuSharer, drive.devpath, mountPoint,
 		installer_proto.FileSystemTypeExt4, layout.Encrypt, nil, 1048576,
 		logger)
 }
@@ -260,6 +228,9 @@ func configureStorage(config fm_proto.GetMachineInfoResponse,
 	if err != nil {
 		return nil, err
 	}
+	if err := layout.Check(); err != nil {
+		return nil, err
+	}
 	// Add an EFI partition if needed and not already defined.
 	isEfi := checkIsEfi()
 	if isEfi {
@@ -347,6 +318,36 @@ func configureStorage(config fm_proto.GetMachineInfoResponse,
 	if err != nil {
 		return nil, err
 	}
+	numBootDrives := 1
+	if layout.MirrorBootDrive {
+		if len(drives) < 2 {
+			return nil, errors.New("need at least 2 drives to mirror")
+		}
+		numBootDrives = 2
+	}
+	bootDrive, err := newBootDrive(drives[:numBootDrives], layout)
+	if err != nil {
+		return nil, err
+	}
+	dataDrives := drives[numBootDrives:]
+	var dataArray *raidArrayType
+	if layout.DataDriveRaidLevel != "" {
+		if len(dataDrives) < 2 {
+			return nil, fmt.Errorf("need at least 2 data drives for RAID%s",
+				layout.DataDriveRaidLevel)
+		}
+		dataArray, err = newRaidArray(dataRaidDevice,
+			layout.DataDriveRaidLevel, raidMetadataDefault)
+		if err != nil {
+			return nil, err
+		}
+	}
+	physicalVolumes := make(map[string]struct{})
+	for _, volumeGroup := range layout.VolumeGroups {
+		for _, physicalVolume := range volumeGroup.PhysicalVolumes {
+			physicalVolumes[physicalVolume] = struct{}{}
+		}
+	}
 	rootDevice := partitionName(drives[0].devpath, partitionIndices.root)
 	var randomKey []byte
 	if layout.Encrypt {
@@ -383,7 +384,8 @@ func configureStorage(config fm_proto.GetMachineInfoResponse,
 	layout.BootDriveLayout[partitionIndices.root-1].MinimumFreeBytes +=
 		imageSize
 	bootInfo, err := util.GetBootInfo(img.FileSystem,
-		layout.BootDriveLayout[partitionIndices.root-1].FileSystemLabel, "")
+		layout.BootDriveLayout[partitionIndices.root-1].FileSystemLabel,
+		bootDrive.kernelOptions(partitionIndices.root))
 	if err != nil {
 		return nil, err
 	}
@@ -440,73 +442,93 @@ func configureStorage(config fm_proto.GetMachineInfoResponse,
 	concurrentState := concurrent.NewState(uint(len(drives)))
 	cpuSharer := cpusharer.NewFifoCpuSharer()
 	err = concurrentState.GoRun(func() error {
-		return configureBootDrive(cpuSharer, drives[0], layout,
-			partitionIndices, img, objGetter, bootInfo, logger)
+		return configureBootDrive(cpuSharer, bootDrive, layout,
+			partitionIndices, physicalVolumes, logger)
 	})
 	if err != nil {
 		return nil, concurrentState.Reap()
 	}
-	for index, drive := range drives[1:] {
-		drive := drive
-		index := index + 1
+	// Data drives are either combined into a single RAID array or are used
+	// individually. The extra mount points are numbered from 1.
+	var dataVolumes []dataVolumeType
+	if dataArray != nil {
+		mountPoint := layout.ExtraMountPointsBasename + "1"
+		_, isPV := physicalVolumes[mountPoint]
 		err := concurrentState.GoRun(func() error {
-			return configureDataDrive(cpuSharer, drive, index, layout, logger)
+			return configureDataArray(cpuSharer, dataArray, dataDrives,
+				mountPoint, layout, isPV, logger)
 		})
 		if err != nil {
-			break
+			return nil, concurrentState.Reap()
+		}
+	} else {
+		for index, drive := range dataDrives {
+			drive := drive
+			mountPoint := layout.ExtraMountPointsBasename + strconv.FormatInt(
+				int64(index+1), 10)
+			_, isPV := physicalVolumes[mountPoint]
+			err := concurrentState.GoRun(func() error {
+				return configureDataDrive(cpuSharer, drive, mountPoint, layout,
+					isPV, logger)
+			})
+			if err != nil {
+				break
+			}
+			dataVolumes = append(dataVolumes, dataVolumeType{
+				mountPoint: mountPoint,
+				volume:     drive,
+			})
 		}
 	}
 	if err := concurrentState.Reap(); err != nil {
 		return nil, err
 	}
-	// Make table entries for the boot device file-systems.
-	fsTab := &bytes.Buffer{}
-	cryptTab := &bytes.Buffer{}
-	// Write the root file-system entry first.
-	bootCheckCount := uint(1)
-	{
-		device := partitionName(drives[0].devpath, partitionIndices.root)
-		partition := layout.BootDriveLayout[partitionIndices.root-1]
-		err = drives[0].writeDeviceEntries(device, partition, fsTab, cryptTab,
-			bootCheckCount)
-		if err != nil {
-			return nil, err
-		}
+	if dataArray != nil { // Pick up the discard state of the drives.
+		dataVolumes = append(dataVolumes, dataVolumeType{
+			mountPoint: layout.ExtraMountPointsBasename + "1",
+			volume:     makeArrayVolume(dataArray, dataDrives),
+		})
 	}
+	// Create volume groups and logical volumes on the physical volumes.
+	pvVolumes := make(map[string]*driveType, len(physicalVolumes))
 	for index, partition := range layout.BootDriveLayout {
-		if index+1 == partitionIndices.root {
-			continue
-		}
-		bootCheckCount++
-		device := partitionName(drives[0].devpath, index+1)
-		err = drives[0].writeDeviceEntries(device, partition, fsTab, cryptTab,
-			bootCheckCount)
-		if err != nil {
-			return nil, err
+		if _, ok := physicalVolumes[partition.MountPoint]; ok {
+			pvVolumes[partition.MountPoint] = bootDrive.volume(index + 1)
 		}
 	}
-	// Make table entries for data file-systems on secondary drives.
-	for index, drive := range drives {
-		if index == 0 { // The boot device is partitioned.
-			continue
-		}
-		dataMountPoint := layout.ExtraMountPointsBasename + strconv.FormatInt(
-			int64(index), 10)
-		err = drive.writeDeviceEntries(drive.devpath, installer_proto.Partition{
-			FileSystemLabel: dataMountPoint,
-			MountPoint:      dataMountPoint,
-		},
-			fsTab, cryptTab, 2)
-		if err != nil {
-			return nil, err
+	for _, dataVolume := range dataVolumes {
+		if _, ok := physicalVolumes[dataVolume.mountPoint]; ok {
+			pvVolumes[dataVolume.mountPoint] = dataVolume.volume
 		}
 	}
+	logicalVolumes, err := configureVolumeGroups(cpuSharer, layout, pvVolumes,
+		logger)
+	if err != nil {
+		return nil, err
+	}
+	err = installBootDrive(bootDrive, layout, partitionIndices,
+		physicalVolumes, logicalVolumes, img, objGetter, bootInfo, logger)
+	if err != nil {
+		return nil, err
+	}
+	fsTab, cryptTab, err := makeStorageTables(bootDrive, layout,
+		partitionIndices, physicalVolumes, dataVolumes, logicalVolumes)
+	if err != nil {
+		return nil, err
+	}
 	logger.Printf("Writing /etc/fstab:\n%s", string(fsTab.Bytes()))
 	err = ioutil.WriteFile(filepath.Join(*mountPoint, "etc", "fstab"),
 		fsTab.Bytes(), fsutil.PublicFilePerms)
 	if err != nil {
 		return nil, err
 	}
+	raidArrays := bootDrive.arrays
+	if dataArray != nil {
+		raidArrays = append(raidArrays, dataArray)
+	}
+	if err := writeMdadmConfig(*mountPoint, raidArrays, logger); err != nil {
+		return nil, err
+	}
 	if len(randomKey) > 0 {
 		logger.Printf("Writing /etc/crypttab:\n%s", string(cryptTab.Bytes()))
 		err = ioutil.WriteFile(filepath.Join(*mountPoint, "/etc", "crypttab"),
@@ -683,7 +705,56 @@ func getRandomKey(numBytes uint, logger log.DebugLogger) ([]byte, error) {
 	}
 }
 
-func installRoot(device string, layout installer_proto.StorageLayout,
+func installBootDrive(bootDrive *bootDriveType,
+	layout installer_proto.StorageLayout, partitionIndices partitionIndicesType,
+	physicalVolumes map[string]struct{}, logicalVolumes []logicalVolumeType,
+	img *image.Image, objGetter objectserver.ObjectsGetter,
+	bootInfo *util.BootInfoType, logger log.DebugLogger) error {
+	// Mount all file-systems, except the /boot and data file-systems, so that
+	// the image can create directories in them. First do the root partition,
+	// which might not be first in the list.
+	err := mount(bootDrive.devices[partitionIndices.root-1], *mountPoint,
+		layout.BootDriveLayout[partitionIndices.root-1].FileSystemType.String(),
+		logger)
+	if err != nil {
+		return err
+	}
+	for index, partition := range layout.BootDriveLayout {
+		switch index + 1 {
+		case partitionIndices.boot,
+			partitionIndices.extra,
+			partitionIndices.root:
+			continue
+		}
+		if _, ok := physicalVolumes[partition.MountPoint]; ok {
+			continue
+		}
+		device := bootDrive.devices[index]
+		err := mount(remapDevice(device, partition.MountPoint, layout.Encrypt),
+			filepath.Join(*mountPoint, partition.MountPoint),
+			partition.FileSystemType.String(), logger)
+		if err != nil {
+			return err
+		}
+	}
+	for _, logicalVolume := range logicalVolumes {
+		err := mount(logicalVolume.volume.devpath,
+			filepath.Join(*mountPoint, logicalVolume.partition.MountPoint),
+			logicalVolume.partition.FileSystemType.String(), logger)
+		if err != nil {
+			return err
+		}
+	}
+	var bootP int
+	if partitionIndices.boot != partitionIndices.root {
+		bootP = partitionIndices.boot
+	}
+	return installRoot(bootDrive, layout, img.FileSystem, objGetter,
+		bootInfo, bootP, partitionIndices.root, logger)
+}
+
+func installRoot(bootDrive *bootDriveType,
+	layout installer_proto.StorageLayout,
 	fileSystem *filesystem.FileSystem, objGetter objectserver.ObjectsGetter,
 	bootInfo *util.BootInfoType, bootPartition, rootPartition int,
 	logger log.DebugLogger) error {
@@ -709,7 +780,7 @@ func installRoot(device string, layout installer_proto.StorageLayout,
 		// This ensures that the bootloader has the files it needs and that the
 		// root file-system is fully up-to-date with the image.
 		partition := layout.BootDriveLayout[bootPartition-1]
-		err := mount(partitionName(device, bootPartition), "/tmpboot",
+		err := mount(bootDrive.devices[bootPartition-1], "/tmpboot",
 			partition.FileSystemType.String(), logger)
 		if err != nil {
 			return err
@@ -730,7 +801,7 @@ func installRoot(device string, layout installer_proto.StorageLayout,
 			return fmt.Errorf("error unmounting: %s: %s", "/tmpboot", err)
 		}
 		logger.Debugln(0, "unmounted /tmpboot")
-		err = mount(partitionName(device, bootPartition),
+		err = mount(bootDrive.devices[bootPartition-1],
 			filepath.Join(*mountPoint, partition.MountPoint),
 			partition.FileSystemType.String(), logger)
 		if err != nil {
@@ -759,9 +830,17 @@ func installRoot(device string, layout installer_proto.StorageLayout,
 		waiter.Lock()
 		waiter.Unlock()
 	}()
-	return util.MakeBootable(fileSystem, device,
-		layout.BootDriveLayout[rootPartition-1].FileSystemLabel, *mountPoint,
-		"", true, logger)
+	// Install the boot loader on each boot drive, so that the machine can boot
+	// from either drive of a mirrored pair.
+	for _, drive := range bootDrive.drives {
+		err := util.MakeBootable(fileSystem, drive.devpath,
+			layout.BootDriveLayout[rootPartition-1].FileSystemLabel,
+			*mountPoint, bootDrive.kernelOptions(rootPartition), true, logger)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func installTmpRoot(fileSystem *filesystem.FileSystem,
@@ -857,6 +936,22 @@ func listDrives(logger log.DebugLogger) ([]*driveType, error) {
 	return drives, nil
 }
 
+// makeArrayVolume returns a driveType for a RAID array made from the drives.
+func makeArrayVolume(array *raidArrayType, drives []*driveType) *driveType {
+	volume := &driveType{
+		devpath:   array.device,
+		discarded: true,
+		name:      filepath.Base(array.device),
+		raidArray: array,
+	}
+	for _, drive := range drives {
+		if !drive.discarded {
+			volume.discarded = false
+		}
+	}
+	return volume
+}
+
 func makeBindMount(targetRoot, bindMount string) error {
 	target := filepath.Join(targetRoot, bindMount)
 	if err := os.MkdirAll(target, fsutil.DirPerms); err != nil {
@@ -892,6 +987,78 @@ func mount(source string, target string, fstype string,
 	return syscall.Mount(source, target, fstype, 0, "")
 }
 
+// makeStorageTables makes the fstab and crypttab entries for the boot drive
+// partitions, the data volumes and the logical volumes. The root file-system
+// entry is first.
+func makeStorageTables(bootDrive *bootDriveType,
+	layout installer_proto.StorageLayout,
+	partitionIndices partitionIndicesType,
+	physicalVolumes map[string]struct{}, dataVolumes []dataVolumeType,
+	logicalVolumes []logicalVolumeType) (*bytes.Buffer, *bytes.Buffer, error) {
+	fsTab := &bytes.Buffer{}
+	cryptTab := &bytes.Buffer{}
+	// Make table entries for the boot device file-systems.
+	// Write the root file-system entry first.
+	bootCheckCount := uint(1)
+	{
+		volume := bootDrive.volume(partitionIndices.root)
+		partition := layout.BootDriveLayout[partitionIndices.root-1]
+		err := volume.writeDeviceEntries(volume.devpath, partition, fsTab,
+			cryptTab, bootCheckCount)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for index, partition := range layout.BootDriveLayout {
+		if index+1 == partitionIndices.root {
+			continue
+		}
+		volume := bootDrive.volume(index + 1)
+		if _, ok := physicalVolumes[partition.MountPoint]; ok {
+			err := volume.writeCryptEntry(volume.devpath, cryptTab)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		bootCheckCount++
+		err := volume.writeDeviceEntries(volume.devpath, partition, fsTab,
+			cryptTab, bootCheckCount)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	// Make table entries for data file-systems on secondary drives.
+	for _, dataVolume := range dataVolumes {
+		volume := dataVolume.volume
+		if _, ok := physicalVolumes[dataVolume.mountPoint]; ok {
+			err := volume.writeCryptEntry(volume.devpath, cryptTab)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		err := volume.writeDeviceEntries(volume.devpath,
+			installer_proto.Partition{
+				FileSystemLabel: dataVolume.mountPoint,
+				MountPoint:      dataVolume.mountPoint,
+			},
+			fsTab, cryptTab, 2)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	// Make table entries for file-systems on logical volumes.
+	for _, logicalVolume := range logicalVolumes {
+		err := logicalVolume.volume.writeFstabEntry(logicalVolume.partition,
+			fsTab, 2)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return fsTab, cryptTab, nil
+}
+
 func partitionName(devpath string, partitionNumber int) string {
 	devLeafName := filepath.Base(devpath)
 	partitionName := "p" + strconv.FormatInt(int64(partitionNumber), 10)
@@ -1061,34 +1228,14 @@ func (drive driveType) cryptSetup(cpuSharer cpusharer.CpuSharer, device string,
 func (drive driveType) makeFileSystem(cpuSharer cpusharer.CpuSharer,
 	device, label string, fstype installer_proto.FileSystemType, encrypt bool,
 	mkfsMutex *sync.Mutex, bytesPerInode uint, logger log.DebugLogger) error {
-	startTime := time.Now()
-	numIterations, numOpened, err := fsutil.WaitForBlockAvailable(device,
-		5*time.Second)
+	device, err := drive.prepareDevice(cpuSharer, device, encrypt, logger)
 	if err != nil {
 		return err
 	}
-	if numIterations > 0 {
-		logger.Debugf(0, "%s available after %d iterations, %d opens, %s\n",
-			device, numIterations, numOpened,
-			format.Duration(time.Since(startTime)))
-	}
-	erase := !drive.discarded
-	if encrypt {
-		if err := drive.cryptSetup(cpuSharer, device, logger); err != nil {
-			return err
-		}
-		device = filepath.Join("/dev/mapper", filepath.Base(device))
-		erase = true
-	}
-	if erase {
-		if err := eraseStart(device, logger); err != nil {
-			return err
-		}
-	}
 	if mkfsMutex != nil {
 		mkfsMutex.Lock()
 	}
-	startTime = time.Now()
+	startTime := time.Now()
 	switch fstype {
 	case installer_proto.FileSystemTypeExt4:
 		if bytesPerInode > 0 {
@@ -1117,6 +1264,123 @@ func (drive driveType) makeFileSystem(cpuSharer cpusharer.CpuSharer,
 	return nil
 }
 
+func (drive *driveType) makePartitions(layout installer_proto.StorageLayout,
+	partitionIndices partitionIndicesType, logger log.DebugLogger) error {
+	startTime := time.Now()
+	if run("blkdiscard", "", logger, drive.devpath) == nil {
+		drive.discarded = true
+		logger.Printf("discarded %s in %s\n",
+			drive.devpath, format.Duration(time.Since(startTime)))
+	} else { // Erase old partition.
+		if err := eraseStart(drive.devpath, logger); err != nil {
+			return err
+		}
+	}
+	isEfi := checkIsEfi()
+	args := []string{"-s", "-a", "optimal", drive.devpath}
+	if isEfi {
+		args = append(args, "mklabel", "gpt")
+	} else {
+		args = append(args, "mklabel", "msdos")
+	}
+	unitSize := uint64(1 << 20)
+	unitSuffix := "MiB"
+	offsetInUnits := uint64(1)
+	for _, partition := range layout.BootDriveLayout {
+		minimumSize := partition.MinimumFreeBytes
+		if partition.MinimumBytes > minimumSize {
+			minimumSize = partition.MinimumBytes
+		}
+		sizeInUnits := minimumSize / unitSize
+		if sizeInUnits*unitSize < minimumSize {
+			sizeInUnits++
+		}
+		var partType string
+		switch partition.FileSystemType {
+		case installer_proto.FileSystemTypeVfat:
+			partType = "fat32"
+		default:
+			partType = partition.FileSystemType.String()
+		}
+		args = append(args, "mkpart", "primary", partType)
+		if minimumSize > 0 {
+			args = append(args,
+				strconv.FormatUint(offsetInUnits, 10)+unitSuffix,
+				strconv.FormatUint(offsetInUnits+sizeInUnits, 10)+unitSuffix)
+			offsetInUnits += sizeInUnits
+		} else {
+			args = append(args,
+				strconv.FormatUint(offsetInUnits, 10)+unitSuffix, "100%")
+		}
+	}
+	if isEfi { // EFI System Partition is always the first partition.
+		args = append(args, "set", "1", "esp", "on")
+	} else {
+		args = append(args,
+			"set", strconv.FormatInt(int64(partitionIndices.boot), 10), "boot",
+			"on")
+	}
+	return run("parted", *tmpRoot, logger, args...)
+}
+
+func (drive driveType) makePhysicalVolume(cpuSharer cpusharer.CpuSharer,
+	device string, encrypt bool, logger log.DebugLogger) error {
+	device, err := drive.prepareDevice(cpuSharer, device, encrypt, logger)
+	if err != nil {
+		return err
+	}
+	return run("pvcreate", *tmpRoot, logger, "--yes", "-ff", device)
+}
+
+// prepareDevice waits for the device to be available, sets up encryption if
+// required and erases the start of the device if it may contain old data. The
+// device to use is returned.
+func (drive driveType) prepareDevice(cpuSharer cpusharer.CpuSharer,
+	device string, encrypt bool, logger log.DebugLogger) (string, error) {
+	startTime := time.Now()
+	numIterations, numOpened, err := fsutil.WaitForBlockAvailable(device,
+		5*time.Second)
+	if err != nil {
+		return "", err
+	}
+	if numIterations > 0 {
+		logger.Debugf(0, "%s available after %d iterations, %d opens, %s\n",
+			device, numIterations, numOpened,
+			format.Duration(time.Since(startTime)))
+	}
+	erase := !drive.discarded
+	if encrypt {
+		if err := drive.cryptSetup(cpuSharer, device, logger); err != nil {
+			return "", err
+		}
+		device = filepath.Join("/dev/mapper", filepath.Base(device))
+		erase = true
+	}
+	if erase {
+		if err := eraseStart(device, logger); err != nil {
+			return "", err
+		}
+	}
+	return device, nil
+}
+
+// writeCryptEntry writes the crypttab entry for the device. RAID arrays are
+// referred to by UUID, since array device names may change between boots.
+func (drive driveType) writeCryptEntry(device string,
+	cryptTab io.Writer) error {
+	var options string
+	if drive.discarded {
+		options = "discard"
+	}
+	source := device
+	if drive.raidArray != nil {
+		source = drive.raidArray.byIdPath()
+	}
+	_, err := fmt.Fprintf(cryptTab, "%-15s %-23s %-15s %s\n",
+		filepath.Base(device), source, keyFile, options)
+	return err
+}
+
 func (drive driveType) writeDeviceEntries(device string,
 	partition installer_proto.Partition,
 	fsTab, cryptTab io.Writer, checkOrder uint) error {
@@ -1125,16 +1389,15 @@ func (drive driveType) writeDeviceEntries(device string,
 	case efiMountPoint:
 	case rootMountPoint:
 	default:
-		var options string
-		if drive.discarded {
-			options = "discard"
-		}
-		_, err := fmt.Fprintf(cryptTab, "%-15s %-23s %-15s %s\n",
-			filepath.Base(device), device, keyFile, options)
-		if err != nil {
+		if err := drive.writeCryptEntry(device, cryptTab); err != nil {
 			return err
 		}
 	}
+	return drive.writeFstabEntry(partition, fsTab, checkOrder)
+}
+
+func (drive driveType) writeFstabEntry(partition installer_proto.Partition,
+	fsTab io.Writer, checkOrder uint) error {
 	var fsFlags string
 	if drive.discarded {
 		fsFlags = "discard"
@@ -0,0 +1,165 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"testing"
+
+	installer_proto "github.com/Cloud-Foundations/Dominator/proto/installer"
+)
+
+func TestMakeStorageTables(t *testing.T) {
+	tests := []struct {
+		name            string
+		bootDrive       *bootDriveType
+		physicalVolumes map[string]struct{}
+		dataVolumes     []dataVolumeType
+		logicalVolumes  []logicalVolumeType
+		fsTab           string
+		cryptTab        string
+	}{
+		{
+			name: "single drive",
+			bootDrive: &bootDriveType{
+				devices: []string{
+					"/dev/nvme0n1p1",
+					"/dev/nvme0n1p2",
+					"/dev/nvme0n1p3",
+					"/dev/nvme0n1p4",
+				},
+				drives: []*driveType{
+					{devpath: "/dev/nvme0n1", discarded: true},
+				},
+			},
+			dataVolumes: []dataVolumeType{
+				{
+					mountPoint: "/data/1",
+					volume: &driveType{
+						devpath: "/dev/mapper/sdb",
+						name:    "sdb",
+					},
+				},
+			},
+			fsTab: "LABEL=rootfs           /          ext4  discard    0 1\n" +
+				"LABEL=boot             /boot      ext4  discard    0 2\n" +
+				"LABEL=/home            /home      ext4  discard    0 3\n" +
+				"LABEL=/data/0          /data/0    ext4  discard    0 4\n" +
+				"LABEL=/data/1          /data/1    ext4  defaults   0 2\n",
+			cryptTab: "nvme0n1p3       /dev/nvme0n1p3          " +
+				"/etc/crypt.key  discard\n" +
+				"nvme0n1p4       /dev/nvme0n1p4          " +
+				"/etc/crypt.key  discard\n" +
+				"sdb             /dev/mapper/sdb         /etc/crypt.key  \n",
+		},
+		// Physical volumes are not mounted but must be unlocked, and array
+		// members are referred to by UUID. A drive which was not discarded
+		// disables discard for the mirror.
+		{
+			name: "mirrored with LVM",
+			bootDrive: &bootDriveType{
+				arrays: []*raidArrayType{
+					{
+						device: "/dev/md1",
+						uuid:   "00000001:00000000:00000000:00000000",
+					},
+					{
+						device: "/dev/md2",
+						uuid:   "00000002:00000000:00000000:00000000",
+					},
+					{
+						device: "/dev/md3",
+						uuid:   "00000003:00000000:00000000:00000000",
+					},
+					{
+						device: "/dev/md4",
+						uuid:   "00000004:00000000:00000000:00000000",
+					},
+				},
+				devices: []string{
+					"/dev/md1",
+					"/dev/md2",
+					"/dev/md3",
+					"/dev/md4",
+				},
+				drives: []*driveType{
+					{devpath: "/dev/sda", discarded: true},
+					{devpath: "/dev/sdb"},
+				},
+			},
+			physicalVolumes: map[string]struct{}{
+				"/data/0": {},
+				"/data/1": {},
+			},
+			dataVolumes: []dataVolumeType{
+				{
+					mountPoint: "/data/1",
+					volume: &driveType{
+						devpath:   "/dev/mapper/md5",
+						discarded: true,
+						name:      "md5",
+						raidArray: &raidArrayType{
+							device: "/dev/md5",
+							uuid:   "00000005:00000000:00000000:00000000",
+						},
+					},
+				},
+			},
+			logicalVolumes: []logicalVolumeType{
+				{
+					partition: installer_proto.Partition{
+						FileSystemLabel: "logs",
+						MountPoint:      "/var/log",
+					},
+					volume: &driveType{devpath: "/dev/mapper/vg0-logs"},
+				},
+				{
+					partition: installer_proto.Partition{
+						FileSystemLabel: "scratch",
+						FileSystemType:  installer_proto.FileSystemTypeVfat,
+						MountPoint:      "/scratch",
+					},
+					volume: &driveType{devpath: "/dev/mapper/vg0-scratch"},
+				},
+			},
+			fsTab: "LABEL=rootfs           /          ext4  defaults   0 1\n" +
+				"LABEL=boot             /boot      ext4  defaults   0 2\n" +
+				"LABEL=/home            /home      ext4  defaults   0 3\n" +
+				"LABEL=logs             /var/log   ext4  defaults   0 2\n" +
+				"LABEL=scratch          /scratch   vfat  noauto     0 2\n",
+			cryptTab: "md3             " +
+				"/dev/disk/by-id/md-uuid-00000003:00000000:00000000:00000000 " +
+				"/etc/crypt.key  \n" +
+				"md4             " +
+				"/dev/disk/by-id/md-uuid-00000004:00000000:00000000:00000000 " +
+				"/etc/crypt.key  \n" +
+				"md5             " +
+				"/dev/disk/by-id/md-uuid-00000005:00000000:00000000:00000000 " +
+				"/etc/crypt.key  discard\n",
+		},
+	}
+	for _, test := range tests {
+		fsTab, cryptTab, err := makeStorageTables(test.bootDrive,
+			installer_proto.StorageLayout{
+				BootDriveLayout: []installer_proto.Partition{
+					{FileSystemLabel: "boot", MountPoint: "/boot"},
+					{FileSystemLabel: "rootfs", MountPoint: "/"},
+					{FileSystemLabel: "/home", MountPoint: "/home"},
+					{FileSystemLabel: "/data/0", MountPoint: "/data/0"},
+				},
+			},
+			partitionIndicesType{boot: 1, root: 2, extra: 4},
+			test.physicalVolumes, test.dataVolumes, test.logicalVolumes)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if got := fsTab.String(); got != test.fsTab {
+			t.Errorf("%s: fstab: expected:\n%s\ngot:\n%s",
+				test.name, test.fsTab, got)
+		}
+		if got := cryptTab.String(); got != test.cryptTab {
+			t.Errorf("%s: crypttab: expected:\n%s\ngot:\n%s",
+				test.name, test.cryptTab, got)
+		}
+	}
+}
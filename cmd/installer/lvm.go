@@ -0,0 +1,169 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Cloud-Foundations/Dominator/lib/concurrent"
+	"github.com/Cloud-Foundations/Dominator/lib/cpusharer"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	installer_proto "github.com/Cloud-Foundations/Dominator/proto/installer"
+)
+
+type logicalVolumeType struct {
+	partition installer_proto.Partition
+	volume    *driveType
+}
+
+// configureVolumeGroups creates the volume groups from the physical volumes
+// (Key: extra mount point), creates the logical volumes and makes their
+// file-systems. The logical volumes which have a mount point are returned,
+// sorted by mount point.
+func configureVolumeGroups(cpuSharer cpusharer.CpuSharer,
+	layout installer_proto.StorageLayout,
+	physicalVolumes map[string]*driveType,
+	logger log.DebugLogger) ([]logicalVolumeType, error) {
+	var logicalVolumes []logicalVolumeType
+	for _, volumeGroup := range layout.VolumeGroups {
+		discarded := true
+		pvDevices := make([]string, 0, len(volumeGroup.PhysicalVolumes))
+		for _, mountPoint := range volumeGroup.PhysicalVolumes {
+			volume := physicalVolumes[mountPoint]
+			if volume == nil {
+				return nil, fmt.Errorf(
+					"volume group: %s: PhysicalVolume: %s does not exist",
+					volumeGroup.Name, mountPoint)
+			}
+			if !volume.discarded {
+				discarded = false
+			}
+			pvDevices = append(pvDevices, remapDevice(volume.devpath,
+				mountPoint, layout.Encrypt))
+		}
+		err := run("vgcreate", *tmpRoot, logger,
+			append([]string{"--yes", volumeGroup.Name}, pvDevices...)...)
+		if err != nil {
+			return nil, err
+		}
+		lvs := orderLogicalVolumes(volumeGroup.LogicalVolumes)
+		concurrentState := concurrent.NewState(uint(len(lvs)))
+		var mkfsMutex sync.Mutex
+		for _, lv := range lvs {
+			err := run("lvcreate", *tmpRoot, logger, "--yes",
+				"--wipesignatures", "y", "-l", logicalVolumeExtents(lv),
+				"-n", lv.Name, volumeGroup.Name)
+			if err != nil {
+				concurrentState.Reap()
+				return nil, err
+			}
+			if lv.MountPoint == "" {
+				continue
+			}
+			device := logicalVolumeDevice(volumeGroup.Name, lv.Name)
+			logicalVolume := logicalVolumeType{
+				partition: installer_proto.Partition{
+					BytesPerInode:   lv.BytesPerInode,
+					FileSystemLabel: lv.FileSystemLabel,
+					FileSystemType:  lv.FileSystemType,
+					MountPoint:      lv.MountPoint,
+				},
+				volume: &driveType{
+					devpath:   device,
+					discarded: discarded,
+					name:      filepath.Base(device),
+				},
+			}
+			if logicalVolume.partition.FileSystemLabel == "" {
+				logicalVolume.partition.FileSystemLabel = lv.MountPoint
+			}
+			logicalVolumes = append(logicalVolumes, logicalVolume)
+			err = concurrentState.GoRun(func() error {
+				return logicalVolume.volume.makeFileSystem(cpuSharer, device,
+					logicalVolume.partition.FileSystemLabel,
+					logicalVolume.partition.FileSystemType, false, &mkfsMutex,
+					uint(logicalVolume.partition.BytesPerInode), logger)
+			})
+			if err != nil {
+				concurrentState.Reap()
+				return nil, err
+			}
+		}
+		if err := concurrentState.Reap(); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(logicalVolumes, func(left, right int) bool {
+		return logicalVolumes[left].partition.MountPoint <
+			logicalVolumes[right].partition.MountPoint
+	})
+	return logicalVolumes, nil
+}
+
+// deactivateVolumeGroups deactivates all volume groups if there are any
+// logical volumes in the device-mapper names, and returns the remaining
+// names. Logical volume names always contain a '-' separating the volume
+// group and logical volume names, whereas encrypted volumes are named after
+// the underlying device.
+func deactivateVolumeGroups(names []string,
+	logger log.DebugLogger) ([]string, error) {
+	var haveLogicalVolumes bool
+	remainingNames := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.Contains(name, "-") {
+			haveLogicalVolumes = true
+		} else {
+			remainingNames = append(remainingNames, name)
+		}
+	}
+	if !haveLogicalVolumes {
+		return names, nil
+	}
+	if err := run("vgchange", *tmpRoot, logger, "-a", "n"); err != nil {
+		return nil, err
+	}
+	return remainingNames, nil
+}
+
+// logicalVolumeDevice returns the device-mapper device for a logical volume.
+// This does not depend on udev creating the /dev/$vg/$lv symlinks.
+func logicalVolumeDevice(volumeGroupName, logicalVolumeName string) string {
+	return filepath.Join("/dev/mapper",
+		strings.ReplaceAll(volumeGroupName, "-", "--")+"-"+
+			strings.ReplaceAll(logicalVolumeName, "-", "--"))
+}
+
+// logicalVolumeExtents returns the lvcreate extents argument for the size of
+// the logical volume: a percentage of the volume group or the remaining space.
+func logicalVolumeExtents(lv installer_proto.LogicalVolume) string {
+	if lv.SizePercentage < 1 {
+		return "100%FREE"
+	}
+	return strconv.FormatUint(uint64(lv.SizePercentage), 10) + "%VG"
+}
+
+// orderLogicalVolumes returns the logical volumes in creation order: those with
+// a specified size first, then the logical volume which uses the remaining
+// space.
+func orderLogicalVolumes(
+	lvs []installer_proto.LogicalVolume) []installer_proto.LogicalVolume {
+	ordered := make([]installer_proto.LogicalVolume, 0, len(lvs))
+	var remaining *installer_proto.LogicalVolume
+	for index, lv := range lvs {
+		if lv.SizePercentage < 1 {
+			remaining = &lvs[index]
+		} else {
+			ordered = append(ordered, lv)
+		}
+	}
+	if remaining != nil {
+		ordered = append(ordered, *remaining)
+	}
+	return ordered
+}
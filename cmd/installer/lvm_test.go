@@ -0,0 +1,80 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/types"
+	installer_proto "github.com/Cloud-Foundations/Dominator/proto/installer"
+)
+
+func TestLogicalVolumeDevice(t *testing.T) {
+	tests := []struct {
+		volumeGroup   string
+		logicalVolume string
+		device        string
+	}{
+		{"vg0", "lv0", "/dev/mapper/vg0-lv0"},
+		{"data-vg", "my-lv", "/dev/mapper/data--vg-my--lv"},
+		{"a--b", "c", "/dev/mapper/a----b-c"},
+	}
+	for _, test := range tests {
+		device := logicalVolumeDevice(test.volumeGroup, test.logicalVolume)
+		if device != test.device {
+			t.Errorf("%s/%s: expected: %s, got: %s",
+				test.volumeGroup, test.logicalVolume, test.device, device)
+		}
+	}
+}
+
+func TestLogicalVolumeExtents(t *testing.T) {
+	tests := []struct {
+		sizePercentage types.Percentage
+		extents        string
+	}{
+		{0, "100%FREE"},
+		{1, "1%VG"},
+		{25, "25%VG"},
+		{100, "100%VG"},
+	}
+	for _, test := range tests {
+		extents := logicalVolumeExtents(installer_proto.LogicalVolume{
+			Name:           "lv",
+			SizePercentage: test.sizePercentage,
+		})
+		if extents != test.extents {
+			t.Errorf("%d%%: expected: %s, got: %s",
+				test.sizePercentage, test.extents, extents)
+		}
+	}
+}
+
+func TestOrderLogicalVolumes(t *testing.T) {
+	lvs := []installer_proto.LogicalVolume{
+		{Name: "rest"},
+		{Name: "logs", SizePercentage: 20},
+		{Name: "swap", SizePercentage: 10},
+	}
+	ordered := orderLogicalVolumes(lvs)
+	expected := []string{"logs", "swap", "rest"}
+	if len(ordered) != len(expected) {
+		t.Fatalf("expected %d logical volumes, got: %d",
+			len(expected), len(ordered))
+	}
+	for index, name := range expected {
+		if ordered[index].Name != name {
+			t.Errorf("index: %d: expected: %s, got: %s",
+				index, name, ordered[index].Name)
+		}
+	}
+	if lvs[0].Name != "rest" {
+		t.Error("input logical volumes reordered")
+	}
+	ordered = orderLogicalVolumes(lvs[1:])
+	if len(ordered) != 2 || ordered[0].Name != "logs" ||
+		ordered[1].Name != "swap" {
+		t.Errorf("sized logical volumes reordered: %v", ordered)
+	}
+}
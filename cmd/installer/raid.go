@@ -0,0 +1,183 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	installer_proto "github.com/Cloud-Foundations/Dominator/proto/installer"
+)
+
+const (
+	dataRaidDevice = "/dev/md0"
+
+	// Metadata at the end of the device, so that firmware and boot loaders
+	// see a plain file-system on each member.
+	raidMetadataAtEnd   = "1.0"
+	raidMetadataDefault = "1.2"
+)
+
+type raidArrayType struct {
+	device   string
+	level    string
+	metadata string
+	uuid     string // mdadm format.
+}
+
+// bootDriveType is the boot drive, or a pair of boot drives which are
+// mirrored partition by partition.
+type bootDriveType struct {
+	arrays  []*raidArrayType // Index: partition number - 1. nil: no mirror.
+	devices []string         // Index: partition number - 1.
+	drives  []*driveType
+}
+
+// newRaidArray returns a RAID array description with a new random UUID. The
+// UUID is known before the array is created, so it may be used in kernel
+// options and configuration files.
+func newRaidArray(device, level, metadata string) (*raidArrayType, error) {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		return nil, err
+	}
+	return &raidArrayType{
+		device:   device,
+		level:    level,
+		metadata: metadata,
+		uuid: fmt.Sprintf("%x:%x:%x:%x",
+			uuid[0:4], uuid[4:8], uuid[8:12], uuid[12:16]),
+	}, nil
+}
+
+func newBootDrive(drives []*driveType,
+	layout installer_proto.StorageLayout) (*bootDriveType, error) {
+	bootDrive := &bootDriveType{
+		devices: make([]string, len(layout.BootDriveLayout)),
+		drives:  drives,
+	}
+	if len(drives) < 2 {
+		return bootDrive, nil
+	}
+	bootDrive.arrays = make([]*raidArrayType, len(layout.BootDriveLayout))
+	for index, partition := range layout.BootDriveLayout {
+		metadata := raidMetadataDefault
+		switch partition.MountPoint {
+		case bootMountPoint, efiMountPoint:
+			metadata = raidMetadataAtEnd
+		}
+		array, err := newRaidArray("/dev/md"+strconv.Itoa(index+1), "1",
+			metadata)
+		if err != nil {
+			return nil, err
+		}
+		bootDrive.arrays[index] = array
+	}
+	return bootDrive, nil
+}
+
+// writeMdadmConfig writes the mdadm configuration file for the arrays into
+// the root file-system mounted at rootDir.
+func writeMdadmConfig(rootDir string, arrays []*raidArrayType,
+	logger log.DebugLogger) error {
+	if len(arrays) < 1 {
+		return nil
+	}
+	filename := filepath.Join(rootDir, "etc", "mdadm", "mdadm.conf")
+	if _, err := os.Stat(filepath.Dir(filename)); err != nil {
+		filename = filepath.Join(rootDir, "etc", "mdadm.conf")
+	}
+	builder := &strings.Builder{}
+	for _, array := range arrays {
+		if err := array.writeConfig(builder); err != nil {
+			return err
+		}
+	}
+	logger.Printf("Writing %s:\n%s", filename[len(rootDir):], builder)
+	return ioutil.WriteFile(filename, []byte(builder.String()),
+		fsutil.PublicFilePerms)
+}
+
+func (array *raidArrayType) byIdPath() string {
+	return "/dev/disk/by-id/md-uuid-" + array.uuid
+}
+
+func (array *raidArrayType) create(members []string,
+	logger log.DebugLogger) error {
+	args := []string{
+		"--create", array.device,
+		"--run",
+		"--level=" + array.level,
+		"--metadata=" + array.metadata,
+		"--uuid=" + array.uuid,
+		"--raid-devices=" + strconv.Itoa(len(members)),
+	}
+	args = append(args, members...)
+	return run("mdadm", *tmpRoot, logger, args...)
+}
+
+func (array *raidArrayType) writeConfig(writer io.Writer) error {
+	_, err := fmt.Fprintf(writer, "ARRAY %s metadata=%s UUID=%s\n",
+		array.device, array.metadata, array.uuid)
+	return err
+}
+
+// kernelOptions returns the kernel options needed to assemble the array
+// containing the root file-system in the initial ramdisk.
+func (bootDrive *bootDriveType) kernelOptions(rootPartition int) string {
+	if bootDrive.arrays == nil {
+		return ""
+	}
+	return "rd.md.uuid=" + bootDrive.arrays[rootPartition-1].uuid
+}
+
+// makeDevices sets up the devices for the partitions on the boot drive(s),
+// creating mirrors if there are multiple boot drives.
+func (bootDrive *bootDriveType) makeDevices(logger log.DebugLogger) error {
+	for index := range bootDrive.devices {
+		if bootDrive.arrays == nil {
+			bootDrive.devices[index] = partitionName(
+				bootDrive.drives[0].devpath, index+1)
+			continue
+		}
+		members := make([]string, 0, len(bootDrive.drives))
+		for _, drive := range bootDrive.drives {
+			members = append(members, partitionName(drive.devpath, index+1))
+		}
+		array := bootDrive.arrays[index]
+		if err := array.create(members, logger); err != nil {
+			return err
+		}
+		bootDrive.devices[index] = array.device
+	}
+	return nil
+}
+
+// volume returns a driveType for the device for the specified partition, to
+// be used for making file-systems and table entries.
+func (bootDrive *bootDriveType) volume(partitionNumber int) *driveType {
+	device := bootDrive.devices[partitionNumber-1]
+	volume := &driveType{
+		devpath:   device,
+		discarded: true,
+		name:      filepath.Base(device),
+	}
+	for _, drive := range bootDrive.drives {
+		if !drive.discarded {
+			volume.discarded = false
+		}
+	}
+	if bootDrive.arrays != nil {
+		volume.raidArray = bootDrive.arrays[partitionNumber-1]
+	}
+	return volume
+}
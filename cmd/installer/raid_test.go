@@ -0,0 +1,133 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	installer_proto "github.com/Cloud-Foundations/Dominator/proto/installer"
+)
+
+var raidUuidRegexp = regexp.MustCompile(
+	"^[0-9a-f]{8}:[0-9a-f]{8}:[0-9a-f]{8}:[0-9a-f]{8}$")
+
+func TestNewBootDrive(t *testing.T) {
+	layout := installer_proto.StorageLayout{
+		BootDriveLayout: []installer_proto.Partition{
+			{MountPoint: "/mnt/efi"},
+			{MountPoint: "/boot"},
+			{MountPoint: "/"},
+		},
+	}
+	bootDrive, err := newBootDrive([]*driveType{{devpath: "/dev/sda"}},
+		layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bootDrive.arrays != nil {
+		t.Error("arrays created for single boot drive")
+	}
+	if options := bootDrive.kernelOptions(3); options != "" {
+		t.Errorf("unexpected kernel options: %s", options)
+	}
+	bootDrive, err = newBootDrive(
+		[]*driveType{{devpath: "/dev/sda"}, {devpath: "/dev/sdb"}}, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedMetadata := []string{"1.0", "1.0", "1.2"}
+	for index, array := range bootDrive.arrays {
+		expected := "/dev/md" + strconv.Itoa(index+1)
+		if array.device != expected {
+			t.Errorf("partition %d: expected device: %s, got: %s",
+				index+1, expected, array.device)
+		}
+		if array.level != "1" {
+			t.Errorf("partition %d: expected RAID level 1, got: %s",
+				index+1, array.level)
+		}
+		if array.metadata != expectedMetadata[index] {
+			t.Errorf("partition %d: expected metadata: %s, got: %s",
+				index+1, expectedMetadata[index], array.metadata)
+		}
+		if !raidUuidRegexp.MatchString(array.uuid) {
+			t.Errorf("partition %d: bad UUID: %s", index+1, array.uuid)
+		}
+	}
+	if bootDrive.arrays[0].uuid == bootDrive.arrays[1].uuid {
+		t.Error("duplicate array UUIDs")
+	}
+	expectedOptions := "rd.md.uuid=" + bootDrive.arrays[2].uuid
+	if options := bootDrive.kernelOptions(3); options != expectedOptions {
+		t.Errorf("expected kernel options: %s, got: %s",
+			expectedOptions, options)
+	}
+}
+
+func TestWriteMdadmConfig(t *testing.T) {
+	arrays := []*raidArrayType{
+		{
+			device:   "/dev/md1",
+			metadata: "1.0",
+			uuid:     "00000001:00000000:00000000:00000000",
+		},
+		{
+			device:   "/dev/md2",
+			metadata: "1.2",
+			uuid:     "00000002:00000000:00000000:00000000",
+		},
+	}
+	expected := "ARRAY /dev/md1 metadata=1.0 " +
+		"UUID=00000001:00000000:00000000:00000000\n" +
+		"ARRAY /dev/md2 metadata=1.2 " +
+		"UUID=00000002:00000000:00000000:00000000\n"
+	logger := testlogger.New(t)
+	// Without an /etc/mdadm directory.
+	rootDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(rootDir, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeMdadmConfig(rootDir, arrays, logger); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(
+		filepath.Join(rootDir, "etc", "mdadm.conf")); err != nil {
+		t.Fatal(err)
+	} else if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, string(data))
+	}
+	// With an /etc/mdadm directory.
+	rootDir = t.TempDir()
+	err := os.MkdirAll(filepath.Join(rootDir, "etc", "mdadm"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeMdadmConfig(rootDir, arrays, logger); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(
+		filepath.Join(rootDir, "etc", "mdadm", "mdadm.conf")); err != nil {
+		t.Fatal(err)
+	} else if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, string(data))
+	}
+	if _, err := os.Stat(filepath.Join(rootDir, "etc", "mdadm.conf")); err ==
+		nil {
+		t.Error("mdadm.conf written outside /etc/mdadm")
+	}
+	// No arrays: nothing is written.
+	rootDir = t.TempDir()
+	if err := writeMdadmConfig(rootDir, nil, logger); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(rootDir, "etc")); err == nil {
+		t.Error("configuration written for no arrays")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/logger"
+)
+
+func auditSubcommand(args []string, logger log.DebugLogger) error {
+	clients, _, err := dial(false)
+	if err != nil {
+		return err
+	}
+	if err := showAuditRecords(clients[0]); err != nil {
+		return fmt.Errorf("error getting audit records: %s", err)
+	}
+	return nil
+}
+
+func showAuditRecords(client *srpc.Client) error {
+	request := proto.GetAuditRecordsRequest{
+		FailedOnly: *auditFailedOnly,
+		MaxRecords: *auditMaxRecords,
+		Method:     *auditMethod,
+		Username:   *auditUsername,
+	}
+	if *auditSince > 0 {
+		request.Since = time.Now().Add(-*auditSince)
+	}
+	var reply proto.GetAuditRecordsResponse
+	err := client.RequestReply("Logger.GetAuditRecords", request, &reply)
+	if err != nil {
+		return err
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	for _, record := range reply.Records {
+		username := record.Username
+		if username == "" {
+			username = "-"
+		}
+		result := "OK"
+		if record.Error != "" {
+			result = "error: " + record.Error
+		}
+		fmt.Printf("%s %s %s %s (%s) %s %s\n",
+			record.StartTime.Local().Format(format.TimeFormatSeconds),
+			username, record.RemoteAddr, record.Method,
+			format.Duration(record.Duration), result, record.Request)
+	}
+	return nil
+}
@@ -14,6 +14,16 @@ import (
 )
 
 var (
+	auditFailedOnly = flag.Bool("auditFailedOnly", false,
+		"If true, only show audit records for failed calls")
+	auditMaxRecords = flag.Uint("auditMaxRecords", 100,
+		"Maximum number of audit records to show (most recent)")
+	auditMethod = flag.String("auditMethod", "",
+		"Only show audit records for methods matching this pattern")
+	auditSince = flag.Duration("auditSince", 0,
+		"Only show audit records newer than this (0: no limit)")
+	auditUsername = flag.String("auditUsername", "",
+		"Only show audit records for this user")
 	excludeRegex = flag.String("excludeRegex", "",
 		"The exclude regular expression to filter out when watching (after include)")
 	includeRegex = flag.String("includeRegex", "",
@@ -27,7 +37,7 @@ var (
 func printUsage() {
 	w := flag.CommandLine.Output()
 	fmt.Fprintln(w,
-		"Usage: logtool [flags...] debug|print|set-debug-level [args...]")
+		"Usage: logtool [flags...] audit|debug|print|set-debug-level [args...]")
 	fmt.Fprintln(w, "Common flags:")
 	flag.PrintDefaults()
 	fmt.Fprintln(w, "Commands:")
@@ -35,6 +45,7 @@ func printUsage() {
 }
 
 var subcommands = []commands.Command{
+	{"audit", "", 0, 0, auditSubcommand},
 	{"debug", "          level args...", 2, -1, debugSubcommand},
 	{"get-stack-trace", "", 0, 0, getStackTraceSubcommand},
 	{"print", "                args...", 1, -1, printSubcommand},
@@ -50,6 +50,12 @@ func startRpcd(eventChannel chan<- struct{}, pauseTable *pauseTableType,
 			"ListImages",
 			"PauseUpdates",
 			"ResumeUpdates",
+		},
+		ReadOnlyMethods: []string{
+			"GetMachine",
+			"GetMdb",
+			"GetMdbUpdates",
+			"ListImages",
 		}})
 	return rpcObj
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/log/cmdlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc/setupclient"
+	proto "github.com/Cloud-Foundations/Dominator/proto/imageunpacker"
 )
 
 var (
@@ -32,6 +33,14 @@ func printUsage() {
 	flag.PrintDefaults()
 	fmt.Fprintln(w, "Commands:")
 	commands.PrintCommands(w, subcommands)
+	fmt.Fprintln(w, "Built-in export types:")
+	fmt.Fprintf(w, "  %s: raw device contents\n", proto.ExportTypeRaw)
+	fmt.Fprintf(w, "  %s: sparse QCOW2 (version 2) image\n",
+		proto.ExportTypeQcow2)
+	fmt.Fprintf(w, "  %s: fixed VHD image\n", proto.ExportTypeVhd)
+	fmt.Fprintln(w,
+		"  Destination: directory, filename or HTTP(S) URL to PUT to")
+	fmt.Fprintln(w, "  Other types are passed to the export tool on the server")
 }
 
 var subcommands = []commands.Command{
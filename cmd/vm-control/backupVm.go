@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	hyperclient "github.com/Cloud-Foundations/Dominator/hypervisor/client"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+func backupVmSubcommand(args []string, logger log.DebugLogger) error {
+	if err := backupVm(args[0], logger); err != nil {
+		return fmt.Errorf("error backing up VM: %s", err)
+	}
+	return nil
+}
+
+func backupVm(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return backupVmOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func backupVmOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.BackupVmRequest{
+		ForceIfNotStopped: *forceIfNotStopped,
+		IpAddress:         ipAddr,
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	manifestHash, err := hyperclient.BackupVm(client, request)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%x\n", manifestHash)
+	return nil
+}
@@ -293,6 +293,11 @@ func checkTags(logger log.DebugLogger) {
 	if client == nil {
 		return
 	}
+	imageName, err = imgclient.ResolveImageName(client, imageName)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
 	expiresAt, err := imgclient.GetImageExpiration(client, imageName)
 	if err != nil {
 		logger.Println(err)
@@ -532,19 +537,9 @@ func getImageUsage(imageName string) (uint64, error) {
 		logger.Printf("no imageserver specified, guessing image size\n")
 		return 2 << 30, nil
 	}
-	var name string
-	if isDir, err := imgclient.CheckDirectory(client, imageName); err != nil {
+	name, err := imgclient.ResolveImageName(client, imageName)
+	if err != nil {
 		return 0, err
-	} else if isDir {
-		name, err = imgclient.FindLatestImage(client, imageName, false)
-		if err != nil {
-			return 0, err
-		}
-		if name == "" {
-			return 0, errors.New("no images in directory: " + imageName)
-		}
-	} else {
-		name = imageName
 	}
 	usage, exists, err := imgclient.GetImageUsageEstimate(client, name)
 	if err != nil {
@@ -785,19 +780,9 @@ func updateVolumeInitParams(vinitParams []volumeInitParams) error {
 		return nil
 	}
 	// TODO(rgooch): pass this in somehow to reduce duplication.
-	var name string
-	if isDir, err := imgclient.CheckDirectory(client, *imageName); err != nil {
+	name, err := imgclient.ResolveImageName(client, *imageName)
+	if err != nil {
 		return err
-	} else if isDir {
-		name, err = imgclient.FindLatestImage(client, *imageName, false)
-		if err != nil {
-			return err
-		}
-		if name == "" {
-			return errors.New("no images in directory: " + *imageName)
-		}
-	} else {
-		name = *imageName
 	}
 	response, err := imgclient.GetImageInodes(client, name, filenames)
 	if err != nil {
@@ -59,7 +59,7 @@ var (
 		constants.FleetManagerPortNumber,
 		"Port number of Fleet Resource Manager")
 	forceIfNotStopped = flag.Bool("forceIfNotStopped", false,
-		"If true, snapshot or restore VM even if not stopped")
+		"If true, backup, snapshot or restore VM even if not stopped")
 	hypervisorArchitectureToMatch hyper_proto.ArchitectureType
 	hypervisorHostname            = flag.String("hypervisorHostname", "",
 		"Hostname of hypervisor")
@@ -235,6 +235,7 @@ func printUsage() {
 
 var subcommands = []commands.Command{
 	{"add-vm-volumes", "IPaddr", 1, 1, addVmVolumesSubcommand},
+	{"backup-vm", "IPaddr", 1, 1, backupVmSubcommand},
 	{"become-primary-vm-owner", "IPaddr", 1, 1, becomePrimaryVmOwnerSubcommand},
 	{"change-vm-console-type", "IPaddr", 1, 1, changeVmConsoleTypeSubcommand},
 	{"change-vm-cpu-priority", "IPaddr", 1, 1, changeVmCpuPrioritySubcommand},
@@ -2,6 +2,7 @@ package main
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
@@ -16,7 +17,9 @@ import (
 	hyperclient "github.com/Cloud-Foundations/Dominator/hypervisor/client"
 	"github.com/Cloud-Foundations/Dominator/lib/errors"
 	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
+	objclient "github.com/Cloud-Foundations/Dominator/lib/objectserver/client"
 	proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
 )
 
@@ -26,6 +29,11 @@ type directoryRestorer struct {
 
 type gzipDecompressor struct{}
 
+type objectServerRestorer struct {
+	client   *objclient.ObjectClient
+	manifest proto.VmBackupManifest
+}
+
 type tarRestorer struct {
 	closer     io.Closer
 	nextHeader *tar.Header
@@ -125,6 +133,12 @@ func restoreVm(source string, logger log.DebugLogger) error {
 		} else {
 			return fmt.Errorf("unknown extension: %s", u.Path)
 		}
+	} else if u.Scheme == "objectserver" {
+		restorer, err = newObjectServerRestorer(u.Host,
+			strings.TrimPrefix(u.Path, "/"))
+		if err != nil {
+			return err
+		}
 	} else {
 		return fmt.Errorf("unknown scheme: %s", u.Scheme)
 	}
@@ -252,6 +266,69 @@ func (gzipDecompressor) MakeReader(r io.ReadCloser) io.ReadCloser {
 	}
 }
 
+func newObjectServerRestorer(address, manifestHash string) (
+	*objectServerRestorer, error) {
+	var hashVal hash.Hash
+	if err := hashVal.UnmarshalText([]byte(manifestHash)); err != nil {
+		return nil, err
+	}
+	client := objclient.NewObjectClient(address)
+	manifest, err := hyperclient.ReadVmBackupManifest(client, hashVal)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &objectServerRestorer{client: client, manifest: manifest}, nil
+}
+
+func (restorer *objectServerRestorer) Close() error {
+	return restorer.client.Close()
+}
+
+func (restorer *objectServerRestorer) OpenReader(filename string) (
+	io.ReadCloser, uint64, error) {
+	switch filename {
+	case "info.json":
+		data, err := json.Marshal(restorer.manifest.VmInfo)
+		if err != nil {
+			return nil, 0, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), uint64(len(data)), nil
+	case "user-data.raw":
+		data := restorer.manifest.UserData
+		if len(data) < 1 {
+			return nil, 0, &os.PathError{
+				Op:   "open",
+				Path: filename,
+				Err:  os.ErrNotExist,
+			}
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), uint64(len(data)), nil
+	}
+	var volIndex int
+	if filename == "root" {
+		volIndex = 0
+	} else if _, err := fmt.Sscanf(filename, "secondary-volume.%d",
+		&volIndex); err != nil {
+		return nil, 0, err
+	} else {
+		volIndex++
+	}
+	if volIndex < 0 || volIndex >= len(restorer.manifest.Volumes) {
+		return nil, 0, &os.PathError{
+			Op:   "open",
+			Path: filename,
+			Err:  os.ErrNotExist,
+		}
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(hyperclient.WriteVmBackupVolume(writer,
+			restorer.client, restorer.manifest, uint(volIndex)))
+	}()
+	return reader, restorer.manifest.Volumes[volIndex].Size, nil
+}
+
 func newTarRestorer(filename string,
 	decompressor readerMaker) (*tarRestorer, error) {
 	file, err := os.OpenFile(filename, os.O_RDONLY, 0)
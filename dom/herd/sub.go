@@ -17,9 +17,11 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/format"
 	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/fieldlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
 	"github.com/Cloud-Foundations/Dominator/lib/resourcepool"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/trace"
 	domproto "github.com/Cloud-Foundations/Dominator/proto/dominator"
 	subproto "github.com/Cloud-Foundations/Dominator/proto/sub"
 	"github.com/Cloud-Foundations/Dominator/sub/client"
@@ -202,6 +204,10 @@ func (sub *Sub) connectAndPoll2(swapImages, failOnReboot bool,
 	defer func() {
 		timer.Stop()
 		sub.publishedStatus = sub.status
+		if sub.status != previousStatus {
+			sub.fieldLogger().DebugWithFields(0, "status changed",
+				"from", previousStatus.String(), "to", sub.status.String())
+		}
 		switch sub.status {
 		case statusUnknown:
 		case statusConnecting:
@@ -374,6 +380,9 @@ func (sub *Sub) loadConfiguration(swapImages bool) bool {
 	if sub.requiredImage != requiredImage || sub.plannedImage != plannedImage {
 		changed = true
 	}
+	// Record the names of the images that channel references resolved to.
+	requiredImageName = sub.herd.imageManager.ResolveName(requiredImageName)
+	plannedImageName = sub.herd.imageManager.ResolveName(plannedImageName)
 	sub.requiredImageName = requiredImageName
 	sub.requiredImage = requiredImage
 	sub.plannedImageName = plannedImageName
@@ -445,6 +454,14 @@ func (sub *Sub) processFileUpdates() bool {
 // reasonable.
 func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 	fast, failOnReboot bool) bool {
+	span := trace.StartSpan("herd.poll", nil)
+	span.SetAttribute("sub", sub.mdb.Hostname)
+	defer func() {
+		span.SetAttribute("status", sub.status.String())
+		span.End()
+	}()
+	srpcClient.SetTraceSpan(span)
+	defer srpcClient.SetTraceSpan(nil)
 	if err := srpcClient.SetTimeout(5 * time.Minute); err != nil {
 		sub.herd.logger.Printf("poll(%s): error setting timeout: %s\n", sub)
 	}
@@ -492,7 +509,7 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 	} else {
 		haveImage = true
 	}
-	logger := sub.herd.logger
+	logger := sub.fieldLogger()
 	sub.lastPollStartTime = time.Now()
 	if err := client.CallPoll(srpcClient, request, &reply); err != nil {
 		srpcClient.Close()
@@ -507,8 +524,9 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 			sub.status = statusFailedToPoll
 			retval = true
 		}
-		logger.Printf("Error calling %s.Poll(%s): %s\n",
-			sub, format.Duration(time.Since(sub.lastPollStartTime)), err)
+		logger.PrintWithFields("error calling Poll",
+			"duration", format.Duration(time.Since(sub.lastPollStartTime)),
+			"error", err)
 		return retval
 	}
 	sub.lastDisruptionState = reply.DisruptionState
@@ -541,7 +559,8 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 		sub.freeSpaceThreshold = nil
 		if err := fs.RebuildInodePointers(); err != nil {
 			sub.status = statusFailedToPoll
-			logger.Printf("Error building pointers for: %s %s\n", sub, err)
+			logger.PrintWithFields("error building pointers",
+				"error", err)
 			return false
 		}
 		fs.BuildEntryMap()
@@ -584,7 +603,8 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 		}
 	}
 	if previousStatus == statusFetching && reply.LastFetchError != "" {
-		logger.Printf("Fetch failure for: %s: %s\n", sub, reply.LastFetchError)
+		logger.PrintWithFields("fetch failure",
+			"error", reply.LastFetchError)
 		sub.status = statusFailedToFetch
 		if sub.fileSystem == nil {
 			sub.generationCount = 0 // Force a full poll next cycle.
@@ -601,8 +621,8 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 		case subproto.ErrorDisruptionDenied:
 			sub.status = statusDisruptionDenied
 		default:
-			logger.Printf("Update failure for: %s: %s\n",
-				sub, reply.LastUpdateError)
+			logger.PrintWithFields("update failure",
+				"error", reply.LastUpdateError)
 			sub.status = statusFailedToUpdate
 		}
 		sub.scanCountAtLastUpdateEnd = reply.ScanCount
@@ -658,15 +678,16 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 		return false
 	}
 	if sub.requiredImage != nil {
-		idle, status := sub.fetchMissingObjects(srpcClient, sub.requiredImage,
-			reply.FreeSpace, true, fast)
+		idle, status := sub.fetchMissingObjects(srpcClient, span,
+			sub.requiredImage, reply.FreeSpace, true, fast)
 		if !idle {
 			sub.status = status
 			sub.reclaim()
 			return false
 		}
 		sub.status = statusComputingUpdate
-		if idle, status := sub.sendUpdate(srpcClient, failOnReboot); !idle {
+		if idle, status := sub.sendUpdate(srpcClient, span,
+			failOnReboot); !idle {
 			sub.status = status
 			sub.reclaim()
 			return false
@@ -675,8 +696,8 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus,
 		sub.status = statusImageNotReady
 	}
 	if sub.plannedImage != sub.requiredImage {
-		idle, status := sub.fetchMissingObjects(srpcClient, sub.plannedImage,
-			reply.FreeSpace, false, fast)
+		idle, status := sub.fetchMissingObjects(srpcClient, span,
+			sub.plannedImage, reply.FreeSpace, false, fast)
 		if !idle {
 			if status != statusImageNotReady &&
 				status != statusNotEnoughFreeSpace {
@@ -757,8 +778,9 @@ func compareConfigs(oldConf, newConf subproto.Configuration) bool {
 }
 
 // Returns true if all required objects are available.
-func (sub *Sub) fetchMissingObjects(srpcClient *srpc.Client, img *image.Image,
-	freeSpace *uint64, isRequiredImage, fast bool) (
+func (sub *Sub) fetchMissingObjects(srpcClient *srpc.Client,
+	parentSpan *trace.Span, img *image.Image, freeSpace *uint64,
+	isRequiredImage, fast bool) (
 	bool, subStatus) {
 	if img == nil {
 		return false, statusImageNotReady
@@ -769,6 +791,11 @@ func (sub *Sub) fetchMissingObjects(srpcClient *srpc.Client, img *image.Image,
 	} else {
 		imageType = "planned"
 	}
+	span := trace.StartSpan("herd.fetch", parentSpan)
+	span.SetAttribute("image.type", imageType)
+	defer span.End()
+	srpcClient.SetTraceSpan(span)
+	defer srpcClient.SetTraceSpan(parentSpan)
 	logger := sub.herd.logger
 	subObj := lib.Sub{
 		Hostname:       sub.mdb.Hostname,
@@ -804,6 +831,7 @@ func (sub *Sub) fetchMissingObjects(srpcClient *srpc.Client, img *image.Image,
 		var response subproto.FetchResponse
 		err := client.CallFetch(srpcClient, request, &response)
 		if err != nil {
+			span.SetError(err)
 			srpcClient.Close()
 			logger.Printf("Error calling %s:Subd.Fetch(): %s\n", sub, err)
 			if err == srpc.ErrorAccessToMethodDenied {
@@ -822,6 +850,7 @@ func (sub *Sub) fetchMissingObjects(srpcClient *srpc.Client, img *image.Image,
 		sub.status = statusPushing
 		err := lib.PushObjects(subObj, objectsToPush, logger)
 		if err != nil {
+			span.SetError(err)
 			if err == srpc.ErrorAccessToMethodDenied {
 				return false, statusPushDenied
 			}
@@ -842,7 +871,7 @@ func (sub *Sub) fetchMissingObjects(srpcClient *srpc.Client, img *image.Image,
 }
 
 // Returns true if no update needs to be performed.
-func (sub *Sub) sendUpdate(srpcClient *srpc.Client,
+func (sub *Sub) sendUpdate(srpcClient *srpc.Client, parentSpan *trace.Span,
 	failOnReboot bool) (bool, subStatus) {
 	logger := sub.herd.logger
 	var request subproto.UpdateRequest
@@ -879,9 +908,15 @@ func (sub *Sub) sendUpdate(srpcClient *srpc.Client,
 	}
 	sub.status = statusSendingUpdate
 	sub.lastUpdateTime = time.Now()
+	span := trace.StartSpan("herd.update", parentSpan)
+	span.SetAttribute("image", sub.requiredImageName)
+	defer span.End()
+	srpcClient.SetTraceSpan(span)
+	defer srpcClient.SetTraceSpan(parentSpan)
 	logger.Printf("Calling %s:Subd.Update() for image: %s\n",
 		sub, sub.requiredImageName)
 	if err := client.CallUpdate(srpcClient, request, &reply); err != nil {
+		span.SetError(err)
 		srpcClient.Close()
 		logger.Printf("Error calling %s:Subd.Update(): %s\n", sub, err)
 		if err == srpc.ErrorAccessToMethodDenied {
@@ -1218,3 +1253,10 @@ func sendFastUpdateFullMessage(ch chan<- FastUpdateMessage,
 		Synced:         status == statusSynced,
 	}
 }
+
+// fieldLogger returns a logger which attaches the hostname and required image
+// of the sub to each log entry.
+func (sub *Sub) fieldLogger() *fieldlogger.Logger {
+	return fieldlogger.New(sub.herd.logger, "hostname", sub.mdb.Hostname,
+		"image", sub.requiredImageName)
+}
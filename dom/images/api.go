@@ -21,6 +21,7 @@ type Manager struct {
 	imageExpireChannel   chan<- string
 	imagesByName         map[string]*image.Image
 	missingImages        map[string]error
+	resolvedNames        map[string]string // Key: channel reference.
 }
 
 func New(imageServerAddress string, logger log.Logger) *Manager {
@@ -49,6 +50,13 @@ func (m *Manager) GetNoError(name string) *image.Image {
 	return img
 }
 
+// ResolveName will return the name of the image that a channel reference was
+// last resolved to. If name is not a channel reference or it has not been
+// resolved yet, name is returned.
+func (m *Manager) ResolveName(name string) string {
+	return m.resolveName(name)
+}
+
 func (m *Manager) SetImageInterestList(images map[string]struct{}, wait bool) {
 	m.setImageInterestList(images, wait)
 }
@@ -23,6 +23,7 @@ func newManager(imageServerAddress string, logger log.Logger) *Manager {
 		imageExpireChannel:   imageExpireChannel,
 		imagesByName:         make(map[string]*image.Image),
 		missingImages:        make(map[string]error),
+		resolvedNames:        make(map[string]string),
 	}
 	go m.manager(imageInterestChannel, imageRequestChannel, imageExpireChannel)
 	return m
@@ -72,6 +73,15 @@ func (m *Manager) getWait(name string) (*image.Image, error) {
 	return m.getNoWait(name)
 }
 
+func (m *Manager) resolveName(name string) string {
+	m.RLock()
+	defer m.RUnlock()
+	if resolvedName, ok := m.resolvedNames[name]; ok {
+		return resolvedName
+	}
+	return name
+}
+
 func (m *Manager) setImageInterestList(images map[string]struct{}, wait bool) {
 	delete(images, "")
 	m.imageInterestChannel <- images
@@ -85,6 +95,7 @@ func (m *Manager) manager(imageInterestChannel <-chan map[string]struct{},
 	imageExpireChannel <-chan string) {
 	var imageClient *srpc.Client
 	timer := time.NewTimer(time.Second)
+	channelTicker := time.NewTicker(time.Minute)
 	for {
 		select {
 		case imageList := <-imageInterestChannel:
@@ -112,6 +123,8 @@ func (m *Manager) manager(imageInterestChannel <-chan map[string]struct{},
 			for name := range missingImages {
 				imageClient = m.requestImage(imageClient, name)
 			}
+		case <-channelTicker.C:
+			imageClient = m.refreshChannels(imageClient)
 		}
 		m.RLock()
 		if len(m.missingImages) > 0 {
@@ -144,6 +157,13 @@ func (m *Manager) setInterest(imageClient *srpc.Client,
 			m.Unlock()
 		}
 	}
+	for name := range m.resolvedNames {
+		if _, ok := imageList[name]; !ok {
+			m.Lock()
+			delete(m.resolvedNames, name)
+			m.Unlock()
+		}
+	}
 	if deletedSome {
 		m.rebuildDeDuper()
 	}
@@ -157,10 +177,21 @@ func (m *Manager) requestImage(imageClient *srpc.Client,
 	}
 	var img *image.Image
 	var err error
-	imageClient, img, err = m.loadImage(imageClient, name)
+	resolvedName := name
+	if image.IsChannelReference(name) {
+		imageClient, resolvedName, err = m.resolveChannel(imageClient, name)
+	}
+	if err == nil {
+		if img = m.imagesByName[resolvedName]; img == nil {
+			imageClient, img, err = m.loadImage(imageClient, resolvedName)
+		}
+	}
 	m.Lock()
 	defer m.Unlock()
 	m.cachedImagesByName = nil
+	if err == nil && resolvedName != name {
+		m.resolvedNames[name] = resolvedName
+	}
 	if img != nil && err == nil {
 		delete(m.missingImages, name)
 		m.imagesByName[name] = img
@@ -171,19 +202,69 @@ func (m *Manager) requestImage(imageClient *srpc.Client,
 	return imageClient
 }
 
-func (m *Manager) loadImage(imageClient *srpc.Client, name string) (
-	*srpc.Client, *image.Image, error) {
-	if imageClient == nil {
+// refreshChannels will re-resolve channel references and will load the new
+// image for any channels which have been moved.
+func (m *Manager) refreshChannels(imageClient *srpc.Client) *srpc.Client {
+	references := make(map[string]string)
+	m.RLock()
+	for reference, resolvedName := range m.resolvedNames {
+		references[reference] = resolvedName
+	}
+	m.RUnlock()
+	for reference, oldName := range references {
+		var newName string
 		var err error
-		imageClient, err = srpc.DialHTTP("tcp", m.imageServerAddress, 0)
-		if err != nil {
-			if !m.loggedDialFailure {
-				m.logger.Printf("Error dialing: %s: %s\n",
-					m.imageServerAddress, err)
-				m.loggedDialFailure = true
-			}
-			return nil, nil, err
+		imageClient, newName, err = m.resolveChannel(imageClient, reference)
+		if err != nil || newName == oldName {
+			continue
+		}
+		m.logger.Printf("Channel: %s moved from: %s to: %s\n",
+			reference, oldName, newName)
+		m.Lock()
+		m.cachedImagesByName = nil
+		delete(m.imagesByName, reference)
+		m.Unlock()
+		imageClient = m.requestImage(imageClient, reference)
+	}
+	return imageClient
+}
+
+func (m *Manager) dial(imageClient *srpc.Client) (*srpc.Client, error) {
+	if imageClient != nil {
+		return imageClient, nil
+	}
+	imageClient, err := srpc.DialHTTP("tcp", m.imageServerAddress, 0)
+	if err != nil {
+		if !m.loggedDialFailure {
+			m.logger.Printf("Error dialing: %s: %s\n",
+				m.imageServerAddress, err)
+			m.loggedDialFailure = true
 		}
+		return nil, err
+	}
+	return imageClient, nil
+}
+
+func (m *Manager) resolveChannel(imageClient *srpc.Client, reference string) (
+	*srpc.Client, string, error) {
+	imageClient, err := m.dial(imageClient)
+	if err != nil {
+		return nil, "", err
+	}
+	imageName, err := client.ResolveChannel(imageClient, reference)
+	if err != nil {
+		m.logger.Printf("Error resolving channel: %s: %s\n", reference, err)
+		imageClient.Close()
+		return nil, "", err
+	}
+	return imageClient, imageName, nil
+}
+
+func (m *Manager) loadImage(imageClient *srpc.Client, name string) (
+	*srpc.Client, *image.Image, error) {
+	imageClient, err := m.dial(imageClient)
+	if err != nil {
+		return nil, nil, err
 	}
 	img, err := client.GetImage(imageClient, name)
 	if err != nil {
@@ -47,7 +47,13 @@ func Setup(config Config, params Params) {
 	}
 	srpc.RegisterNameWithOptions("Dominator", rpcObj,
 		srpc.ReceiverOptions{
-			PublicMethods:          publicMethods,
+			PublicMethods: publicMethods,
+			ReadOnlyMethods: []string{
+				"GetDefaultImage",
+				"GetInfoForSubs",
+				"GetSubsConfiguration",
+				"ListSubs",
+			},
 			UnauthenticatedMethods: unauthenticatedMethods,
 		},
 	)
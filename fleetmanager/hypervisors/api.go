@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Cloud-Foundations/Dominator/fleetmanager/topology"
+	"github.com/Cloud-Foundations/Dominator/lib/bmc"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/tags"
@@ -32,6 +33,9 @@ const (
 type hypervisorType struct {
 	logger         log.DebugLogger
 	receiveChannel chan struct{}
+	bmcMutex       sync.Mutex // Lock bmcDriver and bmcDriverKey.
+	bmcDriver      bmc.Driver
+	bmcDriverKey   string       // BMC type and address used for bmcDriver.
 	mutex          sync.RWMutex // Lock everything below.
 	fm_proto.Hypervisor
 	cachedSerialNumber string
@@ -66,24 +70,26 @@ type locationType struct {
 }
 
 type Manager struct {
-	ipmiLimiter      chan struct{}
-	ipmiPasswordFile string
-	ipmiUsername     string
-	logger           log.DebugLogger
-	storer           Storer
-	mutex            sync.RWMutex               // Protect everything below.
-	allocatingIPs    map[string]struct{}        // Key: VM IP address.
-	hypervisors      map[string]*hypervisorType // Key: hypervisor machine name.
-	hypervisorsByHW  map[string]*hypervisorType // Key: hypervisor HW addr.
-	hypervisorsByIP  map[string]*hypervisorType // Key: hypervisor IP.
-	hypervisorsBySN  map[string]*hypervisorType // Key: serial number, nil: dup
-	locations        map[string]*locationType   // Key: location.
-	migratingIPs     map[string]struct{}        // Key: VM IP address.
-	notifiers        map[<-chan fm_proto.Update]*locationType
-	topology         *topology.Topology
-	topologyLoaded   chan struct{}          // Full at start, empty when loaded.
-	subnets          map[string]*subnetType // Key: Gateway IP.
-	vms              map[string]*vmInfoType // Key: VM IP address.
+	bmcInsecureSkipVerify bool
+	ipmiLimiter           chan struct{}
+	ipmiPassword          string // Read from ipmiPasswordFile, for Redfish.
+	ipmiPasswordFile      string
+	ipmiUsername          string
+	logger                log.DebugLogger
+	storer                Storer
+	mutex                 sync.RWMutex               // Protect everything below.
+	allocatingIPs         map[string]struct{}        // Key: VM IP address.
+	hypervisors           map[string]*hypervisorType // Key: hypervisor machine name.
+	hypervisorsByHW       map[string]*hypervisorType // Key: hypervisor HW addr.
+	hypervisorsByIP       map[string]*hypervisorType // Key: hypervisor IP.
+	hypervisorsBySN       map[string]*hypervisorType // Key: serial number, nil: dup
+	locations             map[string]*locationType   // Key: location.
+	migratingIPs          map[string]struct{}        // Key: VM IP address.
+	notifiers             map[<-chan fm_proto.Update]*locationType
+	topology              *topology.Topology
+	topologyLoaded        chan struct{}          // Full at start, empty when loaded.
+	subnets               map[string]*subnetType // Key: Gateway IP.
+	vms                   map[string]*vmInfoType // Key: VM IP address.
 }
 
 type probeStatus uint
@@ -94,10 +100,11 @@ type serialStorer interface {
 }
 
 type StartOptions struct {
-	IpmiPasswordFile string
-	IpmiUsername     string
-	Logger           log.DebugLogger
-	Storer           Storer
+	BmcInsecureSkipVerify bool // Do not verify Redfish BMC certificates.
+	IpmiPasswordFile      string
+	IpmiUsername          string
+	Logger                log.DebugLogger
+	Storer                Storer
 }
 
 type Storer interface {
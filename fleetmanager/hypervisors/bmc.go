@@ -0,0 +1,154 @@
+package hypervisors
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+
+	"github.com/Cloud-Foundations/Dominator/lib/bmc"
+	"github.com/Cloud-Foundations/Dominator/lib/bmc/ipmi"
+	"github.com/Cloud-Foundations/Dominator/lib/bmc/redfish"
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/html"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/url"
+)
+
+// closeBmcDriver will release the cached BMC driver for the hypervisor.
+func (h *hypervisorType) closeBmcDriver() {
+	h.bmcMutex.Lock()
+	defer h.bmcMutex.Unlock()
+	if h.bmcDriver != nil {
+		h.bmcDriver.Close()
+		h.bmcDriver = nil
+		h.bmcDriverKey = ""
+	}
+}
+
+// getBmcDriver will return the BMC driver for the hypervisor, based on the
+// BmcType in the topology. The driver is cached and is replaced if the BMC
+// type or address changes. If there is no BMC address or no credentials, nil
+// is returned.
+func (m *Manager) getBmcDriver(h *hypervisorType) bmc.Driver {
+	if m.ipmiPasswordFile == "" || m.ipmiUsername == "" {
+		return nil
+	}
+	var address string
+	if len(h.Machine.IPMI.HostIpAddress) > 0 {
+		address = h.Machine.IPMI.HostIpAddress.String()
+	} else if h.Machine.IPMI.Hostname != "" {
+		address = h.Machine.IPMI.Hostname
+	} else {
+		h.closeBmcDriver()
+		return nil
+	}
+	bmcType := h.Machine.BmcType
+	if bmcType == "" {
+		bmcType = bmc.TypeIpmi
+	}
+	key := bmcType + "/" + address
+	h.bmcMutex.Lock()
+	defer h.bmcMutex.Unlock()
+	if h.bmcDriver != nil && h.bmcDriverKey == key {
+		return h.bmcDriver
+	}
+	if h.bmcDriver != nil {
+		h.bmcDriver.Close()
+		h.bmcDriver = nil
+		h.bmcDriverKey = ""
+	}
+	switch bmcType {
+	case bmc.TypeIpmi:
+		h.bmcDriver = ipmi.New(ipmi.Params{
+			Address:      address,
+			PasswordFile: m.ipmiPasswordFile,
+			Username:     m.ipmiUsername,
+		})
+	case bmc.TypeRedfish:
+		h.bmcDriver = redfish.New(redfish.Params{
+			Address:            address,
+			InsecureSkipVerify: m.bmcInsecureSkipVerify,
+			Password:           m.ipmiPassword,
+			Username:           m.ipmiUsername,
+		})
+	default:
+		h.logger.Printf("unsupported BMC type: %s\n", bmcType)
+		return nil
+	}
+	h.bmcDriverKey = key
+	return h.bmcDriver
+}
+
+func (m *Manager) showHypervisorInventoryHandler(w http.ResponseWriter,
+	req *http.Request) {
+	parsedQuery := url.ParseQuery(req.URL)
+	if len(parsedQuery.Flags) != 1 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var hostname string
+	for name := range parsedQuery.Flags {
+		hostname = name
+	}
+	h, err := m.getLockedHypervisor(hostname, false)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	driver := m.getBmcDriver(h)
+	h.mutex.RUnlock()
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	if driver == nil {
+		fmt.Fprintln(writer, "No BMC address or credentials")
+		return
+	}
+	m.ipmiGetSlot()
+	inventory, err := driver.GetInventory()
+	var powerState bmc.PowerState
+	if err == nil {
+		powerState, err = driver.GetPowerState()
+	}
+	m.ipmiReleaseSlot()
+	if err != nil {
+		fmt.Fprintf(writer, "Error reading inventory: %s\n", err)
+		return
+	}
+	if parsedQuery.OutputType() == url.OutputTypeJson {
+		json.WriteWithIndent(writer, "    ", inventory)
+		return
+	}
+	fmt.Fprintf(writer, "<title>Inventory for hypervisor %s</title>\n",
+		hostname)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintf(writer, "<h3>Inventory for hypervisor %s</h3>\n", hostname)
+	fmt.Fprintln(writer, `<table border="1">`)
+	tw, _ := html.NewTableWriter(writer, true, "Property", "Value")
+	tw.WriteRow("", "", "Power", powerState.String())
+	tw.WriteRow("", "", "Manufacturer", inventory.Manufacturer)
+	tw.WriteRow("", "", "Model", inventory.Model)
+	tw.WriteRow("", "", "Serial Number", inventory.SerialNumber)
+	if inventory.BiosVersion != "" {
+		tw.WriteRow("", "", "BIOS Version", inventory.BiosVersion)
+	}
+	if inventory.NumProcessors > 0 {
+		tw.WriteRow("", "", "Processors",
+			fmt.Sprintf("%d %s", inventory.NumProcessors,
+				inventory.ProcessorModel))
+	}
+	if inventory.MemoryInMiB > 0 {
+		tw.WriteRow("", "", "Memory",
+			format.FormatBytes(inventory.MemoryInMiB<<20))
+	}
+	for _, ethernetInterface := range inventory.EthernetInterfaces {
+		value := ethernetInterface.MacAddress
+		if ethernetInterface.SpeedMbps > 0 {
+			value += fmt.Sprintf(" (%d Mb/s)", ethernetInterface.SpeedMbps)
+		}
+		tw.WriteRow("", "", "NIC "+ethernetInterface.Name, value)
+	}
+	tw.Close()
+	fmt.Fprintln(writer, "</body>")
+}
@@ -4,17 +4,13 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
-	"os/exec"
-	"strings"
 	"time"
 
-	"github.com/Cloud-Foundations/Dominator/lib/firmware"
+	"github.com/Cloud-Foundations/Dominator/lib/bmc"
 	"github.com/Cloud-Foundations/Dominator/lib/net/util"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 )
 
-const powerOff = "Power is off"
-
 var (
 	myIP    net.IP
 	wolConn *net.UDPConn
@@ -38,11 +34,8 @@ func (m *Manager) powerOnMachine(hostname string,
 	if err := h.checkAuth(authInfo); err != nil {
 		return err
 	}
-	var ipmiHostname string
-	if len(h.Machine.IPMI.HostIpAddress) > 0 {
-		ipmiHostname = h.Machine.IPMI.HostIpAddress.String()
-	} else if h.Machine.IPMI.Hostname != "" {
-		ipmiHostname = h.Machine.IPMI.Hostname
+	if driver := m.getBmcDriver(h); driver != nil {
+		return driver.PowerOn()
 	} else if sentWakeOnLan, err := m.wakeOnLan(h); err != nil {
 		return err
 	} else if sentWakeOnLan {
@@ -50,30 +43,16 @@ func (m *Manager) powerOnMachine(hostname string,
 	} else {
 		return fmt.Errorf("no IPMI address for: %s", hostname)
 	}
-	cmd := exec.Command("ipmitool", "-f", m.ipmiPasswordFile,
-		"-H", ipmiHostname, "-I", "lanplus", "-U", m.ipmiUsername,
-		"chassis", "power", "on")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("%s: %s", err, string(output))
-	}
-	return nil
 }
 
-// probeSerialNumber will start a delayed background IPMI probe of the serial
+// probeSerialNumber will start a delayed background BMC probe of the serial
 // number if not discovered otherwise.
 func (m *Manager) probeSerialNumber(h *hypervisorType) {
 	if h.serialNumber != "" {
 		return
 	}
-	if m.ipmiPasswordFile == "" || m.ipmiUsername == "" {
-		return
-	}
-	var ipmiHostname string
-	if len(h.Machine.IPMI.HostIpAddress) > 0 {
-		ipmiHostname = h.Machine.IPMI.HostIpAddress.String()
-	} else if h.Machine.IPMI.Hostname != "" {
-		ipmiHostname = h.Machine.IPMI.Hostname
-	} else {
+	driver := m.getBmcDriver(h)
+	if driver == nil {
 		return
 	}
 	// Run the rest in the background.
@@ -85,7 +64,7 @@ func (m *Manager) probeSerialNumber(h *hypervisorType) {
 		if h.getSerialNumber() != "" {
 			return
 		}
-		serialNumber := m.readSerialNumber(ipmiHostname)
+		serialNumber := m.readSerialNumber(driver)
 		if h.isDeleteScheduled() {
 			return
 		}
@@ -116,15 +95,8 @@ func (m *Manager) probeSerialNumber(h *hypervisorType) {
 }
 
 func (m *Manager) probeUnreachable(h *hypervisorType) probeStatus {
-	if m.ipmiPasswordFile == "" || m.ipmiUsername == "" {
-		return probeStatusUnreachable
-	}
-	var ipmiHostname string
-	if len(h.Machine.IPMI.HostIpAddress) > 0 {
-		ipmiHostname = h.Machine.IPMI.HostIpAddress.String()
-	} else if h.Machine.IPMI.Hostname != "" {
-		ipmiHostname = h.Machine.IPMI.Hostname
-	} else {
+	driver := m.getBmcDriver(h)
+	if driver == nil {
 		return probeStatusUnreachable
 	}
 	h.mutex.RLock()
@@ -135,49 +107,28 @@ func (m *Manager) probeUnreachable(h *hypervisorType) probeStatus {
 		time.Until(h.lastIpmiProbe.Add(mimimumProbeInterval)) > 0 {
 		return probeStatusOff
 	}
-	cmd := exec.Command("ipmitool", "-f", m.ipmiPasswordFile,
-		"-H", ipmiHostname, "-I", "lanplus", "-U", m.ipmiUsername,
-		"chassis", "power", "status")
+	powerState, err := driver.GetPowerState()
 	h.lastIpmiProbe = time.Now()
-	if output, err := cmd.Output(); err != nil {
+	if err != nil {
 		if previousProbeStatus == probeStatusOff {
 			return probeStatusOff
 		} else {
 			return probeStatusUnreachable
 		}
-	} else if strings.Contains(string(output), powerOff) {
+	} else if powerState == bmc.PowerStateOff {
 		return probeStatusOff
 	}
 	return probeStatusUnreachable
 }
 
-func (m *Manager) readSerialNumber(ipmiHostname string) string {
+func (m *Manager) readSerialNumber(driver bmc.Driver) string {
 	m.ipmiGetSlot()
-	cmd := exec.Command("ipmitool", "-f", m.ipmiPasswordFile,
-		"-H", ipmiHostname, "-I", "lanplus", "-U", m.ipmiUsername,
-		"fru", "print")
-	output, err := cmd.Output()
+	serialNumber, err := driver.GetSerialNumber()
 	m.ipmiReleaseSlot()
 	if err != nil {
 		return ""
 	}
-	var boardSerial, productSerial string
-	for _, line := range strings.Split(string(output), "\n") {
-		splitLine := strings.Split(line, ":")
-		if len(splitLine) != 2 {
-			continue
-		}
-		switch strings.TrimSpace(splitLine[0]) {
-		case "Board Serial":
-			boardSerial = firmware.ExtractSerialNumber(splitLine[1])
-		case "Product Serial":
-			productSerial = firmware.ExtractSerialNumber(splitLine[1])
-		}
-	}
-	if productSerial != "" {
-		return productSerial
-	}
-	return boardSerial
+	return serialNumber
 }
 
 func (m *Manager) wakeOnLan(h *hypervisorType) (bool, error) {
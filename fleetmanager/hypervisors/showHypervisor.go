@@ -11,6 +11,7 @@ import (
 	"sort"
 
 	"github.com/Cloud-Foundations/Dominator/fleetmanager/topology"
+	"github.com/Cloud-Foundations/Dominator/lib/bmc"
 	"github.com/Cloud-Foundations/Dominator/lib/constants"
 	"github.com/Cloud-Foundations/Dominator/lib/format"
 	"github.com/Cloud-Foundations/Dominator/lib/html"
@@ -119,8 +120,13 @@ func (m *Manager) showHypervisorHandler(w http.ResponseWriter,
 			format.Duration(time.Since(lastConnectedTime)))
 	}
 	if h.IPMI.Hostname != "" {
-		fmt.Fprintf(writer, "<a href=\"https://%s/\">IPMI</a><br>\n",
-			h.IPMI.Hostname)
+		bmcName := "IPMI"
+		if h.BmcType == bmc.TypeRedfish {
+			bmcName = "Redfish BMC"
+		}
+		fmt.Fprintf(writer,
+			"<a href=\"https://%s/\">%s</a> (<a href=\"showHypervisorInventory?%s\">inventory</a>)<br>\n",
+			h.IPMI.Hostname, bmcName, hostname)
 	}
 	if h.serialNumber != "" {
 		fmt.Fprintf(writer, "Serial Number: %s<br>\n", h.serialNumber)
@@ -3,6 +3,7 @@ package hypervisors
 import (
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/Cloud-Foundations/Dominator/lib/html"
 )
@@ -11,28 +12,33 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 	if err := checkPoolLimits(); err != nil {
 		return nil, err
 	}
+	var ipmiPassword string
 	if startOptions.IpmiPasswordFile != "" {
-		file, err := os.Open(startOptions.IpmiPasswordFile)
+		data, err := os.ReadFile(startOptions.IpmiPasswordFile)
 		if err != nil {
 			return nil, err
 		}
-		file.Close()
+		// Like ipmitool, only use the first line.
+		ipmiPassword = strings.TrimRight(
+			strings.SplitN(string(data), "\n", 2)[0], "\r")
 	}
 	manager := &Manager{
-		ipmiLimiter:      make(chan struct{}, runtime.NumCPU()),
-		ipmiPasswordFile: startOptions.IpmiPasswordFile,
-		ipmiUsername:     startOptions.IpmiUsername,
-		logger:           startOptions.Logger,
-		storer:           startOptions.Storer,
-		allocatingIPs:    make(map[string]struct{}),
-		hypervisors:      make(map[string]*hypervisorType),
-		hypervisorsByHW:  make(map[string]*hypervisorType),
-		hypervisorsByIP:  make(map[string]*hypervisorType),
-		hypervisorsBySN:  make(map[string]*hypervisorType),
-		migratingIPs:     make(map[string]struct{}),
-		subnets:          make(map[string]*subnetType),
-		topologyLoaded:   make(chan struct{}, 1),
-		vms:              make(map[string]*vmInfoType),
+		bmcInsecureSkipVerify: startOptions.BmcInsecureSkipVerify,
+		ipmiLimiter:           make(chan struct{}, runtime.NumCPU()),
+		ipmiPassword:          ipmiPassword,
+		ipmiPasswordFile:      startOptions.IpmiPasswordFile,
+		ipmiUsername:          startOptions.IpmiUsername,
+		logger:                startOptions.Logger,
+		storer:                startOptions.Storer,
+		allocatingIPs:         make(map[string]struct{}),
+		hypervisors:           make(map[string]*hypervisorType),
+		hypervisorsByHW:       make(map[string]*hypervisorType),
+		hypervisorsByIP:       make(map[string]*hypervisorType),
+		hypervisorsBySN:       make(map[string]*hypervisorType),
+		migratingIPs:          make(map[string]struct{}),
+		subnets:               make(map[string]*subnetType),
+		topologyLoaded:        make(chan struct{}, 1),
+		vms:                   make(map[string]*vmInfoType),
 	}
 	manager.topologyLoaded <- struct{}{} // Signal topology not yet loaded.
 	html.HandleFunc("/listHypervisors", manager.listHypervisorsHandler)
@@ -41,6 +47,8 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 	html.HandleFunc("/listVMsByPrimaryOwner",
 		manager.listVMsByPrimaryOwnerHandler)
 	html.HandleFunc("/showHypervisor", manager.showHypervisorHandler)
+	html.HandleFunc("/showHypervisorInventory",
+		manager.showHypervisorInventoryHandler)
 	html.HandleFunc("/showVM", manager.showVmHandler)
 	html.HandleFunc("/tftpdata/config.json", manager.tftpdataConfigHandler)
 	if *manageHypervisors {
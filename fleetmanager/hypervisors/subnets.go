@@ -5,9 +5,39 @@ import (
 	"net"
 
 	"github.com/Cloud-Foundations/Dominator/fleetmanager/topology"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/net/util"
+	hyper_proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
 )
 
+// makeAddress returns the address for a VM with the specified IPv4 address.
+// If the subnet has an IPv6 prefix, the IPv6 address is derived from the MAC
+// address.
+func makeAddress(tSubnet *topology.Subnet, ip net.IP,
+	logger log.Logger) hyper_proto.Address {
+	address := hyper_proto.Address{
+		IpAddress: ip,
+		MacAddress: fmt.Sprintf("52:54:%02x:%02x:%02x:%02x",
+			ip[0], ip[1], ip[2], ip[3]),
+	}
+	if len(tSubnet.Ipv6Gateway) < 1 {
+		return address
+	}
+	macAddr, err := net.ParseMAC(address.MacAddress)
+	if err != nil {
+		logger.Println(err)
+		return address
+	}
+	ipv6Addr, err := util.MakeEui64IP(tSubnet.Ipv6Gateway,
+		net.IPMask(tSubnet.Ipv6Mask), macAddr)
+	if err != nil {
+		logger.Printf("subnet: %s: %s\n", tSubnet.Id, err)
+		return address
+	}
+	address.Ipv6Address = ipv6Addr
+	return address
+}
+
 // This must be called with the lock held.
 func (m *Manager) checkIpReserved(tSubnet *topology.Subnet, ip net.IP) bool {
 	if ip.Equal(tSubnet.IpGateway) {
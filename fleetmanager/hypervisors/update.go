@@ -397,6 +397,7 @@ func (m *Manager) updateTopologyLocked(t *topology.Topology,
 			delete(m.hypervisorsBySN, hypervisor.serialNumber)
 		}
 		hypersToDelete = append(hypersToDelete, hypervisor)
+		hypervisor.closeBmcDriver()
 		for vmIP := range hypervisor.migratingVms {
 			delete(m.vms, vmIP)
 		}
@@ -674,11 +675,8 @@ func (m *Manager) processAddressPoolUpdates(h *hypervisorType,
 			}
 			for _, ip := range freeIPs {
 				ipsToAdd = append(ipsToAdd, ip)
-				addressesToAdd = append(addressesToAdd, hyper_proto.Address{
-					IpAddress: ip,
-					MacAddress: fmt.Sprintf("52:54:%02x:%02x:%02x:%02x",
-						ip[0], ip[1], ip[2], ip[3]),
-				})
+				addressesToAdd = append(addressesToAdd,
+					makeAddress(tSubnet, ip, h.logger))
 			}
 			h.logger.Debugf(0, "Adding %d addresses to subnet: %s\n",
 				len(freeIPs), subnetId)
@@ -60,6 +60,16 @@ func Setup(config Config, params Params) (
 				"ListHypervisorsInLocation",
 				"ListVMsInLocation",
 				"PowerOnMachine",
+			},
+			ReadOnlyMethods: []string{
+				"GetHypervisorForVM",
+				"GetHypervisorsInLocation",
+				"GetIpInfo",
+				"GetMachineInfo",
+				"GetUpdates",
+				"ListHypervisorLocations",
+				"ListHypervisorsInLocation",
+				"ListVMsInLocation",
 			}})
 	return (*htmlWriter)(srpcObj), nil
 }
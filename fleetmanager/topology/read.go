@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"sort"
 
+	"github.com/Cloud-Foundations/Dominator/lib/bmc"
 	"github.com/Cloud-Foundations/Dominator/lib/expand"
 	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
 	"github.com/Cloud-Foundations/Dominator/lib/json"
@@ -83,6 +84,11 @@ func loadInstallConfig(filename string) (*InstallConfig, error) {
 		}
 		return nil, fmt.Errorf("error reading: %s: %s", filename, err)
 	}
+	if installConfig.StorageLayout != nil {
+		if err := installConfig.StorageLayout.Check(); err != nil {
+			return nil, fmt.Errorf("error checking: %s: %s", filename, err)
+		}
+	}
 	return &installConfig, nil
 }
 
@@ -151,6 +157,16 @@ func loadSubnets(filename string) ([]*Subnet, error) {
 		} else {
 			gatewayIPs[gatewayIp] = struct{}{}
 		}
+		if len(subnet.Ipv6Gateway) > 0 {
+			if subnet.Ipv6Gateway.To4() != nil {
+				return nil, fmt.Errorf("subnet: %s: Ipv6Gateway: %s not IPv6",
+					subnet.Id, subnet.Ipv6Gateway)
+			}
+			if _, bits := net.IPMask(subnet.Ipv6Mask).Size(); bits != 128 {
+				return nil, fmt.Errorf("subnet: %s: bad Ipv6Mask: %s",
+					subnet.Id, subnet.Ipv6Mask)
+			}
+		}
 		subnet.reservedIpAddrs = make(map[string]struct{})
 		for _, ipAddr := range subnet.ReservedIPs {
 			subnet.reservedIpAddrs[ipAddr.String()] = struct{}{}
@@ -211,6 +227,11 @@ func (cState *commonStateType) addMacAddress(
 
 func (cState *commonStateType) addMachine(machine *proto.Machine,
 	subnetIds map[string]struct{}) error {
+	switch machine.BmcType {
+	case "", bmc.TypeIpmi, bmc.TypeRedfish:
+	default:
+		return fmt.Errorf("unsupported BmcType: %s", machine.BmcType)
+	}
 	if machine.GatewaySubnetId != "" {
 		if _, ok := subnetIds[machine.GatewaySubnetId]; !ok {
 			return fmt.Errorf("unknown gateway subnetId: %s",
@@ -254,6 +275,11 @@ func (cState *commonStateType) addNetworkEntry(entry proto.NetworkEntry,
 				"cannot specify SubnetId(%s) and HostIpAddress(%s) together",
 				entry.SubnetId, entry.HostIpAddress)
 		}
+		if len(entry.HostIpv6Address) > 0 {
+			return fmt.Errorf(
+				"cannot specify SubnetId(%s) and HostIpv6Address(%s) together",
+				entry.SubnetId, entry.HostIpv6Address)
+		}
 	}
 	if err := cState.addHostname(entry.Hostname); err != nil {
 		return err
@@ -261,6 +287,9 @@ func (cState *commonStateType) addNetworkEntry(entry proto.NetworkEntry,
 	if err := cState.addIpAddress(entry.HostIpAddress); err != nil {
 		return err
 	}
+	if err := cState.addIpAddress(entry.HostIpv6Address); err != nil {
+		return err
+	}
 	if err := cState.addMacAddress(entry.HostMacAddress); err != nil {
 		return err
 	}
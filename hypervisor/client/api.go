@@ -7,7 +7,9 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
 	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/tags"
 	proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
@@ -33,6 +35,11 @@ func AddVmVolumes(client srpc.ClientI, ipAddress net.IP, sizes []uint64) error {
 	return addVmVolumes(client, ipAddress, sizes)
 }
 
+func BackupVm(client srpc.ClientI, request proto.BackupVmRequest) (
+	hash.Hash, error) {
+	return backupVm(client, request)
+}
+
 func BecomePrimaryVmOwner(client srpc.ClientI, ipAddress net.IP) error {
 	return becomePrimaryVmOwner(client, ipAddress)
 }
@@ -312,6 +319,12 @@ func ProcessCreateVmResponses(conn *srpc.Conn,
 	return processCreateVmResponses(conn, logger)
 }
 
+// ReadVmBackupManifest reads the VM backup manifest with the specified hash.
+func ReadVmBackupManifest(objectGetter objectserver.ObjectGetter,
+	manifestHash hash.Hash) (proto.VmBackupManifest, error) {
+	return readVmBackupManifest(objectGetter, manifestHash)
+}
+
 func RebootVm(client srpc.ClientI, ipAddress net.IP,
 	dhcpTimeout time.Duration) (bool, error) {
 	return rebootVm(client, ipAddress, dhcpTimeout)
@@ -398,3 +411,11 @@ func WatchDhcp(client srpc.ClientI, request proto.WatchDhcpRequest,
 	handlePacket func(ifName string, rawPacket []byte) error) error {
 	return watchDhcp(client, request, handlePacket)
 }
+
+// WriteVmBackupVolume writes the data for the specified volume in a VM backup,
+// fetching the chunks from objectsGetter.
+func WriteVmBackupVolume(writer io.Writer,
+	objectsGetter objectserver.ObjectsGetter,
+	manifest proto.VmBackupManifest, volumeIndex uint) error {
+	return writeVmBackupVolume(writer, objectsGetter, manifest, volumeIndex)
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -15,7 +16,9 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
 	"github.com/Cloud-Foundations/Dominator/lib/filter"
 	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
 	"github.com/Cloud-Foundations/Dominator/lib/rsync"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/tags"
@@ -41,6 +44,16 @@ func addVmVolumes(client srpc.ClientI, ipAddress net.IP, sizes []uint64) error {
 	return errors.New(reply.Error)
 }
 
+func backupVm(client srpc.ClientI, request proto.BackupVmRequest) (
+	hash.Hash, error) {
+	var reply proto.BackupVmResponse
+	err := client.RequestReply("Hypervisor.BackupVm", request, &reply)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return reply.ManifestHash, errors.New(reply.Error)
+}
+
 func becomePrimaryVmOwner(client srpc.ClientI, ipAddress net.IP) error {
 	request := proto.BecomePrimaryVmOwnerRequest{ipAddress}
 	var reply proto.BecomePrimaryVmOwnerResponse
@@ -1071,6 +1084,23 @@ func processCreateVmResponses(conn *srpc.Conn,
 	}
 }
 
+func readVmBackupManifest(objectGetter objectserver.ObjectGetter,
+	manifestHash hash.Hash) (proto.VmBackupManifest, error) {
+	var manifest proto.VmBackupManifest
+	_, reader, err := objectGetter.GetObject(manifestHash)
+	if err != nil {
+		return manifest, err
+	}
+	defer reader.Close()
+	if err := json.NewDecoder(reader).Decode(&manifest); err != nil {
+		return manifest, err
+	}
+	if manifest.ChunkSize < 1 {
+		return manifest, errors.New("bad chunk size in backup manifest")
+	}
+	return manifest, nil
+}
+
 func rebootVm(client srpc.ClientI, ipAddress net.IP,
 	dhcpTimeout time.Duration) (bool, error) {
 	request := proto.RebootVmRequest{
@@ -1383,3 +1413,87 @@ func watchDhcp(client srpc.ClientI, request proto.WatchDhcpRequest,
 	}
 	return nil
 }
+
+// writeVmBackupChunks writes the chunks, reading the non-zero chunks from
+// objectsReader.
+func writeVmBackupChunks(writer io.Writer, chunks []hash.Hash,
+	objectsReader objectserver.ObjectsReader, chunkSize uint64,
+	zeroChunk []byte, remaining *uint64) error {
+	for _, hashVal := range chunks {
+		length := chunkSize
+		if length > *remaining {
+			length = *remaining
+		}
+		if hashVal == (hash.Hash{}) {
+			if _, err := writer.Write(zeroChunk[:length]); err != nil {
+				return err
+			}
+		} else {
+			size, reader, err := objectsReader.NextObject()
+			if err != nil {
+				return err
+			}
+			if size != length {
+				reader.Close()
+				return fmt.Errorf("chunk: %x size: %d, expected: %d",
+					hashVal, size, length)
+			}
+			_, err = io.CopyN(writer, reader, int64(length))
+			reader.Close()
+			if err != nil {
+				return err
+			}
+		}
+		*remaining -= length
+	}
+	return nil
+}
+
+// writeVmBackupVolume writes the volume data, fetching chunks in batches. Zero
+// chunks are not stored in the object server, so they are generated locally.
+func writeVmBackupVolume(writer io.Writer,
+	objectsGetter objectserver.ObjectsGetter,
+	manifest proto.VmBackupManifest, volumeIndex uint) error {
+	const batchSize = 16
+	if volumeIndex >= uint(len(manifest.Volumes)) {
+		return fmt.Errorf("volume index: %d not in backup manifest",
+			volumeIndex)
+	}
+	volume := manifest.Volumes[volumeIndex]
+	if uint64(len(volume.Chunks))*manifest.ChunkSize < volume.Size {
+		return fmt.Errorf("backup manifest missing %d bytes",
+			volume.Size-uint64(len(volume.Chunks))*manifest.ChunkSize)
+	}
+	zeroChunk := make([]byte, manifest.ChunkSize)
+	remaining := volume.Size
+	for chunks := volume.Chunks; len(chunks) > 0; {
+		batch := chunks
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		chunks = chunks[len(batch):]
+		hashes := make([]hash.Hash, 0, len(batch))
+		for _, hashVal := range batch {
+			if hashVal != (hash.Hash{}) {
+				hashes = append(hashes, hashVal)
+			}
+		}
+		var objectsReader objectserver.ObjectsReader
+		if len(hashes) > 0 {
+			var err error
+			objectsReader, err = objectsGetter.GetObjects(hashes)
+			if err != nil {
+				return err
+			}
+		}
+		err := writeVmBackupChunks(writer, batch, objectsReader,
+			manifest.ChunkSize, zeroChunk, &remaining)
+		if objectsReader != nil {
+			objectsReader.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
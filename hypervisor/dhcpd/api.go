@@ -13,9 +13,13 @@ import (
 
 type DhcpServer struct {
 	dynamicLeasesFile string
+	httpBootImage     string
+	httpPortNum       uint
+	ipxeScript        string
 	logger            log.DebugLogger
 	cleanupTrigger    chan<- struct{}
 	interfaceIPs      map[string][]net.IP // Key: interface name.
+	interfaceIpv6s    map[string][]net.IP // Key: interface name.
 	myIPs             []net.IP
 	networkBootImage  string
 	requestInterface  string
@@ -24,6 +28,7 @@ type DhcpServer struct {
 	ackChannels       map[string]chan struct{}    // Key: IPaddr.
 	dynamicLeases     map[string]*leaseType       // Key: MACaddr.
 	interfaceSubnets  map[string][]*subnetType    // Key: interface name.
+	ipv6Subnets       map[string][]*subnetType    // Key: interface name.
 	ipAddrToMacAddr   map[string]string           // Key: IPaddr, V: MACaddr.
 	packetWatchers    map[<-chan proto.WatchDhcpResponse]chan<- proto.WatchDhcpResponse
 	requestChannels   map[string]chan net.IP // Key: MACaddr.
@@ -90,6 +95,26 @@ func (s *DhcpServer) RemoveSubnet(subnetId string) {
 	s.removeSubnet(subnetId)
 }
 
+// SetHttpBootImage sets the name of the boot image given to UEFI HTTP Boot
+// clients. The name may contain a %d which is replaced with the client
+// architecture.
+func (s *DhcpServer) SetHttpBootImage(name string) error {
+	s.httpBootImage = name
+	return nil
+}
+
+// SetHttpPortNum sets the port number of the HTTP server which serves the
+// network boot files. If 0, only TFTP is offered to network booting clients.
+func (s *DhcpServer) SetHttpPortNum(portNum uint) {
+	s.httpPortNum = portNum
+}
+
+// SetIpxeScript sets the name of the script given to iPXE clients.
+func (s *DhcpServer) SetIpxeScript(name string) error {
+	s.ipxeScript = name
+	return nil
+}
+
 func (s *DhcpServer) SetNetworkBootImage(nbiName string) error {
 	s.networkBootImage = nbiName
 	return nil
@@ -0,0 +1,446 @@
+package dhcpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/net/util"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	dhcp6ClientPort = 546
+	dhcp6ServerPort = 547
+
+	dhcp6MsgSolicit            = 1
+	dhcp6MsgAdvertise          = 2
+	dhcp6MsgRequest            = 3
+	dhcp6MsgConfirm            = 4
+	dhcp6MsgRenew              = 5
+	dhcp6MsgRebind             = 6
+	dhcp6MsgReply              = 7
+	dhcp6MsgRelease            = 8
+	dhcp6MsgDecline            = 9
+	dhcp6MsgInformationRequest = 11
+
+	dhcp6OptionClientId    = 1
+	dhcp6OptionServerId    = 2
+	dhcp6OptionIaNa        = 3
+	dhcp6OptionIaAddr      = 5
+	dhcp6OptionPreference  = 7
+	dhcp6OptionStatusCode  = 13
+	dhcp6OptionRapidCommit = 14
+	dhcp6OptionDnsServers  = 23
+	dhcp6OptionDomainList  = 24
+
+	dhcp6StatusSuccess      = 0
+	dhcp6StatusNoAddrsAvail = 2
+	dhcp6StatusNoBinding    = 3
+	dhcp6StatusNotOnLink    = 4
+
+	duidTypeLinkLayerPlusTime = 1
+	duidTypeLinkLayer         = 3
+	hardwareTypeEthernet      = 1
+)
+
+var allDhcpRelayAgentsAndServers = net.ParseIP("ff02::1:2")
+
+type dhcp6Option struct {
+	code uint16
+	data []byte
+}
+
+type dhcp6Packet struct {
+	msgType       byte
+	transactionId [3]byte
+	options       []dhcp6Option
+}
+
+// encodeDomainList encodes domain names in DNS wire format (RFC 1035).
+func encodeDomainList(domains ...string) []byte {
+	buffer := &bytes.Buffer{}
+	for _, domain := range domains {
+		for _, label := range strings.Split(strings.Trim(domain, "."), ".") {
+			if label == "" || len(label) > 63 {
+				continue
+			}
+			buffer.WriteByte(byte(len(label)))
+			buffer.WriteString(label)
+		}
+		buffer.WriteByte(0)
+	}
+	return buffer.Bytes()
+}
+
+// getMacFromDuid returns the MAC address in a DUID-LLT or DUID-LL, else nil.
+func getMacFromDuid(duid []byte) net.HardwareAddr {
+	if len(duid) < 4 {
+		return nil
+	}
+	if binary.BigEndian.Uint16(duid[2:4]) != hardwareTypeEthernet {
+		return nil
+	}
+	var lladdr []byte
+	switch binary.BigEndian.Uint16(duid[0:2]) {
+	case duidTypeLinkLayerPlusTime:
+		if len(duid) < 8 {
+			return nil
+		}
+		lladdr = duid[8:]
+	case duidTypeLinkLayer:
+		lladdr = duid[4:]
+	default:
+		return nil
+	}
+	if len(lladdr) != 6 {
+		return nil
+	}
+	return net.HardwareAddr(lladdr)
+}
+
+func makeDuid(macAddr net.HardwareAddr) []byte {
+	duid := make([]byte, 4, 4+len(macAddr))
+	binary.BigEndian.PutUint16(duid[0:2], duidTypeLinkLayer)
+	binary.BigEndian.PutUint16(duid[2:4], hardwareTypeEthernet)
+	return append(duid, macAddr...)
+}
+
+func makeIaNa(iaid []byte, ipAddr net.IP, lifetime uint32) []byte {
+	data := make([]byte, 12, 12+4+24)
+	copy(data[0:4], iaid)
+	binary.BigEndian.PutUint32(data[4:8], lifetime/2)
+	binary.BigEndian.PutUint32(data[8:12], lifetime/5*4)
+	iaAddr := make([]byte, 24)
+	copy(iaAddr[0:16], ipAddr.To16())
+	binary.BigEndian.PutUint32(iaAddr[16:20], lifetime)
+	binary.BigEndian.PutUint32(iaAddr[20:24], lifetime)
+	return appendDhcp6Option(data, dhcp6OptionIaAddr, iaAddr)
+}
+
+func makeIaNaStatus(iaid []byte, statusCode uint16, message string) []byte {
+	data := make([]byte, 12, 12+4+2+len(message))
+	copy(data[0:4], iaid)
+	return appendDhcp6Option(data, dhcp6OptionStatusCode,
+		makeStatus(statusCode, message))
+}
+
+func makeStatus(statusCode uint16, message string) []byte {
+	data := make([]byte, 2, 2+len(message))
+	binary.BigEndian.PutUint16(data, statusCode)
+	return append(data, message...)
+}
+
+func appendDhcp6Option(data []byte, code uint16, value []byte) []byte {
+	var header [4]byte
+	binary.BigEndian.PutUint16(header[0:2], code)
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(value)))
+	data = append(data, header[:]...)
+	return append(data, value...)
+}
+
+func parseDhcp6Options(data []byte) ([]dhcp6Option, error) {
+	var options []dhcp6Option
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, errors.New("truncated option header")
+		}
+		code := binary.BigEndian.Uint16(data[0:2])
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if len(data) < 4+length {
+			return nil, errors.New("truncated option")
+		}
+		options = append(options, dhcp6Option{code, data[4 : 4+length]})
+		data = data[4+length:]
+	}
+	return options, nil
+}
+
+func parseDhcp6Packet(data []byte) (*dhcp6Packet, error) {
+	if len(data) < 4 {
+		return nil, errors.New("short DHCPv6 packet")
+	}
+	packet := &dhcp6Packet{msgType: data[0]}
+	copy(packet.transactionId[:], data[1:4])
+	options, err := parseDhcp6Options(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	packet.options = options
+	return packet, nil
+}
+
+func (p *dhcp6Packet) addOption(code uint16, data []byte) {
+	p.options = append(p.options, dhcp6Option{code, data})
+}
+
+func (p *dhcp6Packet) getOption(code uint16) []byte {
+	for _, option := range p.options {
+		if option.code == code {
+			return option.data
+		}
+	}
+	return nil
+}
+
+// hasOption returns true if the packet has the option, which may be empty.
+func (p *dhcp6Packet) hasOption(code uint16) bool {
+	for _, option := range p.options {
+		if option.code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *dhcp6Packet) marshal() []byte {
+	data := make([]byte, 4, 512)
+	data[0] = p.msgType
+	copy(data[1:4], p.transactionId[:])
+	for _, option := range p.options {
+		data = appendDhcp6Option(data, option.code, option.data)
+	}
+	return data
+}
+
+// getRequestedIaid returns the IAID of the first IA_NA in the request, or nil.
+func (p *dhcp6Packet) getRequestedIaid() []byte {
+	if iaNa := p.getOption(dhcp6OptionIaNa); len(iaNa) >= 12 {
+		return iaNa[0:4]
+	}
+	return nil
+}
+
+// getRequestedAddresses returns the addresses in the IA_NA options.
+func (p *dhcp6Packet) getRequestedAddresses() []net.IP {
+	var addresses []net.IP
+	for _, option := range p.options {
+		if option.code != dhcp6OptionIaNa || len(option.data) < 12 {
+			continue
+		}
+		subOptions, err := parseDhcp6Options(option.data[12:])
+		if err != nil {
+			continue
+		}
+		for _, subOption := range subOptions {
+			if subOption.code == dhcp6OptionIaAddr &&
+				len(subOption.data) >= 24 {
+				addresses = append(addresses, net.IP(subOption.data[0:16]))
+			}
+		}
+	}
+	return addresses
+}
+
+func (s *DhcpServer) startDhcp6(ifIndices map[int]string) error {
+	listener, err := net.ListenPacket("udp6",
+		fmt.Sprintf(":%d", dhcp6ServerPort))
+	if err != nil {
+		return err
+	}
+	pktConn := ipv6.NewPacketConn(listener)
+	if err := pktConn.SetControlMessage(ipv6.FlagInterface, true); err != nil {
+		listener.Close()
+		return err
+	}
+	group := &net.UDPAddr{IP: allDhcpRelayAgentsAndServers}
+	for ifIndex, name := range ifIndices {
+		iface, err := net.InterfaceByIndex(ifIndex)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		if err := pktConn.JoinGroup(iface, group); err != nil {
+			s.logger.Printf("unable to join DHCPv6 group on: %s: %s\n",
+				name, err)
+		}
+	}
+	go s.serveDhcp6(pktConn, ifIndices)
+	return nil
+}
+
+func (s *DhcpServer) serveDhcp6(conn *ipv6.PacketConn,
+	ifIndices map[int]string) {
+	buffer := make([]byte, 1500)
+	for {
+		length, cm, srcAddr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			s.logger.Println(err)
+			return
+		}
+		if cm == nil {
+			continue
+		}
+		ifName, ok := ifIndices[cm.IfIndex]
+		if !ok {
+			continue
+		}
+		udpAddr, ok := srcAddr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		request, err := parseDhcp6Packet(buffer[:length])
+		if err != nil {
+			s.logger.Debugf(0, "DHCPv6 from: %s on: %s: %s\n",
+				udpAddr.IP, ifName, err)
+			continue
+		}
+		iface, err := net.InterfaceByIndex(cm.IfIndex)
+		if err != nil {
+			s.logger.Println(err)
+			continue
+		}
+		reply := s.handleDhcp6(request, udpAddr.IP, iface)
+		if reply == nil {
+			continue
+		}
+		_, err = conn.WriteTo(reply.marshal(),
+			&ipv6.ControlMessage{IfIndex: cm.IfIndex},
+			&net.UDPAddr{IP: udpAddr.IP, Port: dhcp6ClientPort})
+		if err != nil {
+			s.logger.Printf("error sending DHCPv6 reply on: %s: %s\n",
+				ifName, err)
+		}
+	}
+}
+
+func (s *DhcpServer) handleDhcp6(request *dhcp6Packet, srcIP net.IP,
+	iface *net.Interface) *dhcp6Packet {
+	serverId := makeDuid(iface.HardwareAddr)
+	if id := request.getOption(dhcp6OptionServerId); id != nil {
+		if !bytes.Equal(id, serverId) {
+			return nil // Message not for this DHCP server.
+		}
+	} else {
+		switch request.msgType {
+		case dhcp6MsgRequest, dhcp6MsgRenew, dhcp6MsgRelease,
+			dhcp6MsgDecline:
+			return nil
+		}
+	}
+	clientId := request.getOption(dhcp6OptionClientId)
+	if clientId == nil && request.msgType != dhcp6MsgInformationRequest {
+		return nil
+	}
+	macAddr := getMacFromDuid(clientId)
+	if macAddr == nil {
+		macAddr, _ = util.GetMacFromEui64IP(srcIP)
+	}
+	var lease *leaseType
+	var subnet *subnetType
+	s.mutex.RLock()
+	if macAddr != nil {
+		if staticLease, ok := s.staticLeases[macAddr.String()]; ok {
+			if len(staticLease.Ipv6Address) > 0 {
+				lease = &staticLease
+				subnet = staticLease.subnet
+				if subnet == nil {
+					subnet = s.findMatchingSubnet(staticLease.IpAddress)
+				}
+			}
+		}
+	}
+	if subnet == nil {
+		if subnets := s.ipv6Subnets[iface.Name]; len(subnets) > 0 {
+			subnet = subnets[0]
+		}
+	}
+	s.mutex.RUnlock()
+	reply := &dhcp6Packet{
+		msgType:       dhcp6MsgReply,
+		transactionId: request.transactionId,
+	}
+	reply.addOption(dhcp6OptionServerId, serverId)
+	if clientId != nil {
+		reply.addOption(dhcp6OptionClientId, clientId)
+	}
+	lifetime := uint32(staticLeaseTime.Seconds())
+	iaid := request.getRequestedIaid()
+	switch request.msgType {
+	case dhcp6MsgSolicit:
+		if lease == nil || iaid == nil {
+			return nil
+		}
+		s.logger.Debugf(0, "DHCPv6 Solicit from: %s on: %s, offering: %s\n",
+			macAddr, iface.Name, lease.Ipv6Address)
+		if request.hasOption(dhcp6OptionRapidCommit) {
+			reply.addOption(dhcp6OptionRapidCommit, nil)
+		} else {
+			reply.msgType = dhcp6MsgAdvertise
+			reply.addOption(dhcp6OptionPreference, []byte{255})
+		}
+		reply.addOption(dhcp6OptionIaNa,
+			makeIaNa(iaid, lease.Ipv6Address, lifetime))
+	case dhcp6MsgRequest, dhcp6MsgRenew, dhcp6MsgRebind:
+		if iaid == nil {
+			return nil
+		}
+		if lease == nil {
+			if request.msgType == dhcp6MsgRebind {
+				return nil
+			}
+			var statusCode uint16 = dhcp6StatusNoAddrsAvail
+			if request.msgType == dhcp6MsgRenew {
+				statusCode = dhcp6StatusNoBinding
+			}
+			reply.addOption(dhcp6OptionIaNa,
+				makeIaNaStatus(iaid, statusCode, "no lease"))
+			break
+		}
+		s.logger.Debugf(0, "DHCPv6 Reply for: %s to: %s on: %s\n",
+			lease.Ipv6Address, macAddr, iface.Name)
+		reply.addOption(dhcp6OptionIaNa,
+			makeIaNa(iaid, lease.Ipv6Address, lifetime))
+	case dhcp6MsgConfirm:
+		if lease == nil {
+			return nil
+		}
+		statusCode := uint16(dhcp6StatusSuccess)
+		for _, ipAddr := range request.getRequestedAddresses() {
+			if !ipAddr.Equal(lease.Ipv6Address) {
+				statusCode = dhcp6StatusNotOnLink
+			}
+		}
+		reply.addOption(dhcp6OptionStatusCode, makeStatus(statusCode, ""))
+	case dhcp6MsgRelease, dhcp6MsgDecline:
+		if request.msgType == dhcp6MsgDecline {
+			s.logger.Printf("DHCPv6 Decline from: %s on: %s\n",
+				macAddr, iface.Name)
+		}
+		reply.addOption(dhcp6OptionStatusCode,
+			makeStatus(dhcp6StatusSuccess, ""))
+		return reply
+	case dhcp6MsgInformationRequest:
+		if subnet == nil {
+			return nil
+		}
+	default:
+		s.logger.Debugf(0, "Unsupported DHCPv6 message type: %d on: %s\n",
+			request.msgType, iface.Name)
+		return nil
+	}
+	if subnet != nil {
+		s.addDhcp6SubnetOptions(reply, subnet)
+	}
+	return reply
+}
+
+func (s *DhcpServer) addDhcp6SubnetOptions(reply *dhcp6Packet,
+	subnet *subnetType) {
+	var dnsServers []byte
+	for _, dnsServer := range subnet.DomainNameServers {
+		if dnsServer.To4() == nil {
+			dnsServers = append(dnsServers, dnsServer.To16()...)
+		}
+	}
+	if len(dnsServers) > 0 {
+		reply.addOption(dhcp6OptionDnsServers, dnsServers)
+	}
+	if subnet.DomainName != "" {
+		reply.addOption(dhcp6OptionDomainList,
+			encodeDomainList(subnet.DomainName))
+	}
+}
@@ -0,0 +1,333 @@
+package dhcpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+var (
+	testClientMac = net.HardwareAddr{0x52, 0x54, 0, 0x12, 0x34, 0x56}
+	testClientIP  = net.ParseIP("2001:db8::5054:ff:fe12:3456")
+	testIaid      = []byte{0, 0, 0, 1}
+	testServerMac = net.HardwareAddr{0x52, 0x54, 0, 0xaa, 0xbb, 0xcc}
+)
+
+// getReplyAddress returns the address in the IA_NA of the reply, or nil.
+func getReplyAddress(t *testing.T, reply *dhcp6Packet) net.IP {
+	iaNa := reply.getOption(dhcp6OptionIaNa)
+	if len(iaNa) < 12 {
+		t.Fatal("no IA_NA in reply")
+	}
+	if !bytes.Equal(iaNa[0:4], testIaid) {
+		t.Errorf("IAID: %v != %v", iaNa[0:4], testIaid)
+	}
+	subOptions, err := parseDhcp6Options(iaNa[12:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, option := range subOptions {
+		if option.code == dhcp6OptionIaAddr {
+			return net.IP(option.data[0:16])
+		}
+	}
+	return nil
+}
+
+func TestDhcp6PacketRoundTrip(t *testing.T) {
+	packet := &dhcp6Packet{
+		msgType:       dhcp6MsgSolicit,
+		transactionId: [3]byte{1, 2, 3},
+		options: []dhcp6Option{
+			{dhcp6OptionClientId, makeDuid(testClientMac)},
+			{dhcp6OptionRapidCommit, []byte{}},
+			{dhcp6OptionIaNa, makeIaNa(testIaid, testClientIP, 3600)},
+		},
+	}
+	data := packet.marshal()
+	if data[0] != dhcp6MsgSolicit || !bytes.Equal(data[1:4], []byte{1, 2, 3}) {
+		t.Fatalf("bad header: %v", data[0:4])
+	}
+	parsed, err := parseDhcp6Packet(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, packet) {
+		t.Errorf("%+v != %+v", *parsed, *packet)
+	}
+	if !bytes.Equal(parsed.getRequestedIaid(), testIaid) {
+		t.Errorf("IAID: %v != %v", parsed.getRequestedIaid(), testIaid)
+	}
+	addresses := parsed.getRequestedAddresses()
+	if len(addresses) != 1 || !addresses[0].Equal(testClientIP) {
+		t.Errorf("requested addresses: %v", addresses)
+	}
+	for _, length := range []int{0, 3, 5, len(data) - 1} {
+		if _, err := parseDhcp6Packet(data[:length]); err == nil {
+			t.Errorf("no error parsing truncated packet of length: %d",
+				length)
+		}
+	}
+}
+
+func TestMakeIaNa(t *testing.T) {
+	data := makeIaNa(testIaid, testClientIP, 1000)
+	if len(data) != 12+4+24 {
+		t.Fatalf("IA_NA length: %d != 40", len(data))
+	}
+	if t1 := binary.BigEndian.Uint32(data[4:8]); t1 != 500 {
+		t.Errorf("T1: %d != 500", t1)
+	}
+	if t2 := binary.BigEndian.Uint32(data[8:12]); t2 != 800 {
+		t.Errorf("T2: %d != 800", t2)
+	}
+	if code := binary.BigEndian.Uint16(data[12:14]); code !=
+		dhcp6OptionIaAddr {
+		t.Errorf("sub-option: %d != %d", code, dhcp6OptionIaAddr)
+	}
+	if ip := net.IP(data[16:32]); !ip.Equal(testClientIP) {
+		t.Errorf("address: %s != %s", ip, testClientIP)
+	}
+	status := makeIaNaStatus(testIaid, dhcp6StatusNoBinding, "no lease")
+	options, err := parseDhcp6Options(status[12:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 1 || options[0].code != dhcp6OptionStatusCode {
+		t.Fatalf("bad status options: %v", options)
+	}
+	if code := binary.BigEndian.Uint16(options[0].data); code !=
+		dhcp6StatusNoBinding {
+		t.Errorf("status: %d != %d", code, dhcp6StatusNoBinding)
+	}
+	if message := string(options[0].data[2:]); message != "no lease" {
+		t.Errorf("message: %s", message)
+	}
+}
+
+func TestDuid(t *testing.T) {
+	if macAddr := getMacFromDuid(makeDuid(testClientMac)); !bytes.Equal(
+		macAddr, testClientMac) {
+		t.Errorf("DUID-LL: %s != %s", macAddr, testClientMac)
+	}
+	duidLlt := []byte{0, duidTypeLinkLayerPlusTime, 0, hardwareTypeEthernet,
+		1, 2, 3, 4}
+	duidLlt = append(duidLlt, testClientMac...)
+	if macAddr := getMacFromDuid(duidLlt); !bytes.Equal(macAddr,
+		testClientMac) {
+		t.Errorf("DUID-LLT: %s != %s", macAddr, testClientMac)
+	}
+	for _, duid := range [][]byte{
+		nil,
+		{0, duidTypeLinkLayer},
+		{0, duidTypeLinkLayer, 0, 6, 1, 2, 3, 4, 5, 6}, // Not Ethernet.
+		{0, duidTypeLinkLayer, 0, hardwareTypeEthernet, 1, 2, 3},
+		{0, 2, 0, 0, 0, 9, 1, 2, 3, 4, 5, 6}, // DUID-EN.
+		{0, duidTypeLinkLayerPlusTime, 0, hardwareTypeEthernet, 1, 2},
+	} {
+		if macAddr := getMacFromDuid(duid); macAddr != nil {
+			t.Errorf("%v: got: %s", duid, macAddr)
+		}
+	}
+}
+
+func TestEncodeDomainList(t *testing.T) {
+	expected := []byte("\x07example\x03com\x00\x03lab\x00")
+	if data := encodeDomainList("example.com.", "lab"); !bytes.Equal(data,
+		expected) {
+		t.Errorf("%q != %q", data, expected)
+	}
+}
+
+func TestHandleDhcp6(t *testing.T) {
+	subnet := &subnetType{
+		Subnet: proto.Subnet{
+			Id:        "dual",
+			IpGateway: net.ParseIP("10.0.0.1").To4(),
+			IpMask:    net.IP(net.CIDRMask(24, 32)),
+			DomainNameServers: []net.IP{
+				net.ParseIP("10.0.0.53").To4(),
+				net.ParseIP("2001:db8::53"),
+			},
+			DomainName:  "example.com",
+			Ipv6Gateway: net.ParseIP("2001:db8::1"),
+			Ipv6Mask:    net.IP(net.CIDRMask(64, 128)),
+		},
+	}
+	server := &DhcpServer{
+		logger: testlogger.New(t),
+		ipv6Subnets: map[string][]*subnetType{
+			"br0": {subnet},
+		},
+		staticLeases: map[string]leaseType{
+			testClientMac.String(): {
+				Address: proto.Address{
+					IpAddress:   net.ParseIP("10.0.0.2").To4(),
+					Ipv6Address: testClientIP,
+					MacAddress:  testClientMac.String(),
+				},
+				subnet: subnet,
+			},
+		},
+	}
+	iface := &net.Interface{Name: "br0", HardwareAddr: testServerMac}
+	// Solicit.
+	clientId := makeDuid(testClientMac)
+	request := &dhcp6Packet{
+		msgType:       dhcp6MsgSolicit,
+		transactionId: [3]byte{1, 2, 3},
+		options: []dhcp6Option{
+			{dhcp6OptionClientId, clientId},
+			{dhcp6OptionIaNa, makeIaNaStatus(testIaid, 0, "")},
+		},
+	}
+	reply := server.handleDhcp6(request, net.ParseIP("fe80::1"), iface)
+	if reply == nil {
+		t.Fatal("Solicit: no reply")
+	}
+	if reply.msgType != dhcp6MsgAdvertise {
+		t.Errorf("message type: %d != %d", reply.msgType, dhcp6MsgAdvertise)
+	}
+	if reply.transactionId != request.transactionId {
+		t.Error("transaction ID not copied")
+	}
+	if !bytes.Equal(reply.getOption(dhcp6OptionClientId), clientId) {
+		t.Error("client ID not copied")
+	}
+	if !bytes.Equal(reply.getOption(dhcp6OptionServerId),
+		makeDuid(testServerMac)) {
+		t.Error("bad server ID")
+	}
+	if ip := getReplyAddress(t, reply); !ip.Equal(testClientIP) {
+		t.Errorf("address: %s != %s", ip, testClientIP)
+	}
+	if dnsServers := reply.getOption(dhcp6OptionDnsServers); !bytes.Equal(
+		dnsServers, net.ParseIP("2001:db8::53")) {
+		t.Errorf("DNS servers: %v", dnsServers)
+	}
+	if domains := reply.getOption(dhcp6OptionDomainList); !bytes.Equal(
+		domains, encodeDomainList("example.com")) {
+		t.Errorf("domain list: %q", domains)
+	}
+	// With Rapid Commit, a Reply is sent immediately.
+	request.addOption(dhcp6OptionRapidCommit, nil)
+	reply = server.handleDhcp6(request, net.ParseIP("fe80::1"), iface)
+	if reply == nil {
+		t.Fatal("Solicit with Rapid Commit: no reply")
+	}
+	if reply.msgType != dhcp6MsgReply {
+		t.Errorf("message type: %d != %d", reply.msgType, dhcp6MsgReply)
+	}
+	if !reply.hasOption(dhcp6OptionRapidCommit) {
+		t.Error("Rapid Commit not acknowledged")
+	}
+	// Solicit from an unknown client.
+	unknownClientId := makeDuid(net.HardwareAddr{2, 0, 0, 0, 0, 1})
+	request.options = []dhcp6Option{
+		{dhcp6OptionClientId, unknownClientId},
+		{dhcp6OptionIaNa, makeIaNaStatus(testIaid, 0, "")},
+	}
+	if server.handleDhcp6(request, net.ParseIP("fe80::2"), iface) != nil {
+		t.Error("replied to unknown client")
+	}
+	// Request from a client identified by the EUI-64 link-local address, not
+	// the DUID.
+	srcIP := net.ParseIP("fe80::5054:ff:fe12:3456")
+	request.msgType = dhcp6MsgRequest
+	request.options = []dhcp6Option{
+		{dhcp6OptionClientId, []byte{0, 4, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
+			12, 13, 14, 15, 16, 17, 18}}, // DUID-UUID.
+		{dhcp6OptionServerId, makeDuid(testServerMac)},
+		{dhcp6OptionIaNa, makeIaNaStatus(testIaid, 0, "")},
+	}
+	reply = server.handleDhcp6(request, srcIP, iface)
+	if reply == nil {
+		t.Fatal("Request: no reply")
+	}
+	if reply.msgType != dhcp6MsgReply {
+		t.Errorf("message type: %d != %d", reply.msgType, dhcp6MsgReply)
+	}
+	if ip := getReplyAddress(t, reply); !ip.Equal(testClientIP) {
+		t.Errorf("address: %s != %s", ip, testClientIP)
+	}
+	// Request for another server.
+	request.options[1].data = makeDuid(net.HardwareAddr{2, 0, 0, 0, 0, 9})
+	if server.handleDhcp6(request, srcIP, iface) != nil {
+		t.Error("replied to request for another server")
+	}
+	// Request without a server ID.
+	request.options = append(request.options[:1], request.options[2:]...)
+	if server.handleDhcp6(request, srcIP, iface) != nil {
+		t.Error("replied to request without server ID")
+	}
+	// Renew from an unknown client.
+	request.msgType = dhcp6MsgRenew
+	request.options = []dhcp6Option{
+		{dhcp6OptionClientId, unknownClientId},
+		{dhcp6OptionServerId, makeDuid(testServerMac)},
+		{dhcp6OptionIaNa, makeIaNaStatus(testIaid, 0, "")},
+	}
+	reply = server.handleDhcp6(request, net.ParseIP("fe80::2"), iface)
+	if reply == nil {
+		t.Fatal("Renew: no reply")
+	}
+	if getReplyAddress(t, reply) != nil {
+		t.Error("address leased to unknown client")
+	}
+	options, err := parseDhcp6Options(reply.getOption(dhcp6OptionIaNa)[12:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 1 || options[0].code != dhcp6OptionStatusCode ||
+		binary.BigEndian.Uint16(options[0].data) != dhcp6StatusNoBinding {
+		t.Errorf("expected NoBinding status, got: %v", options)
+	}
+	// Confirm.
+	request.msgType = dhcp6MsgConfirm
+	for _, test := range []struct {
+		address net.IP
+		status  uint16
+	}{
+		{testClientIP, dhcp6StatusSuccess},
+		{net.ParseIP("2001:db8::99"), dhcp6StatusNotOnLink},
+	} {
+		request.options = []dhcp6Option{
+			{dhcp6OptionClientId, clientId},
+			{dhcp6OptionIaNa, makeIaNa(testIaid, test.address, 3600)},
+		}
+		reply := server.handleDhcp6(request, net.ParseIP("fe80::1"), iface)
+		if reply == nil {
+			t.Fatalf("Confirm: %s: no reply", test.address)
+		}
+		status := reply.getOption(dhcp6OptionStatusCode)
+		if len(status) < 2 {
+			t.Fatalf("Confirm: %s: no status", test.address)
+		}
+		if code := binary.BigEndian.Uint16(status); code != test.status {
+			t.Errorf("Confirm: %s: status: %d != %d",
+				test.address, code, test.status)
+		}
+	}
+	// Information-request.
+	request.msgType = dhcp6MsgInformationRequest
+	request.options = nil
+	reply = server.handleDhcp6(request, net.ParseIP("fe80::2"), iface)
+	if reply == nil {
+		t.Fatal("Information-request: no reply")
+	}
+	if reply.getOption(dhcp6OptionIaNa) != nil {
+		t.Error("address in reply to Information-request")
+	}
+	if reply.getOption(dhcp6OptionDnsServers) == nil {
+		t.Error("no DNS servers in reply")
+	}
+	iface.Name = "br1"
+	if server.handleDhcp6(request, net.ParseIP("fe80::2"), iface) != nil {
+		t.Error("replied on interface without IPv6 subnets")
+	}
+}
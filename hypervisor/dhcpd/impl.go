@@ -49,6 +49,35 @@ func getClientIdentifier(options dhcp.Options) (string, string) {
 	return "(clientIdType=%s) ", fmt.Sprintf("%d", rawClientIdentifier[0])
 }
 
+// listMyIpv6s returns the global unicast IPv6 addresses for each interface.
+func listMyIpv6s() (map[string][]net.IP, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	ifMap := make(map[string][]net.IP)
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		interfaceAddrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range interfaceAddrs {
+			IP, _, err := net.ParseCIDR(addr.String())
+			if err != nil {
+				return nil, err
+			}
+			if IP.To4() != nil || !IP.IsGlobalUnicast() {
+				continue
+			}
+			ifMap[iface.Name] = append(ifMap[iface.Name], IP)
+		}
+	}
+	return ifMap, nil
+}
+
 func listMyIPs() (map[string][]net.IP, []net.IP, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
@@ -98,6 +127,7 @@ func newServer(interfaceNames []string, dynamicLeasesFile string,
 		dynamicLeasesFile: dynamicLeasesFile,
 		interfaceSubnets:  make(map[string][]*subnetType),
 		ipAddrToMacAddr:   make(map[string]string),
+		ipv6Subnets:       make(map[string][]*subnetType),
 		logger:            logger,
 		packetWatchers: make(
 			map[<-chan proto.WatchDhcpResponse]chan<- proto.WatchDhcpResponse),
@@ -114,6 +144,11 @@ func newServer(interfaceNames []string, dynamicLeasesFile string,
 		dhcpServer.interfaceIPs = interfaceIPs
 		dhcpServer.myIPs = myIPs
 	}
+	if interfaceIpv6s, err := listMyIpv6s(); err != nil {
+		return nil, err
+	} else {
+		dhcpServer.interfaceIpv6s = interfaceIpv6s
+	}
 	routeTable, err := util.GetRouteTable()
 	if err != nil {
 		return nil, err
@@ -172,6 +207,13 @@ func newServer(interfaceNames []string, dynamicLeasesFile string,
 		}
 	}()
 	go dhcpServer.cleanupDynamicLeasesLoop(cleanupTriggerChannel)
+	if err := dhcpServer.startDhcp6(serveConn.ifIndices); err != nil {
+		logger.Printf("unable to start DHCPv6 server: %s\n", err)
+	}
+	err = dhcpServer.startRouterAdvertisements(serveConn.ifIndices)
+	if err != nil {
+		logger.Printf("unable to start router advertisements: %s\n", err)
+	}
 	html.HandleFunc("/showDhcpStatus", dhcpServer.showDhcpStatusHandler)
 	return dhcpServer, nil
 }
@@ -266,12 +308,33 @@ func (s *DhcpServer) addSubnet(protoSubnet proto.Subnet) {
 			break
 		}
 	}
+	var ipv6IfaceName string
+	if len(protoSubnet.Ipv6Gateway) > 0 {
+		for name, ips := range s.interfaceIpv6s {
+			for _, ip := range ips {
+				if protoSubnet.Ipv6Gateway.Equal(ip) {
+					ipv6IfaceName = name
+					s.logger.Printf(
+						"attaching subnet IPv6 GW: %s to interface: %s\n",
+						ip, name)
+					break
+				}
+			}
+			if ipv6IfaceName != "" {
+				break
+			}
+		}
+	}
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	if ifaceName != "" {
 		s.interfaceSubnets[ifaceName] = append(s.interfaceSubnets[ifaceName],
 			subnet)
 	}
+	if ipv6IfaceName != "" {
+		s.ipv6Subnets[ipv6IfaceName] = append(s.ipv6Subnets[ipv6IfaceName],
+			subnet)
+	}
 	s.subnets = append(s.subnets, subnet)
 }
 
@@ -516,7 +579,9 @@ func (s *DhcpServer) makeOptions(subnet *subnetType,
 	lease *leaseType, reqOptions dhcp.Options) dhcp.Options {
 	dnsServers := make([]byte, 0)
 	for _, dnsServer := range subnet.DomainNameServers {
-		dnsServers = append(dnsServers, dnsServer...)
+		if dnsServer = dnsServer.To4(); dnsServer != nil {
+			dnsServers = append(dnsServers, dnsServer...)
+		}
 	}
 	leaseOptions := dhcp.Options{
 		dhcp.OptionSubnetMask:       subnet.IpMask,
@@ -530,12 +595,8 @@ func (s *DhcpServer) makeOptions(subnet *subnetType,
 		leaseOptions[dhcp.OptionHostName] = []byte(lease.hostname)
 	}
 	if lease.doNetboot {
-		var clientArchitecture uint16
-		if ca := reqOptions[dhcp.OptionClientArchitecture]; len(ca) > 1 {
-			clientArchitecture = uint16(ca[0])<<8 + uint16(ca[1])
-		}
-		leaseOptions[dhcp.OptionBootFileName] = []byte(
-			fmt.Sprintf(s.networkBootImage, clientArchitecture))
+		leaseOptions[dhcp.OptionBootFileName] = s.makeBootFileName(subnet,
+			reqOptions, leaseOptions)
 	}
 	return leaseOptions
 }
@@ -659,6 +720,15 @@ func (s *DhcpServer) removeSubnet(subnetId string) {
 		}
 		s.interfaceSubnets[name] = subnets
 	}
+	for name, subnets := range s.ipv6Subnets {
+		newSubnets := make([]*subnetType, 0, len(subnets))
+		for _, subnet := range subnets {
+			if subnet != subnetToDelete {
+				newSubnets = append(newSubnets, subnet)
+			}
+		}
+		s.ipv6Subnets[name] = newSubnets
+	}
 }
 
 func (s *DhcpServer) ServeDHCP(req dhcp.Packet, msgType dhcp.MessageType,
@@ -693,8 +763,7 @@ func (s *DhcpServer) ServeDHCP(req dhcp.Packet, msgType dhcp.MessageType,
 		leaseOptions := s.makeOptions(subnet, lease, options)
 		packet := dhcp.ReplyPacket(req, dhcp.Offer, subnet.myIP,
 			lease.IpAddress, s.computeLeaseTime(lease, true),
-			leaseOptions.SelectOrderOrAll(
-				options[dhcp.OptionParameterRequestList]))
+			selectOptions(leaseOptions, options))
 		packet.SetSIAddr(subnet.myIP)
 		return packet
 	case dhcp.Request:
@@ -710,7 +779,7 @@ func (s *DhcpServer) ServeDHCP(req dhcp.Packet, msgType dhcp.MessageType,
 				"did not request an IP, using: %s", reqIP.String()))
 		}
 		reqIP = util.ShrinkIP(reqIP)
-		s.notifyRequest(proto.Address{reqIP, macAddr})
+		s.notifyRequest(proto.Address{IpAddress: reqIP, MacAddress: macAddr})
 		server, ok := options[dhcp.OptionServerIdentifier]
 		if ok {
 			serverIP := net.IP(server)
@@ -759,8 +828,8 @@ func (s *DhcpServer) ServeDHCP(req dhcp.Packet, msgType dhcp.MessageType,
 			s.logger.Debugf(0, "ACK for: %s to: %s on: %s, server: %s\n",
 				reqIP, macAddr, s.requestInterface, subnet.myIP)
 			packet := dhcp.ReplyPacket(req, dhcp.ACK, subnet.myIP, reqIP,
-				s.computeLeaseTime(lease, false), leaseOptions.SelectOrderOrAll(
-					options[dhcp.OptionParameterRequestList]))
+				s.computeLeaseTime(lease, false),
+				selectOptions(leaseOptions, options))
 			packet.SetSIAddr(subnet.myIP)
 			return packet
 		} else {
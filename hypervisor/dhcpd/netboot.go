@@ -0,0 +1,87 @@
+package dhcpd
+
+import (
+	"bytes"
+	"fmt"
+
+	dhcp "github.com/krolaw/dhcp4"
+)
+
+const (
+	httpClientVendorClass = "HTTPClient"
+	httpBootPathPrefix    = "/tftpboot/"
+	ipxeUserClass         = "iPXE"
+)
+
+// isHttpBootClient returns true if the client is a UEFI HTTP Boot client.
+func isHttpBootClient(reqOptions dhcp.Options) bool {
+	return bytes.HasPrefix(reqOptions[dhcp.OptionVendorClassIdentifier],
+		[]byte(httpClientVendorClass))
+}
+
+// isIpxeClient returns true if the client is iPXE. iPXE sends a bare user
+// class string rather than the RFC 3004 length-prefixed list, so both forms
+// are accepted.
+func isIpxeClient(reqOptions dhcp.Options) bool {
+	userClass := reqOptions[dhcp.OptionUserClass]
+	if bytes.Equal(userClass, []byte(ipxeUserClass)) {
+		return true
+	}
+	for len(userClass) > 0 {
+		length := int(userClass[0])
+		if length+1 > len(userClass) {
+			break
+		}
+		if bytes.Equal(userClass[1:length+1], []byte(ipxeUserClass)) {
+			return true
+		}
+		userClass = userClass[length+1:]
+	}
+	return false
+}
+
+// selectOptions returns the options requested by the client. The Vendor Class
+// Identifier is always included if present, since UEFI HTTP Boot clients
+// require it in the response but do not necessarily request it.
+func selectOptions(leaseOptions, reqOptions dhcp.Options) []dhcp.Option {
+	order := reqOptions[dhcp.OptionParameterRequestList]
+	if order != nil {
+		_, ok := leaseOptions[dhcp.OptionVendorClassIdentifier]
+		if ok && bytes.IndexByte(order,
+			byte(dhcp.OptionVendorClassIdentifier)) < 0 {
+			order = append(append(make([]byte, 0, len(order)+1), order...),
+				byte(dhcp.OptionVendorClassIdentifier))
+		}
+	}
+	return leaseOptions.SelectOrderOrAll(order)
+}
+
+// makeBootFileName returns the boot file name for a network booting client.
+// iPXE clients are given the URL of the iPXE script, UEFI HTTP Boot clients
+// are given the URL of the HTTP boot image and all other clients are given the
+// name of the TFTP boot image.
+func (s *DhcpServer) makeBootFileName(subnet *subnetType,
+	reqOptions, leaseOptions dhcp.Options) []byte {
+	var clientArchitecture uint16
+	if ca := reqOptions[dhcp.OptionClientArchitecture]; len(ca) > 1 {
+		clientArchitecture = uint16(ca[0])<<8 + uint16(ca[1])
+	}
+	if s.httpPortNum > 0 {
+		if s.ipxeScript != "" && isIpxeClient(reqOptions) {
+			return []byte(s.makeHttpBootUrl(subnet, s.ipxeScript))
+		}
+		if s.httpBootImage != "" && isHttpBootClient(reqOptions) {
+			leaseOptions[dhcp.OptionVendorClassIdentifier] =
+				[]byte(httpClientVendorClass)
+			return []byte(s.makeHttpBootUrl(subnet,
+				fmt.Sprintf(s.httpBootImage, clientArchitecture)))
+		}
+	}
+	return []byte(fmt.Sprintf(s.networkBootImage, clientArchitecture))
+}
+
+func (s *DhcpServer) makeHttpBootUrl(subnet *subnetType,
+	filename string) string {
+	return fmt.Sprintf("http://%s:%d%s%s",
+		subnet.myIP, s.httpPortNum, httpBootPathPrefix, filename)
+}
@@ -0,0 +1,211 @@
+package dhcpd
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	dhcp "github.com/krolaw/dhcp4"
+)
+
+func TestIsIpxeClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		userClass []byte
+		expected  bool
+	}{
+		{"none", nil, false},
+		{"bare", []byte("iPXE"), true},
+		{"RFC 3004", []byte("\x04iPXE"), true},
+		{"RFC 3004 second", []byte("\x03abc\x04iPXE"), true},
+		{"RFC 3004 other", []byte("\x03abc"), false},
+		{"truncated", []byte("\x05iPXE"), false},
+		{"prefix", []byte("iPXE2"), false},
+	}
+	for _, test := range tests {
+		reqOptions := dhcp.Options{}
+		if test.userClass != nil {
+			reqOptions[dhcp.OptionUserClass] = test.userClass
+		}
+		if got := isIpxeClient(reqOptions); got != test.expected {
+			t.Errorf("%s: expected: %v, got: %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestIsHttpBootClient(t *testing.T) {
+	tests := []struct {
+		vendorClass string
+		expected    bool
+	}{
+		{"", false},
+		{"HTTPClient:Arch:00016:UNDI:003001", true},
+		{"PXEClient:Arch:00007:UNDI:003016", false},
+	}
+	for _, test := range tests {
+		reqOptions := dhcp.Options{
+			dhcp.OptionVendorClassIdentifier: []byte(test.vendorClass),
+		}
+		if got := isHttpBootClient(reqOptions); got != test.expected {
+			t.Errorf("\"%s\": expected: %v, got: %v",
+				test.vendorClass, test.expected, got)
+		}
+	}
+}
+
+func TestSelectOptions(t *testing.T) {
+	vendorClass := byte(dhcp.OptionVendorClassIdentifier)
+	tests := []struct {
+		name           string
+		leaseOptions   dhcp.Options
+		requestList    []byte
+		expectedOrder  []dhcp.OptionCode
+		expectedLength int // Used if there is no request list.
+	}{
+		{
+			name: "HTTPClient not requesting vendor class",
+			leaseOptions: dhcp.Options{
+				dhcp.OptionRouter:                []byte{10, 0, 0, 1},
+				dhcp.OptionSubnetMask:            []byte{255, 255, 255, 0},
+				dhcp.OptionVendorClassIdentifier: []byte("HTTPClient"),
+			},
+			requestList: []byte{byte(dhcp.OptionSubnetMask),
+				byte(dhcp.OptionRouter)},
+			expectedOrder: []dhcp.OptionCode{dhcp.OptionSubnetMask,
+				dhcp.OptionRouter, dhcp.OptionVendorClassIdentifier},
+		},
+		{
+			name: "HTTPClient requesting vendor class",
+			leaseOptions: dhcp.Options{
+				dhcp.OptionRouter:                []byte{10, 0, 0, 1},
+				dhcp.OptionVendorClassIdentifier: []byte("HTTPClient"),
+			},
+			requestList: []byte{vendorClass, byte(dhcp.OptionRouter)},
+			expectedOrder: []dhcp.OptionCode{dhcp.OptionVendorClassIdentifier,
+				dhcp.OptionRouter},
+		},
+		{
+			name: "PXE",
+			leaseOptions: dhcp.Options{
+				dhcp.OptionRouter:     []byte{10, 0, 0, 1},
+				dhcp.OptionSubnetMask: []byte{255, 255, 255, 0},
+			},
+			requestList:   []byte{byte(dhcp.OptionRouter), vendorClass},
+			expectedOrder: []dhcp.OptionCode{dhcp.OptionRouter},
+		},
+		{
+			name: "no request list",
+			leaseOptions: dhcp.Options{
+				dhcp.OptionRouter:                []byte{10, 0, 0, 1},
+				dhcp.OptionVendorClassIdentifier: []byte("HTTPClient"),
+			},
+			expectedLength: 2,
+		},
+	}
+	for _, test := range tests {
+		reqOptions := dhcp.Options{}
+		if test.requestList != nil {
+			reqOptions[dhcp.OptionParameterRequestList] = test.requestList
+		}
+		requestList := append([]byte(nil), test.requestList...)
+		options := selectOptions(test.leaseOptions, reqOptions)
+		if !bytes.Equal(test.requestList, requestList) {
+			t.Errorf("%s: request list modified", test.name)
+		}
+		if test.requestList == nil {
+			if len(options) != test.expectedLength {
+				t.Errorf("%s: expected: %d options, got: %d",
+					test.name, test.expectedLength, len(options))
+			}
+			continue
+		}
+		if len(options) != len(test.expectedOrder) {
+			t.Errorf("%s: expected: %d options, got: %d",
+				test.name, len(test.expectedOrder), len(options))
+			continue
+		}
+		for index, option := range options {
+			if option.Code != test.expectedOrder[index] {
+				t.Errorf("%s: option: %d expected: %s, got: %s",
+					test.name, index, test.expectedOrder[index], option.Code)
+			}
+		}
+	}
+}
+
+func TestMakeBootFileName(t *testing.T) {
+	subnet := &subnetType{myIP: net.IPv4(10, 0, 0, 1)}
+	tests := []struct {
+		name        string
+		httpPortNum uint
+		reqOptions  dhcp.Options
+		expected    string
+		vendorClass bool // Vendor Class Identifier added to the lease.
+	}{
+		{
+			name:        "iPXE",
+			httpPortNum: 8080,
+			reqOptions: dhcp.Options{
+				dhcp.OptionUserClass:          []byte("iPXE"),
+				dhcp.OptionClientArchitecture: []byte{0, 7},
+			},
+			expected: "http://10.0.0.1:8080/tftpboot/boot.ipxe",
+		},
+		{
+			name:        "HTTPClient",
+			httpPortNum: 8080,
+			reqOptions: dhcp.Options{
+				dhcp.OptionVendorClassIdentifier: []byte(
+					"HTTPClient:Arch:00016"),
+				dhcp.OptionClientArchitecture: []byte{0, 16},
+			},
+			expected:    "http://10.0.0.1:8080/tftpboot/uefi-16.efi",
+			vendorClass: true,
+		},
+		{
+			name:        "HTTPClient without HTTP server",
+			httpPortNum: 0,
+			reqOptions: dhcp.Options{
+				dhcp.OptionVendorClassIdentifier: []byte(
+					"HTTPClient:Arch:00016"),
+				dhcp.OptionClientArchitecture: []byte{0, 16},
+			},
+			expected: "pxe-16.0",
+		},
+		{
+			name:        "PXE",
+			httpPortNum: 8080,
+			reqOptions: dhcp.Options{
+				dhcp.OptionVendorClassIdentifier: []byte(
+					"PXEClient:Arch:00007"),
+				dhcp.OptionClientArchitecture: []byte{1, 2},
+			},
+			expected: "pxe-258.0",
+		},
+		{
+			name:        "PXE without architecture",
+			httpPortNum: 8080,
+			reqOptions:  dhcp.Options{},
+			expected:    "pxe-0.0",
+		},
+	}
+	for _, test := range tests {
+		s := &DhcpServer{
+			httpBootImage:    "uefi-%d.efi",
+			httpPortNum:      test.httpPortNum,
+			ipxeScript:       "boot.ipxe",
+			networkBootImage: "pxe-%d.0",
+		}
+		leaseOptions := dhcp.Options{}
+		got := string(s.makeBootFileName(subnet, test.reqOptions,
+			leaseOptions))
+		if got != test.expected {
+			t.Errorf("%s: expected: %s, got: %s", test.name, test.expected, got)
+		}
+		_, ok := leaseOptions[dhcp.OptionVendorClassIdentifier]
+		if ok != test.vendorClass {
+			t.Errorf("%s: expected vendor class: %v, got: %v",
+				test.name, test.vendorClass, ok)
+		}
+	}
+}
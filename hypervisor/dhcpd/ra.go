@@ -0,0 +1,149 @@
+package dhcpd
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	raInterval       = 200 * time.Second
+	raRouterLifetime = 1800 // Seconds.
+	raValidLifetime  = 86400
+	raPreferLifetime = 14400
+
+	raFlagManaged      = 0x80
+	raFlagOther        = 0x40
+	prefixFlagOnLink   = 0x80
+	ndOptionSourceLL   = 1
+	ndOptionPrefixInfo = 3
+)
+
+var (
+	allNodes   = net.ParseIP("ff02::1")
+	allRouters = net.ParseIP("ff02::2")
+)
+
+// makeRouterAdvertisement returns a Router Advertisement (RFC 4861) for the
+// subnets. The Managed and Other flags are set so that clients use DHCPv6 for
+// addresses and configuration. Prefixes are on-link but not for autonomous
+// address configuration.
+func makeRouterAdvertisement(macAddr net.HardwareAddr,
+	subnets []*subnetType) []byte {
+	message := make([]byte, 16, 16+8+32*len(subnets))
+	message[0] = byte(ipv6.ICMPTypeRouterAdvertisement)
+	message[4] = 64 // Current hop limit.
+	message[5] = raFlagManaged | raFlagOther
+	binary.BigEndian.PutUint16(message[6:8], raRouterLifetime)
+	if len(macAddr) == 6 {
+		message = append(message, ndOptionSourceLL, 1)
+		message = append(message, macAddr...)
+	}
+	for _, subnet := range subnets {
+		prefixLength, _ := net.IPMask(subnet.Ipv6Mask).Size()
+		option := make([]byte, 32)
+		option[0] = ndOptionPrefixInfo
+		option[1] = 4 // Length in units of 8 bytes.
+		option[2] = byte(prefixLength)
+		option[3] = prefixFlagOnLink
+		binary.BigEndian.PutUint32(option[4:8], raValidLifetime)
+		binary.BigEndian.PutUint32(option[8:12], raPreferLifetime)
+		copy(option[16:32],
+			subnet.Ipv6Gateway.Mask(net.IPMask(subnet.Ipv6Mask)).To16())
+		message = append(message, option...)
+	}
+	return message
+}
+
+func (s *DhcpServer) startRouterAdvertisements(ifIndices map[int]string) error {
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return err
+	}
+	pktConn := conn.IPv6PacketConn()
+	if err := pktConn.SetControlMessage(ipv6.FlagInterface, true); err != nil {
+		conn.Close()
+		return err
+	}
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeRouterSolicitation)
+	if err := pktConn.SetICMPFilter(&filter); err != nil {
+		conn.Close()
+		return err
+	}
+	group := &net.IPAddr{IP: allRouters}
+	for ifIndex, name := range ifIndices {
+		iface, err := net.InterfaceByIndex(ifIndex)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		if err := pktConn.JoinGroup(iface, group); err != nil {
+			s.logger.Printf("unable to join all-routers group on: %s: %s\n",
+				name, err)
+		}
+	}
+	go s.receiveRouterSolicitations(pktConn, ifIndices)
+	go s.sendRouterAdvertisementsLoop(pktConn, ifIndices)
+	return nil
+}
+
+func (s *DhcpServer) receiveRouterSolicitations(conn *ipv6.PacketConn,
+	ifIndices map[int]string) {
+	buffer := make([]byte, 1500)
+	for {
+		length, cm, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			s.logger.Println(err)
+			return
+		}
+		if cm == nil || length < 1 {
+			continue
+		}
+		if buffer[0] != byte(ipv6.ICMPTypeRouterSolicitation) {
+			continue
+		}
+		if _, ok := ifIndices[cm.IfIndex]; ok {
+			s.sendRouterAdvertisement(conn, cm.IfIndex)
+		}
+	}
+}
+
+// sendRouterAdvertisement sends a Router Advertisement on the interface if
+// the hypervisor is the IPv6 gateway for any subnets on that interface.
+func (s *DhcpServer) sendRouterAdvertisement(conn *ipv6.PacketConn,
+	ifIndex int) {
+	iface, err := net.InterfaceByIndex(ifIndex)
+	if err != nil {
+		s.logger.Println(err)
+		return
+	}
+	s.mutex.RLock()
+	subnets := s.ipv6Subnets[iface.Name]
+	if len(subnets) < 1 {
+		s.mutex.RUnlock()
+		return
+	}
+	message := makeRouterAdvertisement(iface.HardwareAddr, subnets)
+	s.mutex.RUnlock()
+	_, err = conn.WriteTo(message,
+		&ipv6.ControlMessage{HopLimit: 255, IfIndex: ifIndex},
+		&net.IPAddr{IP: allNodes})
+	if err != nil {
+		s.logger.Printf("error sending router advertisement on: %s: %s\n",
+			iface.Name, err)
+	}
+}
+
+func (s *DhcpServer) sendRouterAdvertisementsLoop(conn *ipv6.PacketConn,
+	ifIndices map[int]string) {
+	for ; ; time.Sleep(raInterval) {
+		for ifIndex := range ifIndices {
+			s.sendRouterAdvertisement(conn, ifIndex)
+		}
+	}
+}
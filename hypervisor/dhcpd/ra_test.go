@@ -0,0 +1,85 @@
+package dhcpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+	"golang.org/x/net/ipv6"
+)
+
+func TestMakeRouterAdvertisement(t *testing.T) {
+	subnets := []*subnetType{
+		{
+			Subnet: proto.Subnet{
+				Ipv6Gateway: net.ParseIP("2001:db8:0:1::1"),
+				Ipv6Mask:    net.IP(net.CIDRMask(64, 128)),
+			},
+		},
+		{
+			Subnet: proto.Subnet{
+				Ipv6Gateway: net.ParseIP("2001:db8:1::1"),
+				Ipv6Mask:    net.IP(net.CIDRMask(48, 128)),
+			},
+		},
+	}
+	message := makeRouterAdvertisement(testServerMac, subnets)
+	if len(message) != 16+8+32*2 {
+		t.Fatalf("length: %d != %d", len(message), 16+8+32*2)
+	}
+	if message[0] != byte(ipv6.ICMPTypeRouterAdvertisement) {
+		t.Errorf("type: %d", message[0])
+	}
+	if message[5] != raFlagManaged|raFlagOther {
+		t.Errorf("flags: 0x%x", message[5])
+	}
+	if lifetime := binary.BigEndian.Uint16(message[6:8]); lifetime !=
+		raRouterLifetime {
+		t.Errorf("router lifetime: %d", lifetime)
+	}
+	options := message[16:]
+	if options[0] != ndOptionSourceLL || options[1] != 1 ||
+		!bytes.Equal(options[2:8], testServerMac) {
+		t.Errorf("bad source link-layer option: %v", options[0:8])
+	}
+	options = options[8:]
+	expectedPrefixes := []struct {
+		prefix string
+		length byte
+	}{
+		{"2001:db8:0:1::", 64},
+		{"2001:db8:1::", 48},
+	}
+	for _, expected := range expectedPrefixes {
+		option := options[:32]
+		options = options[32:]
+		if option[0] != ndOptionPrefixInfo || option[1] != 4 {
+			t.Errorf("bad prefix option header: %v", option[0:2])
+		}
+		if option[2] != expected.length {
+			t.Errorf("prefix length: %d != %d", option[2], expected.length)
+		}
+		if option[3] != prefixFlagOnLink {
+			t.Errorf("prefix flags: 0x%x", option[3])
+		}
+		if valid := binary.BigEndian.Uint32(option[4:8]); valid !=
+			raValidLifetime {
+			t.Errorf("valid lifetime: %d", valid)
+		}
+		if preferred := binary.BigEndian.Uint32(option[8:12]); preferred !=
+			raPreferLifetime {
+			t.Errorf("preferred lifetime: %d", preferred)
+		}
+		if prefix := net.IP(option[16:32]); !prefix.Equal(
+			net.ParseIP(expected.prefix)) {
+			t.Errorf("prefix: %s != %s", prefix, expected.prefix)
+		}
+	}
+	message = makeRouterAdvertisement(nil, subnets[:1])
+	if len(message) != 16+32 {
+		t.Errorf("length without link-layer address: %d != %d",
+			len(message), 16+32)
+	}
+}
@@ -9,10 +9,30 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
 	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/net/util"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
 )
 
+// fillIpv6Address sets the IPv6 address (derived from the MAC address) if the
+// subnet has an IPv6 prefix and the address does not have an IPv6 address.
+func fillIpv6Address(address *proto.Address, subnet proto.Subnet) error {
+	if len(address.Ipv6Address) > 0 || len(subnet.Ipv6Gateway) < 1 {
+		return nil
+	}
+	macAddr, err := net.ParseMAC(address.MacAddress)
+	if err != nil {
+		return err
+	}
+	ipAddr, err := util.MakeEui64IP(subnet.Ipv6Gateway,
+		net.IPMask(subnet.Ipv6Mask), macAddr)
+	if err != nil {
+		return fmt.Errorf("subnet: %s: %s", subnet.Id, err)
+	}
+	address.Ipv6Address = ipAddr
+	return nil
+}
+
 func ipIsUnspecified(ipAddr net.IP) bool {
 	if len(ipAddr) < 1 {
 		return true
@@ -70,6 +90,9 @@ func (m *Manager) addAddressesToPool(addresses []proto.Address) error {
 		if address.IpAddress != nil {
 			registeredIpAddresses[address.IpAddress.String()] = struct{}{}
 		}
+		if address.Ipv6Address != nil {
+			registeredIpAddresses[address.Ipv6Address.String()] = struct{}{}
+		}
 		registeredMacAddresses[address.MacAddress] = struct{}{}
 	}
 	ipAddressToVm := make(map[string]*vmInfoType, len(m.vms))
@@ -81,6 +104,20 @@ func (m *Manager) addAddressesToPool(addresses []proto.Address) error {
 	for _, address := range addresses {
 		ipAddr := address.IpAddress
 		var used *vmInfoType
+		if ipv6Addr := address.Ipv6Address; ipv6Addr != nil {
+			if ipAddr == nil {
+				return fmt.Errorf("IPv6 address: %s without IPv4 address",
+					ipv6Addr)
+			}
+			if !subnetContainsIP(m.subnets[m.getMatchingSubnet(ipAddr)],
+				ipv6Addr) {
+				return fmt.Errorf("IPv6 address: %s not in subnet for: %s",
+					ipv6Addr, ipAddr)
+			}
+			if _, ok := registeredIpAddresses[ipv6Addr.String()]; ok {
+				return fmt.Errorf("duplicate IP address: %s", ipv6Addr)
+			}
+		}
 		if ipAddr != nil {
 			if m.getMatchingSubnet(ipAddr) == "" {
 				return fmt.Errorf("no subnet matching: %s", address.IpAddress)
@@ -169,17 +206,27 @@ func (m *Manager) getFreeAddress(ipAddr net.IP, subnetId string,
 	if subnet, err := m.getSubnetAndAuth(subnetId, authInfo); err != nil {
 		return proto.Address{}, "", err
 	} else {
-		subnetMask := net.IPMask(subnet.IpMask)
-		subnetAddr := subnet.IpGateway.Mask(subnetMask)
+		requestIpv6 := !ipIsUnspecified(ipAddr) && ipAddr.To4() == nil
 		foundPos := -1
 		for index, address := range m.addressPool.Free {
-			if !ipIsUnspecified(ipAddr) && !ipAddr.Equal(address.IpAddress) {
+			if !subnetContainsIP(subnet, address.IpAddress) {
 				continue
 			}
-			if address.IpAddress.Mask(subnetMask).Equal(subnetAddr) {
-				foundPos = index
-				break
+			if requestIpv6 {
+				if err := fillIpv6Address(&address, subnet); err != nil {
+					return proto.Address{}, "",
+						fmt.Errorf("MAC address: %s: %s",
+							address.MacAddress, err)
+				}
+				if !ipAddr.Equal(address.Ipv6Address) {
+					continue
+				}
+			} else if !ipIsUnspecified(ipAddr) &&
+				!ipAddr.Equal(address.IpAddress) {
+				continue
 			}
+			foundPos = index
+			break
 		}
 		if foundPos < 0 {
 			if ipIsUnspecified(ipAddr) {
@@ -190,6 +237,10 @@ func (m *Manager) getFreeAddress(ipAddr net.IP, subnetId string,
 					fmt.Errorf("address: %s not found in free pool", ipAddr)
 			}
 		}
+		address := m.addressPool.Free[foundPos]
+		if err := fillIpv6Address(&address, subnet); err != nil {
+			return proto.Address{}, "", err
+		}
 		addressPool := addressPoolType{
 			Free:       make([]proto.Address, 0, len(m.addressPool.Free)-1),
 			Registered: m.addressPool.Registered,
@@ -203,7 +254,6 @@ func (m *Manager) getFreeAddress(ipAddr net.IP, subnetId string,
 		if err := m.writeAddressPoolWithLock(addressPool, false); err != nil {
 			return proto.Address{}, "", err
 		}
-		address := m.addressPool.Free[foundPos]
 		m.addressPool = addressPool
 		return address, subnet.Id, nil
 	}
@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+var testDualStackSubnet = proto.Subnet{
+	Id:          "dual",
+	IpGateway:   net.ParseIP("10.0.0.1").To4(),
+	IpMask:      net.IP(net.CIDRMask(24, 32)),
+	Ipv6Gateway: net.ParseIP("2001:db8::1"),
+	Ipv6Mask:    net.IP(net.CIDRMask(64, 128)),
+}
+
+func TestFillIpv6Address(t *testing.T) {
+	address := proto.Address{MacAddress: "52:54:00:12:34:56"}
+	if err := fillIpv6Address(&address, proto.Subnet{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(address.Ipv6Address) > 0 {
+		t.Errorf("IPv6 address: %s filled for IPv4-only subnet",
+			address.Ipv6Address)
+	}
+	if err := fillIpv6Address(&address, testDualStackSubnet); err != nil {
+		t.Fatal(err)
+	}
+	if expected := net.ParseIP("2001:db8::5054:ff:fe12:3456"); !expected.Equal(
+		address.Ipv6Address) {
+		t.Errorf("IPv6 address: %s != %s", address.Ipv6Address, expected)
+	}
+	explicit := net.ParseIP("2001:db8::10")
+	address = proto.Address{
+		Ipv6Address: explicit,
+		MacAddress:  "52:54:00:12:34:56",
+	}
+	if err := fillIpv6Address(&address, testDualStackSubnet); err != nil {
+		t.Fatal(err)
+	}
+	if !address.Ipv6Address.Equal(explicit) {
+		t.Errorf("explicit IPv6 address replaced with: %s",
+			address.Ipv6Address)
+	}
+	address = proto.Address{MacAddress: "not-a-MAC"}
+	if err := fillIpv6Address(&address, testDualStackSubnet); err == nil {
+		t.Error("no error for bad MAC address")
+	}
+	badSubnet := testDualStackSubnet
+	badSubnet.Ipv6Mask = net.IP(net.CIDRMask(96, 128))
+	address = proto.Address{MacAddress: "52:54:00:12:34:56"}
+	if err := fillIpv6Address(&address, badSubnet); err == nil {
+		t.Error("no error for /96 IPv6 prefix")
+	}
+}
+
+func makeAddressPoolTestManager(t *testing.T,
+	free []proto.Address) *Manager {
+	return &Manager{
+		StartOptions: StartOptions{
+			Logger:   testlogger.New(t),
+			StateDir: t.TempDir(),
+		},
+		addressPool: addressPoolType{Free: free},
+		subnets:     map[string]proto.Subnet{"dual": testDualStackSubnet},
+	}
+}
+
+func TestGetFreeIpv6Address(t *testing.T) {
+	authInfo := &srpc.AuthInformation{HaveMethodAccess: true}
+	explicit := net.ParseIP("2001:db8::10")
+	m := makeAddressPoolTestManager(t, []proto.Address{
+		{
+			IpAddress:  net.ParseIP("10.0.0.2").To4(),
+			MacAddress: "52:54:00:00:00:02",
+		},
+		{
+			IpAddress:   net.ParseIP("10.0.0.3").To4(),
+			Ipv6Address: explicit,
+			MacAddress:  "52:54:00:00:00:03",
+		},
+		{
+			IpAddress:  net.ParseIP("10.0.0.4").To4(),
+			MacAddress: "52:54:00:00:00:04",
+		},
+	})
+	address, subnetId, err := m.getFreeAddress(
+		net.ParseIP("2001:db8::5054:ff:fe00:4"), "dual", authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subnetId != "dual" {
+		t.Errorf("subnet: %s != dual", subnetId)
+	}
+	if address.MacAddress != "52:54:00:00:00:04" {
+		t.Errorf("MAC address: %s != 52:54:00:00:00:04", address.MacAddress)
+	}
+	address, _, err = m.getFreeAddress(explicit, "dual", authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address.MacAddress != "52:54:00:00:00:03" {
+		t.Errorf("MAC address: %s != 52:54:00:00:00:03", address.MacAddress)
+	}
+	address, _, err = m.getFreeAddress(nil, "dual", authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := net.ParseIP("2001:db8::5054:ff:fe00:2"); !expected.Equal(
+		address.Ipv6Address) {
+		t.Errorf("IPv6 address: %s != %s", address.Ipv6Address, expected)
+	}
+	if len(m.addressPool.Free) > 0 {
+		t.Errorf("%d addresses left in pool", len(m.addressPool.Free))
+	}
+}
+
+func TestGetFreeIpv6AddressBadMac(t *testing.T) {
+	m := makeAddressPoolTestManager(t, []proto.Address{
+		{
+			IpAddress:  net.ParseIP("10.0.0.2").To4(),
+			MacAddress: "bad",
+		},
+	})
+	_, _, err := m.getFreeAddress(net.ParseIP("2001:db8::5054:ff:fe00:2"),
+		"dual", &srpc.AuthInformation{HaveMethodAccess: true})
+	if err == nil {
+		t.Error("no error for bad MAC address in pool")
+	}
+	if len(m.addressPool.Free) != 1 {
+		t.Errorf("pool modified: %d addresses", len(m.addressPool.Free))
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
 	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/lockwatcher"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/objectserver/cachingreader"
@@ -79,21 +80,26 @@ type Manager struct {
 }
 
 type StartOptions struct {
-	BridgeMap            map[string]net.Interface // Key: interface name.
-	DhcpServer           DhcpServer
-	IdentityProvider     string
-	ImageServerAddress   string
-	LockCheckInterval    time.Duration
-	LockLogTimeout       time.Duration
-	LocalImagesDirectory string
-	Logger               log.DebugLogger
-	ObjectCacheDirectory string
-	ObjectCacheBytes     uint64
-	ShowVgaConsole       bool
-	StateDir             string
-	Username             string
-	VlanIdToBridge       map[uint]string // Key: VLAN ID, value: bridge interface.
-	VolumeDirectories    []string
+	BackupInterval         time.Duration            // Zero: no scheduled VM backups.
+	BackupImageDirectory   string                   // Backup images are added here.
+	BackupObjectServer     string                   // host:port.
+	BackupRunningVMs       bool                     // Include in scheduled backups.
+	BridgeMap              map[string]net.Interface // Key: interface name.
+	DhcpServer             DhcpServer
+	IdentityProvider       string
+	ImageServerAddress     string
+	LockCheckInterval      time.Duration
+	LockLogTimeout         time.Duration
+	LocalImagesDirectory   string
+	Logger                 log.DebugLogger
+	ObjectCacheDirectory   string
+	ObjectCacheBytes       uint64
+	ObjectDeltaMinimumSize uint64 // Zero: do not fetch objects as deltas.
+	ShowVgaConsole         bool
+	StateDir               string
+	Username               string
+	VlanIdToBridge         map[uint]string // Key: VLAN ID, value: bridge interface.
+	VolumeDirectories      []string
 }
 
 type summaryData struct {
@@ -114,6 +120,7 @@ type vmInfoType struct {
 	mutex                      sync.RWMutex
 	accessToken                []byte
 	accessTokenCleanupNotifier chan<- struct{}
+	backupInProgress           bool
 	commandInput               chan<- string
 	commandOutput              chan byte
 	destroyTimer               *time.Timer
@@ -158,6 +165,11 @@ func (m *Manager) AddVmVolumes(ipAddr net.IP,
 	return m.addVmVolumes(ipAddr, authInfo, volumeSizes)
 }
 
+func (m *Manager) BackupVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	forceIfNotStopped bool) (hash.Hash, error) {
+	return m.backupVm(ipAddr, authInfo, forceIfNotStopped)
+}
+
 func (m *Manager) BecomePrimaryVmOwner(ipAddr net.IP,
 	authInfo *srpc.AuthInformation) error {
 	return m.becomePrimaryVmOwner(ipAddr, authInfo)
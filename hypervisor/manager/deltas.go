@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"path"
+
+	imclient "github.com/Cloud-Foundations/Dominator/imageserver/client"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/verstr"
+	proto "github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+// findPreviousImage will return the name of the latest image in the same
+// directory which precedes the specified image, or "" if there is none.
+func findPreviousImage(client *srpc.Client, imageName string) (string, error) {
+	names, err := imclient.ListSelectedImages(client,
+		proto.ListSelectedImagesRequest{
+			DirectoryName: path.Dir(imageName) + "/",
+		})
+	if err != nil {
+		return "", err
+	}
+	return selectPreviousImage(names, imageName), nil
+}
+
+// listDeltaCandidates will return the hashes of regular files in the file-system
+// of at least minimumSize bytes.
+func listDeltaCandidates(fs *filesystem.FileSystem,
+	minimumSize uint64) []hash.Hash {
+	var hashes []hash.Hash
+	found := make(map[hash.Hash]struct{})
+	for _, genericInode := range fs.InodeTable {
+		inode, ok := genericInode.(*filesystem.RegularInode)
+		if !ok || inode.Size < minimumSize {
+			continue
+		}
+		if _, ok := found[inode.Hash]; ok {
+			continue
+		}
+		found[inode.Hash] = struct{}{}
+		hashes = append(hashes, inode.Hash)
+	}
+	return hashes
+}
+
+// selectPreviousImage will return the latest image name in names which
+// precedes imageName, or "" if there is none.
+func selectPreviousImage(names []string, imageName string) string {
+	var previousName string
+	for _, name := range names {
+		if !verstr.Less(name, imageName) {
+			continue
+		}
+		if previousName == "" || verstr.Less(previousName, name) {
+			previousName = name
+		}
+	}
+	return previousName
+}
+
+// haveUncachedDeltaCandidates returns true if some regular files in the
+// file-system which are large enough to be fetched as deltas are not cached.
+func (m *Manager) haveUncachedDeltaCandidates(
+	fs *filesystem.FileSystem) (bool, error) {
+	hashes := listDeltaCandidates(fs, m.ObjectDeltaMinimumSize)
+	if len(hashes) < 1 {
+		return false, nil
+	}
+	sizes, err := m.objectCache.CheckObjects(hashes)
+	if err != nil {
+		return false, err
+	}
+	for _, size := range sizes {
+		if size < 1 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// prefetchObjectDeltas will fetch objects for the specified image into the
+// object cache as deltas against cached objects from the previous image in the
+// same directory. This is an optimisation, so errors are logged and ignored.
+func (m *Manager) prefetchObjectDeltas(client *srpc.Client, imageName string,
+	fs *filesystem.FileSystem) {
+	if m.objectCache == nil || m.ObjectDeltaMinimumSize < 1 ||
+		imageName == "" {
+		return
+	}
+	if uncached, err := m.haveUncachedDeltaCandidates(fs); err != nil {
+		m.Logger.Debugf(0, "error checking cached objects for: %s: %s\n",
+			imageName, err)
+		return
+	} else if !uncached {
+		return
+	}
+	previousName, err := findPreviousImage(client, imageName)
+	if err != nil {
+		m.Logger.Debugf(0, "error finding image preceding: %s: %s\n",
+			imageName, err)
+		return
+	}
+	if previousName == "" {
+		return
+	}
+	previous, err := imclient.GetImage(client, previousName)
+	if err != nil {
+		m.Logger.Debugf(0, "error getting image: %s: %s\n", previousName, err)
+		return
+	}
+	if previous == nil {
+		return
+	}
+	if err := previous.FileSystem.RebuildInodePointers(); err != nil {
+		m.Logger.Debugf(0, "error rebuilding image: %s: %s\n",
+			previousName, err)
+		return
+	}
+	img := &image.Image{FileSystem: fs}
+	deltaBases := img.FindDeltaBases(previous, m.ObjectDeltaMinimumSize)
+	if len(deltaBases) < 1 {
+		return
+	}
+	numFetched, err := m.objectCache.FetchObjectDeltas(deltaBases)
+	if err != nil {
+		m.Logger.Printf("error fetching object deltas for: %s: %s\n",
+			imageName, err)
+		return
+	}
+	if numFetched > 0 {
+		m.Logger.Printf("fetched %d objects for: %s as deltas against: %s\n",
+			numFetched, imageName, previousName)
+	}
+}
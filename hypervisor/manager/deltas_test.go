@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+)
+
+func TestSelectPreviousImage(t *testing.T) {
+	names := []string{
+		"stream/v1.9",
+		"stream/v1.10",
+		"stream/v1.2",
+		"stream/v1.11",
+		"stream/v2.0",
+	}
+	tests := []struct {
+		imageName string
+		expected  string
+	}{
+		{"stream/v1.11", "stream/v1.10"},
+		{"stream/v1.10", "stream/v1.9"},
+		{"stream/v1.9", "stream/v1.2"},
+		{"stream/v1.2", ""},
+		{"stream/v3.0", "stream/v2.0"},
+		{"stream/v1.5", "stream/v1.2"},
+	}
+	for _, test := range tests {
+		result := selectPreviousImage(names, test.imageName)
+		if result != test.expected {
+			t.Errorf("previous image for: %s: %s != %s",
+				test.imageName, result, test.expected)
+		}
+	}
+	if result := selectPreviousImage(nil, "stream/v1.0"); result != "" {
+		t.Errorf("previous image from empty list: %s", result)
+	}
+}
+
+func TestListDeltaCandidates(t *testing.T) {
+	fs := &filesystem.FileSystem{
+		InodeTable: filesystem.InodeTable{
+			1: &filesystem.RegularInode{Size: 100, Hash: hash.Hash{1}},
+			2: &filesystem.RegularInode{Size: 100, Hash: hash.Hash{1}},
+			3: &filesystem.RegularInode{Size: 10, Hash: hash.Hash{3}},
+			4: &filesystem.RegularInode{Size: 50, Hash: hash.Hash{4}},
+			5: &filesystem.SymlinkInode{Symlink: "target"},
+		},
+	}
+	hashes := listDeltaCandidates(fs, 50)
+	if len(hashes) != 2 {
+		t.Fatalf("number of candidates: %d != 2", len(hashes))
+	}
+	found := make(map[hash.Hash]struct{})
+	for _, hashVal := range hashes {
+		found[hashVal] = struct{}{}
+	}
+	for _, hashVal := range []hash.Hash{{1}, {4}} {
+		if _, ok := found[hashVal]; !ok {
+			t.Errorf("missing candidate: %x", hashVal[:1])
+		}
+	}
+}
@@ -15,7 +15,6 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
 	"github.com/Cloud-Foundations/Dominator/lib/json"
 	"github.com/Cloud-Foundations/Dominator/lib/lockwatcher"
-	"github.com/Cloud-Foundations/Dominator/lib/log/prefixlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/meminfo"
 	"github.com/Cloud-Foundations/Dominator/lib/rpcclientpool"
 	"github.com/Cloud-Foundations/Dominator/lib/stringutil"
@@ -128,7 +127,7 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 		vmDirname := filepath.Join(dirname, ipAddr)
 		filename := filepath.Join(vmDirname, "info.json")
 		vmInfo := vmInfoType{
-			logger: prefixlogger.New(ipAddr+": ", manager.Logger),
+			logger: manager.newVmLogger(ipAddr),
 		}
 		if err := json.ReadFromFile(filename, &vmInfo); err != nil {
 			vmInfo.logger.Println(err)
@@ -235,6 +234,7 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 		manager.writeAddressPoolWithLock(manager.addressPool, false)
 	}
 	go manager.loopCheckHealthStatus()
+	go manager.loopBackupVMs()
 	lockCheckInterval := startOptions.LockCheckInterval
 	if lockCheckInterval > time.Second {
 		// Leveraged for dashboard, so keep it fresh.
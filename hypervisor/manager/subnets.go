@@ -41,6 +41,21 @@ func checkSubnetAccess(subnet proto.Subnet,
 	return false
 }
 
+// subnetContainsIP returns true if the IPv4 or IPv6 address is in the subnet.
+func subnetContainsIP(subnet proto.Subnet, ipAddr net.IP) bool {
+	if ipAddr.To4() == nil {
+		if len(subnet.Ipv6Gateway) < 1 {
+			return false
+		}
+		subnetMask := net.IPMask(subnet.Ipv6Mask)
+		subnetAddr := subnet.Ipv6Gateway.Mask(subnetMask)
+		return ipAddr.Mask(subnetMask).Equal(subnetAddr)
+	}
+	subnetMask := net.IPMask(subnet.IpMask)
+	subnetAddr := subnet.IpGateway.Mask(subnetMask)
+	return ipAddr.Mask(subnetMask).Equal(subnetAddr)
+}
+
 func getHypervisorSubnet() (proto.Subnet, error) {
 	defaultRoute, err := util.GetDefaultRoute()
 	if err != nil {
@@ -88,9 +103,7 @@ func (m *Manager) getBridgeForSubnet(subnet proto.Subnet) (
 func (m *Manager) getMatchingSubnet(ipAddr net.IP) string {
 	if len(ipAddr) > 0 {
 		for id, subnet := range m.subnets {
-			subnetMask := net.IPMask(subnet.IpMask)
-			subnetAddr := subnet.IpGateway.Mask(subnetMask)
-			if ipAddr.Mask(subnetMask).Equal(subnetAddr) {
+			if subnetContainsIP(subnet, ipAddr) {
 				return id
 			}
 		}
@@ -37,8 +37,8 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/json"
 	"github.com/Cloud-Foundations/Dominator/lib/lockwatcher"
 	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/log/fieldlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/log/filelogger"
-	"github.com/Cloud-Foundations/Dominator/lib/log/prefixlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/log/serverlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/mbr"
 	libnet "github.com/Cloud-Foundations/Dominator/lib/net"
@@ -50,6 +50,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/stringutil"
 	"github.com/Cloud-Foundations/Dominator/lib/tags"
 	"github.com/Cloud-Foundations/Dominator/lib/tags/tagmatcher"
+	"github.com/Cloud-Foundations/Dominator/lib/trace"
 	"github.com/Cloud-Foundations/Dominator/lib/url/urlutil"
 	"github.com/Cloud-Foundations/Dominator/lib/verstr"
 	"github.com/Cloud-Foundations/Dominator/lib/wsyscall"
@@ -541,7 +542,7 @@ func (m *Manager) allocateVm(req proto.CreateVmRequest,
 		manager:          m,
 		dirname:          filepath.Join(dirname, ipAddress),
 		ipAddress:        ipAddress,
-		logger:           prefixlogger.New(ipAddress+": ", m.Logger),
+		logger:           m.newVmLogger(ipAddress),
 		metadataChannels: make(map[chan<- string]struct{}),
 	}
 	if numEntries := len(req.NetworkEntries); numEntries > 0 {
@@ -910,7 +911,7 @@ func (m *Manager) changeVmSubnet(authInfo *srpc.AuthInformation,
 	vm.Address = address
 	vm.dirname = newDirname
 	vm.ipAddress = ipAddress
-	vm.logger = prefixlogger.New(ipAddress+": ", m.Logger)
+	vm.logger = m.newVmLogger(ipAddress)
 	vm.SubnetId = subnetId
 	if oldRootLabel == vm.rootLabel(false) {
 		vm.RootFileSystemLabel = "" // Restoring original (default) label.
@@ -1383,13 +1384,25 @@ func (m *Manager) copyVm(conn *srpc.Conn, request proto.CopyVmRequest) error {
 
 func (m *Manager) createVm(conn *srpc.Conn) error {
 
+	// Each progress update starts a new step span, ending the previous step.
+	var stepSpan *trace.Span
+	defer func() {
+		stepSpan.End() // Evaluate stepSpan at return time, not defer time.
+	}()
+
 	sendError := func(conn *srpc.Conn, err error) error {
 		m.Logger.Debugf(1, "CreateVm(%s) failed: %s\n", conn.Username(), err)
+		stepSpan.SetError(err)
+		conn.GetTraceSpan().SetError(err)
 		return conn.Encode(proto.CreateVmResponse{Error: err.Error()})
 	}
 
 	var ipAddressToSend net.IP
 	sendUpdate := func(conn *srpc.Conn, message string) error {
+		stepSpan.End()
+		stepSpan = trace.StartSpan("hypervisor.CreateVm.step",
+			conn.GetTraceSpan())
+		stepSpan.SetAttribute("message", message)
 		response := proto.CreateVmResponse{
 			IpAddress:       ipAddressToSend,
 			ProgressMessage: message,
@@ -1986,6 +1999,13 @@ func (m *Manager) getImage(searchName string, imageTimeout time.Duration) (
 			client.Close()
 		}
 	}()
+	if image.IsChannelReference(searchName) {
+		imageName, err := imclient.ResolveChannel(client, searchName)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		searchName = imageName
+	}
 	if isDir, err := imclient.CheckDirectory(client, searchName); err != nil {
 		return nil, nil, "", err
 	} else if isDir {
@@ -2469,7 +2489,7 @@ func (m *Manager) importLocalVm(authInfo *srpc.AuthInformation,
 		dirname:          filepath.Join(m.StateDir, "VMs", ipAddress),
 		ipAddress:        ipAddress,
 		ownerUsers:       map[string]struct{}{authInfo.Username: {}},
-		logger:           prefixlogger.New(ipAddress+": ", m.Logger),
+		logger:           m.newVmLogger(ipAddress),
 		metadataChannels: make(map[chan<- string]struct{}),
 	}
 	vm.VmInfo.State = proto.StateStarting
@@ -2678,7 +2698,7 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 		dirname:          filepath.Join(m.StateDir, "VMs", ipAddress),
 		doNotWriteOrSend: true,
 		ipAddress:        ipAddress,
-		logger:           prefixlogger.New(ipAddress+": ", m.Logger),
+		logger:           m.newVmLogger(ipAddress),
 		metadataChannels: make(map[chan<- string]struct{}),
 	}
 	vm.Uncommitted = true
@@ -2968,6 +2988,12 @@ func migrateVmVolume(hypervisor *srpc.Client, directory, filename string,
 	return nil
 }
 
+// newVmLogger returns a logger which attaches the IP address of the VM to each
+// log entry.
+func (m *Manager) newVmLogger(ipAddress string) log.DebugLogger {
+	return fieldlogger.New(m.Logger, "vmIP", ipAddress)
+}
+
 func (m *Manager) notifyVmMetadataRequest(ipAddr net.IP, path string) {
 	addr := ipAddr.String()
 	m.mutex.RLock()
@@ -3054,6 +3080,7 @@ func (m *Manager) patchVmImage(conn *srpc.Conn,
 		defer objectClient.Close()
 		objectsGetter = objectClient
 	} else if restart {
+		m.prefetchObjectDeltas(client, imageName, img.FileSystem)
 		hashes := make([]hash.Hash, 0, len(hashToInodesTable))
 		for hashVal := range hashToInodesTable {
 			hashes = append(hashes, hashVal)
@@ -3066,6 +3093,7 @@ func (m *Manager) patchVmImage(conn *srpc.Conn,
 		}
 		objectsGetter = m.objectCache
 	} else {
+		m.prefetchObjectDeltas(client, imageName, img.FileSystem)
 		objectsGetter = m.objectCache
 	}
 	bootInfo, err := util.GetBootInfoWithParams(img.FileSystem,
@@ -4128,6 +4156,7 @@ func (m *Manager) unpackImage(imageName, rootDir string,
 		defer objectClient.Close()
 		objectsGetter = objectClient
 	} else {
+		m.prefetchObjectDeltas(client, imageName, fs)
 		objectsGetter = m.objectCache
 	}
 	return util.Unpack(fs, objectsGetter, rootDir, logger)
@@ -4155,6 +4184,7 @@ func (m *Manager) writeRaw(volume proto.LocalVolume, extension string,
 		defer objectClient.Close()
 		objectsGetter = objectClient
 	} else {
+		m.prefetchObjectDeltas(client, writeRawOptions.InitialImageName, fs)
 		objectsGetter = m.objectCache
 	}
 	writeRawOptions.AllocateBlocks = true
@@ -4219,7 +4249,7 @@ func (vm *vmInfoType) changeIpAddress(ipAddress string) error {
 		}
 	}
 	vm.logger.Printf("changing to new address: %s\n", ipAddress)
-	vm.logger = prefixlogger.New(ipAddress+": ", vm.manager.Logger)
+	vm.logger = vm.manager.newVmLogger(ipAddress)
 	vm.writeInfo()
 	vm.manager.mutex.Lock()
 	defer vm.manager.mutex.Unlock()
@@ -4379,6 +4409,7 @@ func (vm *vmInfoType) delete() {
 		vm.lockWatcher.Stop()
 	}
 	vm.mutex.Lock()
+	fieldlogger.New(vm.logger).PrintWithFields("destroyed")
 	vm.logger.Debugln(2, "delete(): returning")
 }
 
@@ -4605,6 +4636,8 @@ func (vm *vmInfoType) serialManager() {
 
 func (vm *vmInfoType) setState(state proto.State) {
 	if state != vm.State {
+		fieldlogger.New(vm.logger).PrintWithFields("state changed",
+			"from", vm.State.String(), "to", state.String())
 		vm.ChangedStateOn = time.Now()
 		vm.State = state
 	}
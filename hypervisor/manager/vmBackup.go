@@ -0,0 +1,508 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	imageclient "github.com/Cloud-Foundations/Dominator/imageserver/client"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
+	objclient "github.com/Cloud-Foundations/Dominator/lib/objectserver/client"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+const (
+	backupBatchSize      = 16 // Number of chunks to check and send at a time.
+	backupChunkSize      = 4 << 20
+	backupSnapshotSuffix = "backup"
+)
+
+type backupObjectServer interface {
+	AddObject(reader io.Reader, length uint64, expectedHash *hash.Hash) (
+		hash.Hash, bool, error)
+	objectserver.ObjectsChecker
+}
+
+type backupQueue interface {
+	AddData(data []byte, hashVal hash.Hash) error
+	Close() error
+}
+
+// backupSnapshotType is a copy of the VM taken while mutations are blocked,
+// so that it may be sent while the VM is in use.
+type backupSnapshotType struct {
+	filenames []string // Copies of the volumes.
+	time      time.Time
+	userData  []byte
+	vmInfo    proto.VmInfo
+}
+
+// backupWriter sends chunks which the object server does not already have.
+type backupWriter struct {
+	chunkSize    uint64
+	objectServer backupObjectServer
+	queue        backupQueue
+	manifest     *proto.VmBackupManifest // Set by write.
+	manifestSize uint64                  // Set by write.
+	numBytes     uint64                  // Number of bytes sent.
+	numChunks    uint64                  // Number of chunks sent.
+}
+
+// copyVolumeForBackup copies size bytes of the source volume to a new sparse
+// file, skipping chunks which are all zero.
+func copyVolumeForBackup(destFilename, sourceFilename string,
+	size, chunkSize uint64) error {
+	sourceFile, err := os.Open(sourceFilename)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	if err := removeFile(destFilename); err != nil {
+		return err
+	}
+	destFile, err := os.OpenFile(destFilename,
+		os.O_CREATE|os.O_EXCL|os.O_WRONLY, fsutil.PrivateFilePerms)
+	if err != nil {
+		return err
+	}
+	doRemove := true
+	defer func() {
+		destFile.Close()
+		if doRemove {
+			os.Remove(destFilename)
+		}
+	}()
+	reader := io.LimitReader(sourceFile, int64(size))
+	chunk := make([]byte, chunkSize)
+	for offset := uint64(0); offset < size; offset += chunkSize {
+		length := size - offset
+		if length > chunkSize {
+			length = chunkSize
+		}
+		if _, err := io.ReadFull(reader, chunk[:length]); err != nil {
+			return fmt.Errorf("error reading: %s: %s", sourceFilename, err)
+		}
+		if isZero(chunk[:length]) {
+			continue
+		}
+		_, err := destFile.WriteAt(chunk[:length], int64(offset))
+		if err != nil {
+			return err
+		}
+	}
+	if err := destFile.Truncate(int64(size)); err != nil {
+		return err
+	}
+	if err := destFile.Close(); err != nil {
+		return err
+	}
+	doRemove = false
+	return nil
+}
+
+func isZero(data []byte) bool {
+	for _, value := range data {
+		if value != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// makeBackupImage returns an image which references the manifest and the
+// chunks, so that the image server does not garbage collect them. The chunks
+// are files named by their hashes.
+func makeBackupImage(manifest *proto.VmBackupManifest, manifestHash hash.Hash,
+	manifestSize uint64) *image.Image {
+	fs := &filesystem.FileSystem{
+		InodeTable: filesystem.InodeTable{
+			1: &filesystem.RegularInode{
+				Mode: syscall.S_IFREG | syscall.S_IRUSR,
+				Size: manifestSize,
+				Hash: manifestHash,
+			},
+		},
+		DirectoryInode: filesystem.DirectoryInode{
+			Mode: syscall.S_IFDIR | syscall.S_IRWXU,
+		},
+	}
+	chunksInode := &filesystem.DirectoryInode{
+		Mode: syscall.S_IFDIR | syscall.S_IRWXU,
+	}
+	fs.InodeTable[2] = chunksInode
+	chunks := make(map[hash.Hash]struct{})
+	for _, volume := range manifest.Volumes {
+		for index, hashVal := range volume.Chunks {
+			if hashVal == (hash.Hash{}) {
+				continue
+			}
+			if _, ok := chunks[hashVal]; ok {
+				continue
+			}
+			chunks[hashVal] = struct{}{}
+			size := volume.Size - uint64(index)*manifest.ChunkSize
+			if size > manifest.ChunkSize {
+				size = manifest.ChunkSize
+			}
+			inodeNumber := uint64(len(fs.InodeTable)) + 1
+			inode := &filesystem.RegularInode{
+				Mode: syscall.S_IFREG | syscall.S_IRUSR,
+				Size: size,
+				Hash: hashVal,
+			}
+			fs.InodeTable[inodeNumber] = inode
+			dirent := &filesystem.DirectoryEntry{
+				Name:        fmt.Sprintf("%x", hashVal),
+				InodeNumber: inodeNumber,
+			}
+			dirent.SetInode(inode)
+			chunksInode.EntryList = append(chunksInode.EntryList, dirent)
+		}
+	}
+	sort.Slice(chunksInode.EntryList, func(left, right int) bool {
+		return chunksInode.EntryList[left].Name <
+			chunksInode.EntryList[right].Name
+	})
+	chunksEntry := &filesystem.DirectoryEntry{Name: "chunks", InodeNumber: 2}
+	chunksEntry.SetInode(chunksInode)
+	manifestEntry := &filesystem.DirectoryEntry{
+		Name:        "manifest",
+		InodeNumber: 1,
+	}
+	manifestEntry.SetInode(fs.InodeTable[1])
+	fs.EntryList = []*filesystem.DirectoryEntry{chunksEntry, manifestEntry}
+	fs.DirectoryCount = 2
+	fs.ComputeTotalDataBytes()
+	return &image.Image{FileSystem: fs}
+}
+
+// backupVm blocks mutations only while the volumes are copied to a local
+// snapshot, which is then sent to the backup object server.
+func (m *Manager) backupVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	forceIfNotStopped bool) (hash.Hash, error) {
+	if m.BackupObjectServer == "" {
+		return hash.Hash{}, errors.New("no backup object server configured")
+	}
+	if m.BackupImageDirectory == "" {
+		return hash.Hash{}, errors.New("no backup image directory configured")
+	}
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	if vm.backupInProgress {
+		vm.mutex.Unlock()
+		return hash.Hash{}, errors.New("backup already in progress")
+	}
+	vm.backupInProgress = true
+	vm.blockMutations = true
+	vm.mutex.Unlock()
+	defer func() {
+		vm.mutex.Lock()
+		vm.backupInProgress = false
+		vm.mutex.Unlock()
+	}()
+	snapshot, err := vm.snapshotForBackup(forceIfNotStopped)
+	vm.allowMutationsAndUnlock(false)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	defer snapshot.discard(vm.logger)
+	imageName := path.Join(m.BackupImageDirectory, fmt.Sprintf("%s-%s",
+		ipAddr, snapshot.time.UTC().Format("20060102-150405")))
+	manifestHash, err := snapshot.send(m.BackupObjectServer, imageName,
+		vm.logger)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	if vm.State != proto.StateDestroying {
+		vm.LastBackup = &proto.VmBackup{
+			ImageName:    imageName,
+			ManifestHash: manifestHash,
+			Time:         snapshot.time,
+		}
+		vm.writeAndSendInfo()
+	}
+	return manifestHash, nil
+}
+
+// loopBackupVMs periodically backs up all stopped VMs (and running VMs if
+// BackupRunningVMs is true), one at a time. VMs which have not changed since
+// their last backup are skipped.
+func (m *Manager) loopBackupVMs() {
+	if m.BackupInterval <= 0 || m.BackupObjectServer == "" ||
+		m.BackupImageDirectory == "" {
+		return
+	}
+	authInfo := &srpc.AuthInformation{HaveMethodAccess: true}
+	for ; ; time.Sleep(m.BackupInterval) {
+		m.mutex.RLock()
+		ipAddrs := make([]net.IP, 0, len(m.vms))
+		for _, vm := range m.vms {
+			vm.mutex.RLock()
+			if !vm.Uncommitted && (m.BackupRunningVMs ||
+				vm.State == proto.StateStopped) && !vm.isBackupCurrent() {
+				ipAddrs = append(ipAddrs, vm.Address.IpAddress)
+			}
+			vm.mutex.RUnlock()
+		}
+		m.mutex.RUnlock()
+		for _, ipAddr := range ipAddrs {
+			_, err := m.backupVm(ipAddr, authInfo, m.BackupRunningVMs)
+			if err != nil {
+				m.Logger.Printf("error backing up VM: %s: %s\n", ipAddr, err)
+			}
+		}
+	}
+}
+
+// discard removes the copies of the volumes.
+func (s *backupSnapshotType) discard(logger log.Logger) {
+	for _, filename := range s.filenames {
+		if err := removeFile(filename); err != nil {
+			logger.Println(err)
+		}
+	}
+}
+
+// send writes the volumes and the manifest to the backup image server and adds
+// an image which references them, so that they are not garbage collected.
+func (s *backupSnapshotType) send(imageServer, imageName string,
+	logger log.DebugLogger) (hash.Hash, error) {
+	checkClient, err := srpc.DialHTTP("tcp", imageServer, time.Minute)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	defer checkClient.Close()
+	queueClient, err := srpc.DialHTTP("tcp", imageServer, time.Minute)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	defer queueClient.Close()
+	queue, err := objclient.NewObjectAdderQueue(queueClient)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	writer := &backupWriter{
+		chunkSize:    backupChunkSize,
+		objectServer: objclient.AttachObjectClient(checkClient),
+		queue:        queue,
+	}
+	startTime := time.Now()
+	manifestHash, err := writer.write(s)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	img := makeBackupImage(writer.manifest, manifestHash, writer.manifestSize)
+	if err := imageclient.AddImage(checkClient, imageName, img); err != nil {
+		return hash.Hash{}, fmt.Errorf("error adding image: %s: %s",
+			imageName, err)
+	}
+	logger.Debugf(0, "backed up to: %s (%x), sent %d chunks (%s) in %s\n",
+		imageName, manifestHash, writer.numChunks,
+		format.FormatBytes(writer.numBytes),
+		format.Duration(time.Since(startTime)))
+	return manifestHash, nil
+}
+
+// add will send the chunks (with corresponding hashes) which are not already
+// in the object server.
+func (w *backupWriter) add(chunks [][]byte, hashes []hash.Hash) error {
+	sizes, err := w.objectServer.CheckObjects(hashes)
+	if err != nil {
+		return err
+	}
+	if len(sizes) != len(hashes) {
+		return fmt.Errorf("CheckObjects: sent %d hashes, received %d sizes",
+			len(hashes), len(sizes))
+	}
+	for index, size := range sizes {
+		if size > 0 {
+			continue
+		}
+		if err := w.queue.AddData(chunks[index], hashes[index]); err != nil {
+			return err
+		}
+		w.numBytes += uint64(len(chunks[index]))
+		w.numChunks++
+	}
+	return nil
+}
+
+// backupVolume reads the volume in chunks and sends the chunks which are not
+// already in the object server. The list of chunk hashes is returned.
+func (w *backupWriter) backupVolume(filename string, size uint64) (
+	[]hash.Hash, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := io.LimitReader(file, int64(size))
+	numChunks := (size + w.chunkSize - 1) / w.chunkSize
+	chunkHashes := make([]hash.Hash, 0, numChunks)
+	var batchChunks [][]byte
+	var batchHashes []hash.Hash
+	for offset := uint64(0); offset < size; offset += w.chunkSize {
+		length := size - offset
+		if length > w.chunkSize {
+			length = w.chunkSize
+		}
+		chunk := make([]byte, length)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, fmt.Errorf("error reading: %s: %s", filename, err)
+		}
+		if isZero(chunk) {
+			chunkHashes = append(chunkHashes, hash.Hash{})
+			continue
+		}
+		hashVal := hash.Hash(sha512.Sum512(chunk))
+		chunkHashes = append(chunkHashes, hashVal)
+		batchChunks = append(batchChunks, chunk)
+		batchHashes = append(batchHashes, hashVal)
+		if len(batchChunks) >= backupBatchSize {
+			if err := w.add(batchChunks, batchHashes); err != nil {
+				return nil, err
+			}
+			batchChunks = nil
+			batchHashes = nil
+		}
+	}
+	if len(batchChunks) > 0 {
+		if err := w.add(batchChunks, batchHashes); err != nil {
+			return nil, err
+		}
+	}
+	return chunkHashes, nil
+}
+
+// write sends the volumes in the snapshot and then the manifest, returning the
+// hash of the manifest. The queue is closed. The manifest and its size are
+// recorded in the writer.
+func (w *backupWriter) write(snapshot *backupSnapshotType) (hash.Hash, error) {
+	manifest := proto.VmBackupManifest{
+		ChunkSize: w.chunkSize,
+		Time:      snapshot.time,
+		UserData:  snapshot.userData,
+		VmInfo:    snapshot.vmInfo,
+		Volumes:   make([]proto.VmBackupVolume, 0, len(snapshot.filenames)),
+	}
+	for index, filename := range snapshot.filenames {
+		size := snapshot.vmInfo.Volumes[index].Size
+		chunks, err := w.backupVolume(filename, size)
+		if err != nil {
+			w.queue.Close()
+			return hash.Hash{}, err
+		}
+		manifest.Volumes = append(manifest.Volumes, proto.VmBackupVolume{
+			Chunks: chunks,
+			Size:   size,
+		})
+	}
+	if err := w.queue.Close(); err != nil {
+		return hash.Hash{}, err
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	manifestHash, _, err := w.objectServer.AddObject(bytes.NewReader(data),
+		uint64(len(data)), nil)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	w.manifest = &manifest
+	w.manifestSize = uint64(len(data))
+	return manifestHash, nil
+}
+
+// isBackupCurrent returns true if the VM has not changed state and the volumes
+// have not been modified since the last backup. The VM must be locked.
+func (vm *vmInfoType) isBackupCurrent() bool {
+	if vm.LastBackup == nil || !vm.LastBackup.Time.After(vm.ChangedStateOn) {
+		return false
+	}
+	for _, volume := range vm.VolumeLocations {
+		fi, err := os.Stat(volume.Filename)
+		if err != nil || !fi.ModTime().Before(vm.LastBackup.Time) {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshotForBackup copies the volumes and the VM information so that they
+// may be sent after mutations are allowed again. The VM must not be locked and
+// mutations must be blocked.
+func (vm *vmInfoType) snapshotForBackup(forceIfNotStopped bool) (
+	*backupSnapshotType, error) {
+	if vm.getActiveInitrdPath() != "" {
+		return nil, errors.New("cannot backup root volume with separate initrd")
+	}
+	if vm.getActiveKernelPath() != "" {
+		return nil, errors.New("cannot backup root volume with separate kernel")
+	}
+	vm.mutex.RLock()
+	state := vm.State
+	volumeLocations := make([]proto.LocalVolume, len(vm.VolumeLocations))
+	copy(volumeLocations, vm.VolumeLocations)
+	vmInfoData, err := json.Marshal(vm.VmInfo)
+	vm.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if state != proto.StateStopped && !forceIfNotStopped {
+		return nil, errors.New("VM is not stopped")
+	}
+	snapshot := &backupSnapshotType{time: time.Now()}
+	if err := json.Unmarshal(vmInfoData, &snapshot.vmInfo); err != nil {
+		return nil, err
+	}
+	userData, err := os.ReadFile(filepath.Join(vm.dirname, UserDataFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	snapshot.userData = userData
+	allocationTable := vm.manager.calculateStorageAllocations()
+	capacities, err := vm.manager.getCapacities()
+	if err != nil {
+		return nil, err
+	}
+	for index, volume := range snapshot.vmInfo.Volumes {
+		err := vm.manager.checkFreeSpaceForVolume(volumeLocations[index],
+			capacities, allocationTable, volume.Size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for index, volume := range volumeLocations {
+		filename := volume.Filename + "." + backupSnapshotSuffix
+		snapshot.filenames = append(snapshot.filenames, filename)
+		err := copyVolumeForBackup(filename, volume.Filename,
+			snapshot.vmInfo.Volumes[index].Size, backupChunkSize)
+		if err != nil {
+			snapshot.discard(vm.logger)
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,503 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	hyperclient "github.com/Cloud-Foundations/Dominator/hypervisor/client"
+	"github.com/Cloud-Foundations/Dominator/imageserver/scanner"
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/debuglogger"
+	"github.com/Cloud-Foundations/Dominator/lib/log/nulllogger"
+	objectserver "github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/memory"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+const testChunkSize = 16
+
+type testQueue struct {
+	closed       bool
+	objectServer backupObjectServer
+}
+
+func (q *testQueue) AddData(data []byte, hashVal hash.Hash) error {
+	_, _, err := q.objectServer.AddObject(bytes.NewReader(data),
+		uint64(len(data)), &hashVal)
+	return err
+}
+
+func (q *testQueue) Close() error {
+	q.closed = true
+	return nil
+}
+
+func writeTestFile(t *testing.T, filename string, data []byte) {
+	if err := os.WriteFile(filename, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupVolumeChunking(t *testing.T) {
+	data := bytes.Join([][]byte{
+		bytes.Repeat([]byte{1}, testChunkSize),
+		bytes.Repeat([]byte{2}, testChunkSize),
+		make([]byte, testChunkSize),
+		bytes.Repeat([]byte{3}, testChunkSize),
+		bytes.Repeat([]byte{4}, 5),
+	}, nil)
+	filename := filepath.Join(t.TempDir(), "volume")
+	writeTestFile(t, filename, data)
+	objectServer := memory.NewObjectServer()
+	writer := &backupWriter{
+		chunkSize:    testChunkSize,
+		objectServer: objectServer,
+		queue:        &testQueue{objectServer: objectServer},
+	}
+	hashes, err := writer.backupVolume(filename, uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 5 {
+		t.Fatalf("expected 5 chunks, got: %d", len(hashes))
+	}
+	for index, hashVal := range hashes {
+		start := index * testChunkSize
+		end := start + testChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		expected := hash.Hash(sha512.Sum512(data[start:end]))
+		if index == 2 {
+			expected = hash.Hash{}
+		}
+		if hashVal != expected {
+			t.Errorf("chunk: %d: expected: %x, got: %x",
+				index, expected, hashVal)
+		}
+	}
+	if writer.numChunks != 4 {
+		t.Errorf("expected 4 chunks sent, got: %d", writer.numChunks)
+	}
+	if expected := uint64(3*testChunkSize + 5); writer.numBytes != expected {
+		t.Errorf("expected %d bytes sent, got: %d", expected, writer.numBytes)
+	}
+	sizes, err := objectServer.CheckObjects(hashes[3:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes[0] != testChunkSize || sizes[1] != 5 {
+		t.Errorf("unexpected stored chunk sizes: %v", sizes)
+	}
+	// Only part of the file is backed up.
+	hashes, err = writer.backupVolume(filename, testChunkSize+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 2 {
+		t.Errorf("expected 2 chunks, got: %d", len(hashes))
+	}
+	_, err = writer.backupVolume(filename, uint64(len(data)+1))
+	if err == nil {
+		t.Error("no error backing up past end of file")
+	}
+}
+
+func TestBackupVolumeBatches(t *testing.T) {
+	numChunks := backupBatchSize*2 + 3
+	data := make([]byte, 0, numChunks*testChunkSize)
+	for value := 1; value <= numChunks; value++ {
+		data = append(data, bytes.Repeat([]byte{byte(value)}, testChunkSize)...)
+	}
+	filename := filepath.Join(t.TempDir(), "volume")
+	writeTestFile(t, filename, data)
+	objectServer := memory.NewObjectServer()
+	writer := &backupWriter{
+		chunkSize:    testChunkSize,
+		objectServer: objectServer,
+		queue:        &testQueue{objectServer: objectServer},
+	}
+	hashes, err := writer.backupVolume(filename, uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != numChunks {
+		t.Errorf("expected %d chunks, got: %d", numChunks, len(hashes))
+	}
+	if num := objectServer.NumObjects(); num != uint64(numChunks) {
+		t.Errorf("expected %d objects, got: %d", numChunks, num)
+	}
+}
+
+func TestBackupVolumeZeroChunks(t *testing.T) {
+	data := make([]byte, 3*testChunkSize+7)
+	filename := filepath.Join(t.TempDir(), "volume")
+	writeTestFile(t, filename, data)
+	objectServer := memory.NewObjectServer()
+	writer := &backupWriter{
+		chunkSize:    testChunkSize,
+		objectServer: objectServer,
+		queue:        &testQueue{objectServer: objectServer},
+	}
+	hashes, err := writer.backupVolume(filename, uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 4 {
+		t.Fatalf("expected 4 chunks, got: %d", len(hashes))
+	}
+	for index, hashVal := range hashes {
+		if hashVal != (hash.Hash{}) {
+			t.Errorf("chunk: %d: expected zero hash, got: %x", index, hashVal)
+		}
+	}
+	if writer.numChunks != 0 || writer.numBytes != 0 {
+		t.Errorf("sent %d chunks (%d bytes) for zero volume",
+			writer.numChunks, writer.numBytes)
+	}
+	if num := objectServer.NumObjects(); num != 0 {
+		t.Errorf("%d objects stored for zero volume", num)
+	}
+}
+
+func TestBackupVolumeIncremental(t *testing.T) {
+	data := bytes.Join([][]byte{
+		bytes.Repeat([]byte{1}, testChunkSize),
+		bytes.Repeat([]byte{2}, testChunkSize),
+		bytes.Repeat([]byte{3}, testChunkSize),
+		bytes.Repeat([]byte{4}, testChunkSize),
+	}, nil)
+	filename := filepath.Join(t.TempDir(), "volume")
+	objectServer := memory.NewObjectServer()
+	tests := []struct {
+		name      string
+		change    []byte // Written over the second chunk.
+		numChunks uint64
+	}{
+		{"first backup", nil, 4},
+		{"unchanged volume", nil, 0},
+		{"changed volume", bytes.Repeat([]byte{5}, testChunkSize), 1},
+	}
+	for _, test := range tests {
+		copy(data[testChunkSize:], test.change)
+		writeTestFile(t, filename, data)
+		writer := &backupWriter{
+			chunkSize:    testChunkSize,
+			objectServer: objectServer,
+			queue:        &testQueue{objectServer: objectServer},
+		}
+		_, err := writer.backupVolume(filename, uint64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if writer.numChunks != test.numChunks {
+			t.Errorf("%s: expected %d chunks sent, got: %d",
+				test.name, test.numChunks, writer.numChunks)
+		}
+	}
+}
+
+func TestCopyVolumeForBackup(t *testing.T) {
+	dirname := t.TempDir()
+	data := bytes.Join([][]byte{
+		bytes.Repeat([]byte{1}, testChunkSize),
+		make([]byte, 2*testChunkSize),
+		bytes.Repeat([]byte{2}, testChunkSize),
+		bytes.Repeat([]byte{3}, 3),
+	}, nil)
+	sourceFilename := filepath.Join(dirname, "volume")
+	writeTestFile(t, sourceFilename, append(data, 9, 9))
+	destFilename := sourceFilename + "." + backupSnapshotSuffix
+	writeTestFile(t, destFilename, []byte("stale"))
+	err := copyVolumeForBackup(destFilename, sourceFilename,
+		uint64(len(data)), testChunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := os.ReadFile(destFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(copied, data) {
+		t.Errorf("copy: expected: %v, got: %v", data, copied)
+	}
+	err = copyVolumeForBackup(destFilename, sourceFilename,
+		uint64(len(data)+3), testChunkSize)
+	if err == nil {
+		t.Error("no error copying past end of file")
+	}
+	if _, err := os.Stat(destFilename); !os.IsNotExist(err) {
+		t.Error("partial copy not removed")
+	}
+}
+
+func TestBackupManifestRoundTrip(t *testing.T) {
+	dirname := t.TempDir()
+	volumesData := [][]byte{
+		bytes.Join([][]byte{
+			bytes.Repeat([]byte{1}, testChunkSize),
+			make([]byte, testChunkSize),
+			bytes.Repeat([]byte{2}, testChunkSize),
+			bytes.Repeat([]byte{1}, testChunkSize),
+			bytes.Repeat([]byte{3}, 9),
+		}, nil),
+		append(make([]byte, 2*testChunkSize),
+			bytes.Repeat([]byte{4}, testChunkSize)...),
+		make([]byte, 2*testChunkSize+1),
+	}
+	snapshot := &backupSnapshotType{
+		time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		userData: []byte("user data"),
+		vmInfo:   proto.VmInfo{Hostname: "vm0", MemoryInMiB: 1024},
+	}
+	for index, data := range volumesData {
+		filename := filepath.Join(dirname, fmt.Sprintf("volume%d", index))
+		writeTestFile(t, filename, data)
+		snapshot.filenames = append(snapshot.filenames, filename)
+		snapshot.vmInfo.Volumes = append(snapshot.vmInfo.Volumes,
+			proto.Volume{Size: uint64(len(data))})
+	}
+	objectServer := memory.NewObjectServer()
+	writer := &backupWriter{
+		chunkSize:    testChunkSize,
+		objectServer: objectServer,
+		queue:        &testQueue{objectServer: objectServer},
+	}
+	manifestHash, err := writer.write(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !writer.queue.(*testQueue).closed {
+		t.Error("queue not closed")
+	}
+	manifest, err := hyperclient.ReadVmBackupManifest(objectServer,
+		manifestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ChunkSize != testChunkSize {
+		t.Errorf("expected chunk size: %d, got: %d",
+			testChunkSize, manifest.ChunkSize)
+	}
+	if !manifest.Time.Equal(snapshot.time) {
+		t.Errorf("expected time: %s, got: %s", snapshot.time, manifest.Time)
+	}
+	if string(manifest.UserData) != "user data" {
+		t.Errorf("expected user data: \"user data\", got: \"%s\"",
+			manifest.UserData)
+	}
+	if manifest.VmInfo.Hostname != "vm0" ||
+		manifest.VmInfo.MemoryInMiB != 1024 {
+		t.Errorf("VM information not preserved: %+v", manifest.VmInfo)
+	}
+	if len(manifest.Volumes) != len(volumesData) {
+		t.Fatalf("expected %d volumes, got: %d",
+			len(volumesData), len(manifest.Volumes))
+	}
+	for index, data := range volumesData {
+		var buffer bytes.Buffer
+		err := hyperclient.WriteVmBackupVolume(&buffer, objectServer,
+			manifest, uint(index))
+		if err != nil {
+			t.Fatalf("volume: %d: %s", index, err)
+		}
+		if !bytes.Equal(buffer.Bytes(), data) {
+			t.Errorf("volume: %d: expected: %v, got: %v",
+				index, data, buffer.Bytes())
+		}
+	}
+	err = hyperclient.WriteVmBackupVolume(&bytes.Buffer{}, objectServer,
+		manifest, uint(len(volumesData)))
+	if err == nil {
+		t.Error("no error writing missing volume")
+	}
+	manifest.Volumes[0].Size += testChunkSize
+	err = hyperclient.WriteVmBackupVolume(&bytes.Buffer{}, objectServer,
+		manifest, 0)
+	if err == nil {
+		t.Error("no error writing volume with missing chunks")
+	}
+	manifest.Volumes[0].Size -= testChunkSize + 1
+	err = hyperclient.WriteVmBackupVolume(&bytes.Buffer{}, objectServer,
+		manifest, 0)
+	if err == nil {
+		t.Error("no error writing volume with short chunk")
+	}
+}
+
+// TestBackupImageKeepsObjects checks that the objects referenced by a backup
+// image survive retention policies and deletion of unreferenced objects.
+func TestBackupImageKeepsObjects(t *testing.T) {
+	dirname := t.TempDir()
+	// The object server and image database keep running after the test, so
+	// they must not log to the test.
+	objSrv, err := objectserver.NewObjectServer(t.TempDir(),
+		nulllogger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(make([]byte, testChunkSize),
+		bytes.Repeat([]byte{1}, testChunkSize)...)
+	data = append(data, bytes.Repeat([]byte{2}, 5)...)
+	filename := filepath.Join(dirname, "volume")
+	writeTestFile(t, filename, data)
+	writer := &backupWriter{
+		chunkSize:    testChunkSize,
+		objectServer: objSrv,
+		queue:        &testQueue{objectServer: objSrv},
+	}
+	manifestHash, err := writer.write(&backupSnapshotType{
+		filenames: []string{filename},
+		time:      time.Now(),
+		vmInfo: proto.VmInfo{
+			Volumes: []proto.Volume{{Size: uint64(len(data))}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupHashes := []hash.Hash{manifestHash}
+	for _, hashVal := range writer.manifest.Volumes[0].Chunks {
+		if hashVal != (hash.Hash{}) {
+			backupHashes = append(backupHashes, hashVal)
+		}
+	}
+	imdb, err := scanner.Load(
+		scanner.Config{
+			BaseDirectory:          t.TempDir(),
+			RetentionCheckInterval: 10 * time.Millisecond,
+			RetentionEnforce:       true,
+		},
+		scanner.Params{
+			Logger:       debuglogger.Upgrade(nulllogger.New()),
+			ObjectServer: objSrv,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authInfo := &srpc.AuthInformation{HaveMethodAccess: true}
+	for _, dirname := range []string{"backups", "stream"} {
+		if err := imdb.MakeDirectory(dirname, authInfo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = imdb.AddImage(
+		makeBackupImage(writer.manifest, manifestHash, writer.manifestSize),
+		"backups/10.0.0.2-20240102-030405", authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The old image in the stream shares a chunk with the backup and has an
+	// object of its own.
+	ownHash, _, err := objSrv.AddObject(bytes.NewReader([]byte("old")), 3,
+		nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharedHash := backupHashes[1]
+	now := time.Now()
+	err = imdb.AddImage(&image.Image{
+		BuildLog:     &image.Annotation{Object: &ownHash},
+		CreatedOn:    now.Add(-time.Hour),
+		FileSystem:   &filesystem.FileSystem{},
+		ReleaseNotes: &image.Annotation{Object: &sharedHash},
+	}, "stream/old", authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = imdb.AddImage(&image.Image{
+		CreatedOn:  now,
+		FileSystem: &filesystem.FileSystem{},
+	}, "stream/new", authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = imdb.SetRetentionPolicy("stream",
+		&image.RetentionPolicy{KeepLatest: 1}, authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for timeout := time.Now().Add(5 * time.Second); imdb.CheckImage(
+		"stream/old"); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(timeout) {
+			t.Fatal("old image not deleted by retention policy")
+		}
+	}
+	if err := imdb.DeleteUnreferencedObjects(100, 0); err != nil {
+		t.Fatal(err)
+	}
+	sizes, err := objSrv.CheckObjects(append(backupHashes, ownHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, hashVal := range backupHashes {
+		if sizes[index] < 1 {
+			t.Errorf("backup object: %x deleted", hashVal)
+		}
+	}
+	if sizes[len(backupHashes)] > 0 {
+		t.Error("unreferenced object not deleted")
+	}
+	if !imdb.CheckImage("backups/10.0.0.2-20240102-030405") {
+		t.Error("backup image deleted")
+	}
+}
+
+func TestIsBackupCurrent(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "volume")
+	writeTestFile(t, filename, nil)
+	now := time.Now()
+	if err := os.Chtimes(filename, now, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		changedStateOn time.Time
+		lastBackup     *proto.VmBackup
+		expected       bool
+	}{
+		{
+			name:           "never backed up",
+			changedStateOn: now.Add(-2 * time.Hour),
+		},
+		{
+			name:           "stopped after backup",
+			changedStateOn: now.Add(-2 * time.Minute),
+			lastBackup:     &proto.VmBackup{Time: now.Add(-3 * time.Minute)},
+		},
+		{
+			name:           "volume modified after backup",
+			changedStateOn: now.Add(-2 * time.Hour),
+			lastBackup:     &proto.VmBackup{Time: now.Add(-90 * time.Minute)},
+		},
+		{
+			name:           "unchanged",
+			changedStateOn: now.Add(-2 * time.Hour),
+			lastBackup:     &proto.VmBackup{Time: now.Add(-time.Minute)},
+			expected:       true,
+		},
+	}
+	for _, test := range tests {
+		vm := &vmInfoType{
+			LocalVmInfo: proto.LocalVmInfo{
+				VmInfo: proto.VmInfo{
+					ChangedStateOn: test.changedStateOn,
+					LastBackup:     test.lastBackup,
+				},
+				VolumeLocations: []proto.LocalVolume{{Filename: filename}},
+			},
+		}
+		if got := vm.isBackupCurrent(); got != test.expected {
+			t.Errorf("%s: expected: %v, got: %v",
+				test.name, test.expected, got)
+		}
+	}
+}
@@ -110,7 +110,7 @@ func requestCertificate(httpClient *http.Client, pubkey []byte,
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf(resp.Status)
+		return nil, fmt.Errorf("%s", resp.Status)
 	}
 	return io.ReadAll(resp.Body)
 }
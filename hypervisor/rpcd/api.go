@@ -70,6 +70,7 @@ func Setup(config Config, params Params) (*htmlWriter, error) {
 	publicMethods := []string{
 		"AcknowledgeVm",
 		"AddVmVolumes",
+		"BackupVm",
 		"BecomePrimaryVmOwner",
 		"ChangeVmConsoleType",
 		"ChangeVmCpuPriority",
@@ -147,7 +148,29 @@ func Setup(config Config, params Params) (*htmlWriter, error) {
 		}
 	}
 	srpc.RegisterNameWithOptions("Hypervisor", srpcObj, srpc.ReceiverOptions{
-		PublicMethods:          publicMethods,
+		PublicMethods: publicMethods,
+		ReadOnlyMethods: []string{
+			"GetCapacity",
+			"GetIdentityProvider",
+			"GetPublicKey",
+			"GetRootCookiePath",
+			"GetUpdates",
+			"GetVmCreateRequest",
+			"GetVmInfo",
+			"GetVmInfos",
+			"GetVmLastPatchLog",
+			"GetVmUserData",
+			"GetVmVirtualiserLogFile",
+			"GetVmVolume",
+			"GetVmVolumeStorageConfiguration",
+			"ListSubnets",
+			"ListVMs",
+			"ListVmVirtualiserLogFiles",
+			"ListVolumeDirectories",
+			"ProbeVmPort",
+			"TraceVmMetadata",
+			"WatchDhcp",
+		},
 		UnauthenticatedMethods: unauthenticatedMethods,
 	})
 	return (*htmlWriter)(srpcObj), nil
@@ -0,0 +1,19 @@
+package rpcd
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) BackupVm(conn *srpc.Conn,
+	request hypervisor.BackupVmRequest,
+	reply *hypervisor.BackupVmResponse) error {
+	manifestHash, err := t.manager.BackupVm(request.IpAddress,
+		conn.GetAuthInformation(), request.ForceIfNotStopped)
+	*reply = hypervisor.BackupVmResponse{
+		Error:        errors.ErrorToString(err),
+		ManifestHash: manifestHash,
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package tftpbootd
 
 import (
 	"net"
+	"net/http"
 	"sync"
 	"time"
 
@@ -39,6 +40,12 @@ func (s *TftpbootServer) RegisterFiles(ipAddr net.IP, files map[string][]byte) {
 	s.registerFiles(ipAddr, files)
 }
 
+// ServeHTTP serves the files which are available over TFTP. The URL path
+// is the TFTP filename, optionally prefixed with "/tftpboot".
+func (s *TftpbootServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.serveHTTP(w, req)
+}
+
 func (s *TftpbootServer) SetImageStreamName(name string) {
 	s.setImageStreamName(name)
 }
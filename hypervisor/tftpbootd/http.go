@@ -0,0 +1,76 @@
+package tftpbootd
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/log/prefixlogger"
+)
+
+type httpSender struct {
+	sentHeader bool
+	writer     http.ResponseWriter
+}
+
+func (sender *httpSender) send(size uint64, reader io.Reader) (uint64, error) {
+	sender.writer.Header().Set("Content-Type", "application/octet-stream")
+	sender.writer.Header().Set("Content-Length",
+		strconv.FormatUint(size, 10))
+	sender.writer.WriteHeader(http.StatusOK)
+	sender.sentHeader = true
+	nCopied, err := io.Copy(sender.writer, reader)
+	return uint64(nCopied), err
+}
+
+// serveHTTP serves the same files as the TFTP server, so that UEFI HTTP Boot
+// and iPXE clients can fetch boot images, kernels, initrds and per-machine
+// configuration over HTTP.
+func (s *TftpbootServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	rAddr, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ip := net.ParseIP(rAddr); ip != nil {
+		rAddr = ip.String()
+	}
+	filename := cleanPath(req.URL.Path)
+	logger := prefixlogger.New("httpboot("+rAddr+":"+filename+"): ",
+		s.logger)
+	logger.Debugln(1, "received request")
+	sender := &httpSender{writer: w}
+	startTime := time.Now()
+	err = s.readHandlerInternal(filename, rAddr,
+		func(size uint64, reader io.Reader) error {
+			nSent, err := sender.send(size, reader)
+			if err != nil {
+				return err
+			}
+			timeTaken := time.Since(startTime)
+			speed := uint64(float64(nSent) / timeTaken.Seconds())
+			logger.Printf("%d bytes sent in %s (%s/s)\n",
+				nSent, format.Duration(timeTaken), format.FormatBytes(speed))
+			return nil
+		})
+	if err == nil {
+		return
+	}
+	logger.Println(err)
+	if sender.sentHeader {
+		return
+	}
+	if os.IsNotExist(err) {
+		http.NotFound(w, req)
+	} else {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,123 @@
+package tftpbootd
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+)
+
+func TestServeHTTP(t *testing.T) {
+	s := &TftpbootServer{
+		filesForIPs: make(map[string]map[string][]byte),
+		logger:      testlogger.New(t),
+	}
+	s.registerFiles(net.IPv4(10, 0, 0, 2), map[string][]byte{
+		"boot.ipxe":      []byte("#!ipxe\n"),
+		"/config/10-0-0": []byte("config"),
+	})
+	s.registerFiles(net.IPv4(10, 0, 0, 3), map[string][]byte{
+		"other": []byte("other"),
+	})
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		remoteAddr   string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "prefixed",
+			method:       http.MethodGet,
+			path:         "/tftpboot/boot.ipxe",
+			remoteAddr:   "10.0.0.2:1234",
+			expectedCode: http.StatusOK,
+			expectedBody: "#!ipxe\n",
+		},
+		{
+			name:         "not prefixed",
+			method:       http.MethodGet,
+			path:         "/boot.ipxe",
+			remoteAddr:   "10.0.0.2:1234",
+			expectedCode: http.StatusOK,
+			expectedBody: "#!ipxe\n",
+		},
+		{
+			name:         "subdirectory",
+			method:       http.MethodGet,
+			path:         "/tftpboot/config/10-0-0",
+			remoteAddr:   "10.0.0.2:1234",
+			expectedCode: http.StatusOK,
+			expectedBody: "config",
+		},
+		{
+			name:         "HEAD",
+			method:       http.MethodHead,
+			path:         "/tftpboot/boot.ipxe",
+			remoteAddr:   "10.0.0.2:1234",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "registered for other IP",
+			method:       http.MethodGet,
+			path:         "/tftpboot/other",
+			remoteAddr:   "10.0.0.2:1234",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "unregistered IP",
+			method:       http.MethodGet,
+			path:         "/tftpboot/boot.ipxe",
+			remoteAddr:   "10.0.0.4:1234",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "missing",
+			method:       http.MethodGet,
+			path:         "/tftpboot/missing",
+			remoteAddr:   "10.0.0.2:1234",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "POST",
+			method:       http.MethodPost,
+			path:         "/tftpboot/boot.ipxe",
+			remoteAddr:   "10.0.0.2:1234",
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "bad remote address",
+			method:       http.MethodGet,
+			path:         "/tftpboot/boot.ipxe",
+			remoteAddr:   "10.0.0.2",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		req.RemoteAddr = test.remoteAddr
+		recorder := httptest.NewRecorder()
+		s.serveHTTP(recorder, req)
+		if recorder.Code != test.expectedCode {
+			t.Errorf("%s: expected: %d, got: %d",
+				test.name, test.expectedCode, recorder.Code)
+			continue
+		}
+		if test.expectedBody == "" {
+			continue
+		}
+		if body := recorder.Body.String(); body != test.expectedBody {
+			t.Errorf("%s: expected: \"%s\", got: \"%s\"",
+				test.name, test.expectedBody, body)
+		}
+		length := strconv.Itoa(len(test.expectedBody))
+		if got := recorder.Header().Get("Content-Length"); got != length {
+			t.Errorf("%s: expected Content-Length: %s, got: %s",
+				test.name, length, got)
+		}
+	}
+}
@@ -166,25 +166,35 @@ func (s *TftpbootServer) readHandler(filename string, rf io.ReaderFrom) error {
 	rAddr := rf.(tftp.OutgoingTransfer).RemoteAddr().IP.String()
 	logger := prefixlogger.New("tftpd("+rAddr+":"+filename+"): ", s.logger)
 	logger.Debugln(1, "received request")
-	if err := s.readHandlerInternal(filename, rf, rAddr, logger); err != nil {
+	err := s.readHandlerInternal(filename, rAddr,
+		func(size uint64, reader io.Reader) error {
+			rf.(tftp.OutgoingTransfer).SetSize(int64(size))
+			return readHandler(rf, reader, logger)
+		})
+	if err != nil {
 		logger.Println(err)
 		return err
 	}
 	return nil
 }
 
-func (s *TftpbootServer) readHandlerInternal(filename string, rf io.ReaderFrom,
-	remoteAddr string, logger log.DebugLogger) error {
+// readHandlerInternal finds the file for the remote address, either in the
+// registered files or in the latest image in the image stream, and calls
+// sender with the file size and contents.
+func (s *TftpbootServer) readHandlerInternal(filename string,
+	remoteAddr string, sender func(size uint64, reader io.Reader) error) error {
 	s.lock.Lock()
 	if files, ok := s.filesForIPs[remoteAddr]; ok {
 		if data, ok := files[filename]; ok {
 			s.lock.Unlock()
-			rf.(tftp.OutgoingTransfer).SetSize(int64(len(data)))
-			return readHandler(rf, bytes.NewReader(data), logger)
+			return sender(uint64(len(data)), bytes.NewReader(data))
 		}
 	}
 	imageStreamName := s.imageStreamName
 	s.lock.Unlock()
+	if imageStreamName == "" {
+		return os.ErrNotExist
+	}
 	client := s.getImageServerClient()
 	defer s.releaseImageServerClient()
 	fs, err := s.getFileSystem(imageStreamName, client)
@@ -206,8 +216,7 @@ func (s *TftpbootServer) readHandlerInternal(filename string, rf io.ReaderFrom,
 			return err
 		} else {
 			defer reader.Close()
-			rf.(tftp.OutgoingTransfer).SetSize(int64(size))
-			return readHandler(rf, reader, logger)
+			return sender(size, reader)
 		}
 	}
 }
@@ -19,6 +19,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/sshutil"
 	"github.com/Cloud-Foundations/Dominator/lib/tags"
+	"github.com/Cloud-Foundations/Dominator/lib/trace"
 	"github.com/Cloud-Foundations/Dominator/lib/triggers"
 	"github.com/Cloud-Foundations/Dominator/lib/types"
 	proto "github.com/Cloud-Foundations/Dominator/proto/imaginator"
@@ -65,12 +66,18 @@ type bootstrapStream struct {
 	PackagerType     string
 }
 
+type buildCacheConfigurationType struct {
+	ImageDirectory string // Post-install trees are stored below here.
+	MaximumAge     uint   // Seconds. Default: 1 day.
+}
+
 type buildResultType struct {
-	imageName  string
-	startTime  time.Time
-	finishTime time.Time
-	buildLog   []byte
-	error      error
+	imageName        string
+	startTime        time.Time
+	finishTime       time.Time
+	buildLog         []byte
+	error            error
+	buildCacheStatus string
 }
 
 type cacheConfigurationType struct {
@@ -80,9 +87,10 @@ type cacheConfigurationType struct {
 }
 
 type currentBuildInfo struct {
-	buffer       *bytes.Buffer
-	slaveAddress string
-	startedAt    time.Time
+	buffer           *bytes.Buffer
+	buildCacheStatus string
+	slaveAddress     string
+	startedAt        time.Time
 }
 
 type dependencyDataType struct {
@@ -145,6 +153,7 @@ type manifestConfigType struct {
 type masterConfigurationType struct {
 	BindMounts                []string                      `json:",omitempty"`
 	BootstrapStreams          map[string]*bootstrapStream   `json:",omitempty"`
+	BuildCache                buildCacheConfigurationType   `json:",omitempty"`
 	Cache                     cacheConfigurationType        `json:",omitempty"`
 	ImageStreamsCheckInterval uint                          `json:",omitempty"`
 	ImageStreamsToAutoRebuild []string                      `json:",omitempty"`
@@ -227,6 +236,7 @@ type Builder struct {
 	autoRebuildTrigger          chan<- chan<- struct{}
 	buildLogArchiver            logarchiver.BuildLogArchiver
 	bindMounts                  []string
+	buildCache                  buildCacheConfigurationType
 	cache                       cacheConfigurationType
 	createSlaveTimeout          time.Duration
 	disableLock                 sync.RWMutex
@@ -257,6 +267,8 @@ type Builder struct {
 	buildResultsLock            sync.RWMutex
 	currentBuildInfos           map[string]*currentBuildInfo // Key: stream name.
 	lastBuildResults            map[string]buildResultType   // Key: stream name.
+	numBuildCacheHits           uint64
+	numBuildCacheMisses         uint64
 	packagerTypes               map[string]packagerType
 	dependencyDataLock          sync.RWMutex
 	dependencyData              *dependencyDataType
@@ -318,7 +330,15 @@ func LoadWithOptionsAndParams(options BuilderOptions,
 func (b *Builder) BuildImage(request proto.BuildImageRequest,
 	authInfo *srpc.AuthInformation,
 	logWriter io.Writer) (*image.Image, string, error) {
-	return b.buildImage(request, authInfo, logWriter)
+	return b.buildImage(request, authInfo, logWriter, nil)
+}
+
+// BuildImageWithParentSpan is similar to BuildImage, except that the build is
+// traced as a child of parentSpan.
+func (b *Builder) BuildImageWithParentSpan(request proto.BuildImageRequest,
+	authInfo *srpc.AuthInformation, logWriter io.Writer,
+	parentSpan *trace.Span) (*image.Image, string, error) {
+	return b.buildImage(request, authInfo, logWriter, parentSpan)
 }
 
 func (b *Builder) DisableAutoBuilds(disableFor time.Duration) (
@@ -331,6 +351,13 @@ func (b *Builder) DisableBuildRequests(disableFor time.Duration) (
 	return b.disableBuildRequests(disableFor)
 }
 
+// GetBuildCacheStatus returns the build cache status (hit, miss or disabled)
+// of the last build of the specified stream. An empty string is returned if
+// build caching was not used.
+func (b *Builder) GetBuildCacheStatus(streamName string) string {
+	return b.getBuildCacheStatus(streamName)
+}
+
 func (b *Builder) GetCurrentBuildLog(streamName string) ([]byte, error) {
 	return b.getCurrentBuildLog(streamName)
 }
@@ -427,7 +454,7 @@ func ProcessManifest(manifestDir, rootDir string, bindMounts []string,
 	ctx, cancel := makeContext(0)
 	defer cancel()
 	return processManifest(ctx, manifestDir, rootDir,
-		convertBindMounts(bindMounts), nil, buildLog)
+		convertBindMounts(bindMounts), nil, nil, buildLog)
 }
 
 func ProcessManifestWithOptions(options BuildLocalOptions,
@@ -436,7 +463,7 @@ func ProcessManifestWithOptions(options BuildLocalOptions,
 	defer cancel()
 	return processManifest(ctx, options.ManifestDirectory, rootDir,
 		convertBindMounts(options.BindMounts),
-		variablesGetter(options.Variables), buildLog)
+		variablesGetter(options.Variables), nil, buildLog)
 }
 
 func UnpackImageAndProcessManifest(client *srpc.Client, manifestDir string,
@@ -444,7 +471,7 @@ func UnpackImageAndProcessManifest(client *srpc.Client, manifestDir string,
 	ctx, cancel := makeContext(0)
 	defer cancel()
 	_, err := unpackImageAndProcessManifest(ctx, client, manifestDir, 0,
-		rootDir, convertBindMounts(bindMounts), true, nil, buildLog,
+		rootDir, convertBindMounts(bindMounts), true, nil, nil, buildLog,
 		stdlog.New(buildLog, "", 0))
 	return err
 }
@@ -456,7 +483,7 @@ func UnpackImageAndProcessManifestWithOptions(client *srpc.Client,
 	_, err := unpackImageAndProcessManifest(ctx, client,
 		options.ManifestDirectory, 0, rootDir,
 		convertBindMounts(options.BindMounts), true,
-		variablesGetter(options.Variables), buildLog,
+		variablesGetter(options.Variables), nil, buildLog,
 		stdlog.New(buildLog, "", 0))
 	return err
 }
@@ -14,9 +14,11 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/errors"
 	"github.com/Cloud-Foundations/Dominator/lib/format"
 	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/fieldlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/retry"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc/retryclient"
+	"github.com/Cloud-Foundations/Dominator/lib/trace"
 	"github.com/Cloud-Foundations/Dominator/lib/url/urlutil"
 	proto "github.com/Cloud-Foundations/Dominator/proto/imaginator"
 )
@@ -96,17 +98,24 @@ func (b *Builder) checkToAutoExtend() bool {
 }
 
 func (b *Builder) build(client srpc.ClientI, request proto.BuildImageRequest,
-	authInfo *srpc.AuthInformation,
-	logWriter io.Writer) (*image.Image, string, error) {
+	authInfo *srpc.AuthInformation, logWriter io.Writer,
+	parentSpan *trace.Span) (*image.Image, string, error) {
 	startTime := time.Now()
+	span := trace.StartSpan("builder.build", parentSpan)
+	span.SetAttribute("stream", request.StreamName)
+	defer span.End()
 	builder, err := b.getImageBuilderWithReload(request.StreamName)
 	if err != nil {
+		span.SetError(err)
 		return nil, "", err
 	}
 	if builder == nil {
-		return nil, "", errors.New("unknown stream: " + request.StreamName)
+		err := errors.New("unknown stream: " + request.StreamName)
+		span.SetError(err)
+		return nil, "", err
 	}
 	if err := b.checkPermission(builder, request, authInfo); err != nil {
+		span.SetError(err)
 		return nil, "", err
 	}
 	buildLogBuffer := &bytes.Buffer{}
@@ -131,13 +140,18 @@ func (b *Builder) build(client srpc.ClientI, request proto.BuildImageRequest,
 			request.StreamName, authInfo.Username)
 	}
 	img, name, err := b.buildWithLogger(builder, client, request, authInfo,
-		startTime, &buildInfo.slaveAddress, buildLog)
+		startTime, &buildInfo.slaveAddress, buildLog, span)
+	span.SetError(err)
+	if name != "" {
+		span.SetAttribute("image", name)
+	}
 	finishTime := time.Now()
 	b.buildResultsLock.Lock()
 	defer b.buildResultsLock.Unlock()
 	delete(b.currentBuildInfos, request.StreamName)
 	b.lastBuildResults[request.StreamName] = buildResultType{
-		name, startTime, finishTime, buildLog.Bytes(), err}
+		name, startTime, finishTime, buildLog.Bytes(), err,
+		buildInfo.buildCacheStatus}
 	buildLogInfo := logarchiver.BuildInfo{
 		Duration: finishTime.Sub(startTime),
 		Error:    errors.ErrorToString(err),
@@ -154,16 +168,22 @@ func (b *Builder) build(client srpc.ClientI, request proto.BuildImageRequest,
 	if archiveError != nil {
 		b.logger.Printf("Error archiving build log: %s\n", archiveError)
 	}
+	logger := fieldlogger.New(b.logger, "stream", request.StreamName,
+		"duration", format.Duration(finishTime.Sub(startTime)))
+	if authInfo != nil {
+		logger = logger.With("requestor", authInfo.Username)
+	}
 	if err == nil {
-		b.logger.Printf("Built image: %s in %s\n",
-			name, format.Duration(finishTime.Sub(startTime)))
+		logger.PrintWithFields("built image", "image", name)
+	} else {
+		logger.PrintWithFields("failed to build image", "error", err)
 	}
 	return img, name, err
 }
 
 func (b *Builder) buildImage(request proto.BuildImageRequest,
-	authInfo *srpc.AuthInformation,
-	logWriter io.Writer) (*image.Image, string, error) {
+	authInfo *srpc.AuthInformation, logWriter io.Writer,
+	parentSpan *trace.Span) (*image.Image, string, error) {
 	b.disableLock.RLock()
 	disableUntil := b.disableBuildRequestsUntil
 	b.disableLock.RUnlock()
@@ -184,7 +204,7 @@ func (b *Builder) buildImage(request proto.BuildImageRequest,
 		return nil, "", err
 	}
 	defer client.Close()
-	img, name, err := b.build(client, request, authInfo, logWriter)
+	img, name, err := b.build(client, request, authInfo, logWriter, parentSpan)
 	if request.ReturnImage {
 		return img, "", err
 	}
@@ -224,7 +244,8 @@ func (b *Builder) buildLocal(builder imageBuilder, client srpc.ClientI,
 
 func (b *Builder) buildOnSlave(client srpc.ClientI,
 	request proto.BuildImageRequest, authInfo *srpc.AuthInformation,
-	slaveAddress *string, buildLog buildLogger) (*image.Image, error) {
+	slaveAddress *string, buildLog buildLogger,
+	span *trace.Span) (*image.Image, error) {
 	request.DisableRecursiveBuild = true
 	request.ReturnImage = true
 	request.StreamBuildLog = true
@@ -268,8 +289,14 @@ func (b *Builder) buildOnSlave(client srpc.ClientI,
 			authInfo.Username, slave, request.StreamName)
 	}
 	var reply proto.BuildImageResponse
+	span.SetAttribute("slave", slave.GetClientAddress())
+	slave.GetClient().SetTraceSpan(span)
 	err = buildclient.BuildImage(slave.GetClient(), request, &reply, buildLog)
+	slave.GetClient().SetTraceSpan(nil)
 	copyClientLogs(slave.GetClientAddress(), keepSlave, err, buildLog)
+	if reply.BuildCacheStatus != "" {
+		b.setBuildCacheStatus(request.StreamName, reply.BuildCacheStatus)
+	}
 	if err != nil {
 		if reply.NeedSourceImage {
 			keepSlave = true
@@ -288,20 +315,22 @@ func (b *Builder) buildOnSlave(client srpc.ClientI,
 
 func (b *Builder) buildSomewhere(builder imageBuilder, client srpc.ClientI,
 	request proto.BuildImageRequest, authInfo *srpc.AuthInformation,
-	slaveAddress *string, buildLog buildLogger) (*image.Image, error) {
+	slaveAddress *string, buildLog buildLogger,
+	span *trace.Span) (*image.Image, error) {
 	if b.slaveDriver == nil {
 		return b.buildLocal(builder, client, request, authInfo, buildLog)
 	} else {
-		return b.buildOnSlave(client, request, authInfo, slaveAddress, buildLog)
+		return b.buildOnSlave(client, request, authInfo, slaveAddress, buildLog,
+			span)
 	}
 }
 
 func (b *Builder) buildWithLogger(builder imageBuilder, client srpc.ClientI,
 	request proto.BuildImageRequest, authInfo *srpc.AuthInformation,
-	startTime time.Time, slaveAddress *string,
-	buildLog buildLogger) (*image.Image, string, error) {
+	startTime time.Time, slaveAddress *string, buildLog buildLogger,
+	span *trace.Span) (*image.Image, string, error) {
 	img, err := b.buildSomewhere(builder, client, request, authInfo,
-		slaveAddress, buildLog)
+		slaveAddress, buildLog, span)
 	if err != nil {
 		var buildError *BuildErrorType
 		if stderrors.As(err, &buildError) && buildError.NeedSourceImage {
@@ -322,11 +351,12 @@ func (b *Builder) buildWithLogger(builder imageBuilder, client srpc.ClientI,
 				StreamName:   buildError.SourceImage,
 				Variables:    variables,
 			}
-			if _, _, e := b.build(client, sourceReq, nil, buildLog); e != nil {
+			_, _, e := b.build(client, sourceReq, nil, buildLog, span)
+			if e != nil {
 				return nil, "", e
 			}
 			img, err = b.buildSomewhere(builder, client, request, authInfo,
-				slaveAddress, buildLog)
+				slaveAddress, buildLog, span)
 		}
 	}
 	if err != nil {
@@ -339,7 +369,11 @@ func (b *Builder) buildWithLogger(builder imageBuilder, client srpc.ClientI,
 		img.CreatedFor = authInfo.Username
 	}
 	uploadStartTime := time.Now()
-	if name, err := addImage(client, request, img); err != nil {
+	uploadSpan := trace.StartSpan("builder.upload", span)
+	name, err := addImage(client, request, img)
+	uploadSpan.SetError(err)
+	uploadSpan.End()
+	if err != nil {
 		fmt.Fprintln(buildLog, err)
 		return nil, "", err
 	} else {
@@ -499,7 +533,7 @@ func (b *Builder) rebuildImage(client srpc.ClientI, streamName string,
 		StreamName: streamName,
 		ExpiresIn:  expiresIn,
 	},
-		nil, nil)
+		nil, nil, nil)
 	if err == nil {
 		return
 	}
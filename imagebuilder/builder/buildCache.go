@@ -0,0 +1,250 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	imageclient "github.com/Cloud-Foundations/Dominator/imageserver/client"
+	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/imaginator"
+)
+
+const (
+	buildCacheStatusDisabled = "disabled"
+	buildCacheStatusHit      = "hit"
+	buildCacheStatusMiss     = "miss"
+
+	defaultBuildCacheMaximumAge = 24 * time.Hour
+)
+
+// The manifest entries which are processed before the post-install tree is
+// complete. Changes to any of these invalidate the cached tree.
+var buildCacheManifestEntries = []string{
+	"files",
+	"files.append",
+	"package-list",
+	"pre-install-scripts",
+}
+
+// Variables which change for every commit but are not expected to affect the
+// package installation. Including these would defeat the cache.
+var buildCacheIgnoredVariables = map[string]struct{}{
+	"MANIFEST_GIT_COMMIT_ID": {},
+	"REQUESTED_GIT_BRANCH":   {},
+}
+
+type buildCacheType struct {
+	client       srpc.ClientI
+	directory    string // Image server directory for this stream.
+	envGetter    environmentGetter
+	hit          bool
+	imageName    string // Name of cached image (hit) or image to add (miss).
+	manifestDir  string
+	maximumAge   time.Duration
+	sourceFilter *filter.Filter
+	status       string
+	treeCache    *treeCache
+}
+
+// hashBuildCacheEntry will write the contents of the specified file or
+// directory tree to writer. Missing entries are ignored.
+func hashBuildCacheEntry(writer io.Writer, manifestDir, entry string) error {
+	topDir := filepath.Join(manifestDir, entry)
+	return filepath.Walk(topDir,
+		func(pathname string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			fmt.Fprintf(writer, "%s %o\n", pathname[len(manifestDir):],
+				info.Mode())
+			switch {
+			case info.Mode().IsRegular():
+				file, err := os.Open(pathname)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				_, err = io.Copy(writer, file)
+				return err
+			case info.Mode()&os.ModeSymlink != 0:
+				target, err := os.Readlink(pathname)
+				if err != nil {
+					return err
+				}
+				_, err = io.WriteString(writer, target)
+				return err
+			}
+			return nil
+		})
+}
+
+func (b *Builder) getBuildCacheStatus(streamName string) string {
+	b.buildResultsLock.RLock()
+	defer b.buildResultsLock.RUnlock()
+	return b.lastBuildResults[streamName].buildCacheStatus
+}
+
+// makeBuildCache will return a build cache for the stream, or nil if build
+// caching is not configured.
+func (b *Builder) makeBuildCache(client srpc.ClientI,
+	request proto.BuildImageRequest, manifestDir string) *buildCacheType {
+	if b.buildCache.ImageDirectory == "" {
+		return nil
+	}
+	if request.DisableBuildCache {
+		b.setBuildCacheStatus(request.StreamName, buildCacheStatusDisabled)
+		return nil
+	}
+	maximumAge := time.Duration(b.buildCache.MaximumAge) * time.Second
+	if maximumAge <= 0 {
+		maximumAge = defaultBuildCacheMaximumAge
+	}
+	return &buildCacheType{
+		client:      client,
+		directory:   path.Join(b.buildCache.ImageDirectory, request.StreamName),
+		manifestDir: manifestDir,
+		maximumAge:  maximumAge,
+	}
+}
+
+func (b *Builder) setBuildCacheStatus(streamName, status string) {
+	b.buildResultsLock.Lock()
+	defer b.buildResultsLock.Unlock()
+	if buildInfo := b.currentBuildInfos[streamName]; buildInfo != nil {
+		buildInfo.buildCacheStatus = status
+	}
+	switch status {
+	case buildCacheStatusHit:
+		b.numBuildCacheHits++
+	case buildCacheStatusMiss:
+		b.numBuildCacheMisses++
+	}
+}
+
+// computeKey will compute the cache key from the source image name, the
+// manifest entries which precede the post-install tree and the variables.
+func (cache *buildCacheType) computeKey(sourceImageName string) (
+	string, error) {
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "SourceImage: %s\n", sourceImageName)
+	for _, entry := range buildCacheManifestEntries {
+		err := hashBuildCacheEntry(hasher, cache.manifestDir, entry)
+		if err != nil {
+			return "", err
+		}
+	}
+	if cache.envGetter != nil {
+		variables := cache.envGetter.getenv()
+		names := make([]string, 0, len(variables))
+		for name := range variables {
+			if _, ok := buildCacheIgnoredVariables[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(hasher, "%s=%s\n", name, variables[name])
+		}
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)[:16]), nil
+}
+
+// lookup will compute the cache key and will return the cached post-install
+// image if available and not too old. Errors are logged and treated as misses.
+func (cache *buildCacheType) lookup(sourceImageName string,
+	buildLog io.Writer) *image.Image {
+	key, err := cache.computeKey(sourceImageName)
+	if err != nil {
+		fmt.Fprintf(buildLog, "Error computing build cache key: %s\n", err)
+		return nil
+	}
+	cache.imageName = path.Join(cache.directory, key)
+	cache.status = buildCacheStatusMiss
+	if ok, err := imageclient.CheckImage(cache.client,
+		cache.imageName); err != nil {
+		fmt.Fprintf(buildLog, "Error checking for cached image: %s\n", err)
+		return nil
+	} else if !ok {
+		fmt.Fprintf(buildLog, "Build cache miss: %s\n", cache.imageName)
+		return nil
+	}
+	img, err := getImage(cache.client, cache.imageName, buildLog)
+	if err != nil {
+		fmt.Fprintf(buildLog, "Error getting cached image: %s\n", err)
+		return nil
+	}
+	if age := time.Since(img.CreatedOn); age > cache.maximumAge {
+		fmt.Fprintf(buildLog, "Build cache miss: %s is too old (%s)\n",
+			cache.imageName, format.Duration(age))
+		return nil
+	}
+	fmt.Fprintf(buildLog, "Build cache hit: %s\n", cache.imageName)
+	cache.hit = true
+	cache.status = buildCacheStatusHit
+	return img
+}
+
+// save will upload the post-install tree as an image, excluding the specified
+// directories (bind mount points). Errors are logged and otherwise ignored.
+func (cache *buildCacheType) save(rootDir string, excludeDirs []string,
+	buildLog io.Writer) {
+	if cache.imageName == "" {
+		return
+	}
+	startTime := time.Now()
+	filterLines := make([]string, 0, len(excludeDirs))
+	for _, dirname := range excludeDirs {
+		filterLines = append(filterLines,
+			regexp.QuoteMeta(strings.TrimPrefix(dirname, rootDir))+"$")
+	}
+	scanFilter, err := filter.New(filterLines)
+	if err != nil {
+		fmt.Fprintf(buildLog, "Error making build cache filter: %s\n", err)
+		return
+	}
+	var tCache *treeCache
+	if cache.treeCache != nil {
+		tCache = &treeCache{
+			inodeTable:  cache.treeCache.inodeTable,
+			pathToInode: cache.treeCache.pathToInode,
+		}
+	} else {
+		tCache = &treeCache{}
+	}
+	fs, err := buildFileSystem(cache.client, rootDir, scanFilter, tCache)
+	if err != nil {
+		fmt.Fprintf(buildLog, "Error scanning post-install tree: %s\n", err)
+		return
+	}
+	img := &image.Image{
+		ExpiresAt:  time.Now().Add(cache.maximumAge),
+		FileSystem: fs,
+		Filter:     cache.sourceFilter,
+	}
+	err = imageclient.MakeDirectoryAll(cache.client, cache.directory)
+	if err != nil {
+		fmt.Fprintf(buildLog, "Error making build cache directory: %s\n", err)
+		return
+	}
+	if err := imageclient.AddImage(cache.client, cache.imageName,
+		img); err != nil {
+		fmt.Fprintf(buildLog, "Error adding cached image: %s\n", err)
+		return
+	}
+	fmt.Fprintf(buildLog, "Saved post-install tree to build cache: %s in %s\n",
+		cache.imageName, format.Duration(time.Since(startTime)))
+}
@@ -0,0 +1,51 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, filename, contents string) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildCacheKey(t *testing.T) {
+	manifestDir := t.TempDir()
+	writeTestFile(t, filepath.Join(manifestDir, "package-list"), "curl\n")
+	writeTestFile(t, filepath.Join(manifestDir, "files", "etc", "motd"), "hi")
+	variables := variablesGetter{"MANIFEST_GIT_COMMIT_ID": "1", "X": "1"}
+	cache := &buildCacheType{envGetter: variables, manifestDir: manifestDir}
+	computeKey := func() string {
+		key, err := cache.computeKey("base/2024-01-01:00:00:00")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	key := computeKey()
+	writeTestFile(t, filepath.Join(manifestDir, "scripts", "10-setup"), "true")
+	writeTestFile(t,
+		filepath.Join(manifestDir, "post-install-files", "etc", "issue"), "x")
+	variables["MANIFEST_GIT_COMMIT_ID"] = "2"
+	if newKey := computeKey(); newKey != key {
+		t.Error("key changed by post-install changes")
+	}
+	variables["X"] = "2"
+	if newKey := computeKey(); newKey == key {
+		t.Error("key not changed by variable")
+	}
+	variables["X"] = "1"
+	writeTestFile(t, filepath.Join(manifestDir, "files", "etc", "motd"), "ho")
+	if newKey := computeKey(); newKey == key {
+		t.Error("key not changed by files")
+	}
+	if otherKey, _ := cache.computeKey("base/other"); otherKey == computeKey() {
+		t.Error("key not changed by source image")
+	}
+}
@@ -121,8 +121,17 @@ func (b *Builder) writeHtml(writer io.Writer) {
 	fmt.Fprintln(writer,
 		"Image stream <a href=\"showDirectedGraph\">relationships</a><br>")
 	fmt.Fprintf(writer,
-		"Image server: <a href=\"http://%s/\">%s</a><p>\n",
+		"Image server: <a href=\"http://%s/\">%s</a><br>\n",
 		b.imageServerAddress, b.imageServerAddress)
+	if b.buildCache.ImageDirectory != "" {
+		b.buildResultsLock.RLock()
+		numHits := b.numBuildCacheHits
+		numMisses := b.numBuildCacheMisses
+		b.buildResultsLock.RUnlock()
+		fmt.Fprintf(writer, "Build cache: %d hits, %d misses<br>\n",
+			numHits, numMisses)
+	}
+	fmt.Fprintln(writer, "<p>")
 	currentBuildNames := make([]string, 0)
 	currentBuildSlaves := make([]string, 0)
 	currentBuildTimes := make([]time.Time, 0)
@@ -292,6 +301,9 @@ func (stream *imageStreamType) WriteHtml(writer io.Writer) {
 			"SourceImage: <a href=\"showImageStream?%s\"><code>%s</code></a><br>\n",
 			sourceImageName, sourceImageName)
 	}
+	if status := stream.builder.getBuildCacheStatus(stream.name); status != "" {
+		fmt.Fprintf(writer, "Build cache for last build: %s<br>\n", status)
+	}
 	if len(stream.Variables) > 0 {
 		fmt.Fprintln(writer, "Stream variables:<br>")
 		fmt.Fprintf(writer, "<pre style=\"%s\">\n", codeStyle)
@@ -65,8 +65,13 @@ func (stream *imageStreamType) build(b *Builder, client srpc.ClientI,
 			writable: true,
 		})
 	}
+	cache := b.makeBuildCache(client, request, manifestDirectory)
 	img, err := buildImageFromManifest(ctx, client, manifestDirectory, request,
-		bindMounts, stream, gitInfo, b.mtimesCopyFilter, buildLog, b.logger)
+		bindMounts, stream, gitInfo, b.mtimesCopyFilter, cache, buildLog,
+		b.logger)
+	if cache != nil && cache.status != "" {
+		b.setBuildCacheStatus(request.StreamName, cache.status)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -284,7 +289,8 @@ func buildImageFromManifest(ctx context.Context, client srpc.ClientI,
 	manifestDir string, request proto.BuildImageRequest,
 	bindMounts []bindMountType, envGetter environmentGetter,
 	gitInfo *gitInfoType, mtimesCopyFilter *filter.Filter,
-	buildLog buildLogger, logger log.Logger) (*image.Image, error) {
+	cache *buildCacheType, buildLog buildLogger, logger log.Logger) (
+	*image.Image, error) {
 	// First load all the various manifest files (fail early on error).
 	computedFilesList, addComputedFiles, err := loadComputedFiles(manifestDir)
 	if err != nil {
@@ -329,9 +335,12 @@ func buildImageFromManifest(ctx context.Context, client srpc.ClientI,
 	}
 	vGetter.add("REQUESTED_GIT_BRANCH", request.GitBranch)
 	request.Variables = vGetter
+	if cache != nil {
+		cache.envGetter = vGetter
+	}
 	manifest, err := unpackImageAndProcessManifest(ctx, client, manifestDir,
-		request.MaxSourceAge, rootDir, bindMounts, false, vGetter, buildLog,
-		logger)
+		request.MaxSourceAge, rootDir, bindMounts, false, vGetter, cache,
+		buildLog, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -419,6 +428,7 @@ func buildImageFromManifestAndUpload(ctx context.Context, client srpc.ClientI,
 		},
 		nil,
 		options.MtimesCopyFilter,
+		nil,
 		buildLog,
 		logger)
 	if err != nil {
@@ -488,7 +498,7 @@ func buildTreeFromManifest(ctx context.Context, client srpc.ClientI,
 	_, err = unpackImageAndProcessManifest(ctx, client,
 		options.ManifestDirectory, 0, rootDir,
 		convertBindMounts(options.BindMounts), true,
-		variablesGetter(options.Variables), buildLog, logger)
+		variablesGetter(options.Variables), nil, buildLog, logger)
 	if err != nil {
 		os.RemoveAll(rootDir)
 		return "", err
@@ -626,7 +636,8 @@ func loadTriggers(manifestDir string) (*triggers.Triggers, bool, error) {
 
 func unpackImage(client srpc.ClientI, streamName, buildCommitId string,
 	sourceImageTagsToMatch tags.MatchTags, maxSourceAge time.Duration,
-	rootDir string, buildLog io.Writer, logger log.Logger) (
+	rootDir string, cache *buildCacheType, buildLog io.Writer,
+	logger log.Logger) (
 	*sourceImageInfoType, error) {
 	ctimeResolution, err := getCtimeResolution()
 	if err != nil {
@@ -662,18 +673,29 @@ func unpackImage(client srpc.ClientI, streamName, buildCommitId string,
 			SourceImageGitCommitId: buildCommitId,
 		}
 	}
+	// If there is a cached post-install tree, unpack that instead.
+	fsToUnpack := sourceImage.FileSystem
+	if cache != nil {
+		cache.sourceFilter = sourceImage.Filter
+		if cachedImage := cache.lookup(imageName, buildLog); cachedImage != nil {
+			fsToUnpack = cachedImage.FileSystem
+		}
+	}
 	objClient := objectclient.AttachObjectClient(client)
 	defer objClient.Close()
-	err = util.Unpack(sourceImage.FileSystem, objClient, rootDir,
+	err = util.Unpack(fsToUnpack, objClient, rootDir,
 		stdlog.New(buildLog, "", 0))
 	if err != nil {
 		return nil, err
 	}
 	fmt.Fprintf(buildLog, "Source image: %s\n", imageName)
-	treeCache, err := buildTreeCache(rootDir, sourceImage.FileSystem, buildLog)
+	treeCache, err := buildTreeCache(rootDir, fsToUnpack, buildLog)
 	if err != nil {
 		return nil, err
 	}
+	if cache != nil {
+		cache.treeCache = treeCache
+	}
 	time.Sleep(ctimeResolution)
 	fmt.Fprintf(buildLog, "Waited %s (Ctime resolution)\n",
 		format.Duration(ctimeResolution))
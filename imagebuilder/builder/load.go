@@ -178,6 +178,7 @@ func load(options BuilderOptions, params BuilderParams) (*Builder, error) {
 		autoRebuildTrigger:          autoRebuildTrigger,
 		buildLogArchiver:            params.BuildLogArchiver,
 		bindMounts:                  masterConfiguration.BindMounts,
+		buildCache:                  masterConfiguration.BuildCache,
 		cache:                       masterConfiguration.Cache,
 		mtimesCopyFilter:            mtimesCopyFilter,
 		createSlaveTimeout:          options.CreateSlaveTimeout,
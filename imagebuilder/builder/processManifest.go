@@ -147,7 +147,8 @@ func readManifestFile(manifestDir string, envGetter environmentGetter) (
 func unpackImageAndProcessManifest(ctx context.Context, client srpc.ClientI,
 	manifestDir string, maxSourceAge time.Duration, rootDir string,
 	bindMounts []bindMountType, applyFilter bool, envGetter environmentGetter,
-	buildLog io.Writer, logger log.Logger) (manifestType, error) {
+	cache *buildCacheType, buildLog io.Writer, logger log.Logger) (
+	manifestType, error) {
 	manifestConfig, err := readManifestFile(manifestDir, envGetter)
 	if err != nil {
 		return manifestType{}, err
@@ -169,7 +170,7 @@ func unpackImageAndProcessManifest(ctx context.Context, client srpc.ClientI,
 	sourceImageInfo, err := unpackImage(client, manifestConfig.SourceImage,
 		manifestConfig.SourceImageGitCommitId,
 		manifestConfig.SourceImageTagsToMatch,
-		maxSourceAge, rootDir, buildLog, logger)
+		maxSourceAge, rootDir, cache, buildLog, logger)
 	if err != nil {
 		var buildError *BuildErrorType
 		if errors.As(err, &buildError) {
@@ -180,7 +181,7 @@ func unpackImageAndProcessManifest(ctx context.Context, client srpc.ClientI,
 	}
 	startTime := time.Now()
 	err = processManifest(ctx, manifestDir, rootDir, bindMounts, envGetter,
-		buildLog)
+		cache, buildLog)
 	if err != nil {
 		return manifestType{},
 			errors.New("error processing manifest: " + err.Error())
@@ -203,7 +204,7 @@ func unpackImageAndProcessManifest(ctx context.Context, client srpc.ClientI,
 
 func processManifest(ctx context.Context, manifestDir, rootDir string,
 	bindMounts []bindMountType, envGetter environmentGetter,
-	buildLog io.Writer) error {
+	cache *buildCacheType, buildLog io.Writer) error {
 	// Copy in system /etc/resolv.conf
 	file, err := os.Open("/etc/resolv.conf")
 	if err != nil {
@@ -231,34 +232,18 @@ func processManifest(ctx context.Context, manifestDir, rootDir string,
 	if err != nil {
 		return fmt.Errorf("error copying in /etc/resolv.conf: %s", err)
 	}
-	if err := copyFiles(manifestDir, "files", rootDir, buildLog); err != nil {
-		return err
-	}
-	err = appendFiles(manifestDir, "files.append", rootDir, buildLog)
-	if err != nil {
-		return err
-	}
-	err = runScripts(ctx, g, manifestDir, "pre-install-scripts", rootDir,
-		envGetter, buildLog)
-	if err != nil {
-		return err
-	}
-	packageList, err := fsutil.LoadLines(filepath.Join(manifestDir,
-		"package-list"))
-	if err != nil {
-		if !os.IsNotExist(err) {
-			return err
-		}
-	}
-	if len(packageList) > 0 {
-		err := updatePackageDatabase(ctx, g, rootDir, envGetter, buildLog)
+	if cache != nil && cache.hit {
+		fmt.Fprintln(buildLog,
+			"\nUsing cached post-install tree: skipping files, pre-install-scripts and packages")
+	} else {
+		err := processManifestPreInstall(ctx, g, manifestDir, rootDir,
+			envGetter, buildLog)
 		if err != nil {
 			return err
 		}
-	}
-	err = installPackages(ctx, g, packageList, rootDir, envGetter, buildLog)
-	if err != nil {
-		return errors.New("error installing packages: " + err.Error())
+		if cache != nil {
+			cache.save(rootDir, directoriesToDelete, buildLog)
+		}
 	}
 	err = copyFiles(manifestDir, "post-install-files", rootDir, buildLog)
 	if err != nil {
@@ -296,6 +281,43 @@ func processManifest(ctx context.Context, manifestDir, rootDir string,
 	return nil
 }
 
+// processManifestPreInstall will perform the steps up to and including the
+// installation of packages. The resulting tree may be cached.
+func processManifestPreInstall(ctx context.Context, g *goroutine.Goroutine,
+	manifestDir, rootDir string, envGetter environmentGetter,
+	buildLog io.Writer) error {
+	if err := copyFiles(manifestDir, "files", rootDir, buildLog); err != nil {
+		return err
+	}
+	err := appendFiles(manifestDir, "files.append", rootDir, buildLog)
+	if err != nil {
+		return err
+	}
+	err = runScripts(ctx, g, manifestDir, "pre-install-scripts", rootDir,
+		envGetter, buildLog)
+	if err != nil {
+		return err
+	}
+	packageList, err := fsutil.LoadLines(filepath.Join(manifestDir,
+		"package-list"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if len(packageList) > 0 {
+		err := updatePackageDatabase(ctx, g, rootDir, envGetter, buildLog)
+		if err != nil {
+			return err
+		}
+	}
+	err = installPackages(ctx, g, packageList, rootDir, envGetter, buildLog)
+	if err != nil {
+		return errors.New("error installing packages: " + err.Error())
+	}
+	return nil
+}
+
 func appendFiles(manifestDir, dirname, rootDir string,
 	buildLog io.Writer) error {
 	startTime := time.Now()
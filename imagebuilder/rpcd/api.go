@@ -42,6 +42,10 @@ func Setup(builder *builder.Builder, logger log.Logger) (*htmlWriter, error) {
 				"BuildImage",
 				"GetDependencies",
 				"GetDirectedGraph",
+			},
+			ReadOnlyMethods: []string{
+				"GetDependencies",
+				"GetDirectedGraph",
 			}})
 	return (*htmlWriter)(srpcObj), nil
 }
@@ -40,8 +40,8 @@ func (t *srpcType) BuildImage(conn *srpc.Conn) error {
 	} else {
 		logWriter = buildLogBuffer
 	}
-	image, name, err := t.builder.BuildImage(request, conn.GetAuthInformation(),
-		logWriter)
+	image, name, err := t.builder.BuildImageWithParentSpan(request,
+		conn.GetAuthInformation(), logWriter, conn.GetTraceSpan())
 	if f, ok := logWriter.(flusher); ok {
 		// Ensure all data are flushed and no background flush will happen.
 		if err := f.flush(); err != nil {
@@ -49,10 +49,11 @@ func (t *srpcType) BuildImage(conn *srpc.Conn) error {
 		}
 	}
 	reply := proto.BuildImageResponse{
-		Image:       image,
-		ImageName:   name,
-		BuildLog:    buildLogBuffer.Bytes(),
-		ErrorString: errors.ErrorToString(err),
+		Image:            image,
+		ImageName:        name,
+		BuildCacheStatus: t.builder.GetBuildCacheStatus(request.StreamName),
+		BuildLog:         buildLogBuffer.Bytes(),
+		ErrorString:      errors.ErrorToString(err),
 	}
 	var buildError *builder.BuildErrorType
 	if stderrors.As(err, &buildError) {
@@ -0,0 +1,140 @@
+package glancepublisher
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	libtags "github.com/Cloud-Foundations/Dominator/lib/tags"
+)
+
+const (
+	DiskFormatRaw = "raw"
+	DiskFormatVhd = "vhd"
+
+	ExpiresAtFormat = "2006-01-02 15:04:05"
+	ExpiresAtTag    = "ExpiresAt"
+)
+
+// Image describes an image in the Glance registry. Custom image properties
+// are represented as Tags.
+type Image struct {
+	CreatedAt  string
+	DiskFormat string
+	Id         string
+	Name       string
+	Size       uint64
+	Status     string
+	Tags       libtags.Tags `json:",omitempty"`
+	Visibility string
+}
+
+// Instance describes a server which was created from an image.
+type Instance struct {
+	CreatedAt string
+	Id        string
+	ImageId   string
+	Name      string
+}
+
+type Params struct {
+	ComputeEndpoint string // Optional. Used to find which images are in use.
+	Endpoint        string // Glance endpoint, i.e. https://glance:9292.
+	HttpClient      *http.Client
+	Token           string // Sent in the X-Auth-Token header if not empty.
+}
+
+type Publisher struct {
+	params Params
+}
+
+type PublishParams struct {
+	DiskFormat      string // Default: DiskFormatRaw.
+	ImageLeafName   string
+	ImageName       string // Default: StreamName/ImageLeafName.
+	StreamName      string
+	Tags            libtags.Tags
+	UnpackerAddress string // Address of the image-unpacker.
+	Visibility      string // Default: private.
+}
+
+type UnusedImagesResult struct {
+	UnusedImages []Image
+}
+
+type UsedImagesResult struct {
+	UsedImages     []Image
+	UsingInstances []Instance
+}
+
+func New(params Params) *Publisher {
+	if params.HttpClient == nil {
+		params.HttpClient = http.DefaultClient
+	}
+	return &Publisher{params: params}
+}
+
+// PublishToUrl will unpack an image using an image-unpacker and will have the
+// image-unpacker export the disk with the specified type (i.e. qcow2) and PUT
+// it to url. This supports generic HTTP image registries.
+func PublishToUrl(unpackerAddress, streamName, imageLeafName, exportType,
+	url string, logger log.Logger) error {
+	return publishToUrl(unpackerAddress, streamName, imageLeafName, exportType,
+		url, logger)
+}
+
+// DeleteImages will delete the specified images.
+func (p *Publisher) DeleteImages(imageIds []string, logger log.Logger) error {
+	return p.deleteImages(imageIds, logger)
+}
+
+// DeleteUnusedImages will delete images matching searchTags and not matching
+// excludeSearchTags which are older than minImageAge and are not used by any
+// servers. The deleted images are returned.
+func (p *Publisher) DeleteUnusedImages(searchTags, excludeSearchTags libtags.Tags,
+	minImageAge time.Duration, logger log.DebugLogger) (
+	UnusedImagesResult, error) {
+	return p.deleteUnusedImages(searchTags, excludeSearchTags, minImageAge,
+		logger)
+}
+
+// ExpireImages will delete images with an ExpiresAt tag in the past.
+func (p *Publisher) ExpireImages(logger log.Logger) error {
+	return p.expireImages(time.Now(), logger)
+}
+
+// ListImages will list images matching searchTags and not matching
+// excludeSearchTags which are older than minImageAge.
+func (p *Publisher) ListImages(searchTags, excludeSearchTags libtags.Tags,
+	minImageAge time.Duration, logger log.DebugLogger) ([]Image, error) {
+	return p.listImages(searchTags, excludeSearchTags, minImageAge, logger)
+}
+
+// ListUnusedImages is like DeleteUnusedImages except that no images are
+// deleted.
+func (p *Publisher) ListUnusedImages(searchTags, excludeSearchTags libtags.Tags,
+	minImageAge time.Duration, logger log.DebugLogger) (
+	UnusedImagesResult, error) {
+	return p.listUnusedImages(searchTags, excludeSearchTags, minImageAge,
+		logger)
+}
+
+// ListUsedImages will list images matching searchTags and not matching
+// excludeSearchTags which are used by servers, along with those servers.
+func (p *Publisher) ListUsedImages(searchTags, excludeSearchTags libtags.Tags,
+	logger log.DebugLogger) (UsedImagesResult, error) {
+	return p.listUsedImages(searchTags, excludeSearchTags, logger)
+}
+
+// Publish will unpack an image using an image-unpacker, upload the disk to a
+// new Glance image and will return the new image.
+func (p *Publisher) Publish(params PublishParams, logger log.Logger) (
+	*Image, error) {
+	return p.publish(params, logger)
+}
+
+// SetTags will add or replace tags on the specified images.
+func (p *Publisher) SetTags(imageIds []string, tags libtags.Tags,
+	logger log.Logger) error {
+	return p.setTags(imageIds, tags, logger)
+}
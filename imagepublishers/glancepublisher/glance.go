@@ -0,0 +1,270 @@
+package glancepublisher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	libtags "github.com/Cloud-Foundations/Dominator/lib/tags"
+)
+
+const patchContentType = "application/openstack-images-v2.1-json-patch"
+
+type listImagesResponse struct {
+	Images []map[string]interface{} `json:"images"`
+	Next   string                   `json:"next"`
+}
+
+type listServersResponse struct {
+	Servers []struct {
+		Created string `json:"created"`
+		Id      string `json:"id"`
+		Image   struct {
+			Id string `json:"id"`
+		} `json:"image"`
+		Name string `json:"name"`
+	} `json:"servers"`
+}
+
+type patchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value string `json:"value,omitempty"`
+}
+
+// Properties which are defined by Glance. All other string properties are
+// treated as tags.
+var standardProperties = map[string]struct{}{
+	"checksum":         {},
+	"container_format": {},
+	"created_at":       {},
+	"direct_url":       {},
+	"disk_format":      {},
+	"file":             {},
+	"id":               {},
+	"locations":        {},
+	"min_disk":         {},
+	"min_ram":          {},
+	"name":             {},
+	"os_hash_algo":     {},
+	"os_hash_value":    {},
+	"os_hidden":        {},
+	"owner":            {},
+	"protected":        {},
+	"schema":           {},
+	"self":             {},
+	"size":             {},
+	"status":           {},
+	"stores":           {},
+	"tags":             {},
+	"updated_at":       {},
+	"virtual_size":     {},
+	"visibility":       {},
+}
+
+func decodeImage(properties map[string]interface{}) Image {
+	image := Image{Tags: make(libtags.Tags)}
+	for key, value := range properties {
+		switch key {
+		case "created_at":
+			image.CreatedAt, _ = value.(string)
+		case "disk_format":
+			image.DiskFormat, _ = value.(string)
+		case "id":
+			image.Id, _ = value.(string)
+		case "name":
+			image.Name, _ = value.(string)
+		case "size":
+			if size, ok := value.(float64); ok {
+				image.Size = uint64(size)
+			}
+		case "status":
+			image.Status, _ = value.(string)
+		case "visibility":
+			image.Visibility, _ = value.(string)
+		default:
+			if _, ok := standardProperties[key]; ok {
+				continue
+			}
+			if strings.HasPrefix(key, "os_glance_") {
+				continue
+			}
+			if strValue, ok := value.(string); ok {
+				image.Tags[key] = strValue
+			}
+		}
+	}
+	return image
+}
+
+// resolveUrl will resolve ref relative to endpoint. The path of endpoint is
+// preserved for absolute paths such as the "next" links returned by Glance.
+func resolveUrl(endpoint, ref string) (string, error) {
+	base, err := url.Parse(strings.TrimSuffix(endpoint, "/") + "/")
+	if err != nil {
+		return "", err
+	}
+	refUrl, err := url.Parse(strings.TrimPrefix(ref, "/"))
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(refUrl).String(), nil
+}
+
+func (p *Publisher) createImage(name, diskFormat, visibility string,
+	tags libtags.Tags) (Image, error) {
+	properties := map[string]interface{}{
+		"container_format": "bare",
+		"disk_format":      diskFormat,
+		"name":             name,
+		"visibility":       visibility,
+	}
+	for key, value := range tags {
+		if _, ok := standardProperties[key]; ok {
+			return Image{}, fmt.Errorf("cannot set standard property: %s",
+				key)
+		}
+		properties[key] = value
+	}
+	var reply map[string]interface{}
+	err := p.doJson(p.params.Endpoint, http.MethodPost, "/v2/images",
+		"application/json", properties, &reply)
+	if err != nil {
+		return Image{}, err
+	}
+	return decodeImage(reply), nil
+}
+
+func (p *Publisher) deleteImage(imageId string) error {
+	return p.doJson(p.params.Endpoint, http.MethodDelete,
+		"/v2/images/"+url.PathEscape(imageId), "", nil, nil)
+}
+
+func (p *Publisher) do(endpoint, method, ref, contentType string,
+	body io.Reader, contentLength int64) (*http.Response, error) {
+	requestUrl, err := resolveUrl(endpoint, ref)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, requestUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = contentLength
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.params.Token != "" {
+		req.Header.Set("X-Auth-Token", p.params.Token)
+	}
+	resp, err := p.params.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, requestUrl,
+			resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+func (p *Publisher) doJson(endpoint, method, ref, contentType string,
+	request interface{}, reply interface{}) error {
+	var body io.Reader
+	var contentLength int64
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		contentLength = int64(len(data))
+	}
+	resp, err := p.do(endpoint, method, ref, contentType, body, contentLength)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if reply == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
+
+func (p *Publisher) getImage(imageId string) (Image, error) {
+	var reply map[string]interface{}
+	err := p.doJson(p.params.Endpoint, http.MethodGet,
+		"/v2/images/"+url.PathEscape(imageId), "", nil, &reply)
+	if err != nil {
+		return Image{}, err
+	}
+	return decodeImage(reply), nil
+}
+
+// getAllImages will get all images, following the pagination links.
+func (p *Publisher) getAllImages() ([]Image, error) {
+	var images []Image
+	ref := "/v2/images?limit=100"
+	for ref != "" {
+		var reply listImagesResponse
+		err := p.doJson(p.params.Endpoint, http.MethodGet, ref, "", nil,
+			&reply)
+		if err != nil {
+			return nil, err
+		}
+		for _, properties := range reply.Images {
+			images = append(images, decodeImage(properties))
+		}
+		ref = reply.Next
+	}
+	return images, nil
+}
+
+func (p *Publisher) getAllInstances() ([]Instance, error) {
+	if p.params.ComputeEndpoint == "" {
+		return nil, fmt.Errorf("no compute endpoint specified")
+	}
+	var reply listServersResponse
+	err := p.doJson(p.params.ComputeEndpoint, http.MethodGet,
+		"/servers/detail?all_tenants=1", "", nil, &reply)
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]Instance, 0, len(reply.Servers))
+	for _, server := range reply.Servers {
+		instances = append(instances, Instance{
+			CreatedAt: server.Created,
+			Id:        server.Id,
+			ImageId:   server.Image.Id,
+			Name:      server.Name,
+		})
+	}
+	return instances, nil
+}
+
+func (p *Publisher) patchImage(imageId string,
+	operations []patchOperation) error {
+	return p.doJson(p.params.Endpoint, http.MethodPatch,
+		"/v2/images/"+url.PathEscape(imageId), patchContentType, operations,
+		nil)
+}
+
+func (p *Publisher) uploadImageData(imageId string, reader io.Reader,
+	size uint64) error {
+	resp, err := p.do(p.params.Endpoint, http.MethodPut,
+		"/v2/images/"+url.PathEscape(imageId)+"/file",
+		"application/octet-stream", reader, int64(size))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
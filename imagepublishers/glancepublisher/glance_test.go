@@ -0,0 +1,254 @@
+package glancepublisher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	libtags "github.com/Cloud-Foundations/Dominator/lib/tags"
+)
+
+const testToken = "secret-token"
+
+type fakeGlance struct {
+	sync.Mutex
+	data    map[string][]byte
+	images  map[string]map[string]interface{}
+	nextId  int
+	servers []map[string]interface{}
+}
+
+func newFakeGlance() (*fakeGlance, *httptest.Server) {
+	fake := &fakeGlance{
+		data:   make(map[string][]byte),
+		images: make(map[string]map[string]interface{}),
+	}
+	return fake, httptest.NewServer(fake)
+}
+
+func (fake *fakeGlance) addImage(name, createdAt string,
+	tags libtags.Tags) string {
+	fake.Lock()
+	defer fake.Unlock()
+	fake.nextId++
+	id := strconv.Itoa(fake.nextId)
+	image := map[string]interface{}{
+		"created_at": createdAt,
+		"id":         id,
+		"name":       name,
+		"status":     "active",
+		"tags":       []string{},
+	}
+	for key, value := range tags {
+		image[key] = value
+	}
+	fake.images[id] = image
+	return id
+}
+
+func (fake *fakeGlance) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("X-Auth-Token") != testToken {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+		return
+	}
+	fake.Lock()
+	defer fake.Unlock()
+	encoder := json.NewEncoder(w)
+	switch {
+	case req.URL.Path == "/servers/detail":
+		encoder.Encode(map[string]interface{}{"servers": fake.servers})
+	case req.URL.Path == "/v2/images" && req.Method == http.MethodGet:
+		// Return one image per page to exercise pagination.
+		marker, _ := strconv.Atoi(req.URL.Query().Get("marker"))
+		reply := map[string]interface{}{}
+		images := []map[string]interface{}{}
+		for id := marker + 1; id <= fake.nextId; id++ {
+			if image, ok := fake.images[strconv.Itoa(id)]; ok {
+				images = append(images, image)
+				reply["next"] = fmt.Sprintf("/v2/images?marker=%d", id)
+				break
+			}
+		}
+		reply["images"] = images
+		encoder.Encode(reply)
+	case req.URL.Path == "/v2/images" && req.Method == http.MethodPost:
+		var image map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&image); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fake.nextId++
+		id := strconv.Itoa(fake.nextId)
+		image["id"] = id
+		image["status"] = "queued"
+		fake.images[id] = image
+		w.WriteHeader(http.StatusCreated)
+		encoder.Encode(image)
+	case strings.HasPrefix(req.URL.Path, "/v2/images/"):
+		id := strings.TrimPrefix(req.URL.Path, "/v2/images/")
+		id = strings.TrimSuffix(id, "/file")
+		image, ok := fake.images[id]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		switch {
+		case req.Method == http.MethodPut:
+			data, _ := io.ReadAll(req.Body)
+			fake.data[id] = data
+			image["size"] = len(data)
+			image["status"] = "active"
+			w.WriteHeader(http.StatusNoContent)
+		case req.Method == http.MethodPatch:
+			if req.Header.Get("Content-Type") != patchContentType {
+				http.Error(w, "bad content type",
+					http.StatusUnsupportedMediaType)
+				return
+			}
+			var operations []patchOperation
+			json.NewDecoder(req.Body).Decode(&operations)
+			for _, operation := range operations {
+				image[strings.TrimPrefix(operation.Path, "/")] = operation.Value
+			}
+			encoder.Encode(image)
+		case req.Method == http.MethodDelete:
+			delete(fake.images, id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			encoder.Encode(image)
+		}
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestCreateUploadAndTag(t *testing.T) {
+	fake, server := newFakeGlance()
+	defer server.Close()
+	publisher := New(Params{Endpoint: server.URL, Token: testToken})
+	logger := testlogger.New(t)
+	image, err := publisher.createImage("stream/leaf", DiskFormatRaw,
+		"private", libtags.Tags{"Owner": "team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("disk contents")
+	err = publisher.uploadImageData(image.Id, bytes.NewReader(data),
+		uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fake.data[image.Id], data) {
+		t.Fatalf("uploaded data: %q", fake.data[image.Id])
+	}
+	err = publisher.SetTags([]string{image.Id}, libtags.Tags{"Preferred": "true"},
+		logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err = publisher.getImage(image.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTags := libtags.Tags{"Owner": "team", "Preferred": "true"}
+	if !image.Tags.Equal(expectedTags) {
+		t.Fatalf("tags: %v != expected: %v", image.Tags, expectedTags)
+	}
+	if image.Size != uint64(len(data)) || image.Status != "active" {
+		t.Fatalf("bad image: %+v", image)
+	}
+	if _, err := publisher.createImage("bad", DiskFormatRaw, "private",
+		libtags.Tags{"status": "active"}); err == nil {
+		t.Fatal("setting a standard property did not fail")
+	}
+}
+
+func TestBadToken(t *testing.T) {
+	_, server := newFakeGlance()
+	defer server.Close()
+	publisher := New(Params{Endpoint: server.URL, Token: "wrong"})
+	if _, err := publisher.getAllImages(); err == nil {
+		t.Fatal("listing images with a bad token did not fail")
+	}
+}
+
+func TestExpireImages(t *testing.T) {
+	fake, server := newFakeGlance()
+	defer server.Close()
+	now := time.Now().UTC()
+	created := now.Add(-time.Hour).Format(time.RFC3339)
+	expiredId := fake.addImage("expired", created, libtags.Tags{
+		ExpiresAtTag: now.Add(-time.Minute).Format(ExpiresAtFormat)})
+	liveId := fake.addImage("live", created, libtags.Tags{
+		ExpiresAtTag: now.Add(time.Minute).Format(ExpiresAtFormat)})
+	permanentId := fake.addImage("permanent", created, nil)
+	publisher := New(Params{Endpoint: server.URL, Token: testToken})
+	if err := publisher.expireImages(now, testlogger.New(t)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.images[expiredId]; ok {
+		t.Error("expired image not deleted")
+	}
+	if _, ok := fake.images[liveId]; !ok {
+		t.Error("live image deleted")
+	}
+	if _, ok := fake.images[permanentId]; !ok {
+		t.Error("permanent image deleted")
+	}
+}
+
+func TestUsedAndUnusedImages(t *testing.T) {
+	fake, server := newFakeGlance()
+	defer server.Close()
+	old := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	recent := time.Now().UTC().Format(time.RFC3339)
+	usedId := fake.addImage("used", old, libtags.Tags{"Preferred": "true"})
+	unusedId := fake.addImage("unused", old, libtags.Tags{"Preferred": "true"})
+	fake.addImage("recent", recent, libtags.Tags{"Preferred": "true"})
+	fake.addImage("excluded", old,
+		libtags.Tags{"Preferred": "true", "Keep": "true"})
+	fake.addImage("other", old, nil)
+	fake.servers = []map[string]interface{}{
+		{"id": "s1", "name": "server1", "image": map[string]string{
+			"id": usedId}},
+	}
+	publisher := New(Params{
+		ComputeEndpoint: server.URL,
+		Endpoint:        server.URL,
+		Token:           testToken,
+	})
+	logger := testlogger.New(t)
+	searchTags := libtags.Tags{"Preferred": "true"}
+	excludeTags := libtags.Tags{"Keep": "true"}
+	used, err := publisher.ListUsedImages(searchTags, excludeTags, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(used.UsedImages) != 1 || used.UsedImages[0].Id != usedId ||
+		len(used.UsingInstances) != 1 {
+		t.Fatalf("bad used images: %+v", used)
+	}
+	unused, err := publisher.DeleteUnusedImages(searchTags, excludeTags,
+		24*time.Hour, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unused.UnusedImages) != 1 || unused.UnusedImages[0].Id != unusedId {
+		t.Fatalf("bad unused images: %+v", unused)
+	}
+	if _, ok := fake.images[unusedId]; ok {
+		t.Error("unused image not deleted")
+	}
+	if len(fake.images) != 4 {
+		t.Errorf("%d images remaining, expected 4", len(fake.images))
+	}
+}
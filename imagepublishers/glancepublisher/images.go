@@ -0,0 +1,175 @@
+package glancepublisher
+
+import (
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	libtags "github.com/Cloud-Foundations/Dominator/lib/tags"
+)
+
+// matchTags returns true if all of searchTags match and none of
+// excludeSearchTags match.
+func matchTags(tags, searchTags, excludeSearchTags libtags.Tags) bool {
+	for key, value := range searchTags {
+		if tags[key] != value {
+			return false
+		}
+	}
+	for key, value := range excludeSearchTags {
+		if tagValue, ok := tags[key]; ok && tagValue == value {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Publisher) deleteImages(imageIds []string, logger log.Logger) error {
+	var firstError error
+	for _, imageId := range imageIds {
+		if err := p.deleteImage(imageId); err != nil {
+			logger.Printf("error deleting: %s: %s\n", imageId, err)
+			if firstError == nil {
+				firstError = err
+			}
+			continue
+		}
+		logger.Printf("deleted: %s\n", imageId)
+	}
+	return firstError
+}
+
+func (p *Publisher) deleteUnusedImages(searchTags,
+	excludeSearchTags libtags.Tags, minImageAge time.Duration,
+	logger log.DebugLogger) (UnusedImagesResult, error) {
+	results, err := p.listUnusedImages(searchTags, excludeSearchTags,
+		minImageAge, logger)
+	if err != nil {
+		return UnusedImagesResult{}, err
+	}
+	imageIds := make([]string, 0, len(results.UnusedImages))
+	for _, image := range results.UnusedImages {
+		imageIds = append(imageIds, image.Id)
+	}
+	return results, p.deleteImages(imageIds, logger)
+}
+
+func (p *Publisher) expireImages(currentTime time.Time,
+	logger log.Logger) error {
+	images, err := p.getAllImages()
+	if err != nil {
+		return err
+	}
+	var expiredImageIds []string
+	for _, image := range images {
+		expiresAt, ok := image.Tags[ExpiresAtTag]
+		if !ok {
+			continue
+		}
+		expirationTime, err := time.Parse(ExpiresAtFormat, expiresAt)
+		if err != nil {
+			logger.Printf("%s: bad %s tag: %s\n", image.Id, ExpiresAtTag, err)
+			continue
+		}
+		if currentTime.After(expirationTime) {
+			expiredImageIds = append(expiredImageIds, image.Id)
+		}
+	}
+	return p.deleteImages(expiredImageIds, logger)
+}
+
+func (p *Publisher) listImages(searchTags, excludeSearchTags libtags.Tags,
+	minImageAge time.Duration, logger log.DebugLogger) ([]Image, error) {
+	images, err := p.getAllImages()
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf(0, "found %d images\n", len(images))
+	results := make([]Image, 0, len(images))
+	for _, image := range images {
+		if !matchTags(image.Tags, searchTags, excludeSearchTags) {
+			continue
+		}
+		if minImageAge > 0 {
+			createdAt, err := time.Parse(time.RFC3339, image.CreatedAt)
+			if err != nil || time.Since(createdAt) < minImageAge {
+				continue
+			}
+		}
+		results = append(results, image)
+	}
+	return results, nil
+}
+
+func (p *Publisher) listUnusedImages(searchTags,
+	excludeSearchTags libtags.Tags, minImageAge time.Duration,
+	logger log.DebugLogger) (UnusedImagesResult, error) {
+	images, err := p.listImages(searchTags, excludeSearchTags, minImageAge,
+		logger)
+	if err != nil {
+		return UnusedImagesResult{}, err
+	}
+	instances, err := p.getAllInstances()
+	if err != nil {
+		return UnusedImagesResult{}, err
+	}
+	usedImages := make(map[string]struct{}, len(instances))
+	for _, instance := range instances {
+		usedImages[instance.ImageId] = struct{}{}
+	}
+	var results UnusedImagesResult
+	for _, image := range images {
+		if _, ok := usedImages[image.Id]; !ok {
+			results.UnusedImages = append(results.UnusedImages, image)
+		}
+	}
+	return results, nil
+}
+
+func (p *Publisher) listUsedImages(searchTags, excludeSearchTags libtags.Tags,
+	logger log.DebugLogger) (UsedImagesResult, error) {
+	images, err := p.listImages(searchTags, excludeSearchTags, 0, logger)
+	if err != nil {
+		return UsedImagesResult{}, err
+	}
+	instances, err := p.getAllInstances()
+	if err != nil {
+		return UsedImagesResult{}, err
+	}
+	imagesById := make(map[string]Image, len(images))
+	for _, image := range images {
+		imagesById[image.Id] = image
+	}
+	var results UsedImagesResult
+	usedImages := make(map[string]struct{})
+	for _, instance := range instances {
+		image, ok := imagesById[instance.ImageId]
+		if !ok {
+			continue
+		}
+		results.UsingInstances = append(results.UsingInstances, instance)
+		if _, ok := usedImages[image.Id]; !ok {
+			usedImages[image.Id] = struct{}{}
+			results.UsedImages = append(results.UsedImages, image)
+		}
+	}
+	return results, nil
+}
+
+func (p *Publisher) setTags(imageIds []string, tags libtags.Tags,
+	logger log.Logger) error {
+	operations := make([]patchOperation, 0, len(tags))
+	for key, value := range tags {
+		operations = append(operations, patchOperation{
+			Op:    "add", // Replaces existing properties.
+			Path:  "/" + key,
+			Value: value,
+		})
+	}
+	for _, imageId := range imageIds {
+		if err := p.patchImage(imageId, operations); err != nil {
+			return err
+		}
+		logger.Printf("set tags on: %s\n", imageId)
+	}
+	return nil
+}
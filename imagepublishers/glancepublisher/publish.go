@@ -0,0 +1,126 @@
+package glancepublisher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	uclient "github.com/Cloud-Foundations/Dominator/imageunpacker/client"
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/images/vhd"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+)
+
+func publishToUrl(unpackerAddress, streamName, imageLeafName, exportType,
+	url string, logger log.Logger) error {
+	srpcClient, err := srpc.DialHTTP("tcp", unpackerAddress, 0)
+	if err != nil {
+		return err
+	}
+	defer srpcClient.Close()
+	if err := unpack(srpcClient, streamName, imageLeafName, logger); err != nil {
+		return err
+	}
+	logger.Printf("Exporting: %s to: %s\n", streamName, url)
+	err = uclient.ExportImage(srpcClient, streamName, exportType, url)
+	if err != nil {
+		return err
+	}
+	// Kick off scan for next time.
+	return uclient.PrepareForUnpack(srpcClient, streamName, false, true)
+}
+
+// unpack will unpack the image into the stream device and prepare it for
+// capture.
+func unpack(srpcClient *srpc.Client, streamName, imageLeafName string,
+	logger log.Logger) error {
+	logger.Printf("Preparing to unpack: %s\n", streamName)
+	err := uclient.PrepareForUnpack(srpcClient, streamName, true, false)
+	if err != nil {
+		return err
+	}
+	logger.Printf("Unpacking: %s\n", streamName)
+	err = uclient.UnpackImage(srpcClient, streamName, imageLeafName)
+	if err != nil {
+		return err
+	}
+	logger.Printf("Preparing to capture: %s\n", streamName)
+	return uclient.PrepareForCapture(srpcClient, streamName)
+}
+
+func (p *Publisher) publish(params PublishParams, logger log.Logger) (
+	*Image, error) {
+	if params.DiskFormat == "" {
+		params.DiskFormat = DiskFormatRaw
+	}
+	if params.DiskFormat != DiskFormatRaw && params.DiskFormat != DiskFormatVhd {
+		return nil, errors.New("unsupported disk format: " + params.DiskFormat)
+	}
+	if params.ImageName == "" {
+		params.ImageName = path.Join(params.StreamName,
+			path.Base(params.ImageLeafName))
+	}
+	if params.Visibility == "" {
+		params.Visibility = "private"
+	}
+	srpcClient, err := srpc.DialHTTP("tcp", params.UnpackerAddress, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer srpcClient.Close()
+	err = unpack(srpcClient, params.StreamName, params.ImageLeafName, logger)
+	if err != nil {
+		return nil, err
+	}
+	image, err := p.createImage(params.ImageName, params.DiskFormat,
+		params.Visibility, params.Tags)
+	if err != nil {
+		return nil, err
+	}
+	logger.Printf("Created image: %s, uploading\n", image.Id)
+	if err := p.uploadFromUnpacker(srpcClient, params, image.Id,
+		logger); err != nil {
+		if err := p.deleteImage(image.Id); err != nil {
+			logger.Printf("Error deleting image: %s: %s\n", image.Id, err)
+		}
+		return nil, err
+	}
+	// Kick off scan for next time.
+	err = uclient.PrepareForUnpack(srpcClient, params.StreamName, false, true)
+	if err != nil {
+		return nil, err
+	}
+	image, err = p.getImage(image.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+func (p *Publisher) uploadFromUnpacker(srpcClient *srpc.Client,
+	params PublishParams, imageId string, logger log.Logger) error {
+	reader, size, err := uclient.GetRaw(srpcClient, params.StreamName)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	uploadSize := size
+	var uploadReader io.Reader = reader
+	if params.DiskFormat == DiskFormatVhd {
+		uploadSize += vhd.FooterSize
+		pipeReader, pipeWriter := io.Pipe()
+		defer pipeReader.Close()
+		go func() {
+			pipeWriter.CloseWithError(vhd.WriteFixed(pipeWriter, reader, size))
+		}()
+		uploadReader = pipeReader
+	}
+	if err := p.uploadImageData(imageId, uploadReader, uploadSize); err != nil {
+		return fmt.Errorf("error uploading image data: %s", err)
+	}
+	logger.Printf("Uploaded %s to image: %s\n", format.FormatBytes(uploadSize),
+		imageId)
+	return nil
+}
@@ -31,6 +31,11 @@ func CheckImage(client srpc.ClientI, name string) (bool, error) {
 	return checkImage(client, name)
 }
 
+func ChownChannel(client srpc.ClientI, dirname, channelName,
+	ownerGroup string) error {
+	return chownChannel(client, dirname, channelName, ownerGroup)
+}
+
 func ChownDirectory(client srpc.ClientI, dirname, ownerGroup string) error {
 	return chownDirectory(client, dirname, ownerGroup)
 }
@@ -61,6 +66,12 @@ func FindLatestImageReq(client srpc.ClientI,
 	return findLatestImage(client, request)
 }
 
+// GetChannels will return the channels for the specified directory.
+func GetChannels(client srpc.ClientI, dirname string) (
+	map[string]image.Channel, error) {
+	return getChannels(client, dirname)
+}
+
 func GetImage(client srpc.ClientI, name string) (*image.Image, error) {
 	return getImage(client, name, 0)
 }
@@ -136,8 +147,36 @@ func MakeDirectoryAll(client srpc.ClientI, dirname string) error {
 	return makeDirectory(client, dirname, true)
 }
 
+// ResolveChannel will return the name of the image that the channel reference
+// (of the form "directory@channel") points to.
+func ResolveChannel(client srpc.ClientI, reference string) (string, error) {
+	return resolveChannel(client, reference)
+}
+
+// ResolveImageName will resolve a channel reference or a directory name to an
+// image name. Channel references are resolved to the image the channel points
+// to and directories are resolved to the latest image in the directory. Other
+// names are returned unchanged.
+func ResolveImageName(client srpc.ClientI, name string) (string, error) {
+	return resolveImageName(client, name)
+}
+
 func RestoreImageFromArchive(client srpc.ClientI,
 	request proto.RestoreImageFromArchiveRequest) (
 	proto.RestoreImageFromArchiveResponse, error) {
 	return restoreImageFromArchive(client, request)
 }
+
+// SetChannel will point the channel in the directory to the specified image.
+// If imageName is empty the channel is deleted.
+func SetChannel(client srpc.ClientI, dirname, channelName, imageName,
+	comment string) error {
+	return setChannel(client, dirname, channelName, imageName, comment)
+}
+
+// SetRetentionPolicy will set or clear (if policy is nil) the retention policy
+// for the specified directory.
+func SetRetentionPolicy(client srpc.ClientI, dirname string,
+	policy *image.RetentionPolicy) error {
+	return setRetentionPolicy(client, dirname, policy)
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+func chownChannel(client srpc.ClientI, dirname, channelName,
+	ownerGroup string) error {
+	request := imageserver.ChangeChannelOwnerRequest{
+		ChannelName:   channelName,
+		DirectoryName: dirname,
+		OwnerGroup:    ownerGroup,
+	}
+	var reply imageserver.ChangeChannelOwnerResponse
+	err := client.RequestReply("ImageServer.ChownChannel", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
+func getChannels(client srpc.ClientI, dirname string) (
+	map[string]image.Channel, error) {
+	request := imageserver.GetChannelsRequest{DirectoryName: dirname}
+	var reply imageserver.GetChannelsResponse
+	err := client.RequestReply("ImageServer.GetChannels", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return nil, err
+	}
+	return reply.Channels, nil
+}
+
+func resolveChannel(client srpc.ClientI, reference string) (string, error) {
+	request := imageserver.ResolveChannelRequest{Reference: reference}
+	var reply imageserver.ResolveChannelResponse
+	err := client.RequestReply("ImageServer.ResolveChannel", request, &reply)
+	if err != nil {
+		return "", err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return "", err
+	}
+	return reply.ImageName, nil
+}
+
+func resolveImageName(client srpc.ClientI, name string) (string, error) {
+	if image.IsChannelReference(name) {
+		return resolveChannel(client, name)
+	}
+	if isDir, err := checkDirectory(client, name); err != nil {
+		return "", err
+	} else if !isDir {
+		return name, nil
+	}
+	imageName, err := FindLatestImage(client, name, false)
+	if err != nil {
+		return "", err
+	}
+	if imageName == "" {
+		return "", errors.New("no images in directory: " + name)
+	}
+	return imageName, nil
+}
+
+func setChannel(client srpc.ClientI, dirname, channelName, imageName,
+	comment string) error {
+	request := imageserver.SetChannelRequest{
+		ChannelName:   channelName,
+		Comment:       comment,
+		DirectoryName: dirname,
+		ImageName:     imageName,
+	}
+	var reply imageserver.SetChannelResponse
+	err := client.RequestReply("ImageServer.SetChannel", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
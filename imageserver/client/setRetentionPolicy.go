@@ -0,0 +1,23 @@
+package client
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+func setRetentionPolicy(client srpc.ClientI, dirname string,
+	policy *image.RetentionPolicy) error {
+	request := imageserver.SetRetentionPolicyRequest{
+		DirectoryName:   dirname,
+		RetentionPolicy: policy,
+	}
+	var reply imageserver.SetRetentionPolicyResponse
+	err := client.RequestReply("ImageServer.SetRetentionPolicy", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
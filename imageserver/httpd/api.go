@@ -73,6 +73,7 @@ func StartServer(config Config, params Params) error {
 	html.HandleFunc("/listReleaseNotes", myState.listReleaseNotesHandler)
 	html.HandleFunc("/listTriggers", myState.listTriggersHandler)
 	html.HandleFunc("/showImage", myState.showImageHandler)
+	html.HandleFunc("/showRetention", myState.showRetentionHandler)
 	if params.DaemonMode {
 		go http.Serve(listener, nil)
 	} else {
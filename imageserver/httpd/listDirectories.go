@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 	"text/template"
 
 	"github.com/Cloud-Foundations/Dominator/lib/html"
@@ -32,7 +34,8 @@ func (s state) listDirectoriesHandler(w http.ResponseWriter,
 	fmt.Fprintln(writer, "<body>")
 	fmt.Fprintln(writer, "<h3>")
 	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
-	tw, _ := html.NewTableWriter(writer, true, "Name", "Owner Group")
+	tw, _ := html.NewTableWriter(writer, true, "Name", "Owner Group",
+		"Channels")
 	for _, directory := range directories {
 		writeDirectory(tw, directory)
 	}
@@ -43,11 +46,19 @@ func (s state) listDirectoriesHandler(w http.ResponseWriter,
 func writeDirectory(tw *html.TableWriter, directory image.Directory) {
 	name := directory.Name
 	ownerGroup := directory.Metadata.OwnerGroup
+	channelNames := make([]string, 0, len(directory.Metadata.Channels))
+	for channelName, channel := range directory.Metadata.Channels {
+		channelNames = append(channelNames,
+			fmt.Sprintf("%s: %s", template.HTMLEscapeString(channelName),
+				template.HTMLEscapeString(channel.ImageName)))
+	}
+	sort.Strings(channelNames)
 	tw.WriteRow("", "",
 		fmt.Sprintf(
 			"<a href=\"listImages?directoryName=%s\">%s</a>",
 			url.QueryEscape(name), template.HTMLEscapeString(name),
 		),
 		ownerGroup,
+		strings.Join(channelNames, "<br>"),
 	)
 }
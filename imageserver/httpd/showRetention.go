@@ -0,0 +1,82 @@
+package httpd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/html"
+)
+
+func (s state) showRetentionHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	report := s.imageDataBase.GetRetentionReport()
+	if req.URL.RawQuery == "output=text" {
+		if report == nil {
+			return
+		}
+		for _, directory := range report.Directories {
+			for _, name := range directory.ImagesToDelete {
+				fmt.Fprintln(writer, name)
+			}
+		}
+		return
+	}
+	fmt.Fprintln(writer, "<title>imageserver retention policies</title>")
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>")
+	if report == nil {
+		fmt.Fprintln(writer, "Retention policies have not been evaluated<br>")
+		fmt.Fprintln(writer, "</body>")
+		return
+	}
+	fmt.Fprintf(writer, "Evaluated %s ago<br>\n",
+		format.Duration(time.Since(report.ComputedAt)))
+	if report.Error != "" {
+		fmt.Fprintf(writer,
+			"<font color=\"red\">Not evaluated: %s</font><br>\n",
+			template.HTMLEscapeString(report.Error))
+	} else if report.Enforced {
+		fmt.Fprintf(writer, "Deleted %d images, reclaimed %s<br>\n",
+			report.NumImagesDeleted, format.FormatBytes(report.BytesReclaimed))
+	} else {
+		fmt.Fprintln(writer,
+			`<font color="purple">Dry run: no images were deleted</font><br>`)
+	}
+	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
+	tw, _ := html.NewTableWriter(writer, true, "Directory", "Keep Latest",
+		"Maximum Age", "Images", "Images To Delete")
+	for _, directory := range report.Directories {
+		var maximumAge string
+		if directory.Policy.MaximumAge > 0 {
+			maximumAge = format.Duration(directory.Policy.MaximumAge)
+		}
+		var toDelete string
+		for _, name := range directory.ImagesToDelete {
+			toDelete += fmt.Sprintf("<a href=\"showImage?%s\">%s</a><br>",
+				name, template.HTMLEscapeString(name))
+		}
+		tw.WriteRow("", "",
+			fmt.Sprintf("<a href=\"listImages?directoryName=%s\">%s</a>",
+				url.QueryEscape(directory.Name),
+				template.HTMLEscapeString(directory.Name)),
+			fmt.Sprintf("%d", directory.Policy.KeepLatest),
+			maximumAge,
+			fmt.Sprintf("%d", directory.NumImages),
+			toDelete,
+		)
+	}
+	tw.Close()
+	fmt.Fprintln(writer, "</body>")
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"sync"
 	"text/template"
+	"time"
 
 	"github.com/Cloud-Foundations/Dominator/imageserver/scanner"
 	"github.com/Cloud-Foundations/Dominator/lib/filter"
@@ -29,8 +30,11 @@ var (
 
 type Config struct {
 	AllowUnauthenticatedReads   bool
+	DeltaStagingDirectory       string // Default: system temporary directory.
+	DeltaTransferMinimumSize    uint64 // Zero: disable delta transfers.
 	InformationDatabaseTemplate string
 	ReplicationMaster           string
+	ReplicationPeers            []string // Multi-master peers.
 }
 
 type Params struct {
@@ -41,6 +45,8 @@ type Params struct {
 
 type srpcType struct {
 	analysisGoroutine           *goroutine.Goroutine
+	deltaStagingDirectory       string
+	deltaTransferMinimumSize    uint64
 	imageDataBase               *scanner.ImageDataBase
 	excludeFilter               *filter.Filter
 	finishedReplication         <-chan struct{} // Closed when finished.
@@ -55,6 +61,28 @@ type srpcType struct {
 	numReplicationClients       uint
 	imagesBeingInjectedLock     sync.Mutex // Protect imagesBeingInjected.
 	imagesBeingInjected         map[string]struct{}
+	peers                       []*peerType
+}
+
+type peerType struct {
+	address   string
+	resource  *srpc.ClientResource
+	lock      sync.Mutex          // Protect everything below.
+	conflicts map[string]struct{} // Key: image name.
+	peerStatus
+}
+
+type peerStatus struct {
+	connected           bool
+	connectedSince      time.Time
+	initialListReceived bool
+	lag                 time.Duration // Delay for the last image added.
+	lastConflict        string        // Name of last conflicting image.
+	lastError           string
+	lastErrorTime       time.Time
+	lastUpdate          time.Time
+	numConflicts        uint64
+	numImagesAdded      uint64
 }
 
 type htmlWriter srpcType
@@ -70,16 +98,22 @@ func Setup(config Config, params Params) (*htmlWriter, error) {
 	if *archiveMode && config.ReplicationMaster == "" {
 		return nil, errors.New("replication master required in archive mode")
 	}
+	if config.ReplicationMaster != "" && len(config.ReplicationPeers) > 0 {
+		return nil,
+			errors.New("cannot have both replication master and peers")
+	}
 	analysisGoroutine, err := makeAnalysisGoroutine()
 	if err != nil {
 		return nil, err
 	}
 	finishedReplication := make(chan struct{})
 	srpcObj := &srpcType{
-		analysisGoroutine:   analysisGoroutine,
-		imageDataBase:       params.ImageDataBase,
-		finishedReplication: finishedReplication,
-		replicationMaster:   config.ReplicationMaster,
+		analysisGoroutine:        analysisGoroutine,
+		deltaStagingDirectory:    config.DeltaStagingDirectory,
+		deltaTransferMinimumSize: config.DeltaTransferMinimumSize,
+		imageDataBase:            params.ImageDataBase,
+		finishedReplication:      finishedReplication,
+		replicationMaster:        config.ReplicationMaster,
 		imageserverResource: srpc.NewClientResource("tcp",
 			config.ReplicationMaster),
 		objSrv:              params.ObjectServer,
@@ -95,6 +129,9 @@ func Setup(config Config, params Params) (*htmlWriter, error) {
 		}
 		srpcObj.informationDatabaseTemplate = tmpl
 	}
+	for _, address := range config.ReplicationPeers {
+		srpcObj.peers = append(srpcObj.peers, newPeer(address))
+	}
 	if *replicationExcludeFilter != "" {
 		srpcObj.excludeFilter, err = filter.Load(*replicationExcludeFilter)
 		if err != nil {
@@ -111,9 +148,11 @@ func Setup(config Config, params Params) (*htmlWriter, error) {
 		"ChangeImageExpiration",
 		"CheckDirectory",
 		"CheckImage",
+		"ChownChannel",
 		"ChownDirectory",
 		"DeleteImage",
 		"FindLatestImage",
+		"GetChannels",
 		"GetFilteredImageUpdates",
 		"GetImage",
 		"GetImageArchive",
@@ -128,6 +167,9 @@ func Setup(config Config, params Params) (*htmlWriter, error) {
 		"ListImages",
 		"ListSelectedImages",
 		"ListUnreferencedObjects",
+		"ResolveChannel",
+		"SetChannel",
+		"SetRetentionPolicy",
 	}
 	var unauthenticatedMethods []string
 	if config.AllowUnauthenticatedReads {
@@ -152,7 +194,28 @@ func Setup(config Config, params Params) (*htmlWriter, error) {
 		}
 	}
 	srpc.RegisterNameWithOptions("ImageServer", srpcObj, srpc.ReceiverOptions{
-		PublicMethods:          publicMethods,
+		PublicMethods: publicMethods,
+		ReadOnlyMethods: []string{
+			"CheckDirectory",
+			"CheckImage",
+			"FindLatestImage",
+			"GetChannels",
+			"GetFilteredImageUpdates",
+			"GetImage",
+			"GetImageArchive",
+			"GetImageComputedFiles",
+			"GetImageExpiration",
+			"GetImageInodes",
+			"GetImageUpdates",
+			"GetImageUsageEstimate",
+			"GetObjectStatisticsForImages",
+			"GetReplicationMaster",
+			"ListDirectories",
+			"ListImages",
+			"ListSelectedImages",
+			"ListUnreferencedObjects",
+			"ResolveChannel",
+		},
 		UnauthenticatedMethods: unauthenticatedMethods,
 	})
 	if config.ReplicationMaster != "" {
@@ -160,6 +223,9 @@ func Setup(config Config, params Params) (*htmlWriter, error) {
 	} else {
 		close(finishedReplication)
 	}
+	for _, peer := range srpcObj.peers {
+		go srpcObj.peerReplicator(peer)
+	}
 	return (*htmlWriter)(srpcObj), nil
 }
 
@@ -0,0 +1,79 @@
+package rpcd
+
+import (
+	"os/user"
+
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) ChownChannel(conn *srpc.Conn,
+	request imageserver.ChangeChannelOwnerRequest,
+	reply *imageserver.ChangeChannelOwnerResponse) error {
+	if err := t.checkMutability(); err != nil {
+		reply.Error = errors.ErrorToString(err)
+		return nil
+	}
+	if request.OwnerGroup != "" {
+		if _, err := user.LookupGroup(request.OwnerGroup); err != nil {
+			reply.Error = errors.ErrorToString(err)
+			return nil
+		}
+	}
+	err := t.imageDataBase.ChownChannel(request.DirectoryName,
+		request.ChannelName, request.OwnerGroup, conn.GetAuthInformation())
+	if err == nil {
+		t.logger.Printf("ChownChannel(%s@%s) to: \"%s\" by %s\n",
+			request.DirectoryName, request.ChannelName, request.OwnerGroup,
+			conn.Username())
+	}
+	reply.Error = errors.ErrorToString(err)
+	return nil
+}
+
+func (t *srpcType) GetChannels(conn *srpc.Conn,
+	request imageserver.GetChannelsRequest,
+	reply *imageserver.GetChannelsResponse) error {
+	channels, err := t.imageDataBase.GetChannels(request.DirectoryName)
+	*reply = imageserver.GetChannelsResponse{
+		Channels: channels,
+		Error:    errors.ErrorToString(err),
+	}
+	return nil
+}
+
+func (t *srpcType) ResolveChannel(conn *srpc.Conn,
+	request imageserver.ResolveChannelRequest,
+	reply *imageserver.ResolveChannelResponse) error {
+	imageName, err := t.imageDataBase.ResolveChannel(request.Reference)
+	*reply = imageserver.ResolveChannelResponse{
+		Error:     errors.ErrorToString(err),
+		ImageName: imageName,
+	}
+	return nil
+}
+
+func (t *srpcType) SetChannel(conn *srpc.Conn,
+	request imageserver.SetChannelRequest,
+	reply *imageserver.SetChannelResponse) error {
+	if err := t.checkMutability(); err != nil {
+		reply.Error = errors.ErrorToString(err)
+		return nil
+	}
+	err := t.imageDataBase.SetChannel(request.DirectoryName,
+		request.ChannelName, request.ImageName, request.Comment,
+		conn.GetAuthInformation())
+	if err == nil {
+		if request.ImageName == "" {
+			t.logger.Printf("SetChannel(%s@%s) deleted by %s\n",
+				request.DirectoryName, request.ChannelName, conn.Username())
+		} else {
+			t.logger.Printf("SetChannel(%s@%s) to: %s by %s\n",
+				request.DirectoryName, request.ChannelName, request.ImageName,
+				conn.Username())
+		}
+	}
+	reply.Error = errors.ErrorToString(err)
+	return nil
+}
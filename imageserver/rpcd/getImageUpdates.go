@@ -63,12 +63,21 @@ func (t *srpcType) getImageUpdates(conn *srpc.Conn,
 		if t.checkIgnoreImage(request.IgnoreExpiring, imageName) {
 			continue
 		}
-		imageUpdate := imageserver.ImageUpdate{Name: imageName}
-		if err := conn.Encode(imageUpdate); err != nil {
+		if err := t.sendAddImage(conn, imageName); err != nil {
 			t.logger.Println(err)
 			return err
 		}
 	}
+	if request.IncludeTombstones {
+		tombstones := t.imageDataBase.ListTombstones()
+		for imageName, deletedOn := range tombstones {
+			if err := sendDeleteImage(conn, imageName,
+				deletedOn); err != nil {
+				t.logger.Println(err)
+				return err
+			}
+		}
+	}
 	// Signal end of initial image list.
 	if err := conn.Encode(imageserver.ImageUpdate{}); err != nil {
 		t.logger.Println(err)
@@ -88,14 +97,14 @@ func (t *srpcType) getImageUpdates(conn *srpc.Conn,
 			if t.checkIgnoreImage(request.IgnoreExpiring, imageName) {
 				break
 			}
-			if err := sendUpdate(conn, imageName,
-				imageserver.OperationAddImage); err != nil {
+			if err := t.sendAddImage(conn, imageName); err != nil {
 				t.logger.Println(err)
 				return err
 			}
 		case imageName := <-deleteChannel:
-			if err := sendUpdate(conn, imageName,
-				imageserver.OperationDeleteImage); err != nil {
+			deletedOn, _ := t.imageDataBase.GetTombstone(imageName)
+			if err := sendDeleteImage(conn, imageName,
+				deletedOn); err != nil {
 				t.logger.Println(err)
 				return err
 			}
@@ -150,6 +159,26 @@ func (t *srpcType) incrementNumReplicationClients(increment bool) {
 	}
 }
 
+func (t *srpcType) sendAddImage(encoder srpc.Encoder, name string) error {
+	imageUpdate := imageserver.ImageUpdate{
+		Name:      name,
+		Operation: imageserver.OperationAddImage,
+	}
+	if img := t.imageDataBase.GetImage(name); img != nil {
+		imageUpdate.CreatedOn = img.CreatedOn
+	}
+	return encoder.Encode(imageUpdate)
+}
+
+func sendDeleteImage(encoder srpc.Encoder, name string,
+	deletedOn time.Time) error {
+	return encoder.Encode(imageserver.ImageUpdate{
+		DeletedOn: deletedOn,
+		Name:      name,
+		Operation: imageserver.OperationDeleteImage,
+	})
+}
+
 func sendUpdate(encoder srpc.Encoder, name string, operation uint) error {
 	imageUpdate := imageserver.ImageUpdate{Name: name, Operation: operation}
 	return encoder.Encode(imageUpdate)
@@ -3,6 +3,11 @@ package rpcd
 import (
 	"fmt"
 	"io"
+	"text/template"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/html"
 )
 
 func (hw *htmlWriter) writeHtml(writer io.Writer) {
@@ -12,6 +17,9 @@ func (hw *htmlWriter) writeHtml(writer io.Writer) {
 	}
 	fmt.Fprintf(writer, "Replication clients: %d<br>\n",
 		hw.getNumReplicationClients())
+	if len(hw.peers) > 0 {
+		hw.writePeers(writer)
+	}
 }
 
 func (hw *htmlWriter) getNumReplicationClients() uint {
@@ -19,3 +27,57 @@ func (hw *htmlWriter) getNumReplicationClients() uint {
 	defer hw.numReplicationClientsLock.RUnlock()
 	return hw.numReplicationClients
 }
+
+func (hw *htmlWriter) writePeers(writer io.Writer) {
+	fmt.Fprintln(writer, "Replication peers:<br>")
+	fmt.Fprintln(writer, `<table border="1">`)
+	tw, _ := html.NewTableWriter(writer, true, "Peer", "State", "Last Update",
+		"Replication Lag", "Images Added", "Conflicts", "Last Error")
+	for _, peer := range hw.peers {
+		status := peer.getStatus()
+		var background, state string
+		if !status.connected {
+			background = "red"
+			state = "disconnected"
+		} else if !status.initialListReceived {
+			background = "yellow"
+			state = "synchronising"
+		} else {
+			state = "connected for " +
+				format.Duration(time.Since(status.connectedSince))
+		}
+		var conflicts, lag, lastError, lastUpdate string
+		if status.numConflicts > 0 {
+			if background == "" {
+				background = "yellow"
+			}
+			conflicts = fmt.Sprintf("%d (last: %s)", status.numConflicts,
+				template.HTMLEscapeString(status.lastConflict))
+		} else {
+			conflicts = "0"
+		}
+		if status.initialListReceived {
+			lag = format.Duration(status.lag)
+		}
+		if !status.lastUpdate.IsZero() {
+			lastUpdate = format.Duration(time.Since(status.lastUpdate)) +
+				" ago"
+		}
+		if status.lastError != "" {
+			lastError = fmt.Sprintf("%s (%s ago)",
+				template.HTMLEscapeString(status.lastError),
+				format.Duration(time.Since(status.lastErrorTime)))
+		}
+		tw.WriteRow("", background,
+			fmt.Sprintf("<a href=\"http://%s/\">%s</a>",
+				peer.address, peer.address),
+			state,
+			lastUpdate,
+			lag,
+			fmt.Sprintf("%d", status.numImagesAdded),
+			conflicts,
+			lastError,
+		)
+	}
+	tw.Close()
+}
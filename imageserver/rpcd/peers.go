@@ -0,0 +1,274 @@
+package rpcd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	imageclient "github.com/Cloud-Foundations/Dominator/imageserver/client"
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/prefixlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+// Conflict resolution rules for multi-master (peer) replication:
+//   - Image names are immutable: an image which exists locally is never
+//     replaced. If a peer has a different image with the same name (a
+//     conflicting add), the conflict is recorded and reported
+//   - Deleted images leave a tombstone and a tombstoned name cannot be added
+//     again (locally or from a peer), so a delete wins over a concurrent add.
+//     Tombstones carry the time of the original delete, so they expire at the
+//     same time on every peer
+//   - Directory metadata with the later modification time wins.
+
+const (
+	peerAddIgnore = iota
+	peerAddImage
+	peerAddConflict
+)
+
+// decidePeerAdd returns the action to take for an image added on a peer, given
+// whether the image name has a local tombstone, the local image (nil if there
+// is none) and the creation time of the image on the peer.
+func decidePeerAdd(tombstoned bool, localImage *image.Image,
+	createdOn time.Time) uint {
+	if tombstoned {
+		return peerAddIgnore
+	}
+	if localImage == nil {
+		return peerAddImage
+	}
+	if !createdOn.IsZero() && !createdOn.Equal(localImage.CreatedOn) {
+		return peerAddConflict
+	}
+	return peerAddIgnore
+}
+
+func newPeer(address string) *peerType {
+	return &peerType{
+		address:   address,
+		conflicts: make(map[string]struct{}),
+		resource:  srpc.NewClientResource("tcp", address),
+	}
+}
+
+func (peer *peerType) getStatus() peerStatus {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	return peer.peerStatus
+}
+
+func (peer *peerType) recordAdd(createdOn time.Time) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	peer.numImagesAdded++
+	if peer.initialListReceived && !createdOn.IsZero() {
+		peer.lag = time.Since(createdOn)
+	}
+}
+
+func (peer *peerType) recordConflict(name string) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	if _, ok := peer.conflicts[name]; ok {
+		return
+	}
+	peer.conflicts[name] = struct{}{}
+	peer.numConflicts++
+	peer.lastConflict = name
+}
+
+func (peer *peerType) recordError(err error) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	peer.lastError = err.Error()
+	peer.lastErrorTime = time.Now()
+}
+
+func (peer *peerType) setConnected(connected bool) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	peer.connected = connected
+	peer.initialListReceived = false
+	if connected {
+		peer.connectedSince = time.Now()
+	}
+}
+
+func (peer *peerType) setInitialListReceived() {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	peer.initialListReceived = true
+	peer.lag = 0
+}
+
+func (peer *peerType) updateReceived() {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	peer.lastUpdate = time.Now()
+}
+
+func (t *srpcType) peerReplicator(peer *peerType) {
+	initialTimeout := time.Second * 15
+	timeout := initialTimeout
+	logger := prefixlogger.New(fmt.Sprintf("Peer(%s): ", peer.address),
+		t.logger)
+	for {
+		nextSleepStopTime := time.Now().Add(timeout)
+		if client, err := srpc.DialHTTP("tcp", peer.address,
+			timeout); err != nil {
+			logger.Printf("Error dialing: %s\n", err)
+			peer.recordError(err)
+		} else {
+			conn, err := client.Call("ImageServer.GetFilteredImageUpdates")
+			if err != nil {
+				logger.Println(err)
+				peer.recordError(err)
+			} else {
+				peer.setConnected(true)
+				err := t.getPeerUpdates(conn, peer)
+				peer.setConnected(false)
+				if err == io.EOF {
+					logger.Println("Connection to peer closed")
+					if nextSleepStopTime.Sub(time.Now()) < 1 {
+						timeout = initialTimeout
+					}
+				} else if err != nil {
+					logger.Println(err)
+					peer.recordError(err)
+				}
+				conn.Close()
+			}
+			client.Close()
+			peer.resource.ScheduleClose()
+		}
+		time.Sleep(nextSleepStopTime.Sub(time.Now()))
+		if timeout < time.Minute {
+			timeout *= 2
+		}
+	}
+}
+
+func (t *srpcType) getPeerUpdates(conn *srpc.Conn, peer *peerType) error {
+	t.logger.Printf("Peer replicator: connected to: %s\n", peer.address)
+	replicationStartTime := time.Now()
+	request := imageserver.GetFilteredImageUpdatesRequest{
+		IncludeTombstones: true,
+	}
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for {
+		var imageUpdate imageserver.ImageUpdate
+		if err := conn.Decode(&imageUpdate); err != nil {
+			if err == io.EOF {
+				return err
+			}
+			return errors.New("decode err: " + err.Error())
+		}
+		peer.updateReceived()
+		if imageUpdate.Operation == imageserver.OperationAddImage &&
+			imageUpdate.Name == "" {
+			peer.setInitialListReceived()
+			t.logger.Printf("Peer replicator: synchronised with: %s in %s\n",
+				peer.address, format.Duration(time.Since(replicationStartTime)))
+			continue
+		}
+		// Failing to apply a single update should not stall replication.
+		if err := t.applyPeerUpdate(peer, imageUpdate); err != nil {
+			t.logger.Printf("Peer(%s): %s: %s\n",
+				peer.address, imageUpdate.Name, err)
+			peer.recordError(err)
+		}
+	}
+}
+
+func (t *srpcType) applyPeerUpdate(peer *peerType,
+	imageUpdate imageserver.ImageUpdate) error {
+	switch imageUpdate.Operation {
+	case imageserver.OperationAddImage:
+		name := imageUpdate.Name
+		switch decidePeerAdd(t.imageDataBase.CheckTombstone(name),
+			t.imageDataBase.GetImage(name), imageUpdate.CreatedOn) {
+		case peerAddConflict:
+			t.logger.Printf(
+				"Peer(%s): %s: conflicting add, keeping local image\n",
+				peer.address, name)
+			peer.recordConflict(name)
+		case peerAddImage:
+			if err := t.addPeerImage(peer, name); err != nil {
+				return err
+			}
+			peer.recordAdd(imageUpdate.CreatedOn)
+		}
+	case imageserver.OperationDeleteImage:
+		return t.imageDataBase.RecordDeletedImage(imageUpdate.Name,
+			imageUpdate.DeletedOn)
+	case imageserver.OperationMakeDirectory:
+		directory := imageUpdate.Directory
+		if directory == nil {
+			return errors.New("nil imageUpdate.Directory")
+		}
+		for _, localDirectory := range t.imageDataBase.ListDirectories() {
+			if localDirectory.Name != directory.Name {
+				continue
+			}
+			if !directory.Metadata.ModifiedAt.After(
+				localDirectory.Metadata.ModifiedAt) {
+				return nil
+			}
+			break
+		}
+		return t.imageDataBase.UpdateDirectory(*directory)
+	case imageserver.OperationDeleteDirectory:
+		if !t.imageDataBase.CheckDirectory(imageUpdate.Name) {
+			return nil
+		}
+		return t.imageDataBase.DeleteDirectory(imageUpdate.Name,
+			&srpc.AuthInformation{HaveMethodAccess: true})
+	default:
+		return fmt.Errorf("unknown operation: %d", imageUpdate.Operation)
+	}
+	return nil
+}
+
+func (t *srpcType) addPeerImage(peer *peerType, name string) error {
+	timeout := time.Second * 60
+	logger := prefixlogger.New(
+		fmt.Sprintf("Peer(%s): %s: ", peer.address, name), t.logger)
+	client, err := peer.resource.GetHTTP(nil, timeout)
+	if err != nil {
+		return err
+	}
+	defer client.Put()
+	img, err := imageclient.GetImage(client, name)
+	if err != nil {
+		client.Close()
+		return err
+	}
+	if img == nil {
+		return nil // Deleted on the peer since: the delete will follow.
+	}
+	logger.Println("downloaded image")
+	img.FileSystem.RebuildInodePointers()
+	err = t.imageDataBase.DoWithPendingImage(img, func() error {
+		if err := t.getMissingObjects(img, name, client,
+			logger); err != nil {
+			client.Close()
+			return err
+		}
+		return t.imageDataBase.AddImage(img, name,
+			&srpc.AuthInformation{HaveMethodAccess: true})
+	})
+	if err != nil {
+		return err
+	}
+	logger.Println("added image")
+	return nil
+}
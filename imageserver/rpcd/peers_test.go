@@ -0,0 +1,38 @@
+package rpcd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+)
+
+func TestDecidePeerAdd(t *testing.T) {
+	createdOn := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	earlier := createdOn.Add(-time.Hour)
+	later := createdOn.Add(time.Hour)
+	local := &image.Image{CreatedOn: createdOn}
+	tests := []struct {
+		name       string
+		tombstoned bool
+		local      *image.Image
+		createdOn  time.Time
+		want       uint
+	}{
+		{"new image", false, nil, createdOn, peerAddImage},
+		{"new image without time", false, nil, time.Time{}, peerAddImage},
+		{"deleted", true, nil, createdOn, peerAddIgnore},
+		{"deleted and present", true, local, createdOn, peerAddIgnore},
+		{"same image", false, local, createdOn, peerAddIgnore},
+		{"unknown time", false, local, time.Time{}, peerAddIgnore},
+		{"earlier conflict", false, local, earlier, peerAddConflict},
+		{"later conflict", false, local, later, peerAddConflict},
+	}
+	for _, test := range tests {
+		got := decidePeerAdd(test.tombstoned, test.local, test.createdOn)
+		if got != test.want {
+			t.Errorf("%s: decidePeerAdd() = %d, want: %d",
+				test.name, got, test.want)
+		}
+	}
+}
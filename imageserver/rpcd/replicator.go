@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path"
 	"time"
 
 	imageclient "github.com/Cloud-Foundations/Dominator/imageserver/client"
@@ -273,7 +274,8 @@ func (t *srpcType) addImage(name string) error {
 	}
 	img.FileSystem.RebuildInodePointers()
 	err = t.imageDataBase.DoWithPendingImage(img, func() error {
-		if err := t.getMissingObjects(img, client, logger); err != nil {
+		if err := t.getMissingObjects(img, name, client,
+			logger); err != nil {
 			client.Close()
 			return err
 		}
@@ -298,9 +300,27 @@ func (t *srpcType) checkImageBeingInjected(name string) bool {
 	return ok
 }
 
-func (t *srpcType) getMissingObjects(img *image.Image, client *srpc.Client,
-	logger log.DebugLogger) error {
+func (t *srpcType) getMissingObjects(img *image.Image, name string,
+	client *srpc.Client, logger log.DebugLogger) error {
 	objClient := objectclient.AttachObjectClient(client)
 	defer objClient.Close()
+	if t.deltaTransferMinimumSize > 0 {
+		if previous := t.getPreviousImage(name); previous != nil {
+			return img.GetMissingObjectsWithDeltas(t.objSrv, objClient,
+				previous, t.deltaTransferMinimumSize, t.deltaStagingDirectory,
+				logger)
+		}
+	}
 	return img.GetMissingObjects(t.objSrv, objClient, logger)
 }
+
+// getPreviousImage returns the latest image in the same directory as the
+// specified image, or nil if there is none.
+func (t *srpcType) getPreviousImage(name string) *image.Image {
+	previousName, err := t.imageDataBase.FindLatestImage(
+		imageserver.FindLatestImageRequest{DirectoryName: path.Dir(name)})
+	if err != nil || previousName == "" || previousName == name {
+		return nil
+	}
+	return t.imageDataBase.GetImage(previousName)
+}
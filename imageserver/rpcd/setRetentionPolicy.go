@@ -0,0 +1,31 @@
+package rpcd
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/errors"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) SetRetentionPolicy(conn *srpc.Conn,
+	request imageserver.SetRetentionPolicyRequest,
+	reply *imageserver.SetRetentionPolicyResponse) error {
+	if err := t.checkMutability(); err != nil {
+		reply.Error = errors.ErrorToString(err)
+		return nil
+	}
+	err := t.imageDataBase.SetRetentionPolicy(request.DirectoryName,
+		request.RetentionPolicy, conn.GetAuthInformation())
+	if err == nil {
+		if policy := request.RetentionPolicy; policy == nil {
+			t.logger.Printf("SetRetentionPolicy(%s) cleared by %s\n",
+				request.DirectoryName, conn.Username())
+		} else {
+			t.logger.Printf(
+				"SetRetentionPolicy(%s) keep: %d, maximum age: %s by %s\n",
+				request.DirectoryName, policy.KeepLatest, policy.MaximumAge,
+				conn.Username())
+		}
+	}
+	reply.Error = errors.ErrorToString(err)
+	return nil
+}
@@ -27,6 +27,28 @@ type Config struct {
 	MaximumExpirationDuration           time.Duration // Default: 1 day.
 	MaximumExpirationDurationPrivileged time.Duration // Default: 1 month.
 	ReplicationMaster                   string
+	RetentionCheckInterval              time.Duration // Zero: disabled.
+	RetentionEnforce                    bool          // False: report only.
+	TombstoneLifetime                   time.Duration // Zero: forever.
+}
+
+// DirectoryRetention lists the images in a directory which are to be deleted by
+// the retention policy for the directory.
+type DirectoryRetention struct {
+	ImagesToDelete []string
+	Name           string
+	NumImages      uint
+	Policy         image.RetentionPolicy
+}
+
+// RetentionReport is the result of evaluating the retention policies.
+type RetentionReport struct {
+	BytesReclaimed   uint64
+	ComputedAt       time.Time
+	Directories      []DirectoryRetention
+	Enforced         bool
+	Error            string // Policies were not evaluated if set.
+	NumImagesDeleted uint
 }
 
 type notifiers map[<-chan string]chan<- string
@@ -47,9 +69,12 @@ type ImageDataBase struct {
 	deleteNotifiers notifiers
 	mkdirNotifiers  makeDirectoryNotifiers
 	rmdirNotifiers  notifiers
+	tombstones      map[string]time.Time // Deleted images. Value: deleted at.
 	// Unprotected by main lock.
 	pendingImageLock sync.Mutex
 	objectFetchLock  sync.Mutex
+	retentionLock    sync.Mutex // Protect retentionReport.
+	retentionReport  *RetentionReport
 }
 
 type imageType struct {
@@ -64,8 +89,12 @@ type imageType struct {
 }
 
 type Params struct {
-	Logger       log.DebugLogger
-	ObjectServer objectserver.FullObjectServer
+	// GetReferencedImages is optional. If provided, it returns the names of
+	// images which are in use (such as those in the MDB) and should not be
+	// deleted by retention policies.
+	GetReferencedImages func() (map[string]struct{}, error)
+	Logger              log.DebugLogger
+	ObjectServer        objectserver.FullObjectServer
 }
 
 func Load(config Config, params Params) (*ImageDataBase, error) {
@@ -103,6 +132,19 @@ func (imdb *ImageDataBase) CheckImage(name string) bool {
 	return imdb.checkImage(name)
 }
 
+// CheckTombstone returns true if the image was deleted. Names of deleted images
+// may not be re-used.
+func (imdb *ImageDataBase) CheckTombstone(name string) bool {
+	return imdb.checkTombstone(name)
+}
+
+// ChownChannel will set the owner group for a channel. Membership of the
+// directory owner group is required.
+func (imdb *ImageDataBase) ChownChannel(dirname, channelName,
+	ownerGroup string, authInfo *srpc.AuthInformation) error {
+	return imdb.chownChannel(dirname, channelName, ownerGroup, authInfo)
+}
+
 func (imdb *ImageDataBase) ChownDirectory(dirname, ownerGroup string,
 	authInfo *srpc.AuthInformation) error {
 	return imdb.chownDirectory(dirname, ownerGroup, authInfo)
@@ -147,6 +189,12 @@ func (imdb *ImageDataBase) FindLatestImage(
 	return imdb.findLatestImage(request)
 }
 
+// GetChannels will return the channels for the specified directory.
+func (imdb *ImageDataBase) GetChannels(dirname string) (
+	map[string]image.Channel, error) {
+	return imdb.getChannels(dirname)
+}
+
 func (imdb *ImageDataBase) GetImage(name string) *image.Image {
 	return imdb.getImage(name)
 }
@@ -187,6 +235,18 @@ func (imdb *ImageDataBase) GetImageUsageEstimate(name string) (uint64, bool) {
 	return imdb.getImageUsageEstimate(name)
 }
 
+// GetRetentionReport will return the result of the last evaluation of the
+// retention policies. It returns nil if they have not been evaluated.
+func (imdb *ImageDataBase) GetRetentionReport() *RetentionReport {
+	return imdb.getRetentionReport()
+}
+
+// GetTombstone returns the time the image was deleted and true if there is a
+// tombstone for the image.
+func (imdb *ImageDataBase) GetTombstone(name string) (time.Time, bool) {
+	return imdb.getTombstone(name)
+}
+
 func (imdb *ImageDataBase) GetUnreferencedObjectsStatistics() (uint64, uint64) {
 	return 0, 0
 }
@@ -204,6 +264,12 @@ func (imdb *ImageDataBase) ListSelectedImages(
 	return imdb.listImages(request)
 }
 
+// ListTombstones will return the names of deleted images and the times they
+// were deleted.
+func (imdb *ImageDataBase) ListTombstones() map[string]time.Time {
+	return imdb.listTombstones()
+}
+
 // ListUnreferencedObjects will return a map listing all the objects and their
 // corresponding sizes which are not referenced by an image.
 // Note that some objects may have been recently added and the referencing image
@@ -227,6 +293,16 @@ func (imdb *ImageDataBase) ObjectServer() objectserver.ObjectServer {
 	return imdb.Params.ObjectServer
 }
 
+// RecordDeletedImage will delete the specified image if present and will record
+// a tombstone for it, with the time it was deleted (if zero, the current time).
+// It is used to apply deletions made on a replication peer and will succeed if
+// the image was already deleted. As with DeleteImage, images referenced by a
+// channel are not deleted.
+func (imdb *ImageDataBase) RecordDeletedImage(name string,
+	deletedAt time.Time) error {
+	return imdb.recordDeletedImage(name, deletedAt)
+}
+
 func (imdb *ImageDataBase) RegisterAddNotifier() <-chan string {
 	return imdb.registerAddNotifier()
 }
@@ -243,12 +319,33 @@ func (imdb *ImageDataBase) RegisterMakeDirectoryNotifier() <-chan image.Director
 	return imdb.registerMakeDirectoryNotifier()
 }
 
+// ResolveChannel will return the name of the image that the channel reference
+// (of the form "directory@channel") points to.
+func (imdb *ImageDataBase) ResolveChannel(reference string) (string, error) {
+	return imdb.resolveChannel(reference)
+}
+
 func (imdb *ImageDataBase) RestoreImageFromArchive(
 	request proto.RestoreImageFromArchiveRequest,
 	authInfo *srpc.AuthInformation) error {
 	return imdb.restoreImageFromArchive(request, authInfo)
 }
 
+// SetChannel will atomically point the channel in the directory to the
+// specified image, recording the update in the channel history. If imageName
+// is empty the channel is deleted.
+func (imdb *ImageDataBase) SetChannel(dirname, channelName, imageName,
+	comment string, authInfo *srpc.AuthInformation) error {
+	return imdb.setChannel(dirname, channelName, imageName, comment, authInfo)
+}
+
+// SetRetentionPolicy will set or clear (if policy is nil) the retention policy
+// for a directory. Membership of the directory owner group is required.
+func (imdb *ImageDataBase) SetRetentionPolicy(dirname string,
+	policy *image.RetentionPolicy, authInfo *srpc.AuthInformation) error {
+	return imdb.setRetentionPolicy(dirname, policy, authInfo)
+}
+
 func (imdb *ImageDataBase) UnregisterAddNotifier(channel <-chan string) {
 	imdb.unregisterAddNotifier(channel)
 }
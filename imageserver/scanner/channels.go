@@ -0,0 +1,205 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+)
+
+const maxChannelHistory = 100
+
+var channelNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// checkChannelAccess returns nil if the channel may be updated. If the channel
+// has an owner group, membership of that group is required, otherwise
+// membership of the directory owner group is required. This must be called
+// with the lock held.
+func (imdb *ImageDataBase) checkChannelAccess(dirname string,
+	channel image.Channel, authInfo *srpc.AuthInformation) error {
+	if authInfo == nil {
+		return errNoAuthInfo
+	}
+	if authInfo.HaveMethodAccess {
+		return nil
+	}
+	ownerGroup := channel.OwnerGroup
+	if ownerGroup == "" {
+		ownerGroup = imdb.directoryMap[dirname].OwnerGroup
+	}
+	if ownerGroup == "" {
+		return errNoAccess
+	}
+	if _, ok := authInfo.GroupList[ownerGroup]; !ok {
+		return fmt.Errorf("no membership of %s group", ownerGroup)
+	}
+	return nil
+}
+
+// checkDirectoryOwner returns nil if the directory owner group may be changed.
+// This must be called with the lock held.
+func (imdb *ImageDataBase) checkDirectoryOwner(dirname string,
+	authInfo *srpc.AuthInformation) error {
+	if authInfo == nil {
+		return errNoAuthInfo
+	}
+	if authInfo.HaveMethodAccess {
+		return nil
+	}
+	ownerGroup := imdb.directoryMap[dirname].OwnerGroup
+	if ownerGroup == "" {
+		return errNoAccess
+	}
+	if _, ok := authInfo.GroupList[ownerGroup]; !ok {
+		return fmt.Errorf("no membership of %s group", ownerGroup)
+	}
+	return nil
+}
+
+func (imdb *ImageDataBase) chownChannel(dirname, channelName,
+	ownerGroup string, authInfo *srpc.AuthInformation) error {
+	dirname = filepath.Clean(dirname)
+	imdb.Lock()
+	defer imdb.Unlock()
+	directoryMetadata, ok := imdb.directoryMap[dirname]
+	if !ok {
+		return errors.New("unknown directory: " + dirname)
+	}
+	channel, ok := directoryMetadata.Channels[channelName]
+	if !ok {
+		return errors.New("unknown channel: " + dirname + "@" + channelName)
+	}
+	if err := imdb.checkDirectoryOwner(dirname, authInfo); err != nil {
+		return err
+	}
+	channel.OwnerGroup = ownerGroup
+	return imdb.updateChannelWithLock(dirname, directoryMetadata, channelName,
+		&channel)
+}
+
+func (imdb *ImageDataBase) getChannels(dirname string) (
+	map[string]image.Channel, error) {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	directoryMetadata, ok := imdb.directoryMap[dirname]
+	if !ok {
+		return nil, errors.New("unknown directory: " + dirname)
+	}
+	return directoryMetadata.Channels, nil
+}
+
+// getImageChannelWithLock returns the name of a channel which references the
+// image, or an empty string. This must be called with the lock held.
+func (imdb *ImageDataBase) getImageChannelWithLock(name string) string {
+	dirname := filepath.Dir(name)
+	for channelName, channel := range imdb.directoryMap[dirname].Channels {
+		if channel.ImageName == name {
+			return dirname + "@" + channelName
+		}
+	}
+	return ""
+}
+
+func (imdb *ImageDataBase) resolveChannel(reference string) (string, error) {
+	dirname, channelName, ok := image.SplitChannelReference(reference)
+	if !ok {
+		return "", errors.New("bad channel reference: " + reference)
+	}
+	imdb.RLock()
+	defer imdb.RUnlock()
+	directoryMetadata, ok := imdb.directoryMap[dirname]
+	if !ok {
+		return "", errors.New("unknown directory: " + dirname)
+	}
+	channel, ok := directoryMetadata.Channels[channelName]
+	if !ok {
+		return "", errors.New("unknown channel: " + reference)
+	}
+	return channel.ImageName, nil
+}
+
+func (imdb *ImageDataBase) setChannel(dirname, channelName, imageName,
+	comment string, authInfo *srpc.AuthInformation) error {
+	dirname = filepath.Clean(dirname)
+	if !channelNameRegex.MatchString(channelName) {
+		return errors.New("bad channel name: " + channelName)
+	}
+	imdb.Lock()
+	defer imdb.Unlock()
+	directoryMetadata, ok := imdb.directoryMap[dirname]
+	if !ok {
+		return errors.New("unknown directory: " + dirname)
+	}
+	channel := directoryMetadata.Channels[channelName]
+	if err := imdb.checkChannelAccess(dirname, channel, authInfo); err != nil {
+		return err
+	}
+	if imageName == "" {
+		if _, ok := directoryMetadata.Channels[channelName]; !ok {
+			return errors.New("unknown channel: " + dirname + "@" +
+				channelName)
+		}
+		return imdb.updateChannelWithLock(dirname, directoryMetadata,
+			channelName, nil)
+	}
+	if filepath.Dir(imageName) != dirname {
+		return fmt.Errorf("image: %s not in directory: %s", imageName, dirname)
+	}
+	img, ok := imdb.getImageWithLock(imageName)
+	if !ok {
+		return errors.New("image: " + imageName + " does not exist")
+	}
+	if img == nil {
+		return errors.New("image: " + imageName + " is being written")
+	}
+	if !img.ExpiresAt.IsZero() {
+		return errors.New("cannot promote expiring image: " + imageName)
+	}
+	update := image.ChannelUpdate{
+		Comment:   comment,
+		ImageName: imageName,
+		UpdatedAt: time.Now(),
+		UpdatedBy: authInfo.Username,
+	}
+	// Copy the history: the old slice may be shared with readers.
+	history := channel.History
+	if len(history) >= maxChannelHistory {
+		history = history[len(history)-maxChannelHistory+1:]
+	}
+	channel.History = make([]image.ChannelUpdate, 0, len(history)+1)
+	channel.History = append(channel.History, history...)
+	channel.History = append(channel.History, update)
+	channel.ImageName = imageName
+	return imdb.updateChannelWithLock(dirname, directoryMetadata, channelName,
+		&channel)
+}
+
+// updateChannelWithLock will update or delete (if channel is nil) a channel and
+// will write and replicate the directory metadata. A new channel map is made
+// since the old one may be shared with readers. This must be called with the
+// lock held.
+func (imdb *ImageDataBase) updateChannelWithLock(dirname string,
+	directoryMetadata image.DirectoryMetadata, channelName string,
+	channel *image.Channel) error {
+	channels := make(map[string]image.Channel,
+		len(directoryMetadata.Channels)+1)
+	for name, channel := range directoryMetadata.Channels {
+		channels[name] = channel
+	}
+	if channel == nil {
+		delete(channels, channelName)
+	} else {
+		channels[channelName] = *channel
+	}
+	if len(channels) < 1 {
+		channels = nil
+	}
+	directoryMetadata.Channels = channels
+	directoryMetadata.ModifiedAt = time.Now()
+	return imdb.updateDirectoryMetadata(
+		image.Directory{Name: dirname, Metadata: directoryMetadata})
+}
@@ -0,0 +1,208 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+)
+
+func TestSetChannel(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{Logger: testlogger.New(t)},
+		directoryMap: map[string]image.DirectoryMetadata{
+			"stream": {OwnerGroup: "team"},
+		},
+		imageMap: map[string]*imageType{
+			"other/image": {image: &image.Image{}},
+			"stream/expiring": {
+				image: &image.Image{ExpiresAt: time.Now().Add(time.Hour)},
+			},
+			"stream/image0":  {image: &image.Image{}},
+			"stream/image1":  {image: &image.Image{}},
+			"stream/writing": nil,
+		},
+	}
+	teamMember := &srpc.AuthInformation{
+		GroupList: map[string]struct{}{"team": {}},
+		Username:  "user",
+	}
+	releaser := &srpc.AuthInformation{
+		GroupList: map[string]struct{}{"releasers": {}},
+		Username:  "user",
+	}
+	tests := []struct {
+		channel   string
+		imageName string
+		authInfo  *srpc.AuthInformation
+		ok        bool
+	}{
+		{"prod", "stream/image0", nil, false},
+		{"prod", "stream/image0", releaser, false},
+		{"bad/name", "stream/image0", teamMember, false},
+		{"-bad", "stream/image0", teamMember, false},
+		{"prod", "other/image", teamMember, false},
+		{"prod", "stream/missing", teamMember, false},
+		{"prod", "stream/writing", teamMember, false},
+		{"prod", "stream/expiring", teamMember, false},
+		{"prod", "", teamMember, false}, // Delete unknown channel.
+		{"prod", "stream/image0", teamMember, true},
+	}
+	for _, test := range tests {
+		err := imdb.setChannel("stream", test.channel, test.imageName, "",
+			test.authInfo)
+		if test.ok && err != nil {
+			t.Errorf("setChannel(%s, %s): %s",
+				test.channel, test.imageName, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("setChannel(%s, %s): expected error",
+				test.channel, test.imageName)
+		}
+	}
+	channel := imdb.directoryMap["stream"].Channels["prod"]
+	if channel.ImageName != "stream/image0" {
+		t.Errorf("channel image: %s != stream/image0", channel.ImageName)
+	}
+	if len(channel.History) != 1 {
+		t.Fatalf("history length: %d != 1", len(channel.History))
+	}
+	if channel.History[0].UpdatedBy != "user" {
+		t.Errorf("updated by: %s != user", channel.History[0].UpdatedBy)
+	}
+	// Channel owner group takes precedence over the directory owner group.
+	err := imdb.chownChannel("stream", "prod", "releasers", teamMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = imdb.setChannel("stream", "prod", "stream/image1", "", teamMember)
+	if err == nil {
+		t.Error("setChannel by non-member of channel owner group succeeded")
+	}
+	err = imdb.setChannel("stream", "prod", "stream/image1", "", releaser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = imdb.setChannel("stream", "prod", "", "", releaser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := imdb.directoryMap["stream"].Channels["prod"]; ok {
+		t.Error("channel not deleted")
+	}
+}
+
+func TestResolveChannel(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{Logger: testlogger.New(t)},
+		directoryMap: map[string]image.DirectoryMetadata{
+			"stream": {OwnerGroup: "team"},
+		},
+		imageMap: map[string]*imageType{
+			"stream/image1": {image: &image.Image{}},
+		},
+	}
+	err := imdb.setChannel("stream", "prod", "stream/image1", "",
+		&srpc.AuthInformation{HaveMethodAccess: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := imdb.resolveChannel("stream@prod"); err != nil {
+		t.Error(err)
+	} else if name != "stream/image1" {
+		t.Errorf("resolved: %s != stream/image1", name)
+	}
+	for _, reference := range []string{
+		"stream",
+		"stream@staging",
+		"missing@prod",
+		"stream@",
+	} {
+		if name, err := imdb.resolveChannel(reference); err == nil {
+			t.Errorf("resolveChannel(%s) = %s, expected error",
+				reference, name)
+		}
+	}
+}
+
+func TestChannelHistoryTruncation(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{Logger: testlogger.New(t)},
+		directoryMap: map[string]image.DirectoryMetadata{
+			"stream": {OwnerGroup: "team"},
+		},
+		imageMap: map[string]*imageType{
+			"stream/image0": {image: &image.Image{}},
+			"stream/image1": {image: &image.Image{}},
+		},
+	}
+	authInfo := &srpc.AuthInformation{HaveMethodAccess: true}
+	for count := 0; count < maxChannelHistory+10; count++ {
+		imageName := "stream/image0"
+		if count%2 == 1 {
+			imageName = "stream/image1"
+		}
+		err := imdb.setChannel("stream", "prod", imageName, "", authInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	history := imdb.directoryMap["stream"].Channels["prod"].History
+	if len(history) != maxChannelHistory {
+		t.Fatalf("history length: %d != %d", len(history), maxChannelHistory)
+	}
+	// The last update (count=maxChannelHistory+9) must be the newest entry.
+	if history[len(history)-1].ImageName != "stream/image1" {
+		t.Errorf("newest history entry: %s",
+			history[len(history)-1].ImageName)
+	}
+	for index := 1; index < len(history); index++ {
+		if history[index].UpdatedAt.Before(history[index-1].UpdatedAt) {
+			t.Errorf("history not in order at index: %d", index)
+		}
+	}
+}
+
+func TestRejectChannelSeparatorInNames(t *testing.T) {
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: t.TempDir()},
+		Params: Params{Logger: testlogger.New(t)},
+		directoryMap: map[string]image.DirectoryMetadata{
+			".":      {},
+			"stream": {OwnerGroup: "team"},
+		},
+		imageMap: make(map[string]*imageType),
+	}
+	authInfo := &srpc.AuthInformation{HaveMethodAccess: true}
+	if err := imdb.addImage(&image.Image{}, "stream@prod/image",
+		authInfo); err == nil {
+		t.Error("addImage with '@' in name succeeded")
+	}
+	err := imdb.makeDirectory(image.Directory{Name: "stream@prod"}, authInfo,
+		true)
+	if err == nil {
+		t.Error("makeDirectory with '@' in name succeeded")
+	}
+	err = imdb.makeDirectoryAll("new@dir/sub", authInfo)
+	if err == nil {
+		t.Error("makeDirectoryAll with '@' in name succeeded")
+	}
+}
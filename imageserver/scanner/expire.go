@@ -25,10 +25,15 @@ func (imdb *ImageDataBase) expireImage(img *image.Image, name string) {
 		time.AfterFunc(duration, func() { imdb.expireImage(img, name) })
 		return
 	}
-	imdb.Logger.Printf("Auto expiring (deleting) image: %s\n", name)
 	pathname := path.Join(imdb.BaseDirectory, name)
 	// Only rename file while lock is held, because removing can be slow.
 	imdb.Lock()
+	currentImage, _ := imdb.getImageWithLock(name)
+	if currentImage != img {
+		imdb.Unlock() // Image was deleted: do not remove the tombstone.
+		return
+	}
+	imdb.Logger.Printf("Auto expiring (deleting) image: %s\n", name)
 	if err := os.Rename(pathname, pathname+"~"); err != nil {
 		imdb.Logger.Println(err)
 	}
@@ -15,6 +15,15 @@ func (imdb *ImageDataBase) writeHtml(writer io.Writer) {
 		"Number of  <a href=\"listDirectories?output=text\">directories</a>: "+
 			"<a href=\"listDirectories\">%d</a><br>\n",
 		imdb.CountDirectories())
+	if imdb.RetentionCheckInterval > 0 && imdb.ReplicationMaster == "" {
+		mode := "dry run"
+		if imdb.RetentionEnforce {
+			mode = "enforced"
+		}
+		fmt.Fprintf(writer,
+			"<a href=\"showRetention\">Retention policies</a> (%s)<br>\n",
+			mode)
+	}
 	if imdb.ReplicationMaster != "" {
 		fmt.Fprintf(writer,
 			"Replication master: <a href=\"http://%s/\">%s</a><br>\n",
@@ -79,6 +79,9 @@ func writeImage(filename string, img *image.Image, exclusive bool) (
 
 func (imdb *ImageDataBase) addImage(img *image.Image, name string,
 	authInfo *srpc.AuthInformation) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
 	if err := img.Verify(); err != nil {
 		return err
 	}
@@ -171,6 +174,15 @@ func (imdb *ImageDataBase) changeImageExpiration(name string,
 	return false, nil
 }
 
+// checkName returns an error if the image or directory name cannot be used,
+// such as when it could be mistaken for a channel reference.
+func checkName(name string) error {
+	if strings.ContainsRune(name, '@') {
+		return errors.New("'@' not permitted in name: " + name)
+	}
+	return nil
+}
+
 // This must be called with the lock held.
 func (imdb *ImageDataBase) checkChown(dirname, ownerGroup string,
 	authInfo *srpc.AuthInformation) error {
@@ -289,6 +301,7 @@ func (imdb *ImageDataBase) chownDirectory(dirname, ownerGroup string,
 	if err := imdb.checkChown(dirname, ownerGroup, authInfo); err != nil {
 		return err
 	}
+	directoryMetadata.ModifiedAt = time.Now()
 	directoryMetadata.OwnerGroup = ownerGroup
 	return imdb.updateDirectoryMetadata(
 		image.Directory{Name: dirname, Metadata: directoryMetadata})
@@ -312,8 +325,9 @@ func (imdb *ImageDataBase) getImages(imageNames []string,
 	return images, nil
 }
 
-// prepareToWrite returns an error if the image already exists or is being
-// written, otherwise it marks the image as being written and returns nil.
+// prepareToWrite returns an error if the image already exists, is being
+// written or was deleted, otherwise it marks the image as being written and
+// returns nil.
 // The write lock is grabbed and released.
 func (imdb *ImageDataBase) prepareToWrite(name string) error {
 	imdb.Lock()
@@ -324,6 +338,9 @@ func (imdb *ImageDataBase) prepareToWrite(name string) error {
 		}
 		return errors.New("image: " + name + " already exists")
 	}
+	if _, ok := imdb.tombstones[name]; ok {
+		return errors.New("cannot add previously deleted image: " + name)
+	}
 	imdb.imageMap[name] = nil
 	return nil
 }
@@ -332,7 +349,7 @@ func (imdb *ImageDataBase) prepareToWrite(name string) error {
 func (imdb *ImageDataBase) updateDirectoryMetadata(
 	directory image.Directory) error {
 	oldDirectoryMetadata, ok := imdb.directoryMap[directory.Name]
-	if ok && directory.Metadata == oldDirectoryMetadata {
+	if ok && directory.Metadata.Equal(&oldDirectoryMetadata) {
 		return nil
 	}
 	if err := imdb.updateDirectoryMetadataFile(directory); err != nil {
@@ -347,7 +364,7 @@ func (imdb *ImageDataBase) updateDirectoryMetadataFile(
 	directory image.Directory) error {
 	filename := filepath.Join(imdb.BaseDirectory, directory.Name, metadataFile)
 	_, ok := imdb.directoryMap[directory.Name]
-	if directory.Metadata == (image.DirectoryMetadata{}) {
+	if directory.Metadata.Equal(&image.DirectoryMetadata{}) {
 		if !ok {
 			return nil
 		}
@@ -423,11 +440,16 @@ func (imdb *ImageDataBase) deleteImage(name string,
 		if err := imdb.checkPermissions(name, imgType, authInfo); err != nil {
 			return err
 		}
+		if channel := imdb.getImageChannelWithLock(name); channel != "" {
+			return fmt.Errorf("image: %s is referenced by channel: %s",
+				name, channel)
+		}
 		filename := filepath.Join(imdb.BaseDirectory, name)
 		if err := os.Truncate(filename, 0); err != nil {
 			return err
 		}
 		imdb.deleteImageAndUpdateUnreferencedObjectsList(name)
+		imdb.tombstones[name] = time.Now()
 		imdb.deleteNotifiers.sendPlain(name, "delete", imdb.Logger)
 		return nil
 	}
@@ -724,6 +746,9 @@ func (imdb *ImageDataBase) makeDirectoryWithLock(directory image.Directory,
 		if ok {
 			return fmt.Errorf("directory: %s already exists", directory.Name)
 		}
+		if err := checkName(directory.Name); err != nil {
+			return err
+		}
 		directory.Metadata = oldDirectoryMetadata
 		parentMetadata, ok := imdb.directoryMap[filepath.Dir(directory.Name)]
 		if !ok {
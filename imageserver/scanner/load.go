@@ -50,6 +50,7 @@ func loadImageDataBase(config Config, params Params) (*ImageDataBase, error) {
 		deleteNotifiers: make(notifiers),
 		mkdirNotifiers:  make(makeDirectoryNotifiers),
 		rmdirNotifiers:  make(notifiers),
+		tombstones:      make(map[string]time.Time),
 	}
 	imdb.lockWatcher = lockwatcher.New(&imdb.RWMutex,
 		lockwatcher.LockWatcherOptions{
@@ -81,6 +82,12 @@ func loadImageDataBase(config Config, params Params) (*ImageDataBase, error) {
 			imdb.CountImages(), plural, time.Since(startTime), userTime)
 		logutil.LogMemory(params.Logger, 0, "after loading")
 	}
+	if config.RetentionCheckInterval > 0 && config.ReplicationMaster == "" {
+		go imdb.retentionLoop()
+	}
+	if config.TombstoneLifetime > 0 {
+		go imdb.tombstoneExpirer()
+	}
 	return imdb, nil
 }
 
@@ -119,6 +126,9 @@ func (imdb *ImageDataBase) scanDirectory(dirname string,
 			err = state.GoRun(func() error {
 				return imdb.loadFile(filename)
 			})
+		} else if stat.Mode&syscall.S_IFMT == syscall.S_IFREG &&
+			name[len(name)-1] != '~' {
+			imdb.tombstones[filename] = time.Unix(stat.Mtim.Unix())
 		}
 		if err != nil {
 			if err == syscall.ENOENT {
@@ -0,0 +1,165 @@
+package scanner
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+)
+
+func (imdb *ImageDataBase) setRetentionPolicy(dirname string,
+	policy *image.RetentionPolicy, authInfo *srpc.AuthInformation) error {
+	if policy != nil && policy.KeepLatest < 1 {
+		return errors.New("retention policy must keep at least one image")
+	}
+	dirname = filepath.Clean(dirname)
+	imdb.Lock()
+	defer imdb.Unlock()
+	directoryMetadata, ok := imdb.directoryMap[dirname]
+	if !ok {
+		return errors.New("unknown directory: " + dirname)
+	}
+	if err := imdb.checkDirectoryOwner(dirname, authInfo); err != nil {
+		return err
+	}
+	if policy != nil {
+		policyCopy := *policy
+		policy = &policyCopy
+	}
+	directoryMetadata.ModifiedAt = time.Now()
+	directoryMetadata.RetentionPolicy = policy
+	return imdb.updateDirectoryMetadata(
+		image.Directory{Name: dirname, Metadata: directoryMetadata})
+}
+
+func (imdb *ImageDataBase) getRetentionReport() *RetentionReport {
+	imdb.retentionLock.Lock()
+	defer imdb.retentionLock.Unlock()
+	return imdb.retentionReport
+}
+
+func (imdb *ImageDataBase) retentionLoop() {
+	for ; ; time.Sleep(imdb.RetentionCheckInterval) {
+		report := imdb.computeRetention()
+		if imdb.RetentionEnforce && report.Error == "" {
+			imdb.applyRetention(report)
+		}
+		imdb.retentionLock.Lock()
+		imdb.retentionReport = report
+		imdb.retentionLock.Unlock()
+	}
+}
+
+func (imdb *ImageDataBase) computeRetention() *RetentionReport {
+	report := &RetentionReport{ComputedAt: time.Now()}
+	var referencedImages map[string]struct{}
+	if imdb.GetReferencedImages != nil {
+		var err error
+		referencedImages, err = imdb.GetReferencedImages()
+		if err != nil {
+			// Not knowing which images are in use is not safe: do nothing.
+			imdb.Logger.Printf("Error getting referenced images: %s\n", err)
+			report.Error = err.Error()
+			return report
+		}
+	}
+	imdb.RLock()
+	defer imdb.RUnlock()
+	imagesPerDirectory := make(map[string][]string)
+	for name, imgEntry := range imdb.imageMap {
+		if imgEntry == nil {
+			continue
+		}
+		dirname := filepath.Dir(name)
+		if imdb.directoryMap[dirname].RetentionPolicy == nil {
+			continue
+		}
+		imagesPerDirectory[dirname] = append(imagesPerDirectory[dirname], name)
+	}
+	for dirname, imageNames := range imagesPerDirectory {
+		directoryMetadata := imdb.directoryMap[dirname]
+		policy := *directoryMetadata.RetentionPolicy
+		// Newest first.
+		sort.Slice(imageNames, func(left, right int) bool {
+			return imdb.imageMap[imageNames[left]].image.CreatedOn.After(
+				imdb.imageMap[imageNames[right]].image.CreatedOn)
+		})
+		channelImages := make(map[string]struct{})
+		for _, channel := range directoryMetadata.Channels {
+			channelImages[channel.ImageName] = struct{}{}
+		}
+		directoryRetention := DirectoryRetention{
+			Name:      dirname,
+			NumImages: uint(len(imageNames)),
+			Policy:    policy,
+		}
+		for index, name := range imageNames {
+			if uint(index) < policy.KeepLatest {
+				continue
+			}
+			if _, ok := channelImages[name]; ok {
+				continue
+			}
+			if _, ok := referencedImages[name]; ok {
+				continue
+			}
+			img := imdb.imageMap[name].image
+			if policy.MaximumAge > 0 &&
+				report.ComputedAt.Sub(img.CreatedOn) < policy.MaximumAge {
+				continue
+			}
+			directoryRetention.ImagesToDelete = append(
+				directoryRetention.ImagesToDelete, name)
+		}
+		report.Directories = append(report.Directories, directoryRetention)
+	}
+	sort.Slice(report.Directories, func(left, right int) bool {
+		return report.Directories[left].Name < report.Directories[right].Name
+	})
+	return report
+}
+
+func (imdb *ImageDataBase) applyRetention(report *RetentionReport) {
+	objSrv := imdb.Params.ObjectServer
+	unreferencedBefore := objSrv.ListUnreferenced()
+	authInfo := &srpc.AuthInformation{HaveMethodAccess: true}
+	for _, directory := range report.Directories {
+		for _, name := range directory.ImagesToDelete {
+			if err := imdb.deleteImage(name, authInfo); err != nil {
+				imdb.Logger.Printf("Error deleting image: %s: %s\n", name, err)
+				continue
+			}
+			imdb.Logger.Printf("Deleted image: %s due to retention policy\n",
+				name)
+			report.NumImagesDeleted++
+		}
+	}
+	report.Enforced = true
+	if report.NumImagesDeleted < 1 {
+		return
+	}
+	// Reclaim the space used by objects no longer referenced by any image,
+	// leaving objects which were already unreferenced alone.
+	var freedObjects []hash.Hash
+	for hashVal := range objSrv.ListUnreferenced() {
+		if _, ok := unreferencedBefore[hashVal]; !ok {
+			freedObjects = append(freedObjects, hashVal)
+		}
+	}
+	if len(freedObjects) < 1 {
+		return
+	}
+	bytesFreed, _, err := objSrv.DeleteObjectsIfUnreferenced(freedObjects)
+	report.BytesReclaimed = bytesFreed
+	if err != nil {
+		imdb.Logger.Printf("Error deleting unreferenced objects: %s\n", err)
+		return
+	}
+	imdb.Logger.Printf("Retention policies reclaimed: %s\n",
+		format.FormatBytes(bytesFreed))
+}
@@ -0,0 +1,334 @@
+package scanner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	objectserver "github.com/Cloud-Foundations/Dominator/lib/objectserver/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+)
+
+func getImagesToDelete(t *testing.T, report *RetentionReport) []string {
+	if report.Error != "" {
+		t.Fatal(report.Error)
+	}
+	if len(report.Directories) != 1 {
+		t.Fatalf("number of directories: %d != 1", len(report.Directories))
+	}
+	if name := report.Directories[0].Name; name != "stream" {
+		t.Fatalf("directory: %s != stream", name)
+	}
+	return report.Directories[0].ImagesToDelete
+}
+
+func TestComputeRetention(t *testing.T) {
+	tests := []struct {
+		name             string
+		policy           image.RetentionPolicy
+		numImages        int
+		channels         map[string]image.Channel
+		referencedImages map[string]struct{}
+		expected         []string
+	}{
+		{
+			name:      "keep latest",
+			policy:    image.RetentionPolicy{KeepLatest: 3},
+			numImages: 6,
+			expected:  []string{"stream/3", "stream/4", "stream/5"},
+		},
+		{
+			name:      "keep more than present",
+			policy:    image.RetentionPolicy{KeepLatest: 10},
+			numImages: 6,
+		},
+		{
+			name: "maximum age",
+			policy: image.RetentionPolicy{
+				KeepLatest: 1,
+				MaximumAge: 3*24*time.Hour + time.Hour,
+			},
+			numImages: 6,
+			expected:  []string{"stream/4", "stream/5"},
+		},
+		{
+			name:      "channel pinned",
+			policy:    image.RetentionPolicy{KeepLatest: 2},
+			numImages: 5,
+			channels: map[string]image.Channel{
+				"prod": {ImageName: "stream/3"},
+			},
+			expected: []string{"stream/2", "stream/4"},
+		},
+		{
+			name:      "referenced images",
+			policy:    image.RetentionPolicy{KeepLatest: 2},
+			numImages: 5,
+			referencedImages: map[string]struct{}{
+				"stream/2": {},
+				"stream/4": {},
+			},
+			expected: []string{"stream/3"},
+		},
+	}
+	now := time.Now()
+	for _, test := range tests {
+		policy := test.policy
+		referencedImages := test.referencedImages
+		imdb := &ImageDataBase{
+			Params: Params{
+				GetReferencedImages: func() (map[string]struct{}, error) {
+					return referencedImages, nil
+				},
+				Logger: testlogger.New(t),
+			},
+			directoryMap: map[string]image.DirectoryMetadata{
+				"other": {},
+				"stream": {
+					Channels:        test.channels,
+					OwnerGroup:      "team",
+					RetentionPolicy: &policy,
+				},
+			},
+			imageMap: map[string]*imageType{
+				"other/0": {
+					image: &image.Image{CreatedOn: now.Add(-1000 * time.Hour)},
+				},
+			},
+		}
+		// Images are named by their age in days ("stream/0" is the newest).
+		for age := 0; age < test.numImages; age++ {
+			imdb.imageMap["stream/"+string(rune('0'+age))] = &imageType{
+				image: &image.Image{
+					CreatedOn: now.Add(-time.Duration(age) * 24 * time.Hour),
+				},
+			}
+		}
+		got := getImagesToDelete(t, imdb.computeRetention())
+		if len(got) < 1 && len(test.expected) < 1 {
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: images to delete: %v != %v",
+				test.name, got, test.expected)
+		}
+	}
+}
+
+func TestComputeRetentionReferencedImagesError(t *testing.T) {
+	imdb := &ImageDataBase{
+		Params: Params{
+			GetReferencedImages: func() (map[string]struct{}, error) {
+				return nil, errors.New("MDB unavailable")
+			},
+			Logger: testlogger.New(t),
+		},
+		directoryMap: map[string]image.DirectoryMetadata{
+			"stream": {RetentionPolicy: &image.RetentionPolicy{KeepLatest: 1}},
+		},
+		imageMap: map[string]*imageType{
+			"stream/0": {image: &image.Image{CreatedOn: time.Now()}},
+			"stream/1": {
+				image: &image.Image{CreatedOn: time.Now().Add(-time.Hour)},
+			},
+		},
+	}
+	report := imdb.computeRetention()
+	if report.Error == "" {
+		t.Error("no error reported")
+	}
+	if len(report.Directories) > 0 {
+		t.Errorf("directories evaluated despite error: %v", report.Directories)
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{
+			Logger:       testlogger.New(t),
+			ObjectServer: &testObjectServer{},
+		},
+		directoryMap: map[string]image.DirectoryMetadata{
+			"other":  {},
+			"stream": {RetentionPolicy: &image.RetentionPolicy{KeepLatest: 1}},
+		},
+		imageMap: map[string]*imageType{
+			"other/0": {
+				image: &image.Image{CreatedOn: now.Add(-1000 * time.Hour)},
+			},
+			"stream/0": {image: &image.Image{CreatedOn: now}},
+			"stream/1": {image: &image.Image{CreatedOn: now.Add(-time.Hour)}},
+			"stream/2": {
+				image: &image.Image{CreatedOn: now.Add(-2 * time.Hour)},
+			},
+		},
+		tombstones: make(map[string]time.Time),
+	}
+	for name := range imdb.imageMap {
+		if strings.HasPrefix(name, "stream/") {
+			err := os.WriteFile(filepath.Join(baseDir, name), nil, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	report := imdb.computeRetention()
+	imdb.applyRetention(report)
+	if report.NumImagesDeleted != 2 {
+		t.Errorf("images deleted: %d != 2", report.NumImagesDeleted)
+	}
+	for _, name := range []string{"stream/1", "stream/2"} {
+		if _, ok := imdb.imageMap[name]; ok {
+			t.Errorf("image: %s not deleted", name)
+		}
+		if !imdb.checkTombstone(name) {
+			t.Errorf("no tombstone for: %s", name)
+		}
+	}
+	if _, ok := imdb.imageMap["stream/0"]; !ok {
+		t.Error("newest image deleted")
+	}
+	if _, ok := imdb.imageMap["other/0"]; !ok {
+		t.Error("image in directory without policy deleted")
+	}
+}
+
+func TestApplyRetentionDeletesOnlyFreedObjects(t *testing.T) {
+	objSrv, err := objectserver.NewObjectServer(t.TempDir(),
+		testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addObject := func(data string) hash.Hash {
+		hashVal, _, err := objSrv.AddObject(strings.NewReader(data),
+			uint64(len(data)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hashVal
+	}
+	sharedObject := addObject("shared")
+	freedObject := addObject("freed")
+	strayObject := addObject("stray") // Unreferenced before retention.
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{
+			Logger:       testlogger.New(t),
+			ObjectServer: objSrv,
+		},
+		directoryMap: map[string]image.DirectoryMetadata{
+			"stream": {RetentionPolicy: &image.RetentionPolicy{KeepLatest: 1}},
+		},
+		imageMap: map[string]*imageType{
+			"stream/0": {
+				image: &image.Image{
+					CreatedOn:    time.Now(),
+					FileSystem:   &filesystem.FileSystem{},
+					ReleaseNotes: &image.Annotation{Object: &sharedObject},
+				},
+			},
+			"stream/1": {
+				image: &image.Image{
+					BuildLog:     &image.Annotation{Object: &freedObject},
+					CreatedOn:    time.Now().Add(-time.Hour),
+					FileSystem:   &filesystem.FileSystem{},
+					ReleaseNotes: &image.Annotation{Object: &sharedObject},
+				},
+			},
+		},
+		tombstones: make(map[string]time.Time),
+	}
+	for _, name := range []string{"stream/0", "stream/1"} {
+		err := os.WriteFile(filepath.Join(baseDir, name), nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = objSrv.AdjustRefcounts(true, imdb.imageMap[name].image)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	report := imdb.computeRetention()
+	imdb.applyRetention(report)
+	if report.NumImagesDeleted != 1 {
+		t.Errorf("images deleted: %d != 1", report.NumImagesDeleted)
+	}
+	if report.BytesReclaimed != uint64(len("freed")) {
+		t.Errorf("bytes reclaimed: %d != %d",
+			report.BytesReclaimed, len("freed"))
+	}
+	sizes, err := objSrv.CheckObjects(
+		[]hash.Hash{sharedObject, freedObject, strayObject})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes[0] == 0 {
+		t.Error("object still referenced by an image deleted")
+	}
+	if sizes[1] != 0 {
+		t.Error("object freed by retention not deleted")
+	}
+	if sizes[2] == 0 {
+		t.Error("object unreferenced before retention deleted")
+	}
+}
+
+func TestSetRetentionPolicy(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{Logger: testlogger.New(t)},
+		directoryMap: map[string]image.DirectoryMetadata{
+			"stream": {OwnerGroup: "team"},
+		},
+	}
+	authInfo := &srpc.AuthInformation{
+		GroupList: map[string]struct{}{"team": {}},
+		Username:  "user",
+	}
+	err := imdb.setRetentionPolicy("stream", &image.RetentionPolicy{},
+		authInfo)
+	if err == nil {
+		t.Error("policy keeping no images accepted")
+	}
+	err = imdb.setRetentionPolicy("stream",
+		&image.RetentionPolicy{KeepLatest: 2},
+		&srpc.AuthInformation{
+			GroupList: map[string]struct{}{"other": {}},
+			Username:  "user",
+		})
+	if err == nil {
+		t.Error("policy set by non-owner")
+	}
+	err = imdb.setRetentionPolicy("stream",
+		&image.RetentionPolicy{KeepLatest: 2}, authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy := imdb.directoryMap["stream"].RetentionPolicy; policy == nil {
+		t.Error("policy not set")
+	} else if policy.KeepLatest != 2 {
+		t.Errorf("KeepLatest: %d != 2", policy.KeepLatest)
+	}
+}
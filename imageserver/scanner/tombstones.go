@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+)
+
+func (imdb *ImageDataBase) checkTombstone(name string) bool {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	_, ok := imdb.tombstones[name]
+	return ok
+}
+
+// expireTombstones will remove tombstones (and the corresponding empty files)
+// which are older than the tombstone lifetime. The number of tombstones removed
+// is returned.
+func (imdb *ImageDataBase) expireTombstones() uint {
+	if imdb.TombstoneLifetime <= 0 {
+		return 0
+	}
+	imdb.Lock()
+	defer imdb.Unlock()
+	var numExpired uint
+	for name, deletedAt := range imdb.tombstones {
+		if time.Since(deletedAt) < imdb.TombstoneLifetime {
+			continue
+		}
+		filename := filepath.Join(imdb.BaseDirectory, name)
+		var stat syscall.Stat_t
+		if err := syscall.Lstat(filename, &stat); err == nil {
+			if stat.Mode&syscall.S_IFMT != syscall.S_IFREG || stat.Size > 0 {
+				continue // Not a tombstone: leave it alone.
+			}
+			if err := os.Remove(filename); err != nil {
+				imdb.Logger.Println(err)
+				continue
+			}
+		}
+		delete(imdb.tombstones, name)
+		numExpired++
+	}
+	return numExpired
+}
+
+func (imdb *ImageDataBase) getTombstone(name string) (time.Time, bool) {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	deletedAt, ok := imdb.tombstones[name]
+	return deletedAt, ok
+}
+
+func (imdb *ImageDataBase) listTombstones() map[string]time.Time {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	tombstones := make(map[string]time.Time, len(imdb.tombstones))
+	for name, deletedAt := range imdb.tombstones {
+		tombstones[name] = deletedAt
+	}
+	return tombstones
+}
+
+func (imdb *ImageDataBase) recordDeletedImage(name string,
+	deletedAt time.Time) error {
+	if deletedAt.IsZero() {
+		deletedAt = time.Now()
+	}
+	imdb.Lock()
+	defer imdb.Unlock()
+	if _, ok := imdb.tombstones[name]; ok {
+		return nil
+	}
+	filename := filepath.Join(imdb.BaseDirectory, name)
+	if img, ok := imdb.getImageWithLock(name); ok {
+		if img == nil {
+			return errors.New("image: " + name + " is being written")
+		}
+		if channel := imdb.getImageChannelWithLock(name); channel != "" {
+			return fmt.Errorf("image: %s is referenced by channel: %s",
+				name, channel)
+		}
+		if err := os.Truncate(filename, 0); err != nil {
+			return err
+		}
+		os.Chtimes(filename, deletedAt, deletedAt)
+		imdb.deleteImageAndUpdateUnreferencedObjectsList(name)
+		imdb.tombstones[name] = deletedAt
+		imdb.deleteNotifiers.sendPlain(name, "delete", imdb.Logger)
+		return nil
+	}
+	if imdb.TombstoneLifetime > 0 &&
+		time.Since(deletedAt) >= imdb.TombstoneLifetime {
+		return nil // Tombstone has already expired.
+	}
+	// Never had the image: leave a tombstone so that it is not added later.
+	if _, ok := imdb.directoryMap[filepath.Dir(name)]; ok {
+		file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY,
+			fsutil.PublicFilePerms)
+		if err != nil {
+			return err
+		}
+		file.Close()
+		os.Chtimes(filename, deletedAt, deletedAt)
+	}
+	imdb.tombstones[name] = deletedAt
+	return nil
+}
+
+func (imdb *ImageDataBase) tombstoneExpirer() {
+	interval := imdb.TombstoneLifetime / 24
+	if interval < time.Minute {
+		interval = time.Minute
+	} else if interval > time.Hour {
+		interval = time.Hour
+	}
+	for ; ; time.Sleep(interval) {
+		if numExpired := imdb.expireTombstones(); numExpired > 0 {
+			imdb.Logger.Printf("Expired %d tombstones\n", numExpired)
+		}
+	}
+}
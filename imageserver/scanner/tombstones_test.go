@@ -0,0 +1,257 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/image"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+)
+
+type testObjectServer struct {
+	objectserver.FullObjectServer // Unimplemented methods will panic.
+}
+
+func (objSrv *testObjectServer) AdjustRefcounts(increment bool,
+	iterator objectserver.ObjectsIterator) error {
+	return nil
+}
+
+func (objSrv *testObjectServer) ListUnreferenced() map[hash.Hash]uint64 {
+	return nil
+}
+
+func addTestImage(imdb *ImageDataBase, name string) error {
+	return imdb.addImage(
+		&image.Image{
+			CreatedOn:  time.Now(),
+			FileSystem: &filesystem.FileSystem{},
+		},
+		name, &srpc.AuthInformation{HaveMethodAccess: true})
+}
+
+func TestAddRefusedAfterLocalDelete(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{
+			Logger:       testlogger.New(t),
+			ObjectServer: &testObjectServer{},
+		},
+		directoryMap: map[string]image.DirectoryMetadata{"stream": {}},
+		imageMap:     make(map[string]*imageType),
+		tombstones:   make(map[string]time.Time),
+	}
+	if err := addTestImage(imdb, "stream/new"); err != nil {
+		t.Fatal(err)
+	}
+	err := imdb.deleteImage("stream/new",
+		&srpc.AuthInformation{HaveMethodAccess: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !imdb.checkTombstone("stream/new") {
+		t.Fatal("no tombstone after delete")
+	}
+	if err := addTestImage(imdb, "stream/new"); err == nil {
+		t.Fatal("re-adding deleted image succeeded")
+	}
+	// A delete from a peer for a name already deleted is a no-op.
+	if err := imdb.recordDeletedImage("stream/new", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddRefusedAfterPeerDelete(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{
+			Logger:       testlogger.New(t),
+			ObjectServer: &testObjectServer{},
+		},
+		directoryMap: map[string]image.DirectoryMetadata{"stream": {}},
+		imageMap:     make(map[string]*imageType),
+		tombstones:   make(map[string]time.Time),
+	}
+	deletedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	// The image was never added here.
+	if err := imdb.recordDeletedImage("stream/new", deletedAt); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := imdb.getTombstone("stream/new"); !ok {
+		t.Fatal("no tombstone after peer delete")
+	} else if !got.Equal(deletedAt) {
+		t.Errorf("tombstone time: %s != %s", got, deletedAt)
+	}
+	if err := addTestImage(imdb, "stream/new"); err == nil {
+		t.Fatal("adding image deleted on peer succeeded")
+	}
+	// The tombstone must survive a restart.
+	filename := filepath.Join(baseDir, "stream/new")
+	if fi, err := os.Stat(filename); err != nil {
+		t.Fatal(err)
+	} else if fi.Size() != 0 {
+		t.Errorf("tombstone file size: %d", fi.Size())
+	} else if !fi.ModTime().Equal(deletedAt) {
+		t.Errorf("tombstone file time: %s != %s", fi.ModTime(), deletedAt)
+	}
+}
+
+func TestPeerDeleteRemovesImage(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{
+			Logger:       testlogger.New(t),
+			ObjectServer: &testObjectServer{},
+		},
+		directoryMap: map[string]image.DirectoryMetadata{"stream": {}},
+		imageMap:     make(map[string]*imageType),
+		tombstones:   make(map[string]time.Time),
+	}
+	if err := addTestImage(imdb, "stream/new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := imdb.recordDeletedImage("stream/new", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := imdb.getImageWithLock("stream/new"); ok {
+		t.Error("image not deleted")
+	}
+	if !imdb.checkTombstone("stream/new") {
+		t.Error("no tombstone after peer delete")
+	}
+}
+
+func TestPeerDeleteRefusedForChannelImage(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{
+			Logger:       testlogger.New(t),
+			ObjectServer: &testObjectServer{},
+		},
+		directoryMap: map[string]image.DirectoryMetadata{"stream": {}},
+		imageMap: map[string]*imageType{
+			"stream/image0": {image: &image.Image{}},
+		},
+		tombstones: make(map[string]time.Time),
+	}
+	err := imdb.setChannel("stream", "prod", "stream/image0", "",
+		&srpc.AuthInformation{HaveMethodAccess: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imdb.recordDeletedImage("stream/image0",
+		time.Time{}); err == nil {
+		t.Error("peer delete of image referenced by channel succeeded")
+	}
+	if _, ok := imdb.getImageWithLock("stream/image0"); !ok {
+		t.Error("image referenced by channel deleted")
+	}
+	if imdb.checkTombstone("stream/image0") {
+		t.Error("tombstone recorded for image referenced by channel")
+	}
+}
+
+func TestExpireTombstones(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{
+			Logger:       testlogger.New(t),
+			ObjectServer: &testObjectServer{},
+		},
+		directoryMap: map[string]image.DirectoryMetadata{"stream": {}},
+		imageMap:     make(map[string]*imageType),
+		tombstones:   make(map[string]time.Time),
+	}
+	imdb.TombstoneLifetime = 24 * time.Hour
+	oldTime := time.Now().Add(-25 * time.Hour)
+	newTime := time.Now().Add(-23 * time.Hour)
+	// Tombstones which have already expired are not recorded.
+	if err := imdb.recordDeletedImage("stream/expired", oldTime); err != nil {
+		t.Fatal(err)
+	}
+	if imdb.checkTombstone("stream/expired") {
+		t.Error("expired tombstone recorded")
+	}
+	if err := imdb.recordDeletedImage("stream/old", newTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := imdb.recordDeletedImage("stream/new", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := imdb.expireTombstones(); n != 0 {
+		t.Errorf("expired: %d tombstones, expected none", n)
+	}
+	imdb.tombstones["stream/old"] = oldTime // Simulate the passage of time.
+	if n := imdb.expireTombstones(); n != 1 {
+		t.Errorf("expired: %d tombstones, expected 1", n)
+	}
+	if imdb.checkTombstone("stream/old") {
+		t.Error("old tombstone not expired")
+	}
+	_, err := os.Stat(filepath.Join(baseDir, "stream/old"))
+	if !os.IsNotExist(err) {
+		t.Errorf("old tombstone file not removed: %v", err)
+	}
+	if !imdb.checkTombstone("stream/new") {
+		t.Error("new tombstone expired")
+	}
+	// The name may be re-used once the tombstone has expired.
+	if err := addTestImage(imdb, "stream/old"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpireTombstonesKeepsImages(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(baseDir, "stream"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	imdb := &ImageDataBase{
+		Config: Config{BaseDirectory: baseDir},
+		Params: Params{
+			Logger:       testlogger.New(t),
+			ObjectServer: &testObjectServer{},
+		},
+		directoryMap: map[string]image.DirectoryMetadata{"stream": {}},
+		imageMap:     make(map[string]*imageType),
+		tombstones:   make(map[string]time.Time),
+	}
+	imdb.TombstoneLifetime = time.Hour
+	filename := filepath.Join(baseDir, "stream/data")
+	if err := os.WriteFile(filename, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	imdb.tombstones["stream/data"] = time.Now().Add(-2 * time.Hour)
+	if n := imdb.expireTombstones(); n != 0 {
+		t.Errorf("expired: %d tombstones, expected none", n)
+	}
+	if _, err := os.Stat(filename); err != nil {
+		t.Error(err)
+	}
+}
@@ -20,14 +20,23 @@ func getStreamDashboardLink(streamName string, ok bool) string {
 		streamName, streamName)
 }
 
+func getStreamStatus(stream proto.ImageStreamInfo) string {
+	if stream.ExportBytesTotal < 1 {
+		return stream.Status.String()
+	}
+	return fmt.Sprintf("%s (%s of %s)", stream.Status,
+		format.FormatBytes(stream.ExportBytesDone),
+		format.FormatBytes(stream.ExportBytesTotal))
+}
+
 func (s state) getStreamStatusLink(streamName string,
 	stream proto.ImageStreamInfo, ok bool) string {
 	if !ok {
-		return stream.Status.String()
+		return getStreamStatus(stream)
 	}
 	fs, _ := s.unpacker.GetFileSystem(streamName)
 	if fs == nil {
-		return stream.Status.String()
+		return getStreamStatus(stream)
 	}
 	return fmt.Sprintf("<a href=\"showFileSystem?%s\">%s</a>",
 		streamName, stream.Status.String())
@@ -47,12 +47,14 @@ type requestType struct {
 }
 
 type imageStreamInfo struct {
-	DeviceId       string
-	dualLogger     log.DebugLogger
-	requestChannel chan<- requestType
-	scannedFS      *filesystem.FileSystem
-	status         proto.StreamStatus
-	streamLogger   *serverlogger.Logger
+	DeviceId         string
+	dualLogger       log.DebugLogger
+	exportBytesDone  uint64 // Atomic access only.
+	exportBytesTotal uint64 // Atomic access only.
+	requestChannel   chan<- requestType
+	scannedFS        *filesystem.FileSystem
+	status           proto.StreamStatus
+	streamLogger     *serverlogger.Logger
 }
 
 type persistentState struct {
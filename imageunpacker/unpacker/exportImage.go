@@ -11,17 +11,27 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
 	"github.com/Cloud-Foundations/Dominator/lib/format"
 	proto "github.com/Cloud-Foundations/Dominator/proto/imageunpacker"
 )
 
 var (
+	exportImageDirectory = flag.String("exportImageDirectory", "",
+		"Directory which native exports may write to (default: none)")
 	exportImageTool = flag.String("exportImageTool",
 		"/usr/local/etc/export-image", "Name of tool to export image")
 	exportImageUsername = flag.String("exportImageUsername",
 		"nobody", "Username to run as for export tool")
+
+	exportImageHosts flagutil.StringList
 )
 
+func init() {
+	flag.Var(&exportImageHosts, "exportImageHosts",
+		"Comma separated list of hosts which native exports may PUT to")
+}
+
 func (u *Unpacker) exportImage(streamName string,
 	exportType string, exportDestination string) error {
 	u.rwMutex.Lock()
@@ -45,14 +55,7 @@ func (u *Unpacker) exportImage(streamName string,
 
 func (stream *streamManagerState) export(exportType string,
 	exportDestination string) error {
-	userInfo, err := user.Lookup(*exportImageUsername)
-	if err != nil {
-		return err
-	}
-	groupIds, err := userInfo.GroupIds()
-	if err != nil {
-		return err
-	}
+	exporter, native := nativeExporters[exportType]
 	if err := stream.getDevice(); err != nil {
 		return err
 	}
@@ -68,10 +71,38 @@ func (stream *streamManagerState) export(exportType string,
 	}()
 	deviceFile, err := os.Open(path.Join("/dev", device.DeviceName))
 	if err != nil {
-		stream.streamInfo.dualLogger.Println("Error exporting: %s", err)
+		stream.streamInfo.dualLogger.Printf("Error exporting: %s\n", err)
 		return fmt.Errorf("error exporting: %s", err)
 	}
 	defer deviceFile.Close()
+	startTime := time.Now()
+	if native {
+		err = stream.exportNative(exporter, deviceFile, device.size,
+			exportDestination)
+	} else {
+		err = stream.exportWithTool(deviceFile, exportType, exportDestination)
+	}
+	if err != nil {
+		stream.streamInfo.dualLogger.Printf("Error exporting: %s\n", err)
+		return fmt.Errorf("error exporting: %s", err)
+	}
+	stream.streamInfo.dualLogger.Printf(
+		"Exported(%s) type: %s dest: %s in %s\n",
+		stream.streamName, exportType, exportDestination,
+		format.Duration(time.Since(startTime)))
+	return nil
+}
+
+func (stream *streamManagerState) exportWithTool(deviceFile *os.File,
+	exportType string, exportDestination string) error {
+	userInfo, err := user.Lookup(*exportImageUsername)
+	if err != nil {
+		return err
+	}
+	groupIds, err := userInfo.GroupIds()
+	if err != nil {
+		return err
+	}
 	cmd := exec.Command(*exportImageTool, exportType, exportDestination)
 	cmd.Stdin = deviceFile
 	uid, err := strconv.ParseUint(userInfo.Uid, 10, 32)
@@ -96,16 +127,8 @@ func (stream *streamManagerState) export(exportType string,
 		Groups: gids,
 	}
 	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: creds}
-	startTime := time.Now()
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		stream.streamInfo.dualLogger.Printf("Error exporting: %s: %s\n",
-			err, string(output))
-		return fmt.Errorf("error exporting: %s: %s", err, output)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, output)
 	}
-	stream.streamInfo.dualLogger.Printf(
-		"Exported(%s) type: %s dest: %s in %s\n",
-		stream.streamName, exportType, exportDestination,
-		format.Duration(time.Since(startTime)))
 	return nil
 }
@@ -1,6 +1,7 @@
 package unpacker
 
 import (
+	"sync/atomic"
 	"time"
 
 	proto "github.com/Cloud-Foundations/Dominator/proto/imageunpacker"
@@ -18,7 +19,11 @@ func (u *Unpacker) getStatus() proto.GetStatusResponse {
 	}
 	for name, stream := range u.pState.ImageStreams {
 		imageStreams[name] = proto.ImageStreamInfo{
-			stream.DeviceId, stream.status}
+			DeviceId:         stream.DeviceId,
+			ExportBytesDone:  atomic.LoadUint64(&stream.exportBytesDone),
+			ExportBytesTotal: atomic.LoadUint64(&stream.exportBytesTotal),
+			Status:           stream.status,
+		}
 	}
 	return proto.GetStatusResponse{devices, imageStreams,
 		time.Since(u.lastUsedTime)}
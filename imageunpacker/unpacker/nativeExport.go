@@ -0,0 +1,206 @@
+package unpacker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/images/qcow2"
+	"github.com/Cloud-Foundations/Dominator/lib/images/vhd"
+	proto "github.com/Cloud-Foundations/Dominator/proto/imageunpacker"
+)
+
+type exporterType struct {
+	extension string
+	// prepare returns the image writer and the size of the image.
+	prepare func(device io.ReaderAt, size uint64) (io.WriterTo, uint64, error)
+}
+
+type exportCountingWriter struct {
+	streamInfo *imageStreamInfo
+	writer     io.Writer
+}
+
+type rawWriterTo struct {
+	reader io.Reader
+}
+
+type vhdWriterTo struct {
+	reader io.Reader
+	size   uint64
+}
+
+var nativeExporters = map[string]exporterType{
+	proto.ExportTypeQcow2: {"qcow2", prepareQcow2Export},
+	proto.ExportTypeRaw:   {"raw", prepareRawExport},
+	proto.ExportTypeVhd:   {"vhd", prepareVhdExport},
+}
+
+func prepareQcow2Export(device io.ReaderAt, size uint64) (
+	io.WriterTo, uint64, error) {
+	image, err := qcow2.NewSparseImage(device, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	return image, image.Size(), nil
+}
+
+func prepareRawExport(device io.ReaderAt, size uint64) (
+	io.WriterTo, uint64, error) {
+	return &rawWriterTo{io.NewSectionReader(device, 0, int64(size))}, size,
+		nil
+}
+
+func prepareVhdExport(device io.ReaderAt, size uint64) (
+	io.WriterTo, uint64, error) {
+	return &vhdWriterTo{io.NewSectionReader(device, 0, int64(size)), size},
+		size + vhd.FooterSize, nil
+}
+
+// checkExportFilename will return the destination with symbolic links in the
+// parent directory resolved, if the destination is within directory.
+func checkExportFilename(destination, directory string) (string, error) {
+	if directory == "" {
+		return "", errors.New("exporting to files is not enabled")
+	}
+	if !filepath.IsAbs(destination) {
+		return "", fmt.Errorf("export destination: %s is not absolute",
+			destination)
+	}
+	directory, err := filepath.EvalSymlinks(directory)
+	if err != nil {
+		return "", err
+	}
+	destination = filepath.Clean(destination)
+	dirname, err := filepath.EvalSymlinks(filepath.Dir(destination))
+	if err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dirname, filepath.Base(destination))
+	if fi, err := os.Lstat(filename); err == nil {
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("export destination: %s is a symlink",
+				destination)
+		}
+	}
+	if filename != directory &&
+		!strings.HasPrefix(filename, directory+string(filepath.Separator)) {
+		return "", fmt.Errorf("export destination: %s is not within: %s",
+			destination, directory)
+	}
+	return filename, nil
+}
+
+// checkExportUrl will return an error if the URL is not HTTP(S) or its host
+// is not in the list of permitted hosts.
+func checkExportUrl(rawUrl string, hosts []string) error {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return fmt.Errorf("unsupported export URL scheme: %s",
+			parsedUrl.Scheme)
+	}
+	for _, host := range hosts {
+		if parsedUrl.Host == host || parsedUrl.Hostname() == host {
+			return nil
+		}
+	}
+	return fmt.Errorf("export host: %s is not permitted", parsedUrl.Host)
+}
+
+func (stream *streamManagerState) exportNative(exporter exporterType,
+	deviceFile *os.File, deviceSize uint64, exportDestination string) error {
+	streamInfo := stream.streamInfo
+	atomic.StoreUint64(&streamInfo.exportBytesDone, 0)
+	atomic.StoreUint64(&streamInfo.exportBytesTotal, 0)
+	defer func() {
+		atomic.StoreUint64(&streamInfo.exportBytesDone, 0)
+		atomic.StoreUint64(&streamInfo.exportBytesTotal, 0)
+	}()
+	image, imageSize, err := exporter.prepare(deviceFile, deviceSize)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint64(&streamInfo.exportBytesTotal, imageSize)
+	if strings.Contains(exportDestination, "://") {
+		err := checkExportUrl(exportDestination, exportImageHosts)
+		if err != nil {
+			return err
+		}
+		return stream.exportToUrl(image, imageSize, exportDestination)
+	}
+	filename, err := checkExportFilename(exportDestination,
+		*exportImageDirectory)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(filename); err == nil && fi.IsDir() {
+		filename = filepath.Join(filename,
+			strings.ReplaceAll(stream.streamName, "/", "_")+"."+
+				exporter.extension)
+	}
+	file, err := fsutil.CreateRenamingWriter(filename,
+		fsutil.PublicFilePerms)
+	if err != nil {
+		return err
+	}
+	_, err = image.WriteTo(&exportCountingWriter{streamInfo, file})
+	if err != nil {
+		file.Abort()
+		return err
+	}
+	return file.Close()
+}
+
+func (stream *streamManagerState) exportToUrl(image io.WriterTo,
+	imageSize uint64, rawUrl string) error {
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := image.WriteTo(&exportCountingWriter{stream.streamInfo,
+			writer})
+		writer.CloseWithError(err)
+	}()
+	req, err := http.NewRequest(http.MethodPut, rawUrl, reader)
+	if err != nil {
+		reader.Close()
+		return err
+	}
+	req.ContentLength = int64(imageSize)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("PUT %s: %s", rawUrl, resp.Status)
+	}
+	return nil
+}
+
+func (w *exportCountingWriter) Write(p []byte) (int, error) {
+	nWritten, err := w.writer.Write(p)
+	atomic.AddUint64(&w.streamInfo.exportBytesDone, uint64(nWritten))
+	return nWritten, err
+}
+
+func (w *rawWriterTo) WriteTo(writer io.Writer) (int64, error) {
+	return io.Copy(writer, w.reader)
+}
+
+func (w *vhdWriterTo) WriteTo(writer io.Writer) (int64, error) {
+	if err := vhd.WriteFixed(writer, w.reader, w.size); err != nil {
+		return 0, err
+	}
+	return int64(w.size) + vhd.FooterSize, nil
+}
@@ -0,0 +1,71 @@
+package unpacker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckExportFilename(t *testing.T) {
+	topdir := t.TempDir()
+	exportDir := filepath.Join(topdir, "export")
+	if err := os.Mkdir(exportDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := os.Symlink(topdir, filepath.Join(exportDir, "escape"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(filepath.Join(topdir, "passwd"),
+		filepath.Join(exportDir, "link.raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		destination string
+		directory   string
+		expectError bool
+	}{
+		{exportDir, exportDir, false},
+		{filepath.Join(exportDir, "image.raw"), exportDir, false},
+		{filepath.Join(exportDir, "image.raw"), "", true},
+		{"image.raw", exportDir, true},
+		{filepath.Join(exportDir, "..", "image.raw"), exportDir, true},
+		{filepath.Join(exportDir, "escape", "image.raw"), exportDir, true},
+		{filepath.Join(exportDir, "link.raw"), exportDir, true},
+		{exportDir + "2", exportDir, true},
+		{"/etc/passwd", exportDir, true},
+	}
+	for _, test := range tests {
+		_, err := checkExportFilename(test.destination, test.directory)
+		if test.expectError && err == nil {
+			t.Errorf("%s: no error", test.destination)
+		} else if !test.expectError && err != nil {
+			t.Errorf("%s: %s", test.destination, err)
+		}
+	}
+}
+
+func TestCheckExportUrl(t *testing.T) {
+	hosts := []string{"images.example.com", "upload.example.com:8080"}
+	tests := []struct {
+		url         string
+		expectError bool
+	}{
+		{"https://images.example.com/image.raw", false},
+		{"http://images.example.com:8000/image.raw", false},
+		{"http://upload.example.com:8080/image.raw", false},
+		{"http://upload.example.com/image.raw", true},
+		{"https://metadata.internal/image.raw", true},
+		{"ftp://images.example.com/image.raw", true},
+		{"file://images.example.com/etc/passwd", true},
+	}
+	for _, test := range tests {
+		err := checkExportUrl(test.url, hosts)
+		if test.expectError && err == nil {
+			t.Errorf("%s: no error", test.url)
+		} else if !test.expectError && err != nil {
+			t.Errorf("%s: %s", test.url, err)
+		}
+	}
+}
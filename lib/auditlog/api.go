@@ -0,0 +1,61 @@
+/*
+Package auditlog records calls to SRPC methods which may mutate state.
+
+Records are written to a directory as JSON lines, one record per line. A new
+file is started when the current file exceeds the maximum file size and the
+oldest files are deleted when the directory exceeds its quota. An *AuditLog
+implements the srpc.AuditSink interface.
+*/
+package auditlog
+
+import (
+	"os"
+	"sync"
+
+	"github.com/Cloud-Foundations/Dominator/lib/bufwriter"
+	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/logger"
+)
+
+type AuditLog struct {
+	options  Options
+	params   Params
+	mutex    sync.Mutex // Protect everything below.
+	file     *os.File
+	fileSize flagutil.Size
+	writer   *bufwriter.Writer
+}
+
+type Options struct {
+	Directory   string
+	MaxFileSize flagutil.Size // Minimum: 16 KiB.
+	Quota       flagutil.Size // Minimum: 64 KiB.
+}
+
+type Params struct {
+	Logger log.DebugLogger
+}
+
+// New will create an *AuditLog which writes to the directory specified in
+// options. The directory is created if it does not exist.
+func New(options Options, params Params) (*AuditLog, error) {
+	return newAuditLog(options, params)
+}
+
+// Audit will write a record. It implements the srpc.AuditSink interface.
+func (a *AuditLog) Audit(record *srpc.AuditRecord) {
+	a.audit(record)
+}
+
+// Flush will flush any buffered records to the current file.
+func (a *AuditLog) Flush() error {
+	return a.flush()
+}
+
+// GetRecords will read records matching the request, oldest first.
+func (a *AuditLog) GetRecords(request proto.GetAuditRecordsRequest) (
+	[]proto.AuditRecord, error) {
+	return a.getRecords(request)
+}
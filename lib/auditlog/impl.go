@@ -0,0 +1,257 @@
+package auditlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/bufwriter"
+	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/logger"
+)
+
+const (
+	defaultMaxRecords = 1000
+	fileSuffix        = ".jsonl"
+	maximumMaxRecords = 100000
+	timeLayout        = "2006-01-02:15:04:05.000000"
+)
+
+func newAuditLog(options Options, params Params) (*AuditLog, error) {
+	if options.MaxFileSize < 16<<10 {
+		options.MaxFileSize = 16 << 10
+	}
+	if options.Quota < 64<<10 {
+		options.Quota = 64 << 10
+	}
+	if options.MaxFileSize > options.Quota>>1 {
+		options.MaxFileSize = options.Quota >> 1
+	}
+	if err := os.MkdirAll(options.Directory, fsutil.PrivateDirPerms); err != nil {
+		return nil, err
+	}
+	a := &AuditLog{options: options, params: params}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if err := a.enforceQuota(); err != nil {
+		return nil, err
+	}
+	if err := a.openNewFile(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func matchRecord(record *proto.AuditRecord,
+	request proto.GetAuditRecordsRequest) bool {
+	if request.FailedOnly && record.Error == "" {
+		return false
+	}
+	if request.Username != "" && record.Username != request.Username {
+		return false
+	}
+	if !request.Since.IsZero() && record.StartTime.Before(request.Since) {
+		return false
+	}
+	if request.Method != "" && record.Method != request.Method {
+		if matched, _ := filepath.Match(request.Method,
+			record.Method); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *AuditLog) audit(record *srpc.AuditRecord) {
+	data, err := json.Marshal(proto.AuditRecord{
+		Duration:   record.Duration,
+		Error:      record.Error,
+		GroupList:  record.GroupList,
+		Method:     record.Method,
+		RemoteAddr: record.RemoteAddr,
+		Request:    record.Request,
+		StartTime:  record.StartTime,
+		Username:   record.Username,
+	})
+	if err != nil {
+		a.params.Logger.Printf("error encoding audit record: %s\n", err)
+		return
+	}
+	data = append(data, '\n')
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.writer == nil {
+		return // Writing failed previously.
+	}
+	if _, err := a.writer.Write(data); err != nil {
+		a.params.Logger.Printf("error writing audit record: %s\n", err)
+		return
+	}
+	a.fileSize += flagutil.Size(len(data))
+	if a.fileSize < a.options.MaxFileSize {
+		return
+	}
+	if err := a.closeFile(); err != nil {
+		a.params.Logger.Printf("error closing audit file: %s\n", err)
+	}
+	if err := a.enforceQuota(); err != nil {
+		a.params.Logger.Printf("error enforcing audit quota: %s\n", err)
+	}
+	if err := a.openNewFile(); err != nil {
+		a.params.Logger.Printf("error opening audit file: %s\n", err)
+	}
+}
+
+// This should be called with the lock held.
+func (a *AuditLog) closeFile() error {
+	if a.file == nil {
+		return nil
+	}
+	err := a.writer.Flush()
+	if e := a.file.Close(); err == nil {
+		err = e
+	}
+	a.file = nil
+	a.writer = nil
+	return err
+}
+
+// This should be called with the lock held.
+func (a *AuditLog) enforceQuota() error {
+	filenames, err := a.listFiles()
+	if err != nil {
+		return err
+	}
+	var usage flagutil.Size
+	for index := len(filenames) - 1; index >= 0; index-- {
+		pathname := filepath.Join(a.options.Directory, filenames[index])
+		fi, err := os.Stat(pathname)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		size := flagutil.Size(fi.Size())
+		// Leave room for the next file.
+		if usage+size > a.options.Quota-a.options.MaxFileSize {
+			if err := os.Remove(pathname); err != nil {
+				return err
+			}
+			continue
+		}
+		usage += size
+	}
+	return nil
+}
+
+func (a *AuditLog) flush() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.writer == nil {
+		return nil
+	}
+	return a.writer.Flush()
+}
+
+func (a *AuditLog) getRecords(request proto.GetAuditRecordsRequest) (
+	[]proto.AuditRecord, error) {
+	maxRecords := int(request.MaxRecords)
+	if maxRecords < 1 {
+		maxRecords = defaultMaxRecords
+	} else if maxRecords > maximumMaxRecords {
+		maxRecords = maximumMaxRecords
+	}
+	a.mutex.Lock()
+	if a.writer != nil {
+		a.writer.Flush()
+	}
+	filenames, err := a.listFiles()
+	a.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var records []proto.AuditRecord
+	for index, filename := range filenames {
+		if !request.Since.IsZero() && index+1 < len(filenames) {
+			// Skip files which were closed before the start time.
+			nextStart, err := time.ParseInLocation(timeLayout,
+				strings.TrimSuffix(filenames[index+1], fileSuffix), time.UTC)
+			if err == nil && nextStart.Before(request.Since) {
+				continue
+			}
+		}
+		err := readFile(filepath.Join(a.options.Directory, filename),
+			func(record *proto.AuditRecord) {
+				if !matchRecord(record, request) {
+					return
+				}
+				records = append(records, *record)
+				if len(records) >= maxRecords<<1 {
+					records = append(records[:0],
+						records[len(records)-maxRecords:]...)
+				}
+			})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if len(records) > maxRecords {
+		records = records[len(records)-maxRecords:]
+	}
+	return records, nil
+}
+
+// Returns the names of the audit files, oldest first.
+func (a *AuditLog) listFiles() ([]string, error) {
+	names, err := fsutil.ReadDirnames(a.options.Directory, false)
+	if err != nil {
+		return nil, err
+	}
+	filenames := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.HasSuffix(name, fileSuffix) {
+			filenames = append(filenames, name)
+		}
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+// This should be called with the lock held.
+func (a *AuditLog) openNewFile() error {
+	filename := time.Now().UTC().Format(timeLayout) + fileSuffix
+	file, err := os.OpenFile(filepath.Join(a.options.Directory, filename),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, fsutil.PrivateFilePerms)
+	if err != nil {
+		return err
+	}
+	a.file = file
+	a.fileSize = 0
+	a.writer = bufwriter.NewWriter(file, time.Second)
+	return nil
+}
+
+func readFile(filename string, recordFunc func(*proto.AuditRecord)) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var record proto.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue // Skip partially written or corrupt records.
+		}
+		recordFunc(&record)
+	}
+	return scanner.Err()
+}
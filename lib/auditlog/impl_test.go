@@ -0,0 +1,105 @@
+package auditlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	proto "github.com/Cloud-Foundations/Dominator/proto/logger"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	auditLog, err := New(Options{Directory: t.TempDir()},
+		Params{Logger: testlogger.New(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	startTime := time.Now()
+	for index := 0; index < 10; index++ {
+		record := &srpc.AuditRecord{
+			Method:    "Test.Method",
+			StartTime: startTime.Add(time.Duration(index) * time.Second),
+			Username:  "alice",
+		}
+		if index%2 == 1 {
+			record.Error = "failed"
+			record.Username = "bob"
+		}
+		auditLog.Audit(record)
+	}
+	records, err := auditLog.GetRecords(proto.GetAuditRecordsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 10 {
+		t.Fatalf("read %d records, expected 10", len(records))
+	}
+	records, err = auditLog.GetRecords(proto.GetAuditRecordsRequest{
+		FailedOnly: true,
+		MaxRecords: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("read %d records, expected 2", len(records))
+	}
+	if !records[1].StartTime.Equal(startTime.Add(9 * time.Second)) {
+		t.Errorf("wrong last record time: %s", records[1].StartTime)
+	}
+	records, err = auditLog.GetRecords(proto.GetAuditRecordsRequest{
+		Method:   "Test.*",
+		Username: "alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("read %d records, expected 5", len(records))
+	}
+}
+
+func TestRotateAndQuota(t *testing.T) {
+	auditLog, err := New(Options{Directory: t.TempDir()},
+		Params{Logger: testlogger.New(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index := 0; index < 2000; index++ {
+		auditLog.Audit(&srpc.AuditRecord{
+			Method:    "Test.Method",
+			Request:   fmt.Sprintf("{Index:%d}", index),
+			StartTime: time.Now(),
+		})
+	}
+	filenames, err := auditLog.listFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filenames) < 2 {
+		t.Errorf("only %d files, expected rotation", len(filenames))
+	}
+	records, err := auditLog.GetRecords(proto.GetAuditRecordsRequest{
+		MaxRecords: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Request != "{Index:1999}" {
+		t.Errorf("wrong most recent record: %v", records)
+	}
+	var usage int64
+	for _, filename := range filenames {
+		if fi, err := os.Stat(filepath.Join(auditLog.options.Directory,
+			filename)); err == nil {
+			usage += fi.Size()
+		}
+	}
+	if usage > int64(auditLog.options.Quota) {
+		t.Errorf("usage: %d exceeds quota: %d", usage, auditLog.options.Quota)
+	}
+}
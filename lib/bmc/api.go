@@ -0,0 +1,54 @@
+/*
+Package bmc defines a common interface for controlling machines via their
+Baseboard Management Controller (BMC).
+
+Implementations are provided by the ipmi (using the ipmitool utility) and
+redfish (native HTTPS client) sub-packages.
+*/
+package bmc
+
+const (
+	PowerStateUnknown PowerState = iota
+	PowerStateOff
+	PowerStateOn
+)
+
+const (
+	TypeIpmi    = "ipmi"
+	TypeRedfish = "redfish"
+)
+
+// Driver is the interface implemented by BMC drivers. Methods may block while
+// communicating with the BMC.
+type Driver interface {
+	Close() error // Release any cached connections.
+	GetInventory() (*Inventory, error)
+	GetPowerState() (PowerState, error)
+	GetSerialNumber() (string, error)
+	PowerOn() error
+}
+
+type EthernetInterface struct {
+	Name       string
+	MacAddress string `json:",omitempty"`
+	SpeedMbps  uint   `json:",omitempty"`
+}
+
+// Inventory contains hardware inventory data. Fields which are not known by a
+// driver are left empty.
+type Inventory struct {
+	BiosVersion        string              `json:",omitempty"`
+	EthernetInterfaces []EthernetInterface `json:",omitempty"`
+	Manufacturer       string              `json:",omitempty"`
+	MemoryInMiB        uint64              `json:",omitempty"`
+	Model              string              `json:",omitempty"`
+	NumProcessors      uint                `json:",omitempty"`
+	ProcessorModel     string              `json:",omitempty"`
+	SerialNumber       string              `json:",omitempty"`
+}
+
+type PowerState uint
+
+func (state PowerState) String() string {
+	return state.string()
+}
@@ -0,0 +1,12 @@
+package bmc
+
+func (state PowerState) string() string {
+	switch state {
+	case PowerStateOff:
+		return "off"
+	case PowerStateOn:
+		return "on"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,26 @@
+/*
+Package ipmi implements a bmc.Driver using the ipmitool utility with the
+lanplus interface.
+*/
+package ipmi
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/bmc"
+)
+
+type Params struct {
+	Address      string // Hostname or IP address of the BMC.
+	PasswordFile string
+	Username     string
+}
+
+type driver struct {
+	params Params
+}
+
+var _ bmc.Driver = (*driver)(nil)
+
+// New will create a bmc.Driver which uses the ipmitool utility.
+func New(params Params) bmc.Driver {
+	return &driver{params}
+}
@@ -0,0 +1,105 @@
+package ipmi
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/bmc"
+	"github.com/Cloud-Foundations/Dominator/lib/firmware"
+)
+
+const powerOff = "Power is off"
+const powerOn = "Power is on"
+
+func (d *driver) Close() error {
+	return nil
+}
+
+func (d *driver) GetInventory() (*bmc.Inventory, error) {
+	fields, err := d.readFru()
+	if err != nil {
+		return nil, err
+	}
+	inventory := &bmc.Inventory{
+		Manufacturer: fields["Product Manufacturer"],
+		Model:        fields["Product Name"],
+		SerialNumber: serialNumberFromFru(fields),
+	}
+	if inventory.Manufacturer == "" {
+		inventory.Manufacturer = fields["Board Mfg"]
+	}
+	if inventory.Model == "" {
+		inventory.Model = fields["Board Product"]
+	}
+	return inventory, nil
+}
+
+func (d *driver) GetPowerState() (bmc.PowerState, error) {
+	output, err := d.run("chassis", "power", "status")
+	if err != nil {
+		return bmc.PowerStateUnknown, err
+	}
+	if strings.Contains(output, powerOff) {
+		return bmc.PowerStateOff, nil
+	}
+	if strings.Contains(output, powerOn) {
+		return bmc.PowerStateOn, nil
+	}
+	return bmc.PowerStateUnknown, nil
+}
+
+func (d *driver) GetSerialNumber() (string, error) {
+	fields, err := d.readFru()
+	if err != nil {
+		return "", err
+	}
+	return serialNumberFromFru(fields), nil
+}
+
+func (d *driver) PowerOn() error {
+	_, err := d.run("chassis", "power", "on")
+	return err
+}
+
+// readFru returns the name:value fields from the FRU data.
+func (d *driver) readFru() (map[string]string, error) {
+	output, err := d.run("fru", "print")
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		splitLine := strings.Split(line, ":")
+		if len(splitLine) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(splitLine[0])
+		if _, ok := fields[name]; !ok {
+			fields[name] = strings.TrimSpace(splitLine[1])
+		}
+	}
+	return fields, nil
+}
+
+func (d *driver) run(args ...string) (string, error) {
+	cmdArgs := []string{"-H", d.params.Address, "-I", "lanplus",
+		"-U", d.params.Username}
+	if d.params.PasswordFile != "" {
+		cmdArgs = append(cmdArgs, "-f", d.params.PasswordFile)
+	}
+	cmd := exec.Command("ipmitool", append(cmdArgs, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+func serialNumberFromFru(fields map[string]string) string {
+	if serial := firmware.ExtractSerialNumber(
+		fields["Product Serial"]); serial != "" {
+		return serial
+	}
+	return firmware.ExtractSerialNumber(fields["Board Serial"])
+}
@@ -0,0 +1,41 @@
+/*
+Package redfish implements a bmc.Driver using the DMTF Redfish REST API.
+
+The first ComputerSystem listed by the BMC is controlled. HTTP Basic
+authentication is used for every request, which avoids having to manage
+sessions and is supported by all known implementations.
+*/
+package redfish
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/bmc"
+)
+
+type Params struct {
+	// Address of the BMC. This may be a hostname or IP address (HTTPS is
+	// used) or a URL (i.e. http://localhost:8000 for a mock server).
+	Address            string
+	InsecureSkipVerify bool // BMCs typically have self-signed certificates.
+	Password           string
+	Timeout            time.Duration // Default: 30 seconds.
+	Username           string
+}
+
+type driver struct {
+	baseUrl    string
+	httpClient *http.Client
+	params     Params
+	mutex      sync.Mutex // Protect everything below.
+	systemPath string     // Cached path of the ComputerSystem resource.
+}
+
+var _ bmc.Driver = (*driver)(nil)
+
+// New will create a bmc.Driver which uses the Redfish API.
+func New(params Params) bmc.Driver {
+	return newDriver(params)
+}
@@ -0,0 +1,267 @@
+package redfish
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/bmc"
+	"github.com/Cloud-Foundations/Dominator/lib/firmware"
+)
+
+const (
+	resetTypeOn = "On"
+	systemsPath = "/redfish/v1/Systems"
+)
+
+var errNotFound = errors.New("resource not found")
+
+type ethernetInterfaceType struct {
+	Id                  string
+	MACAddress          string
+	Name                string
+	PermanentMACAddress string
+	SpeedMbps           uint
+}
+
+type linkType struct {
+	OdataId string `json:"@odata.id"`
+}
+
+type collectionType struct {
+	Members []linkType
+}
+
+type computerSystemType struct {
+	Actions struct {
+		Reset struct {
+			Target string `json:"target"`
+		} `json:"#ComputerSystem.Reset"`
+	}
+	BiosVersion        string
+	EthernetInterfaces linkType
+	Manufacturer       string
+	MemorySummary      struct {
+		TotalSystemMemoryGiB float64
+	}
+	Model            string
+	PowerState       string
+	ProcessorSummary struct {
+		Count uint
+		Model string
+	}
+	SerialNumber string
+}
+
+func newDriver(params Params) *driver {
+	if params.Timeout <= 0 {
+		params.Timeout = 30 * time.Second
+	}
+	baseUrl := params.Address
+	if !strings.Contains(baseUrl, "://") {
+		baseUrl = "https://" + baseUrl
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if params.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &driver{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		httpClient: &http.Client{
+			Timeout:   params.Timeout,
+			Transport: transport,
+		},
+		params: params,
+	}
+}
+
+func (d *driver) Close() error {
+	d.httpClient.CloseIdleConnections()
+	return nil
+}
+
+func (d *driver) GetInventory() (*bmc.Inventory, error) {
+	system, systemPath, err := d.getSystem()
+	if err != nil {
+		return nil, err
+	}
+	inventory := &bmc.Inventory{
+		BiosVersion:    system.BiosVersion,
+		Manufacturer:   system.Manufacturer,
+		MemoryInMiB:    uint64(system.MemorySummary.TotalSystemMemoryGiB * 1024),
+		Model:          system.Model,
+		NumProcessors:  system.ProcessorSummary.Count,
+		ProcessorModel: system.ProcessorSummary.Model,
+		SerialNumber:   firmware.ExtractSerialNumber(system.SerialNumber),
+	}
+	interfacesPath := system.EthernetInterfaces.OdataId
+	if interfacesPath == "" {
+		interfacesPath = systemPath + "/EthernetInterfaces"
+	}
+	var interfaces collectionType
+	if err := d.get(interfacesPath, &interfaces); err != nil {
+		if errors.Is(err, errNotFound) {
+			return inventory, nil // Not all BMCs support this.
+		}
+		return nil, err
+	}
+	for _, member := range interfaces.Members {
+		var ethernetInterface ethernetInterfaceType
+		if err := d.get(member.OdataId, &ethernetInterface); err != nil {
+			return nil, err
+		}
+		name := ethernetInterface.Name
+		if name == "" {
+			name = ethernetInterface.Id
+		}
+		macAddress := ethernetInterface.PermanentMACAddress
+		if macAddress == "" {
+			macAddress = ethernetInterface.MACAddress
+		}
+		inventory.EthernetInterfaces = append(inventory.EthernetInterfaces,
+			bmc.EthernetInterface{
+				Name:       name,
+				MacAddress: strings.ToLower(macAddress),
+				SpeedMbps:  ethernetInterface.SpeedMbps,
+			})
+	}
+	return inventory, nil
+}
+
+func (d *driver) GetPowerState() (bmc.PowerState, error) {
+	system, _, err := d.getSystem()
+	if err != nil {
+		return bmc.PowerStateUnknown, err
+	}
+	switch system.PowerState {
+	case "Off":
+		return bmc.PowerStateOff, nil
+	case "On", "PoweringOff": // Still drawing power.
+		return bmc.PowerStateOn, nil
+	}
+	return bmc.PowerStateUnknown, nil
+}
+
+func (d *driver) GetSerialNumber() (string, error) {
+	system, _, err := d.getSystem()
+	if err != nil {
+		return "", err
+	}
+	return firmware.ExtractSerialNumber(system.SerialNumber), nil
+}
+
+func (d *driver) PowerOn() error {
+	system, systemPath, err := d.getSystem()
+	if err != nil {
+		return err
+	}
+	target := system.Actions.Reset.Target
+	if target == "" {
+		target = systemPath + "/Actions/ComputerSystem.Reset"
+	}
+	return d.send(http.MethodPost, target,
+		map[string]string{"ResetType": resetTypeOn})
+}
+
+func (d *driver) do(method, path string, body io.Reader) (
+	*http.Response, error) {
+	req, err := http.NewRequest(method, d.baseUrl+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(d.params.Username, d.params.Password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s %s: %w%s", method, path, errNotFound,
+				readErrorMessage(resp.Body))
+		}
+		return nil, fmt.Errorf("%s %s: %s%s", method, path, resp.Status,
+			readErrorMessage(resp.Body))
+	}
+	return resp, nil
+}
+
+func (d *driver) get(path string, value interface{}) error {
+	resp, err := d.do(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+// getSystem will return the ComputerSystem resource and its path.
+func (d *driver) getSystem() (*computerSystemType, string, error) {
+	systemPath, err := d.getSystemPath()
+	if err != nil {
+		return nil, "", err
+	}
+	var system computerSystemType
+	if err := d.get(systemPath, &system); err != nil {
+		return nil, "", err
+	}
+	return &system, systemPath, nil
+}
+
+func (d *driver) getSystemPath() (string, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.systemPath != "" {
+		return d.systemPath, nil
+	}
+	var systems collectionType
+	if err := d.get(systemsPath, &systems); err != nil {
+		return "", err
+	}
+	if len(systems.Members) < 1 || systems.Members[0].OdataId == "" {
+		return "", errors.New("no computer systems found")
+	}
+	d.systemPath = systems.Members[0].OdataId
+	return d.systemPath, nil
+}
+
+func (d *driver) send(method, path string, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	resp, err := d.do(method, path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// readErrorMessage will attempt to extract the message from a Redfish error
+// response, returning it with a leading separator.
+func readErrorMessage(reader io.Reader) string {
+	var errorResponse struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(reader, 64<<10)).Decode(
+		&errorResponse); err != nil {
+		return ""
+	}
+	if errorResponse.Error.Message == "" {
+		return ""
+	}
+	return ": " + errorResponse.Error.Message
+}
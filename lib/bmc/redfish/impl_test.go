@@ -0,0 +1,179 @@
+package redfish
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/bmc"
+)
+
+type mockServer struct {
+	mutex           sync.Mutex
+	interfaceStatus int // Status for EthernetInterfaces (0: OK).
+	powerState      string
+	resetTypes      []string
+}
+
+func (m *mockServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if user, pass, ok := req.BasicAuth(); !ok || user != "admin" ||
+		pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var response interface{}
+	switch req.Method + " " + req.URL.Path {
+	case "GET /redfish/v1/Systems":
+		response = map[string]interface{}{
+			"Members": []map[string]string{
+				{"@odata.id": "/redfish/v1/Systems/1"},
+			},
+		}
+	case "GET /redfish/v1/Systems/1":
+		response = map[string]interface{}{
+			"Actions": map[string]interface{}{
+				"#ComputerSystem.Reset": map[string]interface{}{
+					"target": "/redfish/v1/Systems/1/Actions/ComputerSystem.Reset",
+				},
+			},
+			"BiosVersion": "1.2.3",
+			"EthernetInterfaces": map[string]string{
+				"@odata.id": "/redfish/v1/Systems/1/EthernetInterfaces",
+			},
+			"Manufacturer":     "Acme",
+			"MemorySummary":    map[string]float64{"TotalSystemMemoryGiB": 64},
+			"Model":            "Server 9000",
+			"PowerState":       m.powerState,
+			"ProcessorSummary": map[string]interface{}{"Count": 2},
+			"SerialNumber":     "SN12345",
+		}
+	case "POST /redfish/v1/Systems/1/Actions/ComputerSystem.Reset":
+		var request struct{ ResetType string }
+		json.NewDecoder(req.Body).Decode(&request)
+		m.resetTypes = append(m.resetTypes, request.ResetType)
+		if request.ResetType == "On" {
+			m.powerState = "On"
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case "GET /redfish/v1/Systems/1/EthernetInterfaces":
+		if m.interfaceStatus != 0 {
+			w.WriteHeader(m.interfaceStatus)
+			return
+		}
+		response = map[string]interface{}{
+			"Members": []map[string]string{
+				{"@odata.id": "/redfish/v1/Systems/1/EthernetInterfaces/1"},
+			},
+		}
+	case "GET /redfish/v1/Systems/1/EthernetInterfaces/1":
+		if m.interfaceStatus != 0 {
+			w.WriteHeader(m.interfaceStatus)
+			return
+		}
+		response = map[string]interface{}{
+			"Id":         "1",
+			"MACAddress": "AA:BB:CC:DD:EE:FF",
+			"SpeedMbps":  10000,
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]string{"message": "no such resource"},
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func TestRedfishDriver(t *testing.T) {
+	mock := &mockServer{powerState: "Off"}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+	driver := New(Params{
+		Address:  server.URL,
+		Password: "secret",
+		Username: "admin",
+	})
+	if state, err := driver.GetPowerState(); err != nil {
+		t.Fatal(err)
+	} else if state != bmc.PowerStateOff {
+		t.Errorf("power state: %s != off", state)
+	}
+	if err := driver.PowerOn(); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := driver.GetPowerState(); err != nil {
+		t.Fatal(err)
+	} else if state != bmc.PowerStateOn {
+		t.Errorf("power state: %s != on", state)
+	}
+	if serial, err := driver.GetSerialNumber(); err != nil {
+		t.Fatal(err)
+	} else if serial != "SN12345" {
+		t.Errorf("serial number: %s != SN12345", serial)
+	}
+	inventory, err := driver.GetInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inventory.MemoryInMiB != 64<<10 || inventory.NumProcessors != 2 {
+		t.Errorf("bad inventory: %+v", inventory)
+	}
+	if len(inventory.EthernetInterfaces) != 1 ||
+		inventory.EthernetInterfaces[0].MacAddress != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("bad interfaces: %+v", inventory.EthernetInterfaces)
+	}
+}
+
+func TestRedfishInventoryErrors(t *testing.T) {
+	tests := []struct {
+		status        int
+		expectError   bool
+		numInterfaces int
+	}{
+		{0, false, 1},
+		{http.StatusNotFound, false, 0},
+		{http.StatusInternalServerError, true, 0},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(
+			&mockServer{interfaceStatus: test.status})
+		driver := New(Params{
+			Address:  server.URL,
+			Password: "secret",
+			Username: "admin",
+		})
+		inventory, err := driver.GetInventory()
+		driver.Close()
+		server.Close()
+		if test.expectError {
+			if err == nil {
+				t.Errorf("status: %d: no error", test.status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("status: %d: %s", test.status, err)
+			continue
+		}
+		if len(inventory.EthernetInterfaces) != test.numInterfaces {
+			t.Errorf("status: %d: expected: %d interfaces, got: %d",
+				test.status, test.numInterfaces,
+				len(inventory.EthernetInterfaces))
+		}
+	}
+}
+
+func TestRedfishAuthFailure(t *testing.T) {
+	server := httptest.NewServer(&mockServer{})
+	defer server.Close()
+	driver := New(Params{Address: server.URL, Username: "admin"})
+	if _, err := driver.GetPowerState(); err == nil {
+		t.Error("no error with bad credentials")
+	}
+}
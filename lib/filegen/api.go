@@ -18,6 +18,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/mdb"
 	"github.com/Cloud-Foundations/Dominator/lib/objectserver/memory"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
 	proto "github.com/Cloud-Foundations/Dominator/proto/filegenerator"
 	"github.com/Cloud-Foundations/tricorder/go/tricorder"
 )
@@ -33,6 +34,30 @@ type FileGenerator interface {
 		data []byte, validUntil time.Time, err error)
 }
 
+// SecretsProvider is the interface that wraps the GetSecret method.
+//
+// GetSecret returns the value of the named secret. Names are slash-separated
+// paths, such as "db/password".
+type SecretsProvider interface {
+	GetSecret(name string) (string, error)
+}
+
+// SecretScope restricts which machines may receive secrets with names
+// starting with NamePrefix. NamePrefix is matched on whole path components, so
+// "db" and "db/" match "db/password" but not "dbx/password". Empty fields match
+// all machines.
+type SecretScope struct {
+	NamePrefix  string
+	OwnerGroups []string       `json:",omitempty"` // Match any group.
+	OwnerUsers  []string       `json:",omitempty"` // Match any user.
+	Tags        tags.MatchTags `json:",omitempty"`
+}
+
+type SecretsConfig struct {
+	RefreshInterval time.Duration // Default: 5 minutes.
+	Scopes          []SecretScope // Empty: no secrets for any machine.
+}
+
 type expiringHash struct {
 	hash       hash.Hash
 	length     uint64
@@ -58,6 +83,7 @@ type Manager struct {
 	bucketer     *tricorder.Bucketer
 	objectServer *memory.ObjectServer
 	logger       log.DebugLogger
+	secrets      *secretsManager
 }
 
 // New creates a new *Manager. Only one should be created per application.
@@ -157,6 +183,17 @@ func (m *Manager) RegisterUrlForPath(pathname, URL string) {
 	m.registerUrlForPath(pathname, URL)
 }
 
+// SetSecretsProvider sets the provider of secrets which may be referenced in
+// templates with {{secret "name"}}. Secrets are only available to machines
+// permitted by config.Scopes, so if there are no scopes no secrets are
+// available. Secrets are refreshed every
+// config.RefreshInterval and if a secret changes, the data for the templates
+// which use it are regenerated.
+func (m *Manager) SetSecretsProvider(provider SecretsProvider,
+	config SecretsConfig) {
+	m.secrets.setProvider(provider, config)
+}
+
 // WriteHtml will write status information about the Manager to w, with
 // appropriate HTML markups.
 func (m *Manager) WriteHtml(writer io.Writer) {
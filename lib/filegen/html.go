@@ -9,4 +9,5 @@ func (m *Manager) writeHtml(writer io.Writer) {
 	fmt.Fprintf(writer,
 		"Number of generated files: <a href=\"listGenerators\">%d</a><br>\n",
 		len(m.pathManagers))
+	m.secrets.writeHtml(writer)
 }
@@ -24,11 +24,16 @@ func newManager(logger log.Logger) *Manager {
 		objectServer: memory.NewObjectServer(),
 		pathManagers: make(map[string]*pathManager),
 	}
+	m.secrets = newSecretsManager(m.logger)
 	m.registerMdbGeneratorForPath("/etc/mdb.json")
 	srpc.RegisterNameWithOptions("FileGenerator", &rpcType{m},
 		srpc.ReceiverOptions{
 			PublicMethods: []string{
 				"ListGenerators",
+			},
+			ReadOnlyMethods: []string{
+				"Connect",
+				"ListGenerators",
 			}})
 	return m
 }
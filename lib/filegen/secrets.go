@@ -0,0 +1,221 @@
+package filegen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	"github.com/Cloud-Foundations/Dominator/lib/stringutil"
+	"github.com/Cloud-Foundations/Dominator/lib/tags/tagmatcher"
+)
+
+type cachedSecret struct {
+	value      string
+	generators map[*templateGenerator]struct{} // Users of this secret.
+}
+
+type secretScope struct {
+	namePrefix  string
+	ownerGroups map[string]struct{}
+	ownerUsers  map[string]struct{}
+	tagMatcher  *tagmatcher.TagMatcher
+}
+
+type secretsManager struct {
+	logger log.DebugLogger
+	mutex  sync.Mutex // Protect everything below.
+	// Set once by setProvider.
+	provider SecretsProvider
+	scopes   []secretScope
+	// Protected by lock.
+	secrets map[string]*cachedSecret // Key: secret name.
+}
+
+func newSecretsManager(logger log.DebugLogger) *secretsManager {
+	return &secretsManager{
+		logger:  logger,
+		secrets: make(map[string]*cachedSecret),
+	}
+}
+
+func noSecret(name string) (string, error) {
+	return "", errors.New("no secrets provider")
+}
+
+// isCanonicalSecretName returns true if the name is a relative path without
+// redundant separators or "." and ".." components, so that it can be matched on
+// whole path components.
+func isCanonicalSecretName(name string) bool {
+	if name == "" || path.IsAbs(name) || path.Clean(name) != name {
+		return false
+	}
+	return name != ".." && !strings.HasPrefix(name, "../")
+}
+
+func (scope secretScope) match(machine mdb.Machine) bool {
+	if !scope.tagMatcher.MatchEach(machine.Tags) {
+		return false
+	}
+	if len(scope.ownerGroups) > 0 {
+		matched := false
+		if _, ok := scope.ownerGroups[machine.OwnerGroup]; ok {
+			matched = true
+		}
+		for _, group := range machine.OwnerGroups {
+			if _, ok := scope.ownerGroups[group]; ok {
+				matched = true
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(scope.ownerUsers) > 0 {
+		matched := false
+		for _, user := range machine.OwnerUsers {
+			if _, ok := scope.ownerUsers[user]; ok {
+				matched = true
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchName returns true if the name is the scope name prefix or is below it.
+// The name prefix is matched on whole path components.
+func (scope secretScope) matchName(name string) bool {
+	if scope.namePrefix == "" {
+		return true
+	}
+	return name == scope.namePrefix ||
+		strings.HasPrefix(name, scope.namePrefix+"/")
+}
+
+func (sm *secretsManager) checkAccess(name string, machine mdb.Machine) bool {
+	if !isCanonicalSecretName(name) {
+		return false
+	}
+	for _, scope := range sm.scopes {
+		if scope.matchName(name) && scope.match(machine) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sm *secretsManager) getProvider() SecretsProvider {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	return sm.provider
+}
+
+func (sm *secretsManager) getSecret(name string, machine mdb.Machine,
+	tgen *templateGenerator) (string, error) {
+	if !sm.checkAccess(name, machine) {
+		return "", fmt.Errorf("secret: %s not available for: %s",
+			name, machine.Hostname)
+	}
+	sm.mutex.Lock()
+	if secret, ok := sm.secrets[name]; ok {
+		secret.generators[tgen] = struct{}{}
+		sm.mutex.Unlock()
+		return secret.value, nil
+	}
+	sm.mutex.Unlock()
+	value, err := sm.provider.GetSecret(name)
+	if err != nil {
+		return "", fmt.Errorf("error getting secret: %s: %s", name, err)
+	}
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	secret, ok := sm.secrets[name]
+	if !ok {
+		secret = &cachedSecret{
+			value:      value,
+			generators: make(map[*templateGenerator]struct{}),
+		}
+		sm.secrets[name] = secret
+	}
+	secret.generators[tgen] = struct{}{}
+	return secret.value, nil
+}
+
+// refresh will re-read all cached secrets and will trigger regeneration for
+// the templates which use secrets which have changed.
+func (sm *secretsManager) refresh() {
+	sm.mutex.Lock()
+	names := make([]string, 0, len(sm.secrets))
+	for name := range sm.secrets {
+		names = append(names, name)
+	}
+	sm.mutex.Unlock()
+	generatorsToNotify := make(map[*templateGenerator]struct{})
+	for _, name := range names {
+		value, err := sm.provider.GetSecret(name)
+		if err != nil {
+			sm.logger.Printf("error refreshing secret: %s: %s\n", name, err)
+			continue
+		}
+		sm.mutex.Lock()
+		if secret := sm.secrets[name]; secret.value != value {
+			sm.logger.Printf("secret: %s changed, regenerating\n", name)
+			secret.value = value
+			for tgen := range secret.generators {
+				generatorsToNotify[tgen] = struct{}{}
+			}
+		}
+		sm.mutex.Unlock()
+	}
+	for tgen := range generatorsToNotify {
+		tgen.notifierChannel <- ""
+	}
+}
+
+func (sm *secretsManager) refreshLoop(interval time.Duration) {
+	for range time.Tick(interval) {
+		sm.refresh()
+	}
+}
+
+func (sm *secretsManager) setProvider(provider SecretsProvider,
+	config SecretsConfig) {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = 5 * time.Minute
+	}
+	scopes := make([]secretScope, 0, len(config.Scopes))
+	for _, scope := range config.Scopes {
+		scopes = append(scopes, secretScope{
+			namePrefix:  strings.Trim(scope.NamePrefix, "/"),
+			ownerGroups: stringutil.ConvertListToMap(scope.OwnerGroups, false),
+			ownerUsers:  stringutil.ConvertListToMap(scope.OwnerUsers, false),
+			tagMatcher:  tagmatcher.New(scope.Tags, false),
+		})
+	}
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	if sm.provider != nil {
+		panic("secrets provider already set")
+	}
+	sm.provider = provider
+	sm.scopes = scopes
+	go sm.refreshLoop(config.RefreshInterval)
+}
+
+func (sm *secretsManager) writeHtml(writer io.Writer) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	if sm.provider == nil {
+		return
+	}
+	fmt.Fprintf(writer, "Number of cached secrets: %d, scopes: %d<br>\n",
+		len(sm.secrets), len(sm.scopes))
+}
@@ -0,0 +1,74 @@
+/*
+Package secrets implements providers of secrets for computed files.
+
+The providers implement the lib/filegen.SecretsProvider interface.
+*/
+package secrets
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+// EncryptedFileProvider provides secrets from a local file containing a JSON
+// object (mapping secret names to values) which is encrypted with AES-256-GCM.
+// The file contains a 12 byte nonce followed by the ciphertext.
+type EncryptedFileProvider struct {
+	filename string
+	key      []byte
+	logger   log.DebugLogger
+	rwMutex  sync.RWMutex      // Protect everything below.
+	secrets  map[string]string // Key: secret name.
+}
+
+type VaultConfig struct {
+	Address   string // Example: https://vault.example.com:8200
+	KvVersion uint   // Version of the KV secrets engine. Default: 2.
+	MountPath string // Default: "secret".
+	Token     string // Exclusive of TokenFile.
+	TokenFile string // Read for each request, so that it may be renewed.
+}
+
+// VaultProvider provides secrets from a Vault-compatible KV secrets engine
+// using the HTTP API. The last component of a secret name is the key within
+// the secret at the preceding path, so "db/password" is the "password" key of
+// the "db" secret.
+type VaultProvider struct {
+	config     VaultConfig
+	httpClient *http.Client
+}
+
+// LoadKeyFile will load an AES-256 key from a file. The file may contain 32
+// bytes of raw key data or the key data encoded in hexadecimal.
+func LoadKeyFile(filename string) ([]byte, error) {
+	return loadKeyFile(filename)
+}
+
+// NewEncryptedFileProvider will create an EncryptedFileProvider which reads
+// secrets from filename using the key in keyFile. The file is re-read if it
+// changes.
+func NewEncryptedFileProvider(filename, keyFile string,
+	logger log.DebugLogger) (*EncryptedFileProvider, error) {
+	return newEncryptedFileProvider(filename, keyFile, logger)
+}
+
+// WriteEncryptedFile will write secrets to filename, encrypted with key, in
+// the format read by EncryptedFileProvider.
+func WriteEncryptedFile(filename string, key []byte,
+	secrets map[string]string) error {
+	return writeEncryptedFile(filename, key, secrets)
+}
+
+func (p *EncryptedFileProvider) GetSecret(name string) (string, error) {
+	return p.getSecret(name)
+}
+
+func NewVaultProvider(config VaultConfig) (*VaultProvider, error) {
+	return newVaultProvider(config)
+}
+
+func (p *VaultProvider) GetSecret(name string) (string, error) {
+	return p.getSecret(name)
+}
@@ -0,0 +1,164 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+const (
+	keySize   = 32
+	filePerms = 0600
+)
+
+func decryptSecrets(data, key []byte) (map[string]string, error) {
+	aead, err := newAead(key)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("encrypted data too short")
+	}
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, err
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func encryptSecrets(secrets map[string]string, key []byte) ([]byte, error) {
+	aead, err := newAead(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func loadKeyFile(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == keySize {
+		return data, nil
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding key: %s: %s", filename, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key: %s is %d bytes, not %d",
+			filename, len(key), keySize)
+	}
+	return key, nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func newEncryptedFileProvider(filename, keyFile string,
+	logger log.DebugLogger) (*EncryptedFileProvider, error) {
+	key, err := loadKeyFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	p := &EncryptedFileProvider{
+		filename: filename,
+		key:      key,
+		logger:   logger,
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.load(file); err != nil {
+		return nil, err
+	}
+	readCloserChannel := fsutil.WatchFile(filename, logger)
+	(<-readCloserChannel).Close() // Drain the first event.
+	go p.watch(readCloserChannel)
+	return p, nil
+}
+
+func (p *EncryptedFileProvider) getSecret(name string) (string, error) {
+	p.rwMutex.RLock()
+	defer p.rwMutex.RUnlock()
+	if value, ok := p.secrets[name]; ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("secret: %s not found", name)
+}
+
+func (p *EncryptedFileProvider) load(readCloser io.ReadCloser) error {
+	data, err := ioutil.ReadAll(readCloser)
+	readCloser.Close()
+	if err != nil {
+		return err
+	}
+	secrets, err := decryptSecrets(data, p.key)
+	if err != nil {
+		return fmt.Errorf("error decrypting: %s: %s", p.filename, err)
+	}
+	p.rwMutex.Lock()
+	p.secrets = secrets
+	p.rwMutex.Unlock()
+	return nil
+}
+
+func (p *EncryptedFileProvider) watch(
+	readCloserChannel <-chan io.ReadCloser) {
+	for readCloser := range readCloserChannel {
+		if err := p.load(readCloser); err != nil {
+			p.logger.Println(err)
+		} else {
+			p.logger.Printf("reloaded secrets from: %s\n", p.filename)
+		}
+	}
+}
+
+func writeEncryptedFile(filename string, key []byte,
+	secrets map[string]string) error {
+	data, err := encryptSecrets(secrets, key)
+	if err != nil {
+		return err
+	}
+	writer, err := fsutil.CreateRenamingWriter(filename, filePerms)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		writer.Abort()
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,123 @@
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+)
+
+func TestEncryptedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key")
+	err = ioutil.WriteFile(keyFile,
+		[]byte("000102030405060708090a0b0c0d0e0f"+
+			"101112131415161718191a1b1c1d1e1f\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := LoadKeyFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretsFile := filepath.Join(dir, "secrets")
+	err = WriteEncryptedFile(secretsFile, key,
+		map[string]string{"db/password": "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewEncryptedFileProvider(secretsFile, keyFile,
+		testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := provider.GetSecret("db/password"); err != nil {
+		t.Fatal(err)
+	} else if value != "hunter2" {
+		t.Fatalf("expected: hunter2, got: %s", value)
+	}
+	if _, err := provider.GetSecret("db/username"); err == nil {
+		t.Fatal("expected error for missing secret")
+	}
+	wrongKey := make([]byte, len(key))
+	_, err = decryptSecrets(mustRead(t, secretsFile), wrongKey)
+	if err == nil {
+		t.Fatal("expected error decrypting with wrong key")
+	}
+}
+
+func TestVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("X-Vault-Token") != "token" {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprintln(w, `{"errors":["permission denied"]}`)
+				return
+			}
+			if req.URL.Path == "/v1/secret/data/proxy/error" {
+				w.WriteHeader(http.StatusBadGateway)
+				fmt.Fprintln(w, "<html>Bad Gateway</html>")
+				return
+			}
+			if req.URL.Path != "/v1/secret/data/app/db" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintln(w, `{"errors":[]}`)
+				return
+			}
+			fmt.Fprintln(w,
+				`{"data":{"data":{"password":"hunter2"},"metadata":{}}}`)
+		}))
+	defer server.Close()
+	provider, err := NewVaultProvider(VaultConfig{
+		Address: server.URL,
+		Token:   "token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := provider.GetSecret("app/db/password"); err != nil {
+		t.Fatal(err)
+	} else if value != "hunter2" {
+		t.Fatalf("expected: hunter2, got: %s", value)
+	}
+	if _, err := provider.GetSecret("app/db/username"); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+	if _, err := provider.GetSecret("other/password"); err == nil {
+		t.Fatal("expected error for missing secret")
+	}
+	_, err = provider.GetSecret("proxy/error/password")
+	if err == nil {
+		t.Fatal("expected error for non-Vault error response")
+	} else if !strings.Contains(err.Error(), "502") {
+		t.Fatalf("error does not report status: %s", err)
+	}
+	provider, err = NewVaultProvider(VaultConfig{Address: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = provider.GetSecret("app/db/password")
+	if err == nil {
+		t.Fatal("expected error for missing token")
+	} else if !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("error does not include Vault errors: %s", err)
+	}
+}
+
+func mustRead(t *testing.T, filename string) []byte {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
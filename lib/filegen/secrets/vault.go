@@ -0,0 +1,119 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+type vaultResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+func newVaultProvider(config VaultConfig) (*VaultProvider, error) {
+	if config.Address == "" {
+		return nil, errors.New("no Vault address specified")
+	}
+	if config.Token != "" && config.TokenFile != "" {
+		return nil, errors.New("cannot specify Vault token and token file")
+	}
+	if config.KvVersion == 0 {
+		config.KvVersion = 2
+	}
+	if config.KvVersion > 2 {
+		return nil, fmt.Errorf("unsupported KV version: %d", config.KvVersion)
+	}
+	if config.MountPath == "" {
+		config.MountPath = "secret"
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	return &VaultProvider{
+		config:     config,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (p *VaultProvider) getSecret(name string) (string, error) {
+	secretPath, key := path.Split(path.Clean("/" + name))
+	secretPath = strings.Trim(secretPath, "/")
+	if secretPath == "" || key == "" {
+		return "", fmt.Errorf("secret name: %s must be of the form path/key",
+			name)
+	}
+	token, err := p.getToken()
+	if err != nil {
+		return "", err
+	}
+	var url string
+	if p.config.KvVersion == 1 {
+		url = fmt.Sprintf("%s/v1/%s/%s",
+			p.config.Address, p.config.MountPath, secretPath)
+	} else {
+		url = fmt.Sprintf("%s/v1/%s/data/%s",
+			p.config.Address, p.config.MountPath, secretPath)
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var response vaultResponse
+	if resp.StatusCode != http.StatusOK {
+		// Error responses may not come from Vault (e.g. from a proxy), so only
+		// use the body if it decodes.
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err == nil && len(response.Errors) > 0 {
+			return "", fmt.Errorf("error reading: %s: %s: %s", secretPath,
+				resp.Status, strings.Join(response.Errors, ", "))
+		}
+		return "", fmt.Errorf("error reading: %s: %s", secretPath, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("error decoding response for: %s: %s",
+			secretPath, err)
+	}
+	data := response.Data
+	if p.config.KvVersion == 2 {
+		innerData, ok := data["data"].(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("no data for: %s", secretPath)
+		}
+		data = innerData
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key: %s not found in: %s", key, secretPath)
+	}
+	if stringValue, ok := value.(string); ok {
+		return stringValue, nil
+	}
+	encodedValue, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encodedValue), nil
+}
+
+func (p *VaultProvider) getToken() (string, error) {
+	if p.config.TokenFile == "" {
+		return p.config.Token, nil
+	}
+	data, err := ioutil.ReadFile(p.config.TokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
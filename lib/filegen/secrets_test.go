@@ -0,0 +1,103 @@
+package filegen
+
+import (
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/mdb"
+	"github.com/Cloud-Foundations/Dominator/lib/tags"
+)
+
+type testSecretsProvider map[string]string
+
+func (p testSecretsProvider) GetSecret(name string) (string, error) {
+	return p[name], nil
+}
+
+func TestSecretScopes(t *testing.T) {
+	sm := newSecretsManager(testlogger.New(t))
+	sm.setProvider(testSecretsProvider{"db/password": "hunter2"},
+		SecretsConfig{
+			Scopes: []SecretScope{
+				{
+					NamePrefix:  "db/",
+					OwnerGroups: []string{"dba"},
+					Tags:        tags.MatchTags{"Type": {"database"}},
+				},
+			},
+		})
+	tgen := &templateGenerator{}
+	allowed := mdb.Machine{
+		Hostname:    "db1",
+		OwnerGroups: []string{"dba"},
+		Tags:        tags.Tags{"Type": "database"},
+	}
+	if value, err := sm.getSecret("db/password", allowed, tgen); err != nil {
+		t.Fatal(err)
+	} else if value != "hunter2" {
+		t.Fatalf("expected: hunter2, got: %s", value)
+	}
+	wrongOwner := allowed
+	wrongOwner.OwnerGroups = []string{"web"}
+	if _, err := sm.getSecret("db/password", wrongOwner, tgen); err == nil {
+		t.Fatal("secret available to machine with wrong owner")
+	}
+	wrongTags := allowed
+	wrongTags.Tags = tags.Tags{"Type": "web"}
+	if _, err := sm.getSecret("db/password", wrongTags, tgen); err == nil {
+		t.Fatal("secret available to machine with wrong tags")
+	}
+	if _, err := sm.getSecret("web/password", allowed, tgen); err == nil {
+		t.Fatal("secret outside of scopes available")
+	}
+}
+
+func TestSecretNoScopes(t *testing.T) {
+	sm := newSecretsManager(testlogger.New(t))
+	sm.setProvider(testSecretsProvider{"db/password": "hunter2"},
+		SecretsConfig{})
+	machine := mdb.Machine{Hostname: "db1", OwnerGroups: []string{"dba"}}
+	if _, err := sm.getSecret("db/password", machine,
+		&templateGenerator{}); err == nil {
+		t.Fatal("secret available without any scopes")
+	}
+}
+
+func TestSecretScopePathComponents(t *testing.T) {
+	sm := newSecretsManager(testlogger.New(t))
+	sm.setProvider(testSecretsProvider{},
+		SecretsConfig{
+			Scopes: []SecretScope{
+				{NamePrefix: "db", OwnerGroups: []string{"dba"}},
+				{NamePrefix: "/web/", OwnerGroups: []string{"web"}},
+			},
+		})
+	dba := mdb.Machine{Hostname: "db1", OwnerGroups: []string{"dba"}}
+	web := mdb.Machine{Hostname: "web1", OwnerGroups: []string{"web"}}
+	tests := []struct {
+		name    string
+		machine mdb.Machine
+		allowed bool
+	}{
+		{"db", dba, true},
+		{"db/password", dba, true},
+		{"db/replica/password", dba, true},
+		{"dbx/password", dba, false},
+		{"db-admin/password", dba, false},
+		{"db/../web/password", dba, false},
+		{"db//password", dba, false},
+		{"./db/password", dba, false},
+		{"/db/password", dba, false},
+		{"", dba, false},
+		{"web/password", web, true},
+		{"webhooks/password", web, false},
+		{"web/password", dba, false},
+	}
+	for _, test := range tests {
+		if allowed := sm.checkAccess(test.name, test.machine); allowed !=
+			test.allowed {
+			t.Errorf("access to: \"%s\" for: %s: %v != %v",
+				test.name, test.machine.Hostname, allowed, test.allowed)
+		}
+	}
+}
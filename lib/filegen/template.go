@@ -21,12 +21,14 @@ import (
 var funcMap = template.FuncMap{
 	"Contains":     strings.Contains,
 	"GetSplitPart": getSplitPart,
+	"secret":       noSecret,
 	"ToLower":      strings.ToLower,
 	"ToUpper":      strings.ToUpper,
 }
 
 type templateGenerator struct {
 	objectServer    *memory.ObjectServer
+	secrets         *secretsManager
 	logger          log.Logger
 	template        *template.Template
 	variables       map[string]string
@@ -37,6 +39,7 @@ func (m *Manager) registerTemplateFileForPath(pathname string,
 	config TemplateFileConfig) error {
 	tgen := &templateGenerator{
 		objectServer: m.objectServer,
+		secrets:      m.secrets,
 		logger:       m.logger}
 	tgen.notifierChannel = m.registerHashGeneratorForPath(pathname, tgen)
 	if config.VariablesFile != "" {
@@ -69,8 +72,20 @@ func (tgen *templateGenerator) generate(machine mdb.Machine,
 	if tgen.template == nil {
 		return hash.Hash{}, 0, time.Time{}, errors.New("no template data yet")
 	}
+	tmpl := tgen.template
+	if tgen.secrets.getProvider() != nil {
+		var err error
+		if tmpl, err = tmpl.Clone(); err != nil {
+			return hash.Hash{}, 0, time.Time{}, err
+		}
+		tmpl.Funcs(template.FuncMap{
+			"secret": func(name string) (string, error) {
+				return tgen.secrets.getSecret(name, machine, tgen)
+			},
+		})
+	}
 	buffer := new(bytes.Buffer)
-	if err := tgen.template.Execute(buffer, machine); err != nil {
+	if err := tmpl.Execute(buffer, machine); err != nil {
 		return hash.Hash{}, 0, time.Time{}, err
 	}
 	length := uint64(buffer.Len())
@@ -1,15 +1,51 @@
 package grpc
 
-import "google.golang.org/grpc/codes"
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
 
 // CodedError is implemented by errors that provide a gRPC status code.
 type CodedError interface {
 	GrpcCode() codes.Code
 }
 
+// GatewayConfig contains the configuration for a gRPC gateway.
+type GatewayConfig struct {
+	PortNumber uint
+}
+
+// GatewayParams contains the parameters for a gRPC gateway.
+type GatewayParams struct {
+	Logger log.DebugLogger
+}
+
 // ErrorToStatus converts err into a gRPC status error. Errors implementing
 // CodedError use their declared code; otherwise the message is matched against
 // a table of prefixes and substrings, falling back to codes.Internal.
 func ErrorToStatus(err error) error {
 	return errorToStatus(err)
 }
+
+// NewGatewayServer returns a gRPC server which exposes the request/reply and
+// streaming methods registered with srpc.RegisterName as gRPC services. The
+// gRPC method /Service/Method is mapped to the SRPC method Service.Method.
+// Request/reply methods are mapped to unary calls and streaming methods
+// (those using a Decoder and Encoder) are mapped to bidirectional streams.
+// Raw methods are not exposed. Only methods registered prior to calling
+// NewGatewayServer are exposed.
+// Messages are JSON-encoded, using the same representation as the SRPC JSON
+// coder. Clients should use the application/grpc+json content type.
+// If a server TLS configuration was registered with srpc then TLS is required
+// and the client certificate is used for authentication and authorisation, in
+// the same way as for SRPC connections.
+func NewGatewayServer(params GatewayParams) *grpc.Server {
+	return newGatewayServer(params)
+}
+
+// StartGatewayServer creates a gRPC gateway server with NewGatewayServer and
+// starts serving it in a goroutine on the configured port.
+func StartGatewayServer(config GatewayConfig, params GatewayParams) error {
+	return startGatewayServer(config, params)
+}
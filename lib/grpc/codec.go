@@ -0,0 +1,29 @@
+package grpc
+
+import "encoding/json"
+
+// jsonCodec passes JSON-encoded messages through unchanged so that they may be
+// forwarded to SRPC method handlers using the SRPC JSON coder.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	switch message := v.(type) {
+	case json.RawMessage:
+		return message, nil
+	case *json.RawMessage:
+		return *message, nil
+	}
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if message, ok := v.(*json.RawMessage); ok {
+		*message = append((*message)[:0], data...)
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
@@ -33,11 +33,13 @@ var patternMappings = []struct {
 	{"unauthenticated", codes.Unauthenticated},
 	{"not authenticated", codes.Unauthenticated},
 	{"authentication required", codes.Unauthenticated},
+	{"bad certificate", codes.Unauthenticated},
 	// PermissionDenied.
 	{"permission denied", codes.PermissionDenied},
 	{"access denied", codes.PermissionDenied},
 	{"forbidden", codes.PermissionDenied},
 	{"no access", codes.PermissionDenied},
+	{"access to method denied", codes.PermissionDenied},
 	// NotFound.
 	{"not found", codes.NotFound},
 	{"does not exist", codes.NotFound},
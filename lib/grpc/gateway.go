@@ -0,0 +1,174 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/nulllogger"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type gatewayType struct {
+	methods map[string]srpc.MethodInfo
+	params  GatewayParams
+}
+
+func newGatewayServer(params GatewayParams) *grpc.Server {
+	if params.Logger == nil {
+		params.Logger = nulllogger.New()
+	}
+	gateway := &gatewayType{
+		methods: make(map[string]srpc.MethodInfo),
+		params:  params,
+	}
+	for _, method := range srpc.ListMethods() {
+		if method.IsRequestReply || method.IsStreaming {
+			gateway.methods[method.Name] = method
+		}
+	}
+	options := []grpc.ServerOption{
+		grpc.ForceServerCodec(jsonCodec{}),
+		grpc.UnknownServiceHandler(gateway.handleStream),
+	}
+	if srpc.GetServerTlsConfig() != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(&tls.Config{
+			GetConfigForClient: getConfigForClient,
+		})))
+	}
+	return grpc.NewServer(options...)
+}
+
+func startGatewayServer(config GatewayConfig, params GatewayParams) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", config.PortNumber))
+	if err != nil {
+		return err
+	}
+	server := newGatewayServer(params)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			params.Logger.Printf("gRPC gateway stopped: %s\n", err)
+		}
+	}()
+	return nil
+}
+
+// getConfigForClient returns the current SRPC server TLS configuration, so
+// that certificate refreshes are picked up.
+func getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	config := srpc.GetServerTlsConfig()
+	config.NextProtos = []string{"h2"}
+	return config, nil
+}
+
+// getServiceMethod converts a gRPC method name (/Service/Method) to a SRPC
+// method name (Service.Method).
+func getServiceMethod(fullMethod string) (string, error) {
+	splitMethod := strings.Split(strings.TrimPrefix(fullMethod, "/"), "/")
+	if len(splitMethod) != 2 {
+		return "", status.Errorf(codes.InvalidArgument,
+			"malformed method name: %s", fullMethod)
+	}
+	return splitMethod[0] + "." + splitMethod[1], nil
+}
+
+func getTlsState(stream grpc.ServerStream) *tls.ConnectionState {
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			return &tlsInfo.State
+		}
+	}
+	return nil
+}
+
+func (g *gatewayType) handleStream(srv interface{},
+	stream grpc.ServerStream) error {
+	fullMethod, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "no method in stream")
+	}
+	serviceMethod, err := getServiceMethod(fullMethod)
+	if err != nil {
+		return err
+	}
+	method, ok := g.methods[serviceMethod]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unsupported method: %s",
+			serviceMethod)
+	}
+	call, err := srpc.CallLocalMethod(serviceMethod, getTlsState(stream))
+	if err != nil {
+		return errorToStatus(err)
+	}
+	defer call.Close()
+	g.params.Logger.Debugf(1, "gRPC gateway: calling: %s\n", serviceMethod)
+	if method.IsRequestReply {
+		return g.handleRequestReply(stream, call)
+	}
+	return g.handleStreaming(stream, call)
+}
+
+func (g *gatewayType) handleRequestReply(stream grpc.ServerStream,
+	call *srpc.LocalCall) error {
+	var request json.RawMessage
+	if err := stream.RecvMsg(&request); err != nil {
+		return err
+	}
+	if len(request) < 1 {
+		request = json.RawMessage("{}")
+	}
+	var reply json.RawMessage
+	if err := call.RequestReply(request, &reply); err != nil {
+		return errorToStatus(err)
+	}
+	return stream.SendMsg(reply)
+}
+
+func (g *gatewayType) handleStreaming(stream grpc.ServerStream,
+	call *srpc.LocalCall) error {
+	receiveErrorChannel := make(chan error, 1)
+	go func() {
+		for {
+			var message json.RawMessage
+			if err := stream.RecvMsg(&message); err != nil {
+				if err != io.EOF { // Client has gone away: abort the call.
+					receiveErrorChannel <- err
+					call.Close()
+				}
+				return
+			}
+			if err := call.Encode(message); err != nil {
+				return
+			}
+			if err := call.Flush(); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		var message json.RawMessage
+		if err := call.Decode(&message); err != nil {
+			break // Method handler has returned.
+		}
+		if err := stream.SendMsg(message); err != nil {
+			return err
+		}
+	}
+	if err := call.Wait(); err != nil {
+		select {
+		case err := <-receiveErrorChannel:
+			return err
+		default:
+		}
+		return errorToStatus(err)
+	}
+	return nil
+}
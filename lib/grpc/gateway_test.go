@@ -0,0 +1,129 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+type gatewayTestType struct{}
+
+func init() {
+	srpc.RegisterName("GatewayTest", &gatewayTestType{})
+}
+
+func (t *gatewayTestType) Echo(conn *srpc.Conn, request test.EchoRequest,
+	response *test.EchoResponse) error {
+	*response = test.EchoResponse{Response: request.Request}
+	return nil
+}
+
+func (t *gatewayTestType) Fail(conn *srpc.Conn, request test.EchoRequest,
+	response *test.EchoResponse) error {
+	return errors.New("not found: " + request.Request)
+}
+
+func (t *gatewayTestType) Raw(conn *srpc.Conn) error {
+	return nil
+}
+
+// Stream echoes each request until a request of "done" is received.
+func (t *gatewayTestType) Stream(conn *srpc.Conn, decoder srpc.Decoder,
+	encoder srpc.Encoder) error {
+	for {
+		var request test.EchoRequest
+		if err := decoder.Decode(&request); err != nil {
+			return err
+		}
+		if request.Request == "done" {
+			return nil
+		}
+		err := encoder.Encode(test.EchoResponse{Response: request.Request})
+		if err != nil {
+			return err
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func TestGateway(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewGatewayServer(GatewayParams{})
+	go server.Serve(listener)
+	defer server.Stop()
+	conn, err := grpc.NewClient("passthrough:///"+listener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// Request/reply.
+	var response test.EchoResponse
+	err = conn.Invoke(ctx, "/GatewayTest/Echo",
+		test.EchoRequest{Request: "hello"}, &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Response != "hello" {
+		t.Errorf("Echo: expected: hello, got: %s", response.Response)
+	}
+	// Errors are mapped to codes.
+	err = conn.Invoke(ctx, "/GatewayTest/Fail",
+		test.EchoRequest{Request: "thing"}, &response)
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("Fail: expected: %s, got: %s (%v)", codes.NotFound, code, err)
+	}
+	// Raw and unknown methods are not exposed.
+	for _, method := range []string{"/GatewayTest/Raw", "/GatewayTest/None"} {
+		err := conn.Invoke(ctx, method, test.EchoRequest{}, &response)
+		if code := status.Code(err); code != codes.Unimplemented {
+			t.Errorf("%s: expected: %s, got: %s (%v)",
+				method, codes.Unimplemented, code, err)
+		}
+	}
+	// Streaming.
+	stream, err := conn.NewStream(ctx,
+		&grpc.StreamDesc{ClientStreams: true, ServerStreams: true},
+		"/GatewayTest/Stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range []string{"one", "two"} {
+		err := stream.SendMsg(test.EchoRequest{Request: request})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response test.EchoResponse
+		if err := stream.RecvMsg(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Response != request {
+			t.Errorf("Stream: expected: %s, got: %s",
+				request, response.Response)
+		}
+	}
+	if err := stream.SendMsg(test.EchoRequest{Request: "done"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.RecvMsg(&response); err != io.EOF {
+		t.Errorf("Stream: expected EOF after handler returned, got: %v", err)
+	}
+}
@@ -46,6 +46,12 @@ func RegisterHtmlWriterForPattern(pattern, title string,
 	registerHtmlWriterForPattern(pattern, title, htmlWriter)
 }
 
+// RegisterHeaderLink registers a link which is written by the WriteHeader*
+// functions, below the link to the metrics.
+func RegisterHeaderLink(text, url string) {
+	registerHeaderLink(text, url)
+}
+
 func ServeMuxHandleFunc(serveMux *http.ServeMux, pattern string,
 	handler func(w http.ResponseWriter, req *http.Request)) {
 	handleFunc(serveMux, pattern, handler)
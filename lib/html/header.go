@@ -7,6 +7,7 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Cloud-Foundations/Dominator/lib/format"
@@ -24,9 +25,17 @@ type cpuStats struct {
 	sysTime  time.Duration
 }
 
+type headerLinkType struct {
+	text string
+	url  string
+}
+
 var (
 	startCpuStats *allCpuStats = getCpuStats()
 	lastCpuStats  *allCpuStats = startCpuStats
+
+	headerLinksMutex sync.Mutex
+	headerLinks      []headerLinkType
 )
 
 func handleFunc(serveMux *http.ServeMux, pattern string,
@@ -38,6 +47,12 @@ func handleFunc(serveMux *http.ServeMux, pattern string,
 		})
 }
 
+func registerHeaderLink(text, url string) {
+	headerLinksMutex.Lock()
+	defer headerLinksMutex.Unlock()
+	headerLinks = append(headerLinks, headerLinkType{text: text, url: url})
+}
+
 func setSecurityHeaders(w http.ResponseWriter) {
 	w.Header().Set("X-Frame-Options", "DENY")
 	w.Header().Set("X-XSS-Protection", "1")
@@ -113,6 +128,11 @@ func writeHeader(writer io.Writer, req *http.Request, noGC bool) {
 	fmt.Fprintln(writer, "    <td></td>")
 	fmt.Fprintf(writer, "</table>\n")
 	fmt.Fprintln(writer, "Raw <a href=\"metrics\">metrics</a><br>")
+	headerLinksMutex.Lock()
+	for _, link := range headerLinks {
+		fmt.Fprintf(writer, "<a href=\"%s\">%s</a><br>\n", link.url, link.text)
+	}
+	headerLinksMutex.Unlock()
 	if req != nil {
 		protocol := "http"
 		if req.TLS != nil {
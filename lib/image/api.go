@@ -17,8 +17,34 @@ type Annotation struct {
 	URL    string
 }
 
+// Channel is a named pointer to an image in a directory (image stream), such
+// as "prod". Channels are referenced as "directory@channel".
+type Channel struct {
+	History    []ChannelUpdate // Oldest first. Includes the current image.
+	ImageName  string
+	OwnerGroup string // If set, membership is required to update.
+}
+
+type ChannelUpdate struct {
+	Comment   string `json:",omitempty"`
+	ImageName string // Empty: channel was deleted.
+	UpdatedAt time.Time
+	UpdatedBy string
+}
+
+// RetentionPolicy specifies which images in a directory are kept. An image is
+// deleted if it is not one of the latest KeepLatest images, is not referenced
+// by the MDB or a channel and is older than MaximumAge.
+type RetentionPolicy struct {
+	KeepLatest uint
+	MaximumAge time.Duration `json:",omitempty"` // Zero: any age.
+}
+
 type DirectoryMetadata struct {
-	OwnerGroup string
+	Channels        map[string]Channel `json:",omitempty"` // Key: channel name.
+	ModifiedAt      time.Time          // Zero if never modified.
+	OwnerGroup      string
+	RetentionPolicy *RetentionPolicy `json:",omitempty"`
 }
 
 type Directory struct {
@@ -26,6 +52,10 @@ type Directory struct {
 	Metadata DirectoryMetadata
 }
 
+func (left *DirectoryMetadata) Equal(right *DirectoryMetadata) bool {
+	return left.equal(right)
+}
+
 type Image struct {
 	BuildBranch   string
 	BuildCommitId string
@@ -52,6 +82,15 @@ type Package struct {
 	Version string
 }
 
+// FindDeltaBases will return a table of objects which may be fetched as deltas
+// against objects from the previous image. The keys are the hashes of regular
+// files of at least minimumSize bytes and the values are the hashes of the
+// files at the same paths in the previous image, where they differ.
+func (image *Image) FindDeltaBases(previous *Image,
+	minimumSize uint64) map[hash.Hash]hash.Hash {
+	return image.findDeltaBases(previous, minimumSize)
+}
+
 // ForEachObject will call objectFunc for all objects (including those for
 // annotations) for the image. If objectFunc returns a non-nil error, processing
 // stops and the error is returned.
@@ -67,6 +106,23 @@ func (image *Image) GetMissingObjects(objectServer objectserver.ObjectServer,
 	return image.getMissingObjects(objectServer, objectsGetter, logger)
 }
 
+// GetMissingObjectsWithDeltas is similar to GetMissingObjects, except that
+// regular files of at least minimumSize bytes which have changed since the
+// previous image are fetched as deltas against the object from the previous
+// image at the same path, if objectsGetter supports fetching deltas. Objects
+// which cannot be fetched as deltas are fetched in full. Objects being
+// reconstructed from deltas are staged in stagingDirectory, which should be on
+// the same file-system as the objects. If stagingDirectory is "", the default
+// directory for temporary files is used.
+func (image *Image) GetMissingObjectsWithDeltas(
+	objectServer objectserver.ObjectServer,
+	objectsGetter objectserver.ObjectsGetter, previous *Image,
+	minimumSize uint64, stagingDirectory string,
+	logger log.DebugLogger) error {
+	return image.getMissingObjectsWithDeltas(objectServer, objectsGetter,
+		previous, minimumSize, stagingDirectory, logger)
+}
+
 func (image *Image) ListMissingObjects(
 	objectsChecker objectserver.ObjectsChecker) ([]hash.Hash, error) {
 	return image.listMissingObjects(objectsChecker)
@@ -106,6 +162,21 @@ func (image *Image) VerifyRequiredPaths(requiredPaths map[string]rune) error {
 	return image.verifyRequiredPaths(requiredPaths)
 }
 
+// IsChannelReference returns true if name is a channel reference of the form
+// "directory@channel".
+func IsChannelReference(name string) bool {
+	_, _, ok := SplitChannelReference(name)
+	return ok
+}
+
 func SortDirectories(directories []Directory) {
 	sortDirectories(directories)
 }
+
+// SplitChannelReference will split a channel reference of the form
+// "directory@channel" into the directory and channel names. If name is not a
+// channel reference, ok is false.
+func SplitChannelReference(name string) (
+	directory string, channel string, ok bool) {
+	return splitChannelReference(name)
+}
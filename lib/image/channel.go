@@ -0,0 +1,60 @@
+package image
+
+import (
+	"strings"
+)
+
+func (left *Channel) equal(right *Channel) bool {
+	if left.ImageName != right.ImageName ||
+		left.OwnerGroup != right.OwnerGroup ||
+		len(left.History) != len(right.History) {
+		return false
+	}
+	for index, leftUpdate := range left.History {
+		rightUpdate := right.History[index]
+		if leftUpdate.Comment != rightUpdate.Comment ||
+			leftUpdate.ImageName != rightUpdate.ImageName ||
+			!leftUpdate.UpdatedAt.Equal(rightUpdate.UpdatedAt) ||
+			leftUpdate.UpdatedBy != rightUpdate.UpdatedBy {
+			return false
+		}
+	}
+	return true
+}
+
+func (left *DirectoryMetadata) equal(right *DirectoryMetadata) bool {
+	if left.OwnerGroup != right.OwnerGroup ||
+		!left.ModifiedAt.Equal(right.ModifiedAt) ||
+		len(left.Channels) != len(right.Channels) {
+		return false
+	}
+	if (left.RetentionPolicy == nil) != (right.RetentionPolicy == nil) {
+		return false
+	}
+	if left.RetentionPolicy != nil &&
+		*left.RetentionPolicy != *right.RetentionPolicy {
+		return false
+	}
+	for name, leftChannel := range left.Channels {
+		rightChannel, ok := right.Channels[name]
+		if !ok {
+			return false
+		}
+		if !leftChannel.equal(&rightChannel) {
+			return false
+		}
+	}
+	return true
+}
+
+func splitChannelReference(name string) (string, string, bool) {
+	index := strings.LastIndexByte(name, '@')
+	if index < 1 || index == len(name)-1 {
+		return "", "", false
+	}
+	channel := name[index+1:]
+	if strings.ContainsRune(channel, '/') {
+		return "", "", false
+	}
+	return name[:index], channel, true
+}
@@ -0,0 +1,31 @@
+package image
+
+import (
+	"testing"
+)
+
+func TestSplitChannelReference(t *testing.T) {
+	tests := []struct {
+		name      string
+		directory string
+		channel   string
+		ok        bool
+	}{
+		{"stream@prod", "stream", "prod", true},
+		{"a/b/stream@staging", "a/b/stream", "staging", true},
+		{"stream/2024-01-02:03:04:05", "", "", false},
+		{"@prod", "", "", false},
+		{"stream@", "", "", false},
+		{"stream@prod/image", "", "", false},
+		{"stream", "", "", false},
+	}
+	for _, test := range tests {
+		directory, channel, ok := SplitChannelReference(test.name)
+		if directory != test.directory || channel != test.channel ||
+			ok != test.ok {
+			t.Errorf("SplitChannelReference(%s) = (%s, %s, %v), "+
+				"want: (%s, %s, %v)", test.name, directory, channel, ok,
+				test.directory, test.channel, test.ok)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package image
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver"
+)
+
+func (image *Image) findDeltaBases(previous *Image,
+	minimumSize uint64) map[hash.Hash]hash.Hash {
+	deltaBases := make(map[hash.Hash]hash.Hash)
+	if image.FileSystem == nil || previous == nil ||
+		previous.FileSystem == nil {
+		return deltaBases
+	}
+	inodeTable := image.FileSystem.InodeTable
+	previousInodeTable := previous.FileSystem.InodeTable
+	previousFilenames := previous.FileSystem.FilenameToInodeTable()
+	for filename, inum := range image.FileSystem.FilenameToInodeTable() {
+		inode, ok := inodeTable[inum].(*filesystem.RegularInode)
+		if !ok || inode.Size < minimumSize {
+			continue
+		}
+		if _, ok := deltaBases[inode.Hash]; ok {
+			continue
+		}
+		previousInum, ok := previousFilenames[filename]
+		if !ok {
+			continue
+		}
+		previousInode, ok :=
+			previousInodeTable[previousInum].(*filesystem.RegularInode)
+		if !ok || previousInode.Size < 1 || previousInode.Hash == inode.Hash {
+			continue
+		}
+		deltaBases[inode.Hash] = previousInode.Hash
+	}
+	return deltaBases
+}
+
+func (image *Image) getMissingObjectsWithDeltas(
+	objectServer objectserver.ObjectServer,
+	objectsGetter objectserver.ObjectsGetter, previous *Image,
+	minimumSize uint64, stagingDirectory string,
+	logger log.DebugLogger) error {
+	deltaGetter, ok := objectsGetter.(objectserver.ObjectDeltaGetter)
+	if !ok || previous == nil {
+		return image.getMissingObjects(objectServer, objectsGetter, logger)
+	}
+	missingObjects, err := image.ListMissingObjects(objectServer)
+	if err != nil {
+		return err
+	}
+	if len(missingObjects) < 1 {
+		return nil
+	}
+	image.logMissingObjects(len(missingObjects), logger)
+	deltaBases := image.findDeltaBases(previous, minimumSize)
+	var deltaObjects, fullObjects []hash.Hash
+	for _, hashVal := range missingObjects {
+		if _, ok := deltaBases[hashVal]; ok {
+			deltaObjects = append(deltaObjects, hashVal)
+		} else {
+			fullObjects = append(fullObjects, hashVal)
+		}
+	}
+	if len(deltaObjects) > 0 {
+		failedObjects, err := fetchDeltas(objectServer, deltaGetter,
+			deltaObjects, deltaBases, stagingDirectory, logger)
+		if err != nil {
+			return err
+		}
+		fullObjects = append(fullObjects, failedObjects...)
+	}
+	if len(fullObjects) < 1 {
+		return nil
+	}
+	return fetchObjects(objectServer, objectsGetter, fullObjects, logger)
+}
+
+// fetchDeltas will fetch the specified objects as deltas against their bases.
+// Objects which could not be fetched this way are returned, so that they may
+// be fetched in full.
+func fetchDeltas(objectServer objectserver.ObjectServer,
+	deltaGetter objectserver.ObjectDeltaGetter, hashes []hash.Hash,
+	deltaBases map[hash.Hash]hash.Hash, stagingDirectory string,
+	logger log.DebugLogger) ([]hash.Hash, error) {
+	baseHashes := make([]hash.Hash, 0, len(hashes))
+	for _, hashVal := range hashes {
+		baseHashes = append(baseHashes, deltaBases[hashVal])
+	}
+	baseSizes, err := objectServer.CheckObjects(baseHashes)
+	if err != nil {
+		return nil, err
+	}
+	startTime := time.Now()
+	var failedObjects []hash.Hash
+	var numDeltas, totalBytes uint64
+	for index, hashVal := range hashes {
+		if baseSizes[index] < 1 {
+			failedObjects = append(failedObjects, hashVal)
+			continue
+		}
+		size, err := fetchDelta(objectServer, deltaGetter, hashVal,
+			baseHashes[index], stagingDirectory)
+		if err != nil {
+			logger.Debugf(0, "error fetching delta for: %x: %s\n",
+				hashVal, err)
+			failedObjects = append(failedObjects, hashVal)
+			continue
+		}
+		numDeltas++
+		totalBytes += size
+	}
+	if numDeltas > 0 {
+		logger.Printf("fetched %d objects (%s) using deltas in %s\n",
+			numDeltas, format.FormatBytes(totalBytes),
+			format.Duration(time.Since(startTime)))
+	}
+	return failedObjects, nil
+}
+
+func fetchDelta(objectServer objectserver.ObjectServer,
+	deltaGetter objectserver.ObjectDeltaGetter, hashVal, baseHash hash.Hash,
+	stagingDirectory string) (uint64, error) {
+	file, err := ioutil.TempFile(stagingDirectory, ".delta.")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	baseSize, reader, err := objectServer.GetObject(baseHash)
+	if err != nil {
+		return 0, err
+	}
+	_, err = io.Copy(file, reader)
+	reader.Close()
+	if err != nil {
+		return 0, err
+	}
+	_, reader, err = objectServer.GetObject(baseHash)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	size, err := deltaGetter.GetObjectDelta(hashVal, reader, baseSize, file)
+	if err != nil {
+		return 0, err
+	}
+	if err := file.Truncate(int64(size)); err != nil {
+		return 0, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, _, err := objectServer.AddObject(file, size, &hashVal); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
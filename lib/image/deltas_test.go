@@ -0,0 +1,188 @@
+package image
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/memory"
+)
+
+type testFile struct {
+	data    []byte
+	symlink bool
+}
+
+type testDeltaGetter struct {
+	*memory.ObjectServer
+	stagingFilenames []string
+}
+
+func (getter *testDeltaGetter) GetObjectDelta(hashVal hash.Hash,
+	base io.Reader, baseSize uint64, writer io.WriteSeeker) (uint64, error) {
+	if file, ok := writer.(*os.File); ok {
+		getter.stagingFilenames = append(getter.stagingFilenames, file.Name())
+	}
+	size, reader, err := getter.GetObject(hashVal)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	if _, err := writer.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(writer, reader); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func TestFindDeltaBases(t *testing.T) {
+	images := make([]*Image, 2) // Previous and current.
+	for index, files := range []map[string]testFile{
+		{
+			"changed":    {data: []byte("old contents of changed")},
+			"empty":      {},
+			"link":       {data: []byte("old contents of link")},
+			"small":      {data: []byte("old")},
+			"unchanged":  {data: []byte("same contents as before")},
+			"wasSymlink": {data: []byte("target"), symlink: true},
+		},
+		{
+			"added":      {data: []byte("contents of a new file")},
+			"changed":    {data: []byte("new contents of changed")},
+			"empty":      {data: []byte("contents of a previously empty file")},
+			"link":       {data: []byte("new contents"), symlink: true},
+			"small":      {data: []byte("new")},
+			"unchanged":  {data: []byte("same contents as before")},
+			"wasSymlink": {data: []byte("contents of a previous symlink")},
+		},
+	} {
+		fs := &filesystem.FileSystem{InodeTable: make(filesystem.InodeTable)}
+		inum := uint64(1)
+		for name, file := range files {
+			if file.symlink {
+				fs.InodeTable[inum] = &filesystem.SymlinkInode{
+					Symlink: string(file.data),
+				}
+			} else {
+				fs.InodeTable[inum] = &filesystem.RegularInode{
+					Size: uint64(len(file.data)),
+					Hash: sha512.Sum512(file.data),
+				}
+			}
+			fs.EntryList = append(fs.EntryList,
+				&filesystem.DirectoryEntry{Name: name, InodeNumber: inum})
+			inum++
+		}
+		if err := fs.RebuildInodePointers(); err != nil {
+			t.Fatal(err)
+		}
+		images[index] = &Image{FileSystem: fs}
+	}
+	previous, current := images[0], images[1]
+	deltaBases := current.FindDeltaBases(previous, 10)
+	expected := map[hash.Hash]hash.Hash{
+		sha512.Sum512([]byte("new contents of changed")): sha512.Sum512(
+			[]byte("old contents of changed")),
+	}
+	if !reflect.DeepEqual(deltaBases, expected) {
+		t.Errorf("delta bases: %v != %v", deltaBases, expected)
+	}
+	deltaBases = current.FindDeltaBases(previous, 1)
+	if len(deltaBases) != 2 {
+		t.Errorf("number of delta bases for small files: %d != 2",
+			len(deltaBases))
+	}
+	if len(current.FindDeltaBases(nil, 1)) > 0 {
+		t.Error("delta bases found without previous image")
+	}
+}
+
+func TestGetMissingObjectsWithDeltas(t *testing.T) {
+	images := make([]*Image, 2) // Previous and current.
+	for index, files := range []map[string]testFile{
+		{
+			"changed": {data: []byte("old contents of changed")},
+		},
+		{
+			"added":   {data: []byte("contents of a new file")},
+			"changed": {data: []byte("new contents of changed")},
+		},
+	} {
+		fs := &filesystem.FileSystem{InodeTable: make(filesystem.InodeTable)}
+		inum := uint64(1)
+		for name, file := range files {
+			if file.symlink {
+				fs.InodeTable[inum] = &filesystem.SymlinkInode{
+					Symlink: string(file.data),
+				}
+			} else {
+				fs.InodeTable[inum] = &filesystem.RegularInode{
+					Size: uint64(len(file.data)),
+					Hash: sha512.Sum512(file.data),
+				}
+			}
+			fs.EntryList = append(fs.EntryList,
+				&filesystem.DirectoryEntry{Name: name, InodeNumber: inum})
+			inum++
+		}
+		if err := fs.RebuildInodePointers(); err != nil {
+			t.Fatal(err)
+		}
+		images[index] = &Image{FileSystem: fs}
+	}
+	previous, current := images[0], images[1]
+	objectServer := memory.NewObjectServer()
+	getter := &testDeltaGetter{ObjectServer: memory.NewObjectServer()}
+	for _, data := range []string{
+		"old contents of changed",
+		"new contents of changed",
+		"contents of a new file",
+	} {
+		_, _, err := getter.AddObject(bytes.NewReader([]byte(data)),
+			uint64(len(data)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _, err := objectServer.AddObject(
+		bytes.NewReader([]byte("old contents of changed")), 23, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stagingDirectory := t.TempDir()
+	err = current.GetMissingObjectsWithDeltas(objectServer, getter, previous,
+		1, stagingDirectory, testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	missingObjects, err := current.ListMissingObjects(objectServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missingObjects) > 0 {
+		t.Errorf("%d objects still missing", len(missingObjects))
+	}
+	if len(getter.stagingFilenames) != 1 {
+		t.Fatalf("number of deltas fetched: %d != 1",
+			len(getter.stagingFilenames))
+	}
+	if dirname := filepath.Dir(getter.stagingFilenames[0]); dirname !=
+		stagingDirectory {
+		t.Errorf("delta staged in: %s instead of: %s",
+			dirname, stagingDirectory)
+	}
+	if names, err := os.ReadDir(stagingDirectory); err != nil {
+		t.Fatal(err)
+	} else if len(names) > 0 {
+		t.Errorf("%d staging files left behind", len(names))
+	}
+}
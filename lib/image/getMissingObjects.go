@@ -19,13 +19,22 @@ func (image *Image) getMissingObjects(objectServer objectserver.ObjectServer,
 	if len(missingObjects) < 1 {
 		return nil
 	}
+	image.logMissingObjects(len(missingObjects), logger)
+	return fetchObjects(objectServer, objectsGetter, missingObjects, logger)
+}
+
+func (image *Image) logMissingObjects(numMissing int, logger log.Logger) {
 	var numObjects uint64
 	image.ForEachObject(func(hashVal hash.Hash) error {
 		numObjects++
 		return nil
 	})
-	logger.Printf("downloading %d of %d objects\n",
-		len(missingObjects), numObjects)
+	logger.Printf("downloading %d of %d objects\n", numMissing, numObjects)
+}
+
+func fetchObjects(objectServer objectserver.ObjectServer,
+	objectsGetter objectserver.ObjectsGetter, missingObjects []hash.Hash,
+	logger log.Logger) error {
 	startTime := time.Now()
 	objectsReader, err := objectsGetter.GetObjects(missingObjects)
 	if err != nil {
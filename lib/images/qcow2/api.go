@@ -36,3 +36,34 @@ func ReadHeaderFromFile(filename string) (*Header, error) {
 func Unmarshal(data []byte, v *Header) error {
 	return unmarshal(data, v)
 }
+
+// SparseImage is a QCOW2 (version 2) image which is constructed from raw data,
+// omitting clusters which contain only zeros.
+type SparseImage struct {
+	allocated             []bool
+	dataOffset            uint64
+	l1Clusters            uint64
+	l2Offsets             []uint64
+	numDataClusters       uint64
+	numL2Tables           uint64
+	reader                io.ReaderAt
+	refcountBlocks        uint64
+	refcountTableClusters uint64
+	virtualSize           uint64
+}
+
+// NewSparseImage will scan size bytes of raw data from reader and will prepare
+// a sparse QCOW2 image. The data are read again when the image is written.
+func NewSparseImage(reader io.ReaderAt, size uint64) (*SparseImage, error) {
+	return newSparseImage(reader, size)
+}
+
+// Size returns the size of the QCOW2 image in bytes.
+func (image *SparseImage) Size() uint64 {
+	return image.size()
+}
+
+// WriteTo will write the QCOW2 image to writer. It implements io.WriterTo.
+func (image *SparseImage) WriteTo(writer io.Writer) (int64, error) {
+	return image.writeTo(writer)
+}
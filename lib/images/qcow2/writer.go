@@ -0,0 +1,205 @@
+package qcow2
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	clusterBits       = 16
+	clusterSize       = 1 << clusterBits
+	copiedFlag        = uint64(1) << 63
+	entriesPerCluster = clusterSize / 8
+	refcountsPerBlock = clusterSize / 2
+)
+
+func ceilDiv(numerator, denominator uint64) uint64 {
+	return (numerator + denominator - 1) / denominator
+}
+
+func isZero(buffer []byte) bool {
+	for _, value := range buffer {
+		if value != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// newSparseImage will scan the data for clusters which are not all zero and
+// will compute the layout of the image. All metadata are placed before the
+// data clusters so that the image may be streamed.
+func newSparseImage(reader io.ReaderAt, size uint64) (*SparseImage, error) {
+	if size < 1 {
+		return nil, errors.New("zero size")
+	}
+	image := &SparseImage{
+		reader:      reader,
+		virtualSize: size,
+	}
+	numClusters := ceilDiv(size, clusterSize)
+	image.allocated = make([]bool, numClusters)
+	buffer := make([]byte, clusterSize)
+	l2Used := make([]bool, ceilDiv(numClusters, entriesPerCluster))
+	for index := uint64(0); index < numClusters; index++ {
+		nRead, err := readCluster(reader, buffer, index, size)
+		if err != nil {
+			return nil, err
+		}
+		if !isZero(buffer[:nRead]) {
+			image.allocated[index] = true
+			image.numDataClusters++
+			l2Used[index/entriesPerCluster] = true
+		}
+	}
+	image.l2Offsets = make([]uint64, len(l2Used))
+	image.l1Clusters = ceilDiv(uint64(len(l2Used))*8, clusterSize)
+	for _, used := range l2Used {
+		if used {
+			image.numL2Tables++
+		}
+	}
+	// The refcount blocks must cover themselves, so iterate until stable.
+	for {
+		total := image.totalClusters()
+		refcountBlocks := ceilDiv(total, refcountsPerBlock)
+		refcountTableClusters := ceilDiv(refcountBlocks*8, clusterSize)
+		if refcountBlocks == image.refcountBlocks &&
+			refcountTableClusters == image.refcountTableClusters {
+			break
+		}
+		image.refcountBlocks = refcountBlocks
+		image.refcountTableClusters = refcountTableClusters
+	}
+	nextCluster := image.totalClusters() - image.numDataClusters -
+		image.numL2Tables
+	for index, used := range l2Used {
+		if used {
+			image.l2Offsets[index] = nextCluster * clusterSize
+			nextCluster++
+		}
+	}
+	image.dataOffset = nextCluster * clusterSize
+	return image, nil
+}
+
+func readCluster(reader io.ReaderAt, buffer []byte, index uint64,
+	size uint64) (int, error) {
+	offset := index * clusterSize
+	length := uint64(len(buffer))
+	if offset+length > size {
+		length = size - offset
+	}
+	nRead, err := reader.ReadAt(buffer[:length], int64(offset))
+	if err == io.EOF && uint64(nRead) == length {
+		err = nil
+	}
+	return nRead, err
+}
+
+func (image *SparseImage) makeHeader() []byte {
+	header := make([]byte, clusterSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint32(header[4:], 2)
+	binary.BigEndian.PutUint32(header[20:], clusterBits)
+	binary.BigEndian.PutUint64(header[24:], image.virtualSize)
+	binary.BigEndian.PutUint32(header[36:], uint32(len(image.l2Offsets)))
+	binary.BigEndian.PutUint64(header[40:], clusterSize)
+	binary.BigEndian.PutUint64(header[48:], (1+image.l1Clusters)*clusterSize)
+	binary.BigEndian.PutUint32(header[56:], uint32(image.refcountTableClusters))
+	return header
+}
+
+func (image *SparseImage) size() uint64 {
+	return image.totalClusters() * clusterSize
+}
+
+func (image *SparseImage) totalClusters() uint64 {
+	return 1 + image.l1Clusters + image.refcountTableClusters +
+		image.refcountBlocks + image.numL2Tables + image.numDataClusters
+}
+
+func (image *SparseImage) writeTo(writer io.Writer) (int64, error) {
+	var nWritten int64
+	write := func(data []byte) error {
+		nWrite, err := writer.Write(data)
+		nWritten += int64(nWrite)
+		return err
+	}
+	if err := write(image.makeHeader()); err != nil {
+		return nWritten, err
+	}
+	// L1 table.
+	buffer := make([]byte, image.l1Clusters*clusterSize)
+	for index, offset := range image.l2Offsets {
+		if offset > 0 {
+			binary.BigEndian.PutUint64(buffer[index*8:], offset|copiedFlag)
+		}
+	}
+	if err := write(buffer); err != nil {
+		return nWritten, err
+	}
+	// Refcount table.
+	refcountBlocksOffset := (1 + image.l1Clusters +
+		image.refcountTableClusters) * clusterSize
+	buffer = make([]byte, image.refcountTableClusters*clusterSize)
+	for index := uint64(0); index < image.refcountBlocks; index++ {
+		binary.BigEndian.PutUint64(buffer[index*8:],
+			refcountBlocksOffset+index*clusterSize)
+	}
+	if err := write(buffer); err != nil {
+		return nWritten, err
+	}
+	// Refcount blocks: every cluster in the image is used exactly once.
+	buffer = make([]byte, image.refcountBlocks*clusterSize)
+	for index := uint64(0); index < image.totalClusters(); index++ {
+		binary.BigEndian.PutUint16(buffer[index*2:], 1)
+	}
+	if err := write(buffer); err != nil {
+		return nWritten, err
+	}
+	// L2 tables.
+	buffer = make([]byte, clusterSize)
+	dataOffset := image.dataOffset
+	for l2Index, l2Offset := range image.l2Offsets {
+		if l2Offset < 1 {
+			continue
+		}
+		for index := range buffer {
+			buffer[index] = 0
+		}
+		for entry := uint64(0); entry < entriesPerCluster; entry++ {
+			cluster := uint64(l2Index)*entriesPerCluster + entry
+			if cluster >= uint64(len(image.allocated)) {
+				break
+			}
+			if image.allocated[cluster] {
+				binary.BigEndian.PutUint64(buffer[entry*8:],
+					dataOffset|copiedFlag)
+				dataOffset += clusterSize
+			}
+		}
+		if err := write(buffer); err != nil {
+			return nWritten, err
+		}
+	}
+	// Data clusters.
+	for cluster, allocated := range image.allocated {
+		if !allocated {
+			continue
+		}
+		for index := range buffer {
+			buffer[index] = 0
+		}
+		_, err := readCluster(image.reader, buffer, uint64(cluster),
+			image.virtualSize)
+		if err != nil {
+			return nWritten, err
+		}
+		if err := write(buffer); err != nil {
+			return nWritten, err
+		}
+	}
+	return nWritten, nil
+}
@@ -0,0 +1,66 @@
+package qcow2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// readVirtual will decode the virtual data at offset using the L1 and L2
+// tables in the image.
+func readVirtual(t *testing.T, image []byte, offset uint64) []byte {
+	l1Offset := binary.BigEndian.Uint64(image[40:])
+	l1Index := offset / (clusterSize * entriesPerCluster)
+	l2Offset := binary.BigEndian.Uint64(image[l1Offset+l1Index*8:]) &^
+		copiedFlag
+	if l2Offset == 0 {
+		return make([]byte, clusterSize)
+	}
+	l2Index := (offset / clusterSize) % entriesPerCluster
+	dataOffset := binary.BigEndian.Uint64(image[l2Offset+l2Index*8:]) &^
+		copiedFlag
+	if dataOffset == 0 {
+		return make([]byte, clusterSize)
+	}
+	if dataOffset+clusterSize > uint64(len(image)) {
+		t.Fatalf("data offset: %d beyond image size: %d",
+			dataOffset, len(image))
+	}
+	return image[dataOffset : dataOffset+clusterSize]
+}
+
+func TestSparseImage(t *testing.T) {
+	const size = clusterSize*(entriesPerCluster+2) + 512
+	raw := make([]byte, size)
+	copy(raw, []byte("first cluster"))
+	copy(raw[clusterSize*entriesPerCluster:], []byte("second L2 table"))
+	copy(raw[size-512:], []byte("last partial cluster"))
+	image, err := NewSparseImage(bytes.NewReader(raw), size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Header, L1, refcount table and block, 2 L2 tables, 3 data clusters.
+	if expected := uint64(9 * clusterSize); image.Size() != expected {
+		t.Fatalf("size: %d != expected: %d", image.Size(), expected)
+	}
+	buffer := &bytes.Buffer{}
+	if nWritten, err := image.WriteTo(buffer); err != nil {
+		t.Fatal(err)
+	} else if uint64(nWritten) != image.Size() {
+		t.Fatalf("wrote: %d != size: %d", nWritten, image.Size())
+	}
+	header, err := ReadHeader(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Size != size {
+		t.Fatalf("virtual size: %d != %d", header.Size, size)
+	}
+	for offset := uint64(0); offset < size; offset += clusterSize {
+		expected := make([]byte, clusterSize)
+		copy(expected, raw[offset:])
+		if !bytes.Equal(readVirtual(t, buffer.Bytes(), offset), expected) {
+			t.Fatalf("data mismatch at offset: %d", offset)
+		}
+	}
+}
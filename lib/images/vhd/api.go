@@ -0,0 +1,21 @@
+package vhd
+
+import (
+	"io"
+	"time"
+)
+
+const FooterSize = 512
+
+// MakeFixedFooter will make a footer for a fixed VHD image with the specified
+// size of raw data, which must be a multiple of 512 bytes. The footer is
+// appended to the raw data to create the image.
+func MakeFixedFooter(size uint64, timestamp time.Time) ([]byte, error) {
+	return makeFixedFooter(size, timestamp)
+}
+
+// WriteFixed will write a fixed VHD image containing size bytes of raw data
+// from reader to writer.
+func WriteFixed(writer io.Writer, reader io.Reader, size uint64) error {
+	return writeFixed(writer, reader, size)
+}
@@ -0,0 +1,97 @@
+package vhd
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	cookie   = []byte("conectix")
+	vhdEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// computeGeometry implements the CHS calculation from the VHD specification.
+func computeGeometry(size uint64) (uint16, uint8, uint8) {
+	totalSectors := size / 512
+	if totalSectors > 65535*16*255 {
+		totalSectors = 65535 * 16 * 255
+	}
+	var cylinderTimesHeads, heads, sectorsPerTrack uint64
+	if totalSectors >= 65535*16*63 {
+		sectorsPerTrack = 255
+		heads = 16
+		cylinderTimesHeads = totalSectors / sectorsPerTrack
+	} else {
+		sectorsPerTrack = 17
+		cylinderTimesHeads = totalSectors / sectorsPerTrack
+		heads = (cylinderTimesHeads + 1023) / 1024
+		if heads < 4 {
+			heads = 4
+		}
+		if cylinderTimesHeads >= heads*1024 || heads > 16 {
+			sectorsPerTrack = 31
+			heads = 16
+			cylinderTimesHeads = totalSectors / sectorsPerTrack
+		}
+		if cylinderTimesHeads >= heads*1024 {
+			sectorsPerTrack = 63
+			heads = 16
+			cylinderTimesHeads = totalSectors / sectorsPerTrack
+		}
+	}
+	return uint16(cylinderTimesHeads / heads), uint8(heads),
+		uint8(sectorsPerTrack)
+}
+
+func makeFixedFooter(size uint64, timestamp time.Time) ([]byte, error) {
+	if size < 1 || size%512 != 0 {
+		return nil, fmt.Errorf("size: %d is not a multiple of 512", size)
+	}
+	footer := make([]byte, FooterSize)
+	copy(footer, cookie)
+	binary.BigEndian.PutUint32(footer[8:], 2)           // Features.
+	binary.BigEndian.PutUint32(footer[12:], 0x00010000) // Format version.
+	binary.BigEndian.PutUint64(footer[16:], ^uint64(0)) // No dynamic header.
+	binary.BigEndian.PutUint32(footer[24:],
+		uint32(timestamp.Sub(vhdEpoch)/time.Second))
+	copy(footer[28:], "domi") // Creator application.
+	binary.BigEndian.PutUint32(footer[32:], 0x00010000)
+	copy(footer[36:], "Wi2k") // Creator host OS.
+	binary.BigEndian.PutUint64(footer[40:], size)
+	binary.BigEndian.PutUint64(footer[48:], size)
+	cylinders, heads, sectorsPerTrack := computeGeometry(size)
+	binary.BigEndian.PutUint16(footer[56:], cylinders)
+	footer[58] = heads
+	footer[59] = sectorsPerTrack
+	binary.BigEndian.PutUint32(footer[60:], 2) // Disk type: fixed.
+	if _, err := rand.Read(footer[68:84]); err != nil {
+		return nil, err
+	}
+	var checksum uint32
+	for _, value := range footer {
+		checksum += uint32(value)
+	}
+	binary.BigEndian.PutUint32(footer[64:], ^checksum)
+	return footer, nil
+}
+
+func writeFixed(writer io.Writer, reader io.Reader, size uint64) error {
+	footer, err := makeFixedFooter(size, time.Now())
+	if err != nil {
+		return err
+	}
+	if nCopied, err := io.CopyN(writer, reader, int64(size)); err != nil {
+		if err == io.EOF {
+			return errors.New("short read")
+		}
+		return err
+	} else if uint64(nCopied) != size {
+		return errors.New("short copy")
+	}
+	_, err = writer.Write(footer)
+	return err
+}
@@ -0,0 +1,110 @@
+/*
+Package jwt verifies JSON Web Tokens.
+
+Package jwt verifies JSON Web Tokens (JWTs) in the JWS Compact Serialization,
+signed using RSA (RS256, RS384, RS512, PS256, PS384, PS512), ECDSA (ES256,
+ES384, ES512) or Ed25519 (EdDSA) keys. The keys are loaded from JSON Web Key
+Sets (JWKS), either from files or from OpenID Connect issuers. Tokens must
+have an expiration time. Unsigned tokens and tokens signed with shared secrets
+are rejected.
+*/
+package jwt
+
+import (
+	"crypto"
+	"sync"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+// Claims contains the verified claims in a token.
+type Claims struct {
+	Audience  []string
+	Expires   time.Time
+	IssuedAt  time.Time
+	Issuer    string
+	NotBefore time.Time
+	Subject   string
+	Values    map[string]interface{} // All claims, including the above.
+}
+
+type issuerType struct {
+	keySetUrl string
+	logger    log.DebugLogger
+	url       string
+	mutex     sync.Mutex // Protect everything below.
+	keySet    *KeySet
+	lastFetch time.Time
+}
+
+type keyType struct {
+	id        string
+	publicKey crypto.PublicKey
+}
+
+// KeySet is a JSON Web Key Set containing public keys.
+type KeySet struct {
+	keys []keyType
+}
+
+// Verifier verifies tokens against key sets from files and OpenID Connect
+// issuers.
+type Verifier struct {
+	audience     string
+	fileKeySets  []*KeySet
+	issuers      map[string]*issuerType // Key: issuer URL.
+	logger       log.DebugLogger
+	timeProvider func() time.Time
+}
+
+type VerifierParams struct {
+	Audience    string   // Tokens must include this. Required for Issuers.
+	Issuers     []string // OpenID Connect issuer URLs.
+	KeySetFiles []string // Files containing JSON Web Key Sets (any issuer).
+	Logger      log.DebugLogger
+}
+
+// LoadKeySet loads a JSON Web Key Set from a file.
+func LoadKeySet(filename string) (*KeySet, error) {
+	return loadKeySet(filename)
+}
+
+// ParseKeySet parses a JSON Web Key Set. Keys of unsupported types are
+// ignored.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	return parseKeySet(data)
+}
+
+// Verify verifies the signature and the validity period of a token using the
+// keys in the key set. The issuer and audience are not checked.
+func (ks *KeySet) Verify(token string) (*Claims, error) {
+	return ks.verify(token, time.Now())
+}
+
+// NewVerifier creates a Verifier. An audience must be specified if there are
+// issuers. The key sets are loaded from the files and the issuers are queried
+// for their key sets. Failure to query an issuer is
+// logged and the query is retried when a token from the issuer is verified.
+func NewVerifier(params VerifierParams) (*Verifier, error) {
+	return newVerifier(params)
+}
+
+// Verify verifies a token. Tokens with an issuer listed in
+// VerifierParams.Issuers are verified with the keys for that issuer, other
+// tokens are verified with the keys loaded from files.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	return v.verify(token)
+}
+
+// GetString returns the value of a string claim. An empty string is returned
+// if the claim is missing or is not a string.
+func (claims *Claims) GetString(name string) string {
+	return claims.getString(name)
+}
+
+// GetStrings returns the values of a claim which is a string or a list of
+// strings. Other values in a list are ignored.
+func (claims *Claims) GetStrings(name string) []string {
+	return claims.getStrings(name)
+}
@@ -0,0 +1,138 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+type jsonWebKey struct {
+	Crv string `json:"crv"`
+	E   string `json:"e"`
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	Use string `json:"use"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1 {
+		return nil, errors.New("empty integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func loadKeySet(filename string) (*KeySet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	keySet, err := parseKeySet(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing: %s: %s", filename, err)
+	}
+	return keySet, nil
+}
+
+func parseKeySet(data []byte) (*KeySet, error) {
+	var jwks jsonWebKeySet
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+	keySet := &KeySet{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.parse()
+		if err != nil {
+			return nil, fmt.Errorf("key: \"%s\": %s", jwk.Kid, err)
+		}
+		if key != nil {
+			keySet.keys = append(keySet.keys, *key)
+		}
+	}
+	if len(keySet.keys) < 1 {
+		return nil, errors.New("no usable keys")
+	}
+	return keySet, nil
+}
+
+// parse returns the public key, or nil if the key type is not supported.
+func (jwk jsonWebKey) parse() (*keyType, error) {
+	key := &keyType{id: jwk.Kid}
+	switch jwk.Kty {
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		key.publicKey = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("bad Ed25519 key size")
+		}
+		key.publicKey = ed25519.PublicKey(x)
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("bad RSA exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, errors.New("RSA key too small")
+		}
+		key.publicKey = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	default:
+		return nil, nil
+	}
+	return key, nil
+}
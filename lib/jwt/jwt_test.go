@@ -0,0 +1,280 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testKey struct {
+	algorithm  string
+	id         string
+	privateKey crypto.Signer
+}
+
+func encodeBigInt(value *big.Int, size int) string {
+	data := value.Bytes()
+	if len(data) < size {
+		data = append(make([]byte, size-len(data)), data...)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeSegment(t *testing.T, value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func makeKeySetData(t *testing.T, keys ...testKey) []byte {
+	var jwks jsonWebKeySet
+	for _, key := range keys {
+		jwk := jsonWebKey{Kid: key.id, Use: "sig"}
+		switch publicKey := key.privateKey.Public().(type) {
+		case *ecdsa.PublicKey:
+			size := (publicKey.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = publicKey.Curve.Params().Name
+			jwk.X = encodeBigInt(publicKey.X, size)
+			jwk.Y = encodeBigInt(publicKey.Y, size)
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encodeBigInt(publicKey.N, 0)
+			jwk.E = encodeBigInt(big.NewInt(int64(publicKey.E)), 0)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func makeToken(t *testing.T, key testKey,
+	claims map[string]interface{}) string {
+	signingInput := encodeSegment(t,
+		map[string]string{"alg": key.algorithm, "kid": key.id}) +
+		"." + encodeSegment(t, claims)
+	var signature []byte
+	var err error
+	switch privateKey := key.privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		// The hash is selected by the algorithm and the signature size by the
+		// curve, so that mismatched tokens may be made.
+		hash := crypto.SHA256
+		if key.algorithm == "ES384" {
+			hash = crypto.SHA384
+		}
+		hasher := hash.New()
+		hasher.Write([]byte(signingInput))
+		r, s, e := ecdsa.Sign(rand.Reader, privateKey, hasher.Sum(nil))
+		if e != nil {
+			t.Fatal(e)
+		}
+		size := (privateKey.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)),
+			s.FillBytes(make([]byte, size))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(privateKey, []byte(signingInput))
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, privateKey,
+			crypto.SHA256, digest[:])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." +
+		base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestKeySetVerify(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []testKey{
+		{"ES256", "ec", ecdsaKey},
+		{"EdDSA", "ed", ed25519Key},
+		{"RS256", "rsa", rsaKey},
+	}
+	keySet, err := ParseKeySet(makeKeySetData(t, keys...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Hour).Unix()
+	for _, key := range keys {
+		token := makeToken(t, key, map[string]interface{}{
+			"exp":    expires,
+			"groups": []string{"group0", "group1"},
+			"sub":    "user0",
+		})
+		claims, err := keySet.Verify(token)
+		if err != nil {
+			t.Fatalf("%s: %s", key.algorithm, err)
+		}
+		if claims.Subject != "user0" {
+			t.Errorf("%s: subject: %s != user0", key.algorithm, claims.Subject)
+		}
+		if groups := claims.GetStrings("groups"); len(groups) != 2 {
+			t.Errorf("%s: groups: %v", key.algorithm, groups)
+		}
+		// Tamper with the signature.
+		tampered := []byte(token)
+		tampered[len(tampered)-2] ^= 1
+		if _, err := keySet.Verify(string(tampered)); err == nil {
+			t.Errorf("%s: tampered token verified", key.algorithm)
+		}
+	}
+}
+
+func TestKeySetVerifyRejects(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, unknownKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := testKey{"ES256", "ec", ecdsaKey}
+	keySet, err := ParseKeySet(makeKeySetData(t, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Hour).Unix()
+	unsigned := encodeSegment(t, map[string]string{"alg": "none"}) + "." +
+		encodeSegment(t, map[string]interface{}{"exp": expires}) + "."
+	tests := map[string]string{
+		"expired": makeToken(t, key, map[string]interface{}{
+			"exp": time.Now().Add(-time.Hour).Unix(),
+		}),
+		"no expiration": makeToken(t, key, map[string]interface{}{}),
+		"not yet valid": makeToken(t, key, map[string]interface{}{
+			"exp": expires,
+			"nbf": time.Now().Add(time.Hour).Unix(),
+		}),
+		"unknown key": makeToken(t, testKey{"EdDSA", "ed", unknownKey},
+			map[string]interface{}{"exp": expires}),
+		"unsigned": unsigned,
+	}
+	for name, token := range tests {
+		if _, err := keySet.Verify(token); err == nil {
+			t.Errorf("%s: token verified", name)
+		}
+	}
+}
+
+func TestVerifierAudience(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := testKey{"EdDSA", "ed", ed25519Key}
+	filename := filepath.Join(t.TempDir(), "jwks.json")
+	err = os.WriteFile(filename, makeKeySetData(t, key), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifier(VerifierParams{
+		Audience:    "dominator",
+		KeySetFiles: []string{filename},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Hour).Unix()
+	token := makeToken(t, key, map[string]interface{}{
+		"aud": "dominator",
+		"exp": expires,
+	})
+	if _, err := verifier.Verify(token); err != nil {
+		t.Fatal(err)
+	}
+	token = makeToken(t, key, map[string]interface{}{
+		"aud": []string{"other"},
+		"exp": expires,
+	})
+	if _, err := verifier.Verify(token); err == nil {
+		t.Fatal("token for other audience verified")
+	}
+	token = makeToken(t, key, map[string]interface{}{"exp": expires})
+	if _, err := verifier.Verify(token); err == nil {
+		t.Fatal("token without audience verified")
+	}
+	// Without a configured audience, tokens for any audience are rejected.
+	verifier, err = NewVerifier(VerifierParams{KeySetFiles: []string{filename}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(token); err != nil {
+		t.Fatal(err)
+	}
+	token = makeToken(t, key, map[string]interface{}{
+		"aud": "dominator",
+		"exp": expires,
+	})
+	if _, err := verifier.Verify(token); err == nil {
+		t.Fatal("token with audience verified without configured audience")
+	}
+}
+
+func TestNewVerifierRequiresAudience(t *testing.T) {
+	_, err := NewVerifier(VerifierParams{
+		Issuers: []string{"https://issuer.example.com"},
+	})
+	if err == nil {
+		t.Fatal("verifier for issuer created without audience")
+	}
+}
+
+func TestKeySetVerifyEcdsaCurve(t *testing.T) {
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet, err := ParseKeySet(makeKeySetData(t,
+		testKey{"ES384", "ec384", p384Key}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Hour).Unix()
+	token := makeToken(t, testKey{"ES384", "ec384", p384Key},
+		map[string]interface{}{"exp": expires})
+	if _, err := keySet.Verify(token); err != nil {
+		t.Fatal(err)
+	}
+	// A P-384 key must not verify a token using the P-256 algorithm.
+	token = makeToken(t, testKey{"ES256", "ec384", p384Key},
+		map[string]interface{}{"exp": expires})
+	if _, err := keySet.Verify(token); err == nil {
+		t.Fatal("ES256 token verified with P-384 key")
+	}
+}
@@ -0,0 +1,188 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/nulllogger"
+)
+
+const (
+	keySetMaximumAge      = time.Hour
+	keySetMinimumInterval = time.Minute // Between fetches for unknown keys.
+	maximumResponseSize   = 1 << 20
+)
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+func httpGetJson(url string, value interface{}) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maximumResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if value != nil {
+		if err := json.Unmarshal(data, value); err != nil {
+			return nil, fmt.Errorf("%s: %s", url, err)
+		}
+	}
+	return data, nil
+}
+
+func newVerifier(params VerifierParams) (*Verifier, error) {
+	if params.Logger == nil {
+		params.Logger = nulllogger.New()
+	}
+	if len(params.Issuers) < 1 && len(params.KeySetFiles) < 1 {
+		return nil, errors.New("no issuers or key set files specified")
+	}
+	if len(params.Issuers) > 0 && params.Audience == "" {
+		return nil, errors.New("no audience specified for issuers")
+	}
+	v := &Verifier{
+		audience:     params.Audience,
+		issuers:      make(map[string]*issuerType, len(params.Issuers)),
+		logger:       params.Logger,
+		timeProvider: time.Now,
+	}
+	for _, filename := range params.KeySetFiles {
+		keySet, err := loadKeySet(filename)
+		if err != nil {
+			return nil, err
+		}
+		v.fileKeySets = append(v.fileKeySets, keySet)
+	}
+	for _, issuerUrl := range params.Issuers {
+		issuer := &issuerType{
+			logger: params.Logger,
+			url:    strings.TrimSuffix(issuerUrl, "/"),
+		}
+		v.issuers[issuer.url] = issuer
+		if _, err := issuer.getKeySet(""); err != nil {
+			params.Logger.Printf("error fetching keys for: %s: %s\n",
+				issuer.url, err)
+		}
+	}
+	return v, nil
+}
+
+// fetchKeySet fetches the key set, discovering the key set URL if needed.
+// The lock must be held.
+func (issuer *issuerType) fetchKeySet() error {
+	issuer.lastFetch = time.Now()
+	if issuer.keySetUrl == "" {
+		var configuration struct {
+			Issuer  string `json:"issuer"`
+			JwksUri string `json:"jwks_uri"`
+		}
+		_, err := httpGetJson(
+			issuer.url+"/.well-known/openid-configuration", &configuration)
+		if err != nil {
+			return err
+		}
+		if configuration.Issuer != issuer.url {
+			return fmt.Errorf("issuer mismatch: %s", configuration.Issuer)
+		}
+		if !strings.HasPrefix(configuration.JwksUri, "https://") {
+			return fmt.Errorf("insecure jwks_uri: %s", configuration.JwksUri)
+		}
+		issuer.keySetUrl = configuration.JwksUri
+	}
+	data, err := httpGetJson(issuer.keySetUrl, nil)
+	if err != nil {
+		return err
+	}
+	keySet, err := parseKeySet(data)
+	if err != nil {
+		return fmt.Errorf("%s: %s", issuer.keySetUrl, err)
+	}
+	issuer.keySet = keySet
+	issuer.logger.Debugf(0, "fetched %d keys for: %s\n",
+		len(keySet.keys), issuer.url)
+	return nil
+}
+
+// getKeySet returns the key set for the issuer, fetching it if it is too old
+// or if it does not contain the specified key.
+func (issuer *issuerType) getKeySet(keyId string) (*KeySet, error) {
+	issuer.mutex.Lock()
+	defer issuer.mutex.Unlock()
+	refetch := issuer.keySet == nil ||
+		time.Since(issuer.lastFetch) > keySetMaximumAge ||
+		(keyId != "" && !issuer.keySet.hasKey(keyId))
+	if refetch && time.Since(issuer.lastFetch) >= keySetMinimumInterval {
+		if err := issuer.fetchKeySet(); err != nil {
+			if issuer.keySet == nil {
+				return nil, err
+			}
+			issuer.logger.Printf("error refreshing keys for: %s: %s\n",
+				issuer.url, err)
+		}
+	}
+	if issuer.keySet == nil {
+		return nil, fmt.Errorf("no keys for: %s", issuer.url)
+	}
+	return issuer.keySet, nil
+}
+
+// checkAudience returns an error if the token does not include the audience.
+// If no audience is configured, tokens which specify an audience are rejected.
+func (v *Verifier) checkAudience(claims *Claims) error {
+	if len(claims.Audience) < 1 {
+		if v.audience == "" {
+			return nil
+		}
+		return errors.New("token has no audience")
+	}
+	for _, audience := range claims.Audience {
+		if audience != "" && audience == v.audience {
+			return nil
+		}
+	}
+	return errors.New("token not issued for this audience")
+}
+
+func (v *Verifier) verify(token string) (*Claims, error) {
+	parsed, err := parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if issuer, ok := v.issuers[parsed.claims.Issuer]; ok {
+		keySet, err := issuer.getKeySet(parsed.header.KeyId)
+		if err != nil {
+			return nil, err
+		}
+		if err := keySet.verifyParsed(parsed); err != nil {
+			return nil, err
+		}
+	} else {
+		err = errors.New("no keys for issuer: " + parsed.claims.Issuer)
+		for _, keySet := range v.fileKeySets {
+			if err = keySet.verifyParsed(parsed); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := parsed.claims.checkTimes(v.timeProvider()); err != nil {
+		return nil, err
+	}
+	if err := v.checkAudience(parsed.claims); err != nil {
+		return nil, err
+	}
+	return parsed.claims, nil
+}
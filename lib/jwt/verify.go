@@ -0,0 +1,259 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Permitted clock skew between the token issuer and the verifier.
+const clockSkew = time.Minute
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyId     string `json:"kid"`
+}
+
+type parsedToken struct {
+	claims       *Claims
+	header       tokenHeader
+	signature    []byte
+	signingInput string
+}
+
+func getNumericDate(values map[string]interface{},
+	name string) (time.Time, error) {
+	value, ok := values[name]
+	if !ok {
+		return time.Time{}, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, fmt.Errorf("claim: %s is not a number", name)
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("claim: %s: %s", name, err)
+	}
+	return time.Unix(int64(seconds), 0), nil
+}
+
+func parseClaims(data []byte) (*Claims, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	claims := &Claims{}
+	if err := decoder.Decode(&claims.Values); err != nil {
+		return nil, err
+	}
+	if claims.Values == nil {
+		return nil, errors.New("no claims")
+	}
+	claims.Audience = claims.getStrings("aud")
+	claims.Issuer = claims.getString("iss")
+	claims.Subject = claims.getString("sub")
+	var err error
+	if claims.Expires, err = getNumericDate(claims.Values, "exp"); err != nil {
+		return nil, err
+	}
+	claims.IssuedAt, err = getNumericDate(claims.Values, "iat")
+	if err != nil {
+		return nil, err
+	}
+	claims.NotBefore, err = getNumericDate(claims.Values, "nbf")
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// parseToken parses a token without verifying the signature.
+func parseToken(token string) (*parsedToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	headerData, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding header: %s", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding payload: %s", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding signature: %s", err)
+	}
+	parsed := &parsedToken{
+		signature:    signature,
+		signingInput: parts[0] + "." + parts[1],
+	}
+	if err := json.Unmarshal(headerData, &parsed.header); err != nil {
+		return nil, fmt.Errorf("error decoding header: %s", err)
+	}
+	if parsed.claims, err = parseClaims(payload); err != nil {
+		return nil, fmt.Errorf("error decoding claims: %s", err)
+	}
+	return parsed, nil
+}
+
+func verifyEcdsa(publicKey *ecdsa.PublicKey, hash crypto.Hash,
+	signingInput string, signature []byte) bool {
+	keySize := (publicKey.Curve.Params().BitSize + 7) / 8
+	if len(signature) != 2*keySize {
+		return false
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(signingInput))
+	r := new(big.Int).SetBytes(signature[:keySize])
+	s := new(big.Int).SetBytes(signature[keySize:])
+	return ecdsa.Verify(publicKey, hasher.Sum(nil), r, s)
+}
+
+func verifyRsa(publicKey *rsa.PublicKey, hash crypto.Hash, pss bool,
+	signingInput string, signature []byte) bool {
+	hasher := hash.New()
+	hasher.Write([]byte(signingInput))
+	if pss {
+		return rsa.VerifyPSS(publicKey, hash, hasher.Sum(nil), signature,
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	}
+	return rsa.VerifyPKCS1v15(publicKey, hash, hasher.Sum(nil),
+		signature) == nil
+}
+
+func (claims *Claims) checkTimes(now time.Time) error {
+	if claims.Expires.IsZero() {
+		return errors.New("token has no expiration time")
+	}
+	if now.After(claims.Expires.Add(clockSkew)) {
+		return errors.New("token expired")
+	}
+	if !claims.NotBefore.IsZero() && now.Add(clockSkew).Before(
+		claims.NotBefore) {
+		return errors.New("token not yet valid")
+	}
+	return nil
+}
+
+func (claims *Claims) getString(name string) string {
+	if value, ok := claims.Values[name].(string); ok {
+		return value
+	}
+	return ""
+}
+
+func (claims *Claims) getStrings(name string) []string {
+	switch value := claims.Values[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, entry := range value {
+			if str, ok := entry.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func (key keyType) verify(parsed *parsedToken) bool {
+	switch publicKey := key.publicKey.(type) {
+	case *ecdsa.PublicKey:
+		// The algorithm determines the curve as well as the hash.
+		var curveName string
+		var hash crypto.Hash
+		switch parsed.header.Algorithm {
+		case "ES256":
+			curveName, hash = "P-256", crypto.SHA256
+		case "ES384":
+			curveName, hash = "P-384", crypto.SHA384
+		case "ES512":
+			curveName, hash = "P-521", crypto.SHA512
+		default:
+			return false
+		}
+		if publicKey.Curve.Params().Name != curveName {
+			return false
+		}
+		return verifyEcdsa(publicKey, hash, parsed.signingInput,
+			parsed.signature)
+	case ed25519.PublicKey:
+		if parsed.header.Algorithm != "EdDSA" {
+			return false
+		}
+		return ed25519.Verify(publicKey, []byte(parsed.signingInput),
+			parsed.signature)
+	case *rsa.PublicKey:
+		var hash crypto.Hash
+		var pss bool
+		switch parsed.header.Algorithm {
+		case "RS256":
+			hash = crypto.SHA256
+		case "RS384":
+			hash = crypto.SHA384
+		case "RS512":
+			hash = crypto.SHA512
+		case "PS256":
+			hash, pss = crypto.SHA256, true
+		case "PS384":
+			hash, pss = crypto.SHA384, true
+		case "PS512":
+			hash, pss = crypto.SHA512, true
+		default:
+			return false
+		}
+		return verifyRsa(publicKey, hash, pss, parsed.signingInput,
+			parsed.signature)
+	}
+	return false
+}
+
+// hasKey returns true if the key set contains a key with the specified ID.
+func (ks *KeySet) hasKey(keyId string) bool {
+	for _, key := range ks.keys {
+		if key.id == keyId {
+			return true
+		}
+	}
+	return false
+}
+
+func (ks *KeySet) verify(token string, now time.Time) (*Claims, error) {
+	parsed, err := parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.verifyParsed(parsed); err != nil {
+		return nil, err
+	}
+	if err := parsed.claims.checkTimes(now); err != nil {
+		return nil, err
+	}
+	return parsed.claims, nil
+}
+
+func (ks *KeySet) verifyParsed(parsed *parsedToken) error {
+	for _, key := range ks.keys {
+		if parsed.header.KeyId != "" && key.id != parsed.header.KeyId {
+			continue
+		}
+		if key.verify(parsed) {
+			return nil
+		}
+	}
+	return errors.New("invalid token signature")
+}
@@ -1,5 +1,19 @@
 package log
 
+// Field is a key/value pair attached to a structured log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// FieldLogger is implemented by loggers which record the fields of structured
+// log entries natively, rather than appending them to the message text.
+type FieldLogger interface {
+	// LogFields logs msg with the specified fields. A level of -1 indicates a
+	// normal (non-debug) log, otherwise it is the debug level.
+	LogFields(level int16, msg string, fields []Field)
+}
+
 type Logger interface {
 	Fatal(v ...interface{})
 	Fatalf(format string, v ...interface{})
@@ -32,3 +46,17 @@ type FullDebugLogger interface {
 	DebugLogLevelGetter
 	DebugLogLevelSetter
 }
+
+// FormatFields returns the fields in the key=value form appended to the text
+// of log messages, with a leading space. Values containing spaces or quotes
+// are quoted.
+func FormatFields(fields []Field) string {
+	return formatFields(fields)
+}
+
+// MakeFields converts a list of alternating keys and values into a list of
+// fields. A key which is not a string is converted to a string and a missing
+// final value is recorded as the key "!BADKEY".
+func MakeFields(keysAndValues ...interface{}) []Field {
+	return makeFields(keysAndValues)
+}
@@ -0,0 +1,110 @@
+/*
+Package fieldlogger provides structured loggers which attach fields to log
+entries.
+
+A Logger attaches a set of fields (such as a hostname, image name or request
+ID) to each log entry. If the underlying logger implements the
+log.FieldLogger interface (such as serverlogger.Logger) the fields are passed
+to it natively, otherwise they are appended to the message in key=value form.
+Child loggers with additional fields may be created with the With method.
+*/
+package fieldlogger
+
+import (
+	"fmt"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/log/debuglogger"
+)
+
+type Logger struct {
+	fields []log.Field
+	logger log.DebugLogger
+}
+
+// New returns a Logger which attaches the fields given by keysAndValues
+// (alternating keys and values) to each entry logged to logger.
+func New(logger log.Logger, keysAndValues ...interface{}) *Logger {
+	if parent, ok := logger.(*Logger); ok {
+		return parent.with(log.MakeFields(keysAndValues...))
+	}
+	return &Logger{
+		fields: log.MakeFields(keysAndValues...),
+		logger: debuglogger.Upgrade(logger),
+	}
+}
+
+func (l *Logger) Debug(level uint8, v ...interface{}) {
+	l.logFields(int16(level), fmt.Sprint(v...), nil)
+}
+
+func (l *Logger) Debugf(level uint8, format string, v ...interface{}) {
+	l.logFields(int16(level), fmt.Sprintf(format, v...), nil)
+}
+
+func (l *Logger) Debugln(level uint8, v ...interface{}) {
+	l.logFields(int16(level), fmt.Sprintln(v...), nil)
+}
+
+// DebugWithFields logs msg at the specified debug level with the fields bound
+// to the Logger and the fields given by keysAndValues (alternating keys and
+// values).
+func (l *Logger) DebugWithFields(level uint8, msg string,
+	keysAndValues ...interface{}) {
+	l.logFields(int16(level), msg, log.MakeFields(keysAndValues...))
+}
+
+func (l *Logger) Fatal(v ...interface{}) {
+	l.logger.Fatal(l.format(fmt.Sprint(v...), nil))
+}
+
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.logger.Fatal(l.format(fmt.Sprintf(format, v...), nil))
+}
+
+func (l *Logger) Fatalln(v ...interface{}) {
+	l.logger.Fatal(l.format(fmt.Sprintln(v...), nil))
+}
+
+// LogFields implements the log.FieldLogger interface, adding the fields
+// bound to the Logger before the specified fields.
+func (l *Logger) LogFields(level int16, msg string, fields []log.Field) {
+	l.logFields(level, msg, fields)
+}
+
+func (l *Logger) Panic(v ...interface{}) {
+	l.logger.Panic(l.format(fmt.Sprint(v...), nil))
+}
+
+func (l *Logger) Panicf(format string, v ...interface{}) {
+	l.logger.Panic(l.format(fmt.Sprintf(format, v...), nil))
+}
+
+func (l *Logger) Panicln(v ...interface{}) {
+	l.logger.Panic(l.format(fmt.Sprintln(v...), nil))
+}
+
+func (l *Logger) Print(v ...interface{}) {
+	l.logFields(-1, fmt.Sprint(v...), nil)
+}
+
+func (l *Logger) Printf(format string, v ...interface{}) {
+	l.logFields(-1, fmt.Sprintf(format, v...), nil)
+}
+
+func (l *Logger) Println(v ...interface{}) {
+	l.logFields(-1, fmt.Sprintln(v...), nil)
+}
+
+// PrintWithFields logs msg with the fields bound to the Logger and the fields
+// given by keysAndValues (alternating keys and values).
+func (l *Logger) PrintWithFields(msg string, keysAndValues ...interface{}) {
+	l.logFields(-1, msg, log.MakeFields(keysAndValues...))
+}
+
+// With returns a child Logger which attaches the fields given by
+// keysAndValues (alternating keys and values) in addition to the fields bound
+// to l.
+func (l *Logger) With(keysAndValues ...interface{}) *Logger {
+	return l.with(log.MakeFields(keysAndValues...))
+}
@@ -0,0 +1,35 @@
+package fieldlogger
+
+import (
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+// format returns msg with the bound and extra fields appended.
+func (l *Logger) format(msg string, fields []log.Field) string {
+	msg = strings.TrimSuffix(msg, "\n")
+	return msg + log.FormatFields(l.fields) + log.FormatFields(fields)
+}
+
+func (l *Logger) logFields(level int16, msg string, fields []log.Field) {
+	if fieldLogger, ok := l.logger.(log.FieldLogger); ok {
+		allFields := make([]log.Field, 0, len(l.fields)+len(fields))
+		allFields = append(allFields, l.fields...)
+		allFields = append(allFields, fields...)
+		fieldLogger.LogFields(level, strings.TrimSuffix(msg, "\n"), allFields)
+		return
+	}
+	if level < 0 {
+		l.logger.Print(l.format(msg, fields))
+	} else {
+		l.logger.Debug(uint8(level), l.format(msg, fields))
+	}
+}
+
+func (l *Logger) with(fields []log.Field) *Logger {
+	allFields := make([]log.Field, 0, len(l.fields)+len(fields))
+	allFields = append(allFields, l.fields...)
+	allFields = append(allFields, fields...)
+	return &Logger{fields: allFields, logger: l.logger}
+}
@@ -0,0 +1,125 @@
+package fieldlogger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+	"github.com/Cloud-Foundations/Dominator/lib/log/nulllogger"
+)
+
+type logEntry struct {
+	fields []log.Field
+	level  int16
+	msg    string
+}
+
+// testFieldLogger records the entries logged with fields.
+type testFieldLogger struct {
+	log.DebugLogger
+	entries []logEntry
+}
+
+// testTextLogger records the entries logged as text.
+type testTextLogger struct {
+	lines []string
+}
+
+func (l *testFieldLogger) LogFields(level int16, msg string,
+	fields []log.Field) {
+	l.entries = append(l.entries,
+		logEntry{fields: fields, level: level, msg: msg})
+}
+
+func (l *testTextLogger) Fatal(v ...interface{})                 {}
+func (l *testTextLogger) Fatalf(format string, v ...interface{}) {}
+func (l *testTextLogger) Fatalln(v ...interface{})               {}
+func (l *testTextLogger) Panic(v ...interface{})                 {}
+func (l *testTextLogger) Panicf(format string, v ...interface{}) {}
+func (l *testTextLogger) Panicln(v ...interface{})               {}
+
+func (l *testTextLogger) Print(v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprint(v...))
+}
+
+func (l *testTextLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *testTextLogger) Println(v ...interface{}) {
+	l.lines = append(l.lines, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+func checkFields(t *testing.T, fields []log.Field, expected ...string) {
+	keys := make([]string, 0, len(fields))
+	for _, field := range fields {
+		keys = append(keys, fmt.Sprintf("%s=%v", field.Key, field.Value))
+	}
+	if got := strings.Join(keys, " "); got != strings.Join(expected, " ") {
+		t.Errorf("expected fields: %v, got: %s", expected, got)
+	}
+}
+
+func TestFieldInheritance(t *testing.T) {
+	base := &testFieldLogger{DebugLogger: nulllogger.New()}
+	parent := New(base, "host", "host0")
+	child := parent.With("image", "image0")
+	grandchild := New(child, "request", 42) // Inherits from child.
+	parent.Print("parent")
+	child.PrintWithFields("child", "extra", true)
+	grandchild.Debugf(2, "grandchild %d", 1)
+	child.Println("child again")
+	if len(base.entries) != 4 {
+		t.Fatalf("expected 4 entries, got: %d", len(base.entries))
+	}
+	checkFields(t, base.entries[0].fields, "host=host0")
+	checkFields(t, base.entries[1].fields, "host=host0", "image=image0",
+		"extra=true")
+	checkFields(t, base.entries[2].fields, "host=host0", "image=image0",
+		"request=42")
+	// Creating children and logging extra fields does not change the parent.
+	checkFields(t, base.entries[3].fields, "host=host0", "image=image0")
+	if entry := base.entries[2]; entry.level != 2 ||
+		entry.msg != "grandchild 1" {
+		t.Errorf("unexpected entry: level: %d, msg: %s",
+			entry.level, entry.msg)
+	}
+	if entry := base.entries[3]; entry.level != -1 ||
+		entry.msg != "child again" {
+		t.Errorf("unexpected entry: level: %d, msg: \"%s\"",
+			entry.level, entry.msg)
+	}
+}
+
+func TestFieldInheritanceSiblings(t *testing.T) {
+	base := &testFieldLogger{DebugLogger: nulllogger.New()}
+	parent := New(base, "host", "host0")
+	// Fill the capacity of the parent fields so that appends could alias.
+	parent = parent.With("a", 1)
+	left := parent.With("side", "left")
+	right := parent.With("side", "right")
+	left.Print("left")
+	right.Print("right")
+	checkFields(t, base.entries[0].fields, "host=host0", "a=1", "side=left")
+	checkFields(t, base.entries[1].fields, "host=host0", "a=1", "side=right")
+}
+
+func TestFieldsAsText(t *testing.T) {
+	base := &testTextLogger{}
+	child := New(base, "host", "host0").With("path", "/a b")
+	child.Println("message")
+	child.PrintWithFields("extra", "count", 3)
+	expected := []string{
+		`message host=host0 path="/a b"`,
+		`extra host=host0 path="/a b" count=3`,
+	}
+	if len(base.lines) != len(expected) {
+		t.Fatalf("expected %d lines, got: %v", len(expected), base.lines)
+	}
+	for index, line := range base.lines {
+		if !strings.HasSuffix(line, expected[index]) {
+			t.Errorf("expected: %s, got: %s", expected[index], line)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func formatFields(fields []Field) string {
+	if len(fields) < 1 {
+		return ""
+	}
+	var builder strings.Builder
+	for _, field := range fields {
+		builder.WriteByte(' ')
+		builder.WriteString(field.Key)
+		builder.WriteByte('=')
+		value := fmt.Sprint(field.Value)
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		builder.WriteString(value)
+	}
+	return builder.String()
+}
+
+func makeFields(keysAndValues []interface{}) []Field {
+	fields := make([]Field, 0, (len(keysAndValues)+1)/2)
+	for index := 0; index < len(keysAndValues); index += 2 {
+		if index+1 >= len(keysAndValues) {
+			fields = append(fields,
+				Field{Key: "!BADKEY", Value: keysAndValues[index]})
+			break
+		}
+		key, ok := keysAndValues[index].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[index])
+		}
+		fields = append(fields, Field{Key: key, Value: keysAndValues[index+1]})
+	}
+	return fields
+}
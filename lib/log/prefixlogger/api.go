@@ -63,3 +63,15 @@ func (l *Logger) Printf(format string, v ...interface{}) {
 func (l *Logger) Println(v ...interface{}) {
 	l.logger.Print(l.prefix + fmt.Sprintln(v...))
 }
+
+// LogFields implements the log.FieldLogger interface. If the underlying logger
+// does not implement log.FieldLogger, the fields are appended to the message.
+func (l *Logger) LogFields(level int16, msg string, fields []log.Field) {
+	if fieldLogger, ok := l.logger.(log.FieldLogger); ok {
+		fieldLogger.LogFields(level, l.prefix+msg, fields)
+	} else if level < 0 {
+		l.logger.Print(l.prefix + msg + log.FormatFields(fields))
+	} else {
+		l.logger.Debug(uint8(level), l.prefix+msg+log.FormatFields(fields))
+	}
+}
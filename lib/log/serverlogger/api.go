@@ -8,12 +8,16 @@ import (
 	"regexp"
 	"sync"
 
+	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
 	liblog "github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/logbuf"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 )
 
 var (
+	auditLogging = flag.Bool("auditLogging", true,
+		"If true, record calls to methods which are not read-only in the audit subdirectory of the log directory")
+	auditLogQuota        = flagutil.Size(100 << 20)
 	initialLogDebugLevel = flag.Int("initialLogDebugLevel", -1,
 		"initial debug log level")
 	logAtStartup = flag.Bool("logAtStartup", true,
@@ -22,9 +26,15 @@ var (
 		"if true, datestamps will have subsecond resolution")
 
 	// Interface check.
+	_ liblog.FieldLogger     = (*Logger)(nil)
 	_ liblog.FullDebugLogger = (*Logger)(nil)
 )
 
+func init() {
+	flag.Var(&auditLogQuota, "auditLogQuota",
+		"Audit log quota. If exceeded, old audit records are deleted")
+}
+
 type Logger struct {
 	accessChecker  func(method string, authInfo *srpc.AuthInformation) bool
 	circularBuffer *logbuf.LogBuffer
@@ -130,6 +140,13 @@ func (l *Logger) Flush() error {
 	return l.circularBuffer.Flush()
 }
 
+// LogFields implements the log.FieldLogger interface. The fields are
+// appended to the message, or recorded separately if the log buffer writes
+// JSON lines. A level of -1 indicates a normal (non-debug) log.
+func (l *Logger) LogFields(level int16, msg string, fields []liblog.Field) {
+	l.logFields(level, msg, fields)
+}
+
 // Panic is equivalent to Print() followed by a call to panic().
 func (l *Logger) Panic(v ...interface{}) {
 	l.panics(fmt.Sprint(v...))
@@ -7,12 +7,15 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Cloud-Foundations/Dominator/lib/auditlog"
+	liblog "github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/logbuf"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 	"github.com/Cloud-Foundations/Dominator/lib/srpc/serverutil"
@@ -26,20 +29,30 @@ type loggerMapT struct {
 	loggerMap map[string]*Logger
 }
 
-var loggerMap *loggerMapT = &loggerMapT{
-	loggerMap: make(map[string]*Logger),
-	PerUserMethodLimiter: serverutil.NewPerUserMethodLimiter(
-		map[string]uint{
-			"Debug":         1,
-			"GetStackTrace": 1,
-			"Print":         1,
-			"SetDebugLevel": 1,
-			"Watch":         1,
-		}),
-}
+var (
+	auditLog *auditlog.AuditLog
+
+	loggerMap *loggerMapT = &loggerMapT{
+		loggerMap: make(map[string]*Logger),
+		PerUserMethodLimiter: serverutil.NewPerUserMethodLimiter(
+			map[string]uint{
+				"Debug":           1,
+				"GetAuditRecords": 1,
+				"GetStackTrace":   1,
+				"Print":           1,
+				"SetDebugLevel":   1,
+				"Watch":           1,
+			}),
+	}
+)
 
 func init() {
-	srpc.RegisterName("Logger", loggerMap)
+	srpc.RegisterNameWithOptions("Logger", loggerMap, srpc.ReceiverOptions{
+		ReadOnlyMethods: []string{
+			"GetAuditRecords",
+			"GetStackTrace",
+			"Watch",
+		}})
 }
 
 func getCallerName(depth int) string {
@@ -78,6 +91,9 @@ func newLogger(name string, options logbuf.Options, flags int) *Logger {
 	if circularBuffer.CheckNeverLogged() {
 		logger.Println("New log directory")
 	}
+	if name == "" && *auditLogging && options.Directory != "" {
+		logger.setupAuditLog(filepath.Join(options.Directory, "audit"))
+	}
 	if *logAtStartup {
 		logger.Printf("Startup: %s", version.Get())
 	}
@@ -99,37 +115,53 @@ func (l *Logger) checkAuth(authInfo *srpc.AuthInformation) error {
 
 func (l *Logger) debug(level int16, v ...interface{}) {
 	if l.maxLevel >= level {
-		l.log(level, fmt.Sprint(v...), false)
+		l.log(level, fmt.Sprint(v...), nil, false)
 	}
 }
 
 func (l *Logger) debugf(level int16, format string, v ...interface{}) {
 	if l.maxLevel >= level {
-		l.log(level, fmt.Sprintf(format, v...), false)
+		l.log(level, fmt.Sprintf(format, v...), nil, false)
 	}
 }
 
 func (l *Logger) debugln(level int16, v ...interface{}) {
 	if l.maxLevel >= level {
-		l.log(level, fmt.Sprintln(v...), false)
+		l.log(level, fmt.Sprintln(v...), nil, false)
+	}
+}
+
+func (l *Logger) logFields(level int16, msg string, fields []liblog.Field) {
+	if l.maxLevel >= level {
+		l.log(level, msg, fields, false)
 	}
 }
 
 func (l *Logger) fatals(msg string) {
-	l.log(-1, msg, true)
+	l.log(-1, msg, nil, true)
 	os.Exit(1)
 }
 
-func (l *Logger) log(level int16, msg string, dying bool) {
+func (l *Logger) log(level int16, msg string, fields []liblog.Field,
+	dying bool) {
 	buffer := &bytes.Buffer{}
 	rawLogger := log.New(buffer, "", l.flags)
 	rawLogger.Output(4, msg)
 	if l.level >= level {
-		l.circularBuffer.Write(buffer.Bytes())
+		if len(fields) < 1 {
+			l.circularBuffer.WriteWithLevel(level, buffer.Bytes())
+		} else {
+			l.circularBuffer.WriteWithFields(level, buffer.Bytes(), fields)
+		}
 	}
 	if !l.haveStreamers { // Fast return if no streamers.
 		return
 	}
+	if len(fields) > 0 {
+		buffer.Truncate(buffer.Len() - 1) // Strip newline.
+		buffer.WriteString(liblog.FormatFields(fields))
+		buffer.WriteByte('\n')
+	}
 	recalculateLevels := false
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
@@ -184,12 +216,12 @@ func (l *Logger) makeStreamer(request proto.WatchRequest) (
 }
 
 func (l *Logger) panics(msg string) {
-	l.log(-1, msg, true)
+	l.log(-1, msg, nil, true)
 	panic(msg)
 }
 
 func (l *Logger) prints(msg string) {
-	l.log(-1, msg, false)
+	l.log(-1, msg, nil, false)
 }
 
 func (l *Logger) setLevel(maxLevel int16) {
@@ -212,6 +244,22 @@ func (l *Logger) updateMaxLevel() {
 	l.maxLevel = maxLevel
 }
 
+func (l *Logger) setupAuditLog(dirname string) {
+	var err error
+	auditLog, err = auditlog.New(
+		auditlog.Options{
+			Directory:   dirname,
+			MaxFileSize: auditLogQuota >> 4,
+			Quota:       auditLogQuota,
+		},
+		auditlog.Params{Logger: l})
+	if err != nil {
+		l.Printf("Error setting up audit log: %s\n", err)
+		return
+	}
+	srpc.SetAuditSink(auditLog)
+}
+
 func (l *Logger) watch(conn *srpc.Conn, streamer *streamerType) {
 	channel := make(chan []byte, 256)
 	streamer.output = channel
@@ -294,6 +342,25 @@ func (t *loggerMapT) Debug(conn *srpc.Conn,
 	}
 }
 
+func (t *loggerMapT) GetAuditRecords(conn *srpc.Conn,
+	request proto.GetAuditRecordsRequest,
+	reply *proto.GetAuditRecordsResponse) error {
+	if _, err := t.getLogger("", conn.GetAuthInformation()); err != nil {
+		return err
+	}
+	if auditLog == nil {
+		reply.Error = "audit logging is not enabled"
+		return nil
+	}
+	records, err := auditLog.GetRecords(request)
+	if err != nil {
+		reply.Error = err.Error()
+		return nil
+	}
+	reply.Records = records
+	return nil
+}
+
 func (t *loggerMapT) GetStackTrace(conn *srpc.Conn,
 	request proto.GetStackTraceRequest,
 	reply *proto.GetStackTraceResponse) error {
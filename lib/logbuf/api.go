@@ -4,6 +4,12 @@ Package logbuf provides a circular buffer for writing logs to.
 Package logbuf provides an io.Writer which can be passed to the log.New
 function to serve as a destination for logs. Logs can be viewed via a HTTP
 interface and may also be directed to the standard error output.
+
+Logs may also be shipped to a remote collector, either a syslog server
+(RFC 5424 messages over TCP or TLS) or a HTTP endpoint accepting JSON. Each
+log line is tagged with the daemon name, hostname and level. If the collector
+is unavailable, log lines are buffered in the log directory until they can be
+sent.
 */
 package logbuf
 
@@ -19,13 +25,20 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/lib/bufwriter"
 	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+const (
+	FormatJson = "json" // JSON lines.
+	FormatText = "text" // Human readable text.
 )
 
 var (
 	stdOptions = Options{
-		HttpServeMux: http.DefaultServeMux,
-		MaxFileSize:  10 << 20,
-		Quota:        100 << 20,
+		HttpServeMux:   http.DefaultServeMux,
+		MaxFileSize:    10 << 20,
+		Quota:          100 << 20,
+		ShipSpoolQuota: 100 << 20,
 	}
 	kSoleLogBuffer *LogBuffer
 	kOnce          sync.Once
@@ -43,6 +56,7 @@ type LogBuffer struct {
 	lastStackTrace time.Time
 	noLogsEver     bool    // true if no logs ever written (fresh directory).
 	panicLogfile   *string // Name of last invocation logfile if it has a panic.
+	shipper        *shipperType
 	usage          flagutil.Size
 	writeNotifier  chan<- struct{}
 	writer         *bufwriter.Writer
@@ -51,12 +65,17 @@ type LogBuffer struct {
 type Options struct {
 	AlsoLogToStderr bool
 	Directory       string
+	Format          string // FormatText (default) or FormatJson.
 	HttpServeMux    *http.ServeMux
 	IdleMarkTimeout time.Duration
 	MaxBufferLines  uint          // Minimum: 100.
 	MaxFileSize     flagutil.Size // Minimum: 16 KiB
 	Quota           flagutil.Size // Minimum: 64 KiB.
 	RedirectStderr  bool          // Only one LogBuffer should set this.
+	ShipCAfile      string        // Default: system roots.
+	ShipDaemonName  string        // Default: name of the executable.
+	ShipSpoolQuota  flagutil.Size // Minimum: 64 KiB.
+	ShipUrl         string        // syslog+tcp://, syslog+tls://, http(s)://
 }
 
 // UseFlagSet instructs this package to read its command-line flags from the
@@ -65,6 +84,8 @@ type Options struct {
 func UseFlagSet(set *flag.FlagSet) {
 	set.BoolVar(&stdOptions.AlsoLogToStderr, "alsoLogToStderr", false,
 		"If true, also write logs to stderr")
+	set.StringVar(&stdOptions.Format, "logFormat", FormatText,
+		"Format to write logs in: text or json")
 	set.DurationVar(&stdOptions.IdleMarkTimeout, "idleMarkTimeout", 0,
 		"time after last log before a 'MARK' message is written to logfile")
 	set.UintVar(&stdOptions.MaxBufferLines, "logbufLines", 1024,
@@ -76,6 +97,12 @@ func UseFlagSet(set *flag.FlagSet) {
 		"Maximum size for a log file. If exceeded, new file is created")
 	set.Var(&stdOptions.Quota, "logQuota",
 		"Log quota. If exceeded, old logs are deleted")
+	set.StringVar(&stdOptions.ShipCAfile, "logShipCAfile", "",
+		"Name of file containing the CA for the log collector")
+	set.Var(&stdOptions.ShipSpoolQuota, "logShipSpoolQuota",
+		"Quota for logs waiting to be shipped. If exceeded, old logs are dropped")
+	set.StringVar(&stdOptions.ShipUrl, "logShipUrl", "",
+		"URL of log collector (syslog+tcp://, syslog+tls://, http:// or https://)")
 }
 
 // GetStandardOptions will return the standard options.
@@ -86,11 +113,19 @@ func UseFlagSet(set *flag.FlagSet) {
 //	-logbufLines:     Number of lines to store in the log buffer
 //	-logDir:          Directory to write log data to. If empty, no logs are
 //	                  written
+//	-logFormat:       Format to write logs in: text (default) or json (JSON
+//	                  lines, with the fields of structured logs as keys)
 //	-logFileMaxSize:  Maximum size for each log file. If exceeded, the logfile
 //	                  is closed and a new one opened.
 //	                  If zero, the limit will be 16 KiB
 //	-logQuota:        Log quota. If exceeded, old logs are deleted.
 //	                  If zero, the quota will be 64 KiB
+//	-logShipCAfile:   Name of file containing the CA for the log collector.
+//	                  If empty, the system roots are used
+//	-logShipSpoolQuota: Quota for logs waiting to be shipped. If exceeded,
+//	                  the oldest logs are dropped
+//	-logShipUrl:      URL of the log collector. If empty, logs are not
+//	                  shipped
 func GetStandardOptions() Options { return stdOptions }
 
 // New returns a new *LogBuffer with the standard options. Note that
@@ -147,6 +182,24 @@ func (lb *LogBuffer) Write(p []byte) (n int, err error) {
 	return lb.write(p)
 }
 
+// WriteWithFields works like WriteWithLevel, except that the fields of a
+// structured log entry are also recorded. For the text format they are
+// appended to the line in key=value form, for the JSON format they are
+// written as keys. Fields named time, level, debugLevel or msg are written
+// with the "fields." prefix (i.e. "fields.time") in JSON format.
+func (lb *LogBuffer) WriteWithFields(level int16, p []byte,
+	fields []log.Field) (int, error) {
+	return lb.writeWithFields(level, p, fields)
+}
+
+// WriteWithLevel works like Write, except that the log level is recorded
+// with the line when it is shipped to a remote collector or written in JSON
+// format. A level of -1 indicates a normal (non-debug) log, otherwise it is
+// the debug level.
+func (lb *LogBuffer) WriteWithLevel(level int16, p []byte) (int, error) {
+	return lb.writeWithFields(level, p, nil)
+}
+
 // WriteHtml will write the contents of the log buffer to writer, with
 // appropriate HTML markups.
 func (lb *LogBuffer) WriteHtml(writer io.Writer) {
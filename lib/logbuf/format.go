@@ -0,0 +1,112 @@
+package logbuf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+const (
+	jsonFieldPrefix = "fields." // For fields which would duplicate a key.
+	jsonTimePrefix  = `{"time":"`
+)
+
+// jsonReservedKeys are the keys written for every JSON log line.
+var jsonReservedKeys = map[string]struct{}{
+	"debugLevel": {},
+	"level":      {},
+	"msg":        {},
+	"time":       {},
+}
+
+// formatMessage formats a message generated by the LogBuffer itself.
+func (lb *LogBuffer) formatMessage(msg string) []byte {
+	now := time.Now()
+	line := now.Format(stampLayout) + " " + msg + "\n"
+	if lb.options.Format == FormatJson {
+		return renderJson(now, -1, []byte(line), nil)
+	}
+	return []byte(line)
+}
+
+// jsonFieldKey returns the JSON key for a field, prefixing keys which are
+// reserved so that they do not duplicate the standard keys.
+func jsonFieldKey(key string) string {
+	if _, ok := jsonReservedKeys[key]; ok {
+		return jsonFieldPrefix + key
+	}
+	return key
+}
+
+// parseJsonTime returns the time of a JSON log line and true, or false if the
+// line is not a JSON log line.
+func parseJsonTime(line string) (time.Time, bool) {
+	if !strings.HasPrefix(line, jsonTimePrefix) {
+		return time.Time{}, false
+	}
+	line = line[len(jsonTimePrefix):]
+	index := strings.IndexByte(line, '"')
+	if index < 0 {
+		return time.Time{}, false
+	}
+	timeStamp, err := time.Parse(time.RFC3339Nano, line[:index])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return timeStamp, true
+}
+
+// renderJson renders a log line (with the timestamp written by the log
+// package) and its fields as a JSON line. The time is always the first key.
+// Fields with reserved keys are written with the "fields." prefix.
+func renderJson(now time.Time, level int16, p []byte,
+	fields []log.Field) []byte {
+	buffer := &bytes.Buffer{}
+	buffer.WriteString(jsonTimePrefix)
+	buffer.WriteString(now.Format(time.RFC3339Nano))
+	if level < 0 {
+		buffer.WriteString(`","level":"info"`)
+	} else {
+		fmt.Fprintf(buffer, `","level":"debug","debugLevel":%d`, level)
+	}
+	buffer.WriteString(`,"msg":`)
+	msg := stripTimestamp(string(bytes.TrimSuffix(p, []byte{'\n'})))
+	writeJsonValue(buffer, msg)
+	for _, field := range fields {
+		buffer.WriteByte(',')
+		writeJsonValue(buffer, jsonFieldKey(field.Key))
+		buffer.WriteByte(':')
+		writeJsonValue(buffer, field.Value)
+	}
+	buffer.WriteString("}\n")
+	return buffer.Bytes()
+}
+
+// jsonValue returns value if it can be encoded as JSON, else it returns the
+// string form of value.
+func jsonValue(value interface{}) interface{} {
+	if err, ok := value.(error); ok {
+		return err.Error()
+	}
+	if _, err := json.Marshal(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return value
+}
+
+// writeJsonValue writes the JSON encoding of value. If value cannot be
+// encoded, it is written as a string.
+func writeJsonValue(buffer *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buffer.Write(data)
+}
@@ -0,0 +1,142 @@
+package logbuf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+// jsonKeys returns the keys of a JSON object in order, including duplicates.
+func jsonKeys(t *testing.T, line []byte) []string {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	if token, err := decoder.Token(); err != nil {
+		t.Fatal(err)
+	} else if token != json.Delim('{') {
+		t.Fatalf("not an object: %s", line)
+	}
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, token.(string))
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+func TestRenderJson(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	tests := []struct {
+		name     string
+		level    int16
+		line     string
+		fields   []log.Field
+		expected string
+	}{
+		{"info", -1, "2024/01/02 03:04:05 hello \"world\"\n", nil,
+			`{"time":"2024-01-02T03:04:05.123456789Z","level":"info",` +
+				`"msg":"hello \"world\""}`},
+		{"debug", 2, "2024/01/02 03:04:05.123456 debugging\n", nil,
+			`{"time":"2024-01-02T03:04:05.123456789Z","level":"debug",` +
+				`"debugLevel":2,"msg":"debugging"}`},
+		{"fields", -1, "message\n",
+			[]log.Field{
+				{Key: "host", Value: "host0"},
+				{Key: "count", Value: 3},
+				{Key: "error", Value: errors.New("failed")},
+				{Key: "channel", Value: make(chan int)},
+			},
+			`{"time":"2024-01-02T03:04:05.123456789Z","level":"info",` +
+				`"msg":"message","host":"host0","count":3,` +
+				`"error":"failed","channel":"0x`},
+		{"reserved keys", 1, "message\n",
+			[]log.Field{
+				{Key: "time", Value: "yesterday"},
+				{Key: "level", Value: "high"},
+				{Key: "debugLevel", Value: 9},
+				{Key: "msg", Value: "other"},
+			},
+			`{"time":"2024-01-02T03:04:05.123456789Z","level":"debug",` +
+				`"debugLevel":1,"msg":"message",` +
+				`"fields.time":"yesterday","fields.level":"high",` +
+				`"fields.debugLevel":9,"fields.msg":"other"}`},
+	}
+	for _, test := range tests {
+		line := renderJson(now, test.level, []byte(test.line), test.fields)
+		if !bytes.HasSuffix(line, []byte("}\n")) ||
+			bytes.Count(line, []byte("\n")) != 1 {
+			t.Errorf("%s: not a single line: %s", test.name, line)
+			continue
+		}
+		if !strings.HasPrefix(string(line), test.expected) {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s",
+				test.name, test.expected, line)
+		}
+		if !json.Valid(line) {
+			t.Errorf("%s: invalid JSON: %s", test.name, line)
+		}
+		seen := make(map[string]struct{})
+		for _, key := range jsonKeys(t, line) {
+			if _, ok := seen[key]; ok {
+				t.Errorf("%s: duplicate key: %s", test.name, key)
+			}
+			seen[key] = struct{}{}
+		}
+	}
+}
+
+func TestRenderJsonReservedKeys(t *testing.T) {
+	now := time.Now()
+	line := renderJson(now, -1, []byte("message\n"), []log.Field{
+		{Key: "time", Value: "yesterday"},
+		{Key: "msg", Value: "other"},
+	})
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(line, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["msg"] != "message" || decoded["fields.msg"] != "other" ||
+		decoded["fields.time"] != "yesterday" {
+		t.Errorf("unexpected line: %s", line)
+	}
+	if timeStamp, ok := parseJsonTime(string(line)); !ok {
+		t.Errorf("time not parsed: %s", line)
+	} else if !timeStamp.Equal(now) {
+		t.Errorf("expected time: %s, got: %s", now, timeStamp)
+	}
+}
+
+func TestParseJsonTime(t *testing.T) {
+	expected := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("", -7200))
+	tests := []struct {
+		line  string
+		valid bool
+	}{
+		{`{"time":"2024-01-02T03:04:05.000000006-02:00","msg":"x"}`, true},
+		{`{"time":"2024-01-02T03:04:05.000000006-02:00"`, true},
+		{"2024/01/02 03:04:05 text line", false},
+		{`{"msg":"x","time":"2024-01-02T03:04:05Z"}`, false},
+		{`{"time":"yesterday","msg":"x"}`, false},
+		{`{"time":"2024-01-02T03:04:05Z`, false},
+		{"", false},
+	}
+	for _, test := range tests {
+		timeStamp, ok := parseJsonTime(test.line)
+		if ok != test.valid {
+			t.Errorf("%s: expected valid: %v", test.line, test.valid)
+		} else if ok && !timeStamp.Equal(expected) {
+			t.Errorf("%s: expected: %s, got: %s",
+				test.line, expected, timeStamp)
+		}
+	}
+}
@@ -496,6 +496,9 @@ func (lb *LogBuffer) writeHtml(writer io.Writer) {
 	} else {
 		fmt.Fprintln(writer, "Logs:<br>")
 	}
+	if lb.shipper != nil {
+		lb.shipper.writeHtml(writer)
+	}
 	fmt.Fprintln(writer, "<pre>")
 	lb.dump(writer, "", "", false, true, nil)
 	fmt.Fprintln(writer, "</pre>")
@@ -2,6 +2,7 @@ package logbuf
 
 import (
 	"bufio"
+	"bytes"
 	"container/ring"
 	"errors"
 	"flag"
@@ -19,6 +20,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
 	"github.com/Cloud-Foundations/Dominator/lib/format"
 	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
 	"github.com/Cloud-Foundations/Dominator/lib/wsyscall"
 )
 
@@ -42,6 +44,9 @@ func newLogBuffer(options Options) *LogBuffer {
 	if options.Quota < 65536 {
 		options.Quota = 65536
 	}
+	if options.Format != FormatJson {
+		options.Format = FormatText
+	}
 	if options.AlsoLogToStderr {
 		options.RedirectStderr = false // Prevent duplicates sent to stderr.
 	}
@@ -52,6 +57,9 @@ func newLogBuffer(options Options) *LogBuffer {
 	if err := logBuffer.setupFileLogging(); err != nil {
 		fmt.Fprintln(logBuffer, err)
 	}
+	if err := logBuffer.setupShipper(); err != nil {
+		fmt.Fprintln(logBuffer, err)
+	}
 	logBuffer.addHttpHandlers()
 	return logBuffer
 }
@@ -170,19 +178,37 @@ func (lb *LogBuffer) flush() error {
 }
 
 func (lb *LogBuffer) write(p []byte) (n int, err error) {
+	return lb.writeWithFields(-1, p, nil)
+}
+
+func (lb *LogBuffer) writeWithFields(level int16, p []byte,
+	fields []log.Field) (int, error) {
+	var val []byte
+	if lb.options.Format == FormatJson {
+		val = renderJson(time.Now(), level, p, fields)
+	} else if len(fields) > 0 {
+		val = make([]byte, 0, len(p)+64)
+		val = append(val, bytes.TrimSuffix(p, []byte{'\n'})...)
+		val = append(val, log.FormatFields(fields)...)
+		val = append(val, '\n')
+	} else {
+		val = make([]byte, len(p))
+		copy(val, p)
+	}
 	if lb.options.AlsoLogToStderr {
-		os.Stderr.Write(p)
+		os.Stderr.Write(val)
 	}
-	val := make([]byte, len(p))
-	copy(val, p)
 	lb.rwMutex.Lock()
-	sendNotify := lb.writeToLogFile(p)
+	sendNotify := lb.writeToLogFile(val)
 	lb.buffer.Value = val
 	lb.buffer = lb.buffer.Next()
 	lb.rwMutex.Unlock()
 	if sendNotify {
 		lb.writeNotifier <- struct{}{}
 	}
+	if lb.shipper != nil {
+		lb.shipper.ship(level, p, fields)
+	}
 	return len(p), nil
 }
 
@@ -205,11 +231,7 @@ func (lb *LogBuffer) writeToLogFile(p []byte) bool {
 
 // This should be called with the lock held.
 func (lb *LogBuffer) closeAndOpenNewFile() error {
-	now := time.Now()
-	year, month, day := now.Date()
-	hour, minute, second := now.Clock()
-	nWritten, _ := fmt.Fprintf(lb.writer, "%d/%02d/%02d %02d:%02d:%02d %s\n",
-		year, month, day, hour, minute, second, reopenMessage)
+	nWritten, _ := lb.writer.Write(lb.formatMessage(reopenMessage))
 	lb.usage += flagutil.Size(nWritten)
 	lb.writer.Flush()
 	lb.writer = nil
@@ -303,13 +325,9 @@ func (lb *LogBuffer) enforceQuota() error {
 		}
 	}
 	if numBytesDeleted > 0 {
-		now := time.Now()
-		year, month, day := now.Date()
-		hour, minute, second := now.Clock()
-		nWritten, _ := fmt.Fprintf(lb.writer,
-			"%d/%02d/%02d %02d:%02d:%02d Deleted %s in %d files\n",
-			year, month, day, hour, minute, second,
-			format.FormatBytes(numBytesDeleted), numFilesDeleted)
+		nWritten, _ := lb.writer.Write(lb.formatMessage(fmt.Sprintf(
+			"Deleted %s in %d files",
+			format.FormatBytes(numBytesDeleted), numFilesDeleted)))
 		lb.fileSize += flagutil.Size(nWritten)
 		lb.usage += flagutil.Size(nWritten)
 	}
@@ -368,7 +386,11 @@ func (lb *LogBuffer) dumpSince(writer io.Writer, name string,
 			foundReopenMessage = true
 			continue
 		}
-		if len(line) >= minLength {
+		if timeStamp, ok := parseJsonTime(line); ok {
+			if timeStamp.Before(earliestTime) {
+				continue
+			}
+		} else if len(line) >= minLength {
 			timeString := line[:minLength-2]
 			timeStamp, err := time.ParseInLocation(timeFormat, timeString,
 				time.Local)
@@ -402,14 +424,10 @@ func (lb *LogBuffer) dumpSince(writer io.Writer, name string,
 }
 
 func (lb *LogBuffer) writeMark() {
-	now := time.Now()
-	year, month, day := now.Date()
-	hour, minute, second := now.Clock()
-	str := fmt.Sprintf("%d/%02d/%02d %02d:%02d:%02d MARK\n",
-		year, month, day, hour, minute, second)
+	line := lb.formatMessage("MARK")
 	lb.rwMutex.Lock()
 	defer lb.rwMutex.Unlock()
-	lb.writeToLogFile([]byte(str))
+	lb.writeToLogFile(line)
 }
 
 func (rl *regexpListType) include(b []byte) bool {
@@ -0,0 +1,219 @@
+package logbuf
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	syslogFacilityDaemon = 3
+	syslogSeverityDebug  = 7
+	syslogSeverityInfo   = 6
+	syslogStructuredId   = "logbuf@32473" // 32473: example enterprise number.
+	syslogTimeLayout     = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+type httpSender struct {
+	client *http.Client
+	url    string
+}
+
+type syslogSender struct {
+	address   string
+	conn      net.Conn
+	pid       int
+	tlsConfig *tls.Config // nil: plain TCP.
+}
+
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+func loadCAfile(filename string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in: %s", filename)
+	}
+	return certPool, nil
+}
+
+func newShipSender(shipUrl, caFile string) (shipSender, error) {
+	parsedUrl, err := url.Parse(shipUrl)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		if tlsConfig.RootCAs, err = loadCAfile(caFile); err != nil {
+			return nil, err
+		}
+	}
+	switch parsedUrl.Scheme {
+	case "http", "https":
+		return &httpSender{
+			client: &http.Client{
+				Timeout: shipTimeout,
+				Transport: &http.Transport{
+					Proxy:           http.ProxyFromEnvironment,
+					TLSClientConfig: tlsConfig,
+				},
+			},
+			url: shipUrl,
+		}, nil
+	case "syslog+tcp":
+		return newSyslogSender(parsedUrl, nil)
+	case "syslog+tls":
+		tlsConfig.ServerName = parsedUrl.Hostname()
+		return newSyslogSender(parsedUrl, tlsConfig)
+	}
+	return nil, fmt.Errorf("unsupported log shipping scheme: %s",
+		parsedUrl.Scheme)
+}
+
+func newSyslogSender(parsedUrl *url.URL, tlsConfig *tls.Config) (
+	*syslogSender, error) {
+	if parsedUrl.Host == "" {
+		return nil, errors.New("no syslog server specified")
+	}
+	address := parsedUrl.Host
+	if parsedUrl.Port() == "" {
+		if tlsConfig == nil {
+			address = net.JoinHostPort(address, "514")
+		} else {
+			address = net.JoinHostPort(address, "6514")
+		}
+	}
+	return &syslogSender{
+		address:   address,
+		pid:       os.Getpid(),
+		tlsConfig: tlsConfig,
+	}, nil
+}
+
+// syslogParamName converts a field key to a valid SD-NAME.
+func syslogParamName(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+	if key == "" {
+		return "_"
+	}
+	if len(key) > 32 {
+		return key[:32]
+	}
+	return key
+}
+
+func syslogValue(value string, maxLength int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > maxLength {
+		return value[:maxLength]
+	}
+	return value
+}
+
+func (sender *httpSender) send(records []shipRecord) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	resp, err := sender.client.Post(sender.url, "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("error shipping logs: %s", resp.Status)
+	}
+	return nil
+}
+
+// formatMessage formats a record as a RFC 5424 syslog message, framed with
+// the octet count (RFC 5425, RFC 6587).
+func (sender *syslogSender) formatMessage(buffer *bytes.Buffer,
+	record shipRecord) {
+	severity := syslogSeverityInfo
+	if record.DebugLevel >= 0 {
+		severity = syslogSeverityDebug
+	}
+	keys := make([]string, 0, len(record.Fields))
+	for key := range record.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var fields strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&fields, " %s=\"%s\"",
+			syslogParamName(key),
+			syslogParamEscaper.Replace(fmt.Sprint(record.Fields[key])))
+	}
+	message := fmt.Sprintf(
+		"<%d>1 %s %s %s %d - [%s level=\"%s\" debugLevel=\"%d\"%s] %s",
+		syslogFacilityDaemon*8+severity,
+		record.Time.UTC().Format(syslogTimeLayout),
+		syslogValue(record.Hostname, 255),
+		syslogValue(record.DaemonName, 48),
+		sender.pid,
+		syslogStructuredId,
+		syslogParamEscaper.Replace(record.Level),
+		record.DebugLevel,
+		fields.String(),
+		record.Message)
+	fmt.Fprintf(buffer, "%d %s", len(message), message)
+}
+
+func (sender *syslogSender) send(records []shipRecord) error {
+	if sender.conn == nil {
+		dialer := &net.Dialer{Timeout: shipTimeout}
+		var conn net.Conn
+		var err error
+		if sender.tlsConfig == nil {
+			conn, err = dialer.Dial("tcp", sender.address)
+		} else {
+			conn, err = tls.DialWithDialer(dialer, "tcp", sender.address,
+				sender.tlsConfig)
+		}
+		if err != nil {
+			return err
+		}
+		sender.conn = conn
+	}
+	buffer := &bytes.Buffer{}
+	for _, record := range records {
+		sender.formatMessage(buffer, record)
+	}
+	sender.conn.SetWriteDeadline(time.Now().Add(shipTimeout))
+	if _, err := sender.conn.Write(buffer.Bytes()); err != nil {
+		sender.conn.Close()
+		sender.conn = nil
+		return err
+	}
+	return nil
+}
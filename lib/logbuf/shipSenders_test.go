@@ -0,0 +1,248 @@
+package logbuf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSyslogFrame reads one octet-counted frame (RFC 6587).
+func readSyslogFrame(t *testing.T, reader *bufio.Reader) string {
+	lengthString, err := reader.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	length, err := strconv.Atoi(strings.TrimSuffix(lengthString, " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(reader, message); err != nil {
+		t.Fatal(err)
+	}
+	return string(message)
+}
+
+func TestSyslogFormatMessage(t *testing.T) {
+	sender := &syslogSender{pid: 42}
+	recordTime := time.Date(2024, 1, 2, 3, 4, 5, 6000,
+		time.FixedZone("", 3600))
+	tests := []struct {
+		record   shipRecord
+		expected string
+	}{
+		{shipRecord{
+			DaemonName: "test-daemon",
+			DebugLevel: -1,
+			Fields: map[string]interface{}{
+				"path":    "/a b",
+				"count":   3,
+				"quote":   `x"]\`,
+				"bad key": "value",
+			},
+			Hostname: "host0",
+			Level:    "info",
+			Message:  "hello world",
+			Time:     recordTime,
+		},
+			`<30>1 2024-01-02T02:04:05.000006Z host0 test-daemon 42 - ` +
+				`[logbuf@32473 level="info" debugLevel="-1" ` +
+				`bad_key="value" count="3" path="/a b" quote="x\"\]\\"] ` +
+				`hello world`},
+		{shipRecord{
+			DebugLevel: 2,
+			Hostname:   "bad host",
+			Level:      "debug",
+			Message:    "hello world",
+			Time:       recordTime,
+		},
+			`<31>1 2024-01-02T02:04:05.000006Z bad_host - 42 - ` +
+				`[logbuf@32473 level="debug" debugLevel="2"] hello world`},
+	}
+	for _, test := range tests {
+		buffer := &bytes.Buffer{}
+		sender.formatMessage(buffer, test.record)
+		message := readSyslogFrame(t, bufio.NewReader(buffer))
+		if message != test.expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", test.expected, message)
+		}
+		if buffer.Len() > 0 {
+			t.Errorf("%d bytes after frame", buffer.Len())
+		}
+	}
+}
+
+func TestSyslogParamName(t *testing.T) {
+	tests := map[string]string{
+		"":                      "_",
+		"key":                   "key",
+		`a=b]c"d e`:             "a_b_c_d_e",
+		strings.Repeat("k", 40): strings.Repeat("k", 32),
+	}
+	for key, expected := range tests {
+		if name := syslogParamName(key); name != expected {
+			t.Errorf("\"%s\": expected: \"%s\", got: \"%s\"",
+				key, expected, name)
+		}
+	}
+	if value := syslogValue("", 4); value != "-" {
+		t.Errorf("empty value: expected: \"-\", got: \"%s\"", value)
+	}
+	if value := syslogValue("a b\tcdef", 4); value != "a_b_" {
+		t.Errorf("expected: \"a_b_\", got: \"%s\"", value)
+	}
+}
+
+func TestSyslogSenderSend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	sender, err := newShipSender("syslog+tcp://"+listener.Addr().String(),
+		"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []shipRecord{
+		{DebugLevel: -1, Level: "info", Message: "hello world"},
+		{DebugLevel: -1, Level: "info", Message: "second"},
+	}
+	if err := sender.send(records); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	for _, expected := range []string{" hello world", " second"} {
+		message := readSyslogFrame(t, reader)
+		if !strings.HasPrefix(message, "<30>1 ") ||
+			!strings.HasSuffix(message, expected) {
+			t.Errorf("unexpected message: %s", message)
+		}
+	}
+}
+
+func TestNewShipSender(t *testing.T) {
+	tests := []struct {
+		url     string
+		address string // Empty for HTTP.
+		tls     bool
+		valid   bool
+	}{
+		{"https://logs.example.com/ingest", "", false, true},
+		{"syslog+tcp://logs.example.com", "logs.example.com:514", false, true},
+		{"syslog+tls://logs.example.com", "logs.example.com:6514", true, true},
+		{"syslog+tcp://logs.example.com:1514", "logs.example.com:1514", false,
+			true},
+		{"syslog+tcp://", "", false, false},
+		{"ftp://logs.example.com", "", false, false},
+	}
+	for _, test := range tests {
+		sender, err := newShipSender(test.url, "")
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: no error", test.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.url, err)
+			continue
+		}
+		switch sender := sender.(type) {
+		case *httpSender:
+			if test.address != "" {
+				t.Errorf("%s: unexpected HTTP sender", test.url)
+			}
+		case *syslogSender:
+			if sender.address != test.address {
+				t.Errorf("%s: expected address: %s, got: %s",
+					test.url, test.address, sender.address)
+			}
+			if (sender.tlsConfig != nil) != test.tls {
+				t.Errorf("%s: expected TLS: %v", test.url, test.tls)
+			}
+		}
+	}
+}
+
+func TestHttpSenderSend(t *testing.T) {
+	var received []shipRecord
+	var contentType string
+	statusCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			contentType = req.Header.Get("Content-Type")
+			received = nil
+			if err := json.NewDecoder(req.Body).Decode(&received); err != nil {
+				t.Error(err)
+			}
+			w.WriteHeader(statusCode)
+		}))
+	defer server.Close()
+	sender, err := newShipSender(server.URL+"/ingest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordTime := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	records := []shipRecord{
+		{
+			DaemonName: "test-daemon",
+			DebugLevel: -1,
+			Fields:     map[string]interface{}{"count": 3, "path": "/a b"},
+			Hostname:   "host0",
+			Level:      "info",
+			Message:    "hello world",
+			Time:       recordTime,
+		},
+		{
+			DaemonName: "test-daemon",
+			DebugLevel: 1,
+			Hostname:   "host0",
+			Level:      "debug",
+			Message:    "second",
+			Time:       recordTime,
+		},
+	}
+	if err := sender.send(records); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("unexpected content type: %s", contentType)
+	}
+	if len(received) != len(records) {
+		t.Fatalf("expected %d records, got: %d", len(records), len(received))
+	}
+	for index, record := range received {
+		sent := records[index]
+		if record.DaemonName != sent.DaemonName ||
+			record.DebugLevel != sent.DebugLevel ||
+			record.Hostname != sent.Hostname ||
+			record.Message != sent.Message ||
+			!record.Time.Equal(sent.Time) ||
+			len(record.Fields) != len(sent.Fields) {
+			t.Errorf("record %d: sent: %v, received: %v",
+				index, sent, record)
+		}
+	}
+	if path := received[0].Fields["path"]; path != "/a b" {
+		t.Errorf("field path: %v", path)
+	}
+	statusCode = http.StatusServiceUnavailable
+	if err := sender.send(records); err == nil {
+		t.Error("no error for failed request")
+	}
+}
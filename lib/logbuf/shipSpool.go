@@ -0,0 +1,165 @@
+package logbuf
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spoolType holds batches of records waiting to be shipped, one batch per
+// file. Files are named <nanoseconds>-<number of records> so that they sort
+// oldest first and can be counted without being read.
+type spoolType struct {
+	directory  string
+	quota      uint64
+	mutex      sync.Mutex      // Protect everything below.
+	files      []spoolFileType // Oldest first.
+	lastName   string
+	numDropped uint64
+	numRecords uint64
+	size       uint64
+}
+
+type spoolFileType struct {
+	name       string
+	numRecords uint64
+	size       uint64
+}
+
+func openSpool(directory string, quota uint64) (*spoolType, error) {
+	if err := os.MkdirAll(directory, dirPerms); err != nil {
+		return nil, err
+	}
+	dirEntries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	spool := &spoolType{directory: directory, quota: quota}
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if !dirEntry.Type().IsRegular() {
+			continue
+		}
+		fields := strings.Split(name, "-")
+		if len(fields) != 2 {
+			os.Remove(path.Join(directory, name))
+			continue
+		}
+		numRecords, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			os.Remove(path.Join(directory, name))
+			continue
+		}
+		fi, err := dirEntry.Info()
+		if err != nil {
+			return nil, err
+		}
+		spool.files = append(spool.files, spoolFileType{
+			name:       name,
+			numRecords: numRecords,
+			size:       uint64(fi.Size()),
+		})
+		spool.numRecords += numRecords
+		spool.size += uint64(fi.Size())
+	}
+	sort.Slice(spool.files, func(i, j int) bool {
+		return spool.files[i].name < spool.files[j].name
+	})
+	if len(spool.files) > 0 {
+		spool.lastName = spool.files[len(spool.files)-1].name
+	}
+	return spool, nil
+}
+
+func (spool *spoolType) empty() bool {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	return len(spool.files) < 1
+}
+
+func (spool *spoolType) getStats() (uint64, uint64, uint64) {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	return spool.numRecords, spool.size, spool.numDropped
+}
+
+func (spool *spoolType) readOldest() ([]shipRecord, error) {
+	spool.mutex.Lock()
+	name := spool.files[0].name
+	spool.mutex.Unlock()
+	file, err := os.Open(path.Join(spool.directory, name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []shipRecord
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for decoder.More() {
+		var record shipRecord
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("error decoding: %s: %s", name, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// This should be called with the lock held.
+func (spool *spoolType) removeFile(index int) {
+	file := spool.files[index]
+	os.Remove(path.Join(spool.directory, file.name))
+	spool.numRecords -= file.numRecords
+	spool.size -= file.size
+	spool.files = append(spool.files[:index], spool.files[index+1:]...)
+}
+
+func (spool *spoolType) removeOldest() {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	spool.removeFile(0)
+}
+
+// write writes a batch to a new file. If the quota is exceeded, the oldest
+// batches are dropped.
+func (spool *spoolType) write(records []shipRecord) error {
+	var builder strings.Builder
+	encoder := json.NewEncoder(&builder)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	name := fmt.Sprintf("%020d-%d", time.Now().UnixNano(), len(records))
+	if name <= spool.lastName { // Preserve ordering if the clock steps back.
+		lastNanoseconds, _ := strconv.ParseUint(
+			strings.Split(spool.lastName, "-")[0], 10, 64)
+		name = fmt.Sprintf("%020d-%d", lastNanoseconds+1, len(records))
+	}
+	err := os.WriteFile(path.Join(spool.directory, name),
+		[]byte(builder.String()), filePerms)
+	if err != nil {
+		return err
+	}
+	spool.lastName = name
+	spool.files = append(spool.files, spoolFileType{
+		name:       name,
+		numRecords: uint64(len(records)),
+		size:       uint64(builder.Len()),
+	})
+	spool.numRecords += uint64(len(records))
+	spool.size += uint64(builder.Len())
+	for spool.size > spool.quota && len(spool.files) > 1 {
+		spool.numDropped += spool.files[0].numRecords
+		spool.removeFile(0)
+	}
+	return nil
+}
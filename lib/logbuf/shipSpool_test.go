@@ -0,0 +1,183 @@
+package logbuf
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// readSpool reads and removes all batches, returning the messages in order.
+func readSpool(t *testing.T, spool *spoolType) []string {
+	var messages []string
+	for !spool.empty() {
+		records, err := spool.readOldest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			messages = append(messages, record.Message)
+		}
+		spool.removeOldest()
+	}
+	return messages
+}
+
+func checkMessages(t *testing.T, messages []string, first, count int) {
+	if len(messages) != count {
+		t.Fatalf("expected %d messages, got: %d", count, len(messages))
+	}
+	for index, message := range messages {
+		expected := fmt.Sprintf("message %d", first+index)
+		if message != expected {
+			t.Errorf("expected: \"%s\", got: \"%s\"", expected, message)
+		}
+	}
+}
+
+func TestSpoolOrdering(t *testing.T) {
+	spool, err := openSpool(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spool.empty() {
+		t.Fatal("new spool not empty")
+	}
+	for batch := 0; batch < 3; batch++ {
+		records := make([]shipRecord, 4)
+		for index := range records {
+			records[index].Message = fmt.Sprintf("message %d", batch*4+index)
+		}
+		if err := spool.write(records); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Simulate the clock stepping back: the next batch must still sort last.
+	spool.lastName = fmt.Sprintf("%020d-%d",
+		time.Now().Add(time.Hour).UnixNano(), 4)
+	err = spool.write([]shipRecord{
+		{Message: "message 12"},
+		{Message: "message 13"},
+		{Message: "message 14"},
+		{Message: "message 15"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numRecords, _, _ := spool.getStats(); numRecords != 16 {
+		t.Errorf("expected 16 records, got: %d", numRecords)
+	}
+	checkMessages(t, readSpool(t, spool), 0, 16)
+	if numRecords, size, _ := spool.getStats(); numRecords != 0 || size != 0 {
+		t.Errorf("drained spool has %d records, %d bytes", numRecords, size)
+	}
+	if dirEntries, err := os.ReadDir(spool.directory); err != nil {
+		t.Fatal(err)
+	} else if len(dirEntries) > 0 {
+		t.Errorf("%d files left in drained spool", len(dirEntries))
+	}
+}
+
+func TestSpoolQuota(t *testing.T) {
+	directory := t.TempDir()
+	spool, err := openSpool(directory, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = spool.write([]shipRecord{
+		{Message: "message 0"},
+		{Message: "message 1"},
+		{Message: "message 2"},
+		{Message: "message 3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, batchSize, _ := spool.getStats()
+	// Allow for two and a half batches.
+	spool.quota = batchSize*5/2 + 1
+	for batch := 1; batch < 5; batch++ {
+		records := make([]shipRecord, 4)
+		for index := range records {
+			records[index].Message = fmt.Sprintf("message %d", batch*4+index)
+		}
+		if err := spool.write(records); err != nil {
+			t.Fatal(err)
+		}
+	}
+	numRecords, size, numDropped := spool.getStats()
+	if numRecords != 8 {
+		t.Errorf("expected 8 records, got: %d", numRecords)
+	}
+	if numDropped != 12 {
+		t.Errorf("expected 12 records dropped, got: %d", numDropped)
+	}
+	if size > spool.quota {
+		t.Errorf("size: %d exceeds quota: %d", size, spool.quota)
+	}
+	// The oldest batches are dropped.
+	checkMessages(t, readSpool(t, spool), 12, 8)
+	// The newest batch is kept even if it exceeds the quota.
+	spool.quota = 1
+	err = spool.write([]shipRecord{
+		{Message: "message 20"},
+		{Message: "message 21"},
+		{Message: "message 22"},
+		{Message: "message 23"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMessages(t, readSpool(t, spool), 20, 4)
+}
+
+func TestSpoolRestart(t *testing.T) {
+	directory := t.TempDir()
+	spool, err := openSpool(directory, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for batch := 0; batch < 3; batch++ {
+		records := make([]shipRecord, 4)
+		for index := range records {
+			records[index].Message = fmt.Sprintf("message %d", batch*4+index)
+		}
+		if err := spool.write(records); err != nil {
+			t.Fatal(err)
+		}
+	}
+	numRecords, size, _ := spool.getStats()
+	// Files not written by the spool are removed when it is opened.
+	for _, name := range []string{"junk", "junk-records"} {
+		err := os.WriteFile(path.Join(directory, name), []byte("x"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	spool, err = openSpool(directory, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newNumRecords, newSize, _ := spool.getStats()
+	if newNumRecords != numRecords || newSize != size {
+		t.Errorf("expected %d records, %d bytes, got: %d records, %d bytes",
+			numRecords, size, newNumRecords, newSize)
+	}
+	for _, name := range []string{"junk", "junk-records"} {
+		if _, err := os.Stat(path.Join(directory, name)); err == nil {
+			t.Errorf("%s not removed", name)
+		}
+	}
+	// New batches are shipped after the recovered batches.
+	err = spool.write([]shipRecord{
+		{Message: "message 12"},
+		{Message: "message 13"},
+		{Message: "message 14"},
+		{Message: "message 15"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMessages(t, readSpool(t, spool), 0, 16)
+}
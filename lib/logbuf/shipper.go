@@ -0,0 +1,264 @@
+package logbuf
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/log"
+)
+
+const (
+	shipBatchInterval = time.Second
+	shipBatchSize     = 256
+	shipMaxRetryDelay = 5 * time.Minute
+	shipMinRetryDelay = 5 * time.Second
+	shipQueueLength   = 4096
+	shipSpoolDirname  = "ship-spool"
+	shipTimeout       = 30 * time.Second
+	stampLayout       = "2006/01/02 15:04:05"
+)
+
+type shipRecord struct {
+	DaemonName string
+	DebugLevel int16                  // -1: not a debug log.
+	Fields     map[string]interface{} `json:",omitempty"`
+	Hostname   string
+	Level      string // "info" or "debug".
+	Message    string
+	Time       time.Time
+}
+
+type shipSender interface {
+	send(records []shipRecord) error
+}
+
+type shipperType struct {
+	daemonName string
+	hostname   string
+	queue      chan shipRecord
+	sender     shipSender
+	spool      *spoolType // nil: no log directory, no buffering on disk.
+	url        string
+	mutex      sync.Mutex // Protect everything below.
+	lastError  error
+	numDropped uint64
+	numShipped uint64
+}
+
+func (lb *LogBuffer) setupShipper() error {
+	if lb.options.ShipUrl == "" {
+		return nil
+	}
+	sender, err := newShipSender(lb.options.ShipUrl, lb.options.ShipCAfile)
+	if err != nil {
+		return fmt.Errorf("error setting up log shipping: %s", err)
+	}
+	shipper := &shipperType{
+		daemonName: lb.options.ShipDaemonName,
+		queue:      make(chan shipRecord, shipQueueLength),
+		sender:     sender,
+		url:        lb.options.ShipUrl,
+	}
+	if shipper.daemonName == "" {
+		shipper.daemonName = path.Base(os.Args[0])
+	}
+	if shipper.hostname, err = os.Hostname(); err != nil {
+		return err
+	}
+	if lb.options.Directory != "" {
+		quota := lb.options.ShipSpoolQuota
+		if quota < 65536 {
+			quota = 65536
+		}
+		shipper.spool, err = openSpool(
+			path.Join(lb.options.Directory, shipSpoolDirname), uint64(quota))
+		if err != nil {
+			return fmt.Errorf("error opening log shipping spool: %s", err)
+		}
+	}
+	lb.shipper = shipper
+	go shipper.loop()
+	return nil
+}
+
+// stripTimestamp removes the timestamp written by the log package (with or
+// without subsecond resolution) from the start of a log line.
+func stripTimestamp(line string) string {
+	if len(line) < len(stampLayout)+1 {
+		return line
+	}
+	index := len(stampLayout)
+	if _, err := time.Parse(stampLayout, line[:index]); err != nil {
+		return line
+	}
+	if line[index] == '.' {
+		for index++; index < len(line); index++ {
+			if line[index] < '0' || line[index] > '9' {
+				break
+			}
+		}
+	}
+	if index >= len(line) || line[index] != ' ' {
+		return line
+	}
+	return line[index+1:]
+}
+
+// loop collects records into batches and sends them. If sending fails the
+// batch is spooled and subsequent batches are spooled (preserving order)
+// until the spool has been drained.
+func (s *shipperType) loop() {
+	retryDelay := shipMinRetryDelay
+	var nextRetry time.Time
+	var batch []shipRecord
+	ticker := time.NewTicker(shipBatchInterval)
+	for {
+		select {
+		case record := <-s.queue:
+			batch = append(batch, record)
+			if len(batch) < shipBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		if len(batch) > 0 {
+			if s.spool == nil || s.spool.empty() {
+				if err := s.send(batch); err != nil {
+					s.spoolBatch(batch)
+					nextRetry = time.Now().Add(retryDelay)
+				}
+			} else {
+				s.spoolBatch(batch)
+			}
+			batch = nil
+		}
+		if s.spool == nil || s.spool.empty() {
+			continue
+		}
+		if time.Now().Before(nextRetry) {
+			continue
+		}
+		if err := s.drainSpool(); err != nil {
+			if retryDelay *= 2; retryDelay > shipMaxRetryDelay {
+				retryDelay = shipMaxRetryDelay
+			}
+			nextRetry = time.Now().Add(retryDelay)
+		} else {
+			retryDelay = shipMinRetryDelay
+		}
+	}
+}
+
+func (s *shipperType) drainSpool() error {
+	for !s.spool.empty() {
+		records, err := s.spool.readOldest()
+		if err != nil {
+			s.setError(err)
+			s.spool.removeOldest()
+			continue
+		}
+		if err := s.send(records); err != nil {
+			return err
+		}
+		s.spool.removeOldest()
+	}
+	return nil
+}
+
+// ship queues a log line for shipping. If the queue is full, the line is
+// dropped rather than blocking the logger.
+func (s *shipperType) ship(level int16, line []byte, fields []log.Field) {
+	for len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+	record := shipRecord{
+		DaemonName: s.daemonName,
+		DebugLevel: level,
+		Hostname:   s.hostname,
+		Level:      "info",
+		Message:    stripTimestamp(string(line)),
+		Time:       time.Now(),
+	}
+	if level >= 0 {
+		record.Level = "debug"
+	}
+	if len(fields) > 0 {
+		record.Fields = make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			record.Fields[field.Key] = jsonValue(field.Value)
+		}
+	}
+	select {
+	case s.queue <- record:
+	default:
+		s.mutex.Lock()
+		s.numDropped++
+		s.mutex.Unlock()
+	}
+}
+
+func (s *shipperType) send(records []shipRecord) error {
+	if err := s.sender.send(records); err != nil {
+		s.setError(err)
+		return err
+	}
+	s.mutex.Lock()
+	s.lastError = nil
+	s.numShipped += uint64(len(records))
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *shipperType) setError(err error) {
+	s.mutex.Lock()
+	s.lastError = err
+	s.mutex.Unlock()
+}
+
+func (s *shipperType) spoolBatch(batch []shipRecord) {
+	if s.spool == nil {
+		s.mutex.Lock()
+		s.numDropped += uint64(len(batch))
+		s.mutex.Unlock()
+		return
+	}
+	if err := s.spool.write(batch); err != nil {
+		s.mutex.Lock()
+		s.lastError = err
+		s.numDropped += uint64(len(batch))
+		s.mutex.Unlock()
+	}
+}
+
+func (s *shipperType) writeHtml(writer io.Writer) {
+	s.mutex.Lock()
+	lastError := s.lastError
+	numDropped := s.numDropped
+	numShipped := s.numShipped
+	s.mutex.Unlock()
+	fmt.Fprintf(writer, "Shipping logs to: %s, %d lines shipped", s.url,
+		numShipped)
+	if s.spool != nil {
+		numRecords, size, numDroppedFromSpool := s.spool.getStats()
+		if numRecords > 0 {
+			fmt.Fprintf(writer, ", %d lines (%s) waiting", numRecords,
+				format.FormatBytes(size))
+		}
+		numDropped += numDroppedFromSpool
+	}
+	if numDropped > 0 {
+		fmt.Fprintf(writer, ", <font color=\"red\">%d lines dropped</font>",
+			numDropped)
+	}
+	if lastError != nil {
+		fmt.Fprintf(writer, ", <font color=\"red\">error: %s</font>",
+			html.EscapeString(lastError.Error()))
+	}
+	fmt.Fprintln(writer, "<br>")
+}
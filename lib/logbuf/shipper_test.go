@@ -0,0 +1,78 @@
+package logbuf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type testSender struct {
+	err     error
+	records []shipRecord
+}
+
+func (sender *testSender) send(records []shipRecord) error {
+	if sender.err != nil {
+		return sender.err
+	}
+	sender.records = append(sender.records, records...)
+	return nil
+}
+
+func TestShipperDrainSpool(t *testing.T) {
+	spool, err := openSpool(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &testSender{err: errors.New("unavailable")}
+	shipper := &shipperType{sender: sender, spool: spool}
+	for batch := 0; batch < 3; batch++ {
+		records := make([]shipRecord, 4)
+		for index := range records {
+			records[index].Message = fmt.Sprintf("message %d", batch*4+index)
+		}
+		if err := shipper.send(records); err == nil {
+			t.Fatal("send did not fail")
+		}
+		shipper.spoolBatch(records)
+	}
+	if err := shipper.drainSpool(); err == nil {
+		t.Fatal("drain did not fail")
+	}
+	if spool.empty() {
+		t.Fatal("spool drained while sender failing")
+	}
+	sender.err = nil
+	if err := shipper.drainSpool(); err != nil {
+		t.Fatal(err)
+	}
+	if !spool.empty() {
+		t.Error("spool not drained")
+	}
+	messages := make([]string, 0, len(sender.records))
+	for _, record := range sender.records {
+		messages = append(messages, record.Message)
+	}
+	checkMessages(t, messages, 0, 12)
+	if shipper.numShipped != 12 || shipper.lastError != nil {
+		t.Errorf("shipped: %d, last error: %v",
+			shipper.numShipped, shipper.lastError)
+	}
+}
+
+func TestStripTimestamp(t *testing.T) {
+	tests := map[string]string{
+		"2024/01/02 03:04:05 message":        "message",
+		"2024/01/02 03:04:05.123456 message": "message",
+		"2024/01/02 03:04:05":                "2024/01/02 03:04:05",
+		"2024/01/02 03:04:05.12x message":    "2024/01/02 03:04:05.12x message",
+		"not a timestamp at all, message":    "not a timestamp at all, message",
+		"short":                              "short",
+	}
+	for line, expected := range tests {
+		if stripped := stripTimestamp(line); stripped != expected {
+			t.Errorf("\"%s\": expected: \"%s\", got: \"%s\"",
+				line, expected, stripped)
+		}
+	}
+}
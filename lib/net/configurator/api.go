@@ -9,10 +9,21 @@ import (
 	hyper_proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
 )
 
+const (
+	FormatDebian          = "debian"
+	FormatNetplan         = "netplan"
+	FormatSystemdNetworkd = "systemd-networkd"
+
+	// FormatTagName is the name of the machine tag which may be used to
+	// specify the network configuration format in the topology.
+	FormatTagName = "NetworkConfigFormat"
+)
+
 type bondedInterfaceType struct {
-	name   string // "bond0.VlanId" interface name.
-	ipAddr net.IP
-	subnet *hyper_proto.Subnet
+	name     string // "bond0.VlanId" interface name.
+	ipAddr   net.IP
+	ipv6Addr net.IP
+	subnet   *hyper_proto.Subnet
 }
 
 type bridgeOnlyInterfaceType struct {
@@ -22,6 +33,7 @@ type bridgeOnlyInterfaceType struct {
 
 type normalInterfaceType struct {
 	ipAddr       net.IP
+	ipv6Addr     net.IP
 	netInterface net.Interface
 	subnet       *hyper_proto.Subnet
 }
@@ -36,11 +48,33 @@ type NetworkConfig struct {
 	vlanRawDevice        string
 }
 
+// CheckFormat returns an error if format is not a known network configuration
+// format.
+func CheckFormat(format string) error {
+	return checkFormat(format)
+}
+
+// DetectFormat returns the format of the network configuration previously
+// written to the root file-system at rootDir, or else the network
+// configuration format supported by the OS.
+func DetectFormat(rootDir string) string {
+	return detectFormat(rootDir)
+}
+
 func FindMatchingSubnet(subnets []*hyper_proto.Subnet,
 	ipAddr net.IP) *hyper_proto.Subnet {
 	return findMatchingSubnet(subnets, ipAddr)
 }
 
+// GetFormat returns the network configuration format for a machine. The
+// NetworkConfigFormat tag takes precedence, otherwise the format is detected
+// from the root file-system at rootDir. If rootDir is empty, the Debian format
+// is returned.
+func GetFormat(info fm_proto.GetMachineInfoResponse,
+	rootDir string) (string, error) {
+	return getFormat(info, rootDir)
+}
+
 func GetNetworkEntries(
 	info fm_proto.GetMachineInfoResponse) []fm_proto.NetworkEntry {
 	return getNetworkEntries(info)
@@ -52,23 +86,62 @@ func Compute(machineInfo fm_proto.GetMachineInfoResponse,
 	return compute(machineInfo, interfaces, logger)
 }
 
+func (netconf *NetworkConfig) Print(writer io.Writer, format string) error {
+	return netconf.print(writer, format)
+}
+
 func (netconf *NetworkConfig) PrintDebian(writer io.Writer) error {
 	return netconf.printDebian(writer)
 }
 
-func (netconf *NetworkConfig) Update(rootDir string,
+func (netconf *NetworkConfig) PrintNetplan(writer io.Writer) error {
+	return netconf.printNetplan(writer)
+}
+
+func (netconf *NetworkConfig) PrintSystemdNetworkd(writer io.Writer) error {
+	return netconf.printSystemdNetworkd(writer)
+}
+
+// Update will update the network configuration in the specified format and
+// the DNS resolver configuration, applying any changes. If format is empty,
+// it is detected from the root file-system. It returns true if the
+// configuration was changed.
+func (netconf *NetworkConfig) Update(rootDir, format string,
 	logger log.DebugLogger) (bool, error) {
-	return netconf.update(rootDir, logger)
+	return netconf.update(rootDir, format, logger)
 }
 
 func (netconf *NetworkConfig) UpdateDebian(rootDir string) (bool, error) {
 	return netconf.updateDebian(rootDir)
 }
 
+func (netconf *NetworkConfig) UpdateNetplan(rootDir string) (bool, error) {
+	return netconf.updateNetplan(rootDir)
+}
+
+func (netconf *NetworkConfig) UpdateSystemdNetworkd(
+	rootDir string) (bool, error) {
+	return netconf.updateSystemdNetworkd(rootDir)
+}
+
+func (netconf *NetworkConfig) Write(rootDir, format string) error {
+	return netconf.write(rootDir, format)
+}
+
 func (netconf *NetworkConfig) WriteDebian(rootDir string) error {
 	return netconf.writeDebian(rootDir)
 }
 
+func (netconf *NetworkConfig) WriteNetplan(rootDir string) error {
+	return netconf.writeNetplan(rootDir)
+}
+
+// WriteSystemdNetworkd will write the systemd-networkd configuration files
+// and enable the systemd-networkd service.
+func (netconf *NetworkConfig) WriteSystemdNetworkd(rootDir string) error {
+	return netconf.writeSystemdNetworkd(rootDir)
+}
+
 func PrintResolvConf(writer io.Writer, subnet *hyper_proto.Subnet) error {
 	return printResolvConf(writer, subnet)
 }
@@ -11,6 +11,25 @@ import (
 	hyper_proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
 )
 
+// checkIpv6Address returns the IPv6 address for the network entry, or nil if
+// there is none. An error is returned if the address is not in the subnet.
+func checkIpv6Address(networkEntry fm_proto.NetworkEntry,
+	subnet *hyper_proto.Subnet) (net.IP, error) {
+	ipAddr := networkEntry.HostIpv6Address
+	if len(ipAddr) < 1 {
+		return nil, nil
+	}
+	if len(subnet.Ipv6Gateway) < 1 {
+		return nil, fmt.Errorf("subnet: %s has no IPv6 prefix for: %s",
+			subnet.Id, ipAddr)
+	}
+	subnetMask := net.IPMask(subnet.Ipv6Mask)
+	if !ipAddr.Mask(subnetMask).Equal(subnet.Ipv6Gateway.Mask(subnetMask)) {
+		return nil, fmt.Errorf("%s is not in subnet: %s", ipAddr, subnet.Id)
+	}
+	return ipAddr, nil
+}
+
 func findMatchingSubnet(subnets []*hyper_proto.Subnet,
 	ipAddr net.IP) *hyper_proto.Subnet {
 	for _, subnet := range subnets {
@@ -44,13 +63,14 @@ func getNetworkEntries(
 	return networkEntries
 }
 
-func (netconf *NetworkConfig) addBondedInterface(name string, ipAddr net.IP,
-	subnet *hyper_proto.Subnet) {
+func (netconf *NetworkConfig) addBondedInterface(name string,
+	ipAddr, ipv6Addr net.IP, subnet *hyper_proto.Subnet) {
 	netconf.bondedInterfaces = append(netconf.bondedInterfaces,
 		bondedInterfaceType{
-			name:   name,
-			ipAddr: ipAddr,
-			subnet: subnet,
+			name:     name,
+			ipAddr:   ipAddr,
+			ipv6Addr: ipv6Addr,
+			subnet:   subnet,
 		})
 }
 
@@ -64,11 +84,12 @@ func (netconf *NetworkConfig) addBridgeOnlyInterface(iface net.Interface,
 }
 
 func (netconf *NetworkConfig) addNormalInterface(iface net.Interface,
-	ipAddr net.IP, subnet *hyper_proto.Subnet) {
+	ipAddr, ipv6Addr net.IP, subnet *hyper_proto.Subnet) {
 	netconf.normalInterfaces = append(netconf.normalInterfaces,
 		normalInterfaceType{
 			netInterface: iface,
 			ipAddr:       ipAddr,
+			ipv6Addr:     ipv6Addr,
 			subnet:       subnet,
 		})
 }
@@ -149,7 +170,12 @@ func compute(machineInfo fm_proto.GetMachineInfoResponse,
 		}
 		usedSubnets[subnet] = struct{}{}
 		normalInterfaceIndex++
-		netconf.addNormalInterface(iface, networkEntry.HostIpAddress, subnet)
+		ipv6Addr, err := checkIpv6Address(networkEntry, subnet)
+		if err != nil {
+			return nil, err
+		}
+		netconf.addNormalInterface(iface, networkEntry.HostIpAddress,
+			ipv6Addr, subnet)
 		delete(interfaces, iface.Name)
 		if subnet == preferredSubnet {
 			netconf.DefaultSubnet = subnet
@@ -188,8 +214,12 @@ func compute(machineInfo fm_proto.GetMachineInfoResponse,
 			usedSubnets[subnet] = struct{}{}
 			entryName := fmt.Sprintf("%s.%d",
 				netconf.vlanRawDevice, subnet.VlanId)
+			ipv6Addr, err := checkIpv6Address(networkEntry, subnet)
+			if err != nil {
+				return nil, err
+			}
 			netconf.addBondedInterface(entryName, networkEntry.HostIpAddress,
-				subnet)
+				ipv6Addr, subnet)
 			if subnet == preferredSubnet {
 				netconf.DefaultSubnet = subnet
 			} else if netconf.DefaultSubnet == nil {
@@ -0,0 +1,149 @@
+package configurator
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+	fm_proto "github.com/Cloud-Foundations/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+type testTopology struct {
+	name        string
+	machineInfo fm_proto.GetMachineInfoResponse
+	interfaces  map[string]net.Interface
+}
+
+var (
+	testInterfaces = map[string]net.Interface{
+		"eth0": {
+			Flags:        net.FlagUp,
+			HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 0},
+			Name:         "eth0",
+		},
+		"eth1": {
+			Flags:        net.FlagUp,
+			HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+			Name:         "eth1",
+		},
+		"eth2": {
+			HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+			Name:         "eth2",
+		},
+	}
+	testSubnets = []*hyper_proto.Subnet{
+		{
+			DomainName:        "example.com",
+			DomainNameServers: []net.IP{{10, 0, 0, 53}},
+			Id:                "default",
+			IpGateway:         net.IP{10, 0, 10, 1},
+			IpMask:            net.IP{255, 255, 255, 0},
+			Ipv6Gateway:       net.ParseIP("fd00:10::1"),
+			Ipv6Mask:          net.IP(net.CIDRMask(64, 128)),
+			Manage:            true,
+			VlanId:            10,
+		},
+		{
+			DomainNameServers: []net.IP{{10, 0, 20, 53}},
+			Id:                "storage",
+			IpGateway:         net.IP{10, 0, 20, 1},
+			IpMask:            net.IP{255, 255, 255, 0},
+			VlanId:            20,
+		},
+		{
+			Id:        "vms",
+			IpGateway: net.IP{10, 0, 30, 1},
+			IpMask:    net.IP{255, 255, 255, 0},
+			Manage:    true,
+			VlanId:    30,
+		},
+	}
+	// testTopologies are machines with bonded VLAN trunk, bridge-only and
+	// single interface VLAN trunk network topologies.
+	testTopologies = []testTopology{
+		{
+			name: "bonded",
+			machineInfo: fm_proto.GetMachineInfoResponse{
+				Machine: fm_proto.Machine{
+					NetworkEntry: fm_proto.NetworkEntry{
+						HostIpAddress:   net.IP{10, 0, 10, 5},
+						HostIpv6Address: net.ParseIP("fd00:10::5"),
+					},
+					SecondaryNetworkEntries: []fm_proto.NetworkEntry{
+						{HostIpAddress: net.IP{10, 0, 20, 5}},
+					},
+				},
+				Subnets: testSubnets,
+			},
+			interfaces: testInterfaces,
+		},
+		{
+			name: "bridge-only",
+			machineInfo: fm_proto.GetMachineInfoResponse{
+				Machine: fm_proto.Machine{
+					NetworkEntry: fm_proto.NetworkEntry{
+						HostIpAddress: net.IP{10, 0, 10, 5},
+						HostMacAddress: fm_proto.HardwareAddr(
+							testInterfaces["eth0"].HardwareAddr),
+					},
+					SecondaryNetworkEntries: []fm_proto.NetworkEntry{
+						{
+							HostMacAddress: fm_proto.HardwareAddr(
+								testInterfaces["eth1"].HardwareAddr),
+							SubnetId: "vms",
+						},
+					},
+				},
+				Subnets: testSubnets,
+			},
+			interfaces: testInterfaces,
+		},
+		{
+			name: "vlan",
+			machineInfo: fm_proto.GetMachineInfoResponse{
+				Machine: fm_proto.Machine{
+					GatewaySubnetId: "storage",
+					NetworkEntry: fm_proto.NetworkEntry{
+						HostIpAddress: net.IP{10, 0, 20, 5},
+						HostMacAddress: fm_proto.HardwareAddr(
+							testInterfaces["eth0"].HardwareAddr),
+						VlanTrunk: true,
+					},
+					SecondaryNetworkEntries: []fm_proto.NetworkEntry{
+						{HostIpAddress: net.IP{10, 0, 10, 5}},
+					},
+				},
+				Subnets: testSubnets,
+			},
+			interfaces: map[string]net.Interface{
+				"eth0": testInterfaces["eth0"],
+				"eth2": testInterfaces["eth2"],
+			},
+		},
+	}
+)
+
+func computeTestTopology(t *testing.T,
+	topology testTopology) *NetworkConfig {
+	netconf, err := compute(topology.machineInfo, topology.interfaces,
+		testlogger.New(t))
+	if err != nil {
+		t.Fatalf("%s: %s", topology.name, err)
+	}
+	return netconf
+}
+
+// checkGolden compares the output with the golden file in testdata.
+func checkGolden(t *testing.T, filename string, got []byte) {
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("%s: expected:\n%s\ngot:\n%s", filename, expected, got)
+	}
+}
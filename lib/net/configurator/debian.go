@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -38,6 +39,17 @@ func (netconf *NetworkConfig) printDebian(writer io.Writer) error {
 				iface.netInterface.HardwareAddr)
 			fmt.Fprintf(writer, "\tbridge_ports %s\n", iface.netInterface.Name)
 		}
+		if len(iface.ipv6Addr) > 0 {
+			prefixLength, _ := net.IPMask(iface.subnet.Ipv6Mask).Size()
+			fmt.Fprintln(writer)
+			fmt.Fprintf(writer, "iface %s inet6 static\n", name)
+			fmt.Fprintf(writer, "\taddress      %s\n", iface.ipv6Addr)
+			fmt.Fprintf(writer, "\tnetmask      %d\n", prefixLength)
+			if iface.subnet.IpGateway.Equal(netconf.DefaultSubnet.IpGateway) {
+				fmt.Fprintf(writer, "\tgateway      %s\n",
+					iface.subnet.Ipv6Gateway)
+			}
+		}
 		configuredInterfaces[name] = struct{}{}
 	}
 	for _, iface := range netconf.bridgeOnlyInterfaces {
@@ -76,6 +88,17 @@ func (netconf *NetworkConfig) printDebian(writer io.Writer) error {
 			if iface.subnet.IpGateway.Equal(netconf.DefaultSubnet.IpGateway) {
 				fmt.Fprintf(writer, "\tgateway %s\n", iface.subnet.IpGateway)
 			}
+			if len(iface.ipv6Addr) > 0 {
+				prefixLength, _ := net.IPMask(iface.subnet.Ipv6Mask).Size()
+				fmt.Fprintln(writer)
+				fmt.Fprintf(writer, "iface %s inet6 static\n", iface.name)
+				fmt.Fprintf(writer, "\taddress %s\n", iface.ipv6Addr)
+				fmt.Fprintf(writer, "\tnetmask %d\n", prefixLength)
+				if iface.subnet.IpGateway.Equal(netconf.DefaultSubnet.IpGateway) {
+					fmt.Fprintf(writer, "\tgateway %s\n",
+						iface.subnet.Ipv6Gateway)
+				}
+			}
 		}
 		for _, vlanId := range netconf.bridges {
 			fmt.Fprintln(writer)
@@ -0,0 +1,167 @@
+package configurator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	fm_proto "github.com/Cloud-Foundations/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+func checkFormat(format string) error {
+	switch format {
+	case FormatDebian, FormatNetplan, FormatSystemdNetworkd:
+		return nil
+	}
+	return fmt.Errorf("unknown network configuration format: \"%s\"", format)
+}
+
+// detectFormat returns the format of the network configuration previously
+// written to the root file-system, so that updates do not switch formats.
+// Otherwise it looks for the network configuration tools. Netplan is preferred
+// since it generates the configuration for systemd-networkd, then ifupdown for
+// compatibility with existing machines.
+func detectFormat(rootDir string) string {
+	if format := detectWrittenFormat(rootDir); format != "" {
+		return format
+	}
+	if fileExists(rootDir, "usr", "sbin", "netplan") ||
+		fileExists(rootDir, "usr", "bin", "netplan") {
+		return FormatNetplan
+	}
+	if fileExists(rootDir, "sbin", "ifup") ||
+		fileExists(rootDir, "usr", "sbin", "ifup") {
+		return FormatDebian
+	}
+	if fileExists(rootDir, "lib", "systemd", "systemd-networkd") ||
+		fileExists(rootDir, "usr", "lib", "systemd", "systemd-networkd") {
+		return FormatSystemdNetworkd
+	}
+	return FormatDebian
+}
+
+// detectWrittenFormat returns the format of the network configuration files
+// created by SmallStack in the root file-system, or "" if there are none.
+func detectWrittenFormat(rootDir string) string {
+	if isSmallStackFile(netplanFilename(rootDir)) {
+		return FormatNetplan
+	}
+	filenames, _ := filepath.Glob(
+		filepath.Join(networkdDirectory(rootDir), networkdFilePrefix+"*"))
+	if len(filenames) > 0 {
+		return FormatSystemdNetworkd
+	}
+	if isSmallStackFile(filepath.Join(rootDir, "etc", "network",
+		"interfaces")) {
+		return FormatDebian
+	}
+	return ""
+}
+
+func fileExists(rootDir string, elements ...string) bool {
+	_, err := os.Stat(filepath.Join(rootDir, filepath.Join(elements...)))
+	return err == nil
+}
+
+// isSmallStackFile returns true if the first line of the file says it was
+// created by SmallStack.
+func isSmallStackFile(filename string) bool {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	firstLine, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false
+	}
+	return strings.Contains(firstLine, "created by SmallStack")
+}
+
+func getFormat(info fm_proto.GetMachineInfoResponse,
+	rootDir string) (string, error) {
+	if format := info.Machine.Tags[FormatTagName]; format != "" {
+		if err := checkFormat(format); err != nil {
+			return "", err
+		}
+		return format, nil
+	}
+	if rootDir == "" {
+		return FormatDebian, nil
+	}
+	return detectFormat(rootDir), nil
+}
+
+func (netconf *NetworkConfig) print(writer io.Writer, format string) error {
+	switch format {
+	case FormatDebian:
+		return netconf.printDebian(writer)
+	case FormatNetplan:
+		return netconf.printNetplan(writer)
+	case FormatSystemdNetworkd:
+		return netconf.printSystemdNetworkd(writer)
+	}
+	return checkFormat(format)
+}
+
+func (netconf *NetworkConfig) updateFormat(rootDir,
+	format string) (bool, error) {
+	switch format {
+	case FormatDebian:
+		return netconf.updateDebian(rootDir)
+	case FormatNetplan:
+		return netconf.updateNetplan(rootDir)
+	case FormatSystemdNetworkd:
+		return netconf.updateSystemdNetworkd(rootDir)
+	}
+	return false, checkFormat(format)
+}
+
+func (netconf *NetworkConfig) write(rootDir, format string) error {
+	switch format {
+	case FormatDebian:
+		return netconf.writeDebian(rootDir)
+	case FormatNetplan:
+		return netconf.writeNetplan(rootDir)
+	case FormatSystemdNetworkd:
+		return netconf.writeSystemdNetworkd(rootDir)
+	}
+	return checkFormat(format)
+}
+
+func ipv4Prefix(ipAddr net.IP, subnet *hyper_proto.Subnet) string {
+	prefixLength, _ := net.IPMask(subnet.IpMask.To4()).Size()
+	return fmt.Sprintf("%s/%d", ipAddr, prefixLength)
+}
+
+func ipv6Prefix(ipAddr net.IP, subnet *hyper_proto.Subnet) string {
+	prefixLength, _ := net.IPMask(subnet.Ipv6Mask).Size()
+	return fmt.Sprintf("%s/%d", ipAddr, prefixLength)
+}
+
+// isDefaultSubnet returns true if the default route should be via the
+// gateway for the subnet.
+func (netconf *NetworkConfig) isDefaultSubnet(
+	subnet *hyper_proto.Subnet) bool {
+	return subnet.IpGateway.Equal(netconf.DefaultSubnet.IpGateway)
+}
+
+// vlanInterfaceNames returns the names of the VLAN interfaces on the VLAN
+// trunk device.
+func (netconf *NetworkConfig) vlanInterfaceNames() []string {
+	names := make([]string, 0,
+		len(netconf.bondedInterfaces)+len(netconf.bridges))
+	for _, iface := range netconf.bondedInterfaces {
+		names = append(names, iface.name)
+	}
+	for _, vlanId := range netconf.bridges {
+		names = append(names,
+			fmt.Sprintf("%s.%d", netconf.vlanRawDevice, vlanId))
+	}
+	return names
+}
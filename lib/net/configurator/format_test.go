@@ -0,0 +1,136 @@
+package configurator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/lib/log/testlogger"
+)
+
+func TestDetectFormat(t *testing.T) {
+	const smallStack = "# created by SmallStack installer\n"
+	tests := []struct {
+		name     string
+		files    map[string]string // Key: pathname, value: contents.
+		expected string
+	}{
+		{
+			name:     "empty",
+			expected: FormatDebian,
+		},
+		{
+			name: "fresh install with netplan",
+			files: map[string]string{
+				"sbin/ifup":        "",
+				"usr/sbin/netplan": "",
+			},
+			expected: FormatNetplan,
+		},
+		{
+			name:     "fresh install with ifupdown",
+			files:    map[string]string{"sbin/ifup": ""},
+			expected: FormatDebian,
+		},
+		{
+			name: "fresh install with systemd-networkd",
+			files: map[string]string{
+				"lib/systemd/systemd-networkd": "",
+			},
+			expected: FormatSystemdNetworkd,
+		},
+		{
+			name: "ifupdown with netplan installed",
+			files: map[string]string{
+				"etc/network/interfaces": smallStack,
+				"sbin/ifup":              "",
+				"usr/sbin/netplan":       "",
+			},
+			expected: FormatDebian,
+		},
+		{
+			name: "foreign ifupdown with netplan installed",
+			files: map[string]string{
+				"etc/network/interfaces": "auto lo\n",
+				"sbin/ifup":              "",
+				"usr/sbin/netplan":       "",
+			},
+			expected: FormatNetplan,
+		},
+		{
+			name: "systemd-networkd with netplan installed",
+			files: map[string]string{
+				"etc/systemd/network/10-smallstack-eth0.network": smallStack,
+				"usr/sbin/netplan": "",
+			},
+			expected: FormatSystemdNetworkd,
+		},
+		{
+			name: "netplan with ifupdown installed",
+			files: map[string]string{
+				"etc/netplan/10-smallstack.yaml": smallStack,
+				"sbin/ifup":                      "",
+			},
+			expected: FormatNetplan,
+		},
+	}
+	for _, test := range tests {
+		rootDir := t.TempDir()
+		for pathname, contents := range test.files {
+			pathname = filepath.Join(rootDir, pathname)
+			if err := os.MkdirAll(filepath.Dir(pathname), 0755); err != nil {
+				t.Fatal(err)
+			}
+			err := ioutil.WriteFile(pathname, []byte(contents), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := detectFormat(rootDir); got != test.expected {
+			t.Errorf("%s: expected: %s, got: %s",
+				test.name, test.expected, got)
+		}
+	}
+}
+
+// TestUpdateDebianWithNetplanInstalled checks that updating an existing
+// ifupdown configuration keeps using ifupdown when netplan is installed.
+func TestUpdateDebianWithNetplanInstalled(t *testing.T) {
+	rootDir := t.TempDir()
+	for _, dirname := range []string{"etc/network", "sbin", "usr/sbin"} {
+		err := os.MkdirAll(filepath.Join(rootDir, dirname), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, filename := range []string{"sbin/ifup", "usr/sbin/netplan"} {
+		err := ioutil.WriteFile(filepath.Join(rootDir, filename), nil, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	netconf := computeTestTopology(t, testTopologies[0])
+	if err := netconf.writeDebian(rootDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeResolvConf(rootDir, netconf.DefaultSubnet); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := netconf.update(rootDir, "", testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("unchanged configuration reported as changed")
+	}
+	if _, err := os.Stat(netplanFilename(rootDir)); err == nil {
+		t.Error("netplan configuration written")
+	}
+	data, err := ioutil.ReadFile(
+		filepath.Join(rootDir, "etc", "network", "interfaces"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "bonded.debian", data)
+}
@@ -0,0 +1,263 @@
+package configurator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	hyper_proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+var (
+	unaddressedNetplanLines = []string{
+		"dhcp4: false",
+		"dhcp6: false",
+		"link-local: []",
+	}
+	unconfiguredNetplanLines = []string{"dhcp4: false"}
+)
+
+type netplanInterface struct {
+	name  string
+	lines []string // Relative to the interface.
+}
+
+type netplanConfig struct {
+	ethernets []netplanInterface
+	bonds     []netplanInterface
+	vlans     []netplanInterface
+	bridges   []netplanInterface
+}
+
+func netplanFilename(rootDir string) string {
+	return filepath.Join(rootDir, "etc", "netplan", "10-smallstack.yaml")
+}
+
+func netplanBridgeLines(port string, hwAddr net.HardwareAddr) []string {
+	lines := []string{"interfaces: [" + port + "]"}
+	if len(hwAddr) > 0 {
+		lines = append(lines, fmt.Sprintf("macaddress: \"%s\"", hwAddr))
+	}
+	return append(lines, "parameters:", "  stp: false")
+}
+
+func writeNetplanSection(writer io.Writer, section string,
+	interfaces []netplanInterface) {
+	if len(interfaces) < 1 {
+		return
+	}
+	fmt.Fprintf(writer, "  %s:\n", section)
+	for _, iface := range interfaces {
+		fmt.Fprintf(writer, "    %s:\n", iface.name)
+		for _, line := range iface.lines {
+			fmt.Fprintf(writer, "      %s\n", line)
+		}
+	}
+}
+
+func (netconf *NetworkConfig) makeNetplanConfig() netplanConfig {
+	var config netplanConfig
+	configuredInterfaces := make(map[string]struct{})
+	for _, iface := range netconf.normalInterfaces {
+		name := iface.netInterface.Name
+		lines := netconf.netplanAddressLines(iface.ipAddr, iface.ipv6Addr,
+			iface.subnet)
+		if iface.subnet.Manage {
+			bridge := fmt.Sprintf("br%d", iface.subnet.VlanId)
+			config.bridges = append(config.bridges, netplanInterface{
+				name: bridge,
+				lines: append(
+					netplanBridgeLines(name, iface.netInterface.HardwareAddr),
+					lines...),
+			})
+			config.ethernets = append(config.ethernets,
+				netplanInterface{name: name, lines: unconfiguredNetplanLines})
+		} else {
+			config.ethernets = append(config.ethernets,
+				netplanInterface{name: name, lines: lines})
+		}
+		configuredInterfaces[name] = struct{}{}
+	}
+	for _, iface := range netconf.bridgeOnlyInterfaces {
+		name := iface.netInterface.Name
+		config.bridges = append(config.bridges, netplanInterface{
+			name: "br@" + iface.subnetId,
+			lines: append(
+				netplanBridgeLines(name, iface.netInterface.HardwareAddr),
+				unaddressedNetplanLines...),
+		})
+		config.ethernets = append(config.ethernets,
+			netplanInterface{name: name, lines: unconfiguredNetplanLines})
+	}
+	if netconf.vlanRawDevice == "" {
+		return config
+	}
+	if _, ok := configuredInterfaces[netconf.vlanRawDevice]; !ok {
+		if len(netconf.bondSlaves) > 1 {
+			for _, name := range netconf.bondSlaves {
+				config.ethernets = append(config.ethernets, netplanInterface{
+					name:  name,
+					lines: unconfiguredNetplanLines,
+				})
+			}
+			lines := []string{
+				"interfaces: [" + strings.Join(netconf.bondSlaves, ", ") + "]",
+				"mtu: 9000",
+				"parameters:",
+				"  mode: 802.3ad",
+				"  transmit-hash-policy: layer3+4",
+			}
+			config.bonds = append(config.bonds, netplanInterface{
+				name:  netconf.vlanRawDevice,
+				lines: append(lines, unaddressedNetplanLines...),
+			})
+		} else {
+			config.ethernets = append(config.ethernets, netplanInterface{
+				name:  netconf.vlanRawDevice,
+				lines: unaddressedNetplanLines,
+			})
+		}
+	}
+	for _, iface := range netconf.bondedInterfaces {
+		config.vlans = append(config.vlans, netplanInterface{
+			name: iface.name,
+			lines: append(
+				netconf.netplanVlanLines(iface.subnet.VlanId),
+				netconf.netplanAddressLines(iface.ipAddr, iface.ipv6Addr,
+					iface.subnet)...),
+		})
+	}
+	for _, vlanId := range netconf.bridges {
+		vlanName := fmt.Sprintf("%s.%d", netconf.vlanRawDevice, vlanId)
+		config.vlans = append(config.vlans, netplanInterface{
+			name: vlanName,
+			lines: append(netconf.netplanVlanLines(vlanId),
+				unconfiguredNetplanLines...),
+		})
+		config.bridges = append(config.bridges, netplanInterface{
+			name: fmt.Sprintf("br%d", vlanId),
+			lines: append(netplanBridgeLines(vlanName, nil),
+				unaddressedNetplanLines...),
+		})
+	}
+	return config
+}
+
+// netplanAddressLines returns the lines for the addresses, routes and name
+// servers of an interface.
+func (netconf *NetworkConfig) netplanAddressLines(ipAddr, ipv6Addr net.IP,
+	subnet *hyper_proto.Subnet) []string {
+	lines := []string{"addresses:", "  - " + ipv4Prefix(ipAddr, subnet)}
+	if len(ipv6Addr) > 0 {
+		lines = append(lines, "  - "+ipv6Prefix(ipv6Addr, subnet))
+	}
+	isDefault := netconf.isDefaultSubnet(subnet)
+	if isDefault {
+		lines = append(lines, "routes:",
+			"  - to: default",
+			"    via: "+subnet.IpGateway.String())
+		if len(ipv6Addr) > 0 && len(subnet.Ipv6Gateway) > 0 {
+			lines = append(lines,
+				"  - to: \"::/0\"",
+				"    via: \""+subnet.Ipv6Gateway.String()+"\"")
+		}
+	}
+	if isDefault &&
+		(len(subnet.DomainNameServers) > 0 || subnet.DomainName != "") {
+		lines = append(lines, "nameservers:")
+		if len(subnet.DomainNameServers) > 0 {
+			lines = append(lines, "  addresses:")
+			for _, nameserver := range subnet.DomainNameServers {
+				lines = append(lines, "    - \""+nameserver.String()+"\"")
+			}
+		}
+		if subnet.DomainName != "" {
+			lines = append(lines, "  search: ["+subnet.DomainName+"]")
+		}
+	}
+	return lines
+}
+
+func (netconf *NetworkConfig) netplanVlanLines(vlanId uint) []string {
+	return []string{
+		fmt.Sprintf("id: %d", vlanId),
+		"link: " + netconf.vlanRawDevice,
+	}
+}
+
+func (netconf *NetworkConfig) printNetplan(writer io.Writer) error {
+	config := netconf.makeNetplanConfig()
+	fmt.Fprintln(writer,
+		"# /etc/netplan/10-smallstack.yaml -- created by SmallStack installer")
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "network:")
+	fmt.Fprintln(writer, "  version: 2")
+	fmt.Fprintln(writer, "  renderer: networkd")
+	writeNetplanSection(writer, "ethernets", config.ethernets)
+	writeNetplanSection(writer, "bonds", config.bonds)
+	writeNetplanSection(writer, "vlans", config.vlans)
+	writeNetplanSection(writer, "bridges", config.bridges)
+	return nil
+}
+
+func (netconf *NetworkConfig) updateNetplan(rootDir string) (bool, error) {
+	buffer := &bytes.Buffer{}
+	if err := netconf.printNetplan(buffer); err != nil {
+		return false, err
+	}
+	filename := netplanFilename(rootDir)
+	// Check if it was written by me.
+	if file, err := os.Open(filename); err != nil {
+		return false, err
+	} else {
+		defer file.Close()
+		firstLine, err := bufio.NewReader(file).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if !strings.Contains(firstLine, "created by SmallStack") {
+			return false, fmt.Errorf("%s not created by SmallStack", filename)
+		}
+	}
+	if same, err := fsutil.CompareFile(buffer.Bytes(), filename); err != nil {
+		return false, err
+	} else if same {
+		return false, nil
+	}
+	// Netplan complains if its configuration files are readable by others.
+	err := writeFile(buffer.Bytes(), filename, fsutil.PrivateFilePerms)
+	if err != nil {
+		return false, err
+	}
+	cmd := exec.Command("netplan", "apply")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (netconf *NetworkConfig) writeNetplan(rootDir string) error {
+	filename := netplanFilename(rootDir)
+	if err := os.MkdirAll(filepath.Dir(filename), fsutil.DirPerms); err != nil {
+		return err
+	}
+	file, err := fsutil.CreateRenamingWriter(filename, fsutil.PrivateFilePerms)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	if err := netconf.printNetplan(writer); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
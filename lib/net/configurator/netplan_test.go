@@ -0,0 +1,33 @@
+package configurator
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestPrintNetplan(t *testing.T) {
+	for _, topology := range testTopologies {
+		netconf := computeTestTopology(t, topology)
+		buffer := &bytes.Buffer{}
+		if err := netconf.printNetplan(buffer); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, topology.name+".netplan", buffer.Bytes())
+	}
+}
+
+func TestNetplanAddressLinesDefaultSubnet(t *testing.T) {
+	netconf := computeTestTopology(t, testTopologies[0])
+	// A distinct subnet with the same gateway is also the default subnet.
+	subnet := *netconf.DefaultSubnet
+	lines := strings.Join(
+		netconf.netplanAddressLines(net.IP{10, 0, 10, 6}, nil, &subnet), "\n")
+	for _, line := range []string{"via: 10.0.10.1", "nameservers:",
+		"search: [example.com]"} {
+		if !strings.Contains(lines, line) {
+			t.Errorf("missing line: %s in:\n%s", line, lines)
+		}
+	}
+}
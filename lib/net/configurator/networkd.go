@@ -0,0 +1,293 @@
+package configurator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/Cloud-Foundations/Dominator/lib/fsutil"
+	hyper_proto "github.com/Cloud-Foundations/Dominator/proto/hypervisor"
+)
+
+const networkdFilePrefix = "10-smallstack-"
+
+var unaddressedLinkLines = []string{
+	"LinkLocalAddressing=no",
+	"ConfigureWithoutCarrier=yes",
+}
+
+type networkdFiles map[string]*bytes.Buffer // Key: filename.
+
+func networkdDirectory(rootDir string) string {
+	return filepath.Join(rootDir, "etc", "systemd", "network")
+}
+
+func (files networkdFiles) create(filename string) io.Writer {
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer,
+		"# /etc/systemd/network/%s -- created by SmallStack installer\n",
+		filename)
+	fmt.Fprintln(buffer)
+	files[filename] = buffer
+	return buffer
+}
+
+func (files networkdFiles) addNetdev(name, kind string, lines ...string) {
+	writer := files.create(networkdFilePrefix + name + ".netdev")
+	fmt.Fprintln(writer, "[NetDev]")
+	fmt.Fprintf(writer, "Name=%s\n", name)
+	fmt.Fprintf(writer, "Kind=%s\n", kind)
+	for _, line := range lines {
+		fmt.Fprintln(writer, line)
+	}
+}
+
+func (files networkdFiles) addNetwork(name string, lines ...string) {
+	writer := files.create(networkdFilePrefix + name + ".network")
+	fmt.Fprintln(writer, "[Match]")
+	fmt.Fprintf(writer, "Name=%s\n", name)
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "[Network]")
+	for _, line := range lines {
+		fmt.Fprintln(writer, line)
+	}
+}
+
+func (files networkdFiles) addVlan(name string, vlanId uint) {
+	files.addNetdev(name, "vlan", "", "[VLAN]", fmt.Sprintf("Id=%d", vlanId))
+}
+
+func (files networkdFiles) filenames() []string {
+	filenames := make([]string, 0, len(files))
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+
+// enableSystemdNetworkd enables the systemd-networkd service in the root
+// file-system, in the same way as "systemctl enable" would.
+func enableSystemdNetworkd(rootDir string) error {
+	for _, unitDir := range []string{"/lib/systemd/system",
+		"/usr/lib/systemd/system"} {
+		unitFile := filepath.Join(unitDir, "systemd-networkd.service")
+		if _, err := os.Stat(filepath.Join(rootDir, unitFile)); err != nil {
+			continue
+		}
+		wantsDir := filepath.Join(rootDir, "etc", "systemd", "system",
+			"multi-user.target.wants")
+		if err := os.MkdirAll(wantsDir, fsutil.DirPerms); err != nil {
+			return err
+		}
+		err := os.Symlink(unitFile,
+			filepath.Join(wantsDir, "systemd-networkd.service"))
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+		return nil
+	}
+	return nil
+}
+
+// addressLines returns the [Network] section lines for the addresses of an
+// interface.
+func (netconf *NetworkConfig) addressLines(ipAddr, ipv6Addr net.IP,
+	subnet *hyper_proto.Subnet) []string {
+	lines := []string{"Address=" + ipv4Prefix(ipAddr, subnet)}
+	isDefault := netconf.isDefaultSubnet(subnet)
+	if isDefault {
+		lines = append(lines, "Gateway="+subnet.IpGateway.String())
+	}
+	if len(ipv6Addr) > 0 {
+		lines = append(lines, "Address="+ipv6Prefix(ipv6Addr, subnet))
+		if isDefault && len(subnet.Ipv6Gateway) > 0 {
+			lines = append(lines, "Gateway="+subnet.Ipv6Gateway.String())
+		}
+	}
+	if isDefault {
+		for _, nameserver := range subnet.DomainNameServers {
+			lines = append(lines, "DNS="+nameserver.String())
+		}
+		if subnet.DomainName != "" {
+			lines = append(lines, "Domains="+subnet.DomainName)
+		}
+	}
+	return lines
+}
+
+func (netconf *NetworkConfig) makeSystemdNetworkdFiles() networkdFiles {
+	files := make(networkdFiles)
+	var vlanLines []string
+	for _, name := range netconf.vlanInterfaceNames() {
+		vlanLines = append(vlanLines, "VLAN="+name)
+	}
+	trunkLines := func(name string) []string {
+		if name == netconf.vlanRawDevice {
+			return vlanLines
+		}
+		return nil
+	}
+	configuredInterfaces := make(map[string]struct{})
+	for _, iface := range netconf.normalInterfaces {
+		name := iface.netInterface.Name
+		lines := netconf.addressLines(iface.ipAddr, iface.ipv6Addr,
+			iface.subnet)
+		if iface.subnet.Manage {
+			bridge := fmt.Sprintf("br%d", iface.subnet.VlanId)
+			files.addNetdev(bridge, "bridge",
+				"MACAddress="+iface.netInterface.HardwareAddr.String())
+			files.addNetwork(bridge, lines...)
+			files.addNetwork(name,
+				append([]string{"Bridge=" + bridge}, trunkLines(name)...)...)
+		} else {
+			files.addNetwork(name, append(lines, trunkLines(name)...)...)
+		}
+		configuredInterfaces[name] = struct{}{}
+	}
+	for _, iface := range netconf.bridgeOnlyInterfaces {
+		bridge := "br@" + iface.subnetId
+		files.addNetdev(bridge, "bridge",
+			"MACAddress="+iface.netInterface.HardwareAddr.String())
+		files.addNetwork(bridge, unaddressedLinkLines...)
+		files.addNetwork(iface.netInterface.Name, "Bridge="+bridge)
+	}
+	if netconf.vlanRawDevice == "" {
+		return files
+	}
+	if _, ok := configuredInterfaces[netconf.vlanRawDevice]; !ok {
+		if len(netconf.bondSlaves) > 1 {
+			files.addNetdev(netconf.vlanRawDevice, "bond", "MTUBytes=9000",
+				"", "[Bond]", "Mode=802.3ad", "TransmitHashPolicy=layer3+4")
+			for _, name := range netconf.bondSlaves {
+				files.addNetwork(name, "Bond="+netconf.vlanRawDevice)
+			}
+		}
+		files.addNetwork(netconf.vlanRawDevice,
+			append([]string{"LinkLocalAddressing=no"}, vlanLines...)...)
+	}
+	for _, iface := range netconf.bondedInterfaces {
+		files.addVlan(iface.name, iface.subnet.VlanId)
+		files.addNetwork(iface.name,
+			netconf.addressLines(iface.ipAddr, iface.ipv6Addr,
+				iface.subnet)...)
+	}
+	for _, vlanId := range netconf.bridges {
+		vlanName := fmt.Sprintf("%s.%d", netconf.vlanRawDevice, vlanId)
+		bridge := fmt.Sprintf("br%d", vlanId)
+		files.addVlan(vlanName, vlanId)
+		files.addNetwork(vlanName, "Bridge="+bridge)
+		files.addNetdev(bridge, "bridge")
+		files.addNetwork(bridge, unaddressedLinkLines...)
+	}
+	return files
+}
+
+func (netconf *NetworkConfig) printSystemdNetworkd(writer io.Writer) error {
+	files := netconf.makeSystemdNetworkdFiles()
+	for index, filename := range files.filenames() {
+		if index > 0 {
+			fmt.Fprintln(writer)
+		}
+		if _, err := writer.Write(files[filename].Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (netconf *NetworkConfig) updateSystemdNetworkd(
+	rootDir string) (bool, error) {
+	dirname := networkdDirectory(rootDir)
+	// Check if they were written by me.
+	oldFilenames, err := filepath.Glob(
+		filepath.Join(dirname, networkdFilePrefix+"*"))
+	if err != nil {
+		return false, err
+	}
+	if len(oldFilenames) < 1 {
+		return false, fmt.Errorf("%s: no files created by SmallStack",
+			dirname)
+	}
+	changed, err := netconf.writeSystemdNetworkdFiles(rootDir)
+	if err != nil {
+		return false, err
+	} else if !changed {
+		return false, nil
+	}
+	cmd := exec.Command("networkctl", "reload")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (netconf *NetworkConfig) writeSystemdNetworkd(rootDir string) error {
+	if _, err := netconf.writeSystemdNetworkdFiles(rootDir); err != nil {
+		return err
+	}
+	return enableSystemdNetworkd(rootDir)
+}
+
+// writeSystemdNetworkdFiles writes the configuration files which have changed
+// and removes stale files previously written. It returns true if any files
+// were changed.
+func (netconf *NetworkConfig) writeSystemdNetworkdFiles(
+	rootDir string) (bool, error) {
+	dirname := networkdDirectory(rootDir)
+	if err := os.MkdirAll(dirname, fsutil.DirPerms); err != nil {
+		return false, err
+	}
+	files := netconf.makeSystemdNetworkdFiles()
+	changed := false
+	for _, filename := range files.filenames() {
+		pathname := filepath.Join(dirname, filename)
+		data := files[filename].Bytes()
+		if _, err := os.Stat(pathname); os.IsNotExist(err) {
+			err := writeFile(data, pathname, fsutil.PublicFilePerms)
+			if err != nil {
+				return false, err
+			}
+			changed = true
+		} else if u, err := fsutil.UpdateFile(data, pathname); err != nil {
+			return false, err
+		} else if u {
+			changed = true
+		}
+	}
+	oldFilenames, err := filepath.Glob(
+		filepath.Join(dirname, networkdFilePrefix+"*"))
+	if err != nil {
+		return false, err
+	}
+	for _, pathname := range oldFilenames {
+		filename := filepath.Base(pathname)
+		if _, ok := files[filename]; ok {
+			continue
+		}
+		if err := os.Remove(pathname); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+func writeFile(data []byte, filename string, perm os.FileMode) error {
+	file, err := fsutil.CreateRenamingWriter(filename, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,94 @@
+package configurator
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintSystemdNetworkd(t *testing.T) {
+	for _, topology := range testTopologies {
+		netconf := computeTestTopology(t, topology)
+		buffer := &bytes.Buffer{}
+		if err := netconf.printSystemdNetworkd(buffer); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, topology.name+".networkd", buffer.Bytes())
+	}
+}
+
+func TestAddressLinesDefaultSubnet(t *testing.T) {
+	netconf := computeTestTopology(t, testTopologies[0])
+	// A distinct subnet with the same gateway is also the default subnet.
+	subnet := *netconf.DefaultSubnet
+	lines := strings.Join(
+		netconf.addressLines(net.IP{10, 0, 10, 6}, nil, &subnet), "\n")
+	for _, line := range []string{"Gateway=10.0.10.1", "DNS=10.0.0.53",
+		"Domains=example.com"} {
+		if !strings.Contains(lines, line) {
+			t.Errorf("missing line: %s in:\n%s", line, lines)
+		}
+	}
+	subnet.IpGateway = net.IP{10, 0, 11, 1}
+	lines = strings.Join(
+		netconf.addressLines(net.IP{10, 0, 11, 6}, nil, &subnet), "\n")
+	if strings.Contains(lines, "Gateway=") || strings.Contains(lines, "DNS=") {
+		t.Errorf("unexpected gateway or DNS lines in:\n%s", lines)
+	}
+}
+
+func TestWriteSystemdNetworkdFiles(t *testing.T) {
+	rootDir := t.TempDir()
+	dirname := networkdDirectory(rootDir)
+	if err := os.MkdirAll(dirname, 0755); err != nil {
+		t.Fatal(err)
+	}
+	otherFile := filepath.Join(dirname, "99-local.network")
+	if err := ioutil.WriteFile(otherFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	netconf := computeTestTopology(t, testTopologies[0])
+	if changed, err := netconf.writeSystemdNetworkdFiles(rootDir); err != nil {
+		t.Fatal(err)
+	} else if !changed {
+		t.Error("initial write not reported as changed")
+	}
+	if changed, err := netconf.writeSystemdNetworkdFiles(rootDir); err != nil {
+		t.Fatal(err)
+	} else if changed {
+		t.Error("unchanged configuration reported as changed")
+	}
+	// Switching topology removes the stale files.
+	netconf = computeTestTopology(t, testTopologies[1])
+	if changed, err := netconf.writeSystemdNetworkdFiles(rootDir); err != nil {
+		t.Fatal(err)
+	} else if !changed {
+		t.Error("new topology not reported as changed")
+	}
+	filenames, err := filepath.Glob(
+		filepath.Join(dirname, networkdFilePrefix+"*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := netconf.makeSystemdNetworkdFiles()
+	if len(filenames) != len(files) {
+		t.Errorf("expected %d files, found: %d", len(files), len(filenames))
+	}
+	for _, pathname := range filenames {
+		filename := filepath.Base(pathname)
+		if file, ok := files[filename]; !ok {
+			t.Errorf("stale file: %s", filename)
+		} else if data, err := ioutil.ReadFile(pathname); err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(data, file.Bytes()) {
+			t.Errorf("%s: wrong content", filename)
+		}
+	}
+	if _, err := os.Stat(otherFile); err != nil {
+		t.Errorf("file not created by SmallStack removed: %s", err)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/log"
 )
 
-func (netconf *NetworkConfig) update(rootDir string,
+func (netconf *NetworkConfig) update(rootDir, format string,
 	logger log.DebugLogger) (bool, error) {
+	if format == "" {
+		format = detectFormat(rootDir)
+	}
 	updated := false
-	if u, err := netconf.updateDebian(rootDir); err != nil {
+	if u, err := netconf.updateFormat(rootDir, format); err != nil {
 		return updated, err
 	} else if u {
 		logger.Printf("updated network interfaces configuration")
@@ -52,6 +52,12 @@ func GetDefaultRoute() (*DefaultRouteInfo, error) {
 	return getDefaultRoute()
 }
 
+// GetMacFromEui64IP returns the MAC address embedded in an IPv6 address
+// which has a modified EUI-64 interface identifier.
+func GetMacFromEui64IP(ip net.IP) (net.HardwareAddr, error) {
+	return getMacFromEui64IP(ip)
+}
+
 func GetMyIP() (net.IP, error) {
 	return getMyIP()
 }
@@ -72,6 +78,14 @@ func InvertIP(input net.IP) {
 	invertIP(input)
 }
 
+// MakeEui64IP returns the IPv6 address in the network specified by prefix and
+// mask, using the modified EUI-64 interface identifier derived from macAddr.
+// The prefix length must be 64 bits or less.
+func MakeEui64IP(prefix net.IP, mask net.IPMask,
+	macAddr net.HardwareAddr) (net.IP, error) {
+	return makeEui64IP(prefix, mask, macAddr)
+}
+
 func ShrinkIP(netIP net.IP) net.IP {
 	return shrinkIP(netIP)
 }
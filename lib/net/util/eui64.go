@@ -0,0 +1,43 @@
+package util
+
+import (
+	"errors"
+	"net"
+)
+
+func getMacFromEui64IP(ip net.IP) (net.HardwareAddr, error) {
+	ip = ip.To16()
+	if ip == nil || ip.To4() != nil {
+		return nil, errors.New("not an IPv6 address")
+	}
+	if ip[11] != 0xff || ip[12] != 0xfe {
+		return nil, errors.New("not an EUI-64 address")
+	}
+	return net.HardwareAddr{ip[8] ^ 0x02, ip[9], ip[10], ip[13], ip[14],
+		ip[15]}, nil
+}
+
+func makeEui64IP(prefix net.IP, mask net.IPMask,
+	macAddr net.HardwareAddr) (net.IP, error) {
+	prefix = prefix.To16()
+	if prefix == nil || prefix.To4() != nil {
+		return nil, errors.New("prefix is not an IPv6 address")
+	}
+	if ones, bits := mask.Size(); bits != 128 || ones > 64 {
+		return nil, errors.New("IPv6 prefix must be /64 or shorter")
+	}
+	if len(macAddr) != 6 {
+		return nil, errors.New("MAC address is not 48 bits")
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix.Mask(mask))
+	ip[8] = macAddr[0] ^ 0x02
+	ip[9] = macAddr[1]
+	ip[10] = macAddr[2]
+	ip[11] = 0xff
+	ip[12] = 0xfe
+	ip[13] = macAddr[3]
+	ip[14] = macAddr[4]
+	ip[15] = macAddr[5]
+	return ip, nil
+}
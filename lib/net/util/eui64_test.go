@@ -0,0 +1,106 @@
+package util
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestEui64RoundTrip(t *testing.T) {
+	macAddrs := []string{
+		"00:00:00:00:00:00",
+		"02:00:00:00:00:01",
+		"52:54:00:12:34:56",
+		"ff:ff:ff:ff:ff:ff",
+		"a8:5e:45:c0:ff:ee",
+	}
+	prefixes := []struct {
+		prefix string
+		length int
+	}{
+		{"2001:db8::1", 64},
+		{"2001:db8:1:2:3:4:5:6", 64},
+		{"2001:db8::", 48},
+		{"fd00::", 8},
+	}
+	for _, prefix := range prefixes {
+		prefixIP := net.ParseIP(prefix.prefix)
+		mask := net.CIDRMask(prefix.length, 128)
+		for _, macString := range macAddrs {
+			macAddr, err := net.ParseMAC(macString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ip, err := MakeEui64IP(prefixIP, mask, macAddr)
+			if err != nil {
+				t.Fatalf("%s/%d: %s: %s",
+					prefix.prefix, prefix.length, macString, err)
+			}
+			if !ip.Mask(mask).Equal(prefixIP.Mask(mask)) {
+				t.Errorf("%s not in %s/%d", ip, prefix.prefix, prefix.length)
+			}
+			if ip[11] != 0xff || ip[12] != 0xfe {
+				t.Errorf("%s: missing ff:fe marker", ip)
+			}
+			if (ip[8]^macAddr[0])&0x02 == 0 {
+				t.Errorf("%s: universal/local bit not inverted", ip)
+			}
+			result, err := GetMacFromEui64IP(ip)
+			if err != nil {
+				t.Fatalf("%s: %s", ip, err)
+			}
+			if !bytes.Equal(result, macAddr) {
+				t.Errorf("%s: %s != %s", ip, result, macAddr)
+			}
+		}
+	}
+}
+
+func TestMakeEui64IP(t *testing.T) {
+	macAddr, _ := net.ParseMAC("52:54:00:12:34:56")
+	ip, err := MakeEui64IP(net.ParseIP("2001:db8::1"), net.CIDRMask(64, 128),
+		macAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := net.ParseIP("2001:db8::5054:ff:fe12:3456"); !ip.Equal(
+		expected) {
+		t.Errorf("%s != %s", ip, expected)
+	}
+	tests := []struct {
+		name    string
+		prefix  net.IP
+		mask    net.IPMask
+		macAddr net.HardwareAddr
+	}{
+		{"IPv4 prefix", net.ParseIP("10.0.0.1"), net.CIDRMask(24, 32),
+			macAddr},
+		{"long prefix", net.ParseIP("2001:db8::"), net.CIDRMask(80, 128),
+			macAddr},
+		{"IPv4 mask", net.ParseIP("2001:db8::"), net.CIDRMask(24, 32),
+			macAddr},
+		{"EUI-64 MAC", net.ParseIP("2001:db8::"), net.CIDRMask(64, 128),
+			net.HardwareAddr{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"nil prefix", nil, net.CIDRMask(64, 128), macAddr},
+	}
+	for _, test := range tests {
+		if _, err := MakeEui64IP(test.prefix, test.mask,
+			test.macAddr); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestGetMacFromEui64IP(t *testing.T) {
+	for _, address := range []string{
+		"10.0.0.1",
+		"2001:db8::1",
+		"2001:db8::5054:fe:ff12:3456",
+		"::ffff:10.0.0.1",
+	} {
+		if macAddr, err := GetMacFromEui64IP(
+			net.ParseIP(address)); err == nil {
+			t.Errorf("%s: no error, got: %s", address, macAddr)
+		}
+	}
+}
@@ -34,6 +34,11 @@ type ObjectLinker interface {
 	LinkObject(filename string, hashVal hash.Hash) (bool, error)
 }
 
+type ObjectDeltaGetter interface {
+	GetObjectDelta(hashVal hash.Hash, base io.Reader, baseSize uint64,
+		writer io.WriteSeeker) (uint64, error)
+}
+
 type ObjectGetter interface {
 	GetObject(hashVal hash.Hash) (uint64, io.ReadCloser, error)
 }
@@ -52,6 +57,7 @@ type ObjectsIterator interface {
 
 type ObjectsRefcounter interface {
 	AdjustRefcounts(bool, ObjectsIterator) error
+	DeleteObjectsIfUnreferenced(hashes []hash.Hash) (uint64, uint64, error)
 	DeleteUnreferenced(percentage uint8, bytes uint64) (uint64, uint64, error)
 	ListUnreferenced() map[hash.Hash]uint64
 }
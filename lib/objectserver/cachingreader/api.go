@@ -66,6 +66,22 @@ func NewObjectServer(baseDir string, maxCachedBytes uint64,
 	})
 }
 
+// CheckObjects will return the sizes of the specified objects which are cached
+// or being downloaded. The size is zero for objects which are not cached.
+func (objSrv *ObjectServer) CheckObjects(hashes []hash.Hash) ([]uint64, error) {
+	return objSrv.checkObjects(hashes), nil
+}
+
+// FetchObjectDeltas will fetch and cache objects as deltas against similar base
+// objects which are already cached. The keys of deltaBases are the objects to
+// fetch and the values are the corresponding base objects. Objects which are
+// already cached or whose base is not cached are skipped, as are objects which
+// fail to be fetched. The number of objects fetched is returned.
+func (objSrv *ObjectServer) FetchObjectDeltas(
+	deltaBases map[hash.Hash]hash.Hash) (uint, error) {
+	return objSrv.fetchObjectDeltas(deltaBases)
+}
+
 func (objSrv *ObjectServer) FetchObjects(hashes []hash.Hash) error {
 	return objSrv.fetchObjects(hashes)
 }
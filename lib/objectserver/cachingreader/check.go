@@ -0,0 +1,17 @@
+package cachingreader
+
+import (
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+)
+
+func (objSrv *ObjectServer) checkObjects(hashes []hash.Hash) []uint64 {
+	sizes := make([]uint64, len(hashes))
+	objSrv.rwLock.RLock()
+	defer objSrv.rwLock.RUnlock()
+	for index, hashVal := range hashes {
+		if object, ok := objSrv.objects[hashVal]; ok {
+			sizes[index] = object.size
+		}
+	}
+	return sizes
+}
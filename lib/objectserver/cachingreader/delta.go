@@ -0,0 +1,114 @@
+package cachingreader
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/objectcache"
+	"github.com/Cloud-Foundations/Dominator/lib/objectserver/client"
+)
+
+func (objSrv *ObjectServer) fetchObjectDeltas(
+	deltaBases map[hash.Hash]hash.Hash) (uint, error) {
+	objectClient := objSrv.params.ObjectClient
+	if objectClient == nil {
+		objectClient = client.NewObjectClient(objSrv.params.ObjectServerAddress)
+		defer objectClient.Close()
+	}
+	var numFetched uint
+	for hashVal, baseHash := range deltaBases {
+		objSrv.rwLock.Lock()
+		if _, ok := objSrv.objects[hashVal]; ok {
+			objSrv.rwLock.Unlock()
+			continue
+		}
+		baseObject := objSrv.getObjectWithLock(baseHash)
+		objSrv.rwLock.Unlock()
+		if baseObject == nil {
+			continue
+		}
+		err := objSrv.fetchObjectDelta(objectClient, hashVal, baseObject)
+		objSrv.rwLock.Lock()
+		objSrv.putObjectWithLock(baseObject)
+		objSrv.rwLock.Unlock()
+		if err != nil {
+			objSrv.params.Logger.Debugf(0,
+				"error fetching delta for: %x: %s\n", hashVal, err)
+			continue
+		}
+		numFetched++
+	}
+	return numFetched, nil
+}
+
+func (objSrv *ObjectServer) fetchObjectDelta(objectClient *client.ObjectClient,
+	hashVal hash.Hash, baseObject *objectType) error {
+	baseFilename := filepath.Join(objSrv.params.BaseDirectory,
+		objectcache.HashToFilename(baseObject.hash))
+	baseFile, err := os.Open(baseFilename)
+	if err != nil {
+		return err
+	}
+	defer baseFile.Close()
+	file, err := ioutil.TempFile(objSrv.params.BaseDirectory, ".delta.")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if _, err := io.Copy(file, baseFile); err != nil {
+		return err
+	}
+	if _, err := baseFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	size, err := objectClient.GetObjectDelta(hashVal, baseFile,
+		baseObject.size, file)
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(int64(size)); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hasher := sha512.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return err
+	}
+	var computedHash hash.Hash
+	copy(computedHash[:], hasher.Sum(nil))
+	if computedHash != hashVal {
+		return fmt.Errorf("hash mismatch: computed: %x", computedHash)
+	}
+	if err := file.Chmod(privateFilePerms); err != nil {
+		return err
+	}
+	filename := filepath.Join(objSrv.params.BaseDirectory,
+		objectcache.HashToFilename(hashVal))
+	if err := os.MkdirAll(filepath.Dir(filename), dirPerms); err != nil {
+		return err
+	}
+	objSrv.rwLock.Lock()
+	defer objSrv.rwLock.Unlock()
+	if _, ok := objSrv.objects[hashVal]; ok {
+		return nil // Fetched by someone else in the meantime.
+	}
+	if !objSrv.releaseSpaceWithLock(size) {
+		return fmt.Errorf("no space to cache object of size: %d", size)
+	}
+	if err := os.Rename(file.Name(), filename); err != nil {
+		return err
+	}
+	object := &objectType{hash: hashVal, size: size}
+	objSrv.objects[hashVal] = object
+	objSrv.data.CachedBytes += size
+	objSrv.addToLruWithLock(object)
+	return nil
+}
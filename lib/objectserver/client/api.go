@@ -41,6 +41,15 @@ func (objClient *ObjectClient) GetObject(hashVal hash.Hash) (
 	return objectserver.GetObject(objClient, hashVal)
 }
 
+// GetObjectDelta will fetch the object with hash hashVal, sending only the
+// blocks which differ from base (a similar object of size baseSize). The object
+// is written to writer, which must already contain the base content. The size
+// of the object is returned; the caller should truncate writer to this size.
+func (objClient *ObjectClient) GetObjectDelta(hashVal hash.Hash,
+	base io.Reader, baseSize uint64, writer io.WriteSeeker) (uint64, error) {
+	return objClient.getObjectDelta(hashVal, base, baseSize, writer)
+}
+
 func (objClient *ObjectClient) GetObjects(hashes []hash.Hash) (
 	objectserver.ObjectsReader, error) {
 	return objClient.getObjects(hashes)
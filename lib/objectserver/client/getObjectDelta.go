@@ -0,0 +1,47 @@
+package client
+
+import (
+	"errors"
+	"io"
+
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
+	"github.com/Cloud-Foundations/Dominator/lib/rsync"
+	"github.com/Cloud-Foundations/Dominator/proto/objectserver"
+)
+
+func (objClient *ObjectClient) getObjectDelta(hashVal hash.Hash,
+	base io.Reader, baseSize uint64, writer io.WriteSeeker) (uint64, error) {
+	client, err := objClient.getClient()
+	if err != nil {
+		return 0, err
+	}
+	conn, err := client.Call("ObjectServer.GetObjectDelta")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	request := objectserver.GetObjectDeltaRequest{Hash: hashVal}
+	if err := conn.Encode(request); err != nil {
+		return 0, err
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, err
+	}
+	var reply objectserver.GetObjectDeltaResponse
+	if err := conn.Decode(&reply); err != nil {
+		return 0, err
+	}
+	if reply.Error != "" {
+		return 0, errors.New(reply.Error)
+	}
+	readerBytes := baseSize
+	if readerBytes > reply.Size {
+		readerBytes = reply.Size
+	}
+	_, err = rsync.GetBlocks(conn, conn, conn, base, writer, reply.Size,
+		readerBytes)
+	if err != nil {
+		return 0, err
+	}
+	return reply.Size, nil
+}
@@ -119,6 +119,14 @@ func (objSrv *ObjectServer) DeleteObject(hashVal hash.Hash) error {
 	return objSrv.deleteObject(hashVal, false)
 }
 
+// DeleteObjectsIfUnreferenced will delete the specified objects which are not
+// referenced. Objects which are unknown or referenced are skipped. The number
+// of bytes and objects deleted are returned.
+func (objSrv *ObjectServer) DeleteObjectsIfUnreferenced(hashes []hash.Hash) (
+	uint64, uint64, error) {
+	return objSrv.deleteObjectsIfUnreferenced(hashes)
+}
+
 func (objSrv *ObjectServer) DeleteStashedObject(hashVal hash.Hash) error {
 	return objSrv.deleteStashedObject(hashVal)
 }
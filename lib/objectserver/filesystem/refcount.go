@@ -123,6 +123,27 @@ func (objSrv *ObjectServer) deleteOldestUnreferenced(lastPauseTime *time.Time) (
 	return object.size, nil
 }
 
+// This must be called without the lock being held.
+func (objSrv *ObjectServer) deleteObjectsIfUnreferenced(hashes []hash.Hash) (
+	uint64, uint64, error) {
+	var bytesDeleted, objectsDeleted uint64
+	for _, hashVal := range hashes {
+		objSrv.rwLock.Lock()
+		object := objSrv.objects[hashVal]
+		if object == nil || object.refcount > 0 {
+			objSrv.rwLock.Unlock()
+			continue
+		}
+		// deleteObject() will release the lock.
+		if err := objSrv.deleteObject(hashVal, true); err != nil {
+			return bytesDeleted, objectsDeleted, err
+		}
+		bytesDeleted += object.size
+		objectsDeleted++
+	}
+	return bytesDeleted, objectsDeleted, nil
+}
+
 // This must be called without the lock being held.
 func (objSrv *ObjectServer) deleteUnreferenced(percentage uint8,
 	bytesToDelete uint64) (uint64, uint64, error) {
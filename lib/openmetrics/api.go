@@ -0,0 +1,47 @@
+/*
+Package openmetrics exports tricorder metrics in the OpenMetrics text format.
+
+The package registers a handler for the /metrics path with the HTTP default
+mux, so that any daemon may be scraped by Prometheus. The HTML interface to
+tricorder metrics (under /metrics/) remains available: requests which accept
+HTML are redirected to it.
+
+Tricorder metric paths are converted to metric names by replacing the path
+separators and any other characters not permitted in metric names with
+underscores. The unit of each metric is preserved and is appended to the
+metric name. Distributions are exported as histograms (or gauge histograms
+for non-cumulative distributions), times as seconds since the Epoch and
+strings as info metrics. All other numeric metrics are exported as gauges,
+unless they were registered with RegisterCounter.
+*/
+package openmetrics
+
+import (
+	"io"
+)
+
+// RegisterCounter registers the metric(s) matching pattern as counters. The
+// pattern is an absolute tricorder metric path, where a "*" path component
+// matches exactly one component.
+func RegisterCounter(pattern string) {
+	registerCounter(pattern)
+}
+
+// RegisterLabels registers a tricorder directory pattern whose wildcard path
+// components are exported as labels rather than being included in the metric
+// names. The pattern is an absolute path where a "*" path component matches
+// exactly one component. There must be one label for each wildcard component.
+// Only metrics directly within the matching directories are affected.
+// For example, the pattern "/srpc/server/*/*" with the labels "service" and
+// "method" exports the metric
+// "/srpc/server/ImageServer/GetImage/num-permitted-calls" as
+// srpc_server_num_permitted_calls{service="ImageServer",method="GetImage"}.
+func RegisterLabels(pattern string, labels ...string) error {
+	return registerLabels(pattern, labels)
+}
+
+// WriteMetrics writes all the tricorder metrics in this process to writer, in
+// OpenMetrics text format.
+func WriteMetrics(writer io.Writer) error {
+	return writeMetrics(writer)
+}
@@ -0,0 +1,24 @@
+package openmetrics
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+)
+
+const contentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+func init() {
+	http.HandleFunc("/metrics", metricsHandler)
+}
+
+func metricsHandler(w http.ResponseWriter, req *http.Request) {
+	if strings.Contains(req.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, req, "/metrics/", http.StatusFound)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	writeMetrics(writer) // Errors can only come from writing to the client.
+}
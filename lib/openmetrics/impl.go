@@ -0,0 +1,419 @@
+package openmetrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cloud-Foundations/tricorder/go/tricorder"
+	"github.com/Cloud-Foundations/tricorder/go/tricorder/messages"
+	"github.com/Cloud-Foundations/tricorder/go/tricorder/types"
+	"github.com/Cloud-Foundations/tricorder/go/tricorder/units"
+)
+
+const (
+	typeCounter        = "counter"
+	typeGauge          = "gauge"
+	typeGaugeHistogram = "gaugehistogram"
+	typeHistogram      = "histogram"
+	typeInfo           = "info"
+)
+
+type familyType struct {
+	help       string
+	metricType string
+	name       string
+	samples    []sampleType
+	unit       string
+}
+
+type labelPatternType struct {
+	components []string
+	labels     []string
+}
+
+type sampleType struct {
+	labels string // Formatted, including braces.
+	suffix string
+	value  string
+}
+
+var (
+	lock            sync.RWMutex
+	counterPatterns [][]string
+	labelPatterns   []labelPatternType
+)
+
+func registerCounter(pattern string) {
+	lock.Lock()
+	defer lock.Unlock()
+	counterPatterns = append(counterPatterns, splitPath(pattern))
+}
+
+func registerLabels(pattern string, labels []string) error {
+	components := splitPath(pattern)
+	numWildcards := 0
+	for _, component := range components {
+		if component == "*" {
+			numWildcards++
+		}
+	}
+	if numWildcards != len(labels) {
+		return fmt.Errorf("%s has %d wildcards but %d labels given",
+			pattern, numWildcards, len(labels))
+	}
+	for _, label := range labels {
+		if sanitiseName(label) != label {
+			return fmt.Errorf("invalid label name: %s", label)
+		}
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	labelPatterns = append(labelPatterns, labelPatternType{
+		components: components,
+		labels:     labels,
+	})
+	return nil
+}
+
+func escapeString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) < 1 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names))
+	for index, name := range names {
+		pairs = append(pairs,
+			name+`="`+escapeString(values[index])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// getUnitName returns the OpenMetrics unit name for a tricorder unit.
+func getUnitName(unit units.Unit) string {
+	switch unit {
+	case units.Millisecond:
+		return "milliseconds"
+	case units.Second:
+		return "seconds"
+	case units.Celsius:
+		return "celsius"
+	case units.Byte:
+		return "bytes"
+	case units.BytePerSecond:
+		return "bytes_per_second"
+	}
+	return ""
+}
+
+// getValue returns the numeric value of a scalar metric and the unit it is
+// expressed in.
+func getValue(metric *messages.Metric) (float64, units.Unit, bool) {
+	switch value := metric.Value.(type) {
+	case bool:
+		if value {
+			return 1, metric.Unit, true
+		}
+		return 0, metric.Unit, true
+	case int8:
+		return float64(value), metric.Unit, true
+	case int16:
+		return float64(value), metric.Unit, true
+	case int32:
+		return float64(value), metric.Unit, true
+	case int64:
+		return float64(value), metric.Unit, true
+	case uint8:
+		return float64(value), metric.Unit, true
+	case uint16:
+		return float64(value), metric.Unit, true
+	case uint32:
+		return float64(value), metric.Unit, true
+	case uint64:
+		return float64(value), metric.Unit, true
+	case float32:
+		return float64(value), metric.Unit, true
+	case float64:
+		return value, metric.Unit, true
+	case time.Duration:
+		return value.Seconds(), units.Second, true
+	case time.Time:
+		if value.IsZero() {
+			return 0, units.Second, true
+		}
+		return float64(value.UnixNano()) / 1e9, units.Second, true
+	}
+	return 0, metric.Unit, false
+}
+
+func matchComponents(pattern, components []string) bool {
+	if len(pattern) != len(components) {
+		return false
+	}
+	for index, component := range pattern {
+		if component != "*" && component != components[index] {
+			return false
+		}
+	}
+	return true
+}
+
+func sanitiseName(name string) string {
+	var builder strings.Builder
+	for index, ch := range name {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch == '_',
+			ch == ':':
+			builder.WriteRune(ch)
+		case ch >= '0' && ch <= '9':
+			if index == 0 {
+				builder.WriteRune('_')
+			}
+			builder.WriteRune(ch)
+		default:
+			builder.WriteRune('_')
+		}
+	}
+	return builder.String()
+}
+
+func splitPath(pathname string) []string {
+	pathname = strings.Trim(path.Clean(pathname), "/")
+	if pathname == "" {
+		return nil
+	}
+	return strings.Split(pathname, "/")
+}
+
+// getNameAndLabels computes the base metric name (without unit and type
+// suffixes) and the formatted labels for a tricorder metric path.
+func getNameAndLabels(pathname string) (string, string) {
+	components := splitPath(pathname)
+	if len(components) < 1 {
+		return "", ""
+	}
+	dirComponents := components[:len(components)-1]
+	for _, pattern := range labelPatterns {
+		if !matchComponents(pattern.components, dirComponents) {
+			continue
+		}
+		var nameComponents, labelValues []string
+		for index, component := range pattern.components {
+			if component == "*" {
+				labelValues = append(labelValues, dirComponents[index])
+			} else {
+				nameComponents = append(nameComponents, component)
+			}
+		}
+		nameComponents = append(nameComponents,
+			components[len(components)-1])
+		return sanitiseName(strings.Join(nameComponents, "_")),
+			formatLabels(pattern.labels, labelValues)
+	}
+	return sanitiseName(strings.Join(components, "_")), ""
+}
+
+func isCounter(pathname string) bool {
+	components := splitPath(pathname)
+	for _, pattern := range counterPatterns {
+		if matchComponents(pattern, components) {
+			return true
+		}
+	}
+	return false
+}
+
+func addDistribution(family *familyType, labels string,
+	dist *messages.Distribution) {
+	var cumulativeCount uint64
+	for index, bucket := range dist.Ranges {
+		cumulativeCount += bucket.Count
+		upper := formatFloat(bucket.Upper)
+		if index == len(dist.Ranges)-1 {
+			upper = "+Inf"
+		}
+		family.samples = append(family.samples, sampleType{
+			labels: addLabel(labels, "le", upper),
+			suffix: "_bucket",
+			value:  strconv.FormatUint(cumulativeCount, 10),
+		})
+	}
+	if len(dist.Ranges) < 1 {
+		family.samples = append(family.samples, sampleType{
+			labels: addLabel(labels, "le", "+Inf"),
+			suffix: "_bucket",
+			value:  strconv.FormatUint(dist.Count, 10),
+		})
+	}
+	countSuffix, sumSuffix := "_count", "_sum"
+	if family.metricType == typeGaugeHistogram {
+		countSuffix, sumSuffix = "_gcount", "_gsum"
+	}
+	family.samples = append(family.samples,
+		sampleType{
+			labels: labels,
+			suffix: countSuffix,
+			value:  strconv.FormatUint(dist.Count, 10),
+		},
+		sampleType{
+			labels: labels,
+			suffix: sumSuffix,
+			value:  formatFloat(dist.Sum),
+		})
+}
+
+func addLabel(labels, name, value string) string {
+	pair := name + `="` + escapeString(value) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+// makeFamilies converts a list of tricorder metrics into a sorted list of
+// metric families.
+func makeFamilies(metrics messages.MetricList) []*familyType {
+	lock.RLock()
+	defer lock.RUnlock()
+	families := make(map[string]*familyType)
+	for _, metric := range metrics {
+		baseName, labels := getNameAndLabels(metric.Path)
+		if baseName == "" {
+			continue
+		}
+		var metricType string
+		var unit units.Unit
+		var value float64
+		var dist *messages.Distribution
+		switch metric.Kind {
+		case types.Dist:
+			var ok bool
+			if dist, ok = metric.Value.(*messages.Distribution); !ok ||
+				dist == nil {
+				continue
+			}
+			unit = metric.Unit
+			if dist.IsNotCumulative {
+				metricType = typeGaugeHistogram
+			} else {
+				metricType = typeHistogram
+			}
+		case types.String:
+			metricType = typeInfo
+		case types.List:
+			continue
+		default:
+			var ok bool
+			if value, unit, ok = getValue(metric); !ok {
+				continue
+			}
+			if isCounter(metric.Path) {
+				metricType = typeCounter
+			} else {
+				metricType = typeGauge
+			}
+		}
+		name := baseName
+		unitName := getUnitName(unit)
+		if unitName != "" && !strings.HasSuffix(name, "_"+unitName) {
+			name += "_" + unitName
+		}
+		family := families[name]
+		if family == nil {
+			family = &familyType{
+				help:       metric.Description,
+				metricType: metricType,
+				name:       name,
+				unit:       unitName,
+			}
+			families[name] = family
+		} else if family.metricType != metricType {
+			continue // Conflicting type: cannot be represented.
+		}
+		switch metricType {
+		case typeCounter:
+			family.samples = append(family.samples, sampleType{
+				labels: labels,
+				suffix: "_total",
+				value:  formatFloat(value),
+			})
+		case typeGauge:
+			family.samples = append(family.samples, sampleType{
+				labels: labels,
+				value:  formatFloat(value),
+			})
+		case typeGaugeHistogram, typeHistogram:
+			addDistribution(family, labels, dist)
+		case typeInfo:
+			family.samples = append(family.samples, sampleType{
+				labels: addLabel(labels, "value", fmt.Sprint(metric.Value)),
+				suffix: "_info",
+				value:  "1",
+			})
+		}
+	}
+	list := make([]*familyType, 0, len(families))
+	for _, family := range families {
+		list = append(list, family)
+	}
+	sort.Slice(list, func(left, right int) bool {
+		return list[left].name < list[right].name
+	})
+	return list
+}
+
+func writeFamilies(writer io.Writer, families []*familyType) error {
+	for _, family := range families {
+		if _, err := fmt.Fprintf(writer, "# TYPE %s %s\n",
+			family.name, family.metricType); err != nil {
+			return err
+		}
+		if family.unit != "" {
+			if _, err := fmt.Fprintf(writer, "# UNIT %s %s\n",
+				family.name, family.unit); err != nil {
+				return err
+			}
+		}
+		if family.help != "" {
+			if _, err := fmt.Fprintf(writer, "# HELP %s %s\n",
+				family.name, escapeString(family.help)); err != nil {
+				return err
+			}
+		}
+		for _, sample := range family.samples {
+			if _, err := fmt.Fprintf(writer, "%s%s%s %s\n", family.name,
+				sample.suffix, sample.labels, sample.value); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(writer, "# EOF\n")
+	return err
+}
+
+func writeMetrics(writer io.Writer) error {
+	return writeFamilies(writer, makeFamilies(tricorder.ReadMyMetrics("/")))
+}
@@ -0,0 +1,114 @@
+package openmetrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/tricorder/go/tricorder/messages"
+	"github.com/Cloud-Foundations/tricorder/go/tricorder/types"
+	"github.com/Cloud-Foundations/tricorder/go/tricorder/units"
+)
+
+func TestWriteFamilies(t *testing.T) {
+	if err := RegisterLabels("/test/*/*", "service", "method"); err != nil {
+		t.Fatal(err)
+	}
+	RegisterCounter("/test/*/*/num-calls")
+	metrics := messages.MetricList{
+		{
+			Path:        "/test/Service/MethodA/num-calls",
+			Description: "number of calls",
+			Unit:        units.None,
+			Kind:        types.Uint64,
+			Value:       uint64(3),
+		},
+		{
+			Path:        "/test/Service/MethodB/num-calls",
+			Description: "number of calls",
+			Unit:        units.None,
+			Kind:        types.Uint64,
+			Value:       uint64(4),
+		},
+		{
+			Path:        "/test/Service/MethodA/durations",
+			Description: "call durations",
+			Unit:        units.Millisecond,
+			Kind:        types.Dist,
+			Value: &messages.Distribution{
+				Count: 3,
+				Sum:   12.5,
+				Ranges: []*messages.RangeWithCount{
+					{Upper: 1, Count: 1},
+					{Lower: 1, Upper: 10, Count: 1},
+					{Lower: 10, Count: 1},
+				},
+			},
+		},
+		{
+			Path:        "/uptime",
+			Description: "time since start",
+			Kind:        types.GoDuration,
+			Value:       90 * time.Second,
+		},
+		{
+			Path:        "/name",
+			Description: "name of \"thing\"",
+			Kind:        types.String,
+			Value:       "a \"name\"",
+		},
+		{
+			Path:        "/list",
+			Description: "a list",
+			Kind:        types.List,
+			Value:       []string{"a"},
+		},
+	}
+	buffer := &bytes.Buffer{}
+	if err := writeFamilies(buffer, makeFamilies(metrics)); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# TYPE name info
+# HELP name name of \"thing\"
+name_info{value="a \"name\""} 1
+# TYPE test_durations_milliseconds histogram
+# UNIT test_durations_milliseconds milliseconds
+# HELP test_durations_milliseconds call durations
+test_durations_milliseconds_bucket{service="Service",method="MethodA",le="1"} 1
+test_durations_milliseconds_bucket{service="Service",method="MethodA",le="10"} 2
+test_durations_milliseconds_bucket{service="Service",method="MethodA",le="+Inf"} 3
+test_durations_milliseconds_count{service="Service",method="MethodA"} 3
+test_durations_milliseconds_sum{service="Service",method="MethodA"} 12.5
+# TYPE test_num_calls counter
+# HELP test_num_calls number of calls
+test_num_calls_total{service="Service",method="MethodA"} 3
+test_num_calls_total{service="Service",method="MethodB"} 4
+# TYPE uptime_seconds gauge
+# UNIT uptime_seconds seconds
+# HELP uptime_seconds time since start
+uptime_seconds 90
+# EOF
+`
+	if output := buffer.String(); output != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestRegisterLabelsMismatch(t *testing.T) {
+	if err := RegisterLabels("/test/*", "a", "b"); err == nil {
+		t.Error("mismatched labels not rejected")
+	}
+}
+
+func TestSanitiseName(t *testing.T) {
+	for input, expected := range map[string]string{
+		"num-calls":   "num_calls",
+		"0day":        "_0day",
+		"a.b/c:d":     "a_b_c:d",
+		"ImageServer": "ImageServer",
+	} {
+		if output := sanitiseName(input); output != expected {
+			t.Errorf("sanitiseName(%s): %s != %s", input, output, expected)
+		}
+	}
+}
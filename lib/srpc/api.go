@@ -36,6 +36,21 @@ If the method call is established, the server sends a newline character. If
 the method call is rejected then an error message followed by a newline is
 sent.
 
+A client without a certificate may instead authenticate with a bearer token
+(such as an OpenID Connect JWT). The client adds "bearerToken=true" to the
+query of the HTTP CONNECT request to a secured endpoint, performs the TLS
+handshake without a certificate and then sends a second HTTP CONNECT request
+over the encrypted connection with the header "Authorization: Bearer <token>".
+The server responds with "200 OK" if the token is accepted, or with
+"401 Unauthorized" and closes the connection. The token is thus never sent in
+clear text. The identity (username, groups and permitted methods) is taken
+from the token claims.
+
+Servers which support trace context propagation include the header
+"Srpc-Features: traceparent" in the HTTP CONNECT response. Clients connected to
+such a server may follow the method name with a space and a W3C trace context
+of the form "traceparent=00-<trace-id>-<parent-id>-<flags>".
+
 The server then calls a registered method hander. The client and server can
 exchange messages using the appropriate coder (GOB is preferred, JSON is
 available as a fallback). Most method handlers wait for client messages and
@@ -61,6 +76,7 @@ import (
 	"github.com/Cloud-Foundations/Dominator/lib/log/debuglogger"
 	libnet "github.com/Cloud-Foundations/Dominator/lib/net"
 	"github.com/Cloud-Foundations/Dominator/lib/resourcepool"
+	"github.com/Cloud-Foundations/Dominator/lib/trace"
 )
 
 var (
@@ -71,6 +87,8 @@ var (
 	ErrorBadCertificate       = errors.New("bad certificate")
 	ErrorNoSrpcEndpoint       = errors.New("no SRPC endpoint")
 	ErrorAccessToMethodDenied = errors.New("access to method denied")
+	ErrorBadBearerToken       = errors.New("bad bearer token")
+	ErrorRateLimited          = errors.New("rate limited")
 
 	ErrorCloseClient = errors.New("close client")
 
@@ -79,10 +97,13 @@ var (
 )
 
 var (
-	clientTlsConfig    *tls.Config
-	fullAuthCaCertPool *x509.CertPool
-	serverTlsConfig    *tls.Config
-	tlsRequired        bool
+	clientBearerToken   func() (string, error)
+	clientTlsConfig     *tls.Config
+	fullAuthCaCertPool  *x509.CertPool
+	serverMethodBlocker MethodBlocker
+	serverTlsConfig     *tls.Config
+	tlsRequired         bool
+	tokenVerifier       TokenVerifier
 
 	logger log.DebugLogger = debuglogger.New(
 		stdlog.New(os.Stderr, "", stdlog.LstdFlags))
@@ -104,6 +125,18 @@ var (
 	srpcTrustedUsers  flagutil.StringSet
 )
 
+// CallLocalMethod calls the named Service.Method function which was registered
+// (with RegisterName) in the current process, on behalf of a remote caller.
+// The caller is authenticated and authorised using the verified certificate
+// chains in tlsState, using the same checks as for network connections. If
+// tlsState is nil the caller is unauthenticated. Messages are exchanged with
+// the method handler using the JSON coder. This is intended to be used by
+// gateways for other RPC protocols.
+func CallLocalMethod(serviceMethod string, tlsState *tls.ConnectionState) (
+	*LocalCall, error) {
+	return callLocalMethod(serviceMethod, tlsState)
+}
+
 // CheckTlsRequired returns true if the server requires TLS connections with
 // trusted certificates. It returns false if unencrypted or unauthenticated
 // connections are permitted (i.e. insecure mode).
@@ -128,6 +161,18 @@ func GetNumPanicedCalls() uint64 {
 	return getNumPanicedCalls()
 }
 
+// GetServerTlsConfig returns a clone of the server TLS config. If no server
+// TLS config has been registered, nil is returned.
+func GetServerTlsConfig() *tls.Config {
+	return serverTlsConfig.Clone()
+}
+
+// ListMethods returns the list of methods registered with RegisterName, sorted
+// by name.
+func ListMethods() []MethodInfo {
+	return listMethods()
+}
+
 // LoadCertificates loads zero or more X.509 certificates from directory. Each
 // certificate must be stored in a pair of PEM-encoded files, with the private
 // key in a file with extension '.key' and the corresponding public key
@@ -153,6 +198,24 @@ type AuthInformation struct {
 	Username         string
 }
 
+// AuditRecord contains information about a call to a method.
+type AuditRecord struct {
+	Duration   time.Duration
+	Error      string // Empty if the call succeeded.
+	GroupList  []string
+	Method     string // Service.Method
+	RemoteAddr string
+	Request    string // Summary of the request message (if any).
+	StartTime  time.Time
+	Username   string // Empty string for unauthenticated.
+}
+
+// AuditSink is the interface used to record calls to methods which may mutate
+// state. The Audit method should not block.
+type AuditSink interface {
+	Audit(record *AuditRecord)
+}
+
 type ClientI interface {
 	Call(serviceMethod string) (*Conn, error)
 	Close() error
@@ -178,6 +241,26 @@ type Encoder interface {
 
 type FakeClientOptions struct{}
 
+// LocalCall is an in-process method call created by CallLocalMethod. The
+// embedded Conn is used to exchange messages with the method handler.
+type LocalCall struct {
+	*Conn
+	done chan error
+	pipe net.Conn
+}
+
+// Close will close the connection to the method handler. Any further I/O by
+// the method handler will fail.
+func (call *LocalCall) Close() error {
+	return call.close()
+}
+
+// Wait will wait for the method handler to return and will return the error it
+// returned.
+func (call *LocalCall) Wait() error {
+	return call.wait()
+}
+
 // MethodBlocker defines an interface to block method calls (after possible
 // authorisation) for a receiver (passed to RegisterName). This may be used to
 // attach rate limiting polcies for method calls.
@@ -186,10 +269,19 @@ type MethodBlocker interface {
 	// the method. After the method call completes, the returned function is
 	// called. If this is nil, no function is called. If a non-nil error is
 	// returned then the method call is blocked and the remote caller will
-	// receive the error.
+	// receive the error. If the error wraps ErrorRateLimited, the remote
+	// caller will receive an error which wraps ErrorRateLimited.
 	BlockMethod(methodName string, authInfo *AuthInformation) (func(), error)
 }
 
+// MethodInfo describes a method registered with RegisterName.
+type MethodInfo struct {
+	IsPublic       bool   // Method does not require method powers.
+	IsRequestReply bool   // func Method(*Conn, request, *response) error
+	IsStreaming    bool   // func Method(*Conn, Decoder, Encoder) error
+	Name           string // Service.Method
+}
+
 // MethodGranter defines an interface to grant method calls (if access is not
 // granted by the built-in authorisation mechanism) for a receiver (passed to
 // RegisterName).
@@ -199,6 +291,21 @@ type MethodGranter interface {
 	GrantMethod(serviceMethod string, authInfo *AuthInformation) bool
 }
 
+// TokenIdentity is the identity asserted by a verified bearer token.
+type TokenIdentity struct {
+	GroupList        map[string]struct{}
+	PermittedMethods map[string]struct{} // nil: none permitted.
+	Username         string
+}
+
+// TokenVerifier defines an interface to verify bearer tokens presented by
+// clients (registered with RegisterTokenVerifier).
+type TokenVerifier interface {
+	// VerifyToken is called to verify a token. If the token is valid, the
+	// identity it asserts is returned.
+	VerifyToken(token string) (*TokenIdentity, error)
+}
+
 // RegisterName publishes in the server the set of methods of the receiver
 // value that satisfy one of the following interfaces:
 //
@@ -233,6 +340,16 @@ func RegisterClientTlsConfig(config *tls.Config) {
 	registerClientTlsConfig(config)
 }
 
+// RegisterClientBearerToken registers a function which returns a bearer token
+// to authenticate with when the client has no certificate. The function is
+// called for each new connection, so that short-lived tokens may be
+// refreshed. The token is only sent if the server certificate is verified
+// (i.e. the client TLS config does not set InsecureSkipVerify without also
+// providing a verification function).
+func RegisterClientBearerToken(getToken func() (string, error)) {
+	registerClientBearerToken(getToken)
+}
+
 // RegisterFullAuthCA registers the CA certificate pool used for full
 // authentication/authorisation checks (including method checks). If not
 // specified, the CA certificate pool registered with RegisterServerTlsConfig is
@@ -242,6 +359,22 @@ func RegisterFullAuthCA(certPool *x509.CertPool) {
 	fullAuthCaCertPool = certPool
 }
 
+// RegisterServerMethodBlocker registers a MethodBlocker which is called for
+// method calls to all receivers, prior to any MethodBlocker implemented by the
+// receiver. The methodName passed to the BlockMethod method is the full
+// Service.Method name. Builtin methods are not blocked.
+func RegisterServerMethodBlocker(blocker MethodBlocker) {
+	serverMethodBlocker = blocker
+}
+
+// RegisterTokenVerifier registers the verifier for bearer tokens presented by
+// clients which do not have a certificate. If no verifier is registered,
+// bearer tokens are rejected. The server TLS config should permit clients
+// without certificates (tls.VerifyClientCertIfGiven).
+func RegisterTokenVerifier(verifier TokenVerifier) {
+	registerTokenVerifier(verifier)
+}
+
 type privateClientResource struct {
 	clientResource *ClientResource
 	tlsConfig      *tls.Config
@@ -269,6 +402,14 @@ func SetDefaultGrantMethod(grantMethod func(serviceMethod string,
 	defaultGrantMethod = grantMethod
 }
 
+// SetAuditSink registers the sink which will be sent a record of every call to
+// a method which is not listed in ReceiverOptions.ReadOnlyMethods, including
+// calls which were denied. It should be called before the server starts
+// accepting connections. If sink is nil, auditing is disabled.
+func SetAuditSink(sink AuditSink) {
+	auditSink = sink
+}
+
 // SetDefaultLogger will override the default logger used.
 func SetDefaultLogger(l log.DebugLogger) {
 	logger = l
@@ -351,8 +492,10 @@ type Client struct {
 	makeCoder         coderMaker
 	remoteAddr        string
 	resource          *ClientResource
+	sendTraceParent   bool           // Server accepts traceparent in preamble.
 	tcpConn           libnet.TCPConn // The underlying raw TCP connection (if TCP).
 	timeout           time.Duration
+	traceSpan         *trace.Span // Parent for client call spans.
 }
 
 // DialHTTP connects to an HTTP SRPC server at the specified network address
@@ -469,6 +612,14 @@ func (client *Client) SetTimeout(timeout time.Duration) error {
 	return client.setTimeout(timeout)
 }
 
+// SetTraceSpan sets the parent span for subsequent method calls. Each call
+// creates a client span which is a child of span and the trace context is
+// propagated to the server. If span is nil, each call starts a new trace.
+// The parent span is cleared when the Client is released with Put.
+func (client *Client) SetTraceSpan(span *trace.Span) {
+	client.traceSpan = span
+}
+
 // RequestReply sends a request message to the named Service.Method function,
 // and waits for a reply. The request and reply messages are GOB encoded and
 // decoded, respectively. This method is a convenience wrapper around the Call
@@ -483,7 +634,11 @@ type Conn struct {
 	Encoder
 	*bufio.ReadWriter
 	allowMethodPowers bool
+	auditing          bool   // Server-side: method call is being audited.
+	auditRequest      string // Summary of first message received.
+	callError         error  // Error returned by request/reply method.
 	conn              net.Conn
+	counter           *countingConn // nil: client-side connection.
 	groupList         map[string]struct{}
 	haveMethodAccess  bool
 	isEncrypted       bool
@@ -492,6 +647,7 @@ type Conn struct {
 	permittedMethods  map[string]struct{} // nil: all, empty: none permitted.
 	releaseNotifier   func()
 	remoteAddr        string
+	traceSpan         *trace.Span
 	username          string // Empty string for unauthenticated.
 }
 
@@ -516,6 +672,15 @@ func (conn *Conn) GetCloseNotifier() <-chan error {
 	return conn.getCloseNotifier()
 }
 
+// GetTraceSpan will return the trace span for the method call. For a server
+// connection this is the span for the incoming call, which may be used as the
+// parent for spans created by the method. For a client connection this is the
+// span for the outgoing call. If tracing is disabled, nil is returned, which is
+// safe to use as a parent span.
+func (conn *Conn) GetTraceSpan() *trace.Span {
+	return conn.traceSpan
+}
+
 // IsEncrypted will return true if the underlying connection is TLS-encrypted.
 func (conn *Conn) IsEncrypted() bool {
 	return conn.isEncrypted
@@ -542,5 +707,6 @@ func (conn *Conn) Username() string {
 
 type ReceiverOptions struct {
 	PublicMethods          []string // Methods not requiring method powers.
+	ReadOnlyMethods        []string // Methods which are not audited.
 	UnauthenticatedMethods []string // Methods not requiring authentication.
 }
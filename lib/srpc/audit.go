@@ -0,0 +1,192 @@
+package srpc
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxAuditDepth         = 3
+	maxAuditListLength    = 8
+	maxAuditStringLength  = 256
+	maxAuditSummaryLength = 1024
+)
+
+var (
+	auditSink AuditSink
+
+	auditRedactedFields = []string{
+		"Credential",
+		"Key",
+		"Password",
+		"Secret",
+		"Token",
+	}
+)
+
+type auditDecoder struct {
+	Decoder
+	conn       *Conn
+	summarised bool
+}
+
+func (d *auditDecoder) Decode(e interface{}) error {
+	err := d.Decoder.Decode(e)
+	if err == nil && !d.summarised {
+		d.conn.auditRequest = summariseRequest(e)
+		d.summarised = true
+	}
+	return err
+}
+
+func isRedactedField(name string) bool {
+	for _, redacted := range auditRedactedFields {
+		if strings.Contains(name, redacted) {
+			return true
+		}
+	}
+	return false
+}
+
+func makeAuditGroupList(groupList map[string]struct{}) []string {
+	if len(groupList) < 1 {
+		return nil
+	}
+	groups := make([]string, 0, len(groupList))
+	for group := range groupList {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// summariseRequest will return a compact, human-readable summary of a request
+// message. Fields which may contain secrets are redacted and large values are
+// abbreviated.
+func summariseRequest(request interface{}) string {
+	builder := &strings.Builder{}
+	summariseValue(builder, reflect.ValueOf(request), 0)
+	summary := builder.String()
+	if len(summary) > maxAuditSummaryLength {
+		summary = summary[:maxAuditSummaryLength] + "..."
+	}
+	return summary
+}
+
+func summariseValue(builder *strings.Builder, value reflect.Value, depth int) {
+	if !value.IsValid() {
+		builder.WriteString("nil")
+		return
+	}
+	if value.CanInterface() {
+		if stringer, ok := value.Interface().(fmt.Stringer); ok {
+			if value.Kind() != reflect.Pointer || !value.IsNil() {
+				builder.WriteString(stringer.String())
+				return
+			}
+		}
+	}
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			builder.WriteString("nil")
+		} else {
+			summariseValue(builder, value.Elem(), depth)
+		}
+	case reflect.Struct:
+		if depth >= maxAuditDepth {
+			builder.WriteString("{...}")
+			return
+		}
+		builder.WriteString("{")
+		first := true
+		valueType := value.Type()
+		for index := 0; index < value.NumField(); index++ {
+			field := valueType.Field(index)
+			fieldValue := value.Field(index)
+			if !field.IsExported() || fieldValue.IsZero() {
+				continue
+			}
+			if !first {
+				builder.WriteString(" ")
+			}
+			first = false
+			builder.WriteString(field.Name)
+			builder.WriteString(":")
+			if isRedactedField(field.Name) {
+				builder.WriteString("<redacted>")
+			} else {
+				summariseValue(builder, fieldValue, depth+1)
+			}
+		}
+		builder.WriteString("}")
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			fmt.Fprintf(builder, "[%d bytes]", value.Len())
+			return
+		}
+		if depth >= maxAuditDepth || value.Len() > maxAuditListLength {
+			fmt.Fprintf(builder, "[%d items]", value.Len())
+			return
+		}
+		builder.WriteString("[")
+		for index := 0; index < value.Len(); index++ {
+			if index > 0 {
+				builder.WriteString(" ")
+			}
+			summariseValue(builder, value.Index(index), depth+1)
+		}
+		builder.WriteString("]")
+	case reflect.Map:
+		fmt.Fprintf(builder, "map[%d entries]", value.Len())
+	case reflect.String:
+		str := value.String()
+		if len(str) > maxAuditStringLength {
+			str = str[:maxAuditStringLength] + "..."
+		}
+		builder.WriteString(strconv.Quote(str))
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		builder.WriteString(value.Kind().String())
+	default:
+		fmt.Fprint(builder, value.Interface())
+	}
+}
+
+func (m *methodWrapper) audit(sink AuditSink, conn *Conn, startTime time.Time,
+	timeTaken time.Duration, err error) {
+	if err == nil || err == ErrorCloseClient {
+		err = conn.callError
+	}
+	record := &AuditRecord{
+		Duration:   timeTaken,
+		GroupList:  makeAuditGroupList(conn.groupList),
+		Method:     m.name,
+		RemoteAddr: conn.remoteAddr,
+		Request:    conn.auditRequest,
+		StartTime:  startTime,
+		Username:   conn.username,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	sink.Audit(record)
+}
+
+func (m *methodWrapper) auditDenied(conn *Conn) {
+	sink := auditSink
+	if sink == nil || m.readOnly {
+		return
+	}
+	sink.Audit(&AuditRecord{
+		Error:      ErrorAccessToMethodDenied.Error(),
+		GroupList:  makeAuditGroupList(conn.groupList),
+		Method:     m.name,
+		RemoteAddr: conn.remoteAddr,
+		StartTime:  time.Now(),
+		Username:   conn.username,
+	})
+}
@@ -0,0 +1,88 @@
+package srpc
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/proto/test"
+)
+
+type testAuditSink struct {
+	mutex   sync.Mutex
+	records []*AuditRecord
+}
+
+func (s *testAuditSink) Audit(record *AuditRecord) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records = append(s.records, record)
+}
+
+func (s *testAuditSink) waitForRecords(count int) []*AuditRecord {
+	for timeout := time.Now().Add(time.Second); time.Now().Before(timeout); {
+		s.mutex.Lock()
+		records := s.records
+		s.mutex.Unlock()
+		if len(records) >= count {
+			return records
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+func TestSummariseRequest(t *testing.T) {
+	type request struct {
+		Data     []byte
+		Hostname string
+		Password string
+		Tags     map[string]string
+		unused   string
+	}
+	summary := summariseRequest(&request{
+		Data:     []byte("secret"),
+		Hostname: "host",
+		Password: "hunter2",
+		Tags:     map[string]string{"key": "value"},
+	})
+	if strings.Contains(summary, "hunter2") {
+		t.Errorf("password not redacted: %s", summary)
+	}
+	expected := `{Data:[6 bytes] Hostname:"host" Password:<redacted> Tags:map[1 entries]}`
+	if summary != expected {
+		t.Errorf("summary: %s != %s", summary, expected)
+	}
+}
+
+func TestAuditRequestReply(t *testing.T) {
+	sink := &testAuditSink{}
+	SetAuditSink(sink)
+	defer SetAuditSink(nil)
+	client, err := makeListenerAndConnect(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	var response test.EchoResponse
+	err = client.RequestReply("Test.RequestReply",
+		test.EchoRequest{Request: "audited"}, &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := sink.waitForRecords(1)
+	if len(records) < 1 {
+		t.Fatal("timed out waiting for audit record")
+	}
+	record := records[0]
+	if record.Method != "Test.RequestReply" {
+		t.Errorf("method: %s != Test.RequestReply", record.Method)
+	}
+	if record.Request != `{Request:"audited"}` {
+		t.Errorf("request summary: %s", record.Request)
+	}
+	if record.Error != "" {
+		t.Errorf("unexpected error: %s", record.Error)
+	}
+}
@@ -0,0 +1,72 @@
+package srpc
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/proto/test"
+)
+
+type testServerBlocker struct {
+	mutex       sync.Mutex
+	blocked     bool
+	methodNames []string
+	numReleased uint
+}
+
+func (b *testServerBlocker) BlockMethod(methodName string,
+	authInfo *AuthInformation) (func(), error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.methodNames = append(b.methodNames, methodName)
+	if b.blocked {
+		return nil, fmt.Errorf("%w: test limit", ErrorRateLimited)
+	}
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		b.numReleased++
+	}, nil
+}
+
+func TestServerMethodBlocker(t *testing.T) {
+	blocker := &testServerBlocker{}
+	RegisterServerMethodBlocker(blocker)
+	defer RegisterServerMethodBlocker(nil)
+	client, err := makeClientServer(&gobCoder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	var response test.EchoResponse
+	err = client.RequestReply("Test.RequestReply",
+		test.EchoRequest{Request: "test0"}, &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocker.mutex.Lock()
+	blocker.blocked = true
+	blocker.mutex.Unlock()
+	err = client.RequestReply("Test.RequestReply",
+		test.EchoRequest{Request: "test1"}, &response)
+	if !errors.Is(err, ErrorRateLimited) {
+		t.Fatalf("error: %v does not wrap: %s", err, ErrorRateLimited)
+	}
+	if err.Error() != "rate limited: test limit" {
+		t.Errorf("error: \"%s\" != \"rate limited: test limit\"", err)
+	}
+	blocker.mutex.Lock()
+	defer blocker.mutex.Unlock()
+	if len(blocker.methodNames) != 2 {
+		t.Fatalf("blocker called %d times, expected 2",
+			len(blocker.methodNames))
+	}
+	if name := blocker.methodNames[0]; name != "Test.RequestReply" {
+		t.Errorf("method name: %s != Test.RequestReply", name)
+	}
+	if blocker.numReleased != 1 {
+		t.Errorf("released %d calls, expected 1", blocker.numReleased)
+	}
+}
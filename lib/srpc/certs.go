@@ -74,6 +74,9 @@ func validateTlsConfig(config *tls.Config) error {
 		return nil
 	}
 	if len(config.Certificates) < 1 {
+		if clientCanSendBearerToken(config) {
+			return nil
+		}
 		return fmt.Errorf("no certificates in TLS configuration")
 	}
 	now := time.Now()
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -177,7 +178,10 @@ func dialHTTPEndpoint(network, address string, tlsConfig *tls.Config,
 		}
 		dataConn = tlsConn
 	}
-	if err := doHTTPConnect(dataConn, endpoint.path); err != nil {
+	useBearerToken := endpoint.tls && clientCanSendBearerToken(tlsConfig)
+	sendTraceParent, err := doHTTPConnect(dataConn, endpoint.path,
+		useBearerToken)
+	if err != nil {
 		return nil, err
 	}
 	if endpoint.tls && !fullTLS {
@@ -190,13 +194,24 @@ func dialHTTPEndpoint(network, address string, tlsConfig *tls.Config,
 		}
 		dataConn = tlsConn
 	}
+	if useBearerToken {
+		if err := sendBearerToken(dataConn, endpoint.path); err != nil {
+			return nil, err
+		}
+	}
 	if *srpcDefaultConnectTimeout > 0 {
 		if err := unsecuredConn.SetDeadline(time.Time{}); err != nil {
 			return nil, err
 		}
 	}
 	doClose = false
-	return newClient(unsecuredConn, dataConn, endpoint.tls, endpoint.coderMaker)
+	client, err := newClient(unsecuredConn, dataConn, endpoint.tls,
+		endpoint.coderMaker)
+	if err != nil {
+		return nil, err
+	}
+	client.sendTraceParent = sendTraceParent
+	return client, nil
 }
 
 func dialHTTPEndpoints(network, address string, tlsConfig *tls.Config,
@@ -214,31 +229,46 @@ func dialHTTPEndpoints(network, address string, tlsConfig *tls.Config,
 	return nil, ErrorNoSrpcEndpoint
 }
 
-func doHTTPConnect(conn net.Conn, path string) error {
-	var query string
+// doHTTPConnect returns true if the server accepts trace context in the method
+// call preamble.
+func doHTTPConnect(conn net.Conn, path string,
+	useBearerToken bool) (bool, error) {
+	var queryParams []string
 	if *srpcClientDoNotUseMethodPowers {
-		query = "?" + doNotUseMethodPowers + "=true"
+		queryParams = append(queryParams, doNotUseMethodPowers+"=true")
+	}
+	if useBearerToken {
+		queryParams = append(queryParams, bearerTokenParam+"=true")
+	}
+	var query string
+	if len(queryParams) > 0 {
+		query = "?" + strings.Join(queryParams, "&")
 	}
 	io.WriteString(conn, "CONNECT "+path+query+" HTTP/1.0\n\n")
 	// Require successful HTTP response before switching to SRPC protocol.
 	resp, err := http.ReadResponse(bufio.NewReader(conn),
 		&http.Request{Method: "CONNECT"})
 	if err != nil {
-		return err
+		return false, err
 	}
 	if resp.StatusCode == http.StatusNotFound {
-		return ErrorNoSrpcEndpoint
+		return false, ErrorNoSrpcEndpoint
 	}
 	if resp.StatusCode == http.StatusUnauthorized {
-		return ErrorBadCertificate
+		return false, ErrorBadCertificate
 	}
 	if resp.StatusCode == http.StatusMethodNotAllowed {
-		return ErrorMissingCertificate
+		return false, ErrorMissingCertificate
 	}
 	if resp.StatusCode != http.StatusOK || resp.Status != connectString {
-		return errors.New("unexpected HTTP response: " + resp.Status)
+		return false, errors.New("unexpected HTTP response: " + resp.Status)
 	}
-	return nil
+	for _, feature := range resp.Header.Values(featuresHeader) {
+		if feature == featureTraceParent {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func newClient(rawConn, dataConn net.Conn, isEncrypted bool,
@@ -323,23 +353,41 @@ func (client *Client) callWithLock(serviceMethod string) (*Conn, error) {
 			return nil, err
 		}
 	}
-	_, err := client.bufrw.WriteString(serviceMethod + "\n")
+	span := client.startTraceSpan(serviceMethod)
+	preamble := serviceMethod
+	if span != nil && client.sendTraceParent {
+		preamble += " traceparent=" + span.TraceParent()
+	}
+	_, err := client.bufrw.WriteString(preamble + "\n")
 	if err != nil {
+		span.SetError(err)
+		span.End()
 		return nil, err
 	}
 	if err = client.bufrw.Flush(); err != nil {
+		span.SetError(err)
+		span.End()
 		return nil, err
 	}
 	resp, err := client.bufrw.ReadString('\n')
 	if err != nil {
+		span.SetError(err)
+		span.End()
 		return nil, err
 	}
 	if resp != "\n" {
 		resp := resp[:len(resp)-1]
 		if resp == ErrorAccessToMethodDenied.Error() {
-			return nil, ErrorAccessToMethodDenied
+			err = ErrorAccessToMethodDenied
+		} else if detail, ok := strings.CutPrefix(resp,
+			ErrorRateLimited.Error()); ok {
+			err = fmt.Errorf("%w%s", ErrorRateLimited, detail)
+		} else {
+			err = errors.New(resp)
 		}
-		return nil, errors.New(resp)
+		span.SetError(err)
+		span.End()
+		return nil, err
 	}
 	conn := &Conn{
 		Decoder:     client.makeCoder.MakeDecoder(client.bufrw),
@@ -348,6 +396,7 @@ func (client *Client) callWithLock(serviceMethod string) (*Conn, error) {
 		isEncrypted: client.isEncrypted,
 		ReadWriter:  client.bufrw,
 		remoteAddr:  client.remoteAddr,
+		traceSpan:   span,
 	}
 	return conn, nil
 }
@@ -413,7 +462,9 @@ func (conn *Conn) requestReply(request interface{}, reply interface{}) error {
 		return err
 	}
 	if str != "\n" {
-		return errors.New(str[:len(str)-1])
+		err := errors.New(str[:len(str)-1])
+		conn.traceSpan.SetError(err)
+		return err
 	}
 	return conn.Decode(reply)
 }
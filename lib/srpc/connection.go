@@ -7,6 +7,7 @@ import (
 
 func (conn *Conn) close() error {
 	err := conn.Flush()
+	conn.traceSpan.End()
 	if client := conn.parent; client != nil {
 		if client.timeout > 0 {
 			client.conn.SetDeadline(time.Time{})
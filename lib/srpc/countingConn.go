@@ -0,0 +1,31 @@
+package srpc
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// countingConn counts the number of bytes read from and written to a server
+// connection, so that they may be attributed to method calls.
+type countingConn struct {
+	net.Conn
+	numRead    uint64
+	numWritten uint64
+}
+
+func (conn *countingConn) counts() (uint64, uint64) {
+	return atomic.LoadUint64(&conn.numRead),
+		atomic.LoadUint64(&conn.numWritten)
+}
+
+func (conn *countingConn) Read(b []byte) (int, error) {
+	nRead, err := conn.Conn.Read(b)
+	atomic.AddUint64(&conn.numRead, uint64(nRead))
+	return nRead, err
+}
+
+func (conn *countingConn) Write(b []byte) (int, error) {
+	nWritten, err := conn.Conn.Write(b)
+	atomic.AddUint64(&conn.numWritten, uint64(nWritten))
+	return nWritten, err
+}
@@ -0,0 +1,114 @@
+package srpc
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"sort"
+)
+
+func callLocalMethod(serviceMethod string,
+	tlsState *tls.ConnectionState) (*LocalCall, error) {
+	serverPipe, clientPipe := net.Pipe()
+	counter := &countingConn{Conn: serverPipe}
+	serverConn := &Conn{
+		allowMethodPowers: true,
+		conn:              serverPipe,
+		counter:           counter,
+		localAddr:         "local",
+		permittedMethods:  emptyMethodList, // Safe default: none permitted.
+		remoteAddr:        "local",
+		ReadWriter: bufio.NewReadWriter(bufio.NewReader(counter),
+			bufio.NewWriter(counter)),
+	}
+	if tlsState != nil {
+		if serverTlsConfig == nil ||
+			!checkVerifiedChains(tlsState.VerifiedChains,
+				serverTlsConfig.ClientCAs) {
+			serverPipe.Close()
+			clientPipe.Close()
+			return nil, ErrorBadCertificate
+		}
+		var err error
+		serverConn.username, serverConn.permittedMethods,
+			serverConn.groupList, err = getAuth(*tlsState)
+		if err != nil {
+			serverPipe.Close()
+			clientPipe.Close()
+			return nil, err
+		}
+		serverConn.isEncrypted = true
+	} else if !tlsRequired {
+		serverConn.permittedMethods = nil // All methods permitted.
+	}
+	method, err := serverConn.findMethod(serviceMethod)
+	if err != nil {
+		serverPipe.Close()
+		clientPipe.Close()
+		return nil, err
+	}
+	makeCoder := &jsonCoder{}
+	bufrw := bufio.NewReadWriter(bufio.NewReader(clientPipe),
+		bufio.NewWriter(clientPipe))
+	done := make(chan error, 1)
+	call := &LocalCall{
+		Conn: &Conn{
+			Decoder:     makeCoder.MakeDecoder(bufrw),
+			Encoder:     makeCoder.MakeEncoder(bufrw),
+			ReadWriter:  bufrw,
+			conn:        clientPipe,
+			isEncrypted: serverConn.isEncrypted,
+			localAddr:   "local",
+			remoteAddr:  "local",
+		},
+		done: done,
+		pipe: clientPipe,
+	}
+	go func() {
+		defer serverPipe.Close()
+		defer serverConn.callReleaseNotifier()
+		serverConn.startTraceSpan(serviceMethod, "")
+		err := method.call(serverConn, makeCoder)
+		if e := serverConn.Flush(); err == nil {
+			err = e
+		}
+		serverConn.endTraceSpan(err)
+		done <- err
+	}()
+	return call, nil
+}
+
+func listMethods() []MethodInfo {
+	var methods []MethodInfo
+	for receiverName, receiver := range receivers {
+		if receiverName == "" {
+			continue
+		}
+		for name, method := range receiver.methods {
+			methods = append(methods, MethodInfo{
+				IsPublic:       method.public,
+				IsRequestReply: method.methodType == methodTypeRequestReply,
+				IsStreaming:    method.methodType == methodTypeCoder,
+				Name:           receiverName + "." + name,
+			})
+		}
+	}
+	sort.Slice(methods, func(left, right int) bool {
+		return methods[left].Name < methods[right].Name
+	})
+	return methods
+}
+
+func (call *LocalCall) close() error {
+	err := call.Conn.Flush()
+	if e := call.pipe.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (call *LocalCall) wait() error {
+	err := <-call.done
+	call.done <- err // Allow multiple calls to Wait.
+	return err
+}
@@ -0,0 +1,79 @@
+package srpc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Cloud-Foundations/Dominator/proto/test"
+)
+
+func TestLocalCallPlain(t *testing.T) {
+	call, err := CallLocalMethod("Test.Plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer call.Close()
+	if err := call.Encode(test.EchoRequest{Request: "local"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := call.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	var response test.EchoResponse
+	if err := call.Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Response != "local" {
+		t.Errorf("Response: %s != local\n", response.Response)
+	}
+	if err := call.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalCallRequestReply(t *testing.T) {
+	call, err := CallLocalMethod("Test.RequestReply", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer call.Close()
+	var response test.EchoResponse
+	err = call.RequestReply(test.EchoRequest{Request: "local"}, &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Response != "local" {
+		t.Errorf("Response: %s != local\n", response.Response)
+	}
+	if err := call.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalCallUnknownMethod(t *testing.T) {
+	if _, err := CallLocalMethod("Test.None", nil); err == nil {
+		t.Fatal("no failure when calling unknown method")
+	} else if !strings.Contains(err.Error(), "unknown method") {
+		t.Fatal(err)
+	}
+}
+
+func TestListMethods(t *testing.T) {
+	found := make(map[string]MethodInfo)
+	for _, method := range ListMethods() {
+		found[method.Name] = method
+	}
+	if method, ok := found["Test.RequestReply"]; !ok {
+		t.Fatal("Test.RequestReply not listed")
+	} else if !method.IsRequestReply {
+		t.Error("Test.RequestReply not a request/reply method")
+	}
+	if method, ok := found["Test.Plain"]; !ok {
+		t.Fatal("Test.Plain not listed")
+	} else if method.IsRequestReply || method.IsStreaming {
+		t.Error("Test.Plain not a raw method")
+	}
+	if _, ok := found[".LocalUpgradeToUnix"]; ok {
+		t.Error("builtin method listed")
+	}
+}
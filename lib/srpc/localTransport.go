@@ -184,6 +184,10 @@ func (*builtinReceiver) LocalUpgradeToUnix(conn *Conn) error {
 	}
 	doClose = false
 	conn.conn.Close()
+	if conn.counter != nil {
+		conn.counter.Conn = newConn
+		newConn = conn.counter
+	}
 	conn.conn = newConn
 	conn.ReadWriter = bufio.NewReadWriter(
 		bufio.NewReaderSize(newConn, unixBufferSize),
@@ -32,6 +32,7 @@ func (cr *ClientResource) getHTTP(tlsConfig *tls.Config,
 }
 
 func (client *Client) put() {
+	client.traceSpan = nil
 	client.resource.resource.Put()
 	if client.resource.inUse {
 		clientMetricsMutex.Lock()
@@ -22,6 +22,7 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/lib/log/prefixlogger"
 	"github.com/Cloud-Foundations/Dominator/lib/net"
+	"github.com/Cloud-Foundations/Dominator/lib/openmetrics"
 	"github.com/Cloud-Foundations/Dominator/lib/stringutil"
 	"github.com/Cloud-Foundations/Dominator/lib/x509util"
 	"github.com/Cloud-Foundations/tricorder/go/tricorder"
@@ -46,11 +47,18 @@ const (
 	methodTypeRequestReply
 )
 
+const (
+	featuresHeader     = "Srpc-Features" // Sent in the CONNECT response.
+	featureTraceParent = "traceparent"   // Preamble may include traceparent.
+)
+
 type builtinReceiver struct{} // NOTE: GrantMethod allows all access.
 
 type methodWrapper struct {
 	methodType                    int
+	name                          string // Service.Method
 	public                        bool
+	readOnly                      bool
 	unauthenticatedPermitted      bool
 	fn                            reflect.Value
 	requestType                   reflect.Type
@@ -58,8 +66,12 @@ type methodWrapper struct {
 	metricsMutex                  sync.Mutex
 	failedCallsDistribution       *tricorder.CumulativeDistribution
 	failedRRCallsDistribution     *tricorder.CumulativeDistribution
+	numBytesReceived              uint64
+	numBytesSent                  uint64
 	numDeniedCalls                uint64
+	numFailedCalls                uint64
 	numPermittedCalls             uint64
+	numRateLimitedCalls           uint64
 	numRunningCalls               uint64
 	successfulCallsDistribution   *tricorder.CumulativeDistribution
 	successfulRRCallsDistribution *tricorder.CumulativeDistribution
@@ -160,6 +172,22 @@ func registerServerMetrics() {
 		panic(err)
 	}
 	bucketer = tricorder.NewGeometricBucketer(0.1, 1e5)
+	err = openmetrics.RegisterLabels("/srpc/server/*/*", "service", "method")
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range []string{
+		"num-bytes-received",
+		"num-bytes-sent",
+		"num-denied-calls",
+		"num-failed-calls",
+		"num-permitted-calls",
+		"num-rate-limited-calls",
+	} {
+		openmetrics.RegisterCounter("/srpc/server/*/*/" + name)
+	}
+	openmetrics.RegisterCounter("/srpc/server/num-connections")
+	openmetrics.RegisterCounter("/srpc/server/num-rejected-connections")
 }
 
 func defaultMethodBlocker(methodName string,
@@ -204,6 +232,8 @@ func _registerName(name string, rcvr interface{},
 		return err
 	}
 	publicMethods := stringutil.ConvertListToMap(options.PublicMethods, false)
+	readOnlyMethods := stringutil.ConvertListToMap(options.ReadOnlyMethods,
+		false)
 	unauthenticatedMethods := stringutil.ConvertListToMap(
 		options.UnauthenticatedMethods, false)
 	for index := 0; index < typeOfReceiver.NumMethod(); index++ {
@@ -217,9 +247,13 @@ func _registerName(name string, rcvr interface{},
 			continue
 		}
 		receiver.methods[method.Name] = mVal
+		mVal.name = name + "." + method.Name
 		if _, ok := publicMethods[method.Name]; ok {
 			mVal.public = true
 		}
+		if _, ok := readOnlyMethods[method.Name]; ok || name == "" {
+			mVal.readOnly = true // Builtin methods are never audited.
+		}
 		if _, ok := unauthenticatedMethods[method.Name]; ok {
 			someUnauthenticatedMethods = true
 			mVal.unauthenticatedPermitted = true
@@ -299,16 +333,37 @@ func (m *methodWrapper) registerMetrics(dir *tricorder.DirectorySpec) error {
 	if err != nil {
 		return err
 	}
+	err = dir.RegisterMetric("num-bytes-received", &m.numBytesReceived,
+		units.Byte, "number of bytes received during calls to method")
+	if err != nil {
+		return err
+	}
+	err = dir.RegisterMetric("num-bytes-sent", &m.numBytesSent,
+		units.Byte, "number of bytes sent during calls to method")
+	if err != nil {
+		return err
+	}
 	err = dir.RegisterMetric("num-denied-calls", &m.numDeniedCalls,
 		units.None, "number of denied calls to method")
 	if err != nil {
 		return err
 	}
+	err = dir.RegisterMetric("num-failed-calls", &m.numFailedCalls,
+		units.None, "number of failed calls to method")
+	if err != nil {
+		return err
+	}
 	err = dir.RegisterMetric("num-permitted-calls", &m.numPermittedCalls,
 		units.None, "number of permitted calls to method")
 	if err != nil {
 		return err
 	}
+	err = dir.RegisterMetric("num-rate-limited-calls",
+		&m.numRateLimitedCalls, units.None,
+		"number of rate limited calls to method")
+	if err != nil {
+		return err
+	}
 	err = dir.RegisterMetric("num-running-calls", &m.numRunningCalls,
 		units.None, "number of running calls to method")
 	if err != nil {
@@ -408,7 +463,9 @@ func httpHandler(w http.ResponseWriter, req *http.Request, doTls bool,
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	if doTls && req.TLS != nil {
+	useBearerToken := doTls &&
+		req.URL.Query().Get(bearerTokenParam) == "true"
+	if doTls && req.TLS != nil && !useBearerToken {
 		if serverTlsConfig == nil ||
 			!checkVerifiedChains(req.TLS.VerifiedChains,
 				serverTlsConfig.ClientCAs) {
@@ -469,16 +526,21 @@ func httpHandler(w http.ResponseWriter, req *http.Request, doTls bool,
 		logger.Println("non-TCP connection")
 		return
 	}
-	_, err = io.WriteString(unsecuredConn, "HTTP/1.0 "+connectString+"\n\n")
+	_, err = io.WriteString(unsecuredConn, "HTTP/1.0 "+connectString+"\n"+
+		featuresHeader+": "+featureTraceParent+"\n\n")
 	if err != nil {
 		logger.Printf("error writing connect message: %s\n", err)
 		return
 	}
+	counter := &countingConn{Conn: unsecuredConn}
+	myConn.counter = counter
 	if doTls {
+		var dataConn io.ReadWriter = counter
 		var tlsConn *tls.Conn
 		if req.TLS == nil {
-			tlsConn = tls.Server(unsecuredConn, serverTlsConfig)
+			tlsConn = tls.Server(counter, serverTlsConfig)
 			myConn.conn = tlsConn
+			dataConn = tlsConn
 			if err := tlsHandshake(tlsConn); err != nil {
 				serverMetricsMutex.Lock()
 				numRejectedServerConnections++
@@ -495,19 +557,45 @@ func httpHandler(w http.ResponseWriter, req *http.Request, doTls bool,
 			connType += "/TLS"
 		}
 		myConn.isEncrypted = true
-		myConn.username, myConn.permittedMethods, myConn.groupList, err =
-			getAuth(tlsConn.ConnectionState())
-		if err != nil {
-			logger.Println(err)
-			return
+		myConn.ReadWriter = bufio.NewReadWriter(bufio.NewReader(dataConn),
+			bufio.NewWriter(dataConn))
+		if useBearerToken {
+			if err := myConn.authenticateBearerToken(); err != nil {
+				serverMetricsMutex.Lock()
+				numRejectedServerConnections++
+				serverMetricsMutex.Unlock()
+				logger.Println(err)
+				return
+			}
+			connType += "/bearer"
+		} else {
+			state := tlsConn.ConnectionState()
+			if len(state.VerifiedChains) < 1 {
+				// Possible if clients without certificates are permitted.
+				serverMetricsMutex.Lock()
+				numRejectedServerConnections++
+				serverMetricsMutex.Unlock()
+				logger.Println("no client certificate or bearer token")
+				return
+			}
+			myConn.username, myConn.permittedMethods, myConn.groupList, err =
+				getAuth(state)
+			if err != nil {
+				logger.Println(err)
+				return
+			}
 		}
-		myConn.ReadWriter = bufio.NewReadWriter(bufio.NewReader(tlsConn),
-			bufio.NewWriter(tlsConn))
 	} else {
 		if !tlsRequired {
 			myConn.permittedMethods = nil // All methods permitted.
 		}
-		myConn.ReadWriter = bufrw
+		if bufrw.Reader.Buffered() > 0 {
+			myConn.counter = nil // Cannot count without losing buffered data.
+			myConn.ReadWriter = bufrw
+		} else {
+			myConn.ReadWriter = bufio.NewReadWriter(bufio.NewReader(counter),
+				bufio.NewWriter(counter))
+		}
 	}
 	logger.Debugf(0, "accepted %s connection\n", connType)
 	serverMetricsMutex.Lock()
@@ -576,7 +664,7 @@ func handleConnection(conn *Conn, makeCoder coderMaker) {
 	defer conn.Flush()
 	for ; ; conn.Flush() {
 		conn.callReleaseNotifier()
-		serviceMethod, err := conn.ReadString('\n')
+		preamble, err := conn.ReadString('\n')
 		if err == io.EOF || err == io.ErrUnexpectedEOF {
 			return
 		}
@@ -588,7 +676,7 @@ func handleConnection(conn *Conn, makeCoder coderMaker) {
 			}
 			continue
 		}
-		serviceMethod = strings.TrimSpace(serviceMethod)
+		serviceMethod, traceParent := parsePreamble(preamble)
 		if serviceMethod == "" {
 			// Received a "ping" request, send response.
 			if _, err := conn.WriteString("\n"); err != nil {
@@ -614,7 +702,10 @@ func handleConnection(conn *Conn, makeCoder coderMaker) {
 			logger.Println(err)
 			return
 		}
-		if err := method.call(conn, makeCoder); err != nil {
+		conn.startTraceSpan(serviceMethod, traceParent)
+		err = method.call(conn, makeCoder)
+		conn.endTraceSpan(err)
+		if err != nil {
 			if err != ErrorCloseClient {
 				logger.Println(err)
 			}
@@ -623,6 +714,22 @@ func handleConnection(conn *Conn, makeCoder coderMaker) {
 	}
 }
 
+// parsePreamble splits a method call preamble of the form:
+// "Service.Method [traceparent=value]" into its components.
+func parsePreamble(preamble string) (string, string) {
+	fields := strings.Fields(preamble)
+	if len(fields) < 1 {
+		return "", ""
+	}
+	var traceParent string
+	for _, field := range fields[1:] {
+		if value, ok := strings.CutPrefix(field, "traceparent="); ok {
+			traceParent = value
+		}
+	}
+	return fields[0], traceParent
+}
+
 func (conn *Conn) callReleaseNotifier() {
 	if releaseNotifier := conn.releaseNotifier; releaseNotifier != nil {
 		releaseNotifier()
@@ -660,17 +767,50 @@ func (conn *Conn) findMethod(serviceMethod string) (*methodWrapper, error) {
 		method.metricsMutex.Lock()
 		method.numDeniedCalls++
 		method.metricsMutex.Unlock()
+		method.auditDenied(conn)
 		return nil, ErrorAccessToMethodDenied
 	}
 	authInfo := conn.GetAuthInformation()
-	if rn, err := receiver.blockMethod(methodName, authInfo); err != nil {
-		return nil, err
-	} else {
+	var serverReleaseNotifier func()
+	if blocker := serverMethodBlocker; blocker != nil && serviceName != "" {
+		rn, err := blocker.BlockMethod(serviceMethod, authInfo)
+		if err != nil {
+			return nil, method.blocked(err)
+		}
+		serverReleaseNotifier = rn
+	}
+	rn, err := receiver.blockMethod(methodName, authInfo)
+	if err != nil {
+		if serverReleaseNotifier != nil {
+			serverReleaseNotifier()
+		}
+		return nil, method.blocked(err)
+	}
+	if serverReleaseNotifier == nil {
 		conn.releaseNotifier = rn
+	} else if rn == nil {
+		conn.releaseNotifier = serverReleaseNotifier
+	} else {
+		conn.releaseNotifier = func() {
+			rn()
+			serverReleaseNotifier()
+		}
 	}
 	return method, nil
 }
 
+// blocked records a blocked call to the method and returns the error to send
+// to the caller.
+func (m *methodWrapper) blocked(err error) error {
+	if !errors.Is(err, ErrorRateLimited) {
+		return err
+	}
+	m.metricsMutex.Lock()
+	m.numRateLimitedCalls++
+	m.metricsMutex.Unlock()
+	return err
+}
+
 // checkMethodAccess implements the built-in authorisation checks. It returns
 // true if the method is permitted, else false if denied.
 func (conn *Conn) checkMethodAccess(serviceMethod string) bool {
@@ -762,16 +902,42 @@ func (m *methodWrapper) call(conn *Conn, makeCoder coderMaker) error {
 	m.numPermittedCalls++
 	m.numRunningCalls++
 	m.metricsMutex.Unlock()
+	counter := conn.counter
+	var numRead, numWritten uint64
+	if counter != nil {
+		numRead, numWritten = counter.counts()
+	}
+	sink := auditSink
+	if m.readOnly {
+		sink = nil
+	}
+	conn.auditing = sink != nil
+	conn.auditRequest = ""
+	conn.callError = nil
 	startTime := time.Now()
 	err := m._call(conn, makeCoder)
 	timeTaken := time.Since(startTime)
+	if sink != nil {
+		m.audit(sink, conn, startTime, timeTaken, err)
+		conn.auditing = false
+	}
 	if err == nil {
 		m.successfulCallsDistribution.Add(timeTaken)
 	} else {
 		m.failedCallsDistribution.Add(timeTaken)
 	}
+	var newNumRead, newNumWritten uint64
+	if counter != nil {
+		conn.Flush() // Ensure buffered replies are counted.
+		newNumRead, newNumWritten = counter.counts()
+	}
 	m.metricsMutex.Lock()
 	m.numRunningCalls--
+	if err != nil {
+		m.numFailedCalls++
+	}
+	m.numBytesReceived += newNumRead - numRead
+	m.numBytesSent += newNumWritten - numWritten
 	m.metricsMutex.Unlock()
 	return err
 }
@@ -795,6 +961,9 @@ func (m *methodWrapper) _call(conn *Conn, makeCoder coderMaker) error {
 	}()
 	connValue := reflect.ValueOf(conn)
 	conn.Decoder = makeCoder.MakeDecoder(conn)
+	if conn.auditing {
+		conn.Decoder = &auditDecoder{Decoder: conn.Decoder, conn: conn}
+	}
 	conn.Encoder = makeCoder.MakeEncoder(conn)
 	switch m.methodType {
 	case methodTypeRaw:
@@ -830,6 +999,8 @@ func (m *methodWrapper) _call(conn *Conn, makeCoder coderMaker) error {
 		if errInter != nil {
 			m.failedRRCallsDistribution.Add(timeTaken)
 			err := errInter.(error)
+			conn.callError = err
+			conn.traceSpan.SetError(err)
 			_, err = conn.WriteString(err.Error() + "\n")
 			return err
 		}
@@ -1,27 +1,116 @@
 package serverutil
 
 import (
+	"io"
 	"sync"
+	"time"
 
 	"github.com/Cloud-Foundations/Dominator/lib/srpc"
 )
 
+// MethodLimiter implements the srpc.MethodBlocker interface, applying the
+// rate and concurrency limits in a MethodLimitsConfiguration. It should be
+// registered with srpc.RegisterServerMethodBlocker, as the method names are
+// matched in the Service.Method form.
+type MethodLimiter struct {
+	rules []*methodLimitRule
+}
+
+// MethodLimitRule specifies the limits for calls matching the rule. A method
+// call must be permitted by all the matching rules.
+type MethodLimitRule struct {
+	Name               string   // Used in metrics and on the status page.
+	Methods            []string `json:",omitempty"` // Service.Method patterns.
+	Users              []string `json:",omitempty"` // Empty: all callers.
+	Groups             []string `json:",omitempty"` // Empty: all callers.
+	PerMethod          bool     `json:",omitempty"` // Limits for each method.
+	PerUser            bool     `json:",omitempty"` // Limits for each user.
+	CallsPerSecond     float64  `json:",omitempty"` // Token bucket rate.
+	Burst              uint     `json:",omitempty"` // Token bucket size.
+	MaxConcurrentCalls uint     `json:",omitempty"`
+	QueueTimeout       string   `json:",omitempty"` // Example: "2s".
+}
+
+// MethodLimitsConfiguration contains the rules for a MethodLimiter.
+type MethodLimitsConfiguration struct {
+	Rules []MethodLimitRule
+}
+
 type PerUserMethodLimiter struct {
 	mutex               sync.Mutex
 	perUserMethodCounts map[userMethodType]uint
 	perUserMethodLimits map[string]uint
 }
 
+type limitKeyType struct {
+	method   string
+	username string
+}
+
+type limitStateType struct {
+	lastRefill time.Time
+	numRunning uint
+	numWaiting uint
+	released   chan struct{} // Closed when a call is released.
+	tokens     float64
+}
+
+type methodLimitRule struct {
+	MethodLimitRule
+	burst               float64
+	groups              map[string]struct{}
+	queueTimeout        time.Duration
+	users               map[string]struct{}
+	mutex               sync.Mutex // Protect everything below.
+	lastSweep           time.Time
+	limits              map[limitKeyType]*limitStateType
+	numPermittedCalls   uint64
+	numQueuedCalls      uint64
+	numRateLimitedCalls uint64
+	numRunningCalls     uint64
+}
+
 type userMethodType struct {
 	method   string
 	username string
 }
 
+// LoadMethodLimiter reads a JSON encoded MethodLimitsConfiguration from the
+// specified file and returns a MethodLimiter.
+func LoadMethodLimiter(filename string) (*MethodLimiter, error) {
+	return loadMethodLimiter(filename)
+}
+
+// NewMethodLimiter returns a MethodLimiter for the specified configuration.
+// Rules with CallsPerSecond set will reject calls when the token bucket is
+// empty and rules with MaxConcurrentCalls set will reject calls when the
+// limit is reached, unless the call can proceed within QueueTimeout. Rejected
+// calls return an error wrapping srpc.ErrorRateLimited.
+func NewMethodLimiter(config MethodLimitsConfiguration) (
+	*MethodLimiter, error) {
+	return newMethodLimiter(config)
+}
+
 func NewPerUserMethodLimiter(
 	perUserMethodLimits map[string]uint) *PerUserMethodLimiter {
 	return newPerUserMethodLimiter(perUserMethodLimits)
 }
 
+func (limiter *MethodLimiter) BlockMethod(serviceMethod string,
+	authInfo *srpc.AuthInformation) (func(), error) {
+	return limiter.blockMethod(serviceMethod, authInfo)
+}
+
+// RegisterMetrics registers the counters for each rule under the
+// /srpc/method-limits tricorder directory. It should be called only once.
+func (limiter *MethodLimiter) RegisterMetrics() error {
+	return limiter.registerMetrics()
+}
+
+func (limiter *MethodLimiter) WriteHtml(writer io.Writer) {
+	limiter.writeHtml(writer)
+}
+
 func (limiter *PerUserMethodLimiter) BlockMethod(methodName string,
 	authInfo *srpc.AuthInformation) (func(), error) {
 	return limiter.blockMethod(methodName, authInfo)
@@ -0,0 +1,414 @@
+package serverutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/format"
+	"github.com/Cloud-Foundations/Dominator/lib/html"
+	"github.com/Cloud-Foundations/Dominator/lib/json"
+	"github.com/Cloud-Foundations/Dominator/lib/openmetrics"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/stringutil"
+	"github.com/Cloud-Foundations/tricorder/go/tricorder"
+	"github.com/Cloud-Foundations/tricorder/go/tricorder/units"
+)
+
+const sweepInterval = time.Minute
+
+func loadMethodLimiter(filename string) (*MethodLimiter, error) {
+	var config MethodLimitsConfiguration
+	if err := json.ReadFromFile(filename, &config); err != nil {
+		return nil, fmt.Errorf("error reading: %s: %s", filename, err)
+	}
+	limiter, err := newMethodLimiter(config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return limiter, nil
+}
+
+func newMethodLimiter(config MethodLimitsConfiguration) (
+	*MethodLimiter, error) {
+	limiter := &MethodLimiter{}
+	names := make(map[string]struct{}, len(config.Rules))
+	for _, ruleConfig := range config.Rules {
+		rule, err := newMethodLimitRule(ruleConfig)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := names[rule.Name]; ok {
+			return nil, fmt.Errorf("duplicate rule: %s", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+		limiter.rules = append(limiter.rules, rule)
+	}
+	return limiter, nil
+}
+
+func newMethodLimitRule(config MethodLimitRule) (*methodLimitRule, error) {
+	if config.Name == "" {
+		return nil, errors.New("rule has no name")
+	}
+	if strings.Contains(config.Name, "/") {
+		return nil, fmt.Errorf("rule: %s: name contains \"/\"", config.Name)
+	}
+	if config.CallsPerSecond < 0 {
+		return nil, fmt.Errorf("rule: %s: negative CallsPerSecond",
+			config.Name)
+	}
+	if config.CallsPerSecond <= 0 && config.MaxConcurrentCalls < 1 {
+		return nil, fmt.Errorf(
+			"rule: %s: no CallsPerSecond or MaxConcurrentCalls", config.Name)
+	}
+	for _, pattern := range config.Methods {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("rule: %s: bad method pattern: %s: %s",
+				config.Name, pattern, err)
+		}
+	}
+	rule := &methodLimitRule{
+		MethodLimitRule: config,
+		burst:           float64(config.Burst),
+		groups:          stringutil.ConvertListToMap(config.Groups, false),
+		limits:          make(map[limitKeyType]*limitStateType),
+		users:           stringutil.ConvertListToMap(config.Users, false),
+	}
+	if rule.burst < 1 {
+		rule.burst = math.Max(1, math.Ceil(config.CallsPerSecond))
+	}
+	if config.QueueTimeout != "" {
+		timeout, err := time.ParseDuration(config.QueueTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("rule: %s: bad QueueTimeout: %s",
+				config.Name, err)
+		}
+		rule.queueTimeout = timeout
+	}
+	return rule, nil
+}
+
+func (limiter *MethodLimiter) blockMethod(serviceMethod string,
+	authInfo *srpc.AuthInformation) (func(), error) {
+	startTime := time.Now()
+	var releasers []func(refund bool)
+	needRelease := false
+	for _, rule := range limiter.rules {
+		if !rule.matches(serviceMethod, authInfo) {
+			continue
+		}
+		releaser, err := rule.acquire(serviceMethod, authInfo.Username,
+			startTime.Add(rule.queueTimeout))
+		if err != nil {
+			// Give back what the earlier rules granted, since the call will
+			// not run.
+			for index := len(releasers) - 1; index >= 0; index-- {
+				releasers[index](true)
+			}
+			return nil, err
+		}
+		releasers = append(releasers, releaser)
+		if rule.MaxConcurrentCalls > 0 {
+			needRelease = true
+		}
+	}
+	if !needRelease {
+		return nil, nil
+	}
+	return func() {
+		for index := len(releasers) - 1; index >= 0; index-- {
+			releasers[index](false)
+		}
+	}, nil
+}
+
+func (limiter *MethodLimiter) registerMetrics() error {
+	if len(limiter.rules) < 1 {
+		return nil
+	}
+	dir, err := tricorder.RegisterDirectory("srpc/method-limits")
+	if err != nil {
+		return err
+	}
+	err = openmetrics.RegisterLabels("/srpc/method-limits/*", "rule")
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{
+		"num-permitted-calls",
+		"num-queued-calls",
+		"num-rate-limited-calls",
+	} {
+		openmetrics.RegisterCounter("/srpc/method-limits/*/" + name)
+	}
+	for _, rule := range limiter.rules {
+		ruleDir, err := dir.RegisterDirectory(rule.Name)
+		if err != nil {
+			return err
+		}
+		if err := rule.registerMetrics(ruleDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (limiter *MethodLimiter) writeHtml(writer io.Writer) {
+	if len(limiter.rules) < 1 {
+		fmt.Fprintln(writer, "No SRPC method limits configured<br>")
+		return
+	}
+	fmt.Fprintln(writer, `<table border="1">`)
+	tw, _ := html.NewTableWriter(writer, true, "Rule", "Methods", "Callers",
+		"Scope", "Rate", "Burst", "Max Concurrent", "Queue Timeout",
+		"Running", "Permitted", "Queued", "Rate Limited")
+	for _, rule := range limiter.rules {
+		rule.writeHtmlRow(tw)
+	}
+	tw.Close()
+	fmt.Fprintln(writer, "<br>")
+}
+
+// acquire waits until the limits for the key permit the call or the deadline
+// is reached. On success, a function to release the call is returned. If
+// refund is true, the call did not run and the token it took is returned.
+func (rule *methodLimitRule) acquire(serviceMethod, username string,
+	deadline time.Time) (func(refund bool), error) {
+	key := limitKeyType{}
+	if rule.PerMethod {
+		key.method = serviceMethod
+	}
+	if rule.PerUser {
+		key.username = username
+	}
+	rule.mutex.Lock()
+	defer rule.mutex.Unlock()
+	now := time.Now()
+	rule.sweep(now)
+	state := rule.limits[key]
+	if state == nil {
+		state = &limitStateType{
+			lastRefill: now,
+			released:   make(chan struct{}),
+			tokens:     rule.burst,
+		}
+		rule.limits[key] = state
+	}
+	queued := false
+	for {
+		rule.refill(state, now)
+		var wait time.Duration
+		remaining := deadline.Sub(now)
+		if limit := rule.MaxConcurrentCalls; limit > 0 &&
+			state.numRunning >= limit {
+			wait = remaining // Wait for a call to be released.
+		} else if rule.CallsPerSecond > 0 && state.tokens < 1 {
+			wait = time.Duration(float64(time.Second) *
+				(1 - state.tokens) / rule.CallsPerSecond)
+		} else {
+			break
+		}
+		if remaining <= 0 || wait > remaining {
+			rule.numRateLimitedCalls++
+			return nil, rule.makeError(serviceMethod, username)
+		}
+		if !queued {
+			queued = true
+			rule.numQueuedCalls++
+		}
+		released := state.released
+		timer := time.NewTimer(wait)
+		state.numWaiting++
+		rule.mutex.Unlock()
+		select {
+		case <-released:
+		case <-timer.C:
+		}
+		timer.Stop()
+		rule.mutex.Lock()
+		state.numWaiting--
+		now = time.Now()
+	}
+	if rule.CallsPerSecond > 0 {
+		state.tokens--
+	}
+	rule.numPermittedCalls++
+	if rule.MaxConcurrentCalls > 0 {
+		state.numRunning++
+		rule.numRunningCalls++
+	}
+	return func(refund bool) {
+		rule.mutex.Lock()
+		defer rule.mutex.Unlock()
+		if refund {
+			rule.numPermittedCalls--
+			if rule.CallsPerSecond > 0 {
+				rule.refill(state, time.Now())
+				state.tokens = math.Min(rule.burst, state.tokens+1)
+			}
+		}
+		if rule.MaxConcurrentCalls > 0 {
+			state.numRunning--
+			rule.numRunningCalls--
+		}
+		close(state.released)
+		state.released = make(chan struct{})
+	}, nil
+}
+
+func (rule *methodLimitRule) makeError(serviceMethod, username string) error {
+	if username == "" {
+		return fmt.Errorf("%w: %s: limit reached for %s",
+			srpc.ErrorRateLimited, rule.Name, serviceMethod)
+	}
+	return fmt.Errorf("%w: %s: limit reached for %s by %s",
+		srpc.ErrorRateLimited, rule.Name, serviceMethod, username)
+}
+
+func (rule *methodLimitRule) matches(serviceMethod string,
+	authInfo *srpc.AuthInformation) bool {
+	if len(rule.Methods) > 0 {
+		matched := false
+		for _, pattern := range rule.Methods {
+			if ok, _ := filepath.Match(pattern, serviceMethod); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if rule.users == nil && rule.groups == nil {
+		return true
+	}
+	if _, ok := rule.users[authInfo.Username]; ok {
+		return true
+	}
+	for group := range authInfo.GroupList {
+		if _, ok := rule.groups[group]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// refill adds the tokens earned since the last refill. The rule lock must be
+// held.
+func (rule *methodLimitRule) refill(state *limitStateType, now time.Time) {
+	if rule.CallsPerSecond <= 0 {
+		return
+	}
+	if elapsed := now.Sub(state.lastRefill); elapsed > 0 {
+		state.tokens = math.Min(rule.burst,
+			state.tokens+elapsed.Seconds()*rule.CallsPerSecond)
+		state.lastRefill = now
+	}
+}
+
+func (rule *methodLimitRule) registerMetrics(
+	dir *tricorder.DirectorySpec) error {
+	err := dir.RegisterMetric("num-permitted-calls", &rule.numPermittedCalls,
+		units.None, "number of calls permitted by rule")
+	if err != nil {
+		return err
+	}
+	err = dir.RegisterMetric("num-queued-calls", &rule.numQueuedCalls,
+		units.None, "number of calls which waited for rule")
+	if err != nil {
+		return err
+	}
+	err = dir.RegisterMetric("num-rate-limited-calls",
+		&rule.numRateLimitedCalls, units.None,
+		"number of calls rejected by rule")
+	if err != nil {
+		return err
+	}
+	return dir.RegisterMetric("num-running-calls", &rule.numRunningCalls,
+		units.None, "number of running calls counted by rule")
+}
+
+// sweep periodically removes idle limit states, to stop the set of keys from
+// growing without bound. The rule lock must be held.
+func (rule *methodLimitRule) sweep(now time.Time) {
+	if now.Sub(rule.lastSweep) < sweepInterval {
+		return
+	}
+	rule.lastSweep = now
+	for key, state := range rule.limits {
+		if state.numRunning > 0 || state.numWaiting > 0 {
+			continue
+		}
+		rule.refill(state, now)
+		if rule.CallsPerSecond <= 0 || state.tokens >= rule.burst {
+			delete(rule.limits, key)
+		}
+	}
+}
+
+func (rule *methodLimitRule) writeHtmlRow(tw *html.TableWriter) {
+	methods := "all"
+	if len(rule.Methods) > 0 {
+		methods = strings.Join(rule.Methods, " ")
+	}
+	var callers []string
+	for _, user := range rule.Users {
+		callers = append(callers, "user:"+user)
+	}
+	for _, group := range rule.Groups {
+		callers = append(callers, "group:"+group)
+	}
+	if len(callers) < 1 {
+		callers = append(callers, "all")
+	}
+	var scope []string
+	if rule.PerMethod {
+		scope = append(scope, "method")
+	}
+	if rule.PerUser {
+		scope = append(scope, "user")
+	}
+	if len(scope) < 1 {
+		scope = append(scope, "shared")
+	}
+	var rate, burst, maxConcurrent string
+	if rule.CallsPerSecond > 0 {
+		rate = fmt.Sprintf("%g/s", rule.CallsPerSecond)
+		burst = fmt.Sprintf("%g", rule.burst)
+	}
+	if rule.MaxConcurrentCalls > 0 {
+		maxConcurrent = fmt.Sprintf("%d", rule.MaxConcurrentCalls)
+	}
+	var queueTimeout string
+	if rule.queueTimeout > 0 {
+		queueTimeout = format.Duration(rule.queueTimeout)
+	}
+	rule.mutex.Lock()
+	numRunningCalls := rule.numRunningCalls
+	numPermittedCalls := rule.numPermittedCalls
+	numQueuedCalls := rule.numQueuedCalls
+	numRateLimitedCalls := rule.numRateLimitedCalls
+	rule.mutex.Unlock()
+	var background string
+	if numRateLimitedCalls > 0 {
+		background = "yellow"
+	}
+	tw.WriteRow("", background,
+		rule.Name,
+		methods,
+		strings.Join(callers, " "),
+		strings.Join(scope, "+"),
+		rate,
+		burst,
+		maxConcurrent,
+		queueTimeout,
+		fmt.Sprintf("%d", numRunningCalls),
+		fmt.Sprintf("%d", numPermittedCalls),
+		fmt.Sprintf("%d", numQueuedCalls),
+		fmt.Sprintf("%d", numRateLimitedCalls))
+}
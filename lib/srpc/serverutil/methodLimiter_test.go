@@ -0,0 +1,343 @@
+package serverutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+)
+
+type limiterCall struct {
+	method   string
+	username string
+	allowed  bool
+}
+
+func TestMethodLimiterRefillAndBurst(t *testing.T) {
+	limiter, err := newMethodLimiter(MethodLimitsConfiguration{
+		Rules: []MethodLimitRule{
+			{Name: "rate", CallsPerSecond: 1, Burst: 3},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authInfo := &srpc.AuthInformation{Username: "alice"}
+	for index := 0; index < 3; index++ {
+		if _, err := limiter.blockMethod("A.a", authInfo); err != nil {
+			t.Fatalf("call: %d: %s", index, err)
+		}
+	}
+	_, err = limiter.blockMethod("A.a", authInfo)
+	if !errors.Is(err, srpc.ErrorRateLimited) {
+		t.Fatalf("expected: %s, got: %v", srpc.ErrorRateLimited, err)
+	}
+	// Pretend two seconds have passed, which earns two tokens.
+	rule := limiter.rules[0]
+	rule.limits[limitKeyType{}].lastRefill = time.Now().Add(-2 * time.Second)
+	for index := 0; index < 2; index++ {
+		if _, err := limiter.blockMethod("A.a", authInfo); err != nil {
+			t.Fatalf("call after refill: %d: %s", index, err)
+		}
+	}
+	if _, err := limiter.blockMethod("A.a", authInfo); err == nil {
+		t.Fatal("call after refill permitted beyond earned tokens")
+	}
+	// Refilling never exceeds the burst size.
+	rule.limits[limitKeyType{}].lastRefill = time.Now().Add(-time.Hour)
+	for index := 0; index < 3; index++ {
+		if _, err := limiter.blockMethod("A.a", authInfo); err != nil {
+			t.Fatalf("call after long idle: %d: %s", index, err)
+		}
+	}
+	if _, err := limiter.blockMethod("A.a", authInfo); err == nil {
+		t.Fatal("call permitted beyond burst size")
+	}
+	if rule.numPermittedCalls != 8 || rule.numRateLimitedCalls != 3 {
+		t.Errorf("expected: 8 permitted, 3 rate limited, got: %d, %d",
+			rule.numPermittedCalls, rule.numRateLimitedCalls)
+	}
+}
+
+func TestMethodLimiterQueueTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		queueTimeout string
+		allowed      bool
+	}{
+		{"no queueing", "", false},
+		{"short queue timeout", "10ms", false},
+		{"long queue timeout", "5s", true},
+	}
+	for _, test := range tests {
+		limiter, err := newMethodLimiter(MethodLimitsConfiguration{
+			Rules: []MethodLimitRule{
+				{
+					Name:           "rate",
+					CallsPerSecond: 20,
+					Burst:          1,
+					QueueTimeout:   test.queueTimeout,
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		authInfo := &srpc.AuthInformation{}
+		if _, err := limiter.blockMethod("A.a", authInfo); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if test.queueTimeout == "10ms" {
+			// Make the wait for the next token longer than the timeout.
+			limiter.rules[0].limits[limitKeyType{}].tokens = -1
+		}
+		_, err = limiter.blockMethod("A.a", authInfo)
+		if test.allowed && err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if !test.allowed && !errors.Is(err, srpc.ErrorRateLimited) {
+			t.Errorf("%s: expected: %s, got: %v",
+				test.name, srpc.ErrorRateLimited, err)
+		}
+		numQueuedCalls := limiter.rules[0].numQueuedCalls
+		if test.allowed && numQueuedCalls != 1 {
+			t.Errorf("%s: expected 1 queued call, got: %d",
+				test.name, numQueuedCalls)
+		} else if !test.allowed && numQueuedCalls != 0 {
+			t.Errorf("%s: expected no queued calls, got: %d",
+				test.name, numQueuedCalls)
+		}
+	}
+}
+
+func TestMethodLimiterConcurrency(t *testing.T) {
+	limiter, err := newMethodLimiter(MethodLimitsConfiguration{
+		Rules: []MethodLimitRule{
+			{Name: "concurrency", MaxConcurrentCalls: 1, QueueTimeout: "5s"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authInfo := &srpc.AuthInformation{}
+	release, err := limiter.blockMethod("A.a", authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release == nil {
+		t.Fatal("no release function")
+	}
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := limiter.blockMethod("A.a", authInfo)
+		if release != nil {
+			release()
+		}
+		acquired <- err
+	}()
+	select {
+	case err := <-acquired:
+		t.Fatalf("second call not blocked, err: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second call not unblocked by release")
+	}
+	rule := limiter.rules[0]
+	if rule.numRunningCalls != 0 {
+		t.Errorf("expected no running calls, got: %d", rule.numRunningCalls)
+	}
+	if state := rule.limits[limitKeyType{}]; state.numRunning != 0 ||
+		state.numWaiting != 0 {
+		t.Errorf("expected idle state, got: %d running, %d waiting",
+			state.numRunning, state.numWaiting)
+	}
+}
+
+func TestMethodLimiterKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  MethodLimitRule
+		calls []limiterCall
+	}{
+		{
+			name: "shared",
+			rule: MethodLimitRule{Name: "shared", CallsPerSecond: 1},
+			calls: []limiterCall{
+				{"A.a", "alice", true},
+				{"A.b", "bob", false},
+			},
+		},
+		{
+			name: "per user",
+			rule: MethodLimitRule{
+				Name:           "per-user",
+				CallsPerSecond: 1,
+				PerUser:        true,
+			},
+			calls: []limiterCall{
+				{"A.a", "alice", true},
+				{"A.b", "alice", false},
+				{"A.a", "bob", true},
+			},
+		},
+		{
+			name: "per method",
+			rule: MethodLimitRule{
+				Name:           "per-method",
+				CallsPerSecond: 1,
+				PerMethod:      true,
+			},
+			calls: []limiterCall{
+				{"A.a", "alice", true},
+				{"A.a", "bob", false},
+				{"A.b", "alice", true},
+			},
+		},
+		{
+			name: "per user and method",
+			rule: MethodLimitRule{
+				Name:           "per-user-method",
+				CallsPerSecond: 1,
+				PerMethod:      true,
+				PerUser:        true,
+			},
+			calls: []limiterCall{
+				{"A.a", "alice", true},
+				{"A.a", "alice", false},
+				{"A.b", "alice", true},
+				{"A.a", "bob", true},
+			},
+		},
+		{
+			name: "matching methods and users",
+			rule: MethodLimitRule{
+				Name:           "matching",
+				Methods:        []string{"A.*"},
+				Users:          []string{"alice"},
+				CallsPerSecond: 1,
+			},
+			calls: []limiterCall{
+				{"A.a", "alice", true},
+				{"A.a", "alice", false},
+				{"A.a", "bob", true},
+				{"B.a", "alice", true},
+			},
+		},
+	}
+	for _, test := range tests {
+		limiter, err := newMethodLimiter(MethodLimitsConfiguration{
+			Rules: []MethodLimitRule{test.rule},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for index, call := range test.calls {
+			_, err := limiter.blockMethod(call.method,
+				&srpc.AuthInformation{Username: call.username})
+			if call.allowed && err != nil {
+				t.Errorf("%s: call: %d: %s", test.name, index, err)
+			} else if !call.allowed && err == nil {
+				t.Errorf("%s: call: %d: %s by %s not limited",
+					test.name, index, call.method, call.username)
+			}
+		}
+	}
+}
+
+// TestMethodLimiterRefund checks that tokens taken by earlier rules are given
+// back when a later rule rejects the call.
+func TestMethodLimiterRefund(t *testing.T) {
+	limiter, err := newMethodLimiter(MethodLimitsConfiguration{
+		Rules: []MethodLimitRule{
+			{Name: "all", CallsPerSecond: 1, Burst: 2},
+			{
+				Name:               "slow",
+				Methods:            []string{"Slow.*"},
+				CallsPerSecond:     1,
+				MaxConcurrentCalls: 1,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authInfo := &srpc.AuthInformation{}
+	release, err := limiter.blockMethod("Slow.a", authInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	for index := 0; index < 3; index++ {
+		if _, err := limiter.blockMethod("Slow.a", authInfo); err == nil {
+			t.Fatalf("call: %d: not limited by second rule", index)
+		}
+	}
+	if _, err := limiter.blockMethod("Fast.a", authInfo); err != nil {
+		t.Fatalf("token not refunded: %s", err)
+	}
+	rule := limiter.rules[0]
+	if rule.numPermittedCalls != 2 {
+		t.Errorf("expected: 2 permitted calls, got: %d",
+			rule.numPermittedCalls)
+	}
+	if state := rule.limits[limitKeyType{}]; state.tokens >= 1 {
+		t.Errorf("expected no tokens left, got: %g", state.tokens)
+	}
+}
+
+func TestMethodLimiterSweep(t *testing.T) {
+	limiter, err := newMethodLimiter(MethodLimitsConfiguration{
+		Rules: []MethodLimitRule{
+			{Name: "per-user", CallsPerSecond: 1, PerUser: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []string{"alice", "bob", "carol"} {
+		_, err := limiter.blockMethod("A.a",
+			&srpc.AuthInformation{Username: username})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rule := limiter.rules[0]
+	if len(rule.limits) != 3 {
+		t.Fatalf("expected: 3 limit states, got: %d", len(rule.limits))
+	}
+	// Alice is idle with a full bucket, Bob is waiting and Carol has not yet
+	// earned her token back.
+	now := time.Now()
+	rule.lastSweep = now.Add(-2 * sweepInterval)
+	rule.limits[limitKeyType{username: "alice"}].lastRefill =
+		now.Add(-time.Minute)
+	rule.limits[limitKeyType{username: "bob"}].lastRefill =
+		now.Add(-time.Minute)
+	rule.limits[limitKeyType{username: "bob"}].numWaiting = 1
+	_, err = limiter.blockMethod("A.a", &srpc.AuthInformation{Username: "dave"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		username string
+		present  bool
+	}{
+		{"alice", false},
+		{"bob", true},
+		{"carol", true},
+		{"dave", true},
+	} {
+		_, ok := rule.limits[limitKeyType{username: test.username}]
+		if ok != test.present {
+			t.Errorf("%s: expected present: %v, got: %v",
+				test.username, test.present, ok)
+		}
+	}
+}
@@ -15,9 +15,13 @@ import (
 )
 
 var (
+	bearerTokenFile = flag.String("bearerTokenFile", "",
+		"Name of file containing bearer token to use if there are no certificates")
 	certDirectory = flag.String("certDirectory",
 		path.Join(os.Getenv("HOME"), ".ssl"),
 		"Name of directory containing user SSL certificates")
+	serverCaFile = flag.String("serverCAfile", "",
+		"Name of file containing the root of trust for servers (required for bearer token)")
 )
 
 type Params struct {
@@ -31,19 +35,25 @@ func GetCertDirectory() string {
 }
 
 // SetupTls loads zero or more client certificates from files and registers them
-// with the lib/srpc package. The following command-line flags are registered
-// with the standard flag package:
+// with the lib/srpc package. If there are no certificates and a bearer token
+// file is specified, the bearer token is used instead. The following
+// command-line flags are registered with the standard flag package:
 //
-//	-certDirectory: Name of directory containing user SSL certificates
+//	-bearerTokenFile: Name of file containing bearer token
+//	-certDirectory:   Name of directory containing user SSL certificates
+//	-serverCAfile:    Name of file containing the root of trust for servers
 func SetupTls(ignoreMissingCerts bool) error {
 	return setupTls(Params{IgnoreMissingCerts: ignoreMissingCerts})
 }
 
 // SetupTlsWithParsms loads zero or more client certificates from files and
-// registers them with the lib/srpc package. The following command-line flags
-// are registered with the standard flag package:
+// registers them with the lib/srpc package. If there are no certificates and a
+// bearer token file is specified, the bearer token is used instead. The
+// following command-line flags are registered with the standard flag package:
 //
-//	-certDirectory: Name of directory containing user SSL certificates
+//	-bearerTokenFile: Name of file containing bearer token
+//	-certDirectory:   Name of directory containing user SSL certificates
+//	-serverCAfile:    Name of file containing the root of trust for servers
 func SetupTlsWithParams(params Params) error {
 	return setupTls(params)
 }
@@ -90,6 +90,9 @@ func setupTlsOnce(params Params) error {
 		return err
 	}
 	if certs == nil {
+		if *bearerTokenFile != "" {
+			return setupBearerToken()
+		}
 		return srpc.ErrorMissingCertificate
 	}
 	// Setup client.
@@ -0,0 +1,65 @@
+package setupclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/x509util"
+)
+
+func readBearerToken() (string, error) {
+	data, err := os.ReadFile(*bearerTokenFile)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s is empty", *bearerTokenFile)
+	}
+	return token, nil
+}
+
+// setupBearerToken registers a client TLS config without certificates which
+// verifies the server certificate chain against the server CA, and registers
+// the bearer token source. Server certificates often do not contain the
+// hostname, so only the chain is verified.
+func setupBearerToken() error {
+	if *serverCaFile == "" {
+		return errors.New("no serverCAfile specified for bearer token")
+	}
+	certs, _, err := x509util.LoadCertificatePEMs(*serverCaFile)
+	if err != nil {
+		return fmt.Errorf("unable to load CA file: \"%s\": %s",
+			*serverCaFile, err)
+	}
+	caCertPool := x509.NewCertPool()
+	for _, cert := range certs {
+		caCertPool.AddCert(cert)
+	}
+	clientConfig := new(tls.Config)
+	clientConfig.InsecureSkipVerify = true // Verified below.
+	clientConfig.MinVersion = tls.VersionTLS12
+	clientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) < 1 {
+			return errors.New("no server certificate")
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			Roots:         caCertPool,
+		})
+		return err
+	}
+	srpc.RegisterClientBearerToken(readBearerToken)
+	srpc.RegisterClientTlsConfig(clientConfig)
+	return nil
+}
@@ -11,6 +11,21 @@ registered with the standard flag package:
 	-caFile:   Name of file containing the root of trust
 	-certFile: Name of file containing the SSL certificate
 	-keyFile:  Name of file containing the SSL key
+
+Clients without a certificate may authenticate with a bearer token (JWT) if
+either of the following flags is specified:
+
+	-srpcTokenIssuers:     OpenID Connect issuers trusted for bearer tokens
+	-srpcTokenKeySetFiles: Files containing JSON Web Key Sets trusted for
+	                       bearer tokens
+
+Issuers require -srpcTokenAudience. Tokens from issuers only grant permitted
+methods if the issuer is listed in -srpcTokenMethodIssuers.
+
+Rate and concurrency limits for method calls to all receivers may be loaded
+from a JSON file (see serverutil.MethodLimitsConfiguration) specified with:
+
+	-srpcMethodLimitsFile: Name of file containing the method limits
 */
 package setupserver
 
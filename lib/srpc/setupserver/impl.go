@@ -172,6 +172,14 @@ func setupTls(params Params) error {
 	if params.Logger == nil {
 		params.Logger = nulllogger.New()
 	}
+	if !params.ClientOnly {
+		if err := setupTokenVerifier(params); err != nil {
+			return err
+		}
+		if err := setupMethodLimiter(params); err != nil {
+			return err
+		}
+	}
 	cert, err := setupTlsOnce(params)
 	if err != nil {
 		return err
@@ -278,6 +286,10 @@ func setupServerTlsOnce(params Params, serverCert *tls.Certificate) error {
 	}
 	serverConfig := new(tls.Config)
 	serverConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	if tokensEnabled {
+		// Clients without a certificate must present a bearer token.
+		serverConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
 	serverConfig.MinVersion = tls.VersionTLS12
 	serverConfig.ClientCAs = caCertPool
 	if *identityCaFile != "" {
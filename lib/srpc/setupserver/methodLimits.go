@@ -0,0 +1,50 @@
+package setupserver
+
+import (
+	"flag"
+	"sync"
+
+	"github.com/Cloud-Foundations/Dominator/lib/html"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc/serverutil"
+)
+
+const methodLimitsPath = "/srpcMethodLimits"
+
+var (
+	methodLimitsFile = flag.String("srpcMethodLimitsFile", "",
+		"Name of JSON file containing rate and concurrency limits for methods")
+
+	methodLimiterError error
+	setupLimiterOnce   sync.Once
+)
+
+// setupMethodLimiter registers a limiter for all method calls if a method
+// limits file is specified. The counters are exported as metrics and on a
+// status page linked from the header of the main status page.
+func setupMethodLimiter(params Params) error {
+	if *methodLimitsFile == "" {
+		return nil
+	}
+	setupLimiterOnce.Do(func() {
+		methodLimiterError = setupMethodLimiterOnce(params)
+	})
+	return methodLimiterError
+}
+
+func setupMethodLimiterOnce(params Params) error {
+	limiter, err := serverutil.LoadMethodLimiter(*methodLimitsFile)
+	if err != nil {
+		return err
+	}
+	if err := limiter.RegisterMetrics(); err != nil {
+		return err
+	}
+	srpc.RegisterServerMethodBlocker(limiter)
+	html.RegisterHtmlWriterForPattern(methodLimitsPath, "SRPC Method Limits",
+		limiter)
+	html.RegisterHeaderLink("SRPC method limits", methodLimitsPath)
+	params.Logger.Printf("Loaded SRPC method limits from: %s\n",
+		*methodLimitsFile)
+	return nil
+}
@@ -0,0 +1,125 @@
+package setupserver
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/flagutil"
+	"github.com/Cloud-Foundations/Dominator/lib/jwt"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+)
+
+var (
+	tokenAudience = flag.String("srpcTokenAudience", "",
+		"Audience bearer tokens must include (required with -srpcTokenIssuers)")
+	tokenGroupsClaim = flag.String("srpcTokenGroupsClaim", "groups",
+		"Name of bearer token claim containing the groups")
+	tokenIssuers               flagutil.StringList
+	tokenKeySetFiles           flagutil.StringList
+	tokenMethodIssuers         flagutil.StringList
+	tokenPermittedMethodsClaim = flag.String(
+		"srpcTokenPermittedMethodsClaim", "permitted_methods",
+		"Name of bearer token claim containing the permitted methods")
+	tokenUsernameClaim = flag.String("srpcTokenUsernameClaim", "sub",
+		"Name of bearer token claim containing the username")
+
+	tokensEnabled bool
+)
+
+type tokenVerifierType struct {
+	issuers       map[string]struct{}
+	methodIssuers map[string]struct{}
+	verifier      *jwt.Verifier
+}
+
+func init() {
+	flag.Var(&tokenIssuers, "srpcTokenIssuers",
+		"Comma separated list of OpenID Connect issuers trusted for bearer tokens")
+	flag.Var(&tokenKeySetFiles, "srpcTokenKeySetFiles",
+		"Comma separated list of files containing JSON Web Key Sets trusted for bearer tokens")
+	flag.Var(&tokenMethodIssuers, "srpcTokenMethodIssuers",
+		"Comma separated list of issuers (from -srpcTokenIssuers) trusted to grant permitted methods in bearer tokens")
+}
+
+// issuersToSet returns the set of issuer URLs, in the form which appears in
+// verified tokens.
+func issuersToSet(issuers []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(issuers))
+	for _, issuer := range issuers {
+		set[strings.TrimSuffix(issuer, "/")] = struct{}{}
+	}
+	return set
+}
+
+func stringsToSet(values []string) map[string]struct{} {
+	if len(values) < 1 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[value] = struct{}{}
+	}
+	return set
+}
+
+// setupTokenVerifier registers a verifier for bearer tokens if any issuers
+// or key set files are specified.
+func setupTokenVerifier(params Params) error {
+	if len(tokenIssuers) < 1 && len(tokenKeySetFiles) < 1 {
+		return nil
+	}
+	issuers := issuersToSet(tokenIssuers)
+	methodIssuers := issuersToSet(tokenMethodIssuers)
+	for issuer := range methodIssuers {
+		if _, ok := issuers[issuer]; !ok {
+			return fmt.Errorf("method issuer: %s not a token issuer", issuer)
+		}
+	}
+	verifier, err := jwt.NewVerifier(jwt.VerifierParams{
+		Audience:    *tokenAudience,
+		Issuers:     tokenIssuers,
+		KeySetFiles: tokenKeySetFiles,
+		Logger:      params.Logger,
+	})
+	if err != nil {
+		return err
+	}
+	srpc.RegisterTokenVerifier(&tokenVerifierType{
+		issuers:       issuers,
+		methodIssuers: methodIssuers,
+		verifier:      verifier,
+	})
+	tokensEnabled = true
+	return nil
+}
+
+func (v *tokenVerifierType) VerifyToken(token string) (
+	*srpc.TokenIdentity, error) {
+	claims, err := v.verifier.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	identity := &srpc.TokenIdentity{
+		GroupList: stringsToSet(claims.GetStrings(*tokenGroupsClaim)),
+		Username:  claims.GetString(*tokenUsernameClaim),
+	}
+	if v.canGrantMethods(claims.Issuer) {
+		identity.PermittedMethods = stringsToSet(
+			claims.GetStrings(*tokenPermittedMethodsClaim))
+	}
+	return identity, nil
+}
+
+// canGrantMethods returns true if tokens from the issuer may grant permitted
+// methods. Tokens from trusted issuers are verified with the keys of the
+// issuer and may only grant methods if the issuer is a method issuer, similar
+// to the restriction on identity-only CAs. Other tokens are verified with the
+// keys in the key set files, which are fully trusted.
+func (v *tokenVerifierType) canGrantMethods(issuer string) bool {
+	if _, ok := v.issuers[issuer]; !ok {
+		return true
+	}
+	_, ok := v.methodIssuers[issuer]
+	return ok
+}
@@ -0,0 +1,30 @@
+package setupserver
+
+import (
+	"testing"
+)
+
+func TestCanGrantMethods(t *testing.T) {
+	v := &tokenVerifierType{
+		issuers: issuersToSet([]string{
+			"https://identity.example.com/",
+			"https://admin.example.com",
+		}),
+		methodIssuers: issuersToSet([]string{"https://admin.example.com/"}),
+	}
+	tests := []struct {
+		issuer string
+		grant  bool
+	}{
+		{"https://admin.example.com", true},
+		{"https://identity.example.com", false},
+		{"", true},                          // Key set file.
+		{"https://other.example.com", true}, // Key set file.
+	}
+	for _, test := range tests {
+		if grant := v.canGrantMethods(test.issuer); grant != test.grant {
+			t.Errorf("issuer: \"%s\": expected: %v, got: %v",
+				test.issuer, test.grant, grant)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package srpc
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	bearerTokenParam  = "bearerToken"
+	bearerTokenPrefix = "Bearer "
+)
+
+// clientCanSendBearerToken returns true if the client should authenticate
+// with a bearer token: a token source is registered, the client has no
+// certificate and the server certificate will be verified, so that the token
+// is not disclosed to an impostor.
+func clientCanSendBearerToken(tlsConfig *tls.Config) bool {
+	if clientBearerToken == nil || tlsConfig == nil {
+		return false
+	}
+	if len(tlsConfig.Certificates) > 0 ||
+		tlsConfig.GetClientCertificate != nil {
+		return false
+	}
+	return !tlsConfig.InsecureSkipVerify ||
+		tlsConfig.VerifyConnection != nil ||
+		tlsConfig.VerifyPeerCertificate != nil
+}
+
+func registerClientBearerToken(getToken func() (string, error)) {
+	clientBearerToken = getToken
+}
+
+func registerTokenVerifier(verifier TokenVerifier) {
+	tokenVerifier = verifier
+}
+
+// sendBearerToken sends a HTTP CONNECT request containing the bearer token
+// over the (encrypted) connection and waits for the response.
+func sendBearerToken(conn net.Conn, path string) error {
+	token, err := clientBearerToken()
+	if err != nil {
+		return fmt.Errorf("error getting bearer token: %s", err)
+	}
+	_, err = io.WriteString(conn, "CONNECT "+path+" HTTP/1.0\n"+
+		"Authorization: "+bearerTokenPrefix+token+"\n\n")
+	if err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn),
+		&http.Request{Method: "CONNECT"})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrorBadBearerToken
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected HTTP response: " + resp.Status)
+	}
+	return nil
+}
+
+// authenticateBearerToken reads a HTTP CONNECT request containing a bearer
+// token from the (encrypted) connection, verifies the token and sets the
+// identity for the connection.
+func (conn *Conn) authenticateBearerToken() error {
+	req, err := http.ReadRequest(conn.Reader)
+	if err != nil {
+		return err
+	}
+	identity, err := verifyBearerToken(req)
+	if err != nil {
+		conn.WriteString("HTTP/1.0 401 Unauthorized\n\n")
+		conn.Flush()
+		return err
+	}
+	if _, err := conn.WriteString("HTTP/1.0 200 OK\n\n"); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	conn.username = identity.Username
+	conn.groupList = identity.GroupList
+	if identity.PermittedMethods != nil {
+		conn.permittedMethods = identity.PermittedMethods
+	} else {
+		conn.permittedMethods = emptyMethodList
+	}
+	return nil
+}
+
+func verifyBearerToken(req *http.Request) (*TokenIdentity, error) {
+	if req.Method != "CONNECT" {
+		return nil, errors.New("expected CONNECT request, got: " + req.Method)
+	}
+	if tokenVerifier == nil {
+		return nil, errors.New("bearer tokens not supported")
+	}
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"),
+		bearerTokenPrefix)
+	if !ok || token == "" {
+		return nil, errors.New("no bearer token")
+	}
+	identity, err := tokenVerifier.VerifyToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("bearer token rejected: %s", err)
+	}
+	if identity == nil || identity.Username == "" {
+		return nil, errors.New("bearer token rejected: no username")
+	}
+	return identity, nil
+}
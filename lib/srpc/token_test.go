@@ -0,0 +1,104 @@
+package srpc
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+)
+
+type testTokenVerifier struct{}
+
+func (testTokenVerifier) VerifyToken(token string) (*TokenIdentity, error) {
+	if token != "good-token" {
+		return nil, errors.New("bad token")
+	}
+	return &TokenIdentity{
+		GroupList: map[string]struct{}{"group0": {}},
+		Username:  "user0",
+	}, nil
+}
+
+func testBearerTokenExchange(t *testing.T, token string) (*Conn, error) {
+	origClientBearerToken := clientBearerToken
+	origTokenVerifier := tokenVerifier
+	defer func() {
+		clientBearerToken = origClientBearerToken
+		tokenVerifier = origTokenVerifier
+	}()
+	RegisterClientBearerToken(func() (string, error) { return token, nil })
+	RegisterTokenVerifier(testTokenVerifier{})
+	serverPipe, clientPipe := net.Pipe()
+	defer serverPipe.Close()
+	defer clientPipe.Close()
+	serverConn := &Conn{
+		conn:             serverPipe,
+		permittedMethods: emptyMethodList,
+		ReadWriter: bufio.NewReadWriter(bufio.NewReader(serverPipe),
+			bufio.NewWriter(serverPipe)),
+	}
+	serverError := make(chan error, 1)
+	go func() {
+		serverError <- serverConn.authenticateBearerToken()
+	}()
+	clientErr := sendBearerToken(clientPipe, tlsRpcPath)
+	if err := <-serverError; err != nil {
+		if clientErr != ErrorBadBearerToken {
+			t.Fatalf("client error: %v, expected: %s", clientErr,
+				ErrorBadBearerToken)
+		}
+		return nil, err
+	}
+	if clientErr != nil {
+		t.Fatal(clientErr)
+	}
+	return serverConn, nil
+}
+
+func TestBearerTokenAccepted(t *testing.T) {
+	conn, err := testBearerTokenExchange(t, "good-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.username != "user0" {
+		t.Errorf("username: %s != user0", conn.username)
+	}
+	if _, ok := conn.groupList["group0"]; !ok {
+		t.Error("group0 missing from group list")
+	}
+	if conn.permittedMethods == nil {
+		t.Error("all methods permitted")
+	}
+}
+
+func TestBearerTokenRejected(t *testing.T) {
+	if _, err := testBearerTokenExchange(t, "bad-token"); err == nil {
+		t.Fatal("bad token accepted")
+	}
+}
+
+func TestClientCanSendBearerToken(t *testing.T) {
+	origClientBearerToken := clientBearerToken
+	defer func() { clientBearerToken = origClientBearerToken }()
+	verifier := func(tls.ConnectionState) error { return nil }
+	if clientCanSendBearerToken(&tls.Config{}) {
+		t.Error("no token source: token would be sent")
+	}
+	RegisterClientBearerToken(func() (string, error) { return "token", nil })
+	if clientCanSendBearerToken(&tls.Config{InsecureSkipVerify: true}) {
+		t.Error("unverified server: token would be sent")
+	}
+	if clientCanSendBearerToken(&tls.Config{
+		Certificates:     make([]tls.Certificate, 1),
+		VerifyConnection: verifier,
+	}) {
+		t.Error("have certificate: token would be sent")
+	}
+	if !clientCanSendBearerToken(&tls.Config{
+		InsecureSkipVerify: true,
+		VerifyConnection:   verifier,
+	}) {
+		t.Error("verified server: token would not be sent")
+	}
+}
@@ -0,0 +1,37 @@
+package srpc
+
+import (
+	"strings"
+
+	"github.com/Cloud-Foundations/Dominator/lib/trace"
+)
+
+func (client *Client) startTraceSpan(serviceMethod string) *trace.Span {
+	if serviceMethod == "" || strings.HasPrefix(serviceMethod, ".") {
+		return nil // Do not trace pings and builtin methods.
+	}
+	span := trace.StartClientSpan(serviceMethod, client.traceSpan)
+	span.SetAttribute("rpc.system", "srpc")
+	span.SetAttribute("server.address", client.remoteAddr)
+	return span
+}
+
+func (conn *Conn) endTraceSpan(err error) {
+	if err != nil && err != ErrorCloseClient {
+		conn.traceSpan.SetError(err)
+	}
+	conn.traceSpan.End()
+	conn.traceSpan = nil
+}
+
+func (conn *Conn) startTraceSpan(serviceMethod, traceParent string) {
+	if strings.HasPrefix(serviceMethod, ".") {
+		return
+	}
+	conn.traceSpan = trace.StartServerSpan(serviceMethod, traceParent)
+	conn.traceSpan.SetAttribute("rpc.system", "srpc")
+	conn.traceSpan.SetAttribute("client.address", conn.remoteAddr)
+	if conn.username != "" {
+		conn.traceSpan.SetAttribute("enduser.id", conn.username)
+	}
+}
@@ -0,0 +1,89 @@
+package srpc
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cloud-Foundations/Dominator/lib/trace"
+	"github.com/Cloud-Foundations/Dominator/proto/test"
+)
+
+type testSpanExporter struct {
+	mutex sync.Mutex
+	spans map[uint]*trace.SpanRecord // Key: span kind.
+}
+
+func (e *testSpanExporter) ExportSpan(span *trace.SpanRecord) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans[span.Kind] = span
+}
+
+func (e *testSpanExporter) waitForSpans(count int) bool {
+	for timeout := time.Now().Add(time.Second); time.Now().Before(timeout); {
+		e.mutex.Lock()
+		numSpans := len(e.spans)
+		e.mutex.Unlock()
+		if numSpans >= count {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+func TestParsePreamble(t *testing.T) {
+	serviceMethod, traceParent := parsePreamble(
+		"Test.Method traceparent=00-0123-4567-01\r\n")
+	if serviceMethod != "Test.Method" {
+		t.Errorf("serviceMethod: %s != Test.Method", serviceMethod)
+	}
+	if traceParent != "00-0123-4567-01" {
+		t.Errorf("traceParent: %s != 00-0123-4567-01", traceParent)
+	}
+	if serviceMethod, _ := parsePreamble("\n"); serviceMethod != "" {
+		t.Errorf("ping parsed as: %s", serviceMethod)
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	exporter := &testSpanExporter{spans: make(map[uint]*trace.SpanRecord)}
+	trace.SetExporter(exporter)
+	defer trace.SetExporter(nil)
+	client, err := makeListenerAndConnect(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if !client.sendTraceParent {
+		t.Fatal("server did not advertise traceparent support")
+	}
+	parent := trace.StartSpan("parent", nil)
+	client.SetTraceSpan(parent)
+	var response test.EchoResponse
+	err = client.RequestReply("Test.RequestReply",
+		test.EchoRequest{Request: "traced"}, &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+	if !exporter.waitForSpans(3) {
+		t.Fatal("timed out waiting for spans")
+	}
+	parentRecord := exporter.spans[trace.KindInternal]
+	clientRecord := exporter.spans[trace.KindClient]
+	serverRecord := exporter.spans[trace.KindServer]
+	if clientRecord.ParentSpanId != parentRecord.SpanId {
+		t.Errorf("client parent: %s != %s",
+			clientRecord.ParentSpanId, parentRecord.SpanId)
+	}
+	if serverRecord.ParentSpanId != clientRecord.SpanId {
+		t.Errorf("server parent: %s != %s",
+			serverRecord.ParentSpanId, clientRecord.SpanId)
+	}
+	if serverRecord.TraceId != parentRecord.TraceId {
+		t.Errorf("server trace ID: %s != %s",
+			serverRecord.TraceId, parentRecord.TraceId)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Package trace implements lightweight distributed tracing.
+
+Trace context is propagated between processes using the W3C traceparent
+format. Completed spans are sent to an Exporter. The default Exporter is
+selected with the -traceExportFile and -traceOtlpUrl command-line flags. If no
+Exporter is configured, tracing is disabled and the Start* functions return
+nil. All Span methods are safe to call on a nil *Span, so callers need not
+check whether tracing is enabled.
+*/
+package trace
+
+import (
+	"sync"
+	"time"
+)
+
+// Span kinds, using the same numbering as OpenTelemetry.
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	TraceId [16]byte
+	SpanId  [8]byte
+	Sampled bool
+}
+
+// ParseTraceParent will parse a W3C traceparent header value.
+func ParseTraceParent(traceParent string) (SpanContext, error) {
+	return parseTraceParent(traceParent)
+}
+
+// TraceParent will return the W3C traceparent header value for the context.
+func (sc SpanContext) TraceParent() string {
+	return sc.traceParent()
+}
+
+// Span represents a single timed operation within a trace.
+type Span struct {
+	mutex      sync.Mutex // Lock everything below.
+	attributes map[string]string
+	context    SpanContext
+	ended      bool
+	errorText  string
+	kind       uint
+	name       string
+	parentId   [8]byte
+	startTime  time.Time
+}
+
+// StartSpan will start a new internal span. If parent is nil a new trace is
+// started.
+func StartSpan(name string, parent *Span) *Span {
+	return startSpan(name, parent, KindInternal)
+}
+
+// StartClientSpan will start a new span for an outgoing call. If parent is nil
+// a new trace is started.
+func StartClientSpan(name string, parent *Span) *Span {
+	return startSpan(name, parent, KindClient)
+}
+
+// StartServerSpan will start a new span for an incoming call. If traceParent is
+// a valid W3C traceparent value the span joins that trace, else a new trace is
+// started.
+func StartServerSpan(name string, traceParent string) *Span {
+	return startServerSpan(name, traceParent)
+}
+
+// Context returns the SpanContext for the span.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// End will mark the span as complete and send it to the Exporter. Subsequent
+// calls are ignored.
+func (s *Span) End() {
+	s.end()
+}
+
+// SetAttribute will set an attribute on the span.
+func (s *Span) SetAttribute(key, value string) {
+	s.setAttribute(key, value)
+}
+
+// SetError will record that the operation failed. A nil error is ignored.
+func (s *Span) SetError(err error) {
+	s.setError(err)
+}
+
+// TraceParent will return the W3C traceparent value to send to a remote
+// process so that it may create child spans. If s is nil the empty string is
+// returned.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return s.context.traceParent()
+}
+
+// SpanRecord contains the data for a completed span.
+type SpanRecord struct {
+	Attributes   map[string]string `json:",omitempty"`
+	EndTime      time.Time
+	Error        string `json:",omitempty"`
+	Kind         uint
+	Name         string
+	ParentSpanId string `json:",omitempty"`
+	Service      string
+	SpanId       string
+	StartTime    time.Time
+	TraceId      string
+}
+
+// Exporter is the interface used to export completed spans.
+type Exporter interface {
+	ExportSpan(span *SpanRecord)
+}
+
+// NewFileExporter will create an Exporter which appends spans to the specified
+// file as JSON lines.
+func NewFileExporter(filename string) (Exporter, error) {
+	if e, err := newFileExporter(filename); err != nil {
+		return nil, err
+	} else {
+		return e, nil
+	}
+}
+
+// NewOtlpExporter will create an Exporter which sends batches of spans to an
+// OpenTelemetry collector at the specified URL, using OTLP/HTTP with JSON
+// encoding. If the URL has no path, /v1/traces is used.
+func NewOtlpExporter(url string) (Exporter, error) {
+	if e, err := newOtlpExporter(url); err != nil {
+		return nil, err
+	} else {
+		return e, nil
+	}
+}
+
+// SetExporter will set the Exporter used for all completed spans, overriding
+// the command-line flags. If exporter is nil, tracing is disabled.
+func SetExporter(exporter Exporter) {
+	setExporter(exporter)
+}
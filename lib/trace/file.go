@@ -0,0 +1,28 @@
+package trace
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+type fileExporter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	file    *os.File
+}
+
+func newFileExporter(filename string) (*fileExporter, error) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileExporter{encoder: json.NewEncoder(file), file: file}, nil
+}
+
+func (e *fileExporter) ExportSpan(span *SpanRecord) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.encoder.Encode(span)
+}
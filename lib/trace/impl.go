@@ -0,0 +1,254 @@
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	traceExportFile = flag.String("traceExportFile", "",
+		"If specified, append completed trace spans to this file as JSON lines")
+	traceOtlpUrl = flag.String("traceOtlpUrl", "",
+		"If specified, send completed trace spans to this OTLP/HTTP collector")
+	traceSampleRatio = flag.Float64("traceSampleRatio", 1.0,
+		"Fraction of new traces to sample (0.0 to 1.0)")
+
+	exporterLock  sync.RWMutex
+	exporter      Exporter
+	exporterSetup sync.Once
+	serviceName   = filepath.Base(os.Args[0])
+)
+
+func getExporter() Exporter {
+	exporterSetup.Do(setupExporterFromFlags)
+	exporterLock.RLock()
+	defer exporterLock.RUnlock()
+	return exporter
+}
+
+func setupExporterFromFlags() {
+	var exporters []Exporter
+	if *traceExportFile != "" {
+		if e, err := newFileExporter(*traceExportFile); err != nil {
+			fmt.Fprintf(os.Stderr, "error setting up trace exporter: %s\n", err)
+		} else {
+			exporters = append(exporters, e)
+		}
+	}
+	if *traceOtlpUrl != "" {
+		if e, err := newOtlpExporter(*traceOtlpUrl); err != nil {
+			fmt.Fprintf(os.Stderr, "error setting up trace exporter: %s\n", err)
+		} else {
+			exporters = append(exporters, e)
+		}
+	}
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+	switch len(exporters) {
+	case 0:
+	case 1:
+		exporter = exporters[0]
+	default:
+		exporter = multiExporter(exporters)
+	}
+}
+
+func setExporter(e Exporter) {
+	exporterSetup.Do(func() {})
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+	exporter = e
+}
+
+type multiExporter []Exporter
+
+func (m multiExporter) ExportSpan(span *SpanRecord) {
+	for _, e := range m {
+		e.ExportSpan(span)
+	}
+}
+
+func parseTraceParent(traceParent string) (SpanContext, error) {
+	var sc SpanContext
+	fields := strings.Split(traceParent, "-")
+	if len(fields) < 4 {
+		return sc, errors.New("malformed traceparent: " + traceParent)
+	}
+	if len(fields[0]) != 2 || fields[0] == "ff" {
+		return sc, errors.New("unsupported traceparent version: " + fields[0])
+	}
+	if fields[0] == "00" && len(fields) != 4 {
+		return sc, errors.New("malformed traceparent: " + traceParent)
+	}
+	if err := decodeHex(sc.TraceId[:], fields[1]); err != nil {
+		return sc, err
+	}
+	if err := decodeHex(sc.SpanId[:], fields[2]); err != nil {
+		return sc, err
+	}
+	var flags [1]byte
+	if err := decodeHex(flags[:], fields[3]); err != nil {
+		return sc, err
+	}
+	if isZero(sc.TraceId[:]) || isZero(sc.SpanId[:]) {
+		return sc, errors.New("invalid all-zero ID in traceparent")
+	}
+	sc.Sampled = flags[0]&1 != 0
+	return sc, nil
+}
+
+func decodeHex(dst []byte, str string) error {
+	if len(str) != hex.EncodedLen(len(dst)) || strings.ToLower(str) != str {
+		return errors.New("malformed traceparent field: " + str)
+	}
+	if _, err := hex.Decode(dst, []byte(str)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (sc SpanContext) traceParent() string {
+	var flags byte
+	if sc.Sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%x-%x-%02x", sc.TraceId, sc.SpanId, flags)
+}
+
+func randomFill(data []byte) {
+	for {
+		if _, err := rand.Read(data); err != nil {
+			panic(err)
+		}
+		if !isZero(data) {
+			return
+		}
+	}
+}
+
+func sampleNewTrace() bool {
+	ratio := *traceSampleRatio
+	if ratio >= 1.0 {
+		return true
+	}
+	if ratio <= 0.0 {
+		return false
+	}
+	return mathrand.Float64() < ratio
+}
+
+func newSpan(name string, kind uint, parent *SpanContext) *Span {
+	if getExporter() == nil {
+		return nil
+	}
+	span := &Span{kind: kind, name: name, startTime: time.Now()}
+	if parent == nil {
+		randomFill(span.context.TraceId[:])
+		span.context.Sampled = sampleNewTrace()
+	} else {
+		span.context.TraceId = parent.TraceId
+		span.context.Sampled = parent.Sampled
+		span.parentId = parent.SpanId
+	}
+	randomFill(span.context.SpanId[:])
+	return span
+}
+
+func startSpan(name string, parent *Span, kind uint) *Span {
+	if parent == nil {
+		return newSpan(name, kind, nil)
+	}
+	return newSpan(name, kind, &parent.context)
+}
+
+func startServerSpan(name string, traceParent string) *Span {
+	if traceParent == "" {
+		return newSpan(name, KindServer, nil)
+	}
+	parent, err := parseTraceParent(traceParent)
+	if err != nil {
+		return newSpan(name, KindServer, nil)
+	}
+	return newSpan(name, KindServer, &parent)
+}
+
+func (s *Span) end() {
+	if s == nil {
+		return
+	}
+	endTime := time.Now()
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.mutex.Unlock()
+	if !s.context.Sampled {
+		return
+	}
+	exporter := getExporter()
+	if exporter == nil {
+		return
+	}
+	record := &SpanRecord{
+		Attributes: s.attributes,
+		EndTime:    endTime,
+		Error:      s.errorText,
+		Kind:       s.kind,
+		Name:       s.name,
+		Service:    serviceName,
+		SpanId:     hex.EncodeToString(s.context.SpanId[:]),
+		StartTime:  s.startTime,
+		TraceId:    hex.EncodeToString(s.context.TraceId[:]),
+	}
+	if !isZero(s.parentId[:]) {
+		record.ParentSpanId = hex.EncodeToString(s.parentId[:])
+	}
+	exporter.ExportSpan(record)
+}
+
+func (s *Span) setAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ended {
+		return
+	}
+	if s.attributes == nil {
+		s.attributes = make(map[string]string)
+	}
+	s.attributes[key] = value
+}
+
+func (s *Span) setError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ended {
+		return
+	}
+	s.errorText = err.Error()
+}
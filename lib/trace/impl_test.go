@@ -0,0 +1,96 @@
+package trace
+
+import (
+	"errors"
+	"testing"
+)
+
+type testExporterType struct {
+	spans []*SpanRecord
+}
+
+func (e *testExporterType) ExportSpan(span *SpanRecord) {
+	e.spans = append(e.spans, span)
+}
+
+func TestParseTraceParent(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceParent(traceParent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.Sampled {
+		t.Error("sampled flag not set")
+	}
+	if result := sc.TraceParent(); result != traceParent {
+		t.Errorf("TraceParent: %s != %s", result, traceParent)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceParent(bad); err == nil {
+			t.Errorf("no error parsing: \"%s\"", bad)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	exporter := &testExporterType{}
+	SetExporter(exporter)
+	defer SetExporter(nil)
+	parent := StartServerSpan("Test.Method",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	child := StartSpan("child", parent)
+	child.SetAttribute("key", "value")
+	child.SetError(errors.New("failed"))
+	child.End()
+	child.End() // Must be ignored.
+	parent.End()
+	if len(exporter.spans) != 2 {
+		t.Fatalf("exported %d spans, expected 2", len(exporter.spans))
+	}
+	childRecord := exporter.spans[0]
+	parentRecord := exporter.spans[1]
+	if parentRecord.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("wrong parent trace ID: %s", parentRecord.TraceId)
+	}
+	if parentRecord.ParentSpanId != "00f067aa0ba902b7" {
+		t.Errorf("wrong parent span ID: %s", parentRecord.ParentSpanId)
+	}
+	if parentRecord.Kind != KindServer {
+		t.Errorf("wrong parent kind: %d", parentRecord.Kind)
+	}
+	if childRecord.TraceId != parentRecord.TraceId {
+		t.Errorf("child trace ID: %s != %s",
+			childRecord.TraceId, parentRecord.TraceId)
+	}
+	if childRecord.ParentSpanId != parentRecord.SpanId {
+		t.Errorf("child parent ID: %s != %s",
+			childRecord.ParentSpanId, parentRecord.SpanId)
+	}
+	if childRecord.Attributes["key"] != "value" {
+		t.Error("missing attribute")
+	}
+	if childRecord.Error != "failed" {
+		t.Errorf("wrong error: %s", childRecord.Error)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	SetExporter(nil)
+	span := StartSpan("disabled", nil)
+	if span != nil {
+		t.Fatal("span created when tracing disabled")
+	}
+	span.SetAttribute("key", "value")
+	span.End()
+	if span.TraceParent() != "" {
+		t.Error("non-empty traceparent for nil span")
+	}
+}
@@ -0,0 +1,188 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	otlpBatchSize     = 512
+	otlpFlushInterval = 5 * time.Second
+	otlpQueueLength   = 4096
+)
+
+type otlpExporter struct {
+	client  *http.Client
+	queue   chan *SpanRecord
+	url     string
+	warning bool
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    uint   `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Kind              uint           `json:"kind"`
+	Name              string         `json:"name"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	SpanId            string         `json:"spanId"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	Status            otlpStatus     `json:"status"`
+	TraceId           string         `json:"traceId"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func newOtlpExporter(rawUrl string) (*otlpExporter, error) {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme: %s", parsedUrl.Scheme)
+	}
+	if parsedUrl.Path == "" || parsedUrl.Path == "/" {
+		parsedUrl.Path = "/v1/traces"
+	}
+	e := &otlpExporter{
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan *SpanRecord, otlpQueueLength),
+		url:    parsedUrl.String(),
+	}
+	go e.loop()
+	return e, nil
+}
+
+func makeOtlpSpan(record *SpanRecord) otlpSpan {
+	span := otlpSpan{
+		EndTimeUnixNano:   strconv.FormatInt(record.EndTime.UnixNano(), 10),
+		Kind:              record.Kind,
+		Name:              record.Name,
+		ParentSpanId:      record.ParentSpanId,
+		SpanId:            record.SpanId,
+		StartTimeUnixNano: strconv.FormatInt(record.StartTime.UnixNano(), 10),
+		TraceId:           record.TraceId,
+	}
+	if record.Error != "" {
+		span.Status = otlpStatus{Code: 2, Message: record.Error}
+	}
+	keys := make([]string, 0, len(record.Attributes))
+	for key := range record.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		span.Attributes = append(span.Attributes, otlpKeyValue{
+			Key:   key,
+			Value: otlpAnyValue{StringValue: record.Attributes[key]},
+		})
+	}
+	return span
+}
+
+func (e *otlpExporter) ExportSpan(span *SpanRecord) {
+	select {
+	case e.queue <- span:
+	default: // Drop spans rather than block the caller.
+	}
+}
+
+func (e *otlpExporter) loop() {
+	ticker := time.NewTicker(otlpFlushInterval)
+	var batch []otlpSpan
+	for {
+		select {
+		case record := <-e.queue:
+			batch = append(batch, makeOtlpSpan(record))
+			if len(batch) < otlpBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) < 1 {
+				continue
+			}
+		}
+		if err := e.send(batch); err != nil {
+			if !e.warning {
+				fmt.Fprintf(os.Stderr, "error exporting trace spans: %s\n", err)
+				e.warning = true
+			}
+		} else {
+			e.warning = false
+		}
+		batch = nil
+	}
+}
+
+func (e *otlpExporter) send(spans []otlpSpan) error {
+	request := otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpKeyValue{{
+					Key:   "service.name",
+					Value: otlpAnyValue{StringValue: serviceName},
+				}},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{
+					Name: "github.com/Cloud-Foundations/Dominator/lib/trace",
+				},
+				Spans: spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", e.url, resp.Status)
+	}
+	return nil
+}
@@ -59,17 +59,24 @@ func Setup(config Config, params Params) *htmlWriter {
 		publicMethods = append(publicMethods, "CheckObjects")
 	}
 	if config.AllowPublicGetObjects {
-		publicMethods = append(publicMethods, "GetObjects")
+		publicMethods = append(publicMethods, "GetObjectDelta", "GetObjects")
 	}
 	if config.AllowUnauthenticatedReads {
 		unauthenticatedMethods = append(unauthenticatedMethods,
 			"CheckObjects",
+			"GetObjectDelta",
 			"GetObjects",
 		)
 	}
 	srpc.RegisterNameWithOptions("ObjectServer", srpcObj,
 		srpc.ReceiverOptions{
-			PublicMethods:          publicMethods,
+			PublicMethods: publicMethods,
+			ReadOnlyMethods: []string{
+				"CheckObjects",
+				"GetObjectDelta",
+				"GetObjects",
+				"TestBandwidth",
+			},
 			UnauthenticatedMethods: unauthenticatedMethods,
 		})
 	tricorder.RegisterMetric("/get-requests",
@@ -0,0 +1,38 @@
+package rpcd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Cloud-Foundations/Dominator/lib/rsync"
+	"github.com/Cloud-Foundations/Dominator/lib/srpc"
+	"github.com/Cloud-Foundations/Dominator/proto/objectserver"
+)
+
+func (objSrv *srpcType) GetObjectDelta(conn *srpc.Conn) error {
+	objSrv.getSemaphore <- true
+	defer releaseSemaphore(objSrv.getSemaphore)
+	var request objectserver.GetObjectDeltaRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	size, reader, err := objSrv.objectServer.GetObject(request.Hash)
+	if err != nil {
+		return conn.Encode(objectserver.GetObjectDeltaResponse{
+			Error: err.Error()})
+	}
+	defer reader.Close()
+	readSeeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		return conn.Encode(objectserver.GetObjectDeltaResponse{
+			Error: fmt.Sprintf("object: %x is not seekable", request.Hash)})
+	}
+	response := objectserver.GetObjectDeltaResponse{Size: size}
+	if err := conn.Encode(response); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	return rsync.ServeBlocks(conn, conn, conn, readSeeker, size)
+}
@@ -148,6 +148,7 @@ type ListVMsInLocationResponse struct {
 
 type Machine struct {
 	MachineData
+	BmcType                 string       `json:",omitempty"` // Default: ipmi.
 	GatewaySubnetId         string       `json:",omitempty"`
 	IPMI                    NetworkEntry `json:",omitempty"`
 	Location                string       `json:",omitempty"`
@@ -175,11 +176,12 @@ type MoveIpAddressesResponse struct {
 }
 
 type NetworkEntry struct {
-	Hostname       string       `json:",omitempty"`
-	HostIpAddress  net.IP       `json:",omitempty"`
-	HostMacAddress HardwareAddr `json:",omitempty"`
-	SubnetId       string       `json:",omitempty"`
-	VlanTrunk      bool         `json:",omitempty"`
+	Hostname        string       `json:",omitempty"`
+	HostIpAddress   net.IP       `json:",omitempty"`
+	HostIpv6Address net.IP       `json:",omitempty"`
+	HostMacAddress  HardwareAddr `json:",omitempty"`
+	SubnetId        string       `json:",omitempty"`
+	VlanTrunk       bool         `json:",omitempty"`
 }
 
 type PowerOnMachineRequest struct {
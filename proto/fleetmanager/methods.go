@@ -158,6 +158,9 @@ func (left *Machine) Equal(right *Machine) bool {
 	if left.ArchitectureType != right.ArchitectureType {
 		return false
 	}
+	if left.BmcType != right.BmcType {
+		return false
+	}
 	if left.GatewaySubnetId != right.GatewaySubnetId {
 		return false
 	}
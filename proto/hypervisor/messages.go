@@ -6,6 +6,7 @@ import (
 
 	"github.com/Cloud-Foundations/Dominator/lib/filesystem"
 	"github.com/Cloud-Foundations/Dominator/lib/filter"
+	"github.com/Cloud-Foundations/Dominator/lib/hash"
 	"github.com/Cloud-Foundations/Dominator/lib/tags"
 	"github.com/Cloud-Foundations/Dominator/lib/types"
 )
@@ -70,8 +71,9 @@ type AcknowledgeVmResponse struct {
 }
 
 type Address struct {
-	IpAddress  net.IP `json:",omitempty"`
-	MacAddress string
+	IpAddress   net.IP `json:",omitempty"`
+	Ipv6Address net.IP `json:",omitempty"`
+	MacAddress  string
 }
 
 type AddressList []Address
@@ -87,6 +89,16 @@ type AddVmVolumesResponse struct {
 
 type ArchitectureType uint
 
+type BackupVmRequest struct {
+	ForceIfNotStopped bool
+	IpAddress         net.IP
+}
+
+type BackupVmResponse struct {
+	Error        string
+	ManifestHash hash.Hash // Hash of the VmBackupManifest object.
+}
+
 type BecomePrimaryVmOwnerRequest struct {
 	IpAddress net.IP
 }
@@ -930,6 +942,8 @@ type Subnet struct {
 	AllowedUsers      []string  `json:",omitempty"`
 	FirstDynamicIP    net.IP    `json:",omitempty"`
 	LastDynamicIP     net.IP    `json:",omitempty"`
+	Ipv6Gateway       net.IP    `json:",omitempty"`
+	Ipv6Mask          net.IP    `json:",omitempty"`
 	Tags              tags.Tags `json:",omitempty"`
 }
 
@@ -970,6 +984,7 @@ type VmInfo struct {
 	IdentityName         string           `json:",omitempty"`
 	ImageName            string           `json:",omitempty"`
 	ImageURL             string           `json:",omitempty"`
+	LastBackup           *VmBackup        `json:",omitempty"`
 	MachineType          MachineType      `json:",omitempty"`
 	MemoryInMiB          uint64
 	MilliCPUs            uint
@@ -991,6 +1006,29 @@ type VmInfo struct {
 	WatchdogModel        WatchdogModel  `json:",omitempty"`
 }
 
+type VmBackup struct {
+	ImageName    string    // Image which references the backup objects.
+	ManifestHash hash.Hash // Hash of the VmBackupManifest object.
+	Time         time.Time
+}
+
+// VmBackupManifest is stored (JSON encoded) in an object server. The volumes
+// are stored as chunks (objects) of ChunkSize bytes (the last chunk may be
+// shorter). Chunks which contain only zeros are not stored and are recorded
+// with the zero hash.
+type VmBackupManifest struct {
+	ChunkSize uint64
+	Time      time.Time
+	UserData  []byte `json:",omitempty"`
+	VmInfo    VmInfo
+	Volumes   []VmBackupVolume
+}
+
+type VmBackupVolume struct {
+	Chunks []hash.Hash
+	Size   uint64
+}
+
 type Volume struct {
 	DFM         DfmParams         `json:",omitempty"`
 	Format      VolumeFormat      `json:",omitempty"`
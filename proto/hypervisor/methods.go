@@ -179,6 +179,9 @@ func (left *Address) Equal(right *Address) bool {
 	if !CompareIPs(left.IpAddress, right.IpAddress) {
 		return false
 	}
+	if !CompareIPs(left.Ipv6Address, right.Ipv6Address) {
+		return false
+	}
 	if left.MacAddress != right.MacAddress {
 		return false
 	}
@@ -186,14 +189,26 @@ func (left *Address) Equal(right *Address) bool {
 }
 
 func (address *Address) Set(value string) error {
-	if split := strings.Split(value, ";"); len(split) != 2 {
+	split := strings.Split(value, ";")
+	if len(split) != 2 && len(split) != 3 {
 		return errors.New("malformed address pair: " + value)
 	} else if ip := net.ParseIP(split[1]); ip == nil {
 		return errors.New("unable to parse IP: " + split[1])
 	} else if ip4 := ip.To4(); ip4 == nil {
 		return errors.New("address is not IPv4: " + split[1])
 	} else {
-		*address = Address{IpAddress: ip4, MacAddress: split[0]}
+		newAddress := Address{IpAddress: ip4, MacAddress: split[0]}
+		if len(split) == 3 {
+			ip6 := net.ParseIP(split[2])
+			if ip6 == nil {
+				return errors.New("unable to parse IP: " + split[2])
+			}
+			if ip6.To4() != nil {
+				return errors.New("address is not IPv6: " + split[2])
+			}
+			newAddress.Ipv6Address = ip6
+		}
+		*address = newAddress
 		return nil
 	}
 }
@@ -203,6 +218,10 @@ func (address *Address) Shrink() {
 }
 
 func (address *Address) String() string {
+	if len(address.Ipv6Address) > 0 {
+		return address.IpAddress.String() + ";" + address.MacAddress + ";" +
+			address.Ipv6Address.String()
+	}
 	return address.IpAddress.String() + ";" + address.MacAddress
 }
 
@@ -486,6 +505,12 @@ func (left *Subnet) Equal(right *Subnet) bool {
 	if !CompareIPs(left.FirstDynamicIP, right.FirstDynamicIP) {
 		return false
 	}
+	if !CompareIPs(left.Ipv6Gateway, right.Ipv6Gateway) {
+		return false
+	}
+	if !CompareIPs(left.Ipv6Mask, right.Ipv6Mask) {
+		return false
+	}
 	if !left.Tags.Equal(right.Tags) {
 		return false
 	}
@@ -512,6 +537,19 @@ func (subnet *Subnet) Shrink() {
 	}
 }
 
+func (left *VmBackup) Equal(right *VmBackup) bool {
+	if left == nil || right == nil {
+		return left == right
+	}
+	if left.ImageName != right.ImageName {
+		return false
+	}
+	if left.ManifestHash != right.ManifestHash {
+		return false
+	}
+	return left.Time.Equal(right.Time)
+}
+
 func (left *VmInfo) Equal(right *VmInfo) bool {
 	if !left.Address.Equal(&right.Address) {
 		return false
@@ -561,6 +599,9 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if left.ImageURL != right.ImageURL {
 		return false
 	}
+	if !left.LastBackup.Equal(right.LastBackup) {
+		return false
+	}
 	if left.MachineType != right.MachineType {
 		return false
 	}
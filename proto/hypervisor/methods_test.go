@@ -78,6 +78,7 @@ func makeVmInfo(t *testing.T, nonZeroFill bool, invertIndex int,
 			case "SecondaryAddresses":
 				addresses := []Address{{
 					[]byte{1, 2, 3, 4},
+					[]byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
 					"01:02:03",
 				}}
 				fieldValue.Set(reflect.ValueOf(addresses))
@@ -106,6 +107,7 @@ func makeVmInfo(t *testing.T, nonZeroFill bool, invertIndex int,
 			case "Address":
 				address := Address{
 					[]byte{1, 2, 3, 4},
+					[]byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
 					"01:02:03",
 				}
 				fieldValue.Set(reflect.ValueOf(address))
@@ -16,6 +16,16 @@ type AddImageRequest struct {
 
 type AddImageResponse struct{}
 
+type ChangeChannelOwnerRequest struct {
+	ChannelName   string
+	DirectoryName string
+	OwnerGroup    string
+}
+
+type ChangeChannelOwnerResponse struct {
+	Error string
+}
+
 type ChangeImageExpirationRequest struct {
 	ExpiresAt time.Time
 	ImageName string
@@ -81,6 +91,15 @@ type FindLatestImageResponse struct {
 	Error     string
 }
 
+type GetChannelsRequest struct {
+	DirectoryName string
+}
+
+type GetChannelsResponse struct {
+	Channels map[string]image.Channel // Key: channel name.
+	Error    string
+}
+
 type GetImageArchiveRequest struct {
 	ImageName string
 }
@@ -159,7 +178,8 @@ type GetImageUsageEstimateResponse struct {
 // The server sends a stream of ImageUpdate messages.
 
 type GetFilteredImageUpdatesRequest struct {
-	IgnoreExpiring bool
+	IgnoreExpiring    bool
+	IncludeTombstones bool // Send deleted images in the initial list.
 }
 
 type GetObjectStatisticsForImagesRequest struct {
@@ -190,7 +210,9 @@ type ImageArchive struct {
 } // HMAC-SHA512 checksum is written after GOB encoded data.
 
 type ImageUpdate struct {
-	Name      string // "" signifies initial list is sent, changes to follow.
+	CreatedOn time.Time // Set for OperationAddImage.
+	DeletedOn time.Time // Set for OperationDeleteImage if known.
+	Name      string    // "" signifies initial list is sent, changes to follow.
 	Directory *image.Directory
 	Operation uint
 }
@@ -251,6 +273,15 @@ type MakeDirectoryRequest struct {
 
 type MakeDirectoryResponse struct{}
 
+type ResolveChannelRequest struct {
+	Reference string // Of the form: "directory@channel".
+}
+
+type ResolveChannelResponse struct {
+	Error     string
+	ImageName string
+}
+
 type RestoreImageFromArchiveRequest struct {
 	ExpiresAt   time.Time
 	ArchiveData []byte // GOB encoding of ImageArchive followed by HMAC.
@@ -260,3 +291,23 @@ type RestoreImageFromArchiveResponse struct {
 	Error             string
 	ReplicationMaster string // If not empty, go here instead.
 }
+
+type SetChannelRequest struct {
+	ChannelName   string
+	Comment       string
+	DirectoryName string
+	ImageName     string // Empty: delete the channel.
+}
+
+type SetChannelResponse struct {
+	Error string
+}
+
+type SetRetentionPolicyRequest struct {
+	DirectoryName   string
+	RetentionPolicy *image.RetentionPolicy // nil: clear the policy.
+}
+
+type SetRetentionPolicyResponse struct {
+	Error string
+}
@@ -14,6 +14,12 @@ const (
 	StatusStreamExporting    = 8
 	StatusStreamNoFileSystem = 9
 	StatusStreamTransferring = 10
+
+	// Export types which are built into the image-unpacker. Other types are
+	// passed to the external export tool.
+	ExportTypeQcow2 = "qcow2"
+	ExportTypeRaw   = "raw"
+	ExportTypeVhd   = "vhd"
 )
 
 type DeviceInfo struct {
@@ -46,7 +52,7 @@ type ClaimDeviceResponse struct{}
 type ExportImageRequest struct {
 	StreamName  string
 	Type        string
-	Destination string
+	Destination string // Directory, filename or HTTP(S) URL for native types.
 }
 
 type ExportImageResponse struct{}
@@ -75,8 +81,10 @@ type GetStatusResponse struct {
 }
 
 type ImageStreamInfo struct {
-	DeviceId string
-	Status   StreamStatus
+	DeviceId         string
+	ExportBytesDone  uint64 `json:",omitempty"`
+	ExportBytesTotal uint64 `json:",omitempty"`
+	Status           StreamStatus
 }
 
 type PrepareForCaptureRequest struct {
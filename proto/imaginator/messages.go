@@ -7,6 +7,7 @@ import (
 )
 
 type BuildImageRequest struct {
+	DisableBuildCache     bool
 	DisableRecursiveBuild bool
 	ExpiresIn             time.Duration
 	GitBranch             string
@@ -21,6 +22,7 @@ type BuildImageRequest struct {
 type BuildImageResponse struct {
 	Image                     *image.Image
 	ImageName                 string
+	BuildCacheStatus          string // hit, miss, disabled or empty.
 	BuildLog                  []byte
 	ErrorString               string
 	NeedSourceImage           bool // True if source image missing/too old.
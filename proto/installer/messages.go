@@ -11,6 +11,15 @@ const (
 
 type FileSystemType uint
 
+type LogicalVolume struct {
+	BytesPerInode   types.Bytes      `json:",omitempty"`
+	FileSystemLabel string           `json:",omitempty"`
+	FileSystemType  FileSystemType   `json:",omitempty"`
+	MountPoint      string           `json:",omitempty"`
+	Name            string           `json:",omitempty"`
+	SizePercentage  types.Percentage `json:",omitempty"` // 0: remaining space.
+}
+
 type Partition struct {
 	BytesPerInode            types.Bytes      `json:",omitempty"`
 	FileSystemLabel          string           `json:",omitempty"`
@@ -24,8 +33,17 @@ type Partition struct {
 }
 
 type StorageLayout struct {
-	BootDriveLayout          []Partition `json:",omitempty"`
-	ExtraMountPointsBasename string      `json:",omitempty"`
-	Encrypt                  bool        `json:",omitempty"`
-	UseKexec                 bool        `json:",omitempty"`
+	BootDriveLayout          []Partition   `json:",omitempty"`
+	DataDriveRaidLevel       string        `json:",omitempty"` // "": no RAID.
+	ExtraMountPointsBasename string        `json:",omitempty"`
+	Encrypt                  bool          `json:",omitempty"`
+	MirrorBootDrive          bool          `json:",omitempty"`
+	UseKexec                 bool          `json:",omitempty"`
+	VolumeGroups             []VolumeGroup `json:",omitempty"`
+}
+
+type VolumeGroup struct {
+	LogicalVolumes  []LogicalVolume `json:",omitempty"`
+	Name            string          `json:",omitempty"`
+	PhysicalVolumes []string        `json:",omitempty"` // Extra mount points.
 }
@@ -2,6 +2,8 @@ package installer
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 )
 
 const (
@@ -9,6 +11,13 @@ const (
 )
 
 var (
+	dataDriveRaidLevels = map[string]struct{}{
+		"0":  {},
+		"1":  {},
+		"5":  {},
+		"6":  {},
+		"10": {},
+	}
 	fileSystemTypeToText = map[FileSystemType]string{
 		FileSystemTypeExt4: "ext4",
 		FileSystemTypeVfat: "vfat",
@@ -24,6 +33,28 @@ func init() {
 	}
 }
 
+// checkLvmName returns an error if name is not a valid LVM object name.
+func checkLvmName(name string) error {
+	if name == "" {
+		return errors.New("missing name")
+	}
+	if name[0] == '-' || name == "." || name == ".." {
+		return fmt.Errorf("invalid name: %s", name)
+	}
+	for _, ch := range name {
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' ||
+			ch >= '0' && ch <= '9' {
+			continue
+		}
+		switch ch {
+		case '+', '-', '.', '_':
+			continue
+		}
+		return fmt.Errorf("invalid character: '%c' in name: %s", ch, name)
+	}
+	return nil
+}
+
 func (fileSystemType FileSystemType) MarshalText() ([]byte, error) {
 	if text := fileSystemType.String(); text == fileSystemTypeUnknown {
 		return nil, errors.New(text)
@@ -72,14 +103,135 @@ func (left *StorageLayout) Equal(right *StorageLayout) bool {
 			return false
 		}
 	}
+	if left.DataDriveRaidLevel != right.DataDriveRaidLevel {
+		return false
+	}
 	if left.ExtraMountPointsBasename != right.ExtraMountPointsBasename {
 		return false
 	}
 	if left.Encrypt != right.Encrypt {
 		return false
 	}
+	if left.MirrorBootDrive != right.MirrorBootDrive {
+		return false
+	}
 	if left.UseKexec != right.UseKexec {
 		return false
 	}
+	if len(left.VolumeGroups) != len(right.VolumeGroups) {
+		return false
+	}
+	for index, leftVolumeGroup := range left.VolumeGroups {
+		if !leftVolumeGroup.Equal(&right.VolumeGroups[index]) {
+			return false
+		}
+	}
 	return true
 }
+
+// Check returns an error if the storage layout is not valid.
+func (layout *StorageLayout) Check() error {
+	if layout.DataDriveRaidLevel != "" {
+		if _, ok := dataDriveRaidLevels[layout.DataDriveRaidLevel]; !ok {
+			return fmt.Errorf("unsupported DataDriveRaidLevel: %s",
+				layout.DataDriveRaidLevel)
+		}
+	}
+	volumeGroupNames := make(map[string]struct{}, len(layout.VolumeGroups))
+	physicalVolumes := make(map[string]string)
+	for _, volumeGroup := range layout.VolumeGroups {
+		if err := checkLvmName(volumeGroup.Name); err != nil {
+			return fmt.Errorf("volume group: %s", err)
+		}
+		if _, ok := volumeGroupNames[volumeGroup.Name]; ok {
+			return fmt.Errorf("duplicate volume group: %s", volumeGroup.Name)
+		}
+		volumeGroupNames[volumeGroup.Name] = struct{}{}
+		if len(volumeGroup.PhysicalVolumes) < 1 {
+			return fmt.Errorf("volume group: %s has no PhysicalVolumes",
+				volumeGroup.Name)
+		}
+		for _, physicalVolume := range volumeGroup.PhysicalVolumes {
+			if layout.ExtraMountPointsBasename == "" ||
+				!strings.HasPrefix(physicalVolume,
+					layout.ExtraMountPointsBasename) {
+				return fmt.Errorf(
+					"volume group: %s: PhysicalVolume: %s is not an extra mount point",
+					volumeGroup.Name, physicalVolume)
+			}
+			if name, ok := physicalVolumes[physicalVolume]; ok {
+				return fmt.Errorf(
+					"PhysicalVolume: %s used by volume groups: %s and %s",
+					physicalVolume, name, volumeGroup.Name)
+			}
+			physicalVolumes[physicalVolume] = volumeGroup.Name
+		}
+		if err := volumeGroup.check(); err != nil {
+			return fmt.Errorf("volume group: %s: %s", volumeGroup.Name, err)
+		}
+	}
+	return nil
+}
+
+func (left *VolumeGroup) Equal(right *VolumeGroup) bool {
+	if left.Name != right.Name {
+		return false
+	}
+	if len(left.LogicalVolumes) != len(right.LogicalVolumes) {
+		return false
+	}
+	for index, leftLogicalVolume := range left.LogicalVolumes {
+		if leftLogicalVolume != right.LogicalVolumes[index] {
+			return false
+		}
+	}
+	if len(left.PhysicalVolumes) != len(right.PhysicalVolumes) {
+		return false
+	}
+	for index, leftPhysicalVolume := range left.PhysicalVolumes {
+		if leftPhysicalVolume != right.PhysicalVolumes[index] {
+			return false
+		}
+	}
+	return true
+}
+
+func (volumeGroup *VolumeGroup) check() error {
+	if len(volumeGroup.LogicalVolumes) < 1 {
+		return errors.New("no LogicalVolumes")
+	}
+	names := make(map[string]struct{}, len(volumeGroup.LogicalVolumes))
+	var numRemaining, totalPercentage uint
+	for _, logicalVolume := range volumeGroup.LogicalVolumes {
+		if err := checkLvmName(logicalVolume.Name); err != nil {
+			return fmt.Errorf("logical volume: %s", err)
+		}
+		if _, ok := names[logicalVolume.Name]; ok {
+			return fmt.Errorf("duplicate logical volume: %s",
+				logicalVolume.Name)
+		}
+		names[logicalVolume.Name] = struct{}{}
+		switch logicalVolume.MountPoint {
+		case "":
+		case "/", "/boot":
+			return fmt.Errorf("logical volume: %s cannot be mounted on: %s",
+				logicalVolume.Name, logicalVolume.MountPoint)
+		}
+		if logicalVolume.FileSystemType.String() == fileSystemTypeUnknown {
+			return fmt.Errorf("logical volume: %s: %s",
+				logicalVolume.Name, fileSystemTypeUnknown)
+		}
+		if logicalVolume.SizePercentage < 1 {
+			numRemaining++
+		}
+		totalPercentage += uint(logicalVolume.SizePercentage)
+	}
+	if numRemaining > 1 {
+		return errors.New(
+			"more than one logical volume using the remaining space")
+	}
+	if totalPercentage > 100 {
+		return fmt.Errorf("logical volumes use %d%% of space", totalPercentage)
+	}
+	return nil
+}
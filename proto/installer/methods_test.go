@@ -0,0 +1,170 @@
+package installer
+
+import (
+	"testing"
+)
+
+func TestStorageLayoutCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(layout *StorageLayout)
+		valid  bool
+	}{
+		{"valid", func(layout *StorageLayout) {}, true},
+		{"no RAID", func(layout *StorageLayout) {
+			layout.DataDriveRaidLevel = ""
+		}, true},
+		{"no volume groups", func(layout *StorageLayout) {
+			layout.VolumeGroups = nil
+		}, true},
+		{"bad RAID level", func(layout *StorageLayout) {
+			layout.DataDriveRaidLevel = "3"
+		}, false},
+		{"bad volume group name", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].Name = "vg/0"
+		}, false},
+		{"volume group name starting with -", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].Name = "-vg"
+		}, false},
+		{"duplicate volume group", func(layout *StorageLayout) {
+			layout.VolumeGroups = append(layout.VolumeGroups,
+				VolumeGroup{
+					Name:            "vg0",
+					PhysicalVolumes: []string{"/data/2"},
+					LogicalVolumes:  []LogicalVolume{{Name: "lv"}},
+				})
+		}, false},
+		{"no physical volumes", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].PhysicalVolumes = nil
+		}, false},
+		{"physical volume not extra mount point", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].PhysicalVolumes = []string{"/home"}
+		}, false},
+		{"no extra mount points basename", func(layout *StorageLayout) {
+			layout.ExtraMountPointsBasename = ""
+		}, false},
+		{"shared physical volume", func(layout *StorageLayout) {
+			layout.VolumeGroups = append(layout.VolumeGroups,
+				VolumeGroup{
+					Name:            "vg1",
+					PhysicalVolumes: []string{"/data/1"},
+					LogicalVolumes:  []LogicalVolume{{Name: "lv"}},
+				})
+		}, false},
+		{"no logical volumes", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].LogicalVolumes = nil
+		}, false},
+		{"duplicate logical volume", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].LogicalVolumes[1].Name = "logs"
+		}, false},
+		{"bad logical volume name", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].LogicalVolumes[0].Name = "log files"
+		}, false},
+		{"logical volume mounted on /", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].LogicalVolumes[0].MountPoint = "/"
+		}, false},
+		{"logical volume mounted on /boot", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].LogicalVolumes[0].MountPoint = "/boot"
+		}, false},
+		{"unknown file-system type", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].LogicalVolumes[0].FileSystemType = 99
+		}, false},
+		{"two logical volumes using remaining space",
+			func(layout *StorageLayout) {
+				layout.VolumeGroups[0].LogicalVolumes[1].SizePercentage = 0
+			}, false},
+		{"exactly 100%", func(layout *StorageLayout) {
+			lvs := layout.VolumeGroups[0].LogicalVolumes
+			lvs[0].SizePercentage = 60
+			lvs[1].SizePercentage = 40
+			layout.VolumeGroups[0].LogicalVolumes = lvs[:2]
+		}, true},
+		{"over 100%", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].LogicalVolumes[0].SizePercentage = 95
+		}, false},
+	}
+	for _, test := range tests {
+		layout := StorageLayout{
+			BootDriveLayout: []Partition{
+				{MountPoint: "/"},
+				{MountPoint: "/data/0"},
+			},
+			DataDriveRaidLevel:       "10",
+			ExtraMountPointsBasename: "/data/",
+			VolumeGroups: []VolumeGroup{
+				{
+					Name:            "vg0",
+					PhysicalVolumes: []string{"/data/0", "/data/1"},
+					LogicalVolumes: []LogicalVolume{
+						{
+							Name:           "logs",
+							MountPoint:     "/var/log",
+							SizePercentage: 20,
+						},
+						{Name: "swap", SizePercentage: 10},
+						{Name: "home", MountPoint: "/home"},
+					},
+				},
+			},
+		}
+		test.modify(&layout)
+		err := layout.Check()
+		if test.valid && err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestStorageLayoutEqual(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(layout *StorageLayout)
+		equal  bool
+	}{
+		{"identical", func(layout *StorageLayout) {}, true},
+		{"different logical volumes", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].LogicalVolumes[0].SizePercentage = 30
+		}, false},
+		{"different physical volumes", func(layout *StorageLayout) {
+			layout.VolumeGroups[0].PhysicalVolumes[1] = "/data/2"
+		}, false},
+		{"different RAID levels", func(layout *StorageLayout) {
+			layout.DataDriveRaidLevel = "5"
+		}, false},
+	}
+	for _, test := range tests {
+		layouts := make([]StorageLayout, 2)
+		for index := range layouts {
+			layouts[index] = StorageLayout{
+				BootDriveLayout: []Partition{
+					{MountPoint: "/"},
+					{MountPoint: "/data/0"},
+				},
+				DataDriveRaidLevel:       "10",
+				ExtraMountPointsBasename: "/data/",
+				VolumeGroups: []VolumeGroup{
+					{
+						Name:            "vg0",
+						PhysicalVolumes: []string{"/data/0", "/data/1"},
+						LogicalVolumes: []LogicalVolume{
+							{
+								Name:           "logs",
+								MountPoint:     "/var/log",
+								SizePercentage: 20,
+							},
+							{Name: "swap", SizePercentage: 10},
+							{Name: "home", MountPoint: "/home"},
+						},
+					},
+				},
+			}
+		}
+		test.modify(&layouts[1])
+		if equal := layouts[0].Equal(&layouts[1]); equal != test.equal {
+			t.Errorf("%s: expected equal: %v, got: %v",
+				test.name, test.equal, equal)
+		}
+	}
+}
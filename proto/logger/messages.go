@@ -1,5 +1,20 @@
 package logger
 
+import (
+	"time"
+)
+
+type AuditRecord struct {
+	Duration   time.Duration
+	Error      string   `json:",omitempty"`
+	GroupList  []string `json:",omitempty"`
+	Method     string
+	RemoteAddr string `json:",omitempty"`
+	Request    string `json:",omitempty"` // Summary of the request.
+	StartTime  time.Time
+	Username   string `json:",omitempty"`
+}
+
 type DebugRequest struct {
 	Args  []string
 	Name  string
@@ -8,6 +23,19 @@ type DebugRequest struct {
 
 type DebugResponse struct{}
 
+type GetAuditRecordsRequest struct {
+	FailedOnly bool
+	MaxRecords uint   // Zero: default limit. Most recent records are returned.
+	Method     string // Empty: all methods. May be a shell pattern.
+	Since      time.Time
+	Username   string // Empty: all users.
+}
+
+type GetAuditRecordsResponse struct {
+	Error   string
+	Records []AuditRecord // Oldest first.
+}
+
 type GetStackTraceRequest struct{}
 
 type GetStackTraceResponse struct {
@@ -40,6 +40,19 @@ type GetObjectsResponse struct {
 	ObjectSizes    []uint64
 } // Object datas are streamed afterwards.
 
+// The GetObjectDelta() RPC is fully streamed. The client sends a
+// GetObjectDeltaRequest and the server replies with a GetObjectDeltaResponse.
+// If there is no error, the proto/rsync.GetBlocks protocol follows, where the
+// client already has a similar (base) object.
+type GetObjectDeltaRequest struct {
+	Hash hash.Hash
+}
+
+type GetObjectDeltaResponse struct {
+	Error string
+	Size  uint64
+}
+
 type ImportObjectsRequest struct {
 	BaseRemoteUrl string
 	// TODO(rgooch): add: CheckCollisions bool
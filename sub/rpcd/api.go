@@ -103,6 +103,11 @@ func Setup(config Config, params Params) *HtmlWriter {
 			PublicMethods: []string{
 				"GetConfiguration",
 				"Poll",
+			},
+			ReadOnlyMethods: []string{
+				"GetConfiguration",
+				"GetFiles",
+				"Poll",
 			}})
 	addObjectsHandler := &addObjectsHandlerType{
 		objectsDir:           config.ObjectsDirectoryName,